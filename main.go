@@ -10,6 +10,7 @@ import (
 	"postie/pkg/cli"
 	"postie/pkg/client"
 	"postie/pkg/commands"
+	"postie/pkg/display"
 	"postie/pkg/middleware"
 )
 
@@ -20,13 +21,22 @@ func main() {
 	// Add commands
 	app.AddCommand(commands.HTTPCommands())
 	app.AddCommand(commands.EnvCommands())
+	app.AddCommand(commands.VarsCommands())
+	app.AddCommand(commands.SessionCommands())
+	app.AddCommand(commands.ContractCommands())
+	app.AddCommand(commands.ListenCommands())
 	app.AddCommand(commands.ContextCommands())
+	app.AddCommand(commands.ResponsesCommands())
+	app.AddCommand(commands.HistoryCommands())
+	app.AddCommand(commands.ReportCommands())
+	app.AddCommand(commands.DocsCommands())
+	app.AddCommand(commands.PluginCommands())
 	app.AddCommand(demoCommand())
 
 	// Run CLI
 	if err := app.Run(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
 
@@ -43,7 +53,7 @@ func demoCommand() *cli.Command {
 }
 
 func runDemo() {
-	fmt.Println("🚀 Running Postie Demo...")
+	fmt.Println("Running Postie Demo...")
 	fmt.Println()
 
 	// Demo 1: Basic GET request
@@ -61,7 +71,7 @@ func runDemo() {
 		fmt.Printf("Demo 1 failed: %v\n", err)
 	} else {
 		defer resp.Response.Body.Close()
-		fmt.Printf("✅ Status: %s, Duration: %v\n", resp.Status, resp.Duration)
+		fmt.Printf("%s Status: %s, Duration: %s\n", display.Glyphs().Pass, resp.Status, display.Duration(resp.Duration))
 	}
 
 	fmt.Println()
@@ -80,7 +90,7 @@ func runDemo() {
 		fmt.Printf("Demo 2 failed: %v\n", err)
 	} else {
 		defer resp2.Response.Body.Close()
-		fmt.Printf("✅ Status: %s, Duration: %v\n", resp2.Status, resp2.Duration)
+		fmt.Printf("%s Status: %s, Duration: %s\n", display.Glyphs().Pass, resp2.Status, display.Duration(resp2.Duration))
 	}
 
 	fmt.Println()
@@ -90,18 +100,18 @@ func runDemo() {
 
 	// API Key auth
 	apiKey := auth.NewAPIKeyAuth("X-API-Key", "your-api-key", "header")
-	fmt.Printf("✅ API Key Auth configured: %+v\n", apiKey)
+	fmt.Printf("%s API Key Auth configured: %+v\n", display.Glyphs().Pass, apiKey)
 
 	// Bearer token auth
 	bearer := auth.NewBearerTokenAuth("your-bearer-token")
-	fmt.Printf("✅ Bearer Token Auth configured: %+v\n", bearer)
+	fmt.Printf("%s Bearer Token Auth configured: %+v\n", display.Glyphs().Pass, bearer)
 
 	// Basic auth
 	basic := auth.NewBasicAuth("username", "password")
-	fmt.Printf("✅ Basic Auth configured: %+v\n", basic)
+	fmt.Printf("%s Basic Auth configured: %+v\n", display.Glyphs().Pass, basic)
 
 	fmt.Println()
-	fmt.Println("🎉 Demo completed! Try the new CLI commands:")
+	fmt.Println("Demo completed! Try the new CLI commands:")
 	fmt.Println("  postie http get --url https://httpbin.org/get")
 	fmt.Println("  postie http post --url https://httpbin.org/post --body '{\"test\":\"data\"}'")
 	fmt.Println("  postie collection create --name \"My API\" --file my-api.collection.json")
@@ -113,15 +123,15 @@ func printResponse(resp *client.Response) {
 	separator := "=================================================="
 	fmt.Println("\n" + separator)
 	fmt.Printf("Status: %s\n", resp.Status)
-	fmt.Printf("Duration: %v\n", resp.Duration)
-	fmt.Printf("Size: %d bytes\n", resp.Size())
+	fmt.Printf("Duration: %s\n", display.Duration(resp.Duration))
+	fmt.Printf("Size: %s\n", display.Size(resp.Size()))
 	fmt.Printf("Content-Type: %s\n", resp.ContentType())
 	fmt.Println(separator)
 
 	if resp.IsSuccess() {
-		fmt.Println("✅ Request successful")
+		fmt.Printf("%s Request successful\n", display.Glyphs().Pass)
 	} else if resp.IsError() {
-		fmt.Printf("❌ Request failed: %s\n", resp.Status)
+		fmt.Printf("%s Request failed: %s\n", display.Glyphs().Fail, resp.Status)
 	}
 
 	// Try to format JSON response
@@ -0,0 +1,143 @@
+// Package apidocs renders documentation for the requests in .http files as
+// Markdown or static HTML: one page per file, plus an index of the pages.
+package apidocs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"postie/pkg/httprequest"
+)
+
+// MaxSampleBytes is the longest sample response body shown on a page
+const MaxSampleBytes = 4096
+
+// Page documents the requests of one .http file
+type Page struct {
+	Title        string // Shown as the page heading
+	Source       string // Path of the .http file
+	Link         string // Path of the generated page, relative to the index
+	Requests     []Request
+	Environments []string // Columns of the variable table
+	Variables    []Variable
+}
+
+// Request documents one request as written in the file, with variables
+// left unexpanded
+type Request struct {
+	Name        string
+	Description string // From the comments above the request
+	Method      string
+	URL         string
+	Headers     []httprequest.Header
+	Body        string // Inline body
+	BodyFile    string // Path of a "< file" body
+	ContentType string
+	Sample      *Sample // Latest stored response, if any
+}
+
+// Sample is a stored response shown as an example
+type Sample struct {
+	Status      string
+	ContentType string
+	Timestamp   time.Time
+	Duration    int64 // Milliseconds
+	Body        string
+}
+
+// Variable is an environment variable used by the requests of a page
+type Variable struct {
+	Name   string
+	Values []string // One per environment; "" if the environment doesn't define it
+}
+
+// NewRequest converts a parsed request. Requests without a name are named
+// after their position in the file.
+func NewRequest(request *httprequest.Request, index int) Request {
+	doc := Request{
+		Name:        request.Name,
+		Description: strings.TrimSpace(strings.Join(request.Comments, "\n")),
+		Method:      request.Method,
+		Headers:     request.Headers,
+	}
+	if doc.Name == "" {
+		doc.Name = fmt.Sprintf("Request %d", index+1)
+	}
+	if request.URL != nil {
+		doc.URL = request.URL.Raw
+	}
+	if request.Body != nil {
+		doc.Body = strings.TrimSpace(request.Body.Content)
+		doc.BodyFile = request.Body.FilePath
+		doc.ContentType = request.Body.ContentType
+	}
+	for _, header := range request.Headers {
+		if strings.EqualFold(header.Name, "Content-Type") {
+			doc.ContentType = header.Value
+		}
+	}
+	return doc
+}
+
+// Anchor is the fragment linking to a request on its page
+func (r Request) Anchor() string {
+	var anchor strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(r.Name) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			if dash && anchor.Len() > 0 {
+				anchor.WriteByte('-')
+			}
+			anchor.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return anchor.String()
+}
+
+// SampleBody formats a sample response body for display: JSON is indented
+// and long bodies are cut at MaxSampleBytes
+func SampleBody(body, contentType string) string {
+	if strings.Contains(contentType, "json") {
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(body), "", "  ") == nil {
+			body = indented.String()
+		}
+	}
+	if len(body) > MaxSampleBytes {
+		body = body[:MaxSampleBytes] + "\n... (truncated)"
+	}
+	return body
+}
+
+// PageLink returns the path of the page generated for a .http file, with
+// the extension of the format
+func PageLink(source, extension string) string {
+	source = path.Clean(strings.ReplaceAll(source, "\\", "/"))
+	for strings.HasPrefix(source, "../") {
+		source = source[len("../"):]
+	}
+	source = strings.TrimLeft(source, "/")
+	return strings.TrimSuffix(source, path.Ext(source)) + extension
+}
+
+// language is the code fence language of a content type
+func language(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		return "json"
+	case strings.Contains(contentType, "xml"):
+		return "xml"
+	case strings.Contains(contentType, "html"):
+		return "html"
+	case strings.Contains(contentType, "graphql"):
+		return "graphql"
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package apidocs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"postie/pkg/httprequest"
+)
+
+const usersFile = `### Get user
+# Returns one user by ID
+GET {{baseUrl}}/users/{{id}}
+Accept: application/json
+
+### Create user
+POST {{baseUrl}}/users
+Content-Type: application/json
+Authorization: Bearer {{token}}
+
+{"name": "Ada | Lovelace"}
+
+###
+DELETE {{baseUrl}}/users/1
+`
+
+func testPage(t *testing.T) *Page {
+	t.Helper()
+	requestsFile, err := httprequest.ParseFile("users.http", usersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := &Page{
+		Title:        "users",
+		Source:       "api/users.http",
+		Link:         PageLink("api/users.http", ".md"),
+		Environments: []string{"dev", "prod"},
+		Variables: []Variable{
+			{Name: "baseUrl", Values: []string{"http://localhost", "https://api.example.com"}},
+			{Name: "token", Values: []string{"[REDACTED]", ""}},
+		},
+	}
+	for i := range requestsFile.Requests {
+		page.Requests = append(page.Requests, NewRequest(&requestsFile.Requests[i], i))
+	}
+	page.Requests[0].Sample = &Sample{
+		Status:      "200 OK",
+		ContentType: "application/json",
+		Timestamp:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:    12,
+		Body:        `{"id":1,"name":"<Ada>"}`,
+	}
+	return page
+}
+
+func TestNewRequest(t *testing.T) {
+	page := testPage(t)
+	if len(page.Requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(page.Requests))
+	}
+
+	get := page.Requests[0]
+	if get.Name != "Get user" || get.Method != "GET" || get.URL != "{{baseUrl}}/users/{{id}}" {
+		t.Errorf("unexpected request: %+v", get)
+	}
+	if get.Description != "Returns one user by ID" {
+		t.Errorf("description = %q", get.Description)
+	}
+
+	create := page.Requests[1]
+	if create.Body != `{"name": "Ada | Lovelace"}` || create.ContentType != "application/json" {
+		t.Errorf("unexpected body: %q (%s)", create.Body, create.ContentType)
+	}
+	if page.Requests[2].Name != "Request 3" {
+		t.Errorf("unnamed request is named %q", page.Requests[2].Name)
+	}
+}
+
+func TestAnchorAndPageLink(t *testing.T) {
+	if anchor := (Request{Name: "Get user (v2)!"}).Anchor(); anchor != "get-user-v2" {
+		t.Errorf("Anchor() = %q", anchor)
+	}
+
+	tests := map[string]string{
+		"users.http":          "users.md",
+		"api/users.http":      "api/users.md",
+		"../shared/auth.http": "shared/auth.md",
+		"/abs/orders.rest":    "abs/orders.md",
+	}
+	for source, want := range tests {
+		if got := PageLink(source, ".md"); got != want {
+			t.Errorf("PageLink(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	out := Markdown(testPage(t))
+
+	for _, want := range []string{
+		"# users\n",
+		"- [Get user](#get-user)",
+		"## Get user\n\nReturns one user by ID\n",
+		"```http\nGET {{baseUrl}}/users/{{id}}\n```",
+		"| Authorization | `Bearer {{token}}` |",
+		"```json\n{\"name\": \"Ada | Lovelace\"}\n```",
+		"**Sample response** `200 OK`",
+		"\"name\": \"<Ada>\"", // Indented JSON
+		"| Variable | dev | prod |",
+		"| `token` | `[REDACTED]` | _not set_ |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, out)
+		}
+	}
+
+	index := MarkdownIndex("API", []*Page{testPage(t)})
+	if !strings.Contains(index, "- [users](api/users.md) (3 requests)") ||
+		!strings.Contains(index, "  - [POST Create user](api/users.md#create-user)") {
+		t.Errorf("unexpected index:\n%s", index)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	out, err := HTML(testPage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<h1>users</h1>",
+		`<section id="get-user">`,
+		`<span class="method">GET</span> {{baseUrl}}/users/{{id}}`,
+		"&#34;name&#34;: &#34;&lt;Ada&gt;&#34;", // Escaped
+		`<span class="missing">not set</span>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("html is missing %q:\n%s", want, out)
+		}
+	}
+
+	index, err := HTMLIndex("API", []*Page{testPage(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(index, `<a href="api/users.md#create-user">`) {
+		t.Errorf("unexpected index:\n%s", index)
+	}
+}
+
+func TestSampleBodyTruncates(t *testing.T) {
+	body := SampleBody(strings.Repeat("x", MaxSampleBytes+10), "text/plain")
+	if !strings.HasSuffix(body, "... (truncated)") || len(body) > MaxSampleBytes+20 {
+		t.Errorf("body not truncated: %d bytes", len(body))
+	}
+}
@@ -0,0 +1,107 @@
+package apidocs
+
+import (
+	"html/template"
+	"strings"
+	"time"
+
+	"postie/pkg/display"
+)
+
+var htmlFuncs = template.FuncMap{
+	"sampleBody":   SampleBody,
+	"datetime":     func(t time.Time) string { return t.Local().Format(time.DateTime) },
+	"milliseconds": display.Milliseconds,
+}
+
+const htmlStyle = `<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; color: #222; }
+code, pre { font-family: SFMono-Regular, Consolas, monospace; font-size: 0.9em; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; border-radius: 4px; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.method { font-weight: bold; color: #0969da; }
+.missing { color: #888; font-style: italic; }
+section { border-top: 1px solid #eee; margin-top: 2em; }
+</style>`
+
+var pageTemplate = template.Must(template.New("page").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + htmlStyle + `
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated from <code>{{.Source}}</code>.</p>
+{{if gt (len .Requests) 1}}<ul>
+{{range .Requests}}<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{end}}</ul>
+{{end}}{{range .Requests}}<section id="{{.Anchor}}">
+<h2>{{.Name}}</h2>
+{{if .Description}}<p>{{.Description}}</p>
+{{end}}<pre><span class="method">{{.Method}}</span> {{.URL}}</pre>
+{{if .Headers}}<table>
+<tr><th>Header</th><th>Value</th></tr>
+{{range .Headers}}<tr><td>{{.Name}}</td><td><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+{{end}}{{if .Body}}<h3>Example body</h3>
+<pre>{{.Body}}</pre>
+{{else if .BodyFile}}<p>Body from file <code>{{.BodyFile}}</code></p>
+{{end}}{{with .Sample}}<h3>Sample response <code>{{.Status}}</code></h3>
+<p>{{milliseconds .Duration}}, recorded {{datetime .Timestamp}}</p>
+{{if .Body}}<pre>{{sampleBody .Body .ContentType}}</pre>
+{{end}}{{end}}</section>
+{{end}}{{if .Variables}}<section>
+<h2>Environment variables</h2>
+<table>
+<tr><th>Variable</th>{{range .Environments}}<th>{{.}}</th>{{end}}</tr>
+{{range .Variables}}<tr><td><code>{{.Name}}</code></td>{{range .Values}}<td>{{if .}}<code>{{.}}</code>{{else}}<span class="missing">not set</span>{{end}}</td>{{end}}</tr>
+{{end}}</table>
+</section>
+{{end}}</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + htmlStyle + `
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Pages}}<li><a href="{{.Link}}">{{.Title}}</a> ({{len .Requests}} requests)
+<ul>
+{{$link := .Link}}{{range .Requests}}<li><a href="{{$link}}#{{.Anchor}}"><span class="method">{{.Method}}</span> {{.Name}}</a></li>
+{{end}}</ul>
+</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// HTML renders a page as a standalone HTML document
+func HTML(page *Page) (string, error) {
+	var b strings.Builder
+	if err := pageTemplate.Execute(&b, page); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// HTMLIndex renders the index of the pages as an HTML document
+func HTMLIndex(title string, pages []*Page) (string, error) {
+	var b strings.Builder
+	err := indexTemplate.Execute(&b, struct {
+		Title string
+		Pages []*Page
+	}{title, pages})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
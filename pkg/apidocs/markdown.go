@@ -0,0 +1,114 @@
+package apidocs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"postie/pkg/display"
+)
+
+// Markdown renders a page as Markdown
+func Markdown(page *Page) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", page.Title)
+	fmt.Fprintf(&b, "Generated from `%s`.\n\n", page.Source)
+
+	if len(page.Requests) > 1 {
+		for _, request := range page.Requests {
+			fmt.Fprintf(&b, "- [%s](#%s)\n", request.Name, request.Anchor())
+		}
+		b.WriteString("\n")
+	}
+
+	for _, request := range page.Requests {
+		fmt.Fprintf(&b, "## %s\n\n", request.Name)
+		if request.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", request.Description)
+		}
+		fmt.Fprintf(&b, "```http\n%s %s\n```\n\n", request.Method, request.URL)
+
+		if len(request.Headers) > 0 {
+			b.WriteString("| Header | Value |\n| --- | --- |\n")
+			for _, header := range request.Headers {
+				fmt.Fprintf(&b, "| %s | %s |\n", markdownCell(header.Name), markdownCode(header.Value))
+			}
+			b.WriteString("\n")
+		}
+
+		if request.Body != "" {
+			b.WriteString("**Example body**\n\n")
+			writeFence(&b, language(request.ContentType), request.Body)
+		} else if request.BodyFile != "" {
+			fmt.Fprintf(&b, "**Body** from file `%s`\n\n", request.BodyFile)
+		}
+
+		if sample := request.Sample; sample != nil {
+			fmt.Fprintf(&b, "**Sample response** `%s` (%s, recorded %s)\n\n",
+				sample.Status, display.Milliseconds(sample.Duration), sample.Timestamp.Local().Format(time.DateTime))
+			if sample.Body != "" {
+				writeFence(&b, language(sample.ContentType), SampleBody(sample.Body, sample.ContentType))
+			}
+		}
+	}
+
+	if len(page.Variables) > 0 {
+		b.WriteString("## Environment variables\n\n")
+		b.WriteString("| Variable |")
+		for _, env := range page.Environments {
+			fmt.Fprintf(&b, " %s |", markdownCell(env))
+		}
+		b.WriteString("\n| --- |" + strings.Repeat(" --- |", len(page.Environments)) + "\n")
+		for _, variable := range page.Variables {
+			fmt.Fprintf(&b, "| `%s` |", variable.Name)
+			for _, value := range variable.Values {
+				if value == "" {
+					b.WriteString(" _not set_ |")
+				} else {
+					fmt.Fprintf(&b, " %s |", markdownCode(value))
+				}
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// MarkdownIndex renders the index of the pages as Markdown
+func MarkdownIndex(title string, pages []*Page) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	for _, page := range pages {
+		fmt.Fprintf(&b, "- [%s](%s) (%d requests)\n", page.Title, page.Link, len(page.Requests))
+		for _, request := range page.Requests {
+			fmt.Fprintf(&b, "  - [%s %s](%s#%s)\n", request.Method, request.Name, page.Link, request.Anchor())
+		}
+	}
+	return b.String()
+}
+
+// writeFence writes content as a fenced code block, with a fence longer than
+// any backtick run in the content
+func writeFence(b *strings.Builder, lang, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", fence, lang, content, fence)
+}
+
+// markdownCell escapes text for a table cell
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// markdownCode formats a value as inline code in a table cell
+func markdownCode(value string) string {
+	value = markdownCell(strings.ReplaceAll(value, "\n", " "))
+	if strings.Contains(value, "`") {
+		return "`` " + value + " ``"
+	}
+	return "`" + value + "`"
+}
@@ -0,0 +1,115 @@
+// Package atomicfile writes the files postie rewrites, such as environment
+// files and the context, so a crash or a second postie process never leaves
+// them half written or loses an update.
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WriteFile writes data to a temporary file next to path and renames it over
+// path, so readers see either the old or the new content
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath) // Fails harmlessly once renamed
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// BackupSuffix is the timestamp format appended to backup file names
+const BackupSuffix = "20060102-150405"
+
+// Backup copies path to path.<timestamp>.bak and returns the copy's path.
+// It returns "" if path doesn't exist.
+func Backup(path string) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	backupPath := path + "." + time.Now().Format(BackupSuffix) + ".bak"
+	backup, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(backup, source); err != nil {
+		backup.Close()
+		return "", err
+	}
+	return backupPath, backup.Close()
+}
+
+// Lock timing; variables so tests can shorten them
+var (
+	// LockTimeout is how long Lock waits for another process
+	LockTimeout = 10 * time.Second
+	// StaleLockAge is the age after which a lock is assumed to be left
+	// behind by a process that crashed
+	StaleLockAge = time.Minute
+
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// ErrLocked is returned when a file stays locked past LockTimeout
+var ErrLocked = errors.New("file is locked by another postie process")
+
+// Lock takes an advisory lock on path by creating path.lock, waiting for
+// other postie processes to release it. Call the returned function to
+// release the lock.
+func Lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(LockTimeout)
+
+	for {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > StaleLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: %w (remove %s if no other postie is running)", path, ErrLocked, lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
@@ -0,0 +1,73 @@
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAndBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.json")
+
+	if backup, err := Backup(path); err != nil || backup != "" {
+		t.Fatalf("Expected no backup of a missing file, got %q, %v", backup, err)
+	}
+
+	if err := WriteFile(path, []byte("one"), 0600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	backup, err := Backup(path)
+	if err != nil {
+		t.Fatalf("Backup error: %v", err)
+	}
+	if err := WriteFile(path, []byte("two"), 0600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "two" {
+		t.Errorf("Expected the new content, got %q", data)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "one" {
+		t.Errorf("Expected the backup to keep the old content, got %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 2 {
+		t.Errorf("Expected the file and its backup only, got %d entries", len(entries))
+	}
+}
+
+func TestLock(t *testing.T) {
+	LockTimeout, StaleLockAge = 100*time.Millisecond, time.Minute
+	path := filepath.Join(t.TempDir(), "env-store.json")
+
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	if _, err := Lock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked while locked, got %v", err)
+	}
+	unlock()
+
+	unlock, err = Lock(path)
+	if err != nil {
+		t.Fatalf("Expected the lock after release, got %v", err)
+	}
+	defer unlock()
+
+	// A lock older than StaleLockAge was left by a crashed process
+	old := time.Now().Add(-2 * time.Minute)
+	os.Chtimes(path+".lock", old, old)
+	if release, err := Lock(path); err != nil {
+		t.Errorf("Expected a stale lock to be taken over, got %v", err)
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schemes # @auth directives can use without a plugin
+const (
+	SchemeBearer = "bearer" // # @auth bearer <token>
+	SchemeBasic  = "basic"  // # @auth basic <user> <password>
+	SchemeAPIKey = "apikey" // # @auth apikey header|query <name> <value>
+)
+
+// IsScheme reports whether scheme is one of the built-in auth schemes
+func IsScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case SchemeBearer, SchemeBasic, SchemeAPIKey:
+		return true
+	}
+	return false
+}
+
+// Parse returns the authenticator for a built-in scheme and its arguments
+func Parse(scheme string, args []string) (Authenticator, error) {
+	switch strings.ToLower(scheme) {
+	case SchemeBearer:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("bearer needs a token")
+		}
+		return NewBearerTokenAuth(args[0]), nil
+	case SchemeBasic:
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("basic needs a user and a password")
+		}
+		password := ""
+		if len(args) == 2 {
+			password = args[1]
+		}
+		return NewBasicAuth(args[0], password), nil
+	case SchemeAPIKey:
+		if len(args) != 3 {
+			return nil, fmt.Errorf("apikey needs a location (header or query), a name and a value")
+		}
+		in := strings.ToLower(args[0])
+		if in != "header" && in != "query" {
+			return nil, fmt.Errorf("unsupported API key location: %s (use header or query)", args[0])
+		}
+		return NewAPIKeyAuth(args[1], args[2], in), nil
+	}
+	return nil, fmt.Errorf("unknown auth scheme %q", scheme)
+}
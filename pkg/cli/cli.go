@@ -3,9 +3,61 @@ package cli
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"postie/pkg/color"
+	"postie/pkg/display"
+	"postie/pkg/logging"
+)
+
+// Output formats accepted by the global --output option
+const (
+	OutputText = "text"
+	OutputJSON = "json"
 )
 
+// GlobalOptions holds options that apply to every command
+type GlobalOptions struct {
+	Output   string // Output format: text (default) or json
+	LogLevel string // Log level: quiet, normal (default), verbose, debug, trace
+	Color    string // Color mode: auto (default), always or never
+	Theme    string // Color theme name
+	Glyphs   string // Status symbols: auto (default), unicode or ascii
+	Trace    bool   // Dump requests and responses with their timings
+}
+
+// globalOptions holds the options parsed by the most recent Run
+var globalOptions = GlobalOptions{Output: OutputText, Color: color.Auto, Theme: color.DefaultTheme, Glyphs: display.GlyphsAuto}
+
+// Options returns the global options for the current invocation
+func Options() GlobalOptions {
+	return globalOptions
+}
+
+// IsQuiet returns true if --quiet (or --log-level quiet) was given
+func IsQuiet() bool {
+	return logging.IsQuiet()
+}
+
+// IsJSONOutput returns true if machine-readable JSON output was requested
+func IsJSONOutput() bool {
+	return globalOptions.Output == OutputJSON
+}
+
+// Palette returns the palette for coloring standard output, or nil if
+// output isn't colored: with --color never, NO_COLOR, --output json, or
+// when standard output isn't a terminal
+func Palette() *color.Palette {
+	if IsJSONOutput() || !color.Enabled(globalOptions.Color, os.Stdout) {
+		return nil
+	}
+	return color.New(color.Themes[globalOptions.Theme])
+}
+
 // Command represents a CLI command
 type Command struct {
 	Name        string
@@ -40,6 +92,11 @@ func (c *CLI) AddCommand(cmd *Command) {
 
 // Run executes the CLI with the given arguments
 func (c *CLI) Run(args []string) error {
+	args, err := parseGlobalOptions(args)
+	if err != nil {
+		return err
+	}
+
 	if len(args) < 1 {
 		c.PrintUsage()
 		return nil
@@ -84,6 +141,10 @@ func (c *CLI) Run(args []string) error {
 		if subCmd, ok := cmd.Subcommands[subCmdName]; ok {
 			return subCmd.Action(args[2:])
 		}
+		// A command with its own action takes flags directly
+		if cmd.Action != nil && strings.HasPrefix(subCmdName, "-") {
+			return cmd.Action(args[1:])
+		}
 		return fmt.Errorf("unknown subcommand: %s %s\nRun '%s %s help' for usage", cmdName, subCmdName, c.Name, cmdName)
 	}
 
@@ -101,31 +162,162 @@ func (c *CLI) Run(args []string) error {
 	return fmt.Errorf("command '%s' has no action defined", cmdName)
 }
 
+// parseGlobalOptions extracts global options from anywhere in args and
+// returns the remaining arguments for command dispatch
+func parseGlobalOptions(args []string) ([]string, error) {
+	globalOptions = GlobalOptions{Output: OutputText, LogLevel: "normal", Color: color.Auto, Theme: color.DefaultTheme, Glyphs: display.GlyphsAuto}
+	if theme := os.Getenv(color.ThemeEnv); theme != "" {
+		globalOptions.Theme = theme
+	}
+	remaining := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		var value string
+		switch {
+		case arg == "--quiet" || arg == "-q":
+			globalOptions.LogLevel = "quiet"
+			continue
+		case arg == "--debug":
+			globalOptions.LogLevel = "debug"
+			continue
+		case arg == "--log-level":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag --log-level requires a value (quiet, normal, verbose, debug, trace)")
+			}
+			i++
+			globalOptions.LogLevel = args[i]
+			continue
+		case strings.HasPrefix(arg, "--log-level="):
+			globalOptions.LogLevel = strings.TrimPrefix(arg, "--log-level=")
+			continue
+		case arg == "--color" || strings.HasPrefix(arg, "--color="):
+			// A bare --color means always, as in ls and grep
+			mode := color.Always
+			if strings.HasPrefix(arg, "--color=") {
+				mode = strings.TrimPrefix(arg, "--color=")
+			} else if i+1 < len(args) {
+				if _, err := color.ParseMode(args[i+1]); err == nil {
+					i++
+					mode = args[i]
+				}
+			}
+			mode, err := color.ParseMode(mode)
+			if err != nil {
+				return nil, err
+			}
+			globalOptions.Color = mode
+			continue
+		case arg == "--trace":
+			globalOptions.Trace = true
+			continue
+		case arg == "--no-color":
+			globalOptions.Color = color.Never
+			continue
+		case arg == "--theme":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag --theme requires a value (%s)", strings.Join(color.ThemeNames(), ", "))
+			}
+			i++
+			globalOptions.Theme = args[i]
+			continue
+		case strings.HasPrefix(arg, "--theme="):
+			globalOptions.Theme = strings.TrimPrefix(arg, "--theme=")
+			continue
+		case arg == "--glyphs":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag --glyphs requires a value (auto, unicode, ascii)")
+			}
+			i++
+			globalOptions.Glyphs = args[i]
+			continue
+		case strings.HasPrefix(arg, "--glyphs="):
+			globalOptions.Glyphs = strings.TrimPrefix(arg, "--glyphs=")
+			continue
+		case arg == "--output":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("flag --output requires a value (text, json)")
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, "--output="):
+			value = strings.TrimPrefix(arg, "--output=")
+		default:
+			remaining = append(remaining, arg)
+			continue
+		}
+
+		switch value {
+		case OutputText, OutputJSON:
+			globalOptions.Output = value
+		default:
+			return nil, fmt.Errorf("unsupported output format: %s (use text or json)", value)
+		}
+	}
+
+	level, err := logging.ParseLevel(globalOptions.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logging.SetLevel(level)
+	logging.SetWireTrace(globalOptions.Trace)
+
+	if _, err := color.LookupTheme(globalOptions.Theme); err != nil {
+		return nil, err
+	}
+	if err := display.SetGlyphs(globalOptions.Glyphs); err != nil {
+		return nil, err
+	}
+
+	return remaining, nil
+}
+
 // PrintUsage prints the CLI usage information
 func (c *CLI) PrintUsage() {
-	fmt.Printf("%s - %s\n\n", c.Name, c.Description)
-	fmt.Println("Usage:")
-	fmt.Printf("  %s <resource> <action> [options]\n\n", c.Name)
-	fmt.Println("Resources:")
+	c.writeUsage(os.Stdout)
+}
+
+// writeUsage writes the CLI usage information to w
+func (c *CLI) writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s - %s\n\n", c.Name, c.Description)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  %s <resource> <action> [options]\n\n", c.Name)
+	fmt.Fprintln(w, "Resources:")
 
-	// Print commands in order
+	// Print the main commands first, then the others by name
 	commandOrder := []string{"http", "env", "context", "demo", "version", "help"}
-	for _, name := range commandOrder {
+	var others []string
+	for name := range c.Commands {
+		if !slices.Contains(commandOrder, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range append(commandOrder, others...) {
 		if cmd, ok := c.Commands[name]; ok {
-			fmt.Printf("  %-15s %s\n", name, cmd.Description)
+			fmt.Fprintf(w, "  %-15s %s\n", name, cmd.Description)
 		}
 	}
 
-	fmt.Println("\nGlobal Options:")
-	fmt.Println("  --help, -h      Show help information")
-	fmt.Println("  --version, -v   Show version information")
-	fmt.Println("\nExamples:")
-	fmt.Printf("  %s http run requests.http --env production\n", c.Name)
-	fmt.Printf("  %s env list\n", c.Name)
-	fmt.Printf("  %s env show development\n", c.Name)
-	fmt.Printf("  %s context set --http-file requests.http --env development\n", c.Name)
-	fmt.Printf("  %s http run requests.http --save-responses\n", c.Name)
-	fmt.Printf("\nRun '%s <resource> help' for more information on a resource.\n", c.Name)
+	fmt.Fprintln(w, "\nGlobal Options:")
+	fmt.Fprintln(w, "  --help, -h      Show help information")
+	fmt.Fprintln(w, "  --version, -v   Show version information")
+	fmt.Fprintln(w, "  --output <fmt>  Output format: text (default) or json")
+	fmt.Fprintln(w, "  --quiet, -q     Only show errors and summaries")
+	fmt.Fprintln(w, "  --debug         Show debug logs on stderr")
+	fmt.Fprintln(w, "  --log-level <l> Log level: quiet, normal, verbose, debug, trace")
+	fmt.Fprintln(w, "  --trace         Show requests and responses as sent, with timings")
+	fmt.Fprintln(w, "  --color <when>  Color output: auto (default), always or never")
+	fmt.Fprintln(w, "  --theme <name>  Color theme: default, light or mono")
+	fmt.Fprintln(w, "  --glyphs <set>  Status symbols: auto (default), unicode or ascii")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintf(w, "  %s http run requests.http --env production\n", c.Name)
+	fmt.Fprintf(w, "  %s env list\n", c.Name)
+	fmt.Fprintf(w, "  %s env show development\n", c.Name)
+	fmt.Fprintf(w, "  %s context set --http-file requests.http --env development\n", c.Name)
+	fmt.Fprintf(w, "  %s http run requests.http --save-responses\n", c.Name)
+	fmt.Fprintf(w, "\nRun '%s <resource> help' for more information on a resource.\n", c.Name)
 }
 
 // PrintUsage prints the command usage information
@@ -153,6 +345,8 @@ type StringFlag struct {
 	Value     string
 	Usage     string
 	Required  bool
+	Multiple  bool     // Allow the flag to be repeated
+	Values    []string // Every value of a Multiple flag, in order
 }
 
 // BoolFlag represents a boolean flag
@@ -170,6 +364,19 @@ func ParseFlags(args []string, stringFlags []*StringFlag, boolFlags []*BoolFlag)
 
 	// Define string flags
 	for _, sf := range stringFlags {
+		if sf.Multiple {
+			sf.Values = nil
+			appendValue := func(value string) error {
+				sf.Value = value
+				sf.Values = append(sf.Values, value)
+				return nil
+			}
+			fs.Func(sf.Name, sf.Usage, appendValue)
+			if sf.ShortName != "" {
+				fs.Func(sf.ShortName, sf.Usage, appendValue)
+			}
+			continue
+		}
 		fs.StringVar(&sf.Value, sf.Name, "", sf.Usage)
 		if sf.ShortName != "" {
 			fs.StringVar(&sf.Value, sf.ShortName, "", sf.Usage)
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUsageListsAllCommands(t *testing.T) {
+	c := NewCLI("postie", "1.0.0", "HTTP client")
+	for _, name := range []string{"listen", "http", "responses", "env", "version", "contract"} {
+		c.AddCommand(&Command{Name: name, Description: name + " description"})
+	}
+
+	var out strings.Builder
+	c.writeUsage(&out)
+	usage := out.String()
+
+	// The main commands come first, then the others by name
+	last := -1
+	for _, name := range []string{"http", "env", "version", "contract", "listen", "responses"} {
+		i := strings.Index(usage, "  "+name+" ")
+		if i < 0 {
+			t.Fatalf("expected %s in the usage, got:\n%s", name, usage)
+		}
+		if i < last {
+			t.Errorf("expected %s after the commands before it, got:\n%s", name, usage)
+		}
+		last = i
+	}
+}
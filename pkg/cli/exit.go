@@ -0,0 +1,45 @@
+package cli
+
+import "errors"
+
+// Exit codes
+const (
+	ExitOK        = 0 // Every request passed
+	ExitFailed    = 1 // A request failed its expected status, tests or assertions
+	ExitTransport = 2 // A request couldn't be sent or got no response
+	ExitConfig    = 3 // Invalid arguments, files or configuration
+)
+
+// ExitError is an error that ends the program with a specific exit code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exit returns an error that ends the program with the given exit code
+func Exit(code int, err error) error {
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the exit code for an error returned by Run: ExitOK for
+// nil, the code of an ExitError, and ExitConfig for any other error, as
+// commands fail before sending requests because of their arguments, files
+// or configuration
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitConfig
+}
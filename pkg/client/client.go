@@ -43,6 +43,8 @@ type Config struct {
 	Timeout    time.Duration
 	Headers    map[string]string
 	Middleware []Middleware
+	Transport  http.RoundTripper // Optional transport (the shared transport for Pool if nil)
+	Pool       TransportConfig   // Connection pooling of the shared transport
 }
 
 // NewClient creates a new API client
@@ -54,9 +56,15 @@ func NewClient(config *Config) *APIClient {
 	// Use the timeout from config (0 means no timeout)
 	timeout := config.Timeout
 
+	transport := config.Transport
+	if transport == nil {
+		transport = SharedTransport(config.Pool)
+	}
+
 	client := &APIClient{
 		httpClient: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: transport,
 		},
 		baseURL:    config.BaseURL,
 		headers:    make(http.Header),
@@ -106,6 +114,11 @@ func (c *APIClient) OPTIONS(url string) *Request {
 	return c.newRequest(http.MethodOptions, url)
 }
 
+// NewRequest creates a request with any HTTP method
+func (c *APIClient) NewRequest(method, url string) *Request {
+	return c.newRequest(strings.ToUpper(method), url)
+}
+
 // newRequest creates a new request builder
 func (c *APIClient) newRequest(method, requestURL string) *Request {
 	// Build full URL
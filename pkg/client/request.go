@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
 	"time"
@@ -29,6 +30,12 @@ func (r *Request) Header(key, value string) *Request {
 	return r
 }
 
+// AddHeader adds a header value, keeping any existing values for the same key
+func (r *Request) AddHeader(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
 // Headers sets multiple headers
 func (r *Request) Headers(headers map[string]string) *Request {
 	for key, value := range headers {
@@ -121,6 +128,10 @@ func (r *Request) Execute() (*Response, error) {
 		req = req.WithContext(r.ctx)
 	}
 
+	// Record the time spent in each phase of the request
+	trace := &requestTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
 	// Execute request
 	start := time.Now()
 	resp, err := r.client.httpClient.Do(req)
@@ -134,6 +145,7 @@ func (r *Request) Execute() (*Response, error) {
 	response := &Response{
 		Response: resp,
 		Duration: duration,
+		trace:    trace,
 	}
 
 	// Apply middleware
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"postie/pkg/display"
 )
 
 // Response wraps http.Response with additional functionality
@@ -13,6 +15,7 @@ type Response struct {
 	*http.Response
 	Duration time.Duration
 	body     []byte
+	trace    *requestTrace
 }
 
 // GetBody returns the response body as bytes
@@ -28,9 +31,26 @@ func (r *Response) GetBody() ([]byte, error) {
 	}
 
 	r.body = body
+	if r.trace != nil {
+		r.trace.finishBody()
+	}
 	return body, nil
 }
 
+// Timings returns the time each phase of the request took. Content
+// transfer is only known once the body has been read.
+func (r *Response) Timings() *Timings {
+	if r.trace == nil {
+		return nil
+	}
+	return r.trace.timings()
+}
+
+// SetBody replaces the response body, e.g. with a decoded form of it
+func (r *Response) SetBody(body []byte) {
+	r.body = body
+}
+
 // Text returns the response body as a string
 func (r *Response) Text() (string, error) {
 	body, err := r.GetBody()
@@ -90,6 +110,6 @@ func (r *Response) ContentType() string {
 // String returns a string representation of the response
 func (r *Response) String() string {
 	body, _ := r.Text()
-	return fmt.Sprintf("Status: %s\nDuration: %v\nSize: %d bytes\nBody: %s",
-		r.Status, r.Duration, r.Size(), body)
+	return fmt.Sprintf("Status: %s\nDuration: %s\nSize: %s\nBody: %s",
+		r.Status, display.Duration(r.Duration), display.Size(r.Size()), body)
 }
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the time each phase of a request took. Phases that didn't
+// happen, such as DNS and connecting on a reused connection, are zero.
+type Timings struct {
+	DNS              time.Duration // Resolving the host name
+	Connect          time.Duration // Opening the TCP connection
+	TLS              time.Duration // The TLS handshake
+	ServerProcessing time.Duration // From sending the request to the first response byte
+	ContentTransfer  time.Duration // From the first response byte to the end of the body
+	Reused           bool          // The connection was reused from the pool
+}
+
+// TimingsReport is the machine-readable form of Timings, in milliseconds
+type TimingsReport struct {
+	DNSMs              float64 `json:"dns_ms"`
+	ConnectMs          float64 `json:"connect_ms"`
+	TLSMs              float64 `json:"tls_ms"`
+	ServerProcessingMs float64 `json:"server_processing_ms"`
+	ContentTransferMs  float64 `json:"content_transfer_ms"`
+	Reused             bool    `json:"reused_connection,omitempty"`
+}
+
+// Report returns the timings in milliseconds, to the microsecond
+func (t *Timings) Report() *TimingsReport {
+	if t == nil {
+		return nil
+	}
+	return &TimingsReport{
+		DNSMs:              milliseconds(t.DNS),
+		ConnectMs:          milliseconds(t.Connect),
+		TLSMs:              milliseconds(t.TLS),
+		ServerProcessingMs: milliseconds(t.ServerProcessing),
+		ContentTransferMs:  milliseconds(t.ContentTransfer),
+		Reused:             t.Reused,
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// requestTrace records when each phase of a request happened, through
+// httptrace hooks. After a redirect it describes the last request.
+type requestTrace struct {
+	mu                        sync.Mutex
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+	bodyDone                  time.Time
+	reused                    bool
+}
+
+// clientTrace returns the hooks that record the phases
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	record := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*at = time.Now()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// Each request of a redirect chain starts over
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.wroteRequest, t.firstByte = time.Time{}, time.Time{}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart:         func(string, string) { record(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { record(&t.connectDone) },
+		TLSHandshakeStart:    func() { record(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { record(&t.wroteRequest) },
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+	}
+}
+
+// finishBody records that the response body has been read
+func (t *requestTrace) finishBody() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bodyDone = time.Now()
+}
+
+// timings returns the durations of the recorded phases
+func (t *requestTrace) timings() *Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &Timings{
+		DNS:              between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLS:              between(t.tlsStart, t.tlsDone),
+		ServerProcessing: between(t.wroteRequest, t.firstByte),
+		ContentTransfer:  between(t.firstByte, t.bodyDone),
+		Reused:           t.reused,
+	}
+}
+
+// between returns the time from start to end, or 0 if either didn't happen
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseTimings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := NewClient(&Config{Transport: NewTransport(TransportConfig{})})
+	resp, err := c.GET(server.URL).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resp.GetBody(); err != nil {
+		t.Fatal(err)
+	}
+
+	timings := resp.Timings()
+	if timings == nil {
+		t.Fatal("expected timings")
+	}
+	if timings.Reused {
+		t.Error("expected a new connection")
+	}
+	if timings.ServerProcessing < 20*time.Millisecond {
+		t.Errorf("expected server processing of at least 20ms, got %s", timings.ServerProcessing)
+	}
+	if timings.TLS != 0 {
+		t.Errorf("expected no TLS over http, got %s", timings.TLS)
+	}
+
+	// The second request reuses the connection
+	resp, err = c.GET(server.URL).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.GetBody()
+	if timings := resp.Timings(); !timings.Reused || timings.Connect != 0 {
+		t.Errorf("expected a reused connection, got %+v", timings)
+	}
+}
+
+func TestTimingsReport(t *testing.T) {
+	timings := &Timings{DNS: 1500 * time.Microsecond, ServerProcessing: 40 * time.Millisecond}
+	report := timings.Report()
+	if report.DNSMs != 1.5 || report.ServerProcessingMs != 40 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	var none *Timings
+	if none.Report() != nil {
+		t.Error("expected no report without timings")
+	}
+}
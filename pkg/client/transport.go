@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransportConfig tunes connection pooling and keep-alives. Zero values
+// keep the defaults of http.DefaultTransport.
+type TransportConfig struct {
+	MaxIdleConns        int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // Idle connections kept for each host
+	MaxConnsPerHost     int           // Connections to each host, including active ones (0 for no limit)
+	IdleConnTimeout     time.Duration // How long an idle connection is kept
+	TLSHandshakeTimeout time.Duration // Time limit for TLS handshakes
+	DisableKeepAlives   bool          // Use a new connection for every request
+
+	// Resolve maps "host:port" or "host" to the address to connect to
+	// instead, like /etc/hosts. An address without a port keeps the
+	// request's port. TLS is still verified against the request's host.
+	Resolve map[string]string
+}
+
+// NewTransport creates a transport with the defaults of
+// http.DefaultTransport and the tuning of config
+func NewTransport(config TransportConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = config.MaxConnsPerHost
+	}
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = config.IdleConnTimeout
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	transport.DisableKeepAlives = config.DisableKeepAlives
+	if len(config.Resolve) > 0 {
+		transport.DialContext = resolvingDialer(config.Resolve, transport.DialContext)
+	}
+	return transport
+}
+
+// ParseResolve parses a curl-style --resolve value, host:port:addr, into
+// a Resolve key and address
+func ParseResolve(value string) (hostPort, addr string, err error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("invalid resolve %q (expected host:port:addr)", value)
+	}
+	if port, err := strconv.Atoi(parts[1]); err != nil || port < 1 || port > 65535 {
+		return "", "", fmt.Errorf("invalid resolve %q: invalid port %q", value, parts[1])
+	}
+	return net.JoinHostPort(parts[0], parts[1]), strings.Trim(parts[2], "[]"), nil
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// resolvingDialer wraps dial so that addresses in resolve are dialed at
+// their mapped address
+func resolvingDialer(resolve map[string]string, dial dialFunc) dialFunc {
+	// Host names are case-insensitive
+	mappings := make(map[string]string, len(resolve))
+	for host, addr := range resolve {
+		mappings[strings.ToLower(host)] = addr
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(ctx, network, mapAddress(mappings, addr))
+	}
+}
+
+// mapAddress returns the address to dial for addr, a host:port
+func mapAddress(mappings map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	host = strings.ToLower(host)
+	target, ok := mappings[net.JoinHostPort(host, port)]
+	if !ok {
+		if target, ok = mappings[host]; !ok {
+			return addr
+		}
+	}
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), port)
+}
+
+var (
+	sharedMu         sync.Mutex
+	sharedTransports = make(map[string]*http.Transport) // By config, formatted with %+v
+)
+
+// SharedTransport returns the transport for config, creating it on first
+// use. Clients and executors with the same config share one connection
+// pool, so connections are reused across them.
+func SharedTransport(config TransportConfig) *http.Transport {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	// fmt sorts map keys, so equal configs format the same
+	key := fmt.Sprintf("%+v", config)
+	transport, ok := sharedTransports[key]
+	if !ok {
+		transport = NewTransport(config)
+		sharedTransports[key] = transport
+	}
+	return transport
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport := NewTransport(TransportConfig{MaxConnsPerHost: 4, TLSHandshakeTimeout: time.Second, DisableKeepAlives: true})
+	if transport.MaxConnsPerHost != 4 || transport.TLSHandshakeTimeout != time.Second || !transport.DisableKeepAlives {
+		t.Errorf("tuning not applied: %+v", transport)
+	}
+	if transport.Proxy == nil || transport.MaxIdleConns == 0 {
+		t.Error("expected the defaults of http.DefaultTransport")
+	}
+}
+
+func TestSharedTransportReusesConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	config := TransportConfig{MaxIdleConnsPerHost: 7}
+	if SharedTransport(config) != SharedTransport(config) || SharedTransport(config) == SharedTransport(TransportConfig{}) {
+		t.Fatal("expected one transport per config")
+	}
+
+	// Separate clients with the same settings share one connection
+	for i := 0; i < 3; i++ {
+		resp, err := NewClient(&Config{Pool: config}).GET(server.URL).Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.GetBody()
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+}
+
+func TestTransportResolve(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	hostPort, addr, err := ParseResolve("api.example.test:" + port + ":127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := map[string]string{
+		hostPort:             addr,
+		"Other.Example.Test": "127.0.0.1:" + port, // Any port, mapped to the server's
+	}
+	apiClient := NewClient(&Config{Pool: TransportConfig{Resolve: resolve}})
+	for _, url := range []string{"http://api.example.test:" + port + "/", "http://other.example.test/"} {
+		resp, err := apiClient.GET(url).Execute()
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		resp.GetBody()
+	}
+	if len(hosts) != 2 || hosts[0] != "api.example.test:"+port || hosts[1] != "other.example.test" {
+		t.Errorf("requests not sent with their own Host: %v", hosts)
+	}
+
+	for _, value := range []string{"api.example.test:443", "api.example.test:https:127.0.0.1", ":443:127.0.0.1"} {
+		if _, _, err := ParseResolve(value); err == nil {
+			t.Errorf("ParseResolve(%q): expected an error", value)
+		}
+	}
+	if hostPort, addr, _ := ParseResolve("example.test:443:[::1]"); hostPort != "example.test:443" || addr != "::1" {
+		t.Errorf("ParseResolve IPv6 = %q, %q", hostPort, addr)
+	}
+	if got := mapAddress(map[string]string{"example.test": "::1"}, "example.test:443"); got != "[::1]:443" {
+		t.Errorf("mapAddress IPv6 = %q", got)
+	}
+}
@@ -0,0 +1,159 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// normalize round-trips a value through JSON so numbers compare equal
+func normalize(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	input := `{"id":1,"big":5000000000,"neg":-200,"ratio":0.5,"name":"Ann","tags":["a","b"],"ok":true,"none":null,"nested":{"x":300}}`
+
+	encoded, err := EncodeMsgpack([]byte(input))
+	if err != nil {
+		t.Fatalf("EncodeMsgpack failed: %v", err)
+	}
+	if !bytes.HasPrefix(encoded, []byte{0x89, 0xa3, 'b', 'i', 'g', 0xcf}) {
+		t.Errorf("unexpected encoding prefix % x", encoded[:6])
+	}
+
+	decoded, err := DecodeMsgpack(encoded)
+	if err != nil {
+		t.Fatalf("DecodeMsgpack failed: %v", err)
+	}
+	var want interface{}
+	json.Unmarshal([]byte(input), &want)
+	if got := normalize(t, decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+
+	if _, err := DecodeMsgpack([]byte{0x92, 0x01}); err == nil {
+		t.Error("expected an error for truncated data")
+	}
+	if _, err := EncodeMsgpack([]byte("{")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+const shopProto = `
+syntax = "proto3";
+package shop.v1;
+
+import "common.proto";
+
+// An order
+message Order {
+  int64 id = 1;
+  string customer_name = 2;
+  repeated int32 quantities = 3;
+  Status status = 4;
+  map<string, Item> items = 5;
+  bytes token = 6 [json_name = "tok"];
+  common.Money total = 7;
+  oneof contact {
+    string email = 8;
+    sint32 delta = 9;
+  }
+
+  enum Status {
+    STATUS_UNKNOWN = 0;
+    STATUS_PAID = 2;
+  }
+
+  message Item {
+    double price = 1;
+    bool gift = 2;
+  }
+}
+
+service Shop {
+  rpc Get(Order) returns (Order) { option (google.api.http) = { get: "/v1/orders" }; }
+}
+`
+
+const commonProto = `
+syntax = "proto3";
+package common;
+message Money { string currency = 1; fixed64 cents = 2; }
+`
+
+func TestProtobufRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "shop.proto"), []byte(shopProto), 0644)
+	os.WriteFile(filepath.Join(dir, "common.proto"), []byte(commonProto), 0644)
+
+	file, err := LoadProto(filepath.Join(dir, "shop.proto"))
+	if err != nil {
+		t.Fatalf("LoadProto failed: %v", err)
+	}
+	message, err := file.Message("Order")
+	if err != nil {
+		t.Fatalf("Message failed: %v", err)
+	}
+
+	input := `{
+		"id": "9007199254740993",
+		"customer_name": "Ann",
+		"quantities": [1, 2, 300],
+		"status": "STATUS_PAID",
+		"items": {"apple": {"price": 1.5, "gift": true}},
+		"tok": "AQI=",
+		"total": {"currency": "EUR", "cents": 1250},
+		"delta": -3
+	}`
+	encoded, err := message.Encode([]byte(input))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Field 1, varint 9007199254740993
+	if !bytes.HasPrefix(encoded, []byte{0x08, 0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x10}) {
+		t.Errorf("unexpected encoding prefix % x", encoded[:9])
+	}
+
+	decoded, err := message.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded["id"] != int64(9007199254740993) {
+		t.Errorf("id = %v", decoded["id"])
+	}
+	want := map[string]interface{}{
+		"customerName": "Ann",
+		"quantities":   []interface{}{1.0, 2.0, 300.0},
+		"status":       "STATUS_PAID",
+		"items":        map[string]interface{}{"apple": map[string]interface{}{"price": 1.5, "gift": true}},
+		"tok":          "AQI=",
+		"total":        map[string]interface{}{"currency": "EUR", "cents": 1250.0},
+		"delta":        -3.0,
+	}
+	delete(decoded, "id")
+	if got := normalize(t, decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %v\nwant %v", got, want)
+	}
+
+	for _, bad := range []string{`{"nope": 1}`, `{"status": "STATUS_LOST"}`, `{"quantities": "x"}`, `{"id": 1.5}`} {
+		if _, err := message.Encode([]byte(bad)); err == nil {
+			t.Errorf("Encode(%s) expected an error", bad)
+		}
+	}
+
+	if _, err := file.Message("Missing"); err == nil {
+		t.Error("expected an error for an unknown message")
+	}
+}
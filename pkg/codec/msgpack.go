@@ -0,0 +1,354 @@
+// Package codec converts JSON request bodies to binary encodings such as
+// MessagePack and Protocol Buffers, and decodes such responses back into
+// JSON-compatible values for display and scripts.
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MsgpackContentType is sent with MessagePack request bodies
+const MsgpackContentType = "application/msgpack"
+
+// EncodeMsgpack converts a JSON document to MessagePack. Integers are
+// encoded as integers, other numbers as 64-bit floats, and object keys in
+// sorted order.
+func EncodeMsgpack(jsonData []byte) ([]byte, error) {
+	value, err := decodeJSON(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := writeMsgpack(&out, value); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// decodeJSON parses JSON keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	return value, nil
+}
+
+func writeMsgpack(out *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		out.WriteByte(0xc0)
+	case bool:
+		if v {
+			out.WriteByte(0xc3)
+		} else {
+			out.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			writeMsgpackInt(out, i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			out.WriteByte(0xcf)
+			binary.Write(out, binary.BigEndian, u)
+		} else {
+			f, err := v.Float64()
+			if err != nil {
+				return fmt.Errorf("invalid number %s", v)
+			}
+			out.WriteByte(0xcb)
+			binary.Write(out, binary.BigEndian, math.Float64bits(f))
+		}
+	case string:
+		writeMsgpackHeader(out, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		out.WriteString(v)
+	case []interface{}:
+		writeMsgpackHeader(out, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := writeMsgpack(out, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeMsgpackHeader(out, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			writeMsgpack(out, key)
+			if err := writeMsgpack(out, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as MessagePack", value)
+	}
+	return nil
+}
+
+func writeMsgpackInt(out *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		out.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		out.WriteByte(byte(int8(i)))
+	case i >= 0 && i <= math.MaxUint8:
+		out.Write([]byte{0xcc, byte(i)})
+	case i >= 0 && i <= math.MaxUint16:
+		out.WriteByte(0xcd)
+		binary.Write(out, binary.BigEndian, uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		out.WriteByte(0xce)
+		binary.Write(out, binary.BigEndian, uint32(i))
+	case i >= 0:
+		out.WriteByte(0xcf)
+		binary.Write(out, binary.BigEndian, uint64(i))
+	case i >= math.MinInt8:
+		out.Write([]byte{0xd0, byte(int8(i))})
+	case i >= math.MinInt16:
+		out.WriteByte(0xd1)
+		binary.Write(out, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		out.WriteByte(0xd2)
+		binary.Write(out, binary.BigEndian, int32(i))
+	default:
+		out.WriteByte(0xd3)
+		binary.Write(out, binary.BigEndian, i)
+	}
+}
+
+// writeMsgpackHeader writes the type and length of a string, array or map:
+// a fix type holding lengths up to fixMax, or else the 8-, 16- or 32-bit
+// length form (code8 is 0 for types without an 8-bit form)
+func writeMsgpackHeader(out *bytes.Buffer, length int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case length <= fixMax:
+		out.WriteByte(fix | byte(length))
+	case code8 != 0 && length <= math.MaxUint8:
+		out.Write([]byte{code8, byte(length)})
+	case length <= math.MaxUint16:
+		out.WriteByte(code16)
+		binary.Write(out, binary.BigEndian, uint16(length))
+	default:
+		out.WriteByte(code32)
+		binary.Write(out, binary.BigEndian, uint32(length))
+	}
+}
+
+// DecodeMsgpack converts MessagePack to a JSON-compatible value. Binary
+// data becomes a base64 string, and map keys become strings.
+func DecodeMsgpack(data []byte) (interface{}, error) {
+	d := &msgpackDecoder{data: data}
+	value, err := d.value()
+	if err != nil {
+		return nil, fmt.Errorf("invalid MessagePack: %w", err)
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("invalid MessagePack: %d trailing bytes", len(data)-d.pos)
+	}
+	return value, nil
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data at byte %d", d.pos)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint reads an n-byte big-endian unsigned integer
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) value() (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return d.mapValue(int(code & 0x0f))
+	case code&0xf0 == 0x90:
+		return d.array(int(code & 0x0f))
+	case code&0xe0 == 0xa0:
+		return d.str(int(code & 0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(raw), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (code - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	case 0xca:
+		bits, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := d.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce:
+		v, err := d.uint(1 << (code - 0xcc))
+		return int64(v), err
+	case 0xcf:
+		v, err := d.uint(8)
+		if v <= math.MaxInt64 {
+			return int64(v), err
+		}
+		return v, err
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(int(n))
+	}
+	return nil, fmt.Errorf("unknown type 0x%02x at byte %d", code, d.pos-1)
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) array(n int) (interface{}, error) {
+	items := make([]interface{}, 0, min(n, len(d.data)))
+	for i := 0; i < n; i++ {
+		item, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) mapValue(n int) (interface{}, error) {
+	object := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			object[s] = value
+		} else {
+			object[fmt.Sprint(key)] = value
+		}
+	}
+	return object, nil
+}
+
+// ext decodes an extension value. Timestamps become RFC 3339 strings;
+// other types are returned with their type number and base64 data.
+func (d *msgpackDecoder) ext(n int) (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	extType := int8(b[0])
+	raw, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if extType == -1 {
+		var t time.Time
+		switch n {
+		case 4:
+			t = time.Unix(int64(binary.BigEndian.Uint32(raw)), 0)
+		case 8:
+			v := binary.BigEndian.Uint64(raw)
+			t = time.Unix(int64(v&0x3ffffffff), int64(v>>34))
+		case 12:
+			t = time.Unix(int64(binary.BigEndian.Uint64(raw[4:])), int64(binary.BigEndian.Uint32(raw)))
+		}
+		if !t.IsZero() {
+			return t.UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+	return map[string]interface{}{"type": int64(extType), "data": base64.StdEncoding.EncodeToString(raw)}, nil
+}
@@ -0,0 +1,586 @@
+package codec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ProtobufContentType is sent with Protocol Buffers request bodies
+const ProtobufContentType = "application/x-protobuf"
+
+// ProtoFile holds the messages and enums of a .proto file and its imports
+type ProtoFile struct {
+	messages map[string]*Message // By full name, e.g. shop.v1.Order
+	enums    map[string]*Enum
+}
+
+// Message is a protobuf message type
+type Message struct {
+	Name   string // Full name
+	Fields []*Field
+}
+
+// Field is a field of a message
+type Field struct {
+	Name     string
+	JSONName string // lowerCamelCase name used in JSON
+	Number   int
+	Type     string // Scalar type such as int32, or a message or enum name
+	Repeated bool
+	Packed   bool
+
+	// Map fields have a key type and store values in Type
+	Map     bool
+	KeyType string
+
+	message *Message
+	enum    *Enum
+}
+
+// Enum is a protobuf enum type
+type Enum struct {
+	Name    string
+	Values  map[string]int32
+	byValue map[int32]string
+}
+
+// LoadProto parses a .proto file and the files it imports, which are looked
+// up relative to it. Services, options and extensions are ignored.
+func LoadProto(path string) (*ProtoFile, error) {
+	file := &ProtoFile{messages: make(map[string]*Message), enums: make(map[string]*Enum)}
+	loader := &protoLoader{file: file, loaded: make(map[string]bool)}
+	if err := loader.load(path); err != nil {
+		return nil, err
+	}
+	if err := loader.resolve(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Message finds a message by its full name, or by its short name if that is
+// unambiguous
+func (f *ProtoFile) Message(name string) (*Message, error) {
+	name = strings.TrimPrefix(name, ".")
+	if message, ok := f.messages[name]; ok {
+		return message, nil
+	}
+
+	var found *Message
+	for fullName, message := range f.messages {
+		if strings.HasSuffix(fullName, "."+name) {
+			if found != nil {
+				return nil, fmt.Errorf("message name %q is ambiguous, use its full name", name)
+			}
+			found = message
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("message %q not found", name)
+	}
+	return found, nil
+}
+
+// field finds a field by its proto or JSON name
+func (m *Message) field(name string) *Field {
+	for _, field := range m.Fields {
+		if field.Name == name || field.JSONName == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// fieldByNumber finds a field by its number
+func (m *Message) fieldByNumber(number int) *Field {
+	for _, field := range m.Fields {
+		if field.Number == number {
+			return field
+		}
+	}
+	return nil
+}
+
+// protoLoader parses .proto files into a ProtoFile
+type protoLoader struct {
+	file   *ProtoFile
+	loaded map[string]bool
+
+	// Type references to resolve once all files are loaded
+	pending []pendingField
+}
+
+type pendingField struct {
+	field *Field
+	scope string // Full name of the message declaring the field
+	file  string
+}
+
+func (l *protoLoader) load(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+	l.loaded[abs] = true
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return fmt.Errorf("failed to read proto file: %w", err)
+	}
+	tokens, err := tokenizeProto(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	p := &protoParser{tokens: tokens, loader: l, path: abs, proto3: false}
+	if err := p.parseFile(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// resolve links fields to the message and enum types they name, searching
+// outwards from the declaring message as protoc does
+func (l *protoLoader) resolve() error {
+	for _, pending := range l.pending {
+		field := pending.field
+		if scalarTypes[field.Type] {
+			continue
+		}
+
+		name := field.Type
+		candidates := []string{strings.TrimPrefix(name, ".")}
+		if !strings.HasPrefix(name, ".") {
+			candidates = nil
+			for scope := pending.scope; ; {
+				if scope == "" {
+					candidates = append(candidates, name)
+					break
+				}
+				candidates = append(candidates, scope+"."+name)
+				if i := strings.LastIndex(scope, "."); i >= 0 {
+					scope = scope[:i]
+				} else {
+					scope = ""
+				}
+			}
+		}
+
+		resolved := false
+		for _, candidate := range candidates {
+			if message, ok := l.file.messages[candidate]; ok {
+				field.message, field.Type, resolved = message, candidate, true
+				break
+			}
+			if enum, ok := l.file.enums[candidate]; ok {
+				field.enum, field.Type, resolved = enum, candidate, true
+				break
+			}
+		}
+		if !resolved {
+			return fmt.Errorf("%s: unknown type %q for field %s in %s", pending.file, name, field.Name, pending.scope)
+		}
+	}
+	return nil
+}
+
+// scalarTypes are the protobuf types that aren't messages or enums
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true,
+	"sfixed64": true, "bool": true, "string": true, "bytes": true,
+}
+
+// protoParser parses the tokens of one .proto file
+type protoParser struct {
+	tokens []string
+	pos    int
+	loader *protoLoader
+	path   string
+	pkg    string
+	proto3 bool
+}
+
+func (p *protoParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *protoParser) expect(want string) error {
+	if got := p.next(); got != want {
+		if got == "" {
+			got = "end of file"
+		}
+		return fmt.Errorf("expected %q, got %q", want, got)
+	}
+	return nil
+}
+
+func (p *protoParser) parseFile() error {
+	for p.pos < len(p.tokens) {
+		switch token := p.next(); token {
+		case ";":
+		case "syntax", "edition":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			value := unquote(p.next())
+			p.proto3 = value == "proto3" || token == "edition"
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "package":
+			p.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "import":
+			if next := p.peek(); next == "public" || next == "weak" {
+				p.next()
+			}
+			imported := unquote(p.next())
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+			if err := p.loadImport(imported); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.parseMessage(p.pkg); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(p.pkg); err != nil {
+				return err
+			}
+		case "option":
+			p.skipStatement()
+		case "service", "extend":
+			p.next()
+			if err := p.skipBlock(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %q", token)
+		}
+	}
+	return nil
+}
+
+// loadImport loads an imported file; well-known google/protobuf imports that
+// aren't present are skipped
+func (p *protoParser) loadImport(imported string) error {
+	path := filepath.Join(filepath.Dir(p.path), imported)
+	if _, err := os.Stat(path); err != nil && strings.HasPrefix(imported, "google/protobuf/") {
+		return nil
+	}
+	return p.loader.load(path)
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) error {
+	message := &Message{Name: qualify(scope, p.next())}
+	p.loader.file.messages[message.Name] = message
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(message)
+}
+
+// parseMessageBody parses fields and nested types up to the closing brace
+func (p *protoParser) parseMessageBody(message *Message) error {
+	for {
+		switch token := p.peek(); token {
+		case "":
+			return fmt.Errorf("unclosed message %s", message.Name)
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			if err := p.parseMessage(message.Name); err != nil {
+				return err
+			}
+		case "enum":
+			p.next()
+			if err := p.parseEnum(message.Name); err != nil {
+				return err
+			}
+		case "oneof":
+			// Oneof members are ordinary fields on the wire
+			p.next()
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			if err := p.parseMessageBody(message); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions":
+			p.skipStatement()
+		case "extend":
+			p.next()
+			p.next()
+			if err := p.skipBlock(); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseField(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseField(message *Message) error {
+	field := &Field{}
+	explicitPacked := false
+
+	switch p.peek() {
+	case "repeated":
+		field.Repeated = true
+		p.next()
+	case "optional", "required":
+		p.next()
+	}
+
+	if p.peek() == "map" {
+		p.next()
+		if err := p.expect("<"); err != nil {
+			return err
+		}
+		field.Map = true
+		field.KeyType = p.next()
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		field.Type = p.next()
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+	} else {
+		field.Type = p.next()
+		if field.Type == "group" {
+			return fmt.Errorf("groups are not supported (in %s)", message.Name)
+		}
+	}
+
+	field.Name = p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil || number < 1 {
+		return fmt.Errorf("invalid field number for %s.%s", message.Name, field.Name)
+	}
+	field.Number = number
+	field.JSONName = jsonName(field.Name)
+
+	// Field options such as [packed = false, json_name = "id"]
+	if p.peek() == "[" {
+		p.next()
+		for p.peek() != "]" && p.peek() != "" {
+			name := p.next()
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			value := p.next()
+			switch name {
+			case "packed":
+				field.Packed, explicitPacked = value == "true", true
+			case "json_name":
+				field.JSONName = unquote(value)
+			}
+			if p.peek() == "," {
+				p.next()
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	}
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	if field.Repeated && !explicitPacked && p.proto3 {
+		field.Packed = true
+	}
+	message.Fields = append(message.Fields, field)
+	p.loader.pending = append(p.loader.pending, pendingField{field: field, scope: message.Name, file: p.path})
+	return nil
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	enum := &Enum{Name: qualify(scope, p.next()), Values: make(map[string]int32), byValue: make(map[int32]string)}
+	p.loader.file.enums[enum.Name] = enum
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for {
+		switch token := p.peek(); token {
+		case "":
+			return fmt.Errorf("unclosed enum %s", enum.Name)
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "option", "reserved":
+			p.skipStatement()
+		default:
+			name := p.next()
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s.%s", enum.Name, name)
+			}
+			p.skipStatement()
+			enum.Values[name] = int32(number)
+			if _, ok := enum.byValue[int32(number)]; !ok {
+				enum.byValue[int32(number)] = name
+			}
+		}
+	}
+}
+
+// skipStatement skips to the end of the current statement
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch p.next() {
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+		case ";":
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipBlock skips a { ... } block, including nested blocks
+func (p *protoParser) skipBlock() error {
+	for p.peek() != "{" {
+		if p.next() == "" {
+			return fmt.Errorf("expected {")
+		}
+	}
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unclosed block")
+}
+
+// tokenizeProto splits .proto source into identifiers, numbers, strings
+// and symbols, dropping comments
+func tokenizeProto(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(source) && source[j] != c {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unclosed string")
+			}
+			tokens = append(tokens, source[i:j+1])
+			i = j + 1
+		case isProtoWordByte(c) || (c == '-' && i+1 < len(source) && isProtoWordByte(source[i+1])):
+			j := i + 1
+			for j < len(source) && isProtoWordByte(source[j]) {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoWordByte(c byte) bool {
+	return c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func unquote(token string) string {
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '\'') {
+		return token[1 : len(token)-1]
+	}
+	return token
+}
+
+// jsonName converts a field name to lowerCamelCase, as protoc does
+func jsonName(name string) string {
+	var out strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
@@ -0,0 +1,502 @@
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Encode converts a JSON document to the message's binary encoding. Fields
+// are named by their proto or JSON names; enums by name or number; bytes
+// are base64 strings; 64-bit integers may be numbers or strings.
+func (m *Message) Encode(jsonData []byte) ([]byte, error) {
+	value, err := decodeJSON(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return m.encode(value, m.Name)
+}
+
+func (m *Message) encode(value interface{}, path string) ([]byte, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object for message %s", path, m.Name)
+	}
+
+	// Fields are written in field number order
+	type entry struct {
+		field *Field
+		name  string
+	}
+	entries := make([]entry, 0, len(object))
+	for name := range object {
+		field := m.field(name)
+		if field == nil {
+			return nil, fmt.Errorf("%s: unknown field %q in message %s", path, name, m.Name)
+		}
+		entries = append(entries, entry{field, name})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].field.Number < entries[j].field.Number })
+
+	var out []byte
+	for _, e := range entries {
+		var err error
+		if out, err = e.field.encode(out, object[e.name], path+"."+e.name); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// encode appends a field's value, which may be a list or map
+func (f *Field) encode(out []byte, value interface{}, path string) ([]byte, error) {
+	if value == nil {
+		return out, nil
+	}
+
+	switch {
+	case f.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an object for map field", path)
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		keyField := &Field{Name: "key", Number: 1, Type: f.KeyType}
+		valueField := &Field{Name: "value", Number: 2, Type: f.Type, message: f.message, enum: f.enum}
+		for _, key := range keys {
+			entry, err := keyField.encodeValue(nil, mapKey(key, f.KeyType), path)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = valueField.encodeValue(entry, object[key], path+"."+key); err != nil {
+				return nil, err
+			}
+			out = appendTag(out, f.Number, wireBytes)
+			out = binary.AppendUvarint(out, uint64(len(entry)))
+			out = append(out, entry...)
+		}
+		return out, nil
+
+	case f.Repeated:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an array for repeated field", path)
+		}
+		if f.Packed && f.packable() {
+			var packed []byte
+			for i, item := range items {
+				var err error
+				if packed, err = f.appendScalar(packed, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return nil, err
+				}
+			}
+			out = appendTag(out, f.Number, wireBytes)
+			out = binary.AppendUvarint(out, uint64(len(packed)))
+			return append(out, packed...), nil
+		}
+		for i, item := range items {
+			var err error
+			if out, err = f.encodeValue(out, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	return f.encodeValue(out, value, path)
+}
+
+// mapKey converts a JSON object key to a value of the map's key type
+func mapKey(key, keyType string) interface{} {
+	switch keyType {
+	case "string":
+		return key
+	case "bool":
+		return key == "true"
+	}
+	return json.Number(key)
+}
+
+// encodeValue appends the tag and a single value of the field's type
+func (f *Field) encodeValue(out []byte, value interface{}, path string) ([]byte, error) {
+	switch {
+	case f.message != nil:
+		encoded, err := f.message.encode(value, path)
+		if err != nil {
+			return nil, err
+		}
+		out = appendTag(out, f.Number, wireBytes)
+		out = binary.AppendUvarint(out, uint64(len(encoded)))
+		return append(out, encoded...), nil
+	case f.Type == "string" || f.Type == "bytes":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a string", path)
+		}
+		data := []byte(s)
+		if f.Type == "bytes" {
+			decoded, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bytes must be base64: %w", path, err)
+			}
+			data = decoded
+		}
+		out = appendTag(out, f.Number, wireBytes)
+		out = binary.AppendUvarint(out, uint64(len(data)))
+		return append(out, data...), nil
+	}
+
+	out = appendTag(out, f.Number, f.wireType())
+	return f.appendScalar(out, value, path)
+}
+
+// packable reports whether repeated values of the field can be packed
+func (f *Field) packable() bool {
+	return f.message == nil && f.Type != "string" && f.Type != "bytes"
+}
+
+func (f *Field) wireType() int {
+	switch f.Type {
+	case "double", "fixed64", "sfixed64":
+		return wireFixed64
+	case "float", "fixed32", "sfixed32":
+		return wireFixed32
+	case "string", "bytes":
+		return wireBytes
+	}
+	if f.message != nil {
+		return wireBytes
+	}
+	return wireVarint
+}
+
+// appendScalar appends a number, bool or enum value without a tag
+func (f *Field) appendScalar(out []byte, value interface{}, path string) ([]byte, error) {
+	if f.enum != nil {
+		if name, ok := value.(string); ok {
+			number, ok := f.enum.Values[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown value %q for enum %s", path, name, f.enum.Name)
+			}
+			return binary.AppendUvarint(out, uint64(int64(number))), nil
+		}
+		n, err := integer(value, 32, path)
+		if err != nil {
+			return nil, err
+		}
+		return binary.AppendUvarint(out, uint64(n)), nil
+	}
+
+	switch f.Type {
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a boolean", path)
+		}
+		if b {
+			return append(out, 1), nil
+		}
+		return append(out, 0), nil
+	case "double", "float":
+		number, err := float(value, path)
+		if err != nil {
+			return nil, err
+		}
+		if f.Type == "float" {
+			return binary.LittleEndian.AppendUint32(out, math.Float32bits(float32(number))), nil
+		}
+		return binary.LittleEndian.AppendUint64(out, math.Float64bits(number)), nil
+	case "uint32", "uint64", "fixed32", "fixed64":
+		bits := 64
+		if f.Type == "uint32" || f.Type == "fixed32" {
+			bits = 32
+		}
+		n, err := unsigned(value, bits, path)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Type {
+		case "fixed32":
+			return binary.LittleEndian.AppendUint32(out, uint32(n)), nil
+		case "fixed64":
+			return binary.LittleEndian.AppendUint64(out, n), nil
+		}
+		return binary.AppendUvarint(out, n), nil
+	case "int32", "int64", "sint32", "sint64", "sfixed32", "sfixed64":
+		bits := 64
+		if f.Type == "int32" || f.Type == "sint32" || f.Type == "sfixed32" {
+			bits = 32
+		}
+		n, err := integer(value, bits, path)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Type {
+		case "sint32", "sint64":
+			return binary.AppendVarint(out, n), nil
+		case "sfixed32":
+			return binary.LittleEndian.AppendUint32(out, uint32(int32(n))), nil
+		case "sfixed64":
+			return binary.LittleEndian.AppendUint64(out, uint64(n)), nil
+		}
+		return binary.AppendUvarint(out, uint64(n)), nil
+	}
+	return nil, fmt.Errorf("%s: unsupported type %s", path, f.Type)
+}
+
+func appendTag(out []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(out, uint64(number)<<3|uint64(wireType))
+}
+
+// numberText returns the text of a JSON number, or of a string holding one
+func numberText(value interface{}, path string) (string, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v), nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("%s: expected a number", path)
+}
+
+func integer(value interface{}, bits int, path string) (int64, error) {
+	text, err := numberText(value, path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(text, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%s: expected a %d-bit integer, got %s", path, bits, text)
+	}
+	return n, nil
+}
+
+func unsigned(value interface{}, bits int, path string) (uint64, error) {
+	text, err := numberText(value, path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(text, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%s: expected an unsigned %d-bit integer, got %s", path, bits, text)
+	}
+	return n, nil
+}
+
+func float(value interface{}, path string) (float64, error) {
+	text, err := numberText(value, path)
+	if err != nil {
+		return 0, err
+	}
+	switch text {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: expected a number, got %s", path, text)
+	}
+	return n, nil
+}
+
+// Decode converts the message's binary encoding to a JSON-compatible
+// value. Fields are named by their JSON names; unknown fields are skipped.
+func (m *Message) Decode(data []byte) (map[string]interface{}, error) {
+	object, err := m.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s message: %w", m.Name, err)
+	}
+	return object, nil
+}
+
+func (m *Message) decode(data []byte) (map[string]interface{}, error) {
+	object := make(map[string]interface{})
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid tag")
+		}
+		data = data[n:]
+		number, wireType := int(tag>>3), int(tag&7)
+
+		raw, rest, err := readWireValue(data, wireType)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", number, err)
+		}
+		data = rest
+
+		field := m.fieldByNumber(number)
+		if field == nil {
+			continue
+		}
+		if err := field.decode(object, raw, wireType); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return object, nil
+}
+
+// readWireValue splits the value of a field off the data
+func readWireValue(data []byte, wireType int) (raw, rest []byte, err error) {
+	switch wireType {
+	case wireVarint:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("invalid varint")
+		}
+		return data[:n], data[n:], nil
+	case wireFixed64:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("truncated 64-bit value")
+		}
+		return data[:8], data[8:], nil
+	case wireFixed32:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("truncated 32-bit value")
+		}
+		return data[:4], data[4:], nil
+	case wireBytes:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, nil, fmt.Errorf("truncated length-delimited value")
+		}
+		end := n + int(length)
+		return data[n:end], data[end:], nil
+	}
+	return nil, nil, fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+// decode stores a field's value in object, appending to lists and maps
+func (f *Field) decode(object map[string]interface{}, raw []byte, wireType int) error {
+	if f.Map {
+		entry := &Message{Fields: []*Field{
+			{Name: "key", JSONName: "key", Number: 1, Type: f.KeyType},
+			{Name: "value", JSONName: "value", Number: 2, Type: f.Type, message: f.message, enum: f.enum},
+		}}
+		decoded, err := entry.decode(raw)
+		if err != nil {
+			return err
+		}
+		entries, _ := object[f.JSONName].(map[string]interface{})
+		if entries == nil {
+			entries = make(map[string]interface{})
+			object[f.JSONName] = entries
+		}
+		key := ""
+		if k, ok := decoded["key"]; ok {
+			key = fmt.Sprint(k)
+		}
+		entries[key] = decoded["value"]
+		return nil
+	}
+
+	// Packed repeated scalars arrive as one length-delimited value
+	if wireType == wireBytes && f.packable() {
+		items, _ := object[f.JSONName].([]interface{})
+		for len(raw) > 0 {
+			value, rest, err := readWireValue(raw, f.wireType())
+			if err != nil {
+				return err
+			}
+			raw = rest
+			decoded, err := f.decodeValue(value, f.wireType())
+			if err != nil {
+				return err
+			}
+			items = append(items, decoded)
+		}
+		object[f.JSONName] = items
+		return nil
+	}
+
+	value, err := f.decodeValue(raw, wireType)
+	if err != nil {
+		return err
+	}
+	if f.Repeated {
+		items, _ := object[f.JSONName].([]interface{})
+		object[f.JSONName] = append(items, value)
+	} else {
+		object[f.JSONName] = value
+	}
+	return nil
+}
+
+// decodeValue decodes a single value of the field's type
+func (f *Field) decodeValue(raw []byte, wireType int) (interface{}, error) {
+	if wireType != f.wireType() {
+		return nil, fmt.Errorf("wire type %d doesn't match type %s", wireType, f.Type)
+	}
+
+	switch {
+	case f.message != nil:
+		return f.message.decode(raw)
+	case f.Type == "string":
+		return string(raw), nil
+	case f.Type == "bytes":
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+
+	switch wireType {
+	case wireFixed32:
+		bits := binary.LittleEndian.Uint32(raw)
+		switch f.Type {
+		case "float":
+			return float64(math.Float32frombits(bits)), nil
+		case "sfixed32":
+			return int64(int32(bits)), nil
+		}
+		return int64(bits), nil
+	case wireFixed64:
+		bits := binary.LittleEndian.Uint64(raw)
+		switch f.Type {
+		case "double":
+			return math.Float64frombits(bits), nil
+		case "sfixed64":
+			return int64(bits), nil
+		}
+		return bits, nil
+	}
+
+	v, _ := binary.Uvarint(raw)
+	switch {
+	case f.enum != nil:
+		if name, ok := f.enum.byValue[int32(v)]; ok {
+			return name, nil
+		}
+		return int64(int32(v)), nil
+	case f.Type == "bool":
+		return v != 0, nil
+	case f.Type == "int32":
+		return int64(int32(v)), nil
+	case f.Type == "int64":
+		return int64(v), nil
+	case f.Type == "uint32":
+		return int64(uint32(v)), nil
+	case f.Type == "sint32" || f.Type == "sint64":
+		n, _ := binary.Varint(raw)
+		return n, nil
+	}
+	return v, nil
+}
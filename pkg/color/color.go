@@ -0,0 +1,181 @@
+// Package color adds ANSI colors to terminal output. Colors come from a
+// theme, and are only used when output goes to a terminal, unless the user
+// asks otherwise. The NO_COLOR environment variable (https://no-color.org)
+// turns them off.
+package color
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Modes accepted by --color
+const (
+	Auto   = "auto"   // Color output to terminals, unless NO_COLOR is set
+	Always = "always" // Color output, even when piped
+	Never  = "never"  // Never color output
+)
+
+// ThemeEnv is the environment variable that chooses the theme
+const ThemeEnv = "POSTIE_THEME"
+
+// DefaultTheme is the theme used unless another is chosen
+const DefaultTheme = "default"
+
+// Theme holds the ANSI SGR codes, such as "1;32" for bold green, used for
+// each kind of text. An empty code leaves the text as is.
+type Theme struct {
+	Success string // Passed statuses and tests
+	Failure string // Failed statuses, tests and errors
+	Warning string // Skipped requests and warnings
+	Heading string // Section headings
+	Muted   string // Details such as durations and sizes
+
+	Key     string // JSON object keys
+	String  string // JSON strings
+	Number  string // JSON numbers
+	Literal string // JSON true, false and null
+
+	Added   string // Lines only in the second side of a diff
+	Removed string // Lines only in the first side of a diff
+	Changed string // Lines that differ between the sides of a diff
+}
+
+// Themes are the built-in themes, by name
+var Themes = map[string]*Theme{
+	// Bright colors for dark terminal backgrounds
+	"default": {
+		Success: "32", Failure: "31", Warning: "33", Heading: "1", Muted: "2",
+		Key: "34", String: "32", Number: "36", Literal: "35",
+		Added: "32", Removed: "31", Changed: "33",
+	},
+	// Darker colors that stay readable on light backgrounds
+	"light": {
+		Success: "38;5;28", Failure: "38;5;160", Warning: "38;5;130", Heading: "1", Muted: "38;5;244",
+		Key: "38;5;25", String: "38;5;28", Number: "38;5;30", Literal: "38;5;90",
+		Added: "38;5;28", Removed: "38;5;160", Changed: "38;5;130",
+	},
+	// Bold, underline and dim instead of hues
+	"mono": {
+		Success: "1", Failure: "1;4", Warning: "4", Heading: "1", Muted: "2",
+		Key: "1", Literal: "4",
+		Added: "1", Removed: "2", Changed: "4",
+	},
+}
+
+// ThemeNames returns the names of the built-in themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseMode checks a --color value
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case Auto, Always, Never:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported color mode: %s (use auto, always or never)", mode)
+}
+
+// LookupTheme returns the theme called name
+func LookupTheme(name string) (*Theme, error) {
+	theme, ok := Themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	return theme, nil
+}
+
+// Enabled reports whether output to file is colored in mode. In auto mode
+// it is if file is a terminal, NO_COLOR is empty and TERM isn't "dumb".
+func Enabled(mode string, file *os.File) bool {
+	switch mode {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Palette colors text with a theme. A nil Palette leaves text as is, so
+// callers don't need to check whether color is enabled.
+type Palette struct {
+	theme *Theme
+}
+
+// New creates a palette for theme
+func New(theme *Theme) *Palette {
+	return &Palette{theme: theme}
+}
+
+// paint wraps s in the escape codes for code
+func (p *Palette) paint(code func(*Theme) string, s string) string {
+	if p == nil || s == "" {
+		return s
+	}
+	if c := code(p.theme); c != "" {
+		return "\x1b[" + c + "m" + s + "\x1b[0m"
+	}
+	return s
+}
+
+// Success colors passed statuses and tests
+func (p *Palette) Success(s string) string {
+	return p.paint(func(t *Theme) string { return t.Success }, s)
+}
+
+// Failure colors failures and errors
+func (p *Palette) Failure(s string) string {
+	return p.paint(func(t *Theme) string { return t.Failure }, s)
+}
+
+// Warning colors skipped requests and warnings
+func (p *Palette) Warning(s string) string {
+	return p.paint(func(t *Theme) string { return t.Warning }, s)
+}
+
+// Heading colors section headings
+func (p *Palette) Heading(s string) string {
+	return p.paint(func(t *Theme) string { return t.Heading }, s)
+}
+
+// Muted colors details such as durations and sizes
+func (p *Palette) Muted(s string) string {
+	return p.paint(func(t *Theme) string { return t.Muted }, s)
+}
+
+// Added colors lines only in the second side of a diff
+func (p *Palette) Added(s string) string {
+	return p.paint(func(t *Theme) string { return t.Added }, s)
+}
+
+// Removed colors lines only in the first side of a diff
+func (p *Palette) Removed(s string) string {
+	return p.paint(func(t *Theme) string { return t.Removed }, s)
+}
+
+// Changed colors lines that differ between the sides of a diff
+func (p *Palette) Changed(s string) string {
+	return p.paint(func(t *Theme) string { return t.Changed }, s)
+}
+
+// Status colors text about an HTTP status: success for 2xx and 3xx,
+// failure for other codes
+func (p *Palette) Status(code int, s string) string {
+	if code >= 200 && code < 400 {
+		return p.Success(s)
+	}
+	return p.Failure(s)
+}
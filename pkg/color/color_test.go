@@ -0,0 +1,66 @@
+package color
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	var none *Palette
+	if got := none.Failure("✗ failed"); got != "✗ failed" {
+		t.Errorf("Expected a nil palette to leave text as is, got %q", got)
+	}
+	if got := none.JSON(`{"a": 1}`); got != `{"a": 1}` {
+		t.Errorf("Expected a nil palette to leave JSON as is, got %q", got)
+	}
+
+	palette := New(Themes["default"])
+	if got := palette.Success("ok"); got != "\x1b[32mok\x1b[0m" {
+		t.Errorf("Unexpected success color: %q", got)
+	}
+	if got := palette.Status(404, "404"); got != "\x1b[31m404\x1b[0m" {
+		t.Errorf("Unexpected status color: %q", got)
+	}
+	if got := New(Themes["mono"]).JSON(`"x"`); got != `"x"` {
+		t.Errorf("Expected mono strings to be plain, got %q", got)
+	}
+}
+
+func TestPaletteJSON(t *testing.T) {
+	palette := New(&Theme{Key: "K", String: "S", Number: "N", Literal: "L"})
+
+	got := palette.JSON(`{"name": "a \"b\"", "n": -1.5e3, "ok": [true, null]}`)
+	want := "{\x1b[Km\"name\"\x1b[0m: \x1b[Sm\"a \\\"b\\\"\"\x1b[0m, \x1b[Km\"n\"\x1b[0m: \x1b[Nm-1.5e3\x1b[0m, " +
+		"\x1b[Km\"ok\"\x1b[0m: [\x1b[Lmtrue\x1b[0m, \x1b[Lmnull\x1b[0m]}"
+	if got != want {
+		t.Errorf("Unexpected highlighting:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	t.Setenv("NO_COLOR", "")
+	if !Enabled(Always, file) || Enabled(Never, file) {
+		t.Error("Expected always and never to ignore the output")
+	}
+	if Enabled(Auto, file) {
+		t.Error("Expected no color in auto mode when output isn't a terminal")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if !Enabled(Always, file) {
+		t.Error("Expected --color always to override NO_COLOR")
+	}
+
+	if _, err := ParseMode("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	if _, err := LookupTheme("neon"); err == nil {
+		t.Error("Expected an error for an unknown theme")
+	}
+}
@@ -0,0 +1,77 @@
+package color
+
+import (
+	"strings"
+)
+
+// JSON highlights the keys, strings, numbers and literals of a JSON
+// document. Text that isn't valid JSON is colored as well as it can be, and
+// is never changed otherwise.
+func (p *Palette) JSON(text string) string {
+	if p == nil {
+		return text
+	}
+
+	var b strings.Builder
+	b.Grow(len(text) * 2)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"':
+			end := stringEnd(text, i)
+			token := text[i:end]
+			if isKey(text, end) {
+				b.WriteString(p.paint(func(t *Theme) string { return t.Key }, token))
+			} else {
+				b.WriteString(p.paint(func(t *Theme) string { return t.String }, token))
+			}
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(text) && strings.IndexByte("0123456789.eE+-", text[end]) >= 0 {
+				end++
+			}
+			b.WriteString(p.paint(func(t *Theme) string { return t.Number }, text[i:end]))
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			literal := ""
+			for _, l := range []string{"true", "false", "null"} {
+				if strings.HasPrefix(text[i:], l) {
+					literal = l
+					break
+				}
+			}
+			if literal == "" {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			b.WriteString(p.paint(func(t *Theme) string { return t.Literal }, literal))
+			i += len(literal)
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// stringEnd returns the index after the string starting at start, or the
+// end of text if it isn't closed
+func stringEnd(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+// isKey reports whether the string ending at end is an object key
+func isKey(text string, end int) bool {
+	rest := strings.TrimLeft(text[end:], " \t\r\n")
+	return strings.HasPrefix(rest, ":")
+}
@@ -4,9 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"postie/pkg/cli"
 	"postie/pkg/context"
+	"postie/pkg/responses"
 )
 
 // ContextCommands returns the context command with subcommands
@@ -55,6 +57,10 @@ func executeContextSet(args []string) error {
 	privateEnvFile := fs.String("private-env-file", "", "Path to private environment file")
 	saveResponses := fs.Bool("save-responses", false, "Save responses to files")
 	responsesDir := fs.String("responses-dir", "", "Directory to save responses")
+	responsesMaxAge := fs.String("responses-max-age", "", "Delete saved responses older than this (e.g. 7d, 12h)")
+	responsesMaxSize := fs.String("responses-max-size", "", "Maximum total size of saved responses (e.g. 100MB)")
+	responsesMaxHistory := fs.Int("responses-max-history", 0, "Saved responses to keep per request")
+	safeHosts := fs.String("safe-hosts", "", "Comma-separated hosts POST, PUT, PATCH and DELETE may be sent to, e.g. localhost,*.staging.example.com (none to allow any)")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -111,6 +117,35 @@ func executeContextSet(args []string) error {
 		ctx.ResponsesDir = absPath
 		updated = true
 	}
+	if *responsesMaxAge != "" {
+		if _, err := responses.ParseAge(*responsesMaxAge); err != nil {
+			return err
+		}
+		ctx.ResponsesMaxAge = *responsesMaxAge
+		updated = true
+	}
+	if *responsesMaxSize != "" {
+		if _, err := responses.ParseSize(*responsesMaxSize); err != nil {
+			return err
+		}
+		ctx.ResponsesMaxSize = *responsesMaxSize
+		updated = true
+	}
+	if *responsesMaxHistory > 0 {
+		ctx.ResponsesMaxHistory = *responsesMaxHistory
+		updated = true
+	}
+	if *safeHosts != "" {
+		ctx.SafeHosts = nil
+		if *safeHosts != "none" {
+			for _, host := range strings.Split(*safeHosts, ",") {
+				if host = strings.TrimSpace(host); host != "" {
+					ctx.SafeHosts = append(ctx.SafeHosts, host)
+				}
+			}
+		}
+		updated = true
+	}
 
 	if !updated {
 		return fmt.Errorf("no context values provided. Use flags like --http-file, --env, --env-file, etc.")
@@ -159,9 +194,23 @@ func executeContextShow(args []string) error {
 	if ctx.ResponsesDir != "" {
 		fmt.Printf("Responses Dir:     %s\n", ctx.ResponsesDir)
 	}
+	if ctx.ResponsesMaxAge != "" {
+		fmt.Printf("Max Age:           %s\n", ctx.ResponsesMaxAge)
+	}
+	if ctx.ResponsesMaxSize != "" {
+		fmt.Printf("Max Size:          %s\n", ctx.ResponsesMaxSize)
+	}
+	if ctx.ResponsesMaxHistory > 0 {
+		fmt.Printf("Max History:       %d\n", ctx.ResponsesMaxHistory)
+	}
+	if len(ctx.SafeHosts) > 0 {
+		fmt.Printf("Safe Hosts:        %s\n", strings.Join(ctx.SafeHosts, ", "))
+	}
 
 	if ctx.HTTPFile == "" && ctx.Environment == "" && ctx.EnvFile == "" &&
-		ctx.PrivateEnvFile == "" && !ctx.SaveResponses && ctx.ResponsesDir == "" {
+		ctx.PrivateEnvFile == "" && !ctx.SaveResponses && ctx.ResponsesDir == "" &&
+		ctx.ResponsesMaxAge == "" && ctx.ResponsesMaxSize == "" && ctx.ResponsesMaxHistory == 0 &&
+		len(ctx.SafeHosts) == 0 {
 		fmt.Println("Context is empty.")
 	}
 
@@ -0,0 +1,331 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/cli"
+	"postie/pkg/contract"
+	"postie/pkg/display"
+	"postie/pkg/executor"
+	"postie/pkg/middleware"
+	"postie/pkg/responses"
+)
+
+// ContractCommands returns the contract command for checking providers
+// against consumer expectations
+func ContractCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "contract",
+		Description: "Check that a provider API meets the expectations of its consumers",
+		Subcommands: map[string]*cli.Command{
+			"verify": contractVerifyCommand(),
+			"record": contractRecordCommand(),
+			"import": contractImportCommand(),
+			"export": contractExportCommand(),
+		},
+	}
+}
+
+// contractVerifyReport is the JSON output of contract verify
+type contractVerifyReport struct {
+	Provider string             `json:"provider"`
+	Results  []*contract.Result `json:"results"`
+	Passed   int                `json:"passed"`
+	Failed   int                `json:"failed"`
+}
+
+// leadingArgs splits off the arguments before the first flag
+func leadingArgs(args []string) ([]string, []string) {
+	var leading []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		leading = append(leading, args[0])
+		args = args[1:]
+	}
+	return leading, args
+}
+
+func contractVerifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "verify",
+		Description: "Run the interactions of contract files against a provider",
+		Action: func(args []string) error {
+			providerFlag := &cli.StringFlag{Name: "provider-url", Usage: "Base URL of the provider to verify", Required: true}
+			headerFlag := &cli.StringFlag{Name: "header", ShortName: "H", Usage: "Add a header to every request as \"Name: value\" (repeatable)", Required: false, Multiple: true}
+			yesIKnowFlag := &cli.BoolFlag{Name: "yes-i-know", Usage: "Send POST, PUT, PATCH and DELETE requests even if the provider isn't one of the context's safe hosts"}
+
+			// Contract files come before the flags, or after them
+			files, args := leadingArgs(args)
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{providerFlag, headerFlag}, []*cli.BoolFlag{yesIKnowFlag})
+			if err != nil {
+				return err
+			}
+			files = append(files, fs.Args()...)
+			if len(files) == 0 {
+				return fmt.Errorf("no contract files given")
+			}
+
+			headers, err := parseHeaderFlags(headerFlag.Values)
+			if err != nil {
+				return err
+			}
+
+			var contracts []*contract.Contract
+			for _, file := range files {
+				loaded, err := contract.Load(file)
+				if err != nil {
+					return cli.Exit(cli.ExitConfig, err)
+				}
+				contracts = append(contracts, loaded)
+			}
+
+			report := &contractVerifyReport{Provider: providerFlag.Value, Results: []*contract.Result{}}
+			hosts, err := loadSafeHosts(yesIKnowFlag.Value)
+			if err != nil {
+				return err
+			}
+			exec := executor.NewExecutor(nil, &executor.ExecutorConfig{ScriptHTTPCheck: middleware.HostSafetyCheck(hosts)})
+			if len(hosts) > 0 {
+				exec.AddHook(middleware.HostSafetyHook(hosts))
+			}
+			for _, loaded := range contracts {
+				report.Results = append(report.Results, contract.Verify(exec, loaded, providerFlag.Value, headers)...)
+			}
+			return executeContractVerify(report)
+		},
+	}
+}
+
+func executeContractVerify(report *contractVerifyReport) error {
+	unsent := 0
+	for _, result := range report.Results {
+		switch {
+		case result.Passed:
+			report.Passed++
+		case result.Error != "":
+			unsent++
+			report.Failed++
+		default:
+			report.Failed++
+		}
+	}
+
+	if cli.IsJSONOutput() {
+		if err := outputJSON(report); err != nil {
+			return err
+		}
+	} else {
+		printContractVerify(report)
+	}
+
+	switch {
+	case unsent > 0:
+		return cli.Exit(cli.ExitTransport, fmt.Errorf("%d of %d interactions couldn't be sent", unsent, len(report.Results)))
+	case report.Failed > 0:
+		return cli.Exit(cli.ExitFailed, fmt.Errorf("%d of %d interactions broke the contract", report.Failed, len(report.Results)))
+	}
+	return nil
+}
+
+func printContractVerify(report *contractVerifyReport) {
+	palette := cli.Palette()
+	fmt.Printf("Verifying %s\n\n", report.Provider)
+
+	for _, result := range report.Results {
+		if result.Passed {
+			fmt.Printf("%s %s  %s\n", palette.Success("✓"), result.Interaction, palette.Muted(display.Milliseconds(result.DurationMs)))
+			continue
+		}
+		fmt.Printf("%s %s\n", palette.Failure("✗"), result.Interaction)
+		fmt.Printf("    %s %s\n", result.Method, result.URL)
+		if result.Error != "" {
+			fmt.Printf("    error: %s\n", result.Error)
+		}
+		for _, violation := range result.Violations {
+			fmt.Printf("    %s\n", violation)
+		}
+	}
+
+	fmt.Printf("\n%d interactions: %d passed, %d failed\n", len(report.Results), report.Passed, report.Failed)
+}
+
+func contractRecordCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "record",
+		Description: "Add the interactions of saved responses to a contract file",
+		Action: func(args []string) error {
+			outFlag := &cli.StringFlag{Name: "out", ShortName: "o", Usage: "Contract file to write, merged with if it exists", Required: true}
+			consumerFlag := &cli.StringFlag{Name: "consumer", Usage: "Name of the consumer", Required: false}
+			providerFlag := &cli.StringFlag{Name: "provider", Usage: "Name of the provider", Required: false}
+
+			// Saved responses come before the flags, or after them
+			files, args := leadingArgs(args)
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{outFlag, consumerFlag, providerFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+			files = append(files, fs.Args()...)
+			if len(files) == 0 {
+				return fmt.Errorf("no saved responses given")
+			}
+
+			return executeContractRecord(files, outFlag.Value, consumerFlag.Value, providerFlag.Value)
+		},
+	}
+}
+
+func executeContractRecord(files []string, out, consumer, provider string) error {
+	recorded := &contract.Contract{}
+	if _, err := os.Stat(out); err == nil {
+		if recorded, err = contract.Load(out); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if consumer != "" {
+		recorded.Consumer = consumer
+	}
+	if provider != "" {
+		recorded.Provider = provider
+	}
+
+	storage := responses.NewStorage(nil)
+	for _, file := range files {
+		stored, err := storage.Load(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		interaction, err := contract.FromStoredResponse(stored)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		recorded.Add(interaction)
+		fmt.Printf("Recorded %s\n", interaction.Description)
+	}
+
+	if err := recorded.Save(out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d interactions to %s\n", len(recorded.Interactions), out)
+	return nil
+}
+
+func contractImportCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "import",
+		Description: "Convert a Pact file to runnable requests or a contract",
+		Action: func(args []string) error {
+			outFlag := &cli.StringFlag{Name: "out", ShortName: "o", Usage: "File to write (default: standard output)", Required: false}
+			formatFlag := &cli.StringFlag{Name: "format", Usage: "What to write: http (requests with expectations) or contract (default: http)", Required: false}
+
+			files, args := leadingArgs(args)
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{outFlag, formatFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+			files = append(files, fs.Args()...)
+			if len(files) != 1 {
+				return fmt.Errorf("give one Pact file to import")
+			}
+
+			format := formatFlag.Value
+			if format == "" {
+				format = "http"
+			}
+			if format != "http" && format != "contract" {
+				return fmt.Errorf("invalid --format %q (use http or contract)", format)
+			}
+			return executeContractImport(files[0], outFlag.Value, format)
+		},
+	}
+}
+
+func executeContractImport(file, out, format string) error {
+	pact, err := contract.LoadPact(file)
+	if err != nil {
+		return err
+	}
+	imported, err := pact.Contract()
+	if err != nil {
+		return fmt.Errorf("invalid pact %s: %w", file, err)
+	}
+
+	if format == "contract" {
+		if out == "" {
+			return outputJSON(imported)
+		}
+		if err := imported.Save(out); err != nil {
+			return err
+		}
+	} else {
+		if out == "" {
+			fmt.Print(imported.HTTPFile())
+			return nil
+		}
+		if err := atomicfile.WriteFile(out, []byte(imported.HTTPFile()), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+	}
+	fmt.Printf("Imported %d interactions to %s\n", len(imported.Interactions), out)
+	return nil
+}
+
+func contractExportCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "export",
+		Description: "Add the interactions of saved responses to a Pact file",
+		Action: func(args []string) error {
+			outFlag := &cli.StringFlag{Name: "out", ShortName: "o", Usage: "Pact file to write, merged with if it exists", Required: true}
+			consumerFlag := &cli.StringFlag{Name: "consumer", Usage: "Name of the consumer", Required: true}
+			providerFlag := &cli.StringFlag{Name: "provider", Usage: "Name of the provider", Required: true}
+
+			files, args := leadingArgs(args)
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{outFlag, consumerFlag, providerFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+			files = append(files, fs.Args()...)
+			if len(files) == 0 {
+				return fmt.Errorf("no saved responses given")
+			}
+
+			return executeContractExport(files, outFlag.Value, consumerFlag.Value, providerFlag.Value)
+		},
+	}
+}
+
+func executeContractExport(files []string, out, consumer, provider string) error {
+	pact := contract.NewPact(consumer, provider)
+	if _, err := os.Stat(out); err == nil {
+		existing, err := contract.LoadPact(out)
+		if err != nil {
+			return err
+		}
+		pact.Interactions = existing.Interactions
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	storage := responses.NewStorage(nil)
+	for _, file := range files {
+		stored, err := storage.Load(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		interaction, err := contract.PactFromStoredResponse(stored)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		pact.Add(interaction)
+		fmt.Printf("Exported %s\n", interaction.Description)
+	}
+
+	if err := pact.Save(out); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d interactions to %s\n", len(pact.Interactions), out)
+	return nil
+}
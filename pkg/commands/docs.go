@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"postie/pkg/apidocs"
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+)
+
+// DocsCommands returns the docs command for generating request documentation
+func DocsCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "docs",
+		Description: "Generate documentation for the requests in .http files",
+		Subcommands: map[string]*cli.Command{
+			"generate": docsGenerateCommand(),
+		},
+	}
+}
+
+func docsGenerateCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "generate",
+		Description: "Write Markdown or HTML pages documenting each .http file",
+		Action: func(args []string) error {
+			ctx, err := context.NewManager().Load()
+			if err != nil {
+				return err
+			}
+
+			var envFile, privateEnvFile, responsesDir string
+
+			outFlag := &cli.StringFlag{Name: "out", Usage: "Output directory (default: api-docs)", Required: false}
+			formatFlag := &cli.StringFlag{Name: "format", Usage: "Output format: markdown or html (default: markdown)", Required: false}
+			titleFlag := &cli.StringFlag{Name: "title", Usage: "Title of the index page (default: API documentation)", Required: false}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			responsesDirFlag := &cli.StringFlag{Name: "responses-dir", Value: responsesDir, Usage: "Directory of saved responses used as samples (default: .http-responses)", Required: false}
+			noSamplesFlag := &cli.BoolFlag{Name: "no-samples", Usage: "Don't include saved responses as samples"}
+
+			// Paths come before the flags, or after them
+			var paths []string
+			for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				paths = append(paths, args[0])
+				args = args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{outFlag, formatFlag, titleFlag, envFileFlag, privateEnvFileFlag, responsesDirFlag}, []*cli.BoolFlag{noSamplesFlag})
+			if err != nil {
+				return err
+			}
+			paths = append(paths, fs.Args()...)
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+
+			format := formatFlag.Value
+			if format == "" {
+				format = "markdown"
+			}
+			if format != "markdown" && format != "html" {
+				return fmt.Errorf("invalid --format %q, expected markdown or html", format)
+			}
+			title := titleFlag.Value
+			if title == "" {
+				title = "API documentation"
+			}
+			outDir := outFlag.Value
+			if outDir == "" {
+				outDir = "api-docs"
+			}
+
+			var httpFile, env string
+			var saveResponses bool
+			envFile = envFileFlag.Value
+			privateEnvFile = privateEnvFileFlag.Value
+			responsesDir = responsesDirFlag.Value
+			context.MergeWithFlags(ctx, &httpFile, &env, &envFile, &privateEnvFile, &responsesDir, &saveResponses)
+			if envFile == "" {
+				envFile = "http-client.env.json"
+			}
+			if privateEnvFile == "" {
+				privateEnvFile = "http-client.private.env.json"
+			}
+
+			var storage *responses.Storage
+			if !noSamplesFlag.Value {
+				config, err := storageConfig(ctx, responsesDir)
+				if err != nil {
+					return err
+				}
+				storage = responses.NewStorage(config)
+			}
+
+			return executeDocsGenerate(&docsGenerateOptions{
+				Paths:          paths,
+				OutDir:         outDir,
+				Format:         format,
+				Title:          title,
+				EnvFile:        envFile,
+				PrivateEnvFile: privateEnvFile,
+				Storage:        storage,
+			})
+		},
+	}
+}
+
+// docsGenerateOptions holds the settings for "docs generate"
+type docsGenerateOptions struct {
+	Paths          []string
+	OutDir         string
+	Format         string // markdown or html
+	Title          string
+	EnvFile        string
+	PrivateEnvFile string
+	Storage        *responses.Storage // Source of sample responses; nil for none
+}
+
+func executeDocsGenerate(opts *docsGenerateOptions) error {
+	scans, err := scanHTTPFiles(opts.Paths)
+	if err != nil {
+		return err
+	}
+	if len(scans) == 0 {
+		return fmt.Errorf("no .http files found in %s", strings.Join(opts.Paths, ", "))
+	}
+
+	publicEnv, privateEnv, err := loadEnvironmentPair(opts.EnvFile, opts.PrivateEnvFile)
+	if err != nil {
+		return err
+	}
+	envNames, resolved := resolveEnvironments(publicEnv, privateEnv)
+
+	// Secrets are masked in the variable tables and in sample responses
+	redactor := redact.New()
+	for _, env := range resolved {
+		for name := range env.Variables {
+			if env.IsSecret(name) {
+				redactor.Add(env.GetString(name))
+			}
+		}
+	}
+
+	// Variables set by response handlers don't need an environment
+	scriptVars := make(map[string]bool)
+	for _, scan := range scans {
+		for name := range scan.ScriptVars {
+			scriptVars[name] = true
+		}
+	}
+
+	extension := ".md"
+	if opts.Format == "html" {
+		extension = ".html"
+	}
+
+	var pages []*apidocs.Page
+	for _, scan := range scans {
+		requestsFile, err := parseHttpFile(scan.File)
+		if err != nil {
+			return fmt.Errorf("%s: %w", scan.File, err)
+		}
+
+		source := scan.File
+		if rel, err := filepath.Rel(".", scan.File); err == nil {
+			source = filepath.ToSlash(rel)
+		}
+		page := &apidocs.Page{
+			Title:        strings.TrimSuffix(filepath.Base(scan.File), filepath.Ext(scan.File)),
+			Source:       source,
+			Link:         apidocs.PageLink(source, extension),
+			Environments: envNames,
+		}
+
+		for i := range requestsFile.Requests {
+			request := &requestsFile.Requests[i]
+			doc := apidocs.NewRequest(request, i)
+			if opts.Storage != nil && request.Name != "" {
+				doc.Sample = docsSample(opts.Storage, request.Name, redactor)
+			}
+			page.Requests = append(page.Requests, doc)
+		}
+
+		seen := make(map[string]bool)
+		for _, ref := range scan.Refs {
+			_, local := scan.FileVars[ref.Name]
+			if strings.HasPrefix(ref.Name, "$") || local || seen[ref.Name] {
+				continue
+			}
+			seen[ref.Name] = true
+
+			variable := apidocs.Variable{Name: ref.Name}
+			defined := false
+			for _, name := range envNames {
+				value := ""
+				if env := resolved[name]; env != nil && env.HasVariable(ref.Name) {
+					value = env.GetString(ref.Name)
+					if env.IsSecret(ref.Name) {
+						value = redact.Mask
+					}
+					defined = true
+				}
+				variable.Values = append(variable.Values, value)
+			}
+			if defined || !scriptVars[ref.Name] {
+				page.Variables = append(page.Variables, variable)
+			}
+		}
+		sort.Slice(page.Variables, func(i, j int) bool { return page.Variables[i].Name < page.Variables[j].Name })
+
+		pages = append(pages, page)
+	}
+
+	for _, page := range pages {
+		content := apidocs.Markdown(page)
+		if opts.Format == "html" {
+			if content, err = apidocs.HTML(page); err != nil {
+				return fmt.Errorf("failed to render %s: %w", page.Source, err)
+			}
+		}
+		if err := writeDocsFile(filepath.Join(opts.OutDir, filepath.FromSlash(page.Link)), content); err != nil {
+			return err
+		}
+	}
+
+	index := apidocs.MarkdownIndex(opts.Title, pages)
+	if opts.Format == "html" {
+		if index, err = apidocs.HTMLIndex(opts.Title, pages); err != nil {
+			return fmt.Errorf("failed to render index: %w", err)
+		}
+	}
+	indexPath := filepath.Join(opts.OutDir, "index"+extension)
+	if err := writeDocsFile(indexPath, index); err != nil {
+		return err
+	}
+
+	requests := 0
+	for _, page := range pages {
+		requests += len(page.Requests)
+	}
+	fmt.Printf("Documented %d requests in %d files: %s\n", requests, len(pages), indexPath)
+	return nil
+}
+
+// docsSample returns the latest saved response of a request, or nil
+func docsSample(storage *responses.Storage, requestName string, redactor *redact.Redactor) *apidocs.Sample {
+	records, err := storage.Recent(requestName, 1)
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	response, err := storage.Load(records[0].FilePath)
+	if err != nil {
+		return nil
+	}
+	return &apidocs.Sample{
+		Status:      response.Status,
+		ContentType: response.ContentType,
+		Timestamp:   response.Timestamp,
+		Duration:    response.Duration,
+		Body:        redactor.Redact(response.Body),
+	}
+}
+
+func writeDocsFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -19,6 +19,9 @@ func EnvCommands() *cli.Command {
 		Subcommands: map[string]*cli.Command{
 			"list": envListCommand(),
 			"show": envShowCommand(),
+			"diff": envDiffCommand(),
+			"lint": envLintCommand(),
+			"init": envInitCommand(),
 		},
 	}
 }
@@ -58,17 +61,18 @@ func envShowCommand() *cli.Command {
 		Description: "Show variables for a specific environment",
 		Action: func(args []string) error {
 			if len(args) == 0 {
-				return fmt.Errorf("environment name required\nUsage: postie env show <environment> [--env-file file.json]")
+				return fmt.Errorf("environment name required\nUsage: postie env show <environment> [--env-file file.json] [--format text|json|yaml]")
 			}
 
-			var envFile, privateEnvFile string
+			var envFile, privateEnvFile, format string
 			var showPrivate bool
 
 			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
 			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			formatFlag := &cli.StringFlag{Name: "format", ShortName: "f", Value: format, Usage: "Output format (text, json, yaml)", Required: false}
 			showPrivateFlag := &cli.BoolFlag{Name: "show-private", Value: showPrivate, Usage: "Show private variables"}
 
-			_, err := cli.ParseFlags(args[1:], []*cli.StringFlag{envFileFlag, privateEnvFileFlag}, []*cli.BoolFlag{showPrivateFlag})
+			_, err := cli.ParseFlags(args[1:], []*cli.StringFlag{envFileFlag, privateEnvFileFlag, formatFlag}, []*cli.BoolFlag{showPrivateFlag})
 			if err != nil {
 				return err
 			}
@@ -82,12 +86,23 @@ func envShowCommand() *cli.Command {
 				privateEnvFile = "http-client.private.env.json"
 			}
 			showPrivate = showPrivateFlag.Value
+			format = formatFlag.Value
+			if format == "" {
+				format = "text"
+			}
 
-			return executeEnvShow(args[0], envFile, privateEnvFile, showPrivate)
+			return executeEnvShow(args[0], envFile, privateEnvFile, showPrivate, format)
 		},
 	}
 }
 
+// envListEntry is the structured form of an 'env list' entry used for json output
+type envListEntry struct {
+	Name             string `json:"name"`
+	PublicVariables  int    `json:"public_variables"`
+	PrivateVariables int    `json:"private_variables"`
+}
+
 func executeEnvList(envFile string, privateEnvFile string) error {
 	// Get working directory
 	workingDir := "."
@@ -108,6 +123,9 @@ func executeEnvList(envFile string, privateEnvFile string) error {
 	if err != nil {
 		// Check if files just don't exist
 		if os.IsNotExist(err) {
+			if cli.IsJSONOutput() {
+				return outputJSON([]envListEntry{})
+			}
 			fmt.Println("No environment files found.")
 			fmt.Printf("Create %s to define environments.\n", envFile)
 			return nil
@@ -128,7 +146,7 @@ func executeEnvList(envFile string, privateEnvFile string) error {
 		}
 	}
 
-	if len(envNames) == 0 {
+	if len(envNames) == 0 && !cli.IsJSONOutput() {
 		fmt.Println("No environments defined.")
 		return nil
 	}
@@ -140,6 +158,21 @@ func executeEnvList(envFile string, privateEnvFile string) error {
 	}
 	sort.Strings(names)
 
+	if cli.IsJSONOutput() {
+		entries := make([]envListEntry, 0, len(names))
+		for _, name := range names {
+			entry := envListEntry{Name: name}
+			if publicEnv != nil {
+				entry.PublicVariables = len((*publicEnv)[name])
+			}
+			if privateEnv != nil {
+				entry.PrivateVariables = len((*privateEnv)[name])
+			}
+			entries = append(entries, entry)
+		}
+		return outputJSON(entries)
+	}
+
 	fmt.Println("Available environments:")
 	for _, name := range names {
 		// Count variables
@@ -171,7 +204,20 @@ func executeEnvList(envFile string, privateEnvFile string) error {
 	return nil
 }
 
-func executeEnvShow(envName string, envFile string, privateEnvFile string, showPrivate bool) error {
+// envShowOutput is the structured form of 'env show' used for json/yaml output
+type envShowOutput struct {
+	Environment       string                  `json:"environment"`
+	PublicVariables   environment.Environment `json:"public_variables,omitempty"`
+	PrivateVariables  environment.Environment `json:"private_variables,omitempty"`
+	PrivateCount      int                     `json:"private_count"`
+	ResolvedVariables int                     `json:"resolved_variables"`
+}
+
+func executeEnvShow(envName string, envFile string, privateEnvFile string, showPrivate bool, format string) error {
+	if format != "text" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
 	// Get working directory
 	workingDir := "."
 	if abs, err := filepath.Abs("."); err == nil {
@@ -215,6 +261,29 @@ func executeEnvShow(envName string, envFile string, privateEnvFile string, showP
 		return fmt.Errorf("environment '%s' not found", envName)
 	}
 
+	if format != "text" {
+		resolver := environment.NewResolver()
+		resolved, err := resolver.Resolve(*publicEnv, *privateEnv, envName)
+		if err != nil {
+			return fmt.Errorf("failed to resolve variables: %w", err)
+		}
+
+		result := envShowOutput{
+			Environment:       envName,
+			PublicVariables:   publicVars,
+			PrivateCount:      len(privateVars),
+			ResolvedVariables: len(resolved.Variables),
+		}
+		if showPrivate {
+			result.PrivateVariables = privateVars
+		}
+
+		if format == "yaml" {
+			return outputYAML(result)
+		}
+		return outputJSON(result)
+	}
+
 	fmt.Printf("Environment: %s\n\n", envName)
 
 	// Display public variables
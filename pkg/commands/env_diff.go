@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"postie/pkg/cli"
+	"postie/pkg/environment"
+	"postie/pkg/redact"
+)
+
+func envDiffCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "diff",
+		Description: "Compare the variables of two environments",
+		Action: func(args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("two environment names required\nUsage: postie env diff <environment> <environment> [--env-file file.json] [--show-private]")
+			}
+
+			var envFile, privateEnvFile string
+			var showPrivate bool
+
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			showPrivateFlag := &cli.BoolFlag{Name: "show-private", Value: showPrivate, Usage: "Show the values of private variables"}
+
+			_, err := cli.ParseFlags(args[2:], []*cli.StringFlag{envFileFlag, privateEnvFileFlag}, []*cli.BoolFlag{showPrivateFlag})
+			if err != nil {
+				return err
+			}
+
+			envFile = envFileFlag.Value
+			if envFile == "" {
+				envFile = "http-client.env.json"
+			}
+			privateEnvFile = privateEnvFileFlag.Value
+			if privateEnvFile == "" {
+				privateEnvFile = "http-client.private.env.json"
+			}
+
+			return executeEnvDiff(args[0], args[1], envFile, privateEnvFile, showPrivateFlag.Value)
+		},
+	}
+}
+
+// loadEnvironmentPair loads the public and private environment files
+func loadEnvironmentPair(envFile, privateEnvFile string) (environment.EnvironmentFile, environment.EnvironmentFile, error) {
+	workingDir := "."
+	if abs, err := filepath.Abs("."); err == nil {
+		workingDir = abs
+	}
+
+	publicEnv, privateEnv, err := environment.NewLoader(workingDir).LoadEnvironments(&environment.EnvironmentConfig{
+		PublicFile:  envFile,
+		PrivateFile: privateEnvFile,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load environments: %w", err)
+	}
+	return *publicEnv, *privateEnv, nil
+}
+
+func executeEnvDiff(env1, env2, envFile, privateEnvFile string, showPrivate bool) error {
+	publicEnv, privateEnv, err := loadEnvironmentPair(envFile, privateEnvFile)
+	if err != nil {
+		return err
+	}
+
+	diff, err := environment.NewMerger().DiffEnvironments(publicEnv, privateEnv, env1, env2)
+	if err != nil {
+		return err
+	}
+
+	if !showPrivate {
+		// Mask values that come from a private file in either environment
+		resolver := environment.NewResolver()
+		resolved1, err := resolver.Resolve(publicEnv, privateEnv, env1)
+		if err != nil {
+			return err
+		}
+		resolved2, err := resolver.Resolve(publicEnv, privateEnv, env2)
+		if err != nil {
+			return err
+		}
+		for i := range diff.Different {
+			entry := &diff.Different[i]
+			if resolved1.IsSecret(entry.Name) {
+				entry.Value1 = redact.Mask
+			}
+			if resolved2.IsSecret(entry.Name) {
+				entry.Value2 = redact.Mask
+			}
+		}
+	}
+
+	if cli.IsJSONOutput() {
+		return outputJSON(diff)
+	}
+
+	palette := cli.Palette()
+	fmt.Printf("Comparing %s with %s\n", env1, env2)
+
+	if len(diff.OnlyIn1) > 0 {
+		fmt.Printf("\nOnly in %s:\n", env1)
+		for _, name := range diff.OnlyIn1 {
+			fmt.Printf("  %s\n", palette.Removed("- "+name))
+		}
+	}
+	if len(diff.OnlyIn2) > 0 {
+		fmt.Printf("\nOnly in %s:\n", env2)
+		for _, name := range diff.OnlyIn2 {
+			fmt.Printf("  %s\n", palette.Added("+ "+name))
+		}
+	}
+	if len(diff.Different) > 0 {
+		fmt.Println("\nDifferent:")
+		for _, entry := range diff.Different {
+			fmt.Printf("  %s\n", palette.Changed("~ "+entry.Name))
+			fmt.Printf("      %s: %s\n", env1, formatDiffValue(entry.Value1))
+			fmt.Printf("      %s: %s\n", env2, formatDiffValue(entry.Value2))
+		}
+	}
+
+	fmt.Printf("\n%d only in %s, %d only in %s, %d different, %d the same\n",
+		len(diff.OnlyIn1), env1, len(diff.OnlyIn2), env2, len(diff.Different), len(diff.Same))
+	return nil
+}
+
+// formatDiffValue formats a variable value, quoting strings other than the mask
+func formatDiffValue(value interface{}) string {
+	if value == redact.Mask {
+		return redact.Mask
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/cli"
+	"postie/pkg/environment"
+)
+
+func envInitCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "init",
+		Description: "Create environment files for the variables used in .http files",
+		Action: func(args []string) error {
+			var envNames, envFile, privateEnvFile string
+			var dryRun, backup bool
+
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: envNames, Usage: "Comma-separated environments to create (default: development)", Required: false}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			dryRunFlag := &cli.BoolFlag{Name: "dry-run", Value: dryRun, Usage: "Show the variables that would be added without writing files"}
+			backupFlag := &cli.BoolFlag{Name: "backup", Value: backup, Usage: "Keep a timestamped copy of each file before changing it"}
+
+			// Paths come before the flags, or after them
+			var paths []string
+			for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				paths = append(paths, args[0])
+				args = args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{envFlag, envFileFlag, privateEnvFileFlag}, []*cli.BoolFlag{dryRunFlag, backupFlag})
+			if err != nil {
+				return err
+			}
+			paths = append(paths, fs.Args()...)
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+
+			envNames = envFlag.Value
+			if envNames == "" {
+				envNames = "development"
+			}
+			envFile = envFileFlag.Value
+			if envFile == "" {
+				envFile = "http-client.env.json"
+			}
+			privateEnvFile = privateEnvFileFlag.Value
+			if privateEnvFile == "" {
+				privateEnvFile = "http-client.private.env.json"
+			}
+
+			var environments []string
+			for _, name := range strings.Split(envNames, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					environments = append(environments, name)
+				}
+			}
+
+			return executeEnvInit(paths, environments, envFile, privateEnvFile, dryRunFlag.Value, backupFlag.Value)
+		},
+	}
+}
+
+func executeEnvInit(paths, environments []string, envFile, privateEnvFile string, dryRun, backup bool) error {
+	variables, err := externalVariables(paths)
+	if err != nil {
+		return err
+	}
+	if len(variables) == 0 {
+		fmt.Println("No environment variables are referenced.")
+		return nil
+	}
+
+	// Keep other postie processes from changing the files between reading
+	// and writing them
+	if !dryRun {
+		for _, path := range []string{envFile, privateEnvFile} {
+			unlock, err := atomicfile.Lock(path)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+	}
+
+	publicEnv, privateEnv, err := loadEnvironmentPair(envFile, privateEnvFile)
+	if err != nil {
+		return err
+	}
+
+	var publicAdded, privateAdded []string
+	publicVariables, privateVariables := map[string][]string{}, map[string][]string{}
+	for _, env := range environments {
+		for _, name := range variables {
+			if definedIn(publicEnv, env, name) || definedIn(privateEnv, env, name) {
+				continue
+			}
+
+			target, added := publicVariables, &publicAdded
+			if secretVariablePattern.MatchString(name) {
+				target, added = privateVariables, &privateAdded
+			}
+			target[env] = append(target[env], name)
+			*added = append(*added, env+"."+name)
+		}
+	}
+
+	if len(publicAdded) == 0 && len(privateAdded) == 0 {
+		fmt.Println("Environment files already define every referenced variable.")
+		return nil
+	}
+
+	for _, file := range []struct {
+		path      string
+		variables map[string][]string // Variables to add, by environment
+		added     []string
+		perm      os.FileMode
+	}{
+		{envFile, publicVariables, publicAdded, 0644},
+		{privateEnvFile, privateVariables, privateAdded, 0600},
+	} {
+		if len(file.added) == 0 {
+			continue
+		}
+
+		verb := "Added"
+		if dryRun {
+			verb = "Would add"
+		}
+		fmt.Printf("%s to %s:\n", verb, file.path)
+		for _, name := range file.added {
+			fmt.Printf("  %s\n", name)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		// The file is edited rather than re-encoded, keeping its order and
+		// any fields postie doesn't use
+		data, err := os.ReadFile(file.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", file.path, err)
+		}
+		for _, env := range environments {
+			if len(file.variables[env]) == 0 {
+				continue
+			}
+			if data, err = environment.AddVariables(data, env, file.variables[env]); err != nil {
+				return fmt.Errorf("failed to update %s: %w", file.path, err)
+			}
+		}
+		if backup {
+			backupPath, err := atomicfile.Backup(file.path)
+			if err != nil {
+				return fmt.Errorf("failed to back up %s: %w", file.path, err)
+			}
+			if backupPath != "" {
+				fmt.Printf("  (previous version saved to %s)\n", backupPath)
+			}
+		}
+		if err := atomicfile.WriteFile(file.path, data, file.perm); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+	}
+
+	if len(privateAdded) > 0 && !dryRun {
+		fmt.Printf("\nKeep %s out of version control, e.g. add it to .gitignore.\n", privateEnvFile)
+	}
+	return nil
+}
+
+// externalVariables returns the variables that .http files reference but
+// don't define themselves, sorted. Directories are searched recursively.
+func externalVariables(paths []string) ([]string, error) {
+	scans, err := scanHTTPFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, scan := range scans {
+		for _, name := range scan.External() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// definedIn reports whether an environment, or the base environment it
+// inherits from, defines a variable
+func definedIn(envs environment.EnvironmentFile, env, name string) bool {
+	if _, ok := envs[env][name]; ok {
+		return true
+	}
+	_, ok := envs[environment.BaseEnvironment][name]
+	return ok
+}
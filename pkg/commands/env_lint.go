@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/environment"
+)
+
+func envLintCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "lint",
+		Description: "Check environment files for invalid values and unresolved references",
+		Action: func(args []string) error {
+			var envFile, privateEnvFile string
+
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{envFileFlag, privateEnvFileFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			envFile = envFileFlag.Value
+			if envFile == "" {
+				envFile = "http-client.env.json"
+			}
+			privateEnvFile = privateEnvFileFlag.Value
+			if privateEnvFile == "" {
+				privateEnvFile = "http-client.private.env.json"
+			}
+
+			return executeEnvLint(envFile, privateEnvFile)
+		},
+	}
+}
+
+// variableReference matches {{variable}} references left after resolution
+var variableReference = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+
+// envLintProblem is a problem found by 'env lint'
+type envLintProblem struct {
+	File        string `json:"file,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Message     string `json:"message"`
+}
+
+func executeEnvLint(envFile, privateEnvFile string) error {
+	publicEnv, privateEnv, err := loadEnvironmentPair(envFile, privateEnvFile)
+	if err != nil {
+		return err
+	}
+
+	problems := lintEnvironments(publicEnv, privateEnv, envFile, privateEnvFile)
+
+	if cli.IsJSONOutput() {
+		if problems == nil {
+			problems = []envLintProblem{}
+		}
+		if err := outputJSON(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, problem := range problems {
+			location := problem.File
+			if problem.Environment != "" {
+				location += ": " + problem.Environment
+			}
+			fmt.Printf("%s: %s\n", location, problem.Message)
+		}
+		if len(problems) == 0 {
+			fmt.Println("No problems found.")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
+
+// lintEnvironments checks the structure of both files, then resolves each
+// environment and reports references that can't be resolved
+func lintEnvironments(publicEnv, privateEnv environment.EnvironmentFile, envFile, privateEnvFile string) []envLintProblem {
+	var problems []envLintProblem
+	loader := environment.NewLoader(".")
+
+	for _, file := range []struct {
+		name string
+		envs environment.EnvironmentFile
+	}{{envFile, publicEnv}, {privateEnvFile, privateEnv}} {
+		if len(file.envs) == 0 {
+			continue
+		}
+		for _, err := range loader.ValidateEnvironmentFile(file.envs) {
+			problems = append(problems, envLintProblem{File: file.name, Message: err.Error()})
+		}
+	}
+
+	names := loader.GetAvailableEnvironments(publicEnv, privateEnv)
+	sort.Strings(names)
+
+	resolver := environment.NewResolver()
+	for _, name := range names {
+		resolved, err := resolver.Resolve(publicEnv, privateEnv, name)
+		if err != nil {
+			problems = append(problems, envLintProblem{File: envFile, Environment: name, Message: err.Error()})
+			continue
+		}
+
+		variables := make([]string, 0, len(resolved.Variables))
+		for variable := range resolved.Variables {
+			variables = append(variables, variable)
+		}
+		sort.Strings(variables)
+
+		for _, variable := range variables {
+			value, ok := resolved.Variables[variable].(string)
+			if !ok {
+				continue
+			}
+			file := envFile
+			if resolved.IsSecret(variable) {
+				file = privateEnvFile
+			}
+			for _, match := range variableReference.FindAllStringSubmatch(value, -1) {
+				problems = append(problems, envLintProblem{
+					File:        file,
+					Environment: name,
+					Message:     fmt.Sprintf("%s references undefined variable {{%s}}", variable, strings.TrimSpace(match[1])),
+				})
+			}
+		}
+	}
+
+	return problems
+}
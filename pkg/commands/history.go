@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/display"
+	"postie/pkg/responses"
+)
+
+// defaultHistoryLimit is the number of requests "postie history" lists
+const defaultHistoryLimit = 20
+
+// HistoryCommands returns the history command for listing and replaying
+// recently executed requests
+func HistoryCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "history",
+		Description: "List recently executed requests saved with --save-responses",
+		Action: func(args []string) error {
+			var limit string
+			limitFlag := &cli.StringFlag{Name: "limit", ShortName: "n", Value: limit, Usage: "Number of requests to list (0 for all, default: 20)", Required: false}
+			requestFlag := &cli.StringFlag{Name: "request", ShortName: "r", Usage: "Only list the responses of this request", Required: false}
+			dirFlag := &cli.StringFlag{Name: "dir", Usage: "Responses directory (default: .http-responses)", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{limitFlag, requestFlag, dirFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			count := defaultHistoryLimit
+			if limitFlag.Value != "" {
+				count, err = strconv.Atoi(limitFlag.Value)
+				if err != nil || count < 0 {
+					return fmt.Errorf("invalid --limit %q", limitFlag.Value)
+				}
+			}
+
+			storage, err := historyStorage(dirFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executeHistoryList(storage, requestFlag.Value, count)
+		},
+		Subcommands: map[string]*cli.Command{
+			"replay": historyReplayCommand(),
+		},
+	}
+}
+
+// historyStorage opens the responses directory given by --dir or the context
+func historyStorage(dir string) (*responses.Storage, error) {
+	ctx, err := context.NewManager().Load()
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir = ctx.ResponsesDir
+	}
+
+	config, err := storageConfig(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return responses.NewStorage(config), nil
+}
+
+func executeHistoryList(storage *responses.Storage, request string, limit int) error {
+	records, err := storage.Recent(request, limit)
+	if err != nil {
+		return err
+	}
+
+	if cli.IsJSONOutput() {
+		if records == nil {
+			records = []responses.HistoryRecord{}
+		}
+		return outputJSON(records)
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No saved requests. Run requests with --save-responses to record them.")
+		return nil
+	}
+
+	for _, record := range records {
+		name := record.RequestName
+		if name == "" {
+			name = "-"
+		}
+		fmt.Printf("%3d  %-19s  %-7s %-40s %-24s %s\n",
+			record.ID,
+			display.Since(record.Timestamp),
+			record.Method,
+			record.RequestURL,
+			record.Status,
+			display.Milliseconds(record.Duration))
+		fmt.Printf("     %s  %s\n", name, record.FilePath)
+	}
+	fmt.Println("\nRe-send one with: postie history replay <id>")
+	return nil
+}
+
+func historyReplayCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "replay",
+		Description: "Re-send a request from the history with its resolved headers and body",
+		Action: func(args []string) error {
+			requestFlag := &cli.StringFlag{Name: "request", ShortName: "r", Usage: "Count IDs among the responses of this request", Required: false}
+			dirFlag := &cli.StringFlag{Name: "dir", Usage: "Responses directory (default: .http-responses)", Required: false}
+			verboseFlag := &cli.BoolFlag{Name: "verbose", ShortName: "v", Usage: "Show request details"}
+			yesIKnowFlag := &cli.BoolFlag{Name: "yes-i-know", Usage: "Send the request even if its host isn't one of the context's safe hosts"}
+
+			// The ID comes before the flags, or after them
+			var id string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				id, args = args[0], args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{requestFlag, dirFlag}, []*cli.BoolFlag{verboseFlag, yesIKnowFlag})
+			if err != nil {
+				return err
+			}
+			if id == "" && fs.NArg() > 0 {
+				id = fs.Arg(0)
+			}
+
+			number, err := strconv.Atoi(id)
+			if err != nil || number < 1 {
+				return fmt.Errorf("history ID is required, e.g. postie history replay 1 (see postie history)")
+			}
+
+			storage, err := historyStorage(dirFlag.Value)
+			if err != nil {
+				return err
+			}
+			records, err := storage.Recent(requestFlag.Value, number)
+			if err != nil {
+				return err
+			}
+			if len(records) < number {
+				return fmt.Errorf("no request with history ID %d", number)
+			}
+
+			hosts, err := loadSafeHosts(yesIKnowFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executeResponsesReplay(records[number-1].FilePath, verboseFlag.Value, hosts)
+		},
+	}
+}
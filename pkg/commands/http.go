@@ -3,27 +3,54 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"postie/pkg/cli"
+	"postie/pkg/client"
 	"postie/pkg/context"
 	"postie/pkg/environment"
 	"postie/pkg/executor"
+	"postie/pkg/fault"
+	"postie/pkg/httpcache"
 	"postie/pkg/httprequest"
+	"postie/pkg/listener"
+	"postie/pkg/logging"
+	"postie/pkg/middleware"
+	"postie/pkg/output"
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+	"postie/pkg/schema"
+	"postie/pkg/scripting"
+	"postie/pkg/selector"
+	"postie/pkg/session"
+	"postie/pkg/telemetry"
 )
 
-// HTTPCommands returns the http command with subcommands for working with .http files
+// HTTPCommands returns the http command with subcommands for working with
+// .http files and sending ad-hoc requests
 func HTTPCommands() *cli.Command {
+	subcommands := map[string]*cli.Command{
+		"run":     httpRunCommand(),
+		"parse":   httpParseCommand(),
+		"check":   httpCheckCommand(),
+		"list":    httpListCommand(),
+		"preview": httpPreviewCommand(),
+		"snippet": httpSnippetCommand(),
+	}
+	for name, command := range adHocCommands() {
+		subcommands[name] = command
+	}
+
 	return &cli.Command{
 		Name:        "http",
-		Description: "Work with HTTP request files (.http)",
-		Subcommands: map[string]*cli.Command{
-			"run":   httpRunCommand(),
-			"parse": httpParseCommand(),
-			"list":  httpListCommand(),
-		},
+		Description: "Work with HTTP request files (.http) and send ad-hoc requests",
+		Subcommands: subcommands,
 	}
 }
 
@@ -39,35 +66,68 @@ func httpRunCommand() *cli.Command {
 				return err
 			}
 
-			// Determine HTTP file - from args or context
+			// Determine HTTP files - from args or context
 			var httpFile string
-			var parseArgs []string
+			var httpFiles []string
+			parseArgs := args
 
-			// Check if first arg is a flag or a file
-			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
-				// First arg is the HTTP file
-				httpFile = args[0]
-				parseArgs = args[1:]
+			// Files and patterns come before the flags
+			for len(parseArgs) > 0 && !strings.HasPrefix(parseArgs[0], "-") {
+				httpFiles = append(httpFiles, parseArgs[0])
+				parseArgs = parseArgs[1:]
+			}
+			if len(httpFiles) > 0 {
+				httpFile = httpFiles[0]
 			} else if ctx.HTTPFile != "" {
 				// Use context default
 				httpFile = ctx.HTTPFile
-				parseArgs = args
 			} else {
-				return fmt.Errorf("HTTP request file required\nUsage: postie http run <file.http> [--env development] [--request name_or_number]\nOr use 'postie context set --http-file <file>' to set a default")
+				return fmt.Errorf("HTTP request file required\nUsage: postie http run <file.http>... [--env development] [--request name_or_number]\nOr use 'postie context set --http-file <file>' to set a default")
 			}
 
-			var env, envFile, privateEnvFile, requestFilter, responsesDir string
-			var verbose, saveResponses bool
+			var env, envFile, privateEnvFile, requestFilter, responsesDir, scriptTimeout, rateLimitWait, faults, listen, tunnel string
+			var otlpEndpoint, metricsAddr, metricsPush, correlationHeaders, vars, dotenvFile, openapiSpec, seed, maxConns, resolve, selectExpr string
+			var verbose, saveResponses, showSecrets, watch, changedOnly, correlation, promptMissing, strictVars, softFail, noKeepAlive, bodyOnly, include, useCache, clearCache, compare bool
 
-			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: env, Usage: "Environment to use", Required: false}
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: env, Usage: "Environment to use; give several with --compare", Required: false, Multiple: true}
 			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
 			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
 			requestFlag := &cli.StringFlag{Name: "request", ShortName: "r", Value: requestFilter, Usage: "Specific request name or number to run", Required: false}
+			selectFlag := &cli.StringFlag{Name: "select", Value: selectExpr, Usage: "Run the requests matching an expression, e.g. 'method==POST && tag in (smoke)'", Required: false}
 			responsesDirFlag := &cli.StringFlag{Name: "responses-dir", Value: responsesDir, Usage: "Directory to save responses", Required: false}
+			scriptTimeoutFlag := &cli.StringFlag{Name: "script-timeout", Value: scriptTimeout, Usage: "Time limit for each response handler, e.g. 5s (0 for none)", Required: false}
 			verboseFlag := &cli.BoolFlag{Name: "verbose", ShortName: "v", Value: verbose, Usage: "Verbose output"}
 			saveResponsesFlag := &cli.BoolFlag{Name: "save-responses", ShortName: "s", Value: saveResponses, Usage: "Save responses to files"}
-
-			_, err = cli.ParseFlags(parseArgs, []*cli.StringFlag{envFlag, envFileFlag, privateEnvFileFlag, requestFlag, responsesDirFlag}, []*cli.BoolFlag{verboseFlag, saveResponsesFlag})
+			showSecretsFlag := &cli.BoolFlag{Name: "show-secrets", Value: showSecrets, Usage: "Don't mask private environment values in output"}
+			watchFlag := &cli.BoolFlag{Name: "watch", ShortName: "w", Value: watch, Usage: "Re-run when the .http, body or environment files change"}
+			changedOnlyFlag := &cli.BoolFlag{Name: "changed-only", Value: changedOnly, Usage: "In watch mode, only re-run requests that changed"}
+			otlpEndpointFlag := &cli.StringFlag{Name: "otlp-endpoint", Value: otlpEndpoint, Usage: "Export request spans to an OTLP/HTTP endpoint, e.g. http://localhost:4318", Required: false}
+			metricsAddrFlag := &cli.StringFlag{Name: "metrics-addr", Value: metricsAddr, Usage: "Serve Prometheus metrics on this address, e.g. :9464", Required: false}
+			metricsPushFlag := &cli.StringFlag{Name: "metrics-push", Value: metricsPush, Usage: "Push Prometheus metrics to this Pushgateway after each run", Required: false}
+			dotenvFlag := &cli.StringFlag{Name: "dotenv", Value: dotenvFile, Usage: "Load variables from this .env file (default: .env if present)", Required: false}
+			varFlag := &cli.StringFlag{Name: "var", Value: vars, Usage: "Set a variable as name=value, overriding every other source (repeatable)", Required: false, Multiple: true}
+			promptMissingFlag := &cli.BoolFlag{Name: "prompt-missing", Value: promptMissing, Usage: "Ask for the value of undefined variables"}
+			strictVarsFlag := &cli.BoolFlag{Name: "strict-vars", Value: strictVars, Usage: "Fail requests that use undefined variables"}
+			softFailFlag := &cli.BoolFlag{Name: "soft-fail", Value: softFail, Usage: "Exit with status 0 even if requests fail"}
+			bodyOnlyFlag := &cli.BoolFlag{Name: "body-only", Value: bodyOnly, Usage: "Write only the response bodies, as received, for piping to other tools"}
+			includeFlag := &cli.BoolFlag{Name: "include", ShortName: "i", Value: include, Usage: "Like --body-only, with the status line and headers before each body"}
+			correlationFlag := &cli.BoolFlag{Name: "correlation", Value: correlation, Usage: "Send X-Request-Id and traceparent headers and show them with each result"}
+			correlationHeadersFlag := &cli.StringFlag{Name: "correlation-headers", Value: correlationHeaders, Usage: "Comma-separated correlation headers to send (implies --correlation)", Required: false}
+			openapiFlag := &cli.StringFlag{Name: "openapi", Value: openapiSpec, Usage: "Check responses against the matching operations of this OpenAPI spec (JSON)", Required: false}
+			maxConnsFlag := &cli.StringFlag{Name: "max-conns-per-host", Value: maxConns, Usage: "Limit the connections open to each host (default: no limit)", Required: false}
+			resolveFlag := &cli.StringFlag{Name: "resolve", Value: resolve, Usage: "Connect to addr for host:port, as host:port:addr (repeatable)", Required: false, Multiple: true}
+			noKeepAliveFlag := &cli.BoolFlag{Name: "no-keep-alive", Value: noKeepAlive, Usage: "Open a new connection for every request"}
+			cacheFlag := &cli.BoolFlag{Name: "cache", Value: useCache, Usage: "Cache GET responses between runs and revalidate them with conditional requests"}
+			clearCacheFlag := &cli.BoolFlag{Name: "clear-cache", Value: clearCache, Usage: "Empty the response cache before running (implies --cache)"}
+			compareFlag := &cli.BoolFlag{Name: "compare", Value: compare, Usage: "Run the requests in every --env at once and compare their statuses, durations and bodies"}
+			rateLimitWaitFlag := &cli.StringFlag{Name: "rate-limit-wait", Value: rateLimitWait, Usage: "When a host's rate limit runs out, wait up to this long for it to reset, e.g. 2m", Required: false}
+			faultFlag := &cli.StringFlag{Name: "fault", Value: faults, Usage: "Inject faults: delay=2s, drop=10%, error=20%[:502], seed=42 (repeatable)", Required: false, Multiple: true}
+			listenFlag := &cli.StringFlag{Name: "listen", Value: listen, Usage: "Capture webhook callbacks on this port during the run, for client.listener in scripts", Required: false}
+			tunnelFlag := &cli.StringFlag{Name: "tunnel", Value: tunnel, Usage: "Expose the listener publicly as {{listener.url}}: ngrok, ssh:<host> or a command with {port}", Required: false}
+			yesIKnowFlag := &cli.BoolFlag{Name: "yes-i-know", Usage: "Send POST, PUT, PATCH and DELETE requests to hosts that aren't in the context's safe hosts"}
+			seedFlag := &cli.StringFlag{Name: "seed", Value: seed, Usage: "Seed for {{$faker...}} variables, to send the same data on every run", Required: false}
+
+			flagSet, err := cli.ParseFlags(parseArgs, []*cli.StringFlag{envFlag, envFileFlag, privateEnvFileFlag, requestFlag, selectFlag, responsesDirFlag, scriptTimeoutFlag, otlpEndpointFlag, metricsAddrFlag, metricsPushFlag, correlationHeadersFlag, varFlag, dotenvFlag, openapiFlag, seedFlag, maxConnsFlag, resolveFlag, rateLimitWaitFlag, faultFlag, listenFlag, tunnelFlag}, []*cli.BoolFlag{verboseFlag, saveResponsesFlag, showSecretsFlag, watchFlag, changedOnlyFlag, correlationFlag, promptMissingFlag, strictVarsFlag, softFailFlag, noKeepAliveFlag, bodyOnlyFlag, includeFlag, cacheFlag, clearCacheFlag, compareFlag, yesIKnowFlag})
 			if err != nil {
 				return err
 			}
@@ -77,9 +137,99 @@ func httpRunCommand() *cli.Command {
 			envFile = envFileFlag.Value
 			privateEnvFile = privateEnvFileFlag.Value
 			requestFilter = requestFlag.Value
+			selectExpr = selectFlag.Value
 			responsesDir = responsesDirFlag.Value
+			scriptTimeout = scriptTimeoutFlag.Value
+			rateLimitWait = rateLimitWaitFlag.Value
 			verbose = verboseFlag.Value
 			saveResponses = saveResponsesFlag.Value
+			showSecrets = showSecretsFlag.Value
+			watch = watchFlag.Value
+			changedOnly = changedOnlyFlag.Value
+			otlpEndpoint = otlpEndpointFlag.Value
+			if otlpEndpoint == "" {
+				otlpEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+			}
+			metricsAddr = metricsAddrFlag.Value
+			metricsPush = metricsPushFlag.Value
+			correlation = correlationFlag.Value
+			promptMissing = promptMissingFlag.Value
+			strictVars = strictVarsFlag.Value
+			softFail = softFailFlag.Value
+			openapiSpec = openapiFlag.Value
+			seed = seedFlag.Value
+			maxConns = maxConnsFlag.Value
+			noKeepAlive = noKeepAliveFlag.Value
+			bodyOnly = bodyOnlyFlag.Value
+			include = includeFlag.Value
+			clearCache = clearCacheFlag.Value
+			useCache = cacheFlag.Value || clearCache
+			compare = compareFlag.Value
+
+			var compareEnvs []string
+			if compare {
+				compareEnvs = envFlag.Values
+			}
+			if compare && len(compareEnvs) < 2 {
+				return fmt.Errorf("--compare needs two or more environments, e.g. --env dev --env staging --compare")
+			}
+			if !compare && len(envFlag.Values) > 1 {
+				return fmt.Errorf("--env was given %d times; add --compare to run in several environments", len(envFlag.Values))
+			}
+			if compare && (watch || bodyOnly || include) {
+				return fmt.Errorf("--compare can't be combined with --watch, --body-only or --include")
+			}
+
+			var fakerSeed int64
+			if seed != "" {
+				fakerSeed, err = strconv.ParseInt(seed, 10, 64)
+				if err != nil || fakerSeed == 0 {
+					return fmt.Errorf("invalid --seed %q (use a non-zero integer)", seed)
+				}
+			}
+
+			transport := client.TransportConfig{DisableKeepAlives: noKeepAlive}
+			if maxConns != "" {
+				transport.MaxConnsPerHost, err = strconv.Atoi(maxConns)
+				if err != nil || transport.MaxConnsPerHost < 1 {
+					return fmt.Errorf("invalid --max-conns-per-host %q (use a positive number)", maxConns)
+				}
+			}
+			for _, value := range resolveFlag.Values {
+				hostPort, addr, err := client.ParseResolve(value)
+				if err != nil {
+					return fmt.Errorf("--resolve: %w", err)
+				}
+				if transport.Resolve == nil {
+					transport.Resolve = make(map[string]string)
+				}
+				transport.Resolve[hostPort] = addr
+			}
+
+			variables, err := parseVarFlags(varFlag.Values)
+			if err != nil {
+				return err
+			}
+
+			dotenvFile = dotenvFlag.Value
+			if dotenvFile != "" {
+				if _, err := os.Stat(dotenvFile); err != nil {
+					return fmt.Errorf("dotenv file: %w", err)
+				}
+			} else {
+				dotenvFile = environment.DefaultDotEnvFile
+			}
+			correlationHeaders = correlationHeadersFlag.Value
+
+			var correlationNames []string
+			for _, name := range strings.Split(correlationHeaders, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					correlationNames = append(correlationNames, name)
+				}
+			}
+			if correlation && len(correlationNames) == 0 {
+				correlationNames = middleware.DefaultCorrelationHeaders
+			}
 
 			// Merge context defaults with flags (flags take precedence)
 			context.MergeWithFlags(ctx, &httpFile, &env, &envFile, &privateEnvFile, &responsesDir, &saveResponses)
@@ -88,17 +238,115 @@ func httpRunCommand() *cli.Command {
 			if env == "" {
 				env = "development"
 			}
-			if envFile == "" {
-				envFile = "http-client.env.json"
+			discoverEnvFiles := envFile == "" && privateEnvFile == ""
+
+			var scriptTimeoutDuration time.Duration
+			if scriptTimeout != "" {
+				scriptTimeoutDuration, err = time.ParseDuration(scriptTimeout)
+				if err != nil || scriptTimeoutDuration < 0 {
+					return fmt.Errorf("invalid --script-timeout %q (use a duration such as 5s)", scriptTimeout)
+				}
+				if scriptTimeoutDuration == 0 {
+					scriptTimeoutDuration = -1 // no limit
+				}
 			}
-			if privateEnvFile == "" {
-				privateEnvFile = "http-client.private.env.json"
+
+			var rateLimitWaitDuration time.Duration
+			if rateLimitWait != "" {
+				rateLimitWaitDuration, err = time.ParseDuration(rateLimitWait)
+				if err != nil || rateLimitWaitDuration < 0 {
+					return fmt.Errorf("invalid --rate-limit-wait %q (use a duration such as 2m)", rateLimitWait)
+				}
 			}
 
-			// Note: responsesDir is merged from context but not yet used in executeHttpFileRun
-			// This is for future enhancement when custom response directories are supported
+			var listenAddress string
+			if listenFlag.Value != "" {
+				if listenAddress, err = listenAddr(listenFlag.Value); err != nil {
+					return fmt.Errorf("--listen: %w", err)
+				}
+			} else if tunnelFlag.Value != "" {
+				// A tunnel needs a listener; any free port will do
+				listenAddress = "127.0.0.1:0"
+			}
 
-			return executeHttpFileRun(httpFile, env, envFile, privateEnvFile, requestFilter, verbose, saveResponses)
+			var faultConfig fault.Config
+			for _, spec := range faultFlag.Values {
+				if err := faultConfig.Parse(spec); err != nil {
+					return fmt.Errorf("--fault: %w", err)
+				}
+			}
+
+			var selection *selector.Selector
+			if selectExpr != "" {
+				if selection, err = executor.ParseSelector(selectExpr); err != nil {
+					return err
+				}
+			}
+
+			storage, err := storageConfig(ctx, responsesDir)
+			if err != nil {
+				return err
+			}
+
+			// Files given after the flags, and files matching patterns
+			httpFiles = append(httpFiles, flagSet.Args()...)
+			if len(httpFiles) == 0 {
+				httpFiles = []string{httpFile}
+			}
+			files, err := expandHttpFiles(httpFiles)
+			if err != nil {
+				return err
+			}
+			httpFile = files[0]
+			defaultEnvFiles(httpFile, &envFile, &privateEnvFile)
+			if compare && len(files) > 1 {
+				return fmt.Errorf("--compare runs a single file")
+			}
+
+			if clearCache {
+				if err := httpcache.NewStore(".").Clear(); err != nil {
+					return err
+				}
+			}
+
+			return executeHttpFileRun(&httpRunOptions{
+				File:             httpFile,
+				Files:            files,
+				Env:              env,
+				EnvFile:          envFile,
+				PrivateEnvFile:   privateEnvFile,
+				DiscoverEnvFiles: discoverEnvFiles,
+				Request:          requestFilter,
+				Select:           selection,
+				Verbose:          verbose,
+				SaveResponses:    saveResponses,
+				Storage:          storage,
+				ShowSecrets:      showSecrets,
+				ScriptTimeout:    scriptTimeoutDuration,
+				Watch:            watch,
+				ChangedOnly:      changedOnly,
+				OTLPEndpoint:     otlpEndpoint,
+				MetricsAddr:      metricsAddr,
+				MetricsPush:      metricsPush,
+				Correlation:      correlationNames,
+				Variables:        variables,
+				DotEnvFile:       dotenvFile,
+				PromptMissing:    promptMissing,
+				StrictVars:       strictVars,
+				OpenAPI:          openapiSpec,
+				FakerSeed:        fakerSeed,
+				SoftFail:         softFail,
+				Transport:        transport,
+				BodyOnly:         bodyOnly,
+				Include:          include,
+				Cache:            useCache,
+				CompareEnvs:      compareEnvs,
+				RateLimitWait:    rateLimitWaitDuration,
+				Faults:           faultConfig,
+				Listen:           listenAddress,
+				Tunnel:           tunnelFlag.Value,
+				SafeHosts:        safeHosts(ctx, yesIKnowFlag.Value),
+			})
 		},
 	}
 }
@@ -109,16 +357,17 @@ func httpParseCommand() *cli.Command {
 		Description: "Parse and validate HTTP request file",
 		Action: func(args []string) error {
 			if len(args) == 0 {
-				return fmt.Errorf("HTTP request file required\nUsage: postie http parse <file.http> [--format summary]")
+				return fmt.Errorf("HTTP request file required\nUsage: postie http parse <file.http> [--format summary|json|yaml] [--validate] [--rules rule=off|warn|error,...]")
 			}
 
-			var format string
+			var format, rules string
 			var validate bool
 
-			formatFlag := &cli.StringFlag{Name: "format", ShortName: "f", Value: format, Usage: "Output format (json, summary)", Required: false}
+			formatFlag := &cli.StringFlag{Name: "format", ShortName: "f", Value: format, Usage: "Output format (summary, json, yaml)", Required: false}
+			rulesFlag := &cli.StringFlag{Name: "rules", Value: rules, Usage: "Validation rule overrides (rule=off|warn|error, comma separated)", Required: false}
 			validateFlag := &cli.BoolFlag{Name: "validate", Value: validate, Usage: "Perform validation"}
 
-			_, err := cli.ParseFlags(args[1:], []*cli.StringFlag{formatFlag}, []*cli.BoolFlag{validateFlag})
+			_, err := cli.ParseFlags(args[1:], []*cli.StringFlag{formatFlag, rulesFlag}, []*cli.BoolFlag{validateFlag})
 			if err != nil {
 				return err
 			}
@@ -127,9 +376,79 @@ func httpParseCommand() *cli.Command {
 			if format == "" {
 				format = "summary"
 			}
+			rules = rulesFlag.Value
 			validate = validateFlag.Value
 
-			return executeHttpFileParse(args[0], format, validate)
+			var validationConfig *httprequest.ValidationConfig
+			if validate {
+				validationConfig, err = loadValidationConfig(rules)
+				if err != nil {
+					return err
+				}
+			}
+
+			return executeHttpFileParse(args[0], format, validationConfig)
+		},
+	}
+}
+
+func httpCheckCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "check",
+		Description: "Report diagnostics for HTTP request file",
+		Action: func(args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("HTTP request file required\nUsage: postie http check <file.http> [--format text|json] [--env name]")
+			}
+
+			var format, env, envFile, privateEnvFile, rules string
+			formatFlag := &cli.StringFlag{Name: "format", ShortName: "f", Value: format, Usage: "Output format (text, json)", Required: false}
+			rulesFlag := &cli.StringFlag{Name: "rules", Value: rules, Usage: "Validation rule overrides (rule=off|warn|error, comma separated)", Required: false}
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: env, Usage: "Expand variables from this environment before validating", Required: false}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+
+			_, err := cli.ParseFlags(args[1:], []*cli.StringFlag{formatFlag, envFlag, envFileFlag, privateEnvFileFlag, rulesFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			format = formatFlag.Value
+			env = envFlag.Value
+			envFile = envFileFlag.Value
+			privateEnvFile = privateEnvFileFlag.Value
+			rules = rulesFlag.Value
+			if format == "" {
+				format = "text"
+				if cli.IsJSONOutput() {
+					format = "json"
+				}
+			}
+
+			// Without --env, variables are left unexpanded
+			var resolvedEnv *environment.ResolvedEnvironment
+			if env != "" {
+				defaultEnvFiles(args[0], &envFile, &privateEnvFile)
+				resolvedEnv, err = loadEnvironmentFiles(&environment.EnvironmentConfig{
+					PublicFile:  envFile,
+					PrivateFile: privateEnvFile,
+					Environment: env,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to load environment: %w", err)
+				}
+			}
+
+			validationConfig, err := loadValidationConfig(rules)
+			if err != nil {
+				return err
+			}
+
+			return executeHttpFileCheck(args[0], format, httprequest.CheckOptions{
+				WorkingDir:  filepath.Dir(args[0]),
+				Environment: resolvedEnv,
+				Rules:       validationConfig,
+			})
 		},
 	}
 }
@@ -165,98 +484,553 @@ func httpListCommand() *cli.Command {
 
 // Execute functions
 
-func executeHttpFileRun(filePath string, envName string, envFile string, privateEnvFile string, requestName string, verbose bool, saveResponses bool) error {
+// httpRunOptions holds the settings for "http run"
+type httpRunOptions struct {
+	File             string
+	Files            []string // Every file to run, when patterns or several files were given
+	Env              string
+	EnvFile          string
+	PrivateEnvFile   string
+	DiscoverEnvFiles bool               // EnvFile and PrivateEnvFile weren't given, so each file uses its own
+	Request          string             // Request name or number filter
+	Select           *selector.Selector // --select expression, or nil
+	Verbose          bool
+	SaveResponses    bool
+	Storage          *responses.StorageConfig // Where and how long responses are saved
+	ShowSecrets      bool
+	ScriptTimeout    time.Duration // 0 for the default, negative for no limit
+	Watch            bool
+	ChangedOnly      bool                   // In watch mode, re-run only requests that changed
+	OTLPEndpoint     string                 // Export spans to this OTLP/HTTP endpoint
+	MetricsAddr      string                 // Serve Prometheus metrics on this address
+	MetricsPush      string                 // Push Prometheus metrics to this Pushgateway
+	Correlation      []string               // Correlation headers to send with each request
+	Variables        map[string]string      // --var overrides
+	DotEnvFile       string                 // .env file to load variables from
+	PromptMissing    bool                   // Ask for the values of undefined variables
+	StrictVars       bool                   // Fail requests that use undefined variables
+	OpenAPI          string                 // OpenAPI spec to check responses against
+	FakerSeed        int64                  // Seed for {{$faker...}} variables (0 for random)
+	SoftFail         bool                   // Exit with status 0 even if requests fail
+	Transport        client.TransportConfig // Connection pooling settings
+	Cache            bool                   // Cache responses in .postie/cache
+	BodyOnly         bool                   // Write only the response bodies
+	Include          bool                   // Write the status line and headers before each body
+	CompareEnvs      []string               // Environments to run in at once and compare, with --compare
+	RateLimitWait    time.Duration          // Wait up to this long for an exhausted rate limit to reset
+	Faults           fault.Config           // Faults to inject with --fault
+	Listen           string                 // Address of the webhook listener, with --listen
+	Tunnel           string                 // Tunnel client that exposes the listener, with --tunnel
+	SafeHosts        []string               // Hosts POST, PUT, PATCH and DELETE may be sent to (nil for any)
+
+	telemetry  *telemetry.Telemetry       // Shared by the runs of a watch session
+	rateLimits *executor.RateLimitTracker // Shared by the files and runs of an environment
+	faults     *fault.Injector            // Shared by the files and runs, nil without --fault
+	listener   *listener.Listener         // Shared by the files and runs, nil without --listen
+}
+
+func executeHttpFileRun(opts *httpRunOptions) error {
+	// --verbose also enables progress logging unless a more detailed level was chosen
+	if opts.Verbose && logging.GetLevel() > logging.LevelVerbose {
+		logging.SetLevel(logging.LevelVerbose)
+	}
+
+	if opts.Faults.Enabled() {
+		opts.faults = fault.NewInjector(opts.Faults)
+		logging.Warn("injecting faults into requests", "faults", opts.Faults.String())
+	}
+
+	if opts.Listen != "" {
+		l, err := listener.Start(opts.Listen, 0)
+		if err != nil {
+			return cli.Exit(cli.ExitConfig, err)
+		}
+		defer l.Close()
+		if opts.Tunnel != "" {
+			tunnel, err := listener.StartTunnel(opts.Tunnel, l.Port(), listener.DefaultTunnelTimeout)
+			if err != nil {
+				return cli.Exit(cli.ExitConfig, fmt.Errorf("--tunnel: %w", err))
+			}
+			defer tunnel.Close()
+			l.SetPublicURL(tunnel.URL)
+		}
+		opts.listener = l
+		logging.Verbose("listening for callbacks", "url", l.URL())
+	}
+
+	if len(opts.CompareEnvs) > 0 {
+		return runEnvironmentComparison(opts)
+	}
+
+	if err := setupTelemetry(opts); err != nil {
+		return err
+	}
+	opts.rateLimits = executor.NewRateLimitTracker(opts.RateLimitWait)
+
+	if len(opts.Files) > 1 {
+		if opts.Watch {
+			return fmt.Errorf("--watch runs a single file")
+		}
+		return runHttpFiles(opts)
+	}
+
+	if opts.Watch {
+		return watchHttpFileRun(opts)
+	}
+
+	return runHttpFile(opts, nil)
+}
+
+// setupTelemetry enables tracing and metrics if they were requested
+func setupTelemetry(opts *httpRunOptions) error {
+	if opts.OTLPEndpoint == "" && opts.MetricsAddr == "" && opts.MetricsPush == "" {
+		return nil
+	}
+
+	var tracer *telemetry.Tracer
+	if opts.OTLPEndpoint != "" {
+		tracer = telemetry.NewTracer(opts.OTLPEndpoint)
+	}
+
+	var metrics *telemetry.Metrics
+	if opts.MetricsAddr != "" || opts.MetricsPush != "" {
+		metrics = telemetry.NewMetrics()
+	}
+
+	if opts.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", opts.MetricsAddr)
+		if err != nil {
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go http.Serve(listener, mux)
+		logging.Verbose("serving metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+	}
+
+	opts.telemetry = telemetry.New(tracer, metrics)
+	return nil
+}
+
+// fileRun holds the results of running the requests of one .http file
+type fileRun struct {
+	file      string
+	results   []*executor.ExecutionResult
+	formatter *executor.Formatter
+	redactor  *redact.Redactor
+}
+
+// runHttpFile executes the requests in opts.File and prints the results.
+// If only is non-nil, just the requests at those indexes are run.
+func runHttpFile(opts *httpRunOptions, only map[int]bool) error {
+	run, err := executeHttpFile(opts, only)
+	if err != nil {
+		return err
+	}
+	if len(run.results) == 0 {
+		return fmt.Errorf("no requests executed")
+	}
+	return printRuns(opts, []*fileRun{run})
+}
+
+// executeHttpFile executes the requests in opts.File
+func executeHttpFile(opts *httpRunOptions, only map[int]bool) (*fileRun, error) {
 	// Load environment files
-	resolvedEnv, err := loadEnvironmentFiles(envName, envFile, privateEnvFile)
+	resolvedEnv, err := loadEnvironmentFiles(&environment.EnvironmentConfig{
+		PublicFile:  opts.EnvFile,
+		PrivateFile: opts.PrivateEnvFile,
+		Environment: opts.Env,
+		DotEnvFile:  opts.DotEnvFile,
+		Variables:   opts.Variables,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to load environment: %w", err)
+		return nil, fmt.Errorf("failed to load environment: %w", err)
 	}
+	logging.Debug("environment loaded", "name", resolvedEnv.Name, "variables", len(resolvedEnv.Variables))
 
-	// Read HTTP file content
-	content, err := os.ReadFile(filePath)
+	// Read and parse the HTTP file
+	requestsFile, err := parseHttpFile(opts.File)
 	if err != nil {
-		return fmt.Errorf("failed to read HTTP file: %w", err)
+		return nil, err
 	}
+	logging.Debug("parsed HTTP file", "file", opts.File, "requests", len(requestsFile.Requests))
 
-	// Parse the HTTP file
-	requestsFile, err := httprequest.ParseFile(filePath, string(content))
+	// client.env variables saved by earlier runs against this environment
+	envStore, err := scripting.LoadEnvStore(".", resolvedEnv.Name)
 	if err != nil {
-		return fmt.Errorf("failed to parse HTTP file: %w", err)
+		return nil, err
+	}
+
+	var spec *schema.Spec
+	if opts.OpenAPI != "" {
+		if spec, err = schema.LoadSpec(opts.OpenAPI); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create executor
 	execConfig := &executor.ExecutorConfig{
-		SaveResponses: saveResponses,
+		SaveResponses: opts.SaveResponses,
+		StorageConfig: opts.Storage,
+		ShowSecrets:   opts.ShowSecrets,
+		ScriptTimeout: opts.ScriptTimeout,
+		FakerSeed:     opts.FakerSeed,
+		Transport:     opts.Transport,
+		EnvStore:      envStore,
+		Sessions:      session.NewStore(".", resolvedEnv.Name),
+
+		CorrelationHeaders: opts.Correlation,
+		StrictVariables:    opts.StrictVars,
+		OpenAPI:            spec,
+		Faults:             opts.faults,
+		Listener:           opts.listener,
+		ScriptHTTPCheck:    middleware.HostSafetyCheck(opts.SafeHosts),
+	}
+	if opts.PromptMissing {
+		execConfig.PromptVariable = promptVariable
+	}
+	if opts.Cache {
+		execConfig.Cache = httpcache.NewStore(".")
 	}
 	exec := executor.NewExecutor(resolvedEnv, execConfig)
-	formatter := executor.NewFormatter(verbose)
+	defer exec.Close()
+
+	// First, so a blocked request isn't delayed or changed by other hooks
+	if len(opts.SafeHosts) > 0 {
+		exec.AddHook(middleware.HostSafetyHook(opts.SafeHosts))
+	}
+	if opts.telemetry != nil {
+		opts.telemetry.SetRedactor(exec.Redactor())
+		exec.AddHook(opts.telemetry)
+		opts.telemetry.StartRun("postie run " + filepath.Base(opts.File))
+		defer finishTelemetry(opts)
+	}
+	if opts.rateLimits != nil {
+		exec.AddHook(opts.rateLimits)
+	}
+	exec.AddHook(middleware.IdempotencyHook())
+	if len(opts.Correlation) > 0 {
+		// After telemetry, so a traced request keeps the traceparent of its span
+		exec.AddHook(middleware.CorrelationHook(opts.Correlation...))
+	}
+	// Last, so auth schemes can sign the headers added above
+	registerPlugins(exec, resolvedEnv.Name)
+	formatter := executor.NewFormatter(opts.Verbose)
+	formatter.SetPalette(cli.Palette())
+	formatter.SetRedactor(exec.Redactor())
+	logging.SetRedactor(exec.Redactor())
 
 	// Execute requests from file
-	results, err := exec.ExecuteFile(requestsFile, requestName)
+	selected, err := exec.SelectRequests(requestsFile, opts.Request)
+	if err == nil && opts.Select != nil {
+		selected, err = executor.SelectMatching(requestsFile, selected, opts.Select)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter requests: %w", err)
+	}
+	if only != nil {
+		var changed []int
+		for _, i := range selected {
+			if only[i] {
+				changed = append(changed, i)
+			}
+		}
+		selected = changed
+	}
+	results, err := exec.ExecuteSelected(requestsFile, selected)
 	if err != nil {
-		return fmt.Errorf("failed to execute requests: %w", err)
+		return nil, fmt.Errorf("failed to execute requests: %w", err)
 	}
 
-	if len(results) == 0 {
-		return fmt.Errorf("no requests executed")
+	return &fileRun{file: opts.File, results: results, formatter: formatter, redactor: exec.Redactor()}, nil
+}
+
+// printRuns prints the results of the files run, with one summary for all
+// of them
+func printRuns(opts *httpRunOptions, runs []*fileRun) error {
+	var results []*executor.ExecutionResult
+	for _, run := range runs {
+		results = append(results, run.results...)
+	}
+
+	if opts.BodyOnly || opts.Include {
+		for _, run := range runs {
+			printRawResults(run.formatter, run.results, opts.Include)
+		}
+		return runError(results, opts.SoftFail)
+	}
+
+	if cli.IsJSONOutput() {
+		reports := make([]*executor.RunReport, len(runs))
+		for i, run := range runs {
+			reports[i] = executor.NewRunReport(run.file, opts.Env, run.results)
+			reports[i].Redact(run.redactor)
+		}
+		report := reports[0]
+		if len(opts.Files) > 1 {
+			report = executor.MergeRunReports(opts.Env, reports)
+		}
+		report.RateLimits = rateLimits(opts)
+		report.Faults = faultCounts(opts)
+		report.Callbacks = callbacks(opts)
+		if err := outputJSON(report); err != nil {
+			return err
+		}
+		return runError(results, opts.SoftFail)
 	}
 
-	// Display results
-	for i, result := range results {
-		fmt.Print(formatter.FormatResult(result, i+1))
+	// Quiet mode only shows the summary
+	formatter := runs[0].formatter
+	if cli.IsQuiet() {
+		fmt.Print(formatter.FormatSummary(results))
+		fmt.Print(formatter.FormatRateLimits(rateLimits(opts)))
+		fmt.Print(formatter.FormatFaults(opts.Faults, faultCounts(opts)))
+		fmt.Print(formatter.FormatCallbacks(callbacks(opts)))
+		return runError(results, opts.SoftFail)
+	}
+
+	// Display results, under the name of their file if there are several
+	for _, run := range runs {
+		if len(opts.Files) > 1 {
+			fmt.Print(run.formatter.FormatFileHeader(run.file))
+		}
+		for i, result := range run.results {
+			fmt.Print(run.formatter.FormatResult(result, i+1))
+		}
 	}
 
 	// Display summary if multiple requests
 	if len(results) > 1 {
 		fmt.Print(formatter.FormatSummary(results))
 	}
+	fmt.Print(formatter.FormatRateLimits(rateLimits(opts)))
+	fmt.Print(formatter.FormatFaults(opts.Faults, faultCounts(opts)))
+	fmt.Print(formatter.FormatCallbacks(callbacks(opts)))
+
+	return runError(results, opts.SoftFail)
+}
+
+// safeHosts returns the hosts the context allows POST, PUT, PATCH and
+// DELETE requests to, or nil if any host is allowed or --yes-i-know was
+// given
+func safeHosts(ctx *context.Context, yesIKnow bool) []string {
+	if len(ctx.SafeHosts) == 0 {
+		return nil
+	}
+	if yesIKnow {
+		logging.Warn("sending requests to hosts outside the safe hosts", "safe_hosts", strings.Join(ctx.SafeHosts, ","))
+		return nil
+	}
+	return ctx.SafeHosts
+}
+
+// loadSafeHosts loads the context of the current directory and returns
+// its safe hosts, as safeHosts does
+func loadSafeHosts(yesIKnow bool) ([]string, error) {
+	ctx, err := context.NewManager().Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context: %w", err)
+	}
+	return safeHosts(ctx, yesIKnow), nil
+}
+
+// rateLimits returns the rate limits the hosts of a run reported
+func rateLimits(opts *httpRunOptions) []executor.RateLimit {
+	if opts.rateLimits == nil {
+		return nil
+	}
+	return opts.rateLimits.Limits()
+}
+
+// faultCounts returns the faults injected into a run, or nil without
+// --fault
+func faultCounts(opts *httpRunOptions) *fault.Counts {
+	if opts.faults == nil {
+		return nil
+	}
+	counts := opts.faults.Counts()
+	return &counts
+}
+
+// callbacks returns the requests the --listen listener received, or nil
+// without one
+func callbacks(opts *httpRunOptions) []listener.Callback {
+	if opts.listener == nil {
+		return nil
+	}
+	return opts.listener.Callbacks()
+}
+
+// runError returns the error that sets the exit code of a run:
+// cli.ExitConfig if a request was invalid, such as one with an unresolved
+// variable or a bad directive, cli.ExitTransport if a request couldn't be
+// sent, or cli.ExitFailed if a request failed its status, tests or
+// assertions or was blocked by one that did. With softFail, failed
+// requests don't fail the run, but invalid ones still do.
+func runError(results []*executor.ExecutionResult, softFail bool) error {
+	invalid, errored, failed := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+		case result.Blocked:
+			failed++
+		case result.HasError() && result.NotSent():
+			invalid++
+		case result.HasError():
+			errored++
+		case !result.Passed():
+			failed++
+		}
+	}
+
+	if invalid > 0 {
+		return cli.Exit(cli.ExitConfig, fmt.Errorf("%d of %d requests are invalid", invalid, len(results)))
+	}
+	if softFail || errored+failed == 0 {
+		return nil
+	}
+	if errored > 0 {
+		return cli.Exit(cli.ExitTransport, fmt.Errorf("%d of %d requests couldn't be sent", errored, len(results)))
+	}
+	return cli.Exit(cli.ExitFailed, fmt.Errorf("%d of %d requests failed", failed, len(results)))
+}
+
+// printRawResults writes the response of each result to standard output
+// with no decoration, for --body-only and --include. Requests that couldn't
+// be sent are reported on standard error.
+func printRawResults(formatter *executor.Formatter, results []*executor.ExecutionResult, includeHeaders bool) {
+	for _, result := range results {
+		if result.Error != nil && result.Response == nil {
+			fmt.Fprintf(os.Stderr, "Error: %s %s: %v\n", result.Request.Method, result.Request.URL.Raw, result.Error)
+			continue
+		}
+		os.Stdout.Write(formatter.FormatRaw(result, includeHeaders))
+	}
+}
+
+// finishTelemetry exports the spans of a run and pushes its metrics
+func finishTelemetry(opts *httpRunOptions) {
+	if err := opts.telemetry.EndRun(); err != nil {
+		logging.Warn("failed to export traces", "error", err)
+	}
+	if opts.MetricsPush != "" {
+		if err := opts.telemetry.Metrics.Push(opts.MetricsPush); err != nil {
+			logging.Warn("failed to push metrics", "error", err)
+		}
+	}
+}
+
+// parseHttpFile reads and parses an HTTP request file
+func parseHttpFile(filePath string) (*httprequest.RequestsFile, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP file: %w", err)
+	}
+
+	requestsFile, err := httprequest.ParseFile(filePath, string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTTP file: %w", err)
+	}
+
+	return requestsFile, nil
+}
+
+func executeHttpFileCheck(httpFile, format string, opts httprequest.CheckOptions) error {
+	content, err := os.ReadFile(httpFile)
+	if err != nil {
+		return fmt.Errorf("failed to read HTTP file: %w", err)
+	}
+
+	report := httprequest.Check(httpFile, string(content), opts)
 
+	switch format {
+	case "json":
+		if err := outputJSON(report); err != nil {
+			return err
+		}
+	case "text":
+		for _, diagnostic := range report.Diagnostics {
+			fmt.Println(diagnostic.String(httpFile))
+		}
+		if len(report.Diagnostics) == 0 {
+			fmt.Printf("%s: no problems found\n", httpFile)
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	if report.HasErrors() {
+		return fmt.Errorf("%d error(s), %d warning(s)", report.Errors, report.Warnings)
+	}
 	return nil
 }
 
-// loadEnvironmentFiles loads and merges environment files
-func loadEnvironmentFiles(envName string, envFile string, privateEnvFile string) (*environment.ResolvedEnvironment, error) {
+// defaultEnvFiles sets the environment files that weren't given to the
+// ones that apply to httpFile: those next to it, or in its nearest parent
+// directory that has any. Without any, the current directory's are used.
+func defaultEnvFiles(httpFile string, envFile, privateEnvFile *string) {
+	dir := ""
+	if httpFile != "" && (*envFile == "" || *privateEnvFile == "") {
+		dir = environment.FindEnvironmentDir(filepath.Dir(httpFile))
+		if wd, err := os.Getwd(); err == nil && dir != "" {
+			if relative, err := filepath.Rel(wd, dir); err == nil {
+				dir = relative
+			}
+		}
+	}
+	if *envFile == "" {
+		*envFile = filepath.Join(dir, environment.DefaultPublicFile)
+	}
+	if *privateEnvFile == "" {
+		*privateEnvFile = filepath.Join(dir, environment.DefaultPrivateFile)
+	}
+}
+
+// loadEnvironmentFiles loads and merges the environment files in config
+func loadEnvironmentFiles(config *environment.EnvironmentConfig) (*environment.ResolvedEnvironment, error) {
 	// Get working directory for loader
 	workingDir := "."
 	if abs, err := filepath.Abs("."); err == nil {
 		workingDir = abs
 	}
 
-	loader := environment.NewLoader(workingDir)
+	return environment.NewLoader(workingDir).Load(config)
+}
 
-	// Create environment config
-	config := &environment.EnvironmentConfig{
-		PublicFile:  envFile,
-		PrivateFile: privateEnvFile,
-		Environment: envName,
+// parseVarFlags parses --var name=value flags
+func parseVarFlags(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
 	}
-
-	// Load both environment files
-	publicEnv, privateEnv, err := loader.LoadEnvironments(config)
-	if err != nil {
-		// Check if it's just missing files
-		if os.IsNotExist(err) {
-			// Use empty environment if files don't exist
-			emptyEnv := make(environment.EnvironmentFile)
-			emptyPrivate := make(environment.EnvironmentFile)
-			publicEnv = &emptyEnv
-			privateEnv = &emptyPrivate
-		} else {
-			return nil, err
+	variables := make(map[string]string, len(values))
+	for _, value := range values {
+		name, val, ok := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --var %q (use name=value)", value)
 		}
+		variables[name] = val
 	}
+	return variables, nil
+}
 
-	// Resolve variables for the specified environment
-	resolver := environment.NewResolver()
-	resolvedEnv, err := resolver.Resolve(*publicEnv, *privateEnv, envName)
+// loadValidationConfig loads .postie/validation.json from the current
+// directory and applies --rules overrides on top
+func loadValidationConfig(rules string) (*httprequest.ValidationConfig, error) {
+	config, err := httprequest.LoadValidationConfig(".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve environment variables: %w", err)
+		return nil, err
 	}
-
-	return resolvedEnv, nil
+	if rules != "" {
+		if err := config.ApplyRuleFlags(rules); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
 }
 
-func executeHttpFileParse(httpFile, format string, validate bool) error {
+// executeHttpFileParse parses a file and validates it if validationConfig is set
+func executeHttpFileParse(httpFile, format string, validationConfig *httprequest.ValidationConfig) error {
 	// Read the HTTP file content
 	content, err := os.ReadFile(httpFile)
 	if err != nil {
@@ -270,14 +1044,15 @@ func executeHttpFileParse(httpFile, format string, validate bool) error {
 	}
 
 	// Validate if requested
-	if validate {
+	if validationConfig != nil {
 		validator := httprequest.NewValidator(true, "")
+		validator.SetRules(validationConfig)
 		if errors := validator.Validate(requestsFile); len(errors) > 0 {
-			fmt.Printf("Validation errors:\n")
-			for _, validationErr := range errors {
-				fmt.Printf("  - %s\n", validationErr.Message)
+			fmt.Print(httprequest.FormatErrors(errors))
+			if httprequest.HasErrors(errors) {
+				return fmt.Errorf("validation failed")
 			}
-			return fmt.Errorf("validation failed")
+			fmt.Println()
 		}
 	}
 
@@ -285,6 +1060,8 @@ func executeHttpFileParse(httpFile, format string, validate bool) error {
 	switch format {
 	case "json":
 		return outputJSON(requestsFile)
+	case "yaml":
+		return outputYAML(requestsFile)
 	case "summary":
 		return outputSummary(requestsFile)
 	default:
@@ -382,9 +1159,21 @@ func outputJSON(data interface{}) error {
 	return encoder.Encode(data)
 }
 
+func outputYAML(data interface{}) error {
+	return output.NewYAMLEncoder(os.Stdout).Encode(data)
+}
+
 func outputSummary(requestsFile *httprequest.RequestsFile) error {
 	fmt.Printf("Requests: %d\n\n", len(requestsFile.Requests))
 
+	if len(requestsFile.Variables) > 0 {
+		fmt.Printf("Variables:\n")
+		for _, variable := range requestsFile.Variables {
+			fmt.Printf("  @%s = %s\n", variable.Name, variable.Value)
+		}
+		fmt.Println()
+	}
+
 	for i, request := range requestsFile.Requests {
 		fmt.Printf("%d. %s %s", i+1, request.Method, request.URL.Raw)
 		if request.Name != "" {
@@ -0,0 +1,339 @@
+package commands
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"postie/pkg/auth"
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/environment"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/middleware"
+)
+
+// adHocMethods are the methods with an "http <method>" command
+var adHocMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// adHocCommands returns the "http get", "http post", ... commands, which
+// send one request described by flags instead of a .http file
+func adHocCommands() map[string]*cli.Command {
+	commands := make(map[string]*cli.Command, len(adHocMethods))
+	for _, method := range adHocMethods {
+		name := strings.ToLower(method)
+		commands[name] = httpAdHocCommand(method)
+	}
+	return commands
+}
+
+func httpAdHocCommand(method string) *cli.Command {
+	return &cli.Command{
+		Name:        strings.ToLower(method),
+		Description: "Send a " + method + " request without a .http file",
+		Action: func(args []string) error {
+			ctx, err := context.NewManager().Load()
+			if err != nil {
+				return err
+			}
+
+			// The URL may be given as the first argument instead of --url
+			var rawURL string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				rawURL, args = args[0], args[1:]
+			}
+
+			var env, envFile, privateEnvFile, body, bodyFile, jsonBody, authValue string
+			var verbose, showSecrets, softFail, bodyOnly, include bool
+
+			urlFlag := &cli.StringFlag{Name: "url", ShortName: "u", Usage: "Request URL; may use {{variables}}, or start with / to use the environment's $baseUrl", Required: false}
+			headerFlag := &cli.StringFlag{Name: "header", ShortName: "H", Usage: "Add a header as \"Name: value\" (repeatable)", Required: false, Multiple: true}
+			bodyFlag := &cli.StringFlag{Name: "body", ShortName: "d", Value: body, Usage: "Request body", Required: false}
+			bodyFileFlag := &cli.StringFlag{Name: "body-file", Value: bodyFile, Usage: "Send this file as the body (- for standard input)", Required: false}
+			jsonFlag := &cli.StringFlag{Name: "json", Value: jsonBody, Usage: "JSON request body, sent with Content-Type: application/json", Required: false}
+			formFlag := &cli.StringFlag{Name: "form", Usage: "Add a form field as name=value, sent URL-encoded (repeatable)", Required: false, Multiple: true}
+			authFlag := &cli.StringFlag{Name: "auth", Value: authValue, Usage: "user:password for Basic auth, \"bearer <token>\", \"apikey header|query <name> <value>\", or a plugin auth scheme and its arguments", Required: false}
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: env, Usage: "Environment to resolve variables with", Required: false}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			varFlag := &cli.StringFlag{Name: "var", Usage: "Set a variable as name=value, overriding every other source (repeatable)", Required: false, Multiple: true}
+			verboseFlag := &cli.BoolFlag{Name: "verbose", ShortName: "v", Value: verbose, Usage: "Show detailed output"}
+			showSecretsFlag := &cli.BoolFlag{Name: "show-secrets", Value: showSecrets, Usage: "Don't mask private environment values in output"}
+			softFailFlag := &cli.BoolFlag{Name: "soft-fail", Value: softFail, Usage: "Exit with status 0 even if the request fails"}
+			bodyOnlyFlag := &cli.BoolFlag{Name: "body-only", Value: bodyOnly, Usage: "Write only the response body, as received, for piping to other tools"}
+			includeFlag := &cli.BoolFlag{Name: "include", ShortName: "i", Value: include, Usage: "Like --body-only, with the status line and headers before the body"}
+			yesIKnowFlag := &cli.BoolFlag{Name: "yes-i-know", Usage: "Send the request even if its host isn't one of the context's safe hosts"}
+
+			_, err = cli.ParseFlags(args, []*cli.StringFlag{urlFlag, headerFlag, bodyFlag, bodyFileFlag, jsonFlag, formFlag, authFlag, envFlag, envFileFlag, privateEnvFileFlag, varFlag}, []*cli.BoolFlag{verboseFlag, showSecretsFlag, softFailFlag, bodyOnlyFlag, includeFlag, yesIKnowFlag})
+			if err != nil {
+				return err
+			}
+
+			if urlFlag.Value != "" {
+				rawURL = urlFlag.Value
+			}
+			if rawURL == "" {
+				return fmt.Errorf("URL required\nUsage: postie http %s <url> [--header \"Name: value\"] [--json '{...}']", strings.ToLower(method))
+			}
+
+			request, err := adHocRequest(method, rawURL, headerFlag.Values, adHocBody{
+				Body:     bodyFlag.Value,
+				BodyFile: bodyFileFlag.Value,
+				JSON:     jsonFlag.Value,
+				Form:     formFlag.Values,
+			}, authFlag.Value)
+			if err != nil {
+				return err
+			}
+
+			variables, err := parseVarFlags(varFlag.Values)
+			if err != nil {
+				return err
+			}
+
+			var httpFile, responsesDir string
+			var saveResponses bool
+			env = envFlag.Value
+			envFile = envFileFlag.Value
+			privateEnvFile = privateEnvFileFlag.Value
+			context.MergeWithFlags(ctx, &httpFile, &env, &envFile, &privateEnvFile, &responsesDir, &saveResponses)
+
+			resolvedEnv, err := adHocEnvironment(env, envFile, privateEnvFile, variables)
+			if err != nil {
+				return err
+			}
+
+			return executeAdHocRequest(request, resolvedEnv, &adHocOptions{
+				Verbose:     verboseFlag.Value,
+				ShowSecrets: showSecretsFlag.Value,
+				SoftFail:    softFailFlag.Value,
+				BodyOnly:    bodyOnlyFlag.Value,
+				Include:     includeFlag.Value,
+				SafeHosts:   safeHosts(ctx, yesIKnowFlag.Value),
+			})
+		},
+	}
+}
+
+// adHocBody holds the body flags of an ad-hoc request; at most one is set
+type adHocBody struct {
+	Body     string
+	BodyFile string
+	JSON     string
+	Form     []string
+}
+
+// adHocRequest builds the request described by the flags of an ad-hoc
+// command
+func adHocRequest(method, rawURL string, headers []string, body adHocBody, authValue string) (*httprequest.Request, error) {
+	request := &httprequest.Request{
+		Method:   method,
+		URL:      &httprequest.URL{Raw: rawURL},
+		Metadata: make(map[string]string),
+	}
+
+	parsed, err := parseHeaderFlags(headers)
+	if err != nil {
+		return nil, err
+	}
+	request.Headers = parsed
+
+	given := 0
+	for _, set := range []bool{body.Body != "", body.BodyFile != "", body.JSON != "", len(body.Form) > 0} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, fmt.Errorf("use only one of --body, --body-file, --json and --form")
+	}
+
+	switch {
+	case body.Body != "":
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: body.Body}
+		request.Body.ContentType = request.Body.GetContentType()
+	case body.BodyFile != "":
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeFile, FilePath: body.BodyFile}
+	case body.JSON != "":
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: body.JSON, ContentType: "application/json"}
+	case len(body.Form) > 0:
+		fields := make([]string, len(body.Form))
+		for i, field := range body.Form {
+			name, value, ok := strings.Cut(field, "=")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("invalid --form %q (use name=value)", field)
+			}
+			fields[i] = escapeFormValue(name) + "=" + escapeFormValue(value)
+		}
+		request.Body = &httprequest.RequestBody{
+			Type:        httprequest.BodyTypeInline,
+			Content:     strings.Join(fields, "&"),
+			ContentType: "application/x-www-form-urlencoded",
+		}
+	}
+
+	if authValue != "" {
+		if err := applyAdHocAuth(request, authValue); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// applyAdHocAuth adds the Authorization header for --auth user:password or
+// --auth "bearer <token>". Other values name a plugin's auth scheme, as
+// # @auth does.
+func applyAdHocAuth(request *httprequest.Request, value string) error {
+	scheme, rest, _ := strings.Cut(value, " ")
+	switch {
+	case strings.EqualFold(scheme, "bearer"):
+		token := strings.TrimSpace(rest)
+		if token == "" {
+			return fmt.Errorf("--auth bearer needs a token")
+		}
+		request.Headers = append(request.Headers, httprequest.Header{Name: "Authorization", Value: "Bearer " + token})
+	case rest == "" && strings.Contains(value, ":"):
+		// "bearer:token" is a mistyped scheme, not a user called bearer
+		if user, _, _ := strings.Cut(value, ":"); auth.IsScheme(user) || strings.EqualFold(user, "digest") {
+			return fmt.Errorf("--auth %s:... isn't user:password; separate the %s scheme from its arguments with a space, as in \"%s ...\"", user, user, strings.ToLower(user))
+		}
+		// Variables are expanded after encoding, so they must be in the
+		// header as written
+		if strings.Contains(value, "{{") {
+			return fmt.Errorf("--auth user:password can't use variables; use --header \"Authorization: Basic ...\" instead")
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(value))
+		request.Headers = append(request.Headers, httprequest.Header{Name: "Authorization", Value: "Basic " + encoded})
+	default:
+		request.Metadata[httprequest.DirectiveAuth] = value
+	}
+	return nil
+}
+
+// formVariable matches {{variables}}, which are kept unescaped in form
+// fields so they can be expanded
+var formVariable = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// escapeFormValue URL-encodes a form field name or value, except for its
+// {{variables}}
+func escapeFormValue(value string) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range formVariable.FindAllStringIndex(value, -1) {
+		b.WriteString(url.QueryEscape(value[last:match[0]]))
+		b.WriteString(value[match[0]:match[1]])
+		last = match[1]
+	}
+	b.WriteString(url.QueryEscape(value[last:]))
+	return b.String()
+}
+
+// adHocEnvironment loads the environment of an ad-hoc request. Without
+// --env, and with no environment files, requests run with just the --var
+// variables.
+func adHocEnvironment(env, envFile, privateEnvFile string, variables map[string]string) (*environment.ResolvedEnvironment, error) {
+	if envFile == "" {
+		envFile = "http-client.env.json"
+	}
+	if privateEnvFile == "" {
+		privateEnvFile = "http-client.private.env.json"
+	}
+
+	if env == "" && !fileExists(envFile) && !fileExists(privateEnvFile) {
+		resolved := &environment.ResolvedEnvironment{
+			Variables: make(map[string]interface{}, len(variables)),
+			Source:    make(map[string]string, len(variables)),
+		}
+		for name, value := range variables {
+			resolved.Variables[name] = value
+			resolved.Source[name] = "cli"
+		}
+		return resolved, nil
+	}
+
+	if env == "" {
+		env = "development"
+	}
+	resolved, err := loadEnvironmentFiles(&environment.EnvironmentConfig{
+		PublicFile:  envFile,
+		PrivateFile: privateEnvFile,
+		Environment: env,
+		DotEnvFile:  environment.DefaultDotEnvFile,
+		Variables:   variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment: %w", err)
+	}
+	return resolved, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// adHocOptions are the output options of an ad-hoc request
+type adHocOptions struct {
+	Verbose     bool
+	ShowSecrets bool
+	SoftFail    bool     // Exit with status 0 even if the request fails
+	BodyOnly    bool     // Write only the response body
+	Include     bool     // Write the status line and headers before the body
+	SafeHosts   []string // Hosts POST, PUT, PATCH and DELETE may be sent to (nil for any)
+}
+
+// executeAdHocRequest sends an ad-hoc request and prints its result like
+// http run does
+func executeAdHocRequest(request *httprequest.Request, env *environment.ResolvedEnvironment, opts *adHocOptions) error {
+	exec := executor.NewExecutor(env, &executor.ExecutorConfig{
+		ShowSecrets:     opts.ShowSecrets,
+		ScriptHTTPCheck: middleware.HostSafetyCheck(opts.SafeHosts),
+	})
+	defer exec.Close()
+	if len(opts.SafeHosts) > 0 {
+		exec.AddHook(middleware.HostSafetyHook(opts.SafeHosts))
+	}
+	exec.AddHook(middleware.IdempotencyHook())
+	registerPlugins(exec, env.Name)
+
+	formatter := executor.NewFormatter(opts.Verbose)
+	formatter.SetPalette(cli.Palette())
+	formatter.SetRedactor(exec.Redactor())
+
+	result, _ := exec.ExecuteRequest(request)
+	results := []*executor.ExecutionResult{result}
+
+	switch {
+	case opts.BodyOnly || opts.Include:
+		printRawResults(formatter, results, opts.Include)
+	case cli.IsJSONOutput():
+		report := executor.NewRunReport("", env.Name, results)
+		report.Redact(exec.Redactor())
+		if err := outputJSON(report); err != nil {
+			return err
+		}
+	case cli.IsQuiet():
+		fmt.Print(formatter.FormatSummary(results))
+	default:
+		fmt.Print(formatter.FormatResult(result, 1))
+	}
+	return runError(results, opts.SoftFail)
+}
+
+// parseHeaderFlags parses --header values given as "Name: value"
+func parseHeaderFlags(values []string) ([]httprequest.Header, error) {
+	var headers []httprequest.Header
+	for _, header := range values {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --header %q (use \"Name: value\")", header)
+		}
+		headers = append(headers, httprequest.Header{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return headers, nil
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"postie/pkg/cli"
+	"postie/pkg/display"
+	"postie/pkg/executor"
+	"postie/pkg/logging"
+)
+
+// maxShownDifferences is the number of body differences listed for each
+// request; --output json lists them all
+const maxShownDifferences = 10
+
+// driftLabels describe each kind of drift between environments
+var driftLabels = map[string]string{
+	executor.DriftNone:    "same",
+	executor.DriftStatus:  "status differs",
+	executor.DriftBody:    "body differs",
+	executor.DriftSkipped: "skipped in some environments",
+}
+
+// runEnvironmentComparison runs opts.File in each of opts.CompareEnvs at
+// once and prints how the results differ
+func runEnvironmentComparison(opts *httpRunOptions) error {
+	if opts.OTLPEndpoint != "" || opts.MetricsAddr != "" || opts.MetricsPush != "" {
+		logging.Warn("--compare runs aren't traced or measured")
+	}
+
+	runs := make([]*fileRun, len(opts.CompareEnvs))
+	errs := make([]error, len(opts.CompareEnvs))
+	var wg sync.WaitGroup
+	for i, env := range opts.CompareEnvs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			envOpts := *opts
+			envOpts.Env = env
+			envOpts.rateLimits = executor.NewRateLimitTracker(opts.RateLimitWait)
+			runs[i], errs[i] = executeHttpFile(&envOpts, nil)
+		}()
+	}
+	wg.Wait()
+
+	results := make([][]*executor.ExecutionResult, len(runs))
+	for i, run := range runs {
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %w", opts.CompareEnvs[i], errs[i])
+		}
+		results[i] = run.results
+	}
+
+	comparison := executor.CompareEnvironments(opts.CompareEnvs, results)
+	for i, run := range runs {
+		comparison.Redact(i, run.redactor)
+	}
+
+	if cli.IsJSONOutput() {
+		if err := outputJSON(comparison); err != nil {
+			return err
+		}
+	} else {
+		printEnvironmentComparison(comparison)
+	}
+
+	if comparison.Drifted() && !opts.SoftFail {
+		drifted := len(comparison.Requests) - comparison.Summary[executor.DriftNone]
+		return cli.Exit(cli.ExitFailed, fmt.Errorf("%d of %d requests differ between environments", drifted, len(comparison.Requests)))
+	}
+	return nil
+}
+
+// printEnvironmentComparison prints each request's result in every
+// environment, and how they differ
+func printEnvironmentComparison(comparison *executor.EnvironmentComparison) {
+	palette := cli.Palette()
+	fmt.Printf("Comparing %s\n", strings.Join(comparison.Environments, ", "))
+
+	width := 0
+	for _, env := range comparison.Environments {
+		width = max(width, len(env))
+	}
+
+	for _, request := range comparison.Requests {
+		label := driftLabels[request.Drift]
+		if request.Drift == executor.DriftNone {
+			label = palette.Success(label)
+		} else {
+			label = palette.Changed(label)
+		}
+		fmt.Printf("\n%s %s\n", palette.Heading(fmt.Sprintf("=== %s ===", request.Request)), label)
+
+		for _, result := range request.Results {
+			fmt.Printf("  %-*s  %s\n", width, result.Environment, describeEnvironmentResult(result))
+		}
+
+		for i, difference := range request.Differences {
+			if i == maxShownDifferences {
+				fmt.Printf("  ... and %d more differences\n", len(request.Differences)-i)
+				break
+			}
+			fmt.Printf("  %s\n", palette.Changed("~ "+difference.Path))
+			for env, value := range difference.Values {
+				if value == "" {
+					value = palette.Muted("(none)")
+				}
+				fmt.Printf("      %-*s  %s\n", width+1, comparison.Environments[env]+":", value)
+			}
+		}
+	}
+
+	summary := comparison.Summary
+	fmt.Printf("\n%d requests: %d same, %d with different statuses, %d with different bodies, %d skipped in some environments\n",
+		len(comparison.Requests), summary[executor.DriftNone], summary[executor.DriftStatus], summary[executor.DriftBody], summary[executor.DriftSkipped])
+}
+
+// describeEnvironmentResult formats the status and duration of a request
+// in one environment
+func describeEnvironmentResult(result executor.EnvironmentResult) string {
+	switch {
+	case result.Skipped:
+		return "skipped"
+	case result.Error != "" && result.StatusCode == 0:
+		return "error: " + result.Error
+	}
+	return fmt.Sprintf("%s  %s", cli.Palette().Status(result.StatusCode, result.Status), display.Milliseconds(result.DurationMs))
+}
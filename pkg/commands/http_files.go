@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"postie/pkg/executor"
+	"postie/pkg/logging"
+)
+
+// expandHttpFiles expands the glob patterns among the files given to "http
+// run". "**" matches any number of directories, so "tests/**/*.http"
+// finds .http files at any depth under tests, outside hidden directories.
+// Files are returned sorted and without repeats, so runs are repeatable.
+func expandHttpFiles(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			if !seen[pattern] {
+				seen[pattern] = true
+				files = append(files, pattern)
+			}
+			continue
+		}
+
+		matches, err := globHttpFiles(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// globHttpFiles returns the files matching a pattern that may use "**"
+func globHttpFiles(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(filepath.FromSlash(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return matches, nil
+	}
+
+	// Walk from the directory before the first wildcard
+	segments := strings.Split(pattern, "/")
+	base := 0
+	for base < len(segments)-1 && !strings.ContainsAny(segments[base], "*?[") {
+		base++
+	}
+	root := strings.Join(segments[:base], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Hidden directories such as .git aren't searched
+			if file != filepath.FromSlash(root) && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relative, err := filepath.Rel(filepath.FromSlash(root), file)
+		if err != nil {
+			return err
+		}
+		ok, err := matchSegments(segments[base:], strings.Split(filepath.ToSlash(relative), "/"))
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		if ok {
+			matches = append(matches, file)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more segments
+func matchSegments(pattern, segments []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(segments) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if ok, err := matchSegments(pattern[1:], segments[i:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(segments) == 0 {
+		return false, nil
+	}
+	ok, err := path.Match(pattern[0], segments[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// runHttpFiles runs several .http files, one after another, and prints
+// their results with one summary. Files that have no requests matching
+// --request or --select are skipped. Unless environment files were given,
+// each file uses the ones that apply to its directory.
+func runHttpFiles(opts *httpRunOptions) error {
+	var runs []*fileRun
+	for _, file := range opts.Files {
+		fileOpts := *opts
+		fileOpts.File = file
+		if opts.DiscoverEnvFiles {
+			fileOpts.EnvFile, fileOpts.PrivateEnvFile = "", ""
+			defaultEnvFiles(file, &fileOpts.EnvFile, &fileOpts.PrivateEnvFile)
+		}
+
+		run, err := executeHttpFile(&fileOpts, nil)
+		if errors.Is(err, executor.ErrNoMatch) {
+			logging.Verbose("skipping file", "file", file, "reason", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if len(run.results) > 0 {
+			runs = append(runs, run)
+		}
+	}
+
+	if len(runs) == 0 {
+		return fmt.Errorf("no requests executed in %d files", len(opts.Files))
+	}
+	return printRuns(opts, runs)
+}
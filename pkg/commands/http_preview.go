@@ -0,0 +1,350 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/environment"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/redact"
+	"postie/pkg/selector"
+)
+
+// previewColumnWidth is the widest value shown in a side-by-side column
+const previewColumnWidth = 48
+
+func httpPreviewCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "preview",
+		Description: "Show requests as they would be sent, or compare them between two environments",
+		Action: func(args []string) error {
+			ctx, err := context.NewManager().Load()
+			if err != nil {
+				return err
+			}
+
+			var httpFile string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				httpFile, args = args[0], args[1:]
+			}
+
+			var envFile, privateEnvFile, requestFilter string
+			var diff, showSecrets bool
+
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Usage: "Environment to resolve with; give two to compare them", Required: false, Multiple: true}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			requestFlag := &cli.StringFlag{Name: "request", ShortName: "r", Value: requestFilter, Usage: "Request name or number to preview", Required: false}
+			selectFlag := &cli.StringFlag{Name: "select", Usage: "Preview the requests matching an expression, e.g. 'tag in (smoke)'", Required: false}
+			diffFlag := &cli.BoolFlag{Name: "diff", Value: diff, Usage: "Only show what differs between the two environments"}
+			showSecretsFlag := &cli.BoolFlag{Name: "show-secrets", Value: showSecrets, Usage: "Don't mask private environment values in output"}
+
+			_, err = cli.ParseFlags(args, []*cli.StringFlag{envFlag, envFileFlag, privateEnvFileFlag, requestFlag, selectFlag}, []*cli.BoolFlag{diffFlag, showSecretsFlag})
+			if err != nil {
+				return err
+			}
+
+			envs := envFlag.Values
+			var env, responsesDir string
+			var saveResponses bool
+			envFile = envFileFlag.Value
+			privateEnvFile = privateEnvFileFlag.Value
+			context.MergeWithFlags(ctx, &httpFile, &env, &envFile, &privateEnvFile, &responsesDir, &saveResponses)
+
+			if httpFile == "" {
+				return fmt.Errorf("HTTP request file required\nUsage: postie http preview <file.http> --request <name> --env dev [--env prod] [--diff]")
+			}
+			if len(envs) == 0 {
+				if env == "" {
+					env = "development"
+				}
+				envs = []string{env}
+			}
+			if len(envs) > 2 {
+				return fmt.Errorf("at most two environments can be compared")
+			}
+			if diffFlag.Value && len(envs) != 2 {
+				return fmt.Errorf("--diff needs two environments, e.g. --env dev --env prod")
+			}
+			defaultEnvFiles(httpFile, &envFile, &privateEnvFile)
+
+			var selection *selector.Selector
+			if selectFlag.Value != "" {
+				if selection, err = executor.ParseSelector(selectFlag.Value); err != nil {
+					return err
+				}
+			}
+
+			return executeHttpPreview(&httpPreviewOptions{
+				File:           httpFile,
+				Envs:           envs,
+				EnvFile:        envFile,
+				PrivateEnvFile: privateEnvFile,
+				Request:        requestFlag.Value,
+				Select:         selection,
+				Diff:           diffFlag.Value,
+				ShowSecrets:    showSecretsFlag.Value,
+			})
+		},
+	}
+}
+
+// httpPreviewOptions holds the settings for "http preview"
+type httpPreviewOptions struct {
+	File           string
+	Envs           []string // One environment, or two to compare
+	EnvFile        string
+	PrivateEnvFile string
+	Request        string             // Request name or number filter
+	Select         *selector.Selector // --select expression, or nil
+	Diff           bool               // Only show differences
+	ShowSecrets    bool
+}
+
+// resolvedRequest is a request as it would be sent in one environment
+type resolvedRequest struct {
+	Method  string               `json:"method"`
+	URL     string               `json:"url"`
+	Headers []httprequest.Header `json:"headers,omitempty"`
+	Body    string               `json:"body,omitempty"`
+}
+
+// previewRow is one compared field of a request
+type previewRow struct {
+	Field     string   `json:"field"`
+	Values    []string `json:"values"`
+	Different bool     `json:"different"`
+}
+
+// requestPreview is the preview of one request in each environment
+type requestPreview struct {
+	Name         string                      `json:"name"`
+	Environments map[string]*resolvedRequest `json:"environments"`
+	Differences  []previewRow                `json:"differences,omitempty"`
+}
+
+func executeHttpPreview(opts *httpPreviewOptions) error {
+	requestsFile, err := parseHttpFile(opts.File)
+	if err != nil {
+		return err
+	}
+
+	// One executor per environment; nothing is sent
+	execs := make([]*executor.Executor, len(opts.Envs))
+	for i, name := range opts.Envs {
+		resolvedEnv, err := loadEnvironmentFiles(&environment.EnvironmentConfig{
+			PublicFile:  opts.EnvFile,
+			PrivateFile: opts.PrivateEnvFile,
+			Environment: name,
+			DotEnvFile:  environment.DefaultDotEnvFile,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load environment %s: %w", name, err)
+		}
+		execs[i] = executor.NewExecutor(resolvedEnv, &executor.ExecutorConfig{ShowSecrets: opts.ShowSecrets})
+		defer execs[i].Close()
+		registerPlugins(execs[i], resolvedEnv.Name)
+	}
+
+	selected, err := execs[0].SelectRequests(requestsFile, opts.Request)
+	if err == nil && opts.Select != nil {
+		selected, err = executor.SelectMatching(requestsFile, selected, opts.Select)
+	}
+	if err != nil {
+		return err
+	}
+
+	var previews []*requestPreview
+	for _, index := range selected {
+		request := &requestsFile.Requests[index]
+		preview := &requestPreview{Name: request.Name, Environments: make(map[string]*resolvedRequest)}
+		if preview.Name == "" {
+			preview.Name = fmt.Sprintf("Request %d", index+1)
+		}
+
+		resolved := make([]*resolvedRequest, len(execs))
+		for i, exec := range execs {
+			expanded, err := exec.ResolveRequest(requestsFile, request)
+			if err != nil {
+				return fmt.Errorf("%s in %s: %w", preview.Name, opts.Envs[i], err)
+			}
+			resolved[i] = newResolvedRequest(expanded)
+		}
+
+		// Values are compared before secrets are masked, so differing
+		// secrets are still reported
+		rows := previewRows(resolved)
+		for i, exec := range execs {
+			for _, row := range rows {
+				row.Values[i] = exec.Redactor().Redact(row.Values[i])
+			}
+			preview.Environments[opts.Envs[i]] = resolved[i].redact(exec.Redactor())
+		}
+		if len(resolved) == 2 {
+			for _, row := range rows {
+				if row.Different {
+					preview.Differences = append(preview.Differences, row)
+				}
+			}
+		}
+
+		if !cli.IsJSONOutput() {
+			printRequestPreview(preview, opts, rows)
+		}
+		previews = append(previews, preview)
+	}
+
+	if cli.IsJSONOutput() {
+		return outputJSON(previews)
+	}
+	return nil
+}
+
+// newResolvedRequest converts an expanded request
+func newResolvedRequest(request *httprequest.Request) *resolvedRequest {
+	resolved := &resolvedRequest{Method: request.Method, Headers: request.Headers}
+	if request.URL != nil {
+		resolved.URL = request.URL.Raw
+	}
+	if request.Body != nil {
+		resolved.Body = request.Body.Content
+	}
+	return resolved
+}
+
+// redact returns a copy of the request with secret values masked
+func (r *resolvedRequest) redact(redactor *redact.Redactor) *resolvedRequest {
+	masked := &resolvedRequest{Method: r.Method, URL: redactor.Redact(r.URL), Body: redactor.Redact(r.Body)}
+	for _, header := range r.Headers {
+		masked.Headers = append(masked.Headers, httprequest.Header{Name: header.Name, Value: redactor.Redact(header.Value)})
+	}
+	return masked
+}
+
+// previewRows lines up the URL, headers and body lines of a request
+// resolved in each environment
+func previewRows(resolved []*resolvedRequest) []previewRow {
+	var rows []previewRow
+	add := func(field string, value func(i int) string) {
+		row := previewRow{Field: field}
+		for i := range resolved {
+			row.Values = append(row.Values, value(i))
+		}
+		for _, v := range row.Values[1:] {
+			if v != row.Values[0] {
+				row.Different = true
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	add("URL", func(i int) string { return resolved[i].Method + " " + resolved[i].URL })
+
+	// Headers are matched by name; repeated headers are joined
+	var names []string
+	seen := make(map[string]bool)
+	for _, request := range resolved {
+		for _, header := range request.Headers {
+			if key := strings.ToLower(header.Name); !seen[key] {
+				seen[key] = true
+				names = append(names, header.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		add(name, func(i int) string {
+			var values []string
+			for _, header := range resolved[i].Headers {
+				if strings.EqualFold(header.Name, name) {
+					values = append(values, header.Value)
+				}
+			}
+			return strings.Join(values, ", ")
+		})
+	}
+
+	// Bodies are compared line by line
+	lines := make([][]string, len(resolved))
+	count := 0
+	for i, request := range resolved {
+		if request.Body != "" {
+			lines[i] = strings.Split(request.Body, "\n")
+		}
+		count = max(count, len(lines[i]))
+	}
+	for n := 0; n < count; n++ {
+		add(fmt.Sprintf("Body:%d", n+1), func(i int) string {
+			if n < len(lines[i]) {
+				return lines[i][n]
+			}
+			return ""
+		})
+	}
+
+	return rows
+}
+
+// printRequestPreview prints a request as it would be sent, or compares it
+// between two environments
+func printRequestPreview(preview *requestPreview, opts *httpPreviewOptions, rows []previewRow) {
+	fmt.Printf("=== %s ===\n", preview.Name)
+
+	if len(opts.Envs) == 1 {
+		resolved := preview.Environments[opts.Envs[0]]
+		fmt.Printf("Environment: %s\n\n", opts.Envs[0])
+		fmt.Printf("%s %s\n", resolved.Method, resolved.URL)
+		for _, header := range resolved.Headers {
+			fmt.Printf("%s: %s\n", header.Name, header.Value)
+		}
+		if resolved.Body != "" {
+			fmt.Printf("\n%s\n", resolved.Body)
+		}
+		fmt.Println()
+		return
+	}
+
+	// --diff lists only the differences, with their full values
+	if opts.Diff {
+		if len(preview.Differences) == 0 {
+			fmt.Printf("No differences between %s and %s\n\n", opts.Envs[0], opts.Envs[1])
+			return
+		}
+		width := max(len(opts.Envs[0]), len(opts.Envs[1]))
+		for _, row := range preview.Differences {
+			fmt.Printf("%s\n", cli.Palette().Changed("~ "+row.Field))
+			fmt.Printf("    %-*s  %s\n", width+1, opts.Envs[0]+":", row.Values[0])
+			fmt.Printf("    %-*s  %s\n", width+1, opts.Envs[1]+":", row.Values[1])
+		}
+		fmt.Println()
+		return
+	}
+
+	fieldWidth := len("Field")
+	valueWidth := len(opts.Envs[0])
+	for _, row := range rows {
+		fieldWidth = max(fieldWidth, len(row.Field))
+		valueWidth = max(valueWidth, min(len(row.Values[0]), previewColumnWidth))
+	}
+
+	fmt.Printf("  %-*s  %-*s  %s\n", fieldWidth, "", valueWidth, opts.Envs[0], opts.Envs[1])
+	for _, row := range rows {
+		line := fmt.Sprintf("  %-*s  %-*s  %s", fieldWidth, row.Field, valueWidth,
+			truncatePreview(row.Values[0]), truncatePreview(row.Values[1]))
+		if row.Different {
+			line = cli.Palette().Changed("~" + line[1:])
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\n%d of %d fields differ\n\n", len(preview.Differences), len(rows))
+}
+
+// truncatePreview shortens a value to the column width
+func truncatePreview(value string) string {
+	if len(value) <= previewColumnWidth {
+		return value
+	}
+	return value[:previewColumnWidth-3] + "..."
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/environment"
+	"postie/pkg/executor"
+	"postie/pkg/snippet"
+)
+
+func httpSnippetCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "snippet",
+		Description: "Print a request as curl, Go, Python or JavaScript code",
+		Action: func(args []string) error {
+			ctx, err := context.NewManager().Load()
+			if err != nil {
+				return err
+			}
+
+			var httpFile string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				httpFile, args = args[0], args[1:]
+			}
+
+			var env, envFile, privateEnvFile, requestFilter, lang string
+			var showSecrets bool
+
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Value: env, Usage: "Environment to resolve variables with", Required: false}
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+			requestFlag := &cli.StringFlag{Name: "request", ShortName: "r", Value: requestFilter, Usage: "Request name or number", Required: false}
+			langFlag := &cli.StringFlag{Name: "lang", ShortName: "l", Value: lang, Usage: "Snippet language: " + strings.Join(snippet.Languages(snippet.TemplatesDir), ", ") + " (default: curl)", Required: false}
+			showSecretsFlag := &cli.BoolFlag{Name: "show-secrets", Value: showSecrets, Usage: "Don't mask private environment values"}
+
+			_, err = cli.ParseFlags(args, []*cli.StringFlag{envFlag, envFileFlag, privateEnvFileFlag, requestFlag, langFlag}, []*cli.BoolFlag{showSecretsFlag})
+			if err != nil {
+				return err
+			}
+
+			var responsesDir string
+			var saveResponses bool
+			env = envFlag.Value
+			envFile = envFileFlag.Value
+			privateEnvFile = privateEnvFileFlag.Value
+			context.MergeWithFlags(ctx, &httpFile, &env, &envFile, &privateEnvFile, &responsesDir, &saveResponses)
+
+			if httpFile == "" {
+				return fmt.Errorf("HTTP request file required\nUsage: postie http snippet <file.http> --request <name> --lang curl|go|python|js")
+			}
+			if env == "" {
+				env = "development"
+			}
+			defaultEnvFiles(httpFile, &envFile, &privateEnvFile)
+			lang = langFlag.Value
+			if lang == "" {
+				lang = "curl"
+			}
+
+			return executeHttpSnippet(httpFile, requestFlag.Value, lang, &environment.EnvironmentConfig{
+				PublicFile:  envFile,
+				PrivateFile: privateEnvFile,
+				Environment: env,
+				DotEnvFile:  environment.DefaultDotEnvFile,
+			}, showSecretsFlag.Value)
+		},
+	}
+}
+
+func executeHttpSnippet(httpFile, requestFilter, lang string, envConfig *environment.EnvironmentConfig, showSecrets bool) error {
+	requestsFile, err := parseHttpFile(httpFile)
+	if err != nil {
+		return err
+	}
+
+	resolvedEnv, err := loadEnvironmentFiles(envConfig)
+	if err != nil {
+		return fmt.Errorf("failed to load environment %s: %w", envConfig.Environment, err)
+	}
+	exec := executor.NewExecutor(resolvedEnv, &executor.ExecutorConfig{ShowSecrets: showSecrets})
+	defer exec.Close()
+	registerPlugins(exec, resolvedEnv.Name)
+
+	selected, err := exec.SelectRequests(requestsFile, requestFilter)
+	if err != nil {
+		return err
+	}
+	if len(selected) != 1 {
+		return fmt.Errorf("%s has %d requests; choose one with --request <name or number>", httpFile, len(selected))
+	}
+
+	expanded, err := exec.ResolveRequest(requestsFile, &requestsFile.Requests[selected[0]])
+	if err != nil {
+		return err
+	}
+
+	// Private values are masked unless --show-secrets is given
+	redactor := exec.Redactor()
+	request := &snippet.Request{
+		Name:   expanded.Name,
+		Method: expanded.Method,
+	}
+	if expanded.URL != nil {
+		request.URL = redactor.Redact(expanded.URL.Raw)
+	}
+	for _, header := range expanded.Headers {
+		header.Value = redactor.Redact(header.Value)
+		request.Headers = append(request.Headers, header)
+	}
+	if expanded.Body != nil {
+		request.Body = redactor.Redact(expanded.Body.Content)
+	}
+
+	code, err := snippet.Render(snippet.TemplatesDir, lang, request)
+	if err != nil {
+		return err
+	}
+	fmt.Print(code)
+	return nil
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"postie/pkg/cli"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+func TestRunErrorExitCodes(t *testing.T) {
+	failed := &executor.ExecutionResult{StatusCode: 500}
+	blocked := &executor.ExecutionResult{Error: errors.New(`dependency "login" failed`), Blocked: true}
+	unsent := &executor.ExecutionResult{Error: errors.New("connection refused")}
+	invalid := &executor.ExecutionResult{Error: &executor.NotSentError{Err: errors.New("unresolved variable: nope")}}
+	passed := &executor.ExecutionResult{StatusCode: 200}
+	skipped := &executor.ExecutionResult{Skipped: true}
+
+	tests := []struct {
+		name     string
+		results  []*executor.ExecutionResult
+		softFail bool
+		want     int
+	}{
+		{"passed", []*executor.ExecutionResult{passed, skipped}, false, cli.ExitOK},
+		{"failed", []*executor.ExecutionResult{passed, failed}, false, cli.ExitFailed},
+		// A request blocked by a failed dependency or setup is a failure,
+		// not a transport error
+		{"blocked", []*executor.ExecutionResult{failed, blocked}, false, cli.ExitFailed},
+		{"unsent", []*executor.ExecutionResult{unsent, blocked}, false, cli.ExitTransport},
+		{"soft fail", []*executor.ExecutionResult{failed, blocked}, true, cli.ExitOK},
+		// Requests that were invalid before anything was sent
+		{"invalid", []*executor.ExecutionResult{unsent, invalid}, false, cli.ExitConfig},
+		{"invalid soft fail", []*executor.ExecutionResult{invalid}, true, cli.ExitConfig},
+	}
+
+	for _, tt := range tests {
+		if code := cli.ExitCode(runError(tt.results, tt.softFail)); code != tt.want {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.want, code)
+		}
+	}
+}
+
+func TestApplyAdHocAuth(t *testing.T) {
+	request := &httprequest.Request{Metadata: map[string]string{}}
+	if err := applyAdHocAuth(request, "alice:secret"); err != nil || len(request.Headers) != 1 || request.Headers[0].Value != "Basic YWxpY2U6c2VjcmV0" {
+		t.Errorf("Expected Basic auth, got %v, %v", request.Headers, err)
+	}
+
+	// A scheme written like user:password isn't sent as Basic auth
+	for _, value := range []string{"bearer:tok", "Digest:user:pass", "apikey:header"} {
+		request := &httprequest.Request{Metadata: map[string]string{}}
+		if err := applyAdHocAuth(request, value); err == nil || len(request.Headers) != 0 {
+			t.Errorf("%s: expected an error, got %v, %v", value, request.Headers, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"postie/pkg/httprequest"
+	"postie/pkg/watch"
+)
+
+// watchHttpFileRun runs the file once and then again whenever the .http file,
+// a referenced body/script file or an environment file changes
+func watchHttpFileRun(opts *httpRunOptions) error {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	watcher := watch.NewWatcher(watch.DefaultInterval)
+	signatures := map[string]requestSignature{}
+
+	// Errors are reported but never end the loop; the next save may fix them
+	runAndReport := func(only map[int]bool) {
+		if err := runHttpFile(opts, only); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	refresh := func() {
+		requestsFile, err := parseHttpFile(opts.File)
+		files := []string{opts.File, opts.EnvFile, opts.PrivateEnvFile}
+		if err == nil {
+			files = append(files, referencedFiles(opts.File, requestsFile)...)
+			signatures = requestSignatures(requestsFile)
+		}
+		watcher.SetFiles(files...)
+	}
+
+	refresh()
+	runAndReport(nil)
+
+	for {
+		fmt.Fprintf(os.Stderr, "\nWatching %d files for changes (Ctrl+C to stop)...\n", len(watcher.Files()))
+
+		changed, ok := watcher.Wait(stop)
+		if !ok {
+			return nil
+		}
+
+		previous := signatures
+		refresh()
+
+		fmt.Fprintf(os.Stderr, "\n[%s] Changed: %v\n", time.Now().Format("15:04:05"), changed)
+
+		var only map[int]bool
+		if opts.ChangedOnly && onlyHttpFileChanged(changed, opts.File) {
+			only = changedRequests(previous, signatures)
+			if len(only) == 0 {
+				fmt.Fprintln(os.Stderr, "No request changes detected")
+				continue
+			}
+		}
+
+		runAndReport(only)
+	}
+}
+
+// referencedFiles returns the body, multipart and script files used by requests.
+// Relative paths are resolved against the directory of the .http file.
+func referencedFiles(httpFile string, requestsFile *httprequest.RequestsFile) []string {
+	dir := filepath.Dir(httpFile)
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	var files []string
+	for _, request := range requestsFile.Requests {
+		if request.Body != nil {
+			if request.Body.FilePath != "" && request.Body.FilePath != httprequest.StdinFile {
+				files = append(files, resolve(request.Body.FilePath))
+			}
+			for _, field := range request.Body.Multipart {
+				if field.FilePath != "" {
+					files = append(files, resolve(field.FilePath))
+				}
+			}
+		}
+		if request.ResponseHandler != nil && request.ResponseHandler.FilePath != "" {
+			files = append(files, resolve(request.ResponseHandler.FilePath))
+		}
+	}
+	return files
+}
+
+// requestSignature is a fingerprint of a request's content and its position
+type requestSignature struct {
+	index   int
+	content string
+}
+
+// requestSignatures fingerprints each request, keyed by requestKey.
+// Line numbers are ignored so edits above a request don't mark it as changed.
+func requestSignatures(requestsFile *httprequest.RequestsFile) map[string]requestSignature {
+	signatures := make(map[string]requestSignature, len(requestsFile.Requests))
+	for i, request := range requestsFile.Requests {
+		// File variables above the request affect it too
+		var variables []string
+		for _, variable := range requestsFile.VariablesBefore(request.LineNumber) {
+			variables = append(variables, variable.Name+"="+variable.Value)
+		}
+
+		request.LineNumber = 0
+		data, err := json.Marshal(struct {
+			Request   httprequest.Request
+			Variables []string
+		}{request, variables})
+		if err != nil {
+			continue
+		}
+		signatures[requestKey(i, &request)] = requestSignature{index: i, content: string(data)}
+	}
+	return signatures
+}
+
+// requestKey identifies a request across edits: by name if it has one, else by position
+func requestKey(index int, request *httprequest.Request) string {
+	if request.Name != "" {
+		return "name:" + request.Name
+	}
+	return fmt.Sprintf("index:%d", index)
+}
+
+// changedRequests returns the indexes of requests that are new or differ from before
+func changedRequests(previous, current map[string]requestSignature) map[int]bool {
+	changed := make(map[int]bool)
+	for key, signature := range current {
+		if old, existed := previous[key]; existed && old.content == signature.content {
+			continue
+		}
+		changed[signature.index] = true
+	}
+	return changed
+}
+
+// onlyHttpFileChanged returns true if the .http file is the only changed file
+func onlyHttpFileChanged(changed []string, httpFile string) bool {
+	return len(changed) == 1 && changed[0] == filepath.Clean(httpFile)
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"postie/pkg/cli"
+	"postie/pkg/listener"
+)
+
+// ListenCommands returns the listen command for capturing webhook callbacks
+func ListenCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "listen",
+		Description: "Start a temporary HTTP listener and print the requests it receives, such as webhooks",
+		Action: func(args []string) error {
+			portFlag := &cli.StringFlag{Name: "port", ShortName: "p", Usage: "Port to listen on (default: 9000)", Required: false}
+			expectFlag := &cli.StringFlag{Name: "expect", Usage: "Stop after this many requests, and fail if fewer arrive", Required: false}
+			timeoutFlag := &cli.StringFlag{Name: "timeout", Usage: "Stop after this long, e.g. 60s (default: until Ctrl+C or --expect)", Required: false}
+			statusFlag := &cli.StringFlag{Name: "status", Usage: "Status to answer requests with (default: 200)", Required: false}
+			outFlag := &cli.StringFlag{Name: "out", ShortName: "o", Usage: "Save the requests received to this JSON file", Required: false}
+			tunnelFlag := &cli.StringFlag{Name: "tunnel", Usage: "Expose the listener at a public URL: ngrok, ssh:<host> or a command with {port}", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{portFlag, expectFlag, timeoutFlag, statusFlag, outFlag, tunnelFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			addr, err := listenAddr(portFlag.Value)
+			if err != nil {
+				return err
+			}
+			var expect, status int
+			if expectFlag.Value != "" {
+				if expect, err = strconv.Atoi(expectFlag.Value); err != nil || expect < 1 {
+					return fmt.Errorf("invalid --expect %q (use a positive number)", expectFlag.Value)
+				}
+			}
+			if statusFlag.Value != "" {
+				if status, err = strconv.Atoi(statusFlag.Value); err != nil || status < 100 || status > 599 {
+					return fmt.Errorf("invalid --status %q (use an HTTP status such as 204)", statusFlag.Value)
+				}
+			}
+			var timeout time.Duration
+			if timeoutFlag.Value != "" {
+				if timeout, err = time.ParseDuration(timeoutFlag.Value); err != nil || timeout <= 0 {
+					return fmt.Errorf("invalid --timeout %q (use a duration such as 60s)", timeoutFlag.Value)
+				}
+			}
+
+			return executeListen(addr, expect, status, timeout, outFlag.Value, tunnelFlag.Value)
+		},
+	}
+}
+
+// listenAddr turns a --port or --listen value, a port or host:port, into
+// an address to listen on
+func listenAddr(value string) (string, error) {
+	if value == "" {
+		return ":9000", nil
+	}
+	if _, _, err := net.SplitHostPort(value); err == nil {
+		return value, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return "", fmt.Errorf("invalid port %q (use a port such as 9000, or host:port)", value)
+	}
+	return ":" + value, nil
+}
+
+func executeListen(addr string, expect, status int, timeout time.Duration, out, tunnelSpec string) error {
+	l, err := listener.Start(addr, status)
+	if err != nil {
+		return cli.Exit(cli.ExitConfig, err)
+	}
+	defer l.Close()
+	if tunnelSpec != "" {
+		tunnel, err := listener.StartTunnel(tunnelSpec, l.Port(), listener.DefaultTunnelTimeout)
+		if err != nil {
+			return cli.Exit(cli.ExitConfig, fmt.Errorf("--tunnel: %w", err))
+		}
+		defer tunnel.Close()
+		l.SetPublicURL(tunnel.URL)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	jsonOutput := cli.IsJSONOutput()
+	if !jsonOutput {
+		l.OnCallback(func(callback listener.Callback) {
+			fmt.Printf("\n%s %s\n", cli.Palette().Heading("=== "+callback.Time.Format(time.TimeOnly)), callback.String())
+		})
+	}
+
+	waiting := "Ctrl+C to stop"
+	if expect > 0 {
+		waiting = fmt.Sprintf("waiting for %d requests", expect)
+	}
+	if timeout > 0 {
+		waiting += fmt.Sprintf(", up to %s", timeout)
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s (%s)\n", l.LocalURL(), waiting)
+	if l.URL() != l.LocalURL() {
+		fmt.Fprintf(os.Stderr, "Public URL: %s\n", l.URL())
+	}
+
+	wait := expect
+	if wait == 0 {
+		// Until Ctrl+C or the timeout
+		wait = int(^uint(0) >> 1)
+	}
+	callbacks, _ := l.Wait(ctx, wait)
+
+	if out != "" {
+		if err := listener.Save(out, callbacks); err != nil {
+			return err
+		}
+	}
+	if jsonOutput {
+		if err := outputJSON(callbacks); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "\nReceived %d requests\n", len(callbacks))
+	}
+
+	if len(callbacks) < expect {
+		return cli.Exit(cli.ExitFailed, fmt.Errorf("received %d of %d expected requests", len(callbacks), expect))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/executor"
+	"postie/pkg/logging"
+	"postie/pkg/plugin"
+)
+
+// PluginCommands returns the plugin command for managing plugins
+func PluginCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "plugin",
+		Description: "Manage plugins that add auth schemes, variables and middleware",
+		Subcommands: map[string]*cli.Command{
+			"list":    pluginListCommand(),
+			"install": pluginInstallCommand(),
+		},
+	}
+}
+
+func pluginListCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "list",
+		Description: "List installed plugins and what they provide",
+		Action: func(args []string) error {
+			dirFlag := &cli.StringFlag{Name: "dir", Usage: "Plugins directory (default: ~/.postie/plugins)", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{dirFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			dir, err := pluginsDir(dirFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executePluginList(dir)
+		},
+	}
+}
+
+func pluginInstallCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "install",
+		Description: "Install a plugin from a directory with a plugin.json",
+		Action: func(args []string) error {
+			dirFlag := &cli.StringFlag{Name: "dir", Usage: "Plugins directory (default: ~/.postie/plugins)", Required: false}
+			forceFlag := &cli.BoolFlag{Name: "force", Usage: "Replace an installed plugin of the same name"}
+
+			// The source comes before the flags, or after them
+			var source string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				source, args = args[0], args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{dirFlag}, []*cli.BoolFlag{forceFlag})
+			if err != nil {
+				return err
+			}
+			if source == "" && fs.NArg() > 0 {
+				source = fs.Arg(0)
+			}
+			if source == "" {
+				return fmt.Errorf("plugin directory required\nUsage: postie plugin install <dir> [--force]")
+			}
+
+			dir, err := pluginsDir(dirFlag.Value)
+			if err != nil {
+				return err
+			}
+
+			installed, err := plugin.Install(source, dir, forceFlag.Value)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed plugin %s %s in %s\n", installed.Name, installed.Version, installed.Dir)
+			if provides := installed.Provides(); provides != "" {
+				fmt.Printf("Provides %s\n", provides)
+			}
+			return nil
+		},
+	}
+}
+
+// pluginsDir returns the --dir flag's value or the default plugins directory
+func pluginsDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return plugin.Dir()
+}
+
+func executePluginList(dir string) error {
+	plugins, err := plugin.Discover(dir)
+	if err != nil {
+		return err
+	}
+
+	if cli.IsJSONOutput() {
+		if plugins == nil {
+			plugins = []*plugin.Plugin{}
+		}
+		return outputJSON(plugins)
+	}
+
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins installed in %s\n", dir)
+		return nil
+	}
+
+	for _, p := range plugins {
+		fmt.Printf("%-20s %-10s %s\n", p.Name, p.Version, p.Provides())
+		if p.Description != "" {
+			fmt.Printf("  %s\n", p.Description)
+		}
+		fmt.Printf("  %s\n", p.Dir)
+	}
+	return nil
+}
+
+// registerPlugins adds the installed plugins to an executor. Plugins that
+// fail to load are reported and skipped.
+func registerPlugins(exec *executor.Executor, env string) {
+	dir, err := plugin.Dir()
+	if err != nil {
+		logging.Warn("plugins not loaded", "error", err)
+		return
+	}
+	plugins, err := plugin.Discover(dir)
+	if err != nil {
+		logging.Warn("plugins not loaded", "error", err)
+		return
+	}
+	if len(plugins) > 0 {
+		logging.Debug("plugins loaded", "dir", dir, "count", len(plugins))
+		plugin.Register(exec, plugins, env)
+	}
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// secretVariablePattern matches variable names whose values are typed without echo
+var secretVariablePattern = regexp.MustCompile(`(?i)pass(word|wd)?|secret|token|api_?key|credential`)
+
+// stdinReader is shared by prompts so buffered input isn't lost between them
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// promptVariable asks on the terminal for the value of an undefined
+// variable. Values of variables named like passwords or tokens aren't echoed.
+func promptVariable(name string) (string, bool, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", false, fmt.Errorf("not defined, and --prompt-missing needs an interactive terminal")
+	}
+
+	secret := secretVariablePattern.MatchString(name)
+	fmt.Fprintf(os.Stderr, "Value for {{%s}}: ", name)
+
+	if secret && setEcho(false) {
+		defer func() {
+			setEcho(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", secret, fmt.Errorf("failed to read value: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), secret, nil
+}
+
+// setEcho turns terminal echo on or off, reporting whether it succeeded
+func setEcho(on bool) bool {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
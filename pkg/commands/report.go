@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"postie/pkg/cli"
+	"postie/pkg/color"
+	"postie/pkg/display"
+	"postie/pkg/executor"
+)
+
+// ReportCommands returns the report command for working with saved run reports
+func ReportCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "report",
+		Description: "Work with run reports saved from 'http run --output json'",
+		Subcommands: map[string]*cli.Command{
+			"compare": reportCompareCommand(),
+		},
+	}
+}
+
+func reportCompareCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "compare",
+		Description: "Compare a run with a baseline and report regressions",
+		Action: func(args []string) error {
+			var baseline, current, threshold, minDelta string
+
+			baselineFlag := &cli.StringFlag{Name: "baseline", Value: baseline, Usage: "Baseline run report", Required: true}
+			currentFlag := &cli.StringFlag{Name: "current", Value: current, Usage: "Current run report", Required: true}
+			thresholdFlag := &cli.StringFlag{Name: "threshold", Value: threshold, Usage: "Slowdown that counts as a regression (default: 20%)", Required: false}
+			minDeltaFlag := &cli.StringFlag{Name: "min-delta", Value: minDelta, Usage: "Ignore slowdowns smaller than this (default: 50ms)", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{baselineFlag, currentFlag, thresholdFlag, minDeltaFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			threshold = thresholdFlag.Value
+			if threshold == "" {
+				threshold = "20%"
+			}
+			minDelta = minDeltaFlag.Value
+			if minDelta == "" {
+				minDelta = "50ms"
+			}
+
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(threshold), "%"), 64)
+			if err != nil || percent < 0 {
+				return fmt.Errorf("invalid --threshold %q (use a percentage such as 20%%)", threshold)
+			}
+			delta, err := time.ParseDuration(minDelta)
+			if err != nil || delta < 0 {
+				return fmt.Errorf("invalid --min-delta %q (use a duration such as 50ms)", minDelta)
+			}
+
+			return executeReportCompare(baselineFlag.Value, currentFlag.Value, executor.CompareOptions{
+				Threshold: percent / 100,
+				MinDelta:  delta,
+			})
+		},
+	}
+}
+
+func executeReportCompare(baselineFile, currentFile string, opts executor.CompareOptions) error {
+	baseline, err := executor.LoadRunReport(baselineFile)
+	if err != nil {
+		return err
+	}
+	current, err := executor.LoadRunReport(currentFile)
+	if err != nil {
+		return err
+	}
+
+	comparison := executor.CompareReports(baseline, current, opts)
+	comparison.Baseline = baselineFile
+	comparison.Current = currentFile
+
+	if cli.IsJSONOutput() {
+		if err := outputJSON(comparison); err != nil {
+			return err
+		}
+	} else {
+		printComparison(comparison)
+	}
+
+	if comparison.Failed() {
+		return fmt.Errorf("%d regression(s), %d new failure(s)",
+			comparison.Summary[executor.ChangeRegression], comparison.Summary[executor.ChangeNewFailure])
+	}
+	return nil
+}
+
+// comparisonLabels are the labels shown for each kind of change
+var comparisonLabels = map[string]string{
+	executor.ChangeUnchanged:     "ok",
+	executor.ChangeRegression:    "REGRESSION",
+	executor.ChangeImprovement:   "faster",
+	executor.ChangeStatusChanged: "STATUS",
+	executor.ChangeNewFailure:    "NEW FAILURE",
+	executor.ChangeFixed:         "fixed",
+	executor.ChangeAdded:         "added",
+	executor.ChangeRemoved:       "removed",
+}
+
+// comparisonColors color the labels of changes worth noticing
+var comparisonColors = map[string]func(*color.Palette, string) string{
+	executor.ChangeRegression:    (*color.Palette).Failure,
+	executor.ChangeImprovement:   (*color.Palette).Success,
+	executor.ChangeStatusChanged: (*color.Palette).Failure,
+	executor.ChangeNewFailure:    (*color.Palette).Failure,
+	executor.ChangeFixed:         (*color.Palette).Success,
+	executor.ChangeAdded:         (*color.Palette).Added,
+	executor.ChangeRemoved:       (*color.Palette).Removed,
+}
+
+func printComparison(comparison *executor.ReportComparison) {
+	palette := cli.Palette()
+	fmt.Printf("Comparing %s (baseline) with %s\n\n", comparison.Baseline, comparison.Current)
+
+	width := 0
+	for _, entry := range comparison.Requests {
+		width = max(width, len(entry.Request))
+	}
+
+	for _, entry := range comparison.Requests {
+		label := fmt.Sprintf("%-12s", comparisonLabels[entry.Change])
+		if paint, ok := comparisonColors[entry.Change]; ok {
+			label = paint(palette, label)
+		}
+		fmt.Printf("  %s %-*s  %s\n", label, width, entry.Request, describeComparison(entry))
+	}
+
+	summary := comparison.Summary
+	fmt.Printf("\n%d regression(s), %d new failure(s), %d status change(s), %d fixed, %d added, %d removed\n",
+		summary[executor.ChangeRegression], summary[executor.ChangeNewFailure], summary[executor.ChangeStatusChanged],
+		summary[executor.ChangeFixed], summary[executor.ChangeAdded], summary[executor.ChangeRemoved])
+}
+
+// describeComparison formats the status and latency change of a request
+func describeComparison(entry *executor.RequestComparison) string {
+	switch entry.Change {
+	case executor.ChangeAdded:
+		return fmt.Sprintf("%s, %s", formatStatusCode(entry.CurrentStatus), display.Milliseconds(entry.CurrentMs))
+	case executor.ChangeRemoved:
+		return fmt.Sprintf("%s, %s", formatStatusCode(entry.BaselineStatus), display.Milliseconds(entry.BaselineMs))
+	}
+
+	arrow := display.Glyphs().Arrow
+	description := fmt.Sprintf("%s %s %s (%s, %+.0f%%)", display.Milliseconds(entry.BaselineMs), arrow, display.Milliseconds(entry.CurrentMs),
+		display.Delta(time.Duration(entry.DeltaMs)*time.Millisecond), entry.DeltaPercent)
+	if entry.BaselineStatus != entry.CurrentStatus {
+		description = fmt.Sprintf("%s %s %s, %s", formatStatusCode(entry.BaselineStatus), arrow, formatStatusCode(entry.CurrentStatus), description)
+	}
+	if entry.Error != "" {
+		description += ": " + entry.Error
+	}
+	return description
+}
+
+// formatStatusCode formats a status code, which is 0 if the request failed
+func formatStatusCode(code int) string {
+	if code == 0 {
+		return "error"
+	}
+	return strconv.Itoa(code)
+}
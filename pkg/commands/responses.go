@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/display"
+	"postie/pkg/executor"
+	"postie/pkg/middleware"
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+)
+
+// ResponsesCommands returns the responses command for managing saved responses
+func ResponsesCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "responses",
+		Description: "Manage saved responses",
+		Subcommands: map[string]*cli.Command{
+			"gc":     responsesGCCommand(),
+			"replay": responsesReplayCommand(),
+		},
+	}
+}
+
+func responsesGCCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "gc",
+		Description: "Delete saved responses beyond the retention limits",
+		Action: func(args []string) error {
+			var dir, maxAge, maxSize, maxHistoryValue string
+			var dryRun bool
+
+			dirFlag := &cli.StringFlag{Name: "dir", Value: dir, Usage: "Responses directory (default: .http-responses)", Required: false}
+			maxAgeFlag := &cli.StringFlag{Name: "max-age", Value: maxAge, Usage: "Delete responses older than this, e.g. 7d or 12h", Required: false}
+			maxSizeFlag := &cli.StringFlag{Name: "max-size", Value: maxSize, Usage: "Maximum total size, e.g. 100MB", Required: false}
+			maxHistoryFlag := &cli.StringFlag{Name: "max-history", Value: maxHistoryValue, Usage: "Responses to keep per request (0 for no limit)", Required: false}
+			dryRunFlag := &cli.BoolFlag{Name: "dry-run", Value: dryRun, Usage: "Show what would be deleted without deleting it"}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{dirFlag, maxAgeFlag, maxSizeFlag, maxHistoryFlag}, []*cli.BoolFlag{dryRunFlag})
+			if err != nil {
+				return err
+			}
+
+			ctx, err := context.NewManager().Load()
+			if err != nil {
+				return err
+			}
+
+			// Flags override the limits saved in the context
+			if dirFlag.Value != "" {
+				ctx.ResponsesDir = dirFlag.Value
+			}
+			if maxAgeFlag.Value != "" {
+				ctx.ResponsesMaxAge = maxAgeFlag.Value
+			}
+			if maxSizeFlag.Value != "" {
+				ctx.ResponsesMaxSize = maxSizeFlag.Value
+			}
+			maxHistory := -1
+			if maxHistoryFlag.Value != "" {
+				maxHistory, err = strconv.Atoi(maxHistoryFlag.Value)
+				if err != nil || maxHistory < 0 {
+					return fmt.Errorf("invalid --max-history %q", maxHistoryFlag.Value)
+				}
+			}
+
+			config, err := storageConfig(ctx, ctx.ResponsesDir)
+			if err != nil {
+				return err
+			}
+			if maxHistory >= 0 {
+				config.MaxHistoryPerReq = maxHistory
+			}
+
+			return executeResponsesGC(config, dryRunFlag.Value)
+		},
+	}
+}
+
+func executeResponsesGC(config *responses.StorageConfig, dryRun bool) error {
+	result, err := responses.NewStorage(config).Collect(dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	for _, path := range result.Removed {
+		fmt.Printf("%s %s\n", verb, path)
+	}
+	fmt.Printf("%s %d response(s), %s. %d response(s), %s remaining in %s\n",
+		verb, len(result.Removed), display.Size(result.FreedBytes),
+		result.Remaining, display.Size(result.RemainingBytes), config.BaseDir)
+
+	return nil
+}
+
+func responsesReplayCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "replay",
+		Description: "Re-send the request of a saved response exactly as it was sent",
+		Action: func(args []string) error {
+			var verbose bool
+			verboseFlag := &cli.BoolFlag{Name: "verbose", ShortName: "v", Value: verbose, Usage: "Show request details"}
+			yesIKnowFlag := &cli.BoolFlag{Name: "yes-i-know", Usage: "Send the request even if its host isn't one of the context's safe hosts"}
+
+			// The response file comes before the flags, or after them
+			var file string
+			if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				file, args = args[0], args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{}, []*cli.BoolFlag{verboseFlag, yesIKnowFlag})
+			if err != nil {
+				return err
+			}
+			if file == "" && fs.NArg() > 0 {
+				file = fs.Arg(0)
+			}
+			if file == "" {
+				return fmt.Errorf("response file is required, e.g. postie responses replay .http-responses/login/2024-01-15T103000.200.json")
+			}
+
+			hosts, err := loadSafeHosts(yesIKnowFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executeResponsesReplay(file, verboseFlag.Value, hosts)
+		},
+	}
+}
+
+// executeResponsesReplay re-sends a saved request. safeHosts are the hosts
+// it may be sent to with POST, PUT, PATCH or DELETE (nil for any).
+func executeResponsesReplay(file string, verbose bool, safeHosts []string) error {
+	stored, err := responses.NewStorage(nil).Load(file)
+	if err != nil {
+		return err
+	}
+
+	if replayRedacted(stored) {
+		return fmt.Errorf("the saved request has redacted secrets; save responses with --show-secrets to replay them")
+	}
+
+	exec := executor.NewExecutor(nil, nil)
+	defer exec.Close()
+	if len(safeHosts) > 0 {
+		exec.AddHook(middleware.HostSafetyHook(safeHosts))
+	}
+
+	result, err := exec.ExecuteRequest(stored.Request())
+	if err != nil && result == nil {
+		return err
+	}
+
+	results := []*executor.ExecutionResult{result}
+	if cli.IsJSONOutput() {
+		if err := outputJSON(executor.NewRunReport(file, "", results)); err != nil {
+			return err
+		}
+		return runError(results, false)
+	}
+	formatter := executor.NewFormatter(verbose)
+	formatter.SetPalette(cli.Palette())
+	fmt.Print(formatter.FormatResult(result, 1))
+	return runError(results, false)
+}
+
+// replayRedacted reports whether secrets were masked in a saved request
+func replayRedacted(stored *responses.StoredResponse) bool {
+	if strings.Contains(stored.RequestURL, redact.Mask) || strings.Contains(stored.RequestBody, redact.Mask) {
+		return true
+	}
+	for _, value := range stored.RequestHeaders {
+		if strings.Contains(value, redact.Mask) {
+			return true
+		}
+	}
+	return false
+}
+
+// storageConfig builds the response storage configuration from the context's
+// retention limits. An empty dir uses the default responses directory.
+func storageConfig(ctx *context.Context, dir string) (*responses.StorageConfig, error) {
+	config := responses.DefaultStorageConfig()
+	if dir != "" {
+		config.BaseDir = dir
+	}
+	if ctx.ResponsesMaxHistory > 0 {
+		config.MaxHistoryPerReq = ctx.ResponsesMaxHistory
+	}
+
+	if ctx.ResponsesMaxAge != "" {
+		age, err := responses.ParseAge(ctx.ResponsesMaxAge)
+		if err != nil {
+			return nil, err
+		}
+		config.MaxAge = age
+	}
+
+	if ctx.ResponsesMaxSize != "" {
+		size, err := responses.ParseSize(ctx.ResponsesMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		config.MaxTotalSize = size
+	}
+
+	return config, nil
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/context"
+	"postie/pkg/display"
+	"postie/pkg/session"
+)
+
+// SessionCommands returns the session command for managing saved logins
+func SessionCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "session",
+		Description: "Manage sessions saved by # @session login requests",
+		Subcommands: map[string]*cli.Command{
+			"list":  sessionListCommand(),
+			"clear": sessionClearCommand(),
+		},
+	}
+}
+
+// sessionStore returns the session store of --env, the context's
+// environment, or development
+func sessionStore(env string) (*session.Store, error) {
+	if env == "" {
+		ctx, err := context.NewManager().Load()
+		if err != nil {
+			return nil, err
+		}
+		env = ctx.Environment
+	}
+	if env == "" {
+		env = "development"
+	}
+	return session.NewStore(".", env), nil
+}
+
+func sessionListCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "list",
+		Description: "List saved sessions and their values",
+		Action: func(args []string) error {
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Usage: "Environment the sessions belong to", Required: false}
+
+			_, err := cli.ParseFlags(args, []*cli.StringFlag{envFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+
+			store, err := sessionStore(envFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executeSessionList(store)
+		},
+	}
+}
+
+func executeSessionList(store *session.Store) error {
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if cli.IsJSONOutput() {
+		if sessions == nil {
+			sessions = []*session.Session{}
+		}
+		return outputJSON(sessions)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Printf("No sessions saved for environment '%s'\n", store.Environment())
+		return nil
+	}
+
+	fmt.Printf("Sessions for environment '%s':\n", store.Environment())
+	for _, saved := range sessions {
+		status := "saved " + display.Since(saved.CreatedAt)
+		if saved.Expired() {
+			status += ", expired"
+		} else if saved.ExpiresAt != nil {
+			status += ", expires " + display.Since(*saved.ExpiresAt)
+		}
+
+		// Values are usually credentials, so only their names are shown
+		keys := make([]string, 0, len(saved.Values))
+		for key := range saved.Values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("  %s (%s)\n", saved.Name, status)
+		if len(keys) > 0 {
+			fmt.Printf("    values: %s\n", strings.Join(keys, ", "))
+		}
+	}
+	return nil
+}
+
+func sessionClearCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "clear",
+		Description: "Delete saved sessions so their login requests run again",
+		Action: func(args []string) error {
+			envFlag := &cli.StringFlag{Name: "env", ShortName: "e", Usage: "Environment the sessions belong to", Required: false}
+
+			// Session names come before the flags, or after them
+			var names []string
+			for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				names = append(names, args[0])
+				args = args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{envFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+			names = append(names, fs.Args()...)
+
+			store, err := sessionStore(envFlag.Value)
+			if err != nil {
+				return err
+			}
+			return executeSessionClear(store, names)
+		},
+	}
+}
+
+func executeSessionClear(store *session.Store, names []string) error {
+	// Without names, every session of the environment is cleared
+	if len(names) == 0 {
+		sessions, err := store.List()
+		if err != nil {
+			return err
+		}
+		for _, saved := range sessions {
+			names = append(names, saved.Name)
+		}
+	}
+
+	for _, name := range names {
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared session %s\n", name)
+	}
+	if len(names) == 0 {
+		fmt.Printf("No sessions saved for environment '%s'\n", store.Environment())
+	}
+	return nil
+}
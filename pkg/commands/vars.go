@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"postie/pkg/cli"
+	"postie/pkg/environment"
+	"postie/pkg/httprequest"
+)
+
+// VarsCommands returns the vars command for inspecting variable usage
+func VarsCommands() *cli.Command {
+	return &cli.Command{
+		Name:        "vars",
+		Description: "Inspect the variables used by .http files",
+		Subcommands: map[string]*cli.Command{
+			"report": varsReportCommand(),
+		},
+	}
+}
+
+func varsReportCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "report",
+		Description: "List each variable, where it is used and which environments define it",
+		Action: func(args []string) error {
+			var envFile, privateEnvFile string
+
+			envFileFlag := &cli.StringFlag{Name: "env-file", Value: envFile, Usage: "Path to environment file", Required: false}
+			privateEnvFileFlag := &cli.StringFlag{Name: "private-env-file", Value: privateEnvFile, Usage: "Path to private environment file", Required: false}
+
+			// Paths come before the flags, or after them
+			var paths []string
+			for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				paths = append(paths, args[0])
+				args = args[1:]
+			}
+			fs, err := cli.ParseFlags(args, []*cli.StringFlag{envFileFlag, privateEnvFileFlag}, []*cli.BoolFlag{})
+			if err != nil {
+				return err
+			}
+			paths = append(paths, fs.Args()...)
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+
+			envFile = envFileFlag.Value
+			if envFile == "" {
+				envFile = "http-client.env.json"
+			}
+			privateEnvFile = privateEnvFileFlag.Value
+			if privateEnvFile == "" {
+				privateEnvFile = "http-client.private.env.json"
+			}
+
+			return executeVarsReport(paths, envFile, privateEnvFile)
+		},
+	}
+}
+
+// variableUsage describes one variable in a 'vars report'
+type variableUsage struct {
+	Name      string   `json:"name"`
+	UsedIn    []string `json:"usedIn"`              // file:line of each reference
+	FileVars  []string `json:"fileVars,omitempty"`  // file:line of @name = value definitions
+	SetBy     []string `json:"setBy,omitempty"`     // file:line of response handlers that set it
+	DefinedIn []string `json:"definedIn,omitempty"` // Environments that define it
+	MissingIn []string `json:"missingIn,omitempty"` // Environments it's needed in but not defined
+}
+
+func executeVarsReport(paths []string, envFile, privateEnvFile string) error {
+	scans, err := scanHTTPFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	publicEnv, privateEnv, err := loadEnvironmentPair(envFile, privateEnvFile)
+	if err != nil {
+		return err
+	}
+	usages := variableUsages(scans, publicEnv, privateEnv)
+
+	if cli.IsJSONOutput() {
+		if usages == nil {
+			usages = []variableUsage{}
+		}
+		return outputJSON(usages)
+	}
+
+	if len(usages) == 0 {
+		fmt.Println("No variables are referenced.")
+		return nil
+	}
+
+	missing := 0
+	for _, usage := range usages {
+		fmt.Println(usage.Name)
+		fmt.Printf("  used in:    %s\n", strings.Join(usage.UsedIn, ", "))
+		if len(usage.FileVars) > 0 {
+			fmt.Printf("  @variable:  %s\n", strings.Join(usage.FileVars, ", "))
+		}
+		if len(usage.SetBy) > 0 {
+			fmt.Printf("  set by:     %s\n", strings.Join(usage.SetBy, ", "))
+		}
+		if len(usage.DefinedIn) > 0 {
+			fmt.Printf("  defined in: %s\n", strings.Join(usage.DefinedIn, ", "))
+		}
+		if len(usage.MissingIn) > 0 {
+			fmt.Printf("  missing in: %s\n", strings.Join(usage.MissingIn, ", "))
+			missing++
+		}
+	}
+	fmt.Printf("\n%d variable(s), %d missing from at least one environment\n", len(usages), missing)
+	return nil
+}
+
+// variableUsages cross-references the variables used in .http files with
+// the environments that define them. A variable is missing from an
+// environment only if some file uses it without defining it itself and no
+// response handler sets it.
+func variableUsages(scans []fileScan, publicEnv, privateEnv environment.EnvironmentFile) []variableUsage {
+	envNames, resolved := resolveEnvironments(publicEnv, privateEnv)
+
+	byName := make(map[string]*variableUsage)
+	needsEnv := make(map[string]bool)
+	for _, scan := range scans {
+		for _, ref := range scan.Refs {
+			if strings.HasPrefix(ref.Name, "$") {
+				continue
+			}
+			usage, ok := byName[ref.Name]
+			if !ok {
+				usage = &variableUsage{Name: ref.Name}
+				byName[ref.Name] = usage
+			}
+			usage.UsedIn = append(usage.UsedIn, fmt.Sprintf("%s:%d", scan.File, ref.Line))
+			if _, local := scan.FileVars[ref.Name]; !local {
+				needsEnv[ref.Name] = true
+			}
+		}
+	}
+
+	for _, scan := range scans {
+		for name, line := range scan.FileVars {
+			if usage, ok := byName[name]; ok {
+				usage.FileVars = append(usage.FileVars, fmt.Sprintf("%s:%d", scan.File, line))
+			}
+		}
+		for name, line := range scan.ScriptVars {
+			if usage, ok := byName[name]; ok {
+				usage.SetBy = append(usage.SetBy, fmt.Sprintf("%s:%d", scan.File, line))
+			}
+		}
+	}
+
+	usages := make([]variableUsage, 0, len(byName))
+	for name, usage := range byName {
+		sort.Strings(usage.FileVars)
+		sort.Strings(usage.SetBy)
+		for _, env := range envNames {
+			if resolved[env] != nil && resolved[env].HasVariable(name) {
+				usage.DefinedIn = append(usage.DefinedIn, env)
+			} else if needsEnv[name] && len(usage.SetBy) == 0 {
+				usage.MissingIn = append(usage.MissingIn, env)
+			}
+		}
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Name < usages[j].Name })
+	return usages
+}
+
+// resolveEnvironments resolves every environment except the shared one.
+// Environments that fail to resolve are left out of the map.
+func resolveEnvironments(publicEnv, privateEnv environment.EnvironmentFile) ([]string, map[string]*environment.ResolvedEnvironment) {
+	var envNames []string
+	for _, name := range environment.NewLoader(".").GetAvailableEnvironments(publicEnv, privateEnv) {
+		if name != environment.BaseEnvironment {
+			envNames = append(envNames, name)
+		}
+	}
+	sort.Strings(envNames)
+
+	resolver := environment.NewResolver()
+	resolved := make(map[string]*environment.ResolvedEnvironment)
+	for _, name := range envNames {
+		// Environments that fail to resolve are reported by 'env lint'
+		if env, err := resolver.Resolve(publicEnv, privateEnv, name); err == nil {
+			resolved[name] = env
+		}
+	}
+	return envNames, resolved
+}
+
+// fileScan is the variable scan of one .http file
+type fileScan struct {
+	File string
+	*httprequest.VariableScan
+}
+
+// scanHTTPFiles scans .http files for variables. Directories are searched
+// recursively.
+func scanHTTPFiles(paths []string) ([]fileScan, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found, err := findHTTPFiles(path, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", path, err)
+		}
+		files = append(files, found...)
+	}
+
+	scans := make([]fileScan, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		scans = append(scans, fileScan{File: file, VariableScan: httprequest.ScanVariables(string(content))})
+	}
+	return scans, nil
+}
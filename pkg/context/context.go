@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"postie/pkg/atomicfile"
 )
 
 // Context represents the saved context configuration for a directory
@@ -15,6 +17,15 @@ type Context struct {
 	PrivateEnvFile string `json:"privateEnvFile,omitempty"`
 	SaveResponses  bool   `json:"saveResponses,omitempty"`
 	ResponsesDir   string `json:"responsesDir,omitempty"`
+
+	// Retention limits for saved responses
+	ResponsesMaxAge     string `json:"responsesMaxAge,omitempty"`     // e.g. "7d"
+	ResponsesMaxSize    string `json:"responsesMaxSize,omitempty"`    // e.g. "100MB"
+	ResponsesMaxHistory int    `json:"responsesMaxHistory,omitempty"` // Responses kept per request
+
+	// Hosts POST, PUT, PATCH and DELETE requests may be sent to without
+	// --yes-i-know, such as localhost or *.staging.example.com (empty for any)
+	SafeHosts []string `json:"safeHosts,omitempty"`
 }
 
 // Manager handles reading and writing context files
@@ -66,7 +77,7 @@ func (m *Manager) Save(ctx *Context) error {
 		return fmt.Errorf("failed to marshal context: %w", err)
 	}
 
-	if err := os.WriteFile(m.contextFile, data, 0644); err != nil {
+	if err := atomicfile.WriteFile(m.contextFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write context file: %w", err)
 	}
 
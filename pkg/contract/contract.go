@@ -0,0 +1,195 @@
+// Package contract checks that a provider API still meets the expectations
+// its consumers recorded: for each interaction, a request and what its
+// response must look like. It is a lightweight take on consumer-driven
+// contracts, as popularized by Pact.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/schema"
+	"postie/pkg/scripting"
+)
+
+// Contract is the set of interactions a consumer expects a provider to
+// support
+type Contract struct {
+	Consumer     string         `json:"consumer,omitempty"`
+	Provider     string         `json:"provider,omitempty"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response the consumer expects to it
+type Interaction struct {
+	Description string   `json:"description"`
+	Request     Request  `json:"request"`
+	Response    Expected `json:"response"`
+}
+
+// Request is the request of an interaction. The path, with its query, is
+// appended to the provider's base URL.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"` // JSON, or a JSON string holding another body
+}
+
+// Expected is what a response must look like to meet the contract
+type Expected struct {
+	Status  int                    `json:"status"`
+	Headers map[string]string      `json:"headers,omitempty"` // Parameters such as charset are ignored unless given
+	Schema  map[string]interface{} `json:"schema,omitempty"`  // JSON Schema the body must match
+	Fields  map[string]interface{} `json:"fields,omitempty"`  // Values required at JSON paths such as $.id
+}
+
+// Load reads and checks a contract file
+func Load(path string) (*Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract: %w", err)
+	}
+
+	var contract Contract
+	if err := json.Unmarshal(data, &contract); err != nil {
+		return nil, fmt.Errorf("failed to parse contract %s: %w", path, err)
+	}
+	if err := contract.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid contract %s: %w", path, err)
+	}
+	return &contract, nil
+}
+
+// Validate checks that every interaction has a request and an expected
+// status
+func (c *Contract) Validate() error {
+	if len(c.Interactions) == 0 {
+		return fmt.Errorf("no interactions")
+	}
+	for i, interaction := range c.Interactions {
+		name := interaction.Description
+		if name == "" {
+			name = fmt.Sprintf("interaction %d", i+1)
+		}
+		switch {
+		case interaction.Request.Method == "":
+			return fmt.Errorf("%s: request method is required", name)
+		case !strings.HasPrefix(interaction.Request.Path, "/"):
+			return fmt.Errorf("%s: request path must start with /", name)
+		case interaction.Response.Status < 100 || interaction.Response.Status > 599:
+			return fmt.Errorf("%s: expected response status is required", name)
+		}
+	}
+	return nil
+}
+
+// Save writes the contract as indented JSON
+func (c *Contract) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal contract: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write contract: %w", err)
+	}
+	return nil
+}
+
+// Add adds an interaction, replacing one with the same description
+func (c *Contract) Add(interaction *Interaction) {
+	for i, existing := range c.Interactions {
+		if existing.Description == interaction.Description {
+			c.Interactions[i] = interaction
+			return
+		}
+	}
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// BodyText returns the request body to send: the text of a JSON string,
+// or the JSON itself
+func (r *Request) BodyText() string {
+	if len(r.Body) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(r.Body, &text) == nil {
+		return text
+	}
+	return string(r.Body)
+}
+
+// Check returns the ways a response breaks the expectation, such as
+// "status: expected 200, got 404"
+func (e *Expected) Check(status int, header http.Header, body []byte) []string {
+	var violations []string
+	if status != e.Status {
+		violations = append(violations, fmt.Sprintf("status: expected %d, got %d", e.Status, status))
+	}
+
+	for _, name := range sortedKeys(e.Headers) {
+		if actual := header.Get(name); !headerMatches(e.Headers[name], actual) {
+			violations = append(violations, fmt.Sprintf("header %s: expected %q, got %q", name, e.Headers[name], actual))
+		}
+	}
+
+	if e.Schema == nil && len(e.Fields) == 0 {
+		return violations
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return append(violations, "body: not valid JSON")
+	}
+	if e.Schema != nil {
+		for _, violation := range schema.New(e.Schema).Validate(decoded) {
+			violations = append(violations, "body "+violation.Error())
+		}
+	}
+
+	for _, path := range sortedKeys(e.Fields) {
+		actual, found, err := scripting.EvalJSONPath(decoded, path)
+		expected, _ := json.Marshal(e.Fields[path])
+		switch {
+		case err != nil:
+			violations = append(violations, fmt.Sprintf("body %s: %v", path, err))
+		case !found:
+			violations = append(violations, fmt.Sprintf("body %s: expected %s, but it is missing", path, expected))
+		default:
+			if got, _ := json.Marshal(actual); string(got) != string(expected) {
+				violations = append(violations, fmt.Sprintf("body %s: expected %s, got %s", path, expected, got))
+			}
+		}
+	}
+	return violations
+}
+
+// headerMatches compares a header with its expected value, ignoring
+// parameters such as charset unless the expected value has them
+func headerMatches(expected, actual string) bool {
+	if strings.EqualFold(expected, actual) {
+		return true
+	}
+	if strings.Contains(expected, ";") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(actual)
+	return err == nil && strings.EqualFold(mediaType, expected)
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
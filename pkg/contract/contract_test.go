@@ -0,0 +1,295 @@
+package contract
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+)
+
+func TestExpectedCheck(t *testing.T) {
+	expected := Expected{
+		Status:  200,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"id", "name"},
+			"properties": map[string]interface{}{"id": map[string]interface{}{"type": "integer"}},
+		},
+		Fields: map[string]interface{}{"$.role": "admin"},
+	}
+	header := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+
+	if violations := expected.Check(200, header, []byte(`{"id": 1, "name": "Ann", "role": "admin"}`)); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+
+	violations := expected.Check(404, http.Header{"Content-Type": {"text/plain"}}, []byte(`{"id": "1", "role": "user"}`))
+	joined := strings.Join(violations, "\n")
+	for _, want := range []string{"status: expected 200, got 404", "header Content-Type", "name", "$.id", `body $.role: expected "admin", got "user"`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected a violation mentioning %q, got %v", want, violations)
+		}
+	}
+
+	if violations := expected.Check(200, header, []byte("not json")); len(violations) != 1 || violations[0] != "body: not valid JSON" {
+		t.Errorf("Expected the body to be rejected, got %v", violations)
+	}
+}
+
+func TestContractValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract Contract
+		wantErr  string
+	}{
+		{"empty", Contract{}, "no interactions"},
+		{"no method", Contract{Interactions: []*Interaction{{Request: Request{Path: "/"}, Response: Expected{Status: 200}}}}, "method"},
+		{"relative path", Contract{Interactions: []*Interaction{{Request: Request{Method: "GET", Path: "users"}, Response: Expected{Status: 200}}}}, "path"},
+		{"no status", Contract{Interactions: []*Interaction{{Request: Request{Method: "GET", Path: "/"}}}}, "status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.contract.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.RequestURI() {
+		case "/users/1":
+			w.Write([]byte(`{"id": 1, "name": "Ann"}`))
+		case "/users?page=2":
+			body, _ := io.ReadAll(r.Body)
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := &Contract{Interactions: []*Interaction{
+		{Description: "get user", Request: Request{Method: "get", Path: "/users/1"}, Response: Expected{Status: 200, Fields: map[string]interface{}{"$.name": "Ann"}}},
+		{Description: "echo", Request: Request{Method: "POST", Path: "/users?page=2", Body: json.RawMessage(`{"id": 2}`)}, Response: Expected{Status: 200, Fields: map[string]interface{}{"$.id": 2}}},
+		{Description: "missing", Request: Request{Method: "GET", Path: "/gone"}, Response: Expected{Status: 200}},
+	}}
+
+	results := Verify(executor.NewExecutor(nil, nil), c, server.URL+"/", []httprequest.Header{{Name: "Authorization", Value: "Bearer token"}})
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if !results[0].Passed || results[0].Method != "GET" || results[0].URL != server.URL+"/users/1" {
+		t.Errorf("Expected get user to pass, got %+v", results[0])
+	}
+	if !results[1].Passed {
+		t.Errorf("Expected echo to pass, got %+v", results[1])
+	}
+	if results[2].Passed || len(results[2].Violations) != 1 || results[2].StatusCode != 404 {
+		t.Errorf("Expected missing to break the contract, got %+v", results[2])
+	}
+
+	unreachable := Verify(executor.NewExecutor(nil, nil), c, "http://127.0.0.1:1", nil)
+	if unreachable[0].Passed || unreachable[0].Error == "" {
+		t.Errorf("Expected an error for an unreachable provider, got %+v", unreachable[0])
+	}
+}
+
+func TestFromStoredResponse(t *testing.T) {
+	stored := &responses.StoredResponse{
+		RequestName:    "create user",
+		RequestURL:     "https://api.example.com/users?notify=true",
+		Method:         "POST",
+		RequestHeaders: map[string]string{"Accept": "application/json", "Authorization": "Bearer " + redact.Mask},
+		RequestBody:    `{"name": "Ann"}`,
+		StatusCode:     201,
+		ContentType:    "application/json; charset=utf-8",
+		Body:           `{"id": 7, "name": "Ann"}`,
+	}
+
+	interaction, err := FromStoredResponse(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interaction.Description != "create user" || interaction.Request.Path != "/users?notify=true" {
+		t.Errorf("Unexpected interaction %+v", interaction)
+	}
+	if _, ok := interaction.Request.Headers["Authorization"]; ok || interaction.Request.Headers["Accept"] != "application/json" {
+		t.Errorf("Expected only unredacted headers, got %v", interaction.Request.Headers)
+	}
+	if interaction.Request.BodyText() != `{"name": "Ann"}` {
+		t.Errorf("Unexpected body %q", interaction.Request.BodyText())
+	}
+	if interaction.Response.Status != 201 || interaction.Response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Unexpected expectation %+v", interaction.Response)
+	}
+	if violations := interaction.Response.Check(201, http.Header{"Content-Type": {"application/json"}}, []byte(`{"id": 8, "name": "Bo"}`)); len(violations) != 0 {
+		t.Errorf("Expected a body of the same shape to pass, got %v", violations)
+	}
+	if violations := interaction.Response.Check(201, http.Header{"Content-Type": {"application/json"}}, []byte(`{"id": "8"}`)); len(violations) == 0 {
+		t.Error("Expected a body of another shape to fail")
+	}
+
+	// Saved contracts load back the same
+	path := filepath.Join(t.TempDir(), "users.contract.json")
+	c := &Contract{Consumer: "web"}
+	c.Add(interaction)
+	c.Add(interaction)
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Interactions) != 1 || loaded.Consumer != "web" {
+		t.Errorf("Unexpected contract %+v", loaded)
+	}
+}
+
+func TestPactContract(t *testing.T) {
+	var pact Pact
+	err := json.Unmarshal([]byte(`{
+		"consumer": {"name": "web"},
+		"provider": {"name": "users-api"},
+		"interactions": [
+			{
+				"description": "list users",
+				"request": {"method": "GET", "path": "/users", "query": "page=2"},
+				"response": {
+					"status": 200,
+					"body": {"users": [{"id": 1, "name": "Ann"}], "total": 1},
+					"matchingRules": {"$.body.users[*].id": {"match": "type"}}
+				}
+			},
+			{
+				"description": "create user",
+				"request": {"method": "POST", "path": "/users", "query": {"notify": ["true"]}, "body": {"name": "Bo"}},
+				"response": {
+					"status": 201,
+					"headers": {"Content-Type": "application/json"},
+					"body": {"id": 2, "name": "Bo", "first name": "Bo"},
+					"matchingRules": {"body": {"$.id": {"matchers": [{"match": "type"}]}}}
+				}
+			}
+		]
+	}`), &pact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := pact.Contract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Consumer != "web" || c.Provider != "users-api" || len(c.Interactions) != 2 {
+		t.Fatalf("Unexpected contract %+v", c)
+	}
+
+	list, create := c.Interactions[0], c.Interactions[1]
+	if list.Request.Path != "/users?page=2" || create.Request.Path != "/users?notify=true" {
+		t.Errorf("Unexpected paths %q and %q", list.Request.Path, create.Request.Path)
+	}
+	wantList := map[string]interface{}{"$.users[0].name": "Ann", "$.total": 1.0}
+	if len(list.Response.Fields) != len(wantList) || list.Response.Fields["$.users[0].name"] != "Ann" || list.Response.Fields["$.total"] != 1.0 {
+		t.Errorf("Expected fields %v, got %v", wantList, list.Response.Fields)
+	}
+	if _, ok := create.Response.Fields["$.id"]; ok || create.Response.Fields["$['first name']"] != "Bo" {
+		t.Errorf("Unexpected fields %v", create.Response.Fields)
+	}
+
+	// Matched by type, a different id passes but a different name doesn't
+	header := http.Header{"Content-Type": {"application/json"}}
+	if violations := create.Response.Check(201, header, []byte(`{"id": 9, "name": "Bo", "first name": "Bo"}`)); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+	if violations := create.Response.Check(201, header, []byte(`{"id": "9", "name": "Cy", "first name": "Bo"}`)); len(violations) != 2 {
+		t.Errorf("Expected 2 violations, got %v", violations)
+	}
+}
+
+func TestPactFromStoredResponse(t *testing.T) {
+	interaction, err := PactFromStoredResponse(&responses.StoredResponse{
+		RequestURL:     "https://api.example.com/users?page=2",
+		Method:         "GET",
+		RequestHeaders: map[string]string{"Authorization": redact.Mask},
+		StatusCode:     200,
+		ContentType:    "application/json",
+		Body:           `[{"id": 1}]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interaction.Description != "GET /users" || interaction.Request.Path != "/users" || string(interaction.Request.Query) != `"page=2"` {
+		t.Errorf("Unexpected request %+v", interaction.Request)
+	}
+	if interaction.Request.Headers != nil {
+		t.Errorf("Expected redacted headers to be left out, got %v", interaction.Request.Headers)
+	}
+	if string(interaction.Response.Body) != `[{"id": 1}]` || interaction.Response.MatchingRules["$.body"] == nil {
+		t.Errorf("Unexpected response %+v", interaction.Response)
+	}
+
+	// Exported Pacts import back
+	pact := NewPact("web", "users-api")
+	pact.Add(interaction)
+	c, err := pact.Contract()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0]; got.Request.Path != "/users?page=2" || len(got.Response.Fields) != 0 || got.Response.Schema == nil {
+		t.Errorf("Unexpected interaction %+v", got)
+	}
+}
+
+func TestContractHTTPFile(t *testing.T) {
+	c := &Contract{Consumer: "web", Provider: "users-api", Interactions: []*Interaction{{
+		Description: "create user",
+		Request:     Request{Method: "post", Path: "/users", Body: json.RawMessage(`{"name":"Bo"}`)},
+		Response: Expected{
+			Status:  201,
+			Headers: map[string]string{"Content-Type": "application/json", "X-Version": "2"},
+			Schema:  map[string]interface{}{"type": "object"},
+			Fields:  map[string]interface{}{"$.note": "100%}"},
+		},
+	}}}
+
+	text := c.HTTPFile()
+	parsed, err := httprequest.ParseFile("users.http", text)
+	if err != nil {
+		t.Fatalf("Generated file doesn't parse: %v\n%s", err, text)
+	}
+	if len(parsed.Requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(parsed.Requests))
+	}
+
+	request := parsed.Requests[0]
+	if request.Method != "POST" || request.URL.Raw != "{{baseUrl}}/users" || request.Metadata[httprequest.DirectiveExpect] != "201" {
+		t.Errorf("Unexpected request %s %s %v", request.Method, request.URL.Raw, request.Metadata)
+	}
+	if request.Body == nil || !strings.Contains(request.Body.Content, `"name": "Bo"`) {
+		t.Errorf("Unexpected body %+v", request.Body)
+	}
+	if len(request.Assertions) != 2 || request.Assertions[0].Operator != httprequest.AssertMatches || request.Assertions[1].Value != "2" {
+		t.Errorf("Unexpected assertions %v", request.Assertions)
+	}
+	if request.ResponseHandler == nil || !strings.Contains(request.ResponseHandler.Script, `client.assertSchema({"type":"object"})`) {
+		t.Errorf("Unexpected handler %+v", request.ResponseHandler)
+	}
+}
@@ -0,0 +1,105 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"postie/pkg/httprequest"
+)
+
+// HTTPFile renders the contract as a .http file with a request for each
+// interaction, sent to {{baseUrl}}. The expected status becomes an
+// # @expect directive, headers ?? assertions, and the schema and fields a
+// response handler's tests.
+func (c *Contract) HTTPFile() string {
+	var b strings.Builder
+	switch {
+	case c.Consumer != "" && c.Provider != "":
+		fmt.Fprintf(&b, "# Contract between %s and %s\n", c.Consumer, c.Provider)
+	case c.Provider != "":
+		fmt.Fprintf(&b, "# Contract of %s\n", c.Provider)
+	}
+	fmt.Fprintf(&b, "# Set baseUrl to the provider's URL in your environment\n")
+
+	for _, interaction := range c.Interactions {
+		b.WriteString("\n")
+		interaction.writeHTTP(&b)
+	}
+	return b.String()
+}
+
+// writeHTTP writes the interaction as a request of a .http file
+func (i *Interaction) writeHTTP(b *strings.Builder) {
+	fmt.Fprintf(b, "### %s\n", i.Description)
+	fmt.Fprintf(b, "# @%s %d\n", httprequest.DirectiveExpect, i.Response.Status)
+	fmt.Fprintf(b, "%s {{baseUrl}}%s\n", strings.ToUpper(i.Request.Method), i.Request.Path)
+
+	hasContentType := false
+	for _, name := range sortedKeys(i.Request.Headers) {
+		fmt.Fprintf(b, "%s: %s\n", name, i.Request.Headers[name])
+		hasContentType = hasContentType || strings.EqualFold(name, "Content-Type")
+	}
+
+	if body := i.Request.BodyText(); body != "" {
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(body), "", "  ") == nil {
+			if !hasContentType {
+				b.WriteString("Content-Type: application/json\n")
+			}
+			body = indented.String()
+		}
+		fmt.Fprintf(b, "\n%s\n", strings.TrimRight(body, "\n"))
+	}
+
+	if len(i.Response.Headers) > 0 {
+		b.WriteString("\n")
+	}
+	for _, name := range sortedKeys(i.Response.Headers) {
+		b.WriteString(headerAssertion(name, i.Response.Headers[name]).String() + "\n")
+	}
+
+	if i.Response.Schema == nil && len(i.Response.Fields) == 0 {
+		return
+	}
+	b.WriteString("\n> {%\n")
+	if i.Response.Schema != nil {
+		fmt.Fprintf(b, "  client.test(\"Body matches the contract's schema\", function() {\n")
+		fmt.Fprintf(b, "    client.assertSchema(%s);\n", scriptLiteral(i.Response.Schema))
+		b.WriteString("  });\n")
+	}
+	if len(i.Response.Fields) > 0 {
+		b.WriteString("  client.test(\"Body has the contract's values\", function() {\n")
+		for _, path := range sortedKeys(i.Response.Fields) {
+			expected := scriptLiteral(i.Response.Fields[path])
+			message := scriptLiteral(fmt.Sprintf("%s should be %s", path, expected))
+			fmt.Fprintf(b, "    client.assert(JSON.stringify(response.jsonPath(%s)) === JSON.stringify(%s), %s);\n", scriptLiteral(path), expected, message)
+		}
+		b.WriteString("  });\n")
+	}
+	b.WriteString("%}\n")
+}
+
+// scriptLiteral encodes a value as a JavaScript literal that can't end
+// the handler block
+func scriptLiteral(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	// %} can only appear inside strings, where it can be escaped
+	return strings.ReplaceAll(string(encoded), "%}", `%\u007d`)
+}
+
+// headerAssertion returns the ?? assertion of an expected header. Like
+// Check, it ignores parameters such as charset unless they are expected.
+func headerAssertion(name, value string) httprequest.Assertion {
+	assertion := httprequest.Assertion{Subject: httprequest.AssertHeader, Name: name, Operator: httprequest.AssertEquals, Value: value}
+	if !strings.Contains(value, ";") && strings.Contains(value, "/") {
+		assertion.Operator = httprequest.AssertMatches
+		assertion.Value = "(?i)^" + regexp.QuoteMeta(value) + `\s*(;|$)`
+	}
+	if strings.ContainsAny(assertion.Value, ` "`) {
+		assertion.Value = fmt.Sprintf("%q", assertion.Value)
+	}
+	return assertion
+}
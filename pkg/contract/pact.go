@@ -0,0 +1,308 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+	"postie/pkg/schema"
+)
+
+// pactSpecification is the version of the Pact files Postie writes
+const pactSpecification = "2.0.0"
+
+// Pact is a Pact contract file, of specification version 2 or 3
+type Pact struct {
+	Consumer     Pacticipant            `json:"consumer"`
+	Provider     Pacticipant            `json:"provider"`
+	Interactions []*PactInteraction     `json:"interactions"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Pacticipant names the consumer or provider of a Pact
+type Pacticipant struct {
+	Name string `json:"name"`
+}
+
+// PactInteraction is a request of a Pact and the response expected to it
+type PactInteraction struct {
+	Description   string       `json:"description"`
+	ProviderState string       `json:"providerState,omitempty"`
+	Request       PactRequest  `json:"request"`
+	Response      PactResponse `json:"response"`
+}
+
+// PactRequest is the request of a Pact interaction. Query is a string in
+// version 2 and a map of values in version 3.
+type PactRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   json.RawMessage   `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// PactResponse is the expected response of a Pact interaction: its body
+// is an example, matched exactly except where MatchingRules say otherwise
+type PactResponse struct {
+	Status        int                    `json:"status"`
+	Headers       map[string]string      `json:"headers,omitempty"`
+	Body          json.RawMessage        `json:"body,omitempty"`
+	MatchingRules map[string]interface{} `json:"matchingRules,omitempty"`
+}
+
+// LoadPact reads a Pact file
+func LoadPact(path string) (*Pact, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pact: %w", err)
+	}
+
+	var pact Pact
+	if err := json.Unmarshal(data, &pact); err != nil {
+		return nil, fmt.Errorf("failed to parse pact %s: %w", path, err)
+	}
+	return &pact, nil
+}
+
+// NewPact creates an empty Pact between a consumer and a provider
+func NewPact(consumer, provider string) *Pact {
+	return &Pact{
+		Consumer: Pacticipant{Name: consumer},
+		Provider: Pacticipant{Name: provider},
+		Metadata: map[string]interface{}{
+			"pactSpecification": map[string]interface{}{"version": pactSpecification},
+		},
+	}
+}
+
+// Save writes the Pact as indented JSON
+func (p *Pact) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pact: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write pact: %w", err)
+	}
+	return nil
+}
+
+// Add adds an interaction, replacing one with the same description
+func (p *Pact) Add(interaction *PactInteraction) {
+	for i, existing := range p.Interactions {
+		if existing.Description == interaction.Description {
+			p.Interactions[i] = interaction
+			return
+		}
+	}
+	p.Interactions = append(p.Interactions, interaction)
+}
+
+// Contract converts the Pact to a contract. Response bodies are expected
+// to have the shape of their example, and the example's values wherever
+// no matching rule relaxes them. Provider states aren't set up.
+func (p *Pact) Contract() (*Contract, error) {
+	c := &Contract{Consumer: p.Consumer.Name, Provider: p.Provider.Name}
+	for _, pactInteraction := range p.Interactions {
+		path, err := pactInteraction.Request.target()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pactInteraction.Description, err)
+		}
+
+		interaction := &Interaction{
+			Description: pactInteraction.Description,
+			Request: Request{
+				Method:  pactInteraction.Request.Method,
+				Path:    path,
+				Headers: pactInteraction.Request.Headers,
+				Body:    pactInteraction.Request.Body,
+			},
+			Response: Expected{
+				Status:  pactInteraction.Response.Status,
+				Headers: pactInteraction.Response.Headers,
+			},
+		}
+
+		var body interface{}
+		if len(pactInteraction.Response.Body) > 0 && json.Unmarshal(pactInteraction.Response.Body, &body) == nil {
+			interaction.Response.Schema = schema.Infer(body)
+			rules := pactInteraction.Response.bodyRules()
+			flat := make(map[string]interface{})
+			flattenValues("$", body, flat)
+			for path, value := range flat {
+				if !rules.covers(path) {
+					if interaction.Response.Fields == nil {
+						interaction.Response.Fields = make(map[string]interface{})
+					}
+					interaction.Response.Fields[path] = value
+				}
+			}
+		}
+		c.Interactions = append(c.Interactions, interaction)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// target returns the path of the request with its query
+func (r *PactRequest) target() (string, error) {
+	if len(r.Query) == 0 {
+		return r.Path, nil
+	}
+
+	var query string
+	if json.Unmarshal(r.Query, &query) != nil {
+		var values url.Values
+		if err := json.Unmarshal(r.Query, &values); err != nil {
+			return "", fmt.Errorf("invalid query: %w", err)
+		}
+		query = values.Encode()
+	}
+	if query == "" {
+		return r.Path, nil
+	}
+	return r.Path + "?" + query, nil
+}
+
+// matchingRules are the JSON paths of a body whose values a Pact matches
+// by type or pattern rather than exactly
+type matchingRules []*regexp.Regexp
+
+// bodyRules returns the response body's matching rules, written as
+// "$.body.id" in version 2 and under "body" as "$.id" in version 3
+func (r *PactResponse) bodyRules() matchingRules {
+	var paths []string
+	for key, rule := range r.MatchingRules {
+		if key == "body" {
+			if body, ok := rule.(map[string]interface{}); ok {
+				for path := range body {
+					paths = append(paths, path)
+				}
+			}
+		} else if key == "$.body" || strings.HasPrefix(key, "$.body.") || strings.HasPrefix(key, "$.body[") {
+			paths = append(paths, "$"+strings.TrimPrefix(key, "$.body"))
+		}
+	}
+	sort.Strings(paths)
+
+	rules := make(matchingRules, 0, len(paths))
+	for _, path := range paths {
+		pattern := regexp.QuoteMeta(path)
+		pattern = strings.ReplaceAll(pattern, `\[\*\]`, `\[\d+\]`)
+		pattern = strings.ReplaceAll(pattern, `\.\*`, `\.[^.\[]+`)
+		rules = append(rules, regexp.MustCompile("^"+pattern+`($|[.\[])`))
+	}
+	return rules
+}
+
+// covers reports whether a rule applies to the value at path, or to a
+// value containing it
+func (rules matchingRules) covers(path string) bool {
+	for _, rule := range rules {
+		if rule.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenValues records the leaf values of a decoded document by path
+func flattenValues(path string, value interface{}, flat map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenValues(childPath(path, key), child, flat)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValues(fmt.Sprintf("%s[%d]", path, i), child, flat)
+		}
+	default:
+		flat[path] = v
+	}
+}
+
+// identifier matches object keys that can be written as .name in a path
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// childPath returns the path of an object's property, as .name or ['name']
+func childPath(path, key string) string {
+	if identifier.MatchString(key) {
+		return path + "." + key
+	}
+	if strings.Contains(key, "'") {
+		return path + `["` + key + `"]`
+	}
+	return path + "['" + key + "']"
+}
+
+// PactFromStoredResponse records a response saved with --save-responses
+// as a Pact interaction. The saved body is the example, matched by type so
+// that providers aren't held to its values. Request headers whose values
+// were redacted are left out.
+func PactFromStoredResponse(stored *responses.StoredResponse) (*PactInteraction, error) {
+	parsed, err := url.Parse(stored.RequestURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL %q: %w", stored.RequestURL, err)
+	}
+
+	interaction := &PactInteraction{
+		Description: stored.RequestName,
+		Request:     PactRequest{Method: stored.Method, Path: parsed.EscapedPath()},
+		Response:    PactResponse{Status: stored.StatusCode},
+	}
+	if interaction.Description == "" {
+		interaction.Description = stored.Method + " " + parsed.Path
+	}
+	if interaction.Request.Path == "" {
+		interaction.Request.Path = "/"
+	}
+	if parsed.RawQuery != "" {
+		interaction.Request.Query, _ = json.Marshal(parsed.RawQuery)
+	}
+
+	for name, value := range stored.RequestHeaders {
+		if strings.Contains(value, redact.Mask) {
+			continue
+		}
+		if interaction.Request.Headers == nil {
+			interaction.Request.Headers = make(map[string]string)
+		}
+		interaction.Request.Headers[name] = value
+	}
+	interaction.Request.Body = jsonBody(stored.RequestBody)
+
+	if stored.ContentType != "" {
+		interaction.Response.Headers = map[string]string{"Content-Type": stored.ContentType}
+	}
+	interaction.Response.Body = jsonBody(stored.Body)
+	if json.Valid([]byte(stored.Body)) {
+		interaction.Response.MatchingRules = map[string]interface{}{
+			"$.body": map[string]interface{}{"match": "type"},
+		}
+	}
+	return interaction, nil
+}
+
+// jsonBody returns a body as JSON, or as a JSON string if it isn't JSON
+func jsonBody(body string) json.RawMessage {
+	if body == "" {
+		return nil
+	}
+	if json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+	encoded, _ := json.Marshal(body)
+	return encoded
+}
@@ -0,0 +1,53 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"postie/pkg/redact"
+	"postie/pkg/responses"
+	"postie/pkg/schema"
+)
+
+// FromStoredResponse records the interaction of a response saved with
+// --save-responses: its request, and its status, content type and the
+// shape of its JSON body as the expectation. Request headers whose values
+// were redacted are left out, to be given when verifying.
+func FromStoredResponse(stored *responses.StoredResponse) (*Interaction, error) {
+	parsed, err := url.Parse(stored.RequestURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL %q: %w", stored.RequestURL, err)
+	}
+
+	interaction := &Interaction{
+		Description: stored.RequestName,
+		Request:     Request{Method: stored.Method, Path: parsed.RequestURI()},
+		Response:    Expected{Status: stored.StatusCode},
+	}
+	if interaction.Description == "" {
+		interaction.Description = stored.Method + " " + parsed.Path
+	}
+
+	for name, value := range stored.RequestHeaders {
+		if strings.Contains(value, redact.Mask) {
+			continue
+		}
+		if interaction.Request.Headers == nil {
+			interaction.Request.Headers = make(map[string]string)
+		}
+		interaction.Request.Headers[name] = value
+	}
+	interaction.Request.Body = jsonBody(stored.RequestBody)
+
+	if mediaType, _, err := mime.ParseMediaType(stored.ContentType); err == nil {
+		interaction.Response.Headers = map[string]string{"Content-Type": mediaType}
+	}
+	var body interface{}
+	if json.Unmarshal([]byte(stored.Body), &body) == nil {
+		interaction.Response.Schema = schema.Infer(body)
+	}
+	return interaction, nil
+}
@@ -0,0 +1,67 @@
+package contract
+
+import (
+	"strings"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+// Result is the outcome of verifying one interaction against a provider
+type Result struct {
+	Interaction string   `json:"interaction"`
+	Method      string   `json:"method"`
+	URL         string   `json:"url"`
+	StatusCode  int      `json:"status_code,omitempty"`
+	DurationMs  int64    `json:"duration_ms"`
+	Violations  []string `json:"violations,omitempty"`
+	Error       string   `json:"error,omitempty"` // The request couldn't be sent
+	Passed      bool     `json:"passed"`
+}
+
+// Verify sends the request of each interaction to the provider at baseURL
+// and checks its response. headers are added to every request, for
+// credentials the contract doesn't hold.
+func Verify(exec *executor.Executor, c *Contract, baseURL string, headers []httprequest.Header) []*Result {
+	results := make([]*Result, 0, len(c.Interactions))
+	for _, interaction := range c.Interactions {
+		request := interaction.httpRequest(baseURL, headers)
+		result := &Result{Interaction: interaction.Description, Method: request.Method, URL: request.URL.Raw}
+
+		executed, err := exec.ExecuteRequest(request)
+		if err != nil && (executed == nil || executed.Response == nil) {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		body, _ := executed.Response.GetBody()
+		result.StatusCode = executed.StatusCode
+		result.DurationMs = executed.Duration.Milliseconds()
+		result.Violations = interaction.Response.Check(executed.StatusCode, executed.Response.Header, body)
+		result.Passed = len(result.Violations) == 0
+		results = append(results, result)
+	}
+	return results
+}
+
+// httpRequest builds the request of an interaction for the provider at
+// baseURL
+func (i *Interaction) httpRequest(baseURL string, headers []httprequest.Header) *httprequest.Request {
+	request := &httprequest.Request{
+		Name:   i.Description,
+		Method: strings.ToUpper(i.Request.Method),
+		URL:    &httprequest.URL{Raw: strings.TrimRight(baseURL, "/") + i.Request.Path},
+	}
+
+	for _, name := range sortedKeys(i.Request.Headers) {
+		request.Headers = append(request.Headers, httprequest.Header{Name: name, Value: i.Request.Headers[name]})
+	}
+	request.Headers = append(request.Headers, headers...)
+
+	if body := i.Request.BodyText(); body != "" {
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: body}
+		request.Body.ContentType = request.Body.GetContentType()
+	}
+	return request
+}
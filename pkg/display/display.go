@@ -0,0 +1,97 @@
+// Package display formats sizes, durations and times for people to read.
+// Machine-readable output, such as --output json, keeps raw numbers
+// instead.
+package display
+
+import (
+	"fmt"
+	"time"
+)
+
+// Size formats a size in bytes, in powers of 1024: "512 B", "1.5 KB"
+func Size(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+// Duration formats a duration with the precision that matters at its
+// scale: "<1ms", "245ms", "1.24s", "2m5s"
+func Duration(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "-" + Duration(-d)
+	case d < time.Millisecond:
+		return "<1ms"
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// Milliseconds formats a duration in milliseconds, as stored in reports
+// and saved responses
+func Milliseconds(ms int64) string {
+	if ms == 0 {
+		return "0ms"
+	}
+	return Duration(time.Duration(ms) * time.Millisecond)
+}
+
+// Delta formats a change in duration with its sign: "+120ms", "-1.50s"
+func Delta(d time.Duration) string {
+	if d >= 0 {
+		return "+" + Duration(d)
+	}
+	return Duration(d)
+}
+
+// Time formats a time relative to now for recent times, such as "5 minutes
+// ago" or "in 2 hours", and as a local date and time otherwise
+func Time(t, now time.Time) string {
+	d := now.Sub(t)
+	suffix := " ago"
+	if d < 0 {
+		d, suffix = -d, ""
+	}
+
+	var relative string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		relative = plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		relative = plural(int(d/time.Hour), "hour")
+	case d < 7*24*time.Hour:
+		relative = plural(int(d/(24*time.Hour)), "day")
+	default:
+		return t.Local().Format(time.DateTime)
+	}
+	if suffix == "" {
+		return "in " + relative
+	}
+	return relative + suffix
+}
+
+// Since formats a time relative to the current time
+func Since(t time.Time) string {
+	return Time(t, time.Now())
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
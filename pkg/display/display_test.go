@@ -0,0 +1,86 @@
+package display
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSize(t *testing.T) {
+	tests := map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KB", 5 << 20: "5.0 MB", 3 << 30: "3.0 GB"}
+	for bytes, want := range tests {
+		if got := Size(bytes); got != want {
+			t.Errorf("Size(%d) = %q, want %q", bytes, got, want)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		500 * time.Microsecond:   "<1ms",
+		245 * time.Millisecond:   "245ms",
+		1240 * time.Millisecond:  "1.24s",
+		125 * time.Second:        "2m5s",
+		-1500 * time.Millisecond: "-1.50s",
+	}
+	for d, want := range tests {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%v) = %q, want %q", d, got, want)
+		}
+	}
+	if got := Milliseconds(0); got != "0ms" {
+		t.Errorf("Milliseconds(0) = %q", got)
+	}
+	if got := Delta(120 * time.Millisecond); got != "+120ms" {
+		t.Errorf("Delta = %q", got)
+	}
+}
+
+func TestTime(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		-10 * time.Second:   "just now",
+		-time.Minute:        "1 minute ago",
+		-5 * time.Hour:      "5 hours ago",
+		-3 * 24 * time.Hour: "3 days ago",
+		2 * time.Hour:       "in 2 hours",
+	}
+	for offset, want := range tests {
+		if got := Time(now.Add(offset), now); got != want {
+			t.Errorf("Time(now%+v) = %q, want %q", offset, got, want)
+		}
+	}
+
+	old := now.Add(-30 * 24 * time.Hour)
+	if got := Time(old, now); got != old.Local().Format(time.DateTime) {
+		t.Errorf("Expected an absolute time for old times, got %q", got)
+	}
+}
+
+func TestUnicodeSupported(t *testing.T) {
+	tests := []struct {
+		goos string
+		env  map[string]string
+		want bool
+	}{
+		{"linux", nil, true},
+		{"linux", map[string]string{"LANG": "en_US.UTF-8"}, true},
+		{"linux", map[string]string{"LANG": "C"}, false},
+		{"linux", map[string]string{"LC_ALL": "C.utf8", "LANG": "C"}, true},
+		{"windows", nil, false},
+		{"windows", map[string]string{"WT_SESSION": "1"}, true},
+	}
+	for _, test := range tests {
+		getenv := func(name string) string { return test.env[name] }
+		if got := unicodeSupported(test.goos, getenv); got != test.want {
+			t.Errorf("unicodeSupported(%s, %v) = %v, want %v", test.goos, test.env, got, test.want)
+		}
+	}
+
+	if err := SetGlyphs(GlyphsASCII); err != nil || Glyphs().Pass != "+" {
+		t.Errorf("Expected ASCII glyphs, got %q, %v", Glyphs().Pass, err)
+	}
+	if err := SetGlyphs("emoji"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	SetGlyphs(GlyphsUnicode)
+}
@@ -0,0 +1,80 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Glyph modes accepted by --glyphs
+const (
+	GlyphsAuto    = "auto"    // Unicode, unless the terminal can't show it
+	GlyphsUnicode = "unicode" // Always Unicode symbols
+	GlyphsASCII   = "ascii"   // Always ASCII symbols
+)
+
+// GlyphSet holds the symbols that mark results in output
+type GlyphSet struct {
+	Pass  string // A passed request, test or check
+	Fail  string // A failed request, test or assertion
+	Error string // A request that couldn't be sent
+	Skip  string // A skipped request
+	Arrow string // A change from one value to another
+}
+
+// Unicode symbols, for terminals that show them
+var Unicode = &GlyphSet{Pass: "✓", Fail: "✗", Error: "⚠", Skip: "⊘", Arrow: "→"}
+
+// ASCII symbols, for terminals that show Unicode as mojibake, such as the
+// legacy Windows console
+var ASCII = &GlyphSet{Pass: "+", Fail: "x", Error: "!", Skip: "-", Arrow: "->"}
+
+// glyphs are the symbols in use. They are Unicode until the CLI detects the
+// terminal.
+var glyphs = Unicode
+
+// Glyphs returns the symbols to use in output
+func Glyphs() *GlyphSet {
+	return glyphs
+}
+
+// SetGlyphs chooses the symbols for mode. In auto mode ASCII is used on
+// Windows consoles that aren't known to show Unicode, and where the locale
+// isn't UTF-8.
+func SetGlyphs(mode string) error {
+	switch mode {
+	case GlyphsUnicode:
+		glyphs = Unicode
+	case GlyphsASCII:
+		glyphs = ASCII
+	case GlyphsAuto:
+		glyphs = Unicode
+		if !unicodeSupported(runtime.GOOS, os.Getenv) {
+			glyphs = ASCII
+		}
+	default:
+		return fmt.Errorf("unsupported glyphs: %s (use auto, unicode or ascii)", mode)
+	}
+	return nil
+}
+
+// unicodeSupported guesses whether the terminal shows Unicode symbols
+func unicodeSupported(goos string, getenv func(string) string) bool {
+	if goos == "windows" {
+		// Windows Terminal, VS Code and ConEmu render UTF-8; conhost
+		// usually uses a legacy code page
+		return getenv("WT_SESSION") != "" || getenv("TERM_PROGRAM") == "vscode" ||
+			getenv("ConEmuANSI") == "ON" || strings.Contains(getenv("TERM"), "xterm")
+	}
+
+	// The first locale variable set decides, as in setlocale(3). Without
+	// any, assume a modern UTF-8 terminal.
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDotEnvFile is the dotenv file loaded from the working directory
+const DefaultDotEnvFile = ".env"
+
+// ParseDotEnv parses dotenv syntax: NAME=value lines with an optional
+// "export " prefix, # comments, and single- or double-quoted values.
+// Double-quoted values may span lines and use \n, \t, \" and \\ escapes;
+// single-quoted values are taken literally.
+func ParseDotEnv(content string) (map[string]string, error) {
+	vars := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected NAME=value", lineNumber)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			// Collect lines until the closing quote
+			raw := value[1:]
+			for !hasClosingQuote(raw) {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated quoted value for %s", lineNumber, name)
+				}
+				raw += "\n" + lines[i]
+			}
+			value = unescapeDotEnv(raw[:closingQuote(raw)])
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value for %s", lineNumber, name)
+			}
+			value = value[1 : end+1]
+		default:
+			// Unquoted values end at an inline comment
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = strings.TrimSpace(value[:index])
+			}
+		}
+
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// LoadDotEnv reads a dotenv file. A missing file yields no variables.
+func LoadDotEnv(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &EnvironmentLoadError{File: path, Message: "failed to read dotenv file", Cause: err}
+	}
+
+	vars, err := ParseDotEnv(string(content))
+	if err != nil {
+		return nil, &EnvironmentLoadError{File: path, Message: "invalid dotenv syntax", Cause: err}
+	}
+	return vars, nil
+}
+
+// loadDotEnv loads a dotenv file relative to the loader's working directory
+func (l *Loader) loadDotEnv(filename string) (map[string]string, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(l.workingDir, filename)
+	}
+	return LoadDotEnv(filename)
+}
+
+// closingQuote returns the index of the first unescaped double quote, or -1
+func closingQuote(s string) int {
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func hasClosingQuote(s string) bool {
+	return closingQuote(s) >= 0
+}
+
+// unescapeDotEnv expands the escapes allowed in double-quoted values
+func unescapeDotEnv(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\"`, `"`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,130 @@
+package environment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// AddVariables adds empty variables to an environment of an environment
+// file's JSON and returns the new JSON. Environments, variables and fields
+// already in the file keep their order and values, so the change shows up
+// as added lines only; new environments and variables are appended.
+func AddVariables(data []byte, env string, names []string) ([]byte, error) {
+	file, err := parseOrderedObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables orderedObject
+	if raw, ok := file.get(env); ok {
+		if variables, err = parseOrderedObject(raw); err != nil {
+			return nil, fmt.Errorf("environment %s: %w", env, err)
+		}
+	}
+	for _, name := range names {
+		if _, ok := variables.get(name); !ok {
+			variables.set(name, json.RawMessage(`""`))
+		}
+	}
+
+	value, err := variables.marshal("", "")
+	if err != nil {
+		return nil, err
+	}
+	file.set(env, value)
+
+	out, err := file.marshal("", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// orderedObject is a JSON object that keeps its keys in order and its
+// values as written
+type orderedObject []orderedField
+
+type orderedField struct {
+	key   string
+	value json.RawMessage
+}
+
+// parseOrderedObject parses a JSON object. Empty data is an empty object.
+func parseOrderedObject(data []byte) (orderedObject, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var object orderedObject
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		object.set(key, value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (o orderedObject) get(key string) (json.RawMessage, bool) {
+	for _, field := range o {
+		if field.key == key {
+			return field.value, true
+		}
+	}
+	return nil, false
+}
+
+// set replaces the value of a key, or appends the key
+func (o *orderedObject) set(key string, value json.RawMessage) {
+	for i := range *o {
+		if (*o)[i].key == key {
+			(*o)[i].value = value
+			return
+		}
+	}
+	*o = append(*o, orderedField{key: key, value: value})
+}
+
+// marshal writes the object like json.MarshalIndent, or compactly if
+// indent is empty
+func (o orderedObject) marshal(prefix, indent string) ([]byte, error) {
+	var compact bytes.Buffer
+	compact.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			compact.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		compact.Write(key)
+		compact.WriteByte(':')
+		if err := json.Compact(&compact, field.value); err != nil {
+			return nil, err
+		}
+	}
+	compact.WriteByte('}')
+
+	if indent == "" {
+		return compact.Bytes(), nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), prefix, indent); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
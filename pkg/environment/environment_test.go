@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -135,6 +136,95 @@ func TestResolverBasic(t *testing.T) {
 	}
 }
 
+func TestResolverRequestDefaults(t *testing.T) {
+	publicEnv := EnvironmentFile{
+		"development": Environment{
+			"tenant":   "acme",
+			"$baseUrl": "https://api-dev.example.com",
+			"$headers": map[string]interface{}{"Accept": "application/json", "X-Tenant": "{{tenant}}"},
+			"$hosts":   map[string]interface{}{"API.example.com": "10.0.0.5", "auth.example.com:443": "localhost:8443"},
+		},
+	}
+	privateEnv := EnvironmentFile{
+		"development": Environment{
+			"$headers": map[string]interface{}{"accept": "text/plain", "X-Api-Key": "secret-key-123"},
+			"$hosts":   map[string]interface{}{"api.example.com": "127.0.0.1"},
+		},
+	}
+
+	resolved, err := NewResolver().Resolve(publicEnv, privateEnv, "development")
+	if err != nil {
+		t.Fatalf("Failed to resolve environment: %v", err)
+	}
+
+	if resolved.HasVariable("$baseUrl") || resolved.HasVariable("$headers") || resolved.HasVariable("$hosts") {
+		t.Error("Expected request defaults not to be variables")
+	}
+	if resolved.Defaults.BaseURL != "https://api-dev.example.com" {
+		t.Errorf("Expected base URL, got %q", resolved.Defaults.BaseURL)
+	}
+
+	expected := map[string]string{"accept": "text/plain", "X-Tenant": "{{tenant}}", "X-Api-Key": "secret-key-123"}
+	if !reflect.DeepEqual(resolved.Defaults.Headers, expected) {
+		t.Errorf("Expected headers %v, got %v", expected, resolved.Defaults.Headers)
+	}
+
+	hosts := map[string]string{"api.example.com": "127.0.0.1", "auth.example.com:443": "localhost:8443"}
+	if !reflect.DeepEqual(resolved.Defaults.Hosts, hosts) {
+		t.Errorf("Expected hosts %v, got %v", hosts, resolved.Defaults.Hosts)
+	}
+
+	secrets := resolved.SecretValues()
+	if len(secrets) != 2 {
+		t.Errorf("Expected the private header values to be secret, got %v", secrets)
+	}
+
+	publicEnv["development"]["$headers"] = "Accept: application/json"
+	if _, err := NewResolver().Resolve(publicEnv, privateEnv, "development"); err == nil {
+		t.Error("Expected an error for $headers that isn't an object")
+	}
+}
+
+func TestResolverOverrides(t *testing.T) {
+	publicEnv := EnvironmentFile{
+		"development": Environment{
+			"host":    "api-dev.example.com",
+			"baseUrl": "https://{{host}}",
+			"apiKey":  "public-key",
+		},
+	}
+	privateEnv := EnvironmentFile{
+		"development": Environment{
+			"apiKey": "private-key",
+		},
+	}
+
+	resolved, err := NewResolver().ResolveWithOverrides(publicEnv, privateEnv, "development", map[string]string{
+		"host":   "localhost:8080",
+		"apiKey": "cli-key",
+	})
+	if err != nil {
+		t.Fatalf("Failed to resolve environment: %v", err)
+	}
+
+	if got := resolved.GetString("apiKey"); got != "cli-key" {
+		t.Errorf("Expected the override to win over the private file, got %s", got)
+	}
+	if got := resolved.GetString("baseUrl"); got != "https://localhost:8080" {
+		t.Errorf("Expected file values to use overridden variables, got %s", got)
+	}
+	// Overriding a private variable keeps it masked
+	if !resolved.IsOverride("apiKey") || !resolved.IsSecret("apiKey") {
+		t.Errorf("Expected apiKey to be a secret override, got source %q", resolved.Source["apiKey"])
+	}
+	if !slices.Contains(resolved.SecretValues(), "cli-key") {
+		t.Errorf("Expected the overridden secret to be masked, got %v", resolved.SecretValues())
+	}
+	if resolved.Source["host"] != "cli" || resolved.IsSecret("host") {
+		t.Errorf("Expected host to be a public override, got source %q", resolved.Source["host"])
+	}
+}
+
 func TestResolverWithSystemEnv(t *testing.T) {
 	// Set test system environment variable
 	os.Setenv("TEST_VAR", "system-value")
@@ -469,3 +559,206 @@ func TestJSONMarshaling(t *testing.T) {
 		t.Errorf("Expected value 'testValue', got %v", unmarshaled["value"])
 	}
 }
+
+func TestParseDotEnv(t *testing.T) {
+	content := `# comment
+export API_TOKEN="tok-123"
+PLAIN=value # inline comment
+EMPTY=
+SINGLE='no \n escapes'
+DOUBLE="line1\nline2 \"quoted\""
+MULTILINE="first
+second"
+URL=https://example.com/#anchor
+`
+	vars, err := ParseDotEnv(content)
+	if err != nil {
+		t.Fatalf("ParseDotEnv error: %v", err)
+	}
+
+	expected := map[string]string{
+		"API_TOKEN": "tok-123",
+		"PLAIN":     "value",
+		"EMPTY":     "",
+		"SINGLE":    `no \n escapes`,
+		"DOUBLE":    "line1\nline2 \"quoted\"",
+		"MULTILINE": "first\nsecond",
+		"URL":       "https://example.com/#anchor",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	for _, invalid := range []string{"NO_EQUALS", "BAD NAME=x", `OPEN="never closed`} {
+		if _, err := ParseDotEnv(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLoaderDotEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "http-client.env.json"), []byte(`{"development": {"region": "us-east"}}`), 0644)
+	os.WriteFile(filepath.Join(tmpDir, ".env"), []byte("region=eu-west\nAPI_TOKEN=secret-token\n"), 0600)
+
+	resolved, err := NewLoader(tmpDir).Load(&EnvironmentConfig{
+		PublicFile:  "http-client.env.json",
+		Environment: "development",
+		DotEnvFile:  DefaultDotEnvFile,
+	})
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	if got := resolved.GetString("region"); got != "us-east" {
+		t.Errorf("Expected the environment file to win over .env, got %s", got)
+	}
+	if got := resolved.GetString("API_TOKEN"); got != "secret-token" || !resolved.IsSecret("API_TOKEN") {
+		t.Errorf("Expected API_TOKEN from .env to be a secret, got %q", got)
+	}
+}
+
+func TestResolverInheritance(t *testing.T) {
+	publicEnv := EnvironmentFile{
+		"_base": Environment{
+			"apiVersion": "v1",
+			"timeout":    "5000",
+			"$headers":   map[string]interface{}{"Accept": "application/json"},
+		},
+		"staging": Environment{
+			"host":    "staging.example.com",
+			"baseUrl": "https://{{host}}/{{apiVersion}}",
+			"timeout": "10000",
+		},
+		"preview": Environment{
+			"$extends": "staging",
+			"host":     "preview.example.com",
+		},
+	}
+	privateEnv := EnvironmentFile{
+		"_base":   Environment{"apiKey": "base-key"},
+		"staging": Environment{"apiKey": "staging-key"},
+	}
+
+	resolved, err := NewResolver().Resolve(publicEnv, privateEnv, "preview")
+	if err != nil {
+		t.Fatalf("Failed to resolve environment: %v", err)
+	}
+
+	expected := map[string]string{
+		"apiVersion": "v1",                             // from _base
+		"timeout":    "10000",                          // staging overrides _base
+		"apiKey":     "staging-key",                    // staging's private file overrides _base's
+		"baseUrl":    "https://preview.example.com/v1", // preview's host
+	}
+	for name, value := range expected {
+		if got := resolved.GetString(name); got != value {
+			t.Errorf("Expected %s=%s, got %s", name, value, got)
+		}
+	}
+	if resolved.HasVariable("$extends") {
+		t.Error("Expected $extends not to be a variable")
+	}
+	if resolved.Defaults.Headers["Accept"] != "application/json" {
+		t.Errorf("Expected default headers from _base, got %v", resolved.Defaults.Headers)
+	}
+}
+
+func TestResolverInheritanceErrors(t *testing.T) {
+	publicEnv := EnvironmentFile{
+		"a":       Environment{"$extends": "b"},
+		"b":       Environment{"$extends": "a"},
+		"orphan":  Environment{"$extends": "missing"},
+		"invalid": Environment{"$extends": 42.0},
+	}
+
+	for name, want := range map[string]string{
+		"a":       "cycle: a -> b -> a",
+		"orphan":  "'missing', which is not defined",
+		"invalid": "must be an environment name",
+	} {
+		_, err := NewResolver().Resolve(publicEnv, EnvironmentFile{}, name)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, want, err)
+		}
+	}
+}
+
+func TestAddVariables(t *testing.T) {
+	data := []byte(`{
+  "production": {
+    "zeta": "https://api.example.com",
+    "alpha": {"nested": true},
+    "$comment": "a <b> & c"
+  },
+  "development": {}
+}
+`)
+
+	out, err := AddVariables(data, "production", []string{"token", "zeta"})
+	if err != nil {
+		t.Fatalf("AddVariables error: %v", err)
+	}
+	out, err = AddVariables(out, "staging", []string{"baseUrl"})
+	if err != nil {
+		t.Fatalf("AddVariables error: %v", err)
+	}
+
+	expected := `{
+  "production": {
+    "zeta": "https://api.example.com",
+    "alpha": {
+      "nested": true
+    },
+    "$comment": "a <b> & c",
+    "token": ""
+  },
+  "development": {},
+  "staging": {
+    "baseUrl": ""
+  }
+}
+`
+	if string(out) != expected {
+		t.Errorf("Expected order and fields to be kept, got:\n%s", out)
+	}
+
+	if out, err := AddVariables(nil, "development", []string{"host"}); err != nil || string(out) != "{\n  \"development\": {\n    \"host\": \"\"\n  }\n}\n" {
+		t.Errorf("Expected a new file, got %q, %v", out, err)
+	}
+	if _, err := AddVariables([]byte(`[1]`), "development", []string{"host"}); err == nil {
+		t.Error("Expected an error for a file that isn't an object")
+	}
+}
+
+func TestFindEnvironmentDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(root, "api", "users", "v1")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing up to the repository root
+	if dir := FindEnvironmentDir(nested); dir != "" {
+		t.Errorf("expected no directory, got %q", dir)
+	}
+
+	// The nearest directory with either file wins
+	apiDir := filepath.Join(root, "api")
+	if err := os.WriteFile(filepath.Join(apiDir, DefaultPublicFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if dir := FindEnvironmentDir(nested); dir != apiDir {
+		t.Errorf("expected %q, got %q", apiDir, dir)
+	}
+	usersDir := filepath.Join(apiDir, "users")
+	if err := os.WriteFile(filepath.Join(usersDir, DefaultPrivateFile), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if dir := FindEnvironmentDir(nested); dir != usersDir {
+		t.Errorf("expected %q, got %q", usersDir, dir)
+	}
+}
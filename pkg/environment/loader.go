@@ -50,6 +50,39 @@ func (l *Loader) LoadEnvironments(config *EnvironmentConfig) (*EnvironmentFile,
 	return &publicEnv, &privateEnv, nil
 }
 
+// Load loads the environment files in config and resolves the variables of
+// config.Environment. Missing files are treated as empty.
+func (l *Loader) Load(config *EnvironmentConfig) (*ResolvedEnvironment, error) {
+	publicEnv, privateEnv, err := l.LoadEnvironments(config)
+	if err != nil {
+		// Check if it's just missing files
+		if os.IsNotExist(err) {
+			// Use empty environment if files don't exist
+			emptyEnv := make(EnvironmentFile)
+			emptyPrivate := make(EnvironmentFile)
+			publicEnv = &emptyEnv
+			privateEnv = &emptyPrivate
+		} else {
+			return nil, err
+		}
+	}
+
+	dotenv, err := l.loadDotEnv(config.DotEnvFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve variables for the specified environment
+	resolver := NewResolver()
+	resolver.SetDotEnv(dotenv)
+	resolvedEnv, err := resolver.ResolveWithOverrides(*publicEnv, *privateEnv, config.Environment, config.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve environment variables: %w", err)
+	}
+
+	return resolvedEnv, nil
+}
+
 // loadEnvironmentFile loads a single environment file
 func (l *Loader) loadEnvironmentFile(filename string) (EnvironmentFile, error) {
 	if filename == "" {
@@ -167,10 +200,43 @@ func (l *Loader) processLine(line string, inBlockComment *bool) string {
 	return strings.TrimRight(string(result), " \t")
 }
 
+// Standard names of the environment files
+const (
+	DefaultPublicFile  = "http-client.env.json"
+	DefaultPrivateFile = "http-client.private.env.json"
+)
+
+// FindEnvironmentDir returns the directory whose environment files apply to
+// the .http files in dir: dir itself or its nearest parent that has a
+// public or private environment file. The search stops at the root of the
+// project, the first directory with a .git entry. It returns "" if no
+// directory has environment files.
+func FindEnvironmentDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range []string{DefaultPublicFile, DefaultPrivateFile} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
 // DiscoverEnvironmentFiles discovers standard JetBrains environment files
 func (l *Loader) DiscoverEnvironmentFiles() *EnvironmentConfig {
-	publicFile := filepath.Join(l.workingDir, "http-client.env.json")
-	privateFile := filepath.Join(l.workingDir, "http-client.private.env.json")
+	publicFile := filepath.Join(l.workingDir, DefaultPublicFile)
+	privateFile := filepath.Join(l.workingDir, DefaultPrivateFile)
 
 	// Check if public file exists
 	if _, err := os.Stat(publicFile); os.IsNotExist(err) {
@@ -216,6 +282,13 @@ func (l *Loader) ValidateEnvironmentFile(envFile EnvironmentFile) []error {
 				continue
 			}
 
+			if varName == HeadersKey || varName == HostsKey {
+				if _, ok := value.(map[string]interface{}); !ok {
+					errors = append(errors, fmt.Errorf("%s in environment '%s' must be an object", varName, envName))
+				}
+				continue
+			}
+
 			// Check for invalid variable types
 			if !l.isValidVariableType(value) {
 				errors = append(errors, fmt.Errorf("invalid variable type for '%s' in environment '%s': %T", varName, envName, value))
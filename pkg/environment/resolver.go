@@ -10,6 +10,7 @@ import (
 // Resolver handles variable resolution and environment merging
 type Resolver struct {
 	systemEnvPrefix string
+	dotenv          map[string]string // Variables from a .env file
 }
 
 // NewResolver creates a new environment resolver
@@ -26,36 +27,64 @@ func NewResolverWithPrefix(prefix string) *Resolver {
 	}
 }
 
+// SetDotEnv adds variables from a .env file to every environment, below
+// both environment files
+func (r *Resolver) SetDotEnv(vars map[string]string) {
+	r.dotenv = vars
+}
+
 // Resolve merges public and private environments and resolves variables
 func (r *Resolver) Resolve(publicEnv, privateEnv EnvironmentFile, envName string) (*ResolvedEnvironment, error) {
-	// Check if environment exists
-	publicVars, publicExists := publicEnv[envName]
-	privateVars, privateExists := privateEnv[envName]
+	return r.ResolveWithOverrides(publicEnv, privateEnv, envName, nil)
+}
 
-	if !publicExists && !privateExists {
-		return nil, fmt.Errorf("environment '%s' not found", envName)
+// ResolveWithOverrides merges public and private environments with
+// command line overrides and resolves variables. Precedence, highest first:
+// overrides, the environment (private, then public), the environments it
+// extends, the _base environment, .env file, system environment variables.
+func (r *Resolver) ResolveWithOverrides(publicEnv, privateEnv EnvironmentFile, envName string, overrides map[string]string) (*ResolvedEnvironment, error) {
+	layers, err := inheritanceLayers(publicEnv, privateEnv, envName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Merge environments with precedence: private > public > system
+	// Merge environments with precedence: cli > environment chain > dotenv > system
 	merged := make(map[string]interface{})
 	sources := make(map[string]string)
 
-	// Start with public environment
-	if publicExists {
-		for key, value := range publicVars {
+	for key, value := range r.dotenv {
+		merged[key] = value
+		sources[key] = "dotenv"
+	}
+
+	// Inherited environments first, each public then private
+	for _, layer := range layers {
+		for key, value := range layer.vars {
 			merged[key] = value
-			sources[key] = "public"
+			sources[key] = layer.source
 		}
 	}
 
-	// Override with private environment
-	if privateExists {
-		for key, value := range privateVars {
-			merged[key] = value
-			sources[key] = "private"
+	// Command line overrides win over both files. An override of a secret
+	// stays secret, since it's usually the same secret from another store.
+	for key, value := range overrides {
+		merged[key] = value
+		if sources[key] == "private" || sources[key] == "dotenv" {
+			sources[key] = "cli-private"
+		} else {
+			sources[key] = "cli"
 		}
 	}
 
+	defaults, err := extractDefaults(layers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request defaults in environment '%s': %w", envName, err)
+	}
+	for _, key := range []string{BaseURLKey, HeadersKey, ExtendsKey, HostsKey} {
+		delete(merged, key)
+		delete(sources, key)
+	}
+
 	// Resolve variables (expand {{var}} references and system env vars)
 	resolved, err := r.resolveVariables(merged, sources)
 	if err != nil {
@@ -66,9 +95,131 @@ func (r *Resolver) Resolve(publicEnv, privateEnv EnvironmentFile, envName string
 		Name:      envName,
 		Variables: resolved,
 		Source:    sources,
+		Defaults:  defaults,
 	}, nil
 }
 
+// envLayer is the variables of one environment from one file
+type envLayer struct {
+	vars   Environment
+	source string // public or private
+}
+
+// inheritanceLayers returns the layers envName is built from, lowest
+// precedence first: the _base environment, the environments named by
+// $extends from the furthest ancestor down, then envName itself
+func inheritanceLayers(publicEnv, privateEnv EnvironmentFile, envName string) ([]envLayer, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for name := envName; name != ""; {
+		if seen[name] {
+			return nil, fmt.Errorf("environment inheritance cycle: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+		seen[name] = true
+
+		publicVars, publicExists := publicEnv[name]
+		privateVars, privateExists := privateEnv[name]
+		if !publicExists && !privateExists {
+			if name == envName {
+				return nil, fmt.Errorf("environment '%s' not found", name)
+			}
+			return nil, fmt.Errorf("environment '%s' extends '%s', which is not defined", chain[len(chain)-1], name)
+		}
+		chain = append(chain, name)
+
+		parent, err := extendsOf(privateVars, publicVars)
+		if err != nil {
+			return nil, fmt.Errorf("environment '%s': %w", name, err)
+		}
+		name = parent
+	}
+
+	if !seen[BaseEnvironment] {
+		if _, ok := publicEnv[BaseEnvironment]; ok {
+			chain = append(chain, BaseEnvironment)
+		} else if _, ok := privateEnv[BaseEnvironment]; ok {
+			chain = append(chain, BaseEnvironment)
+		}
+	}
+
+	layers := make([]envLayer, 0, len(chain)*2)
+	for i := len(chain) - 1; i >= 0; i-- {
+		layers = append(layers,
+			envLayer{vars: publicEnv[chain[i]], source: "public"},
+			envLayer{vars: privateEnv[chain[i]], source: "private"})
+	}
+	return layers, nil
+}
+
+// extendsOf returns the environment named by the first $extends key found
+func extendsOf(envs ...Environment) (string, error) {
+	for _, env := range envs {
+		if value, ok := env[ExtendsKey]; ok {
+			parent, ok := value.(string)
+			if !ok {
+				return "", fmt.Errorf("%s must be an environment name", ExtendsKey)
+			}
+			return parent, nil
+		}
+	}
+	return "", nil
+}
+
+// extractDefaults reads the $baseUrl, $headers and $hosts keys of an
+// environment's layers. Headers and hosts are merged by name. Header values
+// are left unexpanded so they can use variables set while requests run.
+func extractDefaults(layers []envLayer) (RequestDefaults, error) {
+	defaults := RequestDefaults{
+		Headers:       make(map[string]string),
+		HeaderSources: make(map[string]string),
+		Hosts:         make(map[string]string),
+	}
+
+	for _, layer := range layers {
+		if value, ok := layer.vars[BaseURLKey]; ok {
+			baseURL, ok := value.(string)
+			if !ok {
+				return defaults, fmt.Errorf("%s must be a string", BaseURLKey)
+			}
+			defaults.BaseURL = baseURL
+		}
+
+		if value, ok := layer.vars[HeadersKey]; ok {
+			headers, ok := value.(map[string]interface{})
+			if !ok {
+				return defaults, fmt.Errorf("%s must be an object of header names and values", HeadersKey)
+			}
+			for name, headerValue := range headers {
+				// Header names are case-insensitive
+				for existing := range defaults.Headers {
+					if strings.EqualFold(existing, name) {
+						delete(defaults.Headers, existing)
+						delete(defaults.HeaderSources, existing)
+					}
+				}
+				defaults.Headers[name] = fmt.Sprint(headerValue)
+				defaults.HeaderSources[name] = layer.source
+			}
+		}
+
+		if value, ok := layer.vars[HostsKey]; ok {
+			hosts, ok := value.(map[string]interface{})
+			if !ok {
+				return defaults, fmt.Errorf("%s must be an object of host names and addresses", HostsKey)
+			}
+			for host, addr := range hosts {
+				addr, ok := addr.(string)
+				if !ok || addr == "" {
+					return defaults, fmt.Errorf("%s: the address of %s must be a string", HostsKey, host)
+				}
+				defaults.Hosts[strings.ToLower(host)] = addr
+			}
+		}
+	}
+
+	return defaults, nil
+}
+
 // resolveVariables resolves variable references and system environment variables
 func (r *Resolver) resolveVariables(variables map[string]interface{}, sources map[string]string) (map[string]interface{}, error) {
 	resolved := make(map[string]interface{})
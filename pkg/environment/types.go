@@ -13,16 +13,39 @@ type EnvironmentFile map[string]Environment
 
 // EnvironmentConfig holds configuration for environment loading
 type EnvironmentConfig struct {
-	PublicFile  string // Path to http-client.env.json
-	PrivateFile string // Path to http-client.private.env.json
-	Environment string // Active environment name (dev, prod, etc.)
+	PublicFile  string            // Path to http-client.env.json
+	PrivateFile string            // Path to http-client.private.env.json
+	Environment string            // Active environment name (dev, prod, etc.)
+	DotEnvFile  string            // Path to a .env file (optional)
+	Variables   map[string]string // Command line overrides of any variable (optional)
+}
+
+// Reserved environment keys that set request defaults instead of variables
+const (
+	BaseURLKey = "$baseUrl" // Prefixed to request URLs that start with /
+	HeadersKey = "$headers" // Object of headers added to every request
+	ExtendsKey = "$extends" // Name of an environment whose variables are inherited
+	HostsKey   = "$hosts"   // Object of host names mapped to the addresses to connect to
+)
+
+// BaseEnvironment is inherited by every other environment in a file
+const BaseEnvironment = "_base"
+
+// RequestDefaults are applied to every request run in an environment. A
+// request overrides them by using an absolute URL or setting the header.
+type RequestDefaults struct {
+	BaseURL       string
+	Headers       map[string]string
+	HeaderSources map[string]string // Header source tracking (public/private)
+	Hosts         map[string]string // Addresses to connect to instead, by "host" or "host:port"
 }
 
 // ResolvedEnvironment contains the merged environment variables
 type ResolvedEnvironment struct {
 	Name      string                 // Environment name
 	Variables map[string]interface{} // Merged variables
-	Source    map[string]string      // Variable source tracking (public/private/system)
+	Source    map[string]string      // Variable source tracking (cli/cli-private/public/private/dotenv/system)
+	Defaults  RequestDefaults        // Base URL and headers for every request
 }
 
 // Variable represents a resolved environment variable
@@ -163,3 +186,32 @@ func (re *ResolvedEnvironment) ListVariables() []*Variable {
 	}
 	return variables
 }
+
+// IsSecret returns true if a variable comes from the private environment
+// file or a .env file, or is a --var override of one
+func (re *ResolvedEnvironment) IsSecret(name string) bool {
+	source := re.Source[name]
+	return source == "private" || source == "dotenv" || source == "cli-private"
+}
+
+// IsOverride returns true if a variable was set with --var
+func (re *ResolvedEnvironment) IsOverride(name string) bool {
+	source := re.Source[name]
+	return source == "cli" || source == "cli-private"
+}
+
+// SecretValues returns the string values of all secret variables
+func (re *ResolvedEnvironment) SecretValues() []string {
+	var values []string
+	for name, value := range re.Variables {
+		if re.IsSecret(name) && value != nil {
+			values = append(values, fmt.Sprintf("%v", value))
+		}
+	}
+	for name, value := range re.Defaults.Headers {
+		if re.Defaults.HeaderSources[name] == "private" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+	"postie/pkg/schema"
+	"postie/pkg/scripting"
+)
+
+// maxSchemaViolations is how many violations of a schema are reported
+// before the rest are summarized
+const maxSchemaViolations = 10
+
+// checkAssertions evaluates a request's "??" assertions against the
+// response and returns the ones that fail
+func (e *Executor) checkAssertions(request *httprequest.Request, resp *client.Response) []*scripting.AssertionError {
+	var failed []*scripting.AssertionError
+	for _, assertion := range request.Assertions {
+		for _, message := range e.checkAssertion(assertion, resp) {
+			failed = append(failed, &scripting.AssertionError{
+				Message: fmt.Sprintf("%s: %s", assertion, message),
+				Line:    assertion.LineNumber,
+			})
+		}
+	}
+	return failed
+}
+
+// checkAssertion evaluates one assertion and describes how it fails
+func (e *Executor) checkAssertion(assertion httprequest.Assertion, resp *client.Response) []string {
+	if err := assertion.Validate(); err != nil {
+		return []string{err.Error()}
+	}
+
+	switch assertion.Subject {
+	case httprequest.AssertHeader:
+		values := resp.Header.Values(assertion.Name)
+		if len(values) == 0 {
+			return checkValue(assertion, "header", "", false)
+		}
+		return checkValue(assertion, "header", strings.Join(values, ", "), true)
+	case httprequest.AssertCookie:
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == assertion.Name {
+				return checkValue(assertion, "cookie", cookie.Value, true)
+			}
+		}
+		return checkValue(assertion, "cookie", "", false)
+	}
+
+	s, err := e.loadSchema(assertion.Value)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	body, err := resp.GetBody()
+	if err != nil {
+		return []string{fmt.Sprintf("failed to read response body: %v", err)}
+	}
+	violations, err := s.ValidateJSON(body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return violationMessages(violations)
+}
+
+// checkValue compares a header or cookie value with an assertion. found
+// is false if the response has no such header or cookie.
+func checkValue(assertion httprequest.Assertion, kind, actual string, found bool) []string {
+	switch assertion.Operator {
+	case httprequest.AssertExists:
+		if !found {
+			return []string{fmt.Sprintf("no %s %s", kind, assertion.Name)}
+		}
+		return nil
+	case httprequest.AssertNotExists:
+		if found {
+			return []string{fmt.Sprintf("%s %s is %q", kind, assertion.Name, actual)}
+		}
+		return nil
+	}
+	if !found {
+		return []string{fmt.Sprintf("no %s %s", kind, assertion.Name)}
+	}
+
+	var passed bool
+	switch assertion.Operator {
+	case httprequest.AssertEquals:
+		passed = actual == assertion.Value
+	case httprequest.AssertNotEquals:
+		passed = actual != assertion.Value
+	case httprequest.AssertContains:
+		passed = strings.Contains(actual, assertion.Value)
+	case httprequest.AssertMatches:
+		// Validate has already compiled the expression
+		passed = regexp.MustCompile(assertion.Value).MatchString(actual)
+	default:
+		number, err := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+		if err != nil {
+			return []string{fmt.Sprintf("%s %s is %q, not a number", kind, assertion.Name, actual)}
+		}
+		want, _ := strconv.ParseFloat(assertion.Value, 64)
+		switch assertion.Operator {
+		case httprequest.AssertGreater:
+			passed = number > want
+		case httprequest.AssertGreaterEqual:
+			passed = number >= want
+		case httprequest.AssertLess:
+			passed = number < want
+		case httprequest.AssertLessEqual:
+			passed = number <= want
+		}
+	}
+	if !passed {
+		return []string{fmt.Sprintf("%s %s is %q", kind, assertion.Name, actual)}
+	}
+	return nil
+}
+
+// loadSchema loads a JSON Schema file, once per run
+func (e *Executor) loadSchema(path string) (*schema.Schema, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	e.mu.Lock()
+	s, ok := e.schemas[path]
+	e.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+	s, err := schema.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.schemas[path] = s
+	e.mu.Unlock()
+	return s, nil
+}
+
+// violationMessages formats schema violations, summarizing long lists
+func violationMessages(violations []schema.Violation) []string {
+	var messages []string
+	for i, violation := range violations {
+		if i == maxSchemaViolations {
+			messages = append(messages, fmt.Sprintf("and %d more", len(violations)-i))
+			break
+		}
+		messages = append(messages, violation.Error())
+	}
+	return messages
+}
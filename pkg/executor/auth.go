@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"postie/pkg/auth"
+	"postie/pkg/httprequest"
+)
+
+// applyAuth applies a # @auth directive with a built-in scheme, such as
+// "bearer {{token}}", to an expanded request. Other schemes are left to
+// plugins.
+func applyAuth(request *httprequest.Request) error {
+	value, ok := request.Metadata[httprequest.DirectiveAuth]
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 0 || !auth.IsScheme(fields[0]) {
+		return nil
+	}
+
+	authenticator, err := auth.Parse(fields[0], fields[1:])
+	if err != nil {
+		return fmt.Errorf("invalid @%s: %w", httprequest.DirectiveAuth, err)
+	}
+
+	// Authenticators work on an http.Request; copy back what they change
+	req, err := http.NewRequest(request.Method, request.URL.Raw, nil)
+	if err != nil {
+		return fmt.Errorf("invalid @%s: %w", httprequest.DirectiveAuth, err)
+	}
+	if err := authenticator.Apply(req); err != nil {
+		return fmt.Errorf("invalid @%s: %w", httprequest.DirectiveAuth, err)
+	}
+
+	for name, values := range req.Header {
+		request.Headers = withHeader(request.Headers, name, values[0])
+	}
+	if req.URL.RawQuery != "" {
+		url := *request.URL
+		url.Raw = req.URL.String()
+		request.URL = &url
+	}
+	return nil
+}
+
+// withHeader returns headers with any headers of the same name replaced by
+// one with the given value
+func withHeader(headers []httprequest.Header, name, value string) []httprequest.Header {
+	kept := make([]httprequest.Header, 0, len(headers)+1)
+	for _, header := range headers {
+		if !strings.EqualFold(header.Name, name) {
+			kept = append(kept, header)
+		}
+	}
+	return append(kept, httprequest.Header{Name: name, Value: value})
+}
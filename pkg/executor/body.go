@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"postie/pkg/httprequest"
+)
+
+// withFileBody returns a copy of a request with the content of its
+// "< file" body read, or the request itself if its body isn't a file.
+// Variables in the file aren't expanded.
+func (e *Executor) withFileBody(request *httprequest.Request) (*httprequest.Request, error) {
+	if request.Body == nil || request.Body.Type != httprequest.BodyTypeFile {
+		return request, nil
+	}
+
+	content, err := e.readBodyFile(request.Body.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	withBody := *request
+	body := *request.Body
+	body.Content = string(content)
+	withBody.Body = &body
+	return &withBody, nil
+}
+
+// readBodyFile reads a body file, relative to the .http file being run.
+// Standard input is read once, so every request with "< -" sends the same
+// body.
+func (e *Executor) readBodyFile(path string) ([]byte, error) {
+	if path == httprequest.StdinFile {
+		content, err := e.readStdin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the body from standard input: %w", err)
+		}
+		return content, nil
+	}
+
+	if !filepath.IsAbs(path) && e.requestsFile != nil && e.requestsFile.Dir != "" {
+		path = filepath.Join(e.requestsFile.Dir, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body file: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Kinds of change between a baseline and a current run
+const (
+	ChangeUnchanged     = "unchanged"
+	ChangeRegression    = "regression"
+	ChangeImprovement   = "improvement"
+	ChangeStatusChanged = "status_changed"
+	ChangeNewFailure    = "new_failure"
+	ChangeFixed         = "fixed"
+	ChangeAdded         = "added"
+	ChangeRemoved       = "removed"
+)
+
+// CompareOptions sets when a latency change counts as a regression
+type CompareOptions struct {
+	Threshold float64       // Relative slowdown, such as 0.2 for 20%
+	MinDelta  time.Duration // Slowdowns smaller than this are ignored
+}
+
+// RequestComparison compares one request across two runs
+type RequestComparison struct {
+	Request        string  `json:"request"`
+	Change         string  `json:"change"`
+	BaselineStatus int     `json:"baseline_status,omitempty"`
+	CurrentStatus  int     `json:"current_status,omitempty"`
+	BaselineMs     int64   `json:"baseline_ms"`
+	CurrentMs      int64   `json:"current_ms"`
+	DeltaMs        int64   `json:"delta_ms"`
+	DeltaPercent   float64 `json:"delta_percent"`
+	BaselinePassed bool    `json:"baseline_passed"`
+	CurrentPassed  bool    `json:"current_passed"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// ReportComparison is the result of comparing a current run with a baseline
+type ReportComparison struct {
+	Baseline string               `json:"baseline"`
+	Current  string               `json:"current"`
+	Requests []*RequestComparison `json:"requests"`
+	Summary  map[string]int       `json:"summary"`
+}
+
+// Failed reports whether the current run regressed or has new failures
+func (c *ReportComparison) Failed() bool {
+	return c.Summary[ChangeRegression] > 0 || c.Summary[ChangeNewFailure] > 0
+}
+
+// LoadRunReport reads a report written by "http run --output json"
+func LoadRunReport(path string) (*RunReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// CompareReports matches the requests of two runs by name (or method and
+// URL for unnamed requests) and classifies how each one changed
+func CompareReports(baseline, current *RunReport, opts CompareOptions) *ReportComparison {
+	comparison := &ReportComparison{
+		Baseline: baseline.File,
+		Current:  current.File,
+		Summary:  make(map[string]int),
+	}
+
+	baselineKeys := reportKeys(baseline)
+	baselineByKey := make(map[string]*ResultReport, len(baselineKeys))
+	for i, key := range baselineKeys {
+		baselineByKey[key] = baseline.Results[i]
+	}
+
+	seen := make(map[string]bool)
+	for i, key := range reportKeys(current) {
+		result := current.Results[i]
+		seen[key] = true
+
+		entry := &RequestComparison{
+			Request:       key,
+			CurrentStatus: result.StatusCode,
+			CurrentMs:     result.DurationMs,
+			CurrentPassed: result.Passed,
+			Error:         result.Error,
+		}
+
+		base, ok := baselineByKey[key]
+		if !ok {
+			entry.Change = ChangeAdded
+		} else {
+			entry.BaselineStatus = base.StatusCode
+			entry.BaselineMs = base.DurationMs
+			entry.BaselinePassed = base.Passed
+			entry.DeltaMs = entry.CurrentMs - entry.BaselineMs
+			if entry.BaselineMs > 0 {
+				entry.DeltaPercent = float64(entry.DeltaMs) / float64(entry.BaselineMs) * 100
+			}
+			entry.Change = classifyChange(entry, opts)
+		}
+
+		comparison.Requests = append(comparison.Requests, entry)
+		comparison.Summary[entry.Change]++
+	}
+
+	for i, key := range baselineKeys {
+		if seen[key] {
+			continue
+		}
+		result := baseline.Results[i]
+		comparison.Requests = append(comparison.Requests, &RequestComparison{
+			Request:        key,
+			Change:         ChangeRemoved,
+			BaselineStatus: result.StatusCode,
+			BaselineMs:     result.DurationMs,
+			BaselinePassed: result.Passed,
+		})
+		comparison.Summary[ChangeRemoved]++
+	}
+
+	return comparison
+}
+
+// classifyChange decides how a request present in both runs changed
+func classifyChange(entry *RequestComparison, opts CompareOptions) string {
+	switch {
+	case entry.BaselinePassed && !entry.CurrentPassed:
+		return ChangeNewFailure
+	case !entry.BaselinePassed && entry.CurrentPassed:
+		return ChangeFixed
+	case entry.BaselineStatus != entry.CurrentStatus:
+		return ChangeStatusChanged
+	}
+
+	delta := time.Duration(entry.DeltaMs) * time.Millisecond
+	relative := entry.DeltaPercent / 100
+	if delta > 0 && delta >= opts.MinDelta && relative >= opts.Threshold {
+		return ChangeRegression
+	}
+	if delta < 0 && -delta >= opts.MinDelta && -relative >= opts.Threshold {
+		return ChangeImprovement
+	}
+	return ChangeUnchanged
+}
+
+// reportKeys returns a key for each result, in order. Requests are
+// identified by name, or by method and URL; repeats get a "#n" suffix.
+func reportKeys(report *RunReport) []string {
+	keys := make([]string, len(report.Results))
+	counts := make(map[string]int)
+	for i, result := range report.Results {
+		key := result.Name
+		if key == "" {
+			key = result.Method + " " + result.URL
+		}
+		if result.File != "" {
+			key = result.File + ": " + key
+		}
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s #%d", key, counts[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
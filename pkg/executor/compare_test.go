@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+)
+
+func TestCompareReports(t *testing.T) {
+	baseline := &RunReport{Results: []*ResultReport{
+		{Name: "Fast", StatusCode: 200, DurationMs: 100, Passed: true},
+		{Name: "Slow", StatusCode: 200, DurationMs: 100, Passed: true},
+		{Name: "Broken", StatusCode: 200, DurationMs: 100, Passed: true},
+		{Method: "GET", URL: "/a", StatusCode: 200, DurationMs: 100, Passed: true},
+		{Method: "GET", URL: "/a", StatusCode: 500, DurationMs: 100, Passed: false},
+		{Name: "Gone", StatusCode: 200, DurationMs: 100, Passed: true},
+	}}
+	current := &RunReport{Results: []*ResultReport{
+		{Name: "Fast", StatusCode: 200, DurationMs: 130, Passed: true},
+		{Name: "Slow", StatusCode: 200, DurationMs: 400, Passed: true},
+		{Name: "Broken", StatusCode: 500, DurationMs: 100, Passed: false},
+		{Method: "GET", URL: "/a", StatusCode: 201, DurationMs: 100, Passed: true},
+		{Method: "GET", URL: "/a", StatusCode: 200, DurationMs: 100, Passed: true},
+		{Name: "New", StatusCode: 200, DurationMs: 100, Passed: true},
+	}}
+
+	comparison := CompareReports(baseline, current, CompareOptions{Threshold: 0.2, MinDelta: 50 * time.Millisecond})
+
+	expected := map[string]string{
+		"Fast":      ChangeUnchanged, // +30% but under the minimum delta
+		"Slow":      ChangeRegression,
+		"Broken":    ChangeNewFailure,
+		"GET /a":    ChangeStatusChanged,
+		"GET /a #2": ChangeFixed,
+		"New":       ChangeAdded,
+		"Gone":      ChangeRemoved,
+	}
+	if len(comparison.Requests) != len(expected) {
+		t.Fatalf("Expected %d comparisons, got %d", len(expected), len(comparison.Requests))
+	}
+	for _, entry := range comparison.Requests {
+		if entry.Change != expected[entry.Request] {
+			t.Errorf("%s: expected %s, got %s", entry.Request, expected[entry.Request], entry.Change)
+		}
+	}
+
+	if !comparison.Failed() {
+		t.Error("Expected a regression and a new failure to fail the comparison")
+	}
+	if comparison.Summary[ChangeRegression] != 1 || comparison.Summary[ChangeNewFailure] != 1 {
+		t.Errorf("Unexpected summary: %v", comparison.Summary)
+	}
+}
+
+func TestMergeRunReports(t *testing.T) {
+	users := &RunReport{File: "users.http", Results: []*ResultReport{{Name: "List", Passed: true}}, Summary: ReportSummary{Total: 1, Successful: 1}}
+	orders := &RunReport{File: "orders.http", Results: []*ResultReport{{Name: "List", Passed: false}}, Summary: ReportSummary{Total: 1, Failed: 1}}
+
+	merged := MergeRunReports("dev", []*RunReport{users, orders})
+	if len(merged.Files) != 2 || merged.Summary.Total != 2 || merged.Summary.Failed != 1 {
+		t.Fatalf("Unexpected merged report: %+v", merged)
+	}
+
+	// Requests with the same name in different files are told apart
+	keys := reportKeys(merged)
+	if keys[0] != "users.http: List" || keys[1] != "orders.http: List" {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
+
+func TestCompareEnvironments(t *testing.T) {
+	result := func(name string, status int, body string) *ExecutionResult {
+		response := &client.Response{Response: &http.Response{StatusCode: status}}
+		response.SetBody([]byte(body))
+		return &ExecutionResult{Request: &httprequest.Request{Name: name}, Response: response, StatusCode: status}
+	}
+
+	dev := []*ExecutionResult{
+		result("Users", 200, `{"count": 2, "items": [{"id": 1}, {"id": 2}]}`),
+		result("Health", 200, "ok\nv1"),
+		result("Orders", 200, `{}`),
+		result("Config", 200, `{"debug": true}`),
+	}
+	staging := []*ExecutionResult{
+		result("Users", 200, `{"count": 1, "items": [{"id": 1}]}`),
+		result("Health", 200, "ok\nv2"),
+		result("Orders", 500, `{}`),
+		{Request: &httprequest.Request{Name: "Config"}, Skipped: true},
+	}
+
+	comparison := CompareEnvironments([]string{"dev", "staging"}, [][]*ExecutionResult{dev, staging})
+	expected := []string{DriftBody, DriftBody, DriftStatus, DriftSkipped}
+	for i, request := range comparison.Requests {
+		if request.Drift != expected[i] {
+			t.Errorf("%s: expected %s, got %s", request.Request, expected[i], request.Drift)
+		}
+	}
+
+	users := comparison.Requests[0].Differences
+	if len(users) != 2 || users[0].Path != "$.count" || users[0].Values[1] != "1" || users[1].Path != "$.items[1].id" || users[1].Values[1] != "" {
+		t.Errorf("unexpected JSON differences: %+v", users)
+	}
+	if health := comparison.Requests[1].Differences; len(health) != 1 || health[0].Path != "line 2" {
+		t.Errorf("unexpected line differences: %+v", health)
+	}
+	if !comparison.Drifted() {
+		t.Error("expected the environments to have drifted")
+	}
+
+	same := CompareEnvironments([]string{"dev", "dev"}, [][]*ExecutionResult{dev[:1], dev[:1]})
+	if same.Drifted() || same.Requests[0].Drift != DriftNone {
+		t.Errorf("expected no drift, got %+v", same.Requests[0])
+	}
+}
@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+)
+
+// ExecuteSelected executes the requests at the given indexes of a file in
+// order. The file's # @setup requests run first and its # @teardown
+// requests last, whatever was selected. Requests named by # @depends-on
+// directives run before the requests that need them, once per run; a
+// request whose dependency or setup fails isn't sent.
+func (e *Executor) ExecuteSelected(requestsFile *httprequest.RequestsFile, selected []int) ([]*ExecutionResult, error) {
+	if requestsFile == nil {
+		return nil, fmt.Errorf("requests file cannot be nil")
+	}
+	e.requestsFile = requestsFile
+
+	order, err := dependencyOrder(requestsFile.Requests, suiteOrder(requestsFile.Requests, selected))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ExecutionResult, 0, len(order))
+	executed := make(map[int]*ExecutionResult, len(order))
+	failedSetup := ""
+	for _, i := range order {
+		request := requestsFile.Requests[i]
+		fixture := request.HasDirective(httprequest.DirectiveSetup) || request.HasDirective(httprequest.DirectiveTeardown)
+
+		result := e.checkDependencies(requestsFile.Requests, i, executed)
+		if result == nil && failedSetup != "" && !fixture {
+			err := fmt.Errorf("setup request %q failed", failedSetup)
+			e.runOnError(&request, err)
+			result = &ExecutionResult{Request: &request, Error: err, Blocked: true}
+		}
+		if result == nil {
+			result, err = e.ExecuteRequest(&request)
+			if err != nil {
+				logging.Verbose("error executing request", "name", request.Name, "error", err)
+			}
+		}
+
+		if request.HasDirective(httprequest.DirectiveSetup) && failedSetup == "" && !result.Skipped && !result.Passed() {
+			failedSetup = requestLabel(&request, i)
+		}
+		executed[i] = result
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// suiteOrder puts a file's # @setup requests before the selected requests
+// and its # @teardown requests after them
+func suiteOrder(requests []httprequest.Request, selected []int) []int {
+	var setups, teardowns, order []int
+	for i := range requests {
+		switch {
+		case requests[i].HasDirective(httprequest.DirectiveSetup):
+			setups = append(setups, i)
+		case requests[i].HasDirective(httprequest.DirectiveTeardown):
+			teardowns = append(teardowns, i)
+		}
+	}
+
+	order = append(order, setups...)
+	for _, i := range selected {
+		if !requests[i].HasDirective(httprequest.DirectiveSetup) && !requests[i].HasDirective(httprequest.DirectiveTeardown) {
+			order = append(order, i)
+		}
+	}
+	return append(order, teardowns...)
+}
+
+// checkDependencies returns the result of a request whose dependencies
+// failed or were skipped, or nil if it can run
+func (e *Executor) checkDependencies(requests []httprequest.Request, index int, executed map[int]*ExecutionResult) *ExecutionResult {
+	request := &requests[index]
+	for _, name := range dependencyNames(request) {
+		dependency := executed[findDependency(requests, name)]
+		switch {
+		case dependency.Skipped:
+			return &ExecutionResult{Request: request, Skipped: true, SkipReason: fmt.Sprintf("dependency %q was skipped", name)}
+		case !dependency.Passed():
+			err := fmt.Errorf("dependency %q failed", name)
+			e.runOnError(request, err)
+			return &ExecutionResult{Request: request, Error: err, Blocked: true}
+		}
+	}
+	return nil
+}
+
+// dependencyOrder returns the selected requests preceded by the requests
+// they depend on, each once
+func dependencyOrder(requests []httprequest.Request, selected []int) ([]int, error) {
+	var order []int
+	visited := make(map[int]bool)
+	visiting := make(map[int]bool)
+
+	var visit func(index int, path []string) error
+	visit = func(index int, path []string) error {
+		if visited[index] {
+			return nil
+		}
+		request := &requests[index]
+		path = append(path, requestLabel(request, index))
+		if visiting[index] {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[index] = true
+
+		for _, name := range dependencyNames(request) {
+			dependency := findDependency(requests, name)
+			if dependency < 0 {
+				return fmt.Errorf("request %s depends on unknown request %q", path[len(path)-1], name)
+			}
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+
+		visiting[index] = false
+		visited[index] = true
+		order = append(order, index)
+		return nil
+	}
+
+	for _, index := range selected {
+		if err := visit(index, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// dependencyNames returns the request names listed by # @depends-on,
+// separated by commas
+func dependencyNames(request *httprequest.Request) []string {
+	value, ok := request.Metadata[httprequest.DirectiveDependsOn]
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// findDependency returns the index of the request with the given name, or -1
+func findDependency(requests []httprequest.Request, name string) int {
+	for i := range requests {
+		if requests[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// requestLabel names a request in messages, by name or 1-based number
+func requestLabel(request *httprequest.Request, index int) string {
+	if request.Name != "" {
+		return request.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deprecation is what a response's Deprecation, Sunset and Warning headers
+// say about the API that sent it
+type Deprecation struct {
+	Deprecated bool       `json:"deprecated"`
+	Since      *time.Time `json:"since,omitempty"`    // The Deprecation header's date, if it has one
+	Sunset     *time.Time `json:"sunset,omitempty"`   // When the API stops working, from the Sunset header
+	Link       string     `json:"link,omitempty"`     // A Link with rel="deprecation" or rel="sunset", usually documentation
+	Warnings   []string   `json:"warnings,omitempty"` // Warning headers, such as "299 Deprecated API"
+}
+
+// warningValue matches a Warning header: code, agent and quoted text
+var warningValue = regexp.MustCompile(`^(\d{3})\s+\S+\s+"((?:[^"\\]|\\.)*)"`)
+
+// deprecationOf reads the Deprecation (RFC 9745, "@<unix time>", or the
+// earlier "true" or an HTTP date), Sunset (RFC 8594) and Warning headers
+// of a response. It returns nil if there are none.
+func deprecationOf(header http.Header) *Deprecation {
+	deprecation := &Deprecation{}
+
+	if value := strings.TrimSpace(header.Get("Deprecation")); value != "" && !strings.EqualFold(value, "false") {
+		deprecation.Deprecated = true
+		if seconds, err := strconv.ParseInt(strings.TrimPrefix(value, "@"), 10, 64); err == nil && strings.HasPrefix(value, "@") {
+			since := time.Unix(seconds, 0).UTC()
+			deprecation.Since = &since
+		} else if since, err := http.ParseTime(value); err == nil {
+			deprecation.Since = &since
+		}
+	}
+
+	if value := strings.TrimSpace(header.Get("Sunset")); value != "" {
+		if sunset, err := http.ParseTime(value); err == nil {
+			deprecation.Sunset = &sunset
+		}
+	}
+
+	if deprecation.Deprecated || deprecation.Sunset != nil {
+		deprecation.Link = linkTarget(header.Values("Link"), "deprecation")
+		if deprecation.Link == "" {
+			deprecation.Link = linkTarget(header.Values("Link"), "sunset")
+		}
+	}
+
+	for _, value := range header.Values("Warning") {
+		if match := warningValue.FindStringSubmatch(value); match != nil {
+			deprecation.Warnings = append(deprecation.Warnings, match[1]+" "+strings.ReplaceAll(match[2], `\"`, `"`))
+		} else if value = strings.TrimSpace(value); value != "" {
+			deprecation.Warnings = append(deprecation.Warnings, value)
+		}
+	}
+
+	if !deprecation.Deprecated && deprecation.Sunset == nil && len(deprecation.Warnings) == 0 {
+		return nil
+	}
+	return deprecation
+}
+
+// Notice describes the deprecation or sunset, such as "Deprecated since
+// 2024-01-01, sunset on 2025-06-30, see https://...", or returns "" if the
+// response only had warnings. now decides whether the sunset has passed.
+func (d *Deprecation) Notice(now time.Time) string {
+	if !d.Deprecated && d.Sunset == nil {
+		return ""
+	}
+
+	var details []string
+	if d.Since != nil {
+		details = append(details, "since "+d.Since.UTC().Format(time.DateOnly))
+	}
+	if d.Sunset != nil {
+		sunset := "sunset on " + d.Sunset.UTC().Format(time.DateOnly)
+		if d.Sunset.Before(now) {
+			sunset += ", which has passed"
+		}
+		details = append(details, sunset)
+	}
+	if d.Link != "" {
+		details = append(details, "see "+d.Link)
+	}
+
+	if !d.Deprecated {
+		// Only a Sunset header
+		return "S" + strings.Join(details, ", ")[1:]
+	}
+	switch {
+	case len(details) == 0:
+		return "Deprecated"
+	case d.Since == nil:
+		return "Deprecated, " + strings.Join(details, ", ")
+	}
+	return "Deprecated " + strings.Join(details, ", ")
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"postie/pkg/client"
+	"postie/pkg/codec"
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+)
+
+// Body encodings selected with # @encode
+const (
+	encodingMsgpack  = "msgpack"
+	encodingProtobuf = "protobuf"
+)
+
+// encodeBody converts a JSON request body to the binary encoding chosen by
+// # @encode, or implied by # @proto. It returns nil if the body is sent
+// as written.
+func (e *Executor) encodeBody(request *httprequest.Request) (body []byte, contentType string, err error) {
+	encoding := strings.ToLower(request.Metadata[httprequest.DirectiveEncode])
+	if encoding == "" && request.HasDirective(httprequest.DirectiveProto) {
+		encoding = encodingProtobuf
+	}
+
+	switch encoding {
+	case "":
+		return nil, "", nil
+	case encodingMsgpack:
+		body, err = codec.EncodeMsgpack([]byte(request.Body.Content))
+		contentType = codec.MsgpackContentType
+	case encodingProtobuf:
+		message, messageErr := e.protoMessage(request.Metadata[httprequest.DirectiveProto], httprequest.DirectiveProto)
+		if messageErr != nil {
+			return nil, "", messageErr
+		}
+		body, err = message.Encode([]byte(request.Body.Content))
+		contentType = codec.ProtobufContentType
+	default:
+		return nil, "", fmt.Errorf("unsupported @%s %q (supported: %s, %s)", httprequest.DirectiveEncode, encoding, encodingMsgpack, encodingProtobuf)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode body as %s: %w", encoding, err)
+	}
+
+	// An explicit non-JSON Content-Type header is kept
+	if declared := request.Body.ContentType; declared != "" && !strings.Contains(declared, "json") {
+		contentType = declared
+	}
+	return body, contentType, nil
+}
+
+// decodeResponse replaces a MessagePack body, or a protobuf body described by
+// # @proto-response, with its JSON form for display, scripts and assertions
+func (e *Executor) decodeResponse(request *httprequest.Request, resp *client.Response) error {
+	body, err := resp.GetBody()
+	if err != nil || len(body) == 0 {
+		return err
+	}
+
+	var decoded interface{}
+	switch {
+	case request.HasDirective(httprequest.DirectiveProtoResponse):
+		message, err := e.protoMessage(request.Metadata[httprequest.DirectiveProtoResponse], httprequest.DirectiveProtoResponse)
+		if err != nil {
+			return err
+		}
+		if decoded, err = message.Decode(body); err != nil {
+			return err
+		}
+	case strings.Contains(resp.ContentType(), encodingMsgpack):
+		if decoded, err = codec.DecodeMsgpack(body); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return fmt.Errorf("failed to convert decoded body to JSON: %w", err)
+	}
+	logging.Debug("decoded response body", "content_type", resp.ContentType(), "bytes", len(body))
+	resp.SetBody(data)
+	return nil
+}
+
+// protoMessage loads the message named by a "file.proto#Message" directive
+func (e *Executor) protoMessage(value, directive string) (*codec.Message, error) {
+	path, name, _ := strings.Cut(value, "#")
+	if path == "" || name == "" {
+		return nil, fmt.Errorf("@%s needs a .proto file and message, e.g. # @%s ./api.proto#User", directive, directive)
+	}
+
+	e.mu.Lock()
+	file, ok := e.protos[path]
+	e.mu.Unlock()
+	if !ok {
+		var err error
+		if file, err = codec.LoadProto(path); err != nil {
+			return nil, err
+		}
+		e.mu.Lock()
+		e.protos[path] = file
+		e.mu.Unlock()
+	}
+	return file.Message(name)
+}
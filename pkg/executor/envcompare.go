@@ -0,0 +1,251 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"postie/pkg/redact"
+)
+
+// Kinds of drift between environments running the same request
+const (
+	DriftNone    = "same"
+	DriftStatus  = "status"  // The statuses differ, or the request failed to send in some environment
+	DriftBody    = "body"    // The statuses match but the bodies differ
+	DriftSkipped = "skipped" // The request was skipped in some environment
+)
+
+// EnvironmentComparison compares the results of the same requests run in
+// several environments, the first being the reference
+type EnvironmentComparison struct {
+	Environments []string                        `json:"environments"`
+	Requests     []*EnvironmentRequestComparison `json:"requests"`
+	Summary      map[string]int                  `json:"summary"`
+}
+
+// EnvironmentRequestComparison compares one request across environments
+type EnvironmentRequestComparison struct {
+	Request     string              `json:"request"`
+	Drift       string              `json:"drift"`
+	Results     []EnvironmentResult `json:"results"` // In the order of the environments
+	Differences []BodyDifference    `json:"body_differences,omitempty"`
+}
+
+// EnvironmentResult is the outcome of a request in one environment
+type EnvironmentResult struct {
+	Environment string `json:"environment"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Status      string `json:"status,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+	Passed      bool   `json:"passed"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BodyDifference is a part of the response bodies that differs: a JSON
+// path such as $.items[0].id, or a line number for other bodies
+type BodyDifference struct {
+	Path   string   `json:"path"`
+	Values []string `json:"values"` // In the order of the environments; "" if absent
+}
+
+// Drifted reports whether any request differs between the environments
+func (c *EnvironmentComparison) Drifted() bool {
+	return len(c.Requests) > c.Summary[DriftNone]
+}
+
+// CompareEnvironments matches the results of runs of the same requests in
+// each environment by position and reports how they differ
+func CompareEnvironments(envs []string, runs [][]*ExecutionResult) *EnvironmentComparison {
+	comparison := &EnvironmentComparison{Environments: envs, Summary: make(map[string]int)}
+
+	count := 0
+	for _, results := range runs {
+		count = max(count, len(results))
+	}
+
+	for i := 0; i < count; i++ {
+		results := make([]*ExecutionResult, len(runs))
+		for env, run := range runs {
+			if i < len(run) {
+				results[env] = run[i]
+			}
+		}
+
+		entry := &EnvironmentRequestComparison{Request: fmt.Sprintf("Request %d", i+1)}
+		for env, result := range results {
+			entry.Results = append(entry.Results, newEnvironmentResult(envs[env], result))
+			if result != nil && result.Request != nil && result.Request.Name != "" {
+				entry.Request = result.Request.Name
+			}
+		}
+		entry.Drift = classifyDrift(entry, results)
+
+		comparison.Requests = append(comparison.Requests, entry)
+		comparison.Summary[entry.Drift]++
+	}
+
+	return comparison
+}
+
+// newEnvironmentResult summarizes a result; nil results count as skipped
+func newEnvironmentResult(env string, result *ExecutionResult) EnvironmentResult {
+	if result == nil {
+		return EnvironmentResult{Environment: env, Skipped: true}
+	}
+	entry := EnvironmentResult{
+		Environment: env,
+		StatusCode:  result.StatusCode,
+		Status:      result.Status,
+		DurationMs:  result.Duration.Milliseconds(),
+		Passed:      result.Passed(),
+		Skipped:     result.Skipped,
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+	}
+	return entry
+}
+
+// classifyDrift decides how a request differs between environments,
+// recording the body differences of requests whose statuses match
+func classifyDrift(entry *EnvironmentRequestComparison, results []*ExecutionResult) string {
+	first := entry.Results[0]
+	for _, result := range entry.Results {
+		if result.Skipped {
+			return DriftSkipped
+		}
+	}
+	for _, result := range entry.Results[1:] {
+		if result.StatusCode != first.StatusCode || (result.Error == "") != (first.Error == "") {
+			return DriftStatus
+		}
+	}
+
+	bodies := make([]string, len(results))
+	for i, result := range results {
+		if result.Response != nil {
+			bodies[i], _ = result.Response.Text()
+		}
+	}
+	if entry.Differences = diffBodies(bodies); len(entry.Differences) > 0 {
+		return DriftBody
+	}
+	return DriftNone
+}
+
+// diffBodies compares response bodies by JSON path if they are all JSON,
+// or line by line otherwise
+func diffBodies(bodies []string) []BodyDifference {
+	values := make([]map[string]string, len(bodies))
+	for i, body := range bodies {
+		var decoded interface{}
+		if json.Unmarshal([]byte(body), &decoded) != nil {
+			return diffLines(bodies)
+		}
+		values[i] = make(map[string]string)
+		flattenJSON("$", decoded, values[i])
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, flat := range values {
+		for path := range flat {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	var differences []BodyDifference
+	for _, path := range paths {
+		difference := BodyDifference{Path: path}
+		for _, flat := range values {
+			difference.Values = append(difference.Values, flat[path])
+		}
+		if differs(difference.Values) {
+			differences = append(differences, difference)
+		}
+	}
+	return differences
+}
+
+// flattenJSON records the JSON-encoded leaf values of a decoded document
+// by path
+func flattenJSON(path string, value interface{}, flat map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			flat[path] = "{}"
+		}
+		for key, child := range v {
+			flattenJSON(path+"."+key, child, flat)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			flat[path] = "[]"
+		}
+		for i, child := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", path, i), child, flat)
+		}
+	default:
+		encoded, _ := json.Marshal(v)
+		flat[path] = string(encoded)
+	}
+}
+
+// diffLines compares bodies line by line
+func diffLines(bodies []string) []BodyDifference {
+	lines := make([][]string, len(bodies))
+	count := 0
+	for i, body := range bodies {
+		if body != "" {
+			lines[i] = strings.Split(body, "\n")
+		}
+		count = max(count, len(lines[i]))
+	}
+
+	var differences []BodyDifference
+	for n := 0; n < count; n++ {
+		difference := BodyDifference{Path: fmt.Sprintf("line %d", n+1)}
+		for i := range lines {
+			value := ""
+			if n < len(lines[i]) {
+				value = lines[i][n]
+			}
+			difference.Values = append(difference.Values, value)
+		}
+		if differs(difference.Values) {
+			differences = append(differences, difference)
+		}
+	}
+	return differences
+}
+
+// differs reports whether any value differs from the first
+func differs(values []string) bool {
+	for _, value := range values[1:] {
+		if value != values[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact masks the secret values of one environment, given by its
+// position, in the comparison
+func (c *EnvironmentComparison) Redact(env int, redactor *redact.Redactor) {
+	if redactor.Empty() {
+		return
+	}
+	for _, request := range c.Requests {
+		request.Results[env].Error = redactor.Redact(request.Results[env].Error)
+		for i := range request.Differences {
+			request.Differences[i].Values[env] = redactor.Redact(request.Differences[i].Values[env])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"postie/pkg/httprequest"
+)
+
+// errConnectionTimeout is the cause of requests cancelled by
+// # @connection-timeout
+var errConnectionTimeout = errors.New("connection timeout")
+
+// NotSentError is an error that kept a request from being sent, such as an
+// unresolved variable with strict variables, an invalid directive, a
+// template error or a hook that blocked the request
+type NotSentError struct {
+	Err error
+}
+
+func (e *NotSentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NotSentError) Unwrap() error {
+	return e.Err
+}
+
+// notSent returns the result of a request that err kept from being sent
+func notSent(request *httprequest.Request, err error) (*ExecutionResult, error) {
+	err = &NotSentError{Err: err}
+	return &ExecutionResult{Request: request, Error: err}, err
+}
+
+// ClassifyError returns the kind of error that kept a request from getting
+// a response, one of the httprequest.Error kinds, or "" if it is none of
+// them
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return httprequest.ErrorTimeout
+		}
+		return httprequest.ErrorDNS
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errConnectionTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return httprequest.ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return httprequest.ErrorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return httprequest.ErrorConnectionReset
+	case isTLSError(err):
+		return httprequest.ErrorTLS
+	}
+
+	return ""
+}
+
+// isTLSError reports whether err comes from the TLS handshake or the
+// server's certificate
+func isTLSError(err error) bool {
+	var (
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+		verifyErr   *tls.CertificateVerificationError
+		unknownErr  x509.UnknownAuthorityError
+		invalidErr  x509.CertificateInvalidError
+		hostnameErr x509.HostnameError
+		rootsErr    x509.SystemRootsError
+	)
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownErr) || errors.As(err, &invalidErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &rootsErr)
+}
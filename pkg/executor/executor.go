@@ -1,14 +1,34 @@
 package executor
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"postie/pkg/client"
+	"postie/pkg/codec"
 	"postie/pkg/environment"
+	"postie/pkg/faker"
+	"postie/pkg/fault"
+	"postie/pkg/httpcache"
 	"postie/pkg/httprequest"
+	"postie/pkg/listener"
+	"postie/pkg/logging"
+	"postie/pkg/redact"
 	"postie/pkg/responses"
+	"postie/pkg/schema"
 	"postie/pkg/scripting"
+	"postie/pkg/session"
 )
 
 // Executor executes HTTP requests with environment variable resolution
@@ -16,9 +36,35 @@ type Executor struct {
 	client          *client.APIClient
 	environment     *environment.ResolvedEnvironment
 	verbose         bool
-	globals         *scripting.GlobalStore // Global variables for response handlers
-	responseStorage *responses.Storage     // Response storage
-	saveResponses   bool                   // Whether to save responses
+	globals         *scripting.GlobalStore    // Global variables for response handlers
+	envStore        *scripting.EnvStore       // Persisted client.env variables for the environment
+	responseStorage *responses.Storage        // Response storage
+	saveResponses   bool                      // Whether to save responses
+	redactor        *redact.Redactor          // Masks secret values in saved responses
+	requestsFile    *httprequest.RequestsFile // File being executed, for @name = value variables
+	scriptLimits    scripting.Limits          // Limits for response handler scripts
+	transport       client.TransportConfig    // Connection settings, also used by script http() calls
+	cache           bool                      // Responses go through the HTTP cache
+	readStdin       func() ([]byte, error)    // Reads standard input once, for "< -" bodies
+	hooks           []Hook                    // Hooks run around each request
+	correlation     []string                  // Request headers reported with each result
+	strictVariables bool                      // Fail requests that use undefined variables
+	promptVariable  func(name string) (string, bool, error)
+	providers       map[string]VariableProvider // Providers of {{$name}} dynamic variables
+	prompted        map[string]interface{}      // Values entered for undefined variables
+	openapi         *schema.Spec                // Spec to check responses against
+	specs           map[string]*schema.Spec     // Specs loaded for # @openapi directives
+	schemas         map[string]*schema.Schema   // Schemas loaded for "?? body matches-schema"
+	protos          map[string]*codec.ProtoFile // .proto files loaded for # @proto directives
+	sessionStore    *session.Store              // Saved # @session logins (nil to keep sessions in memory)
+	sessions        map[string]*session.Session // Sessions loaded or captured in this run
+	loggingIn       map[string]bool             // Sessions whose login requests led to this executor
+	listener        *listener.Listener          // Listener whose requests scripts can see (nil for none)
+	scriptHTTPCheck func(method, url string) error
+
+	// mu guards prompted, specs, schemas, protos and sessions, which an
+	// executor shares with its workers
+	mu *sync.Mutex
 }
 
 // ExecutorConfig holds configuration for the executor
@@ -27,6 +73,56 @@ type ExecutorConfig struct {
 	Verbose       bool
 	SaveResponses bool                     // Enable response saving
 	StorageConfig *responses.StorageConfig // Response storage configuration
+	ShowSecrets   bool                     // Disable masking of private environment values
+	ScriptTimeout time.Duration            // Response handler time limit (0 for the default, negative for none)
+	EnvStore      *scripting.EnvStore      // Persisted client.env variables (nil to disable)
+	Sessions      *session.Store           // Saved # @session logins (nil to keep sessions in memory)
+	Hooks         []Hook                   // Hooks run around each request
+
+	// CorrelationHeaders are request headers, such as X-Request-Id, whose
+	// values are reported with each result
+	CorrelationHeaders []string
+
+	// StrictVariables fails requests that use undefined variables instead
+	// of sending the {{name}} reference as is
+	StrictVariables bool
+
+	// PromptVariable, if set, is asked for the value of each undefined
+	// variable. Answers are reused for the rest of the run, and masked in
+	// output if secret is true.
+	PromptVariable func(name string) (value string, secret bool, err error)
+
+	// OpenAPI, if set, is the spec that responses are checked against.
+	// Requests that match none of its operations aren't checked.
+	OpenAPI *schema.Spec
+
+	// Transport tunes connection pooling. Executors with the same settings
+	// share connections. Its Resolve mappings win over the environment's
+	// $hosts.
+	Transport client.TransportConfig
+
+	// Cache, if set, caches GET and HEAD responses between runs and
+	// revalidates them with conditional requests
+	Cache *httpcache.Store
+
+	// FakerSeed seeds the {{$faker.name}} generators, so that runs with
+	// the same seed send the same data (0 for a random seed)
+	FakerSeed int64
+
+	// Stdin is read for "< -" bodies (os.Stdin if nil)
+	Stdin io.Reader
+
+	// Faults, if set, injects delays, dropped connections and error
+	// responses into the requests
+	Faults *fault.Injector
+
+	// Listener, if set, is the run's webhook listener, whose requests
+	// response handlers get from client.listener
+	Listener *listener.Listener
+
+	// ScriptHTTPCheck, if set, checks the http() calls of response
+	// handlers before they are sent, such as against the safe hosts
+	ScriptHTTPCheck func(method, url string) error
 }
 
 // NewExecutor creates a new request executor
@@ -54,16 +150,119 @@ func NewExecutor(env *environment.ResolvedEnvironment, config *ExecutorConfig) *
 		storage = responses.NewStorage(config.StorageConfig)
 	}
 
-	return &Executor{
+	var redactor *redact.Redactor
+	if env != nil && !config.ShowSecrets {
+		redactor = redact.New(env.SecretValues()...)
+	}
+
+	scriptLimits := scripting.DefaultLimits()
+	if config.ScriptTimeout < 0 {
+		scriptLimits.Timeout = 0
+	} else if config.ScriptTimeout > 0 {
+		scriptLimits.Timeout = config.ScriptTimeout
+	}
+
+	transport := config.Transport
+	if env != nil && len(env.Defaults.Hosts) > 0 {
+		transport.Resolve = make(map[string]string, len(env.Defaults.Hosts)+len(config.Transport.Resolve))
+		for host, addr := range env.Defaults.Hosts {
+			transport.Resolve[host] = addr
+		}
+		for host, addr := range config.Transport.Resolve {
+			transport.Resolve[host] = addr
+		}
+	}
+
+	stdin := config.Stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	var roundTripper http.RoundTripper = logging.NewTraceTransport(client.SharedTransport(transport))
+	if config.Faults != nil {
+		roundTripper = config.Faults.Transport(roundTripper)
+	}
+	if config.Cache != nil {
+		roundTripper = httpcache.NewTransport(config.Cache, roundTripper)
+	}
+
+	e := &Executor{
 		client: client.NewClient(&client.Config{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: roundTripper,
 		}),
+		cache:           config.Cache != nil,
 		environment:     env,
 		verbose:         config.Verbose,
 		globals:         scripting.NewGlobalStore(),
+		envStore:        config.EnvStore,
 		responseStorage: storage,
 		saveResponses:   config.SaveResponses,
+		redactor:        redactor,
+		scriptLimits:    scriptLimits,
+		transport:       transport,
+		readStdin:       sync.OnceValues(func() ([]byte, error) { return io.ReadAll(stdin) }),
+		hooks:           config.Hooks,
+		correlation:     config.CorrelationHeaders,
+		strictVariables: config.StrictVariables,
+		promptVariable:  config.PromptVariable,
+		prompted:        make(map[string]interface{}),
+		openapi:         config.OpenAPI,
+		specs:           make(map[string]*schema.Spec),
+		schemas:         make(map[string]*schema.Schema),
+		protos:          make(map[string]*codec.ProtoFile),
+		sessionStore:    config.Sessions,
+		sessions:        make(map[string]*session.Session),
+		loggingIn:       make(map[string]bool),
+		listener:        config.Listener,
+		scriptHTTPCheck: config.ScriptHTTPCheck,
+		mu:              &sync.Mutex{},
+	}
+
+	fake := faker.New(config.FakerSeed)
+	e.AddVariableProvider("faker", func(name string, args []string) (string, bool, error) {
+		value, err := fake.Generate(strings.TrimPrefix(name, "faker."), args)
+		return value, false, err
+	})
+	return e
+}
+
+// Worker returns an executor that runs requests concurrently with e, for
+// parallel runs. It shares e's client, variables, sessions, hooks and
+// loaded files, and has its own file being executed, so that workers can
+// call ExecuteSelected with different files at once. Hooks and variable
+// providers must be added before workers are created.
+func (e *Executor) Worker() *Executor {
+	worker := *e
+	return &worker
+}
+
+// Close waits for background work, such as applying response retention
+// limits, to finish
+func (e *Executor) Close() {
+	if e.responseStorage != nil {
+		e.responseStorage.Wait()
+	}
+}
+
+// Redactor returns the redactor for secret environment values (nil if disabled)
+func (e *Executor) Redactor() *redact.Redactor {
+	return e.redactor
+}
+
+// ResolveRequest returns a request of a file as it would be sent, with its
+// variables expanded and environment defaults applied, without sending it
+func (e *Executor) ResolveRequest(requestsFile *httprequest.RequestsFile, request *httprequest.Request) (*httprequest.Request, error) {
+	e.requestsFile = requestsFile
+	expanded, err := e.expandRequestVariables(request)
+	if err != nil {
+		return nil, err
 	}
+	// Built-in # @auth schemes add headers, as when the request is sent
+	if err := applyAuth(expanded); err != nil {
+		return nil, err
+	}
+	return expanded, nil
 }
 
 // ExecuteRequest executes a single HTTP request
@@ -72,38 +271,213 @@ func (e *Executor) ExecuteRequest(request *httprequest.Request) (*ExecutionResul
 		return nil, fmt.Errorf("request cannot be nil")
 	}
 
+	// # @only-env and # @skip-if keep requests out of some environments
+	reason, err := e.skipReason(request)
+	if err != nil {
+		e.runOnError(request, err)
+		return notSent(request, err)
+	}
+	if reason != "" {
+		logging.Verbose("skipping request", "name", request.Name, "reason", reason)
+		return &ExecutionResult{Request: request, Skipped: true, SkipReason: reason}, nil
+	}
+
+	result, err := e.executeRequest(request, true)
+	if result != nil {
+		result.Correlation = e.correlationHeaders(result.Request)
+	}
+	if err != nil {
+		failed := request
+		if result != nil && result.Request != nil {
+			failed = result.Request
+		}
+		e.runOnError(failed, err)
+	}
+
+	return result, err
+}
+
+// correlationHeaders returns the correlation headers sent with a request
+func (e *Executor) correlationHeaders(request *httprequest.Request) []httprequest.Header {
+	if request == nil {
+		return nil
+	}
+	var headers []httprequest.Header
+	for _, name := range e.correlation {
+		for _, header := range request.Headers {
+			if strings.EqualFold(header.Name, name) {
+				headers = append(headers, header)
+				break
+			}
+		}
+	}
+	return headers
+}
+
+// executeRequest expands, sends and post-processes a request. With
+// renewSessions, a 401 response to a request using saved sessions logs in
+// again and sends the request once more.
+func (e *Executor) executeRequest(request *httprequest.Request, renewSessions bool) (*ExecutionResult, error) {
 	// Expand variables in the request
 	expandedRequest, err := e.expandRequestVariables(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to expand variables: %w", err)
+		err = fmt.Errorf("failed to expand variables: %w", err)
+		return notSent(request, err)
+	}
+
+	if err := applyAuth(expandedRequest); err != nil {
+		return notSent(expandedRequest, err)
+	}
+
+	if err := e.runBeforeRequest(expandedRequest); err != nil {
+		return notSent(expandedRequest, err)
 	}
 
 	// Build the HTTP request using the client
 	req, err := e.buildClientRequest(expandedRequest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %w", err)
+		err = fmt.Errorf("failed to build request: %w", err)
+		return notSent(expandedRequest, err)
+	}
+
+	// Per-request timeouts from # @timeout and # @connection-timeout
+	ctx, cancel, err := requestContext(expandedRequest)
+	if err != nil {
+		return notSent(expandedRequest, err)
+	}
+	defer cancel()
+	var cacheOutcome *httpcache.Outcome
+	if e.cache {
+		cacheOutcome = &httpcache.Outcome{}
+		ctx = httpcache.WithOutcome(ctx, cacheOutcome)
+	}
+	req.Context(ctx)
+
+	expectedStatus, _, err := expandedRequest.ExpectedStatus()
+	if err != nil {
+		return notSent(expandedRequest, err)
+	}
+	expectedErrors, _, err := expandedRequest.ExpectedErrors()
+	if err != nil {
+		return notSent(expandedRequest, err)
+	}
+	pagination, _, err := expandedRequest.Pagination()
+	if err != nil {
+		return notSent(expandedRequest, err)
+	}
+	poll, _, err := expandedRequest.Poll()
+	if err != nil {
+		return notSent(expandedRequest, err)
 	}
 
+	logging.Verbose("executing request", "name", expandedRequest.Name, "method", expandedRequest.Method, "url", expandedRequest.URL.Raw)
+
 	// Execute the request
 	startTime := time.Now()
 	resp, err := req.Execute()
+	if err == nil {
+		// Read the body while the request context is still live
+		if _, bodyErr := resp.GetBody(); bodyErr != nil {
+			err = bodyErr
+		} else if decodeErr := e.decodeResponse(expandedRequest, resp); decodeErr != nil {
+			// Keep the raw body if it can't be decoded
+			logging.Warn("failed to decode response body", "error", decodeErr)
+		}
+	}
 	duration := time.Since(startTime)
 
+	// Report why the request was cancelled (e.g. connection timeout)
+	if cause := context.Cause(ctx); err != nil && cause != nil && cause != context.Canceled && cause != context.DeadlineExceeded {
+		err = fmt.Errorf("request failed: %w", cause)
+	}
+
 	if err != nil {
+		logging.Debug("request failed", "url", expandedRequest.URL.Raw, "duration", duration, "error", err)
 		return &ExecutionResult{
-			Request:  expandedRequest,
-			Error:    err,
-			Duration: duration,
+			Request:   expandedRequest,
+			Error:     err,
+			ErrorKind: ClassifyError(err),
+			Duration:  duration,
+
+			ExpectedErrors: expectedErrors,
 		}, err
 	}
 
+	logging.Debug("response received", "url", expandedRequest.URL.Raw, "status", resp.Response.StatusCode, "duration", duration)
+
+	// The server may have revoked a saved session's token before it expired
+	if renewSessions && resp.Response.StatusCode == http.StatusUnauthorized && e.sessionStore != nil {
+		if names := e.sessionReferences(request); len(names) > 0 {
+			if err := e.renewSessions(names, startTime); err != nil {
+				logging.Warn("failed to renew sessions", "request", expandedRequest.Name, "error", err)
+			} else {
+				// Let hooks finish the rejected attempt, such as its span
+				e.runAfterResponse(&ExecutionResult{Request: expandedRequest, Response: resp, Duration: duration, StatusCode: resp.Response.StatusCode, Status: resp.Status})
+				return e.executeRequest(request, false)
+			}
+		}
+	}
+
+	// Send a # @poll request again until its condition holds
+	var polled *PollResult
+	if poll != nil {
+		resp, polled, err = e.poll(expandedRequest, poll, resp)
+		duration = time.Since(startTime)
+		if err != nil {
+			logging.Debug("polling failed", "url", expandedRequest.URL.Raw, "attempts", polled.Attempts, "error", err)
+			return &ExecutionResult{
+				Request:   expandedRequest,
+				Error:     err,
+				ErrorKind: ClassifyError(err),
+				Duration:  duration,
+				Poll:      polled,
+
+				ExpectedErrors: expectedErrors,
+			}, err
+		}
+	}
+
+	// Follow the next pages of a # @paginate request
+	var pages *PaginationResult
+	if pagination != nil {
+		resp, pages, err = e.paginate(ctx, expandedRequest, pagination, resp)
+		duration = time.Since(startTime)
+		if err != nil {
+			logging.Debug("pagination failed", "url", expandedRequest.URL.Raw, "pages", pages.Pages, "error", err)
+			return &ExecutionResult{
+				Request:    expandedRequest,
+				Error:      err,
+				ErrorKind:  ClassifyError(err),
+				Duration:   duration,
+				Pagination: pages,
+
+				ExpectedErrors: expectedErrors,
+			}, err
+		}
+	}
+
 	// Build execution result
 	result := &ExecutionResult{
 		Request:    expandedRequest,
 		Response:   resp,
 		Duration:   duration,
+		Timings:    resp.Timings(),
+		Cache:      cacheOutcome,
 		StatusCode: resp.Response.StatusCode,
 		Status:     resp.Status,
+
+		ExpectedStatus: expectedStatus,
+		ExpectedErrors: expectedErrors,
+		Pagination:     pages,
+		Poll:           polled,
+		Deprecation:    deprecationOf(resp.Header),
+	}
+
+	// Values the handler of a # @session request sets are saved as the session
+	finishSession, err := e.startSession(expandedRequest)
+	if err != nil {
+		result.Error = err
+		return result, err
 	}
 
 	// Execute response handler if present
@@ -113,78 +487,225 @@ func (e *Executor) ExecuteRequest(request *httprequest.Request) (*ExecutionResul
 			envVars = e.environment.Variables
 		}
 
-		scriptResult := scripting.ExecuteResponseHandler(
-			expandedRequest.ResponseHandler,
-			resp,
-			expandedRequest,
-			envVars,
-			e.globals,
-		)
+		scriptResult := scripting.ExecuteResponseHandler(expandedRequest.ResponseHandler, &scripting.ScriptContext{
+			Request:   expandedRequest,
+			Response:  resp,
+			Env:       envVars,
+			Globals:   e.globals,
+			EnvStore:  e.envStore,
+			Limits:    e.scriptLimits,
+			Transport: e.transport,
+			Listener:  e.listener,
+			CheckHTTP: e.scriptHTTPCheck,
+		})
 
 		result.ScriptResult = scriptResult
+
+		if e.envStore != nil {
+			if err := e.envStore.Save(); err != nil {
+				logging.Warn("failed to save env store", "error", err)
+			}
+		}
+	}
+
+	if finishSession != nil {
+		finishSession(resp)
+	}
+
+	// Report failed "??" assertions and OpenAPI violations with the script's
+	assertions := e.checkAssertions(expandedRequest, resp)
+	assertions = append(assertions, e.checkOpenAPI(expandedRequest, resp)...)
+	if len(assertions) > 0 {
+		if result.ScriptResult == nil {
+			result.ScriptResult = &scripting.ScriptExecutionResult{}
+		}
+		result.ScriptResult.Assertions = append(result.ScriptResult.Assertions, assertions...)
 	}
 
-	// Save response if enabled
-	if e.saveResponses && e.responseStorage != nil {
+	// Save response if enabled (# @no-log opts a request out)
+	if e.saveResponses && e.responseStorage != nil && !expandedRequest.HasDirective(httprequest.DirectiveNoLog) {
 		storedResponse, err := responses.FromClientResponse(resp, expandedRequest, duration)
 		if err == nil {
+			storedResponse.Redact(e.redactor)
 			filePath, err := e.responseStorage.Save(storedResponse)
 			if err == nil {
 				result.ResponseFilePath = filePath
+			} else {
+				// Don't fail the request if save fails, just skip
+				logging.Warn("failed to save response", "error", err)
 			}
-			// Don't fail the request if save fails, just skip
 		}
 	}
 
+	if err := e.runAfterResponse(result); err != nil {
+		result.Error = err
+		return result, err
+	}
+
 	return result, nil
 }
 
+// requestContext builds the context for a request, applying its timeout directives.
+// The returned cancel function must always be called.
+func requestContext(request *httprequest.Request) (context.Context, context.CancelFunc, error) {
+	timeout, hasTimeout, err := request.DirectiveDuration(httprequest.DirectiveTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	connectTimeout, hasConnectTimeout, err := request.DirectiveDuration(httprequest.DirectiveConnectionTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if hasTimeout && timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	if hasConnectTimeout && connectTimeout > 0 {
+		// Cancel the request unless a connection is obtained in time
+		connectCtx, connectCancel := context.WithCancelCause(ctx)
+		timer := time.AfterFunc(connectTimeout, func() {
+			connectCancel(fmt.Errorf("%w after %v", errConnectionTimeout, connectTimeout))
+		})
+		connectCtx = httptrace.WithClientTrace(connectCtx, &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) { timer.Stop() },
+		})
+
+		parentCancel := cancel
+		ctx, cancel = connectCtx, func() {
+			timer.Stop()
+			connectCancel(nil)
+			parentCancel()
+		}
+	}
+
+	return ctx, cancel, nil
+}
+
 // ExecuteFile executes all requests in an HTTP request file
 func (e *Executor) ExecuteFile(requestsFile *httprequest.RequestsFile, filter string) ([]*ExecutionResult, error) {
 	if requestsFile == nil {
 		return nil, fmt.Errorf("requests file cannot be nil")
 	}
 
-	requestsToRun := requestsFile.Requests
+	selected, err := e.SelectRequests(requestsFile, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter requests: %w", err)
+	}
+	return e.ExecuteSelected(requestsFile, selected)
+}
 
-	// Apply filter if specified
-	if filter != "" {
-		filtered, err := e.filterRequests(requestsFile.Requests, filter)
-		if err != nil {
-			return nil, fmt.Errorf("failed to filter requests: %w", err)
+// SelectRequests returns the indexes of the requests matching a filter, or
+// of every request if the filter is empty
+func (e *Executor) SelectRequests(requestsFile *httprequest.RequestsFile, filter string) ([]int, error) {
+	if filter == "" {
+		selected := make([]int, len(requestsFile.Requests))
+		for i := range selected {
+			selected[i] = i
 		}
-		requestsToRun = filtered
+		return selected, nil
+	}
+	return e.filterRequests(requestsFile.Requests, filter)
+}
+
+// expandRequestVariables expands all variables in a request and reads its
+// body file
+func (e *Executor) expandRequestVariables(request *httprequest.Request) (*httprequest.Request, error) {
+	expanded, err := e.expandVariables(request)
+	if err != nil {
+		return nil, err
+	}
+	// Body files are read after expansion, as they're sent as is
+	return e.withFileBody(expanded)
+}
+
+// expandVariables returns a copy of a request with the environment's
+// defaults applied and its variables expanded
+func (e *Executor) expandVariables(request *httprequest.Request) (*httprequest.Request, error) {
+	request = e.applyDefaults(request)
+
+	if err := e.loadSessions(request); err != nil {
+		return nil, err
+	}
+
+	// # @template bodies are rendered before variables are expanded
+	request, err := e.withTemplateBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a combined environment with file variables, env vars and globals
+	expanded := expandRequest(request, e.getCombinedEnvironment(request))
+
+	missing := unresolvedVariables(expanded)
+	if len(missing) == 0 {
+		return expanded, nil
 	}
 
-	// Execute each request
-	results := make([]*ExecutionResult, 0, len(requestsToRun))
-	for _, request := range requestsToRun {
-		result, err := e.ExecuteRequest(&request)
-		if err != nil && e.verbose {
-			fmt.Printf("Error executing request: %v\n", err)
+	// {{$name}} variables come from registered providers
+	expanded, err = e.expandDynamicVariables(expanded, missing)
+	if err != nil {
+		return nil, err
+	}
+	if missing = unresolvedVariables(expanded); len(missing) == 0 {
+		return expanded, nil
+	}
+
+	if e.promptVariable != nil {
+		// One prompt at a time; another worker may have asked already
+		e.mu.Lock()
+		for _, name := range missing {
+			if _, ok := e.prompted[name]; ok {
+				continue
+			}
+			value, secret, err := e.promptVariable(name)
+			if err != nil {
+				e.mu.Unlock()
+				return nil, fmt.Errorf("variable %s: %w", name, err)
+			}
+			e.prompted[name] = value
+			if secret && e.redactor != nil {
+				e.redactor.Add(value)
+			}
 		}
-		results = append(results, result)
+		prompted := make(map[string]interface{}, len(e.prompted))
+		for k, v := range e.prompted {
+			prompted[k] = v
+		}
+		e.mu.Unlock()
+		expanded = expandRequest(expanded, &environment.ResolvedEnvironment{Variables: prompted})
+		missing = unresolvedVariables(expanded)
+	}
+
+	if e.strictVariables && len(missing) > 0 {
+		return nil, fmt.Errorf("unresolved variables: {{%s}}", strings.Join(missing, "}}, {{"))
 	}
 
-	return results, nil
+	return expanded, nil
 }
 
-// expandRequestVariables expands all variables in a request
-func (e *Executor) expandRequestVariables(request *httprequest.Request) (*httprequest.Request, error) {
+// expandRequest returns a copy of a request with variables expanded
+func expandRequest(request *httprequest.Request, combinedEnv *environment.ResolvedEnvironment) *httprequest.Request {
 	// Create a copy of the request
 	expanded := *request
 
 	resolver := environment.NewResolver()
 
-	// Create a combined environment with both env vars and globals
-	combinedEnv := e.getCombinedEnvironment()
-
 	// Expand URL
 	if request.URL != nil {
 		expanded.URL = &httprequest.URL{
 			Raw:       resolver.ExpandString(request.URL.Raw, combinedEnv),
 			Variables: request.URL.Variables,
 		}
+
+		// Keep query parameters in order, including repeated names
+		for _, param := range request.URL.Query {
+			expanded.URL.Query = append(expanded.URL.Query, httprequest.QueryParam{
+				Name:  resolver.ExpandString(param.Name, combinedEnv),
+				Value: resolver.ExpandString(param.Value, combinedEnv),
+			})
+		}
 	}
 
 	// Expand headers
@@ -198,37 +719,186 @@ func (e *Executor) expandRequestVariables(request *httprequest.Request) (*httpre
 		}
 	}
 
+	// Expand the credentials of a # @auth directive
+	if value, ok := request.Metadata[httprequest.DirectiveAuth]; ok {
+		expanded.Metadata = maps.Clone(request.Metadata)
+		expanded.Metadata[httprequest.DirectiveAuth] = resolver.ExpandString(value, combinedEnv)
+	}
+
 	// Expand body content
 	if request.Body != nil {
 		expanded.Body = &httprequest.RequestBody{
 			Type:        request.Body.Type,
 			ContentType: request.Body.ContentType,
 			Content:     resolver.ExpandString(request.Body.Content, combinedEnv),
+			FilePath:    resolver.ExpandString(request.Body.FilePath, combinedEnv),
 			Variables:   request.Body.Variables,
 		}
 	}
 
-	return &expanded, nil
+	return &expanded
 }
 
-// getCombinedEnvironment merges environment variables and global variables
-func (e *Executor) getCombinedEnvironment() *environment.ResolvedEnvironment {
-	// Start with environment variables
-	vars := make(map[string]interface{})
+// variableRefPattern matches {{variable}} references
+var variableRefPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// unresolvedVariables returns the names of variables left in an expanded
+// request's URL, headers, body and # @auth directive, in order of first use
+func unresolvedVariables(request *httprequest.Request) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, text := range requestTexts(request) {
+		for _, match := range variableRefPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	return names
+}
+
+// requestTexts returns the parts of a request that may reference variables
+func requestTexts(request *httprequest.Request) []string {
+	var texts []string
+	if request.URL != nil {
+		texts = append(texts, request.URL.Raw)
+		for _, param := range request.URL.Query {
+			texts = append(texts, param.Name, param.Value)
+		}
+	}
+	for _, header := range request.Headers {
+		texts = append(texts, header.Value)
+	}
+	if request.Body != nil {
+		texts = append(texts, request.Body.Content)
+	}
+	if value, ok := request.Metadata[httprequest.DirectiveAuth]; ok {
+		texts = append(texts, value)
+	}
+	return texts
+}
+
+// applyDefaults returns a copy of a request with the environment's base URL
+// and default headers applied. Absolute URLs and headers the request sets
+// itself are kept.
+func (e *Executor) applyDefaults(request *httprequest.Request) *httprequest.Request {
+	if e.environment == nil {
+		return request
+	}
+	defaults := e.environment.Defaults
+	if defaults.BaseURL == "" && len(defaults.Headers) == 0 {
+		return request
+	}
+
+	withDefaults := *request
+
+	if defaults.BaseURL != "" && request.URL != nil && strings.HasPrefix(request.URL.Raw, "/") {
+		url := *request.URL
+		url.Raw = strings.TrimRight(defaults.BaseURL, "/") + url.Raw
+		withDefaults.URL = &url
+	}
+
+	names := make([]string, 0, len(defaults.Headers))
+	for name := range defaults.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	withDefaults.Headers = append([]httprequest.Header(nil), request.Headers...)
+	for _, name := range names {
+		if !hasHeader(request.Headers, name) {
+			withDefaults.Headers = append(withDefaults.Headers, httprequest.Header{Name: name, Value: defaults.Headers[name]})
+		}
+	}
+
+	return &withDefaults
+}
+
+// hasHeader reports whether headers include one with the given name
+func hasHeader(headers []httprequest.Header, name string) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValue returns the first value of a header, or "" if it isn't set
+func headerValue(headers []httprequest.Header, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// getCombinedEnvironment merges file variables, environment variables,
+// client.env variables, global variables and --var overrides for a request
+// (later sources take precedence)
+func (e *Executor) getCombinedEnvironment(request *httprequest.Request) *environment.ResolvedEnvironment {
+	// Answers to missing variable prompts, then environment variables,
+	// overridden by client.env and global variables
+	base := make(map[string]interface{})
+	e.mu.Lock()
+	for k, v := range e.prompted {
+		base[k] = v
+	}
+	e.mu.Unlock()
+	for k, v := range e.sessionVariables() {
+		base[k] = v
+	}
+	if e.listener != nil {
+		base[listener.URLVariable] = e.listener.URL()
+	}
 	if e.environment != nil {
 		for k, v := range e.environment.Variables {
-			vars[k] = v
+			base[k] = v
+		}
+	}
+	if e.envStore != nil {
+		for k, v := range e.envStore.GetAll() {
+			base[k] = v
 		}
 	}
-
-	// Override/add global variables
 	if e.globals != nil {
 		globals := e.globals.GetAll()
 		for k, v := range globals {
-			vars[k] = v
+			base[k] = v
+		}
+	}
+	if e.environment != nil {
+		// --var overrides win even over values set by response handlers
+		for k, v := range e.environment.Variables {
+			if e.environment.IsOverride(k) {
+				base[k] = v
+			}
+		}
+	}
+
+	// File variables defined above the request. Each value may reference
+	// environment variables and earlier file variables.
+	vars := make(map[string]interface{})
+	if e.requestsFile != nil {
+		resolver := environment.NewResolver()
+		for _, variable := range e.requestsFile.VariablesBefore(request.LineNumber) {
+			scope := make(map[string]interface{}, len(vars)+len(base))
+			for k, v := range vars {
+				scope[k] = v
+			}
+			for k, v := range base {
+				scope[k] = v
+			}
+			vars[variable.Name] = resolver.ExpandString(variable.Value, &environment.ResolvedEnvironment{Variables: scope})
 		}
 	}
 
+	for k, v := range base {
+		vars[k] = v
+	}
+
 	return &environment.ResolvedEnvironment{
 		Name:      "combined",
 		Variables: vars,
@@ -263,21 +933,31 @@ func (e *Executor) buildClientRequest(request *httprequest.Request) (*client.Req
 		return nil, fmt.Errorf("unsupported HTTP method: %s", request.Method)
 	}
 
-	// Add headers
+	// Add headers; repeated names are sent as separate header fields
 	for _, header := range request.Headers {
-		req.Header(header.Name, header.Value)
+		req.AddHeader(header.Name, header.Value)
 	}
 
 	// Add body if present
 	if request.Body != nil && request.Body.Content != "" {
-		// Determine content type
-		contentType := request.Body.ContentType
+		// Determine content type; a Content-Type header wins
+		contentType := headerValue(request.Headers, "Content-Type")
+		if contentType == "" {
+			contentType = request.Body.ContentType
+		}
 		if contentType == "" {
 			contentType = "text/plain"
 		}
 
 		// Set body based on content type
-		if contentType == "application/json" || contentType == "text/json" {
+		encoded, encodedType, err := e.encodeBody(request)
+		if err != nil {
+			return nil, err
+		}
+		if encoded != nil {
+			req.Body(bytes.NewReader(encoded))
+			req.Header("Content-Type", encodedType)
+		} else if contentType == "application/json" || contentType == "text/json" {
 			req.Text(request.Body.Content)
 			req.Header("Content-Type", "application/json")
 		} else {
@@ -289,26 +969,36 @@ func (e *Executor) buildClientRequest(request *httprequest.Request) (*client.Req
 	return req, nil
 }
 
-// filterRequests filters requests by name or number
-func (e *Executor) filterRequests(requests []httprequest.Request, filter string) ([]httprequest.Request, error) {
-	var filtered []httprequest.Request
+// filterRequests returns the indexes of the requests matching a name or number
+func (e *Executor) filterRequests(requests []httprequest.Request, filter string) ([]int, error) {
+	var filtered []int
+
+	// An exact name (such as a # @name ID) selects just that request
+	for i, request := range requests {
+		if request.Name == filter {
+			filtered = append(filtered, i)
+		}
+	}
+	if len(filtered) > 0 {
+		return filtered, nil
+	}
 
 	for i, request := range requests {
 		// Check if filter matches request name
 		if request.Name != "" && containsIgnoreCase(request.Name, filter) {
-			filtered = append(filtered, request)
+			filtered = append(filtered, i)
 			continue
 		}
 
 		// Check if filter matches request number (1-based)
 		if fmt.Sprintf("%d", i+1) == filter {
-			filtered = append(filtered, request)
+			filtered = append(filtered, i)
 			continue
 		}
 	}
 
 	if len(filtered) == 0 {
-		return nil, fmt.Errorf("no requests match filter: %s", filter)
+		return nil, fmt.Errorf("%w filter: %s", ErrNoMatch, filter)
 	}
 
 	return filtered, nil
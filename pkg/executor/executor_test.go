@@ -0,0 +1,1380 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/codec"
+	"postie/pkg/environment"
+	"postie/pkg/httprequest"
+	"postie/pkg/listener"
+	"postie/pkg/schema"
+	"postie/pkg/session"
+)
+
+func TestExecutorEnvironmentDefaults(t *testing.T) {
+	var path, accept, tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, accept, tenant = r.URL.Path, r.Header.Get("Accept"), r.Header.Get("X-Tenant")
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{
+		Name:      "test",
+		Variables: map[string]interface{}{"tenant": "acme"},
+		Defaults: environment.RequestDefaults{
+			BaseURL: server.URL + "/api/",
+			Headers: map[string]string{"Accept": "application/json", "X-Tenant": "{{tenant}}"},
+		},
+	}
+	exec := NewExecutor(env, nil)
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: "/users"}}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if path != "/api/users" || accept != "application/json" || tenant != "acme" {
+		t.Errorf("Expected defaults to be applied, got path=%q accept=%q tenant=%q", path, accept, tenant)
+	}
+	if request.URL.Raw != "/users" || len(request.Headers) != 0 {
+		t.Error("Expected the original request to be unchanged")
+	}
+
+	request = &httprequest.Request{
+		Method:  "GET",
+		URL:     &httprequest.URL{Raw: server.URL + "/other"},
+		Headers: []httprequest.Header{{Name: "accept", Value: "text/plain"}},
+	}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if path != "/other" || accept != "text/plain" {
+		t.Errorf("Expected the request to override defaults, got path=%q accept=%q", path, accept)
+	}
+}
+
+func TestExecutorVariableOverrides(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{
+		Name:      "test",
+		Variables: map[string]interface{}{"tenant": "globex", "region": "eu"},
+		Source:    map[string]string{"tenant": "cli", "region": "public"},
+	}
+	exec := NewExecutor(env, nil)
+	exec.globals.Set("tenant", "from-script")
+	exec.globals.Set("region", "us")
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/?tenant={{tenant}}&region={{region}}"}}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if query != "tenant=globex&region=us" {
+		t.Errorf("Expected --var values to win over globals, got %s", query)
+	}
+}
+
+func TestExecutorUndefinedVariables(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/{{user}}"}}
+
+	strict := NewExecutor(nil, &ExecutorConfig{StrictVariables: true})
+	result, err := strict.ExecuteRequest(request)
+	if err == nil || result == nil || result.Request == nil {
+		t.Fatalf("Expected a result with an error for an undefined variable, got %v, %v", result, err)
+	}
+	if len(paths) != 0 || !result.NotSent() {
+		t.Errorf("Expected the request not to be sent, got %v", paths)
+	}
+
+	// Transport errors happen once the request is sent
+	refused := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: "http://127.0.0.1:1/"}}
+	if result, _ := strict.ExecuteRequest(refused); result == nil || result.Error == nil || result.NotSent() {
+		t.Errorf("Expected a transport error, got %+v", result)
+	}
+
+	var prompts []string
+	prompting := NewExecutor(nil, &ExecutorConfig{
+		StrictVariables: true,
+		PromptVariable: func(name string) (string, bool, error) {
+			prompts = append(prompts, name)
+			return "alice", false, nil
+		},
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := prompting.ExecuteRequest(request); err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+	}
+	if len(prompts) != 1 || prompts[0] != "user" {
+		t.Errorf("Expected one prompt for user, got %v", prompts)
+	}
+	if len(paths) != 2 || paths[0] != "/alice" {
+		t.Errorf("Expected the prompted value to be used, got %v", paths)
+	}
+}
+
+func TestExecutorOpenAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "7"}`))
+	}))
+	defer server.Close()
+
+	specFile := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(specFile, []byte(`{"paths": {"/users/{id}": {"get": {"operationId": "getUser", "responses": {
+		"200": {"content": {"application/json": {"schema": {"properties": {"id": {"type": "integer"}}}}}}
+	}}}}}`), 0644)
+	spec, err := schema.LoadSpec(specFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := NewExecutor(nil, &ExecutorConfig{OpenAPI: spec})
+
+	result, err := exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/users/7"}})
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if result.ScriptResult == nil || len(result.ScriptResult.Assertions) != 1 {
+		t.Fatalf("Expected one failed assertion, got %+v", result.ScriptResult)
+	}
+	if want := "OpenAPI getUser: $.id: expected integer, got string"; result.ScriptResult.Assertions[0].Message != want {
+		t.Errorf("Expected %q, got %q", want, result.ScriptResult.Assertions[0].Message)
+	}
+
+	// Requests outside the spec aren't checked
+	result, _ = exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/orders"}})
+	if result.ScriptResult != nil {
+		t.Errorf("Expected no assertions, got %+v", result.ScriptResult)
+	}
+
+	// # @openapi names the spec and operation
+	request := &httprequest.Request{
+		Method:   "GET",
+		URL:      &httprequest.URL{Raw: server.URL + "/anything"},
+		Metadata: map[string]string{httprequest.DirectiveOpenAPI: specFile + "#getUser"},
+	}
+	result, _ = NewExecutor(nil, nil).ExecuteRequest(request)
+	if result.ScriptResult == nil || len(result.ScriptResult.Assertions) != 1 {
+		t.Errorf("Expected the directive's operation to be checked, got %+v", result.ScriptResult)
+	}
+}
+
+func TestExecutorBodyEncoding(t *testing.T) {
+	var contentType string
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		received, _ = io.ReadAll(r.Body)
+		// Echo the body back
+		w.Header().Set("Content-Type", contentType)
+		w.Write(received)
+	}))
+	defer server.Close()
+
+	request := &httprequest.Request{
+		Method:   "POST",
+		URL:      &httprequest.URL{Raw: server.URL},
+		Body:     &httprequest.RequestBody{Content: `{"id": 1, "name": "Ann"}`, ContentType: "application/json"},
+		Metadata: map[string]string{httprequest.DirectiveEncode: "msgpack"},
+	}
+	result, err := NewExecutor(nil, nil).ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if contentType != codec.MsgpackContentType || received[0] != 0x82 {
+		t.Errorf("Expected a MessagePack body, got %q % x", contentType, received)
+	}
+	if body, _ := result.Response.Text(); body != `{"id":1,"name":"Ann"}` {
+		t.Errorf("Expected the response to be decoded, got %s", body)
+	}
+
+	protoFile := filepath.Join(t.TempDir(), "user.proto")
+	os.WriteFile(protoFile, []byte(`syntax = "proto3"; message User { int32 id = 1; string name = 2; }`), 0644)
+	request.Metadata = map[string]string{
+		httprequest.DirectiveProto:         protoFile + "#User",
+		httprequest.DirectiveProtoResponse: protoFile + "#User",
+	}
+	result, err = NewExecutor(nil, nil).ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if contentType != codec.ProtobufContentType || string(received) != "\x08\x01\x12\x03Ann" {
+		t.Errorf("Expected a protobuf body, got %q %q", contentType, received)
+	}
+	if body, _ := result.Response.Text(); body != `{"id":1,"name":"Ann"}` {
+		t.Errorf("Expected the response to be decoded, got %s", body)
+	}
+}
+
+func TestExecutorSessions(t *testing.T) {
+	logins := 0
+	var authorization, cookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			logins++
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1"})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token": "t1"}`))
+			return
+		}
+		authorization, cookie = r.Header.Get("Authorization"), r.Header.Get("Cookie")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "auth.http"), []byte(`# @session api
+POST `+server.URL+`/login
+
+> {%
+  client.global.set("token", response.body.token);
+%}
+`), 0644)
+
+	request := &httprequest.Request{
+		Method: "GET",
+		URL:    &httprequest.URL{Raw: server.URL + "/me"},
+		Headers: []httprequest.Header{
+			{Name: "Authorization", Value: "Bearer {{session.api.token}}"},
+			{Name: "Cookie", Value: "{{session.api.cookies}}"},
+		},
+	}
+
+	// The first run logs in; later runs reuse the saved session
+	for i := 0; i < 2; i++ {
+		exec := NewExecutor(nil, &ExecutorConfig{Sessions: session.NewStore(dir, "dev")})
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+		if authorization != "Bearer t1" || cookie != "sid=s1" {
+			t.Errorf("Expected the session's token and cookies, got %q and %q", authorization, cookie)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("Expected one login, got %d", logins)
+	}
+
+	request.Headers = []httprequest.Header{{Name: "Authorization", Value: "Bearer {{session.missing.token}}"}}
+	if _, err := NewExecutor(nil, &ExecutorConfig{Sessions: session.NewStore(dir, "dev")}).ExecuteRequest(request); err == nil {
+		t.Error("Expected an error for an undeclared session")
+	}
+}
+
+func TestExecutorRenewsRejectedSessions(t *testing.T) {
+	logins, calls := 0, 0
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			logins++
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"token": "t%d"}`, logins)
+			return
+		}
+		calls++
+		authorization = r.Header.Get("Authorization")
+		// The server revoked the first token, and later every token
+		if authorization == "Bearer t1" || r.URL.Path == "/locked" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "auth.http"), []byte(`# @session api 1h
+POST `+server.URL+`/login
+
+> {% client.global.set("token", response.body.token); %}
+`), 0644)
+	store := session.NewStore(dir, "dev")
+
+	request := &httprequest.Request{
+		Method:  "GET",
+		URL:     &httprequest.URL{Raw: server.URL + "/me"},
+		Headers: []httprequest.Header{{Name: "Authorization", Value: "Bearer {{session.api.token}}"}},
+	}
+	result, err := NewExecutor(nil, &ExecutorConfig{Sessions: store}).ExecuteRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != http.StatusOK || authorization != "Bearer t2" || logins != 2 || calls != 2 {
+		t.Errorf("expected a second login and retry, got %d with %q after %d logins and %d calls", result.StatusCode, authorization, logins, calls)
+	}
+	if saved, _ := store.Load("api"); saved == nil || saved.Values["token"] != "t2" {
+		t.Errorf("expected the renewed session to be saved, got %+v", saved)
+	}
+
+	// A request that is still rejected is sent only twice
+	logins, calls = 0, 0
+	request.URL = &httprequest.URL{Raw: server.URL + "/locked"}
+	result, _ = NewExecutor(nil, &ExecutorConfig{Sessions: store}).ExecuteRequest(request)
+	if result.StatusCode != http.StatusUnauthorized || logins != 1 || calls != 2 {
+		t.Errorf("expected one retry, got %d after %d logins and %d calls", result.StatusCode, logins, calls)
+	}
+}
+
+func TestExecutorSkip(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{Name: "production", Variables: map[string]interface{}{"region": "eu"}}
+	exec := NewExecutor(env, nil)
+
+	tests := []struct {
+		metadata map[string]string
+		skipped  bool
+	}{
+		{map[string]string{"skip-if": `{{env}} == "production"`}, true},
+		{map[string]string{"skip-if": `{{env}} != 'production'`}, false},
+		{map[string]string{"skip-if": `{{region}} == us`}, false},
+		{map[string]string{"only-env": "staging, development"}, true},
+		{map[string]string{"only-env": "staging production"}, false},
+	}
+
+	for _, tt := range tests {
+		request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}, Metadata: tt.metadata}
+		result, err := exec.ExecuteRequest(request)
+		if err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+		if result.Skipped != tt.skipped {
+			t.Errorf("%v: expected skipped %v, got %v (%s)", tt.metadata, tt.skipped, result.Skipped, result.SkipReason)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 requests to be sent, got %d", calls)
+	}
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}, Metadata: map[string]string{"skip-if": "a == b == c"}}
+	if _, err := exec.ExecuteRequest(request); err == nil {
+		t.Error("Expected an error for an invalid condition")
+	}
+}
+
+func TestExecutorDependsOn(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	requestsFile, err := httprequest.ParseFile("deps.http", `### login
+GET `+server.URL+`/login
+
+### profile
+# @depends-on login
+GET `+server.URL+`/profile
+
+### orders
+# @depends-on login, profile
+GET `+server.URL+`/orders
+
+### broken
+GET `+server.URL+`/broken
+
+### report
+# @depends-on broken
+GET `+server.URL+`/report
+`)
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+
+	// Dependencies run first, once, even when only orders is selected
+	results, err := NewExecutor(nil, nil).ExecuteFile(requestsFile, "orders")
+	if err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+	if len(results) != 3 || strings.Join(paths, " ") != "/login /profile /orders" {
+		t.Errorf("Expected login, profile and orders to run once in order, got %v", paths)
+	}
+
+	paths = nil
+	results, err = NewExecutor(nil, nil).ExecuteFile(requestsFile, "report")
+	if err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+	if len(results) != 2 || !results[1].Blocked || results[1].Error == nil || strings.Join(paths, " ") != "/broken" {
+		t.Errorf("Expected report to fail without being sent, got %v and %v", paths, results[len(results)-1].Error)
+	}
+
+	requestsFile.Requests[0].Metadata = map[string]string{"depends-on": "orders"}
+	if _, err := NewExecutor(nil, nil).ExecuteFile(requestsFile, "orders"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
+
+func TestExecutorSetupTeardown(t *testing.T) {
+	var paths []string
+	failSetup := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/fixture" && failSetup {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	requestsFile, err := httprequest.ParseFile("suite.http", `### cleanup
+# @teardown
+DELETE `+server.URL+`/fixture
+
+### first
+GET `+server.URL+`/first
+
+### create fixture
+# @setup
+POST `+server.URL+`/fixture
+
+### second
+GET `+server.URL+`/second
+`)
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+
+	// Setup and teardown requests run around the selected request
+	if _, err := NewExecutor(nil, nil).ExecuteFile(requestsFile, "second"); err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+	if strings.Join(paths, " ") != "/fixture /second /fixture" {
+		t.Errorf("Expected setup, second and teardown, got %v", paths)
+	}
+
+	// A failed setup stops the other requests, but teardown still runs
+	paths, failSetup = nil, true
+	results, err := NewExecutor(nil, nil).ExecuteFile(requestsFile, "")
+	if err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+	if len(results) != 4 || strings.Join(paths, " ") != "/fixture /fixture" || !results[1].Blocked {
+		t.Errorf("Expected only setup and teardown to be sent, got %v", paths)
+	}
+}
+
+func TestExecutorVariableProviders(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+" "+r.Header.Get("X-Token"))
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Name: "test", Variables: map[string]interface{}{}}, nil)
+	calls := 0
+	exec.AddVariableProvider("vault", func(name string, args []string) (string, bool, error) {
+		calls++
+		return name + ":" + strings.Join(args, ","), true, nil
+	})
+	exec.AddVariableProvider("vault.fail", func(name string, args []string) (string, bool, error) {
+		return "", false, errors.New("sealed")
+	})
+
+	request := &httprequest.Request{
+		Method:  "GET",
+		URL:     &httprequest.URL{Raw: server.URL + "/{{$vault.read a b}}"},
+		Headers: []httprequest.Header{{Name: "X-Token", Value: "{{$vault}}-{{$vault.read a b}}"}},
+	}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/vault.read:a,b vault:-vault.read:a,b" {
+		t.Errorf("unexpected request: %v", paths)
+	}
+	if calls != 2 {
+		t.Errorf("expected each reference to be evaluated once, got %d calls", calls)
+	}
+	if masked := exec.Redactor().Redact("vault.read:a,b"); masked != "[REDACTED]" {
+		t.Errorf("secret value not masked: %s", masked)
+	}
+
+	request = &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/{{$vault.fail.x}}"}}
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "sealed") {
+		t.Errorf("expected the provider's error, got %v", err)
+	}
+}
+
+func TestExecutorTemplateBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{Name: "staging", Variables: map[string]interface{}{
+		"count":  float64(3), // Numbers from JSON environment files are float64
+		"prefix": "user",
+		"tags":   []interface{}{"a", "b"},
+	}}
+	exec := NewExecutor(env, nil)
+
+	body := `{"env": "{{env}}", "users": [{{range $i, $n := until .count}}{{if $i}}, {{end}}"{{prefix}}-{{add $n 1}}"{{end}}],` +
+		` "tags": {{toJson .tags}}, "name": {{default "anon" .name | upper | quote}}, "raw": "{{"{{"}}notAVariable}}"}`
+	request := &httprequest.Request{
+		Method:   "POST",
+		URL:      &httprequest.URL{Raw: server.URL},
+		Body:     &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: body},
+		Metadata: map[string]string{httprequest.DirectiveTemplate: ""},
+	}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	want := `{"env": "staging", "users": ["user-1", "user-2", "user-3"], "tags": ["a","b"], "name": "ANON", "raw": "{{notAVariable}}"}`
+	if len(bodies) != 1 || bodies[0] != want {
+		t.Errorf("unexpected body:\n got %v\nwant %s", bodies, want)
+	}
+
+	request.Body.Content = `{{range .count}`
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "@template") {
+		t.Errorf("expected a template error, got %v", err)
+	}
+}
+
+func TestExecutorTemplateBodyFaker(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	request := &httprequest.Request{
+		Method: "POST",
+		URL:    &httprequest.URL{Raw: server.URL},
+		Body: &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: `[{{range until 2}}` +
+			`{"name": "{{faker "name"}}", "bio": "{{faker "lorem" 3}}", "id": "{{uuid}}", "n": {{randomInt}}, "at": {{timestamp}}},{{end}}]`},
+		Metadata: map[string]string{httprequest.DirectiveTemplate: ""},
+	}
+	for i := 0; i < 2; i++ {
+		exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, &ExecutorConfig{FakerSeed: 1})
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(bodies))
+	}
+	var users []struct {
+		Name, Bio, ID string
+		N             int
+		At            int64
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(bodies[0], ",]")+"]"), &users); err != nil {
+		t.Fatalf("invalid body %q: %v", bodies[0], err)
+	}
+	if len(users) != 2 || !strings.Contains(users[0].Name, " ") || len(strings.Fields(users[0].Bio)) != 3 || len(users[0].ID) != 36 || users[0].At == 0 {
+		t.Errorf("faker functions not rendered: %q", bodies[0])
+	}
+	if users[0].Name == users[1].Name && users[0].ID == users[1].ID {
+		t.Errorf("expected a new value for each call, got %q", bodies[0])
+	}
+	if strings.Split(bodies[0], `"at"`)[0] != strings.Split(bodies[1], `"at"`)[0] {
+		t.Errorf("expected the same data with the same seed, got %q", bodies)
+	}
+
+	request.Body.Content = `{{faker "nope"}}`
+	if _, err := NewExecutor(nil, nil).ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "unknown faker") {
+		t.Errorf("expected an unknown faker error, got %v", err)
+	}
+}
+
+func TestExecutorFaker(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.URL.Query().Get("name")+"|"+r.Header.Get("X-Email")+"|"+string(body))
+	}))
+	defer server.Close()
+
+	request := &httprequest.Request{
+		Method:  "POST",
+		URL:     &httprequest.URL{Raw: server.URL + "/users?name={{$faker.name}}"},
+		Headers: []httprequest.Header{{Name: "X-Email", Value: "{{$faker.email}}"}},
+		Body:    &httprequest.RequestBody{Content: `{"name": "{{$faker.name}}", "bio": "{{$faker.lorem 3}}"}`},
+	}
+	for i := 0; i < 2; i++ {
+		exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, &ExecutorConfig{FakerSeed: 1})
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+	}
+
+	if len(got) != 2 || got[0] != got[1] {
+		t.Fatalf("expected the same data with the same seed, got %q", got)
+	}
+	parts := strings.SplitN(got[0], "|", 3)
+	if !strings.Contains(parts[0], " ") || !strings.Contains(parts[2], `"name": "`+parts[0]+`"`) {
+		t.Errorf("name not sent in the query and body: %q", got[0])
+	}
+	if !strings.Contains(parts[1], "@") || strings.Contains(got[0], "{{") {
+		t.Errorf("faker variables not expanded: %q", got[0])
+	}
+}
+
+func TestExecutorFileBody(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "user.json"), []byte(`{"name": "{{name}}"}`), 0644)
+	requestsFile, err := httprequest.ParseFile(filepath.Join(dir, "api.http"), "POST "+server.URL+"/users\nContent-Type: application/vnd.api+json\n\n< ./user.json\n\n###\nPOST "+server.URL+"/import\n\n< -\n\n###\nPOST "+server.URL+"/again\n\n< -\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{"name": "Ann"}}, &ExecutorConfig{Stdin: strings.NewReader("piped")})
+	if _, err := exec.ExecuteFile(requestsFile, ""); err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+
+	// Files are sent as is, with the request's Content-Type; standard input
+	// is read once
+	expected := []string{`application/vnd.api+json {"name": "{{name}}"}`, "text/plain piped", "text/plain piped"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	requestsFile.Requests[0].Body.FilePath = "missing.json"
+	if _, err := exec.ExecuteRequest(&requestsFile.Requests[0]); err == nil || !strings.Contains(err.Error(), "failed to read body file") {
+		t.Errorf("expected an error for a missing body file, got %v", err)
+	}
+}
+
+func TestExecutorHosts(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Host+r.URL.Path)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	env := &environment.ResolvedEnvironment{
+		Variables: map[string]interface{}{},
+		Defaults:  environment.RequestDefaults{Hosts: map[string]string{"api.example.test": addr, "other.example.test": "192.0.2.1:1"}},
+	}
+	exec := NewExecutor(env, &ExecutorConfig{
+		Transport: client.TransportConfig{Resolve: map[string]string{"other.example.test:80": addr}},
+	})
+	for _, url := range []string{"http://api.example.test/users", "http://other.example.test/orders"} {
+		request := &httprequest.Request{
+			Method: "GET",
+			URL:    &httprequest.URL{Raw: url},
+			ResponseHandler: &httprequest.ResponseHandler{
+				Type:   httprequest.HandlerTypeInline,
+				Script: `http("http://api.example.test/from-script")`,
+			},
+		}
+		result, err := exec.ExecuteRequest(request)
+		if err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+		if result.ScriptResult.Error != nil {
+			t.Fatalf("script error: %v", result.ScriptResult.Error)
+		}
+	}
+
+	// --resolve mappings win over the environment's
+	expected := []string{"api.example.test/users", "api.example.test/from-script", "other.example.test/orders", "api.example.test/from-script"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestExecutorExpectStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	tests := []struct {
+		path   string
+		expect string
+		passed bool
+	}{
+		{"/", "", true},
+		{"/", "201", false},
+		{"/", "2xx", true},
+		{"/missing", "", false},
+		{"/missing", "404", true},
+	}
+
+	var results []*ExecutionResult
+	for _, tt := range tests {
+		request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + tt.path}, Metadata: map[string]string{}}
+		if tt.expect != "" {
+			request.Metadata[httprequest.DirectiveExpect] = tt.expect
+		}
+		result, err := exec.ExecuteRequest(request)
+		if err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+		if result.Passed() != tt.passed {
+			t.Errorf("%s with @expect %q: expected passed %v", tt.path, tt.expect, tt.passed)
+		}
+		results = append(results, result)
+	}
+
+	formatted := NewFormatter(false).FormatResult(results[1], 2)
+	if !strings.Contains(formatted, "✗ Status: 200 OK") || !strings.Contains(formatted, "Expected: 201 (# @expect), got 200") {
+		t.Errorf("mismatch not shown:\n%s", formatted)
+	}
+	if summary := NewRunReport("", "", results).Summary; summary.Successful != 3 || summary.Failed != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if report := NewResultReport(results[1], 2); report.Expected != "201" || report.Passed {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}, Metadata: map[string]string{httprequest.DirectiveExpect: "created"}}
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "invalid @expect") {
+		t.Errorf("Expected an invalid @expect error, got %v", err)
+	}
+}
+
+func TestExecutorPaginate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		switch r.URL.Path {
+		case "/link":
+			if page < 3 {
+				w.Header().Set("Link", fmt.Sprintf(`</link?page=%d>; rel="next", </link?page=3>; rel="last"`, page+1))
+			}
+			fmt.Fprintf(w, `[{"id":%d}]`, page)
+		case "/cursor":
+			next := map[string]string{"": `"b"`, "b": `"c"`, "c": "null"}[r.URL.Query().Get("after")]
+			fmt.Fprintf(w, `{"data":[{"id":1},{"id":2}],"meta":{"next":%s}}`, next)
+		case "/pages":
+			if page > 2 {
+				fmt.Fprint(w, `{"results":[]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"results":[{"page":%d}],"count":4}`, page)
+		case "/hal":
+			next := ""
+			if page < 2 {
+				next = `,"next":{"href":"/hal?page=2"}`
+			}
+			fmt.Fprintf(w, `{"_links":{"self":{"href":"/hal?page=%d"}%s},"_embedded":{"orders":[{"id":%d}]},"total":2}`, page, next, page)
+		case "/jsonapi":
+			next := "null"
+			if page < 3 {
+				next = fmt.Sprintf(`"%s/jsonapi?page=%d"`, "http://"+r.Host, page+1)
+			}
+			fmt.Fprintf(w, `{"data":[{"type":"orders","id":"%d"}],"links":{"next":%s}}`, page, next)
+		case "/failing":
+			if page > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Link", `</failing?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"id":1}]`)
+		}
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	tests := []struct {
+		path     string
+		paginate string
+		status   int
+		pages    int
+		items    int
+		body     string
+	}{
+		{"/link", "link-header", 200, 3, 3, `[{"id":1},{"id":2},{"id":3}]`},
+		{"/link", "link-header max=2", 200, 2, 2, `[{"id":1},{"id":2}]`},
+		{"/cursor", "cursor:$.meta.next after", 200, 3, 6, ""},
+		{"/pages", "page-param:page", 200, 3, 2, `[{"page":1},{"page":2}]`},
+		{"/hal", "links", 200, 2, 2, `[{"id":1},{"id":2}]`},
+		{"/jsonapi", "links", 200, 3, 3, ""},
+		{"/failing", "link-header", 503, 2, 1, ""},
+	}
+	for _, tt := range tests {
+		request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + tt.path}, Metadata: map[string]string{httprequest.DirectivePaginate: tt.paginate}}
+		result, err := exec.ExecuteRequest(request)
+		if err != nil {
+			t.Fatalf("%s: ExecuteRequest error: %v", tt.paginate, err)
+		}
+		if result.StatusCode != tt.status || result.Pagination == nil || result.Pagination.Pages != tt.pages || result.Pagination.Items != tt.items {
+			t.Errorf("%s: got status %d, pagination %+v", tt.paginate, result.StatusCode, result.Pagination)
+			continue
+		}
+		if body, _ := result.Response.Text(); tt.body != "" && body != tt.body {
+			t.Errorf("%s: got body %s", tt.paginate, body)
+		}
+	}
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/link"}, Metadata: map[string]string{httprequest.DirectivePaginate: "link-header max=1"}}
+	result, _ := exec.ExecuteRequest(request)
+	if !result.Pagination.Truncated {
+		t.Errorf("Expected the page limit to be reported: %+v", result.Pagination)
+	}
+	if formatted := NewFormatter(false).FormatResult(result, 1); !strings.Contains(formatted, "Pages: 1 (1 items), stopped at the page limit") {
+		t.Errorf("pages not shown:\n%s", formatted)
+	}
+}
+
+func TestExecutorHypermedia(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/hal+json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprint(w, `{"_links":{"self":{"href":"/api/orders"},"next":{"href":"orders?page=2"}},"_embedded":{"orders":[{"id":1}]}}`)
+		default:
+			fmt.Fprintf(w, `{"_links":{"self":{"href":"%s"}},"_embedded":{"orders":[{"id":2}]}}`, r.URL.RequestURI())
+		}
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	request := &httprequest.Request{
+		Method: "GET",
+		URL:    &httprequest.URL{Raw: server.URL + "/api/orders"},
+		ResponseHandler: &httprequest.ResponseHandler{Type: httprequest.HandlerTypeInline, Script: `
+			client.test("navigate", function() {
+				client.assert(response.link("next") === "` + server.URL + `/api/orders?page=2", response.link("next"));
+				client.assert(response.link("prev") === null, "unexpected prev link");
+				client.assert(Object.keys(response.links()).length === 2, "expected two links");
+				client.assert(response.embedded("orders")[0].id === 1, "unexpected embedded orders");
+
+				const next = response.follow("next");
+				client.assert(next.status === 200, "follow failed");
+				client.assert(next.embedded("orders")[0].id === 2, "unexpected second page");
+				client.assert(next.link("self") === "` + server.URL + `/api/orders?page=2", next.link("self"));
+			});
+		`},
+	}
+	result, err := exec.ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("Expected the navigation tests to pass, got %+v", result.ScriptResult.Tests[0])
+	}
+}
+
+func TestExecutorPoll(t *testing.T) {
+	var calls sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := calls.LoadOrStore(r.URL.Path, new(int))
+		*count.(*int)++
+		status := "PENDING"
+		if r.URL.Path == "/job" && *count.(*int) >= 3 {
+			status = "READY"
+		}
+		fmt.Fprintf(w, `{"status":%q}`, status)
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/job"}, Metadata: map[string]string{
+		httprequest.DirectivePoll: `interval=10ms timeout=5s until=response.body.status == "READY"`,
+	}}
+	result, err := exec.ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if result.Poll == nil || result.Poll.Attempts != 3 || !result.Poll.Satisfied || !result.Passed() {
+		t.Errorf("Expected the condition to hold on the third attempt, got %+v", result.Poll)
+	}
+	if body, _ := result.Response.Text(); body != `{"status":"READY"}` {
+		t.Errorf("Expected the last response, got %s", body)
+	}
+
+	request.URL.Raw = server.URL + "/stuck"
+	request.Metadata[httprequest.DirectivePoll] = `interval=10ms timeout=50ms until=response.body.status == "READY"`
+	result, err = exec.ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if result.Poll.Satisfied || result.Poll.Attempts < 2 || result.Passed() {
+		t.Errorf("Expected the poll to time out and fail, got %+v", result.Poll)
+	}
+	if formatted := NewFormatter(false).FormatResult(result, 1); !strings.Contains(formatted, `Poll: response.body.status == "READY" never held`) {
+		t.Errorf("poll failure not shown:\n%s", formatted)
+	}
+
+	request.Metadata[httprequest.DirectivePoll] = `until=response.body.status ==`
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "@poll") {
+		t.Errorf("Expected a condition error, got %v", err)
+	}
+}
+
+func TestExecutorListener(t *testing.T) {
+	l, err := listener.Start("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer l.Close()
+
+	// The API delivers a webhook after answering
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			if resp, err := http.Post(l.URL()+"/hook", "application/json", strings.NewReader(`{"event":"order.created"}`)); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, &ExecutorConfig{Listener: l})
+	request := &httprequest.Request{
+		Method: "POST",
+		URL:    &httprequest.URL{Raw: server.URL + "/orders"},
+		ResponseHandler: &httprequest.ResponseHandler{Type: httprequest.HandlerTypeInline, Script: `
+			const calls = client.listener.wait(1, 2000);
+			client.test("webhook delivered", function() {
+				client.assert(calls.length === 1, "expected one callback, got " + calls.length);
+				client.assert(calls[0].body.event === "order.created", "unexpected event");
+				client.assert(calls[0].header("content-type") === "application/json", "unexpected content type");
+			});
+			client.test("url", function() {
+				client.assert(client.listener.url.startsWith("http://localhost:"), client.listener.url);
+			});
+		`},
+	}
+	result, err := exec.ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("Expected the webhook tests to pass, got %+v", result.ScriptResult.Tests[0])
+	}
+
+	// Requests can target the listener through {{listener.url}}
+	ping := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: "{{listener.url}}/ping"}}
+	if _, err := exec.ExecuteRequest(ping); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	callbacks := l.Callbacks()
+	if last := callbacks[len(callbacks)-1]; last.Path != "/ping" {
+		t.Errorf("Expected {{listener.url}} to reach the listener, got %s", last.Path)
+	}
+}
+
+func TestExecutorExpectError(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + listener.Addr().String()
+	listener.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	tests := []struct {
+		url    string
+		expect string
+		kind   string
+		passed bool
+	}{
+		{slow.URL, "timeout", httprequest.ErrorTimeout, true},
+		{slow.URL, "", httprequest.ErrorTimeout, false},
+		{closed, "connection-refused", httprequest.ErrorConnectionRefused, true},
+		{closed, "timeout dns", httprequest.ErrorConnectionRefused, false},
+	}
+
+	var results []*ExecutionResult
+	for _, tt := range tests {
+		request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: tt.url}, Metadata: map[string]string{httprequest.DirectiveTimeout: "50ms"}}
+		if tt.expect != "" {
+			request.Metadata[httprequest.DirectiveExpectError] = tt.expect
+		}
+		result, _ := exec.ExecuteRequest(request)
+		if result.ErrorKind != tt.kind {
+			t.Errorf("%s: expected error kind %q, got %q (%v)", tt.url, tt.kind, result.ErrorKind, result.Error)
+		}
+		if result.Passed() != tt.passed {
+			t.Errorf("%s with @expect-error %q: expected passed %v", tt.url, tt.expect, tt.passed)
+		}
+		results = append(results, result)
+	}
+
+	if summary := NewRunReport("", "", results).Summary; summary.Successful != 2 || summary.Errors != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	formatter := NewFormatter(false)
+	if formatted := formatter.FormatResult(results[2], 3); !strings.Contains(formatted, "✓ Expected error: connection-refused (# @expect-error)") {
+		t.Errorf("expected error not shown:\n%s", formatted)
+	}
+	if formatted := formatter.FormatResult(results[3], 4); !strings.Contains(formatted, "Expected: timeout or dns (# @expect-error), got connection-refused") {
+		t.Errorf("mismatch not shown:\n%s", formatted)
+	}
+
+	// Getting a response fails the request
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+	result, err := exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: fast.URL}, Metadata: map[string]string{httprequest.DirectiveExpectError: "timeout"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Passed() || !strings.Contains(formatter.FormatResult(result, 1), "Expected: timeout (# @expect-error), got 200") {
+		t.Errorf("expected a response to fail the request")
+	}
+}
+
+func TestExecutorAuthDirective(t *testing.T) {
+	var authorization, key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		key = r.Header.Get("X-Key") + r.URL.Query().Get("api_key")
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{Variables: map[string]interface{}{"token": "t0k", "user": "ann", "pass": "secret", "key": "k3y"}}
+	exec := NewExecutor(env, nil)
+	tests := []struct {
+		auth          string
+		authorization string
+		key           string
+	}{
+		{"bearer {{token}}", "Bearer t0k", ""},
+		{"basic {{user}} {{pass}}", "Basic YW5uOnNlY3JldA==", ""},
+		{"apikey header X-Key {{key}}", "Bearer old", "k3y"},
+		{"apikey query api_key {{key}}", "Bearer old", "k3y"},
+	}
+	for _, tt := range tests {
+		request := &httprequest.Request{
+			Method:   "GET",
+			URL:      &httprequest.URL{Raw: server.URL + "/users?page=2"},
+			Headers:  []httprequest.Header{{Name: "authorization", Value: "Bearer old"}},
+			Metadata: map[string]string{httprequest.DirectiveAuth: tt.auth},
+		}
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatalf("%s: %v", tt.auth, err)
+		}
+		if authorization != tt.authorization || key != tt.key {
+			t.Errorf("%s: got Authorization %q and key %q", tt.auth, authorization, key)
+		}
+		if request.Metadata[httprequest.DirectiveAuth] != tt.auth {
+			t.Errorf("%s: the parsed request was changed", tt.auth)
+		}
+	}
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}, Metadata: map[string]string{httprequest.DirectiveAuth: "apikey cookie session x"}}
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), "invalid @auth") {
+		t.Errorf("Expected an invalid @auth error, got %v", err)
+	}
+}
+
+func TestFormatRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Secret", "secret-value")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	env := &environment.ResolvedEnvironment{Variables: map[string]interface{}{"token": "secret-value"}, Source: map[string]string{"token": "private"}}
+	exec := NewExecutor(env, nil)
+	result, err := exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatter := NewFormatter(true)
+	formatter.SetRedactor(exec.Redactor())
+	if body := string(formatter.FormatRaw(result, false)); body != `{"id":1}` {
+		t.Errorf("expected the body as received, got %q", body)
+	}
+	raw := string(formatter.FormatRaw(result, true))
+	if !strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n") || !strings.Contains(raw, "X-Secret: secret-value\r\n") || !strings.HasSuffix(raw, "\r\n\r\n{\"id\":1}") {
+		t.Errorf("unexpected output with headers:\n%s", raw)
+	}
+	if raw := formatter.FormatRaw(&ExecutionResult{Error: fmt.Errorf("refused")}, true); raw != nil {
+		t.Errorf("expected no output without a response, got %q", raw)
+	}
+}
+
+func TestExecutorWorkers(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			mu.Lock()
+			logins++
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "auth.http"), []byte(`# @session api
+POST `+server.URL+`/login
+
+> {%
+  client.global.set("token", "t1");
+%}
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "user.json"), []byte(`{"type": "object", "required": ["id"]}`), 0644)
+
+	requestsFile, err := httprequest.ParseFile("users.http", `@path = users
+
+### create
+POST `+server.URL+`/{{path}}?name={{$faker.name}}
+Authorization: Bearer {{session.api.token}}
+X-Secret: {{apiSecret}}
+
+?? body matches-schema `+filepath.Join(dir, "user.json")+`
+
+> {%
+  client.global.set("created", response.body.id);
+  client.test("created", function() { client.assert(response.status === 200); });
+%}
+
+### get
+GET `+server.URL+`/{{path}}/{{created}}
+`)
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Name: "dev", Variables: map[string]interface{}{}}, &ExecutorConfig{
+		Sessions: session.NewStore(dir, "dev"),
+		PromptVariable: func(name string) (string, bool, error) {
+			return "prompted-secret", true, nil
+		},
+	})
+	exec.AddHook(HookFuncs{BeforeRequestFunc: func(request *httprequest.Request) error { return nil }})
+
+	// Run with go test -race to check for data races
+	var wg sync.WaitGroup
+	failures := make(chan string, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := exec.Worker().ExecuteFile(requestsFile, "")
+			if err != nil {
+				failures <- err.Error()
+				return
+			}
+			for _, result := range results {
+				if !result.Passed() {
+					failures <- fmt.Sprintf("%s: %v %v", result.Request.Name, result.Error, result.ScriptResult)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(failures)
+
+	for failure := range failures {
+		t.Error(failure)
+	}
+	if logins == 0 {
+		t.Error("Expected the session to log in")
+	}
+	if exec.Redactor().Redact("prompted-secret") != "[REDACTED]" {
+		t.Error("Expected the prompted secret to be masked")
+	}
+}
+
+func TestSelectMatching(t *testing.T) {
+	requestsFile, err := httprequest.ParseFile("users.http", `### List users
+# @tag smoke
+GET https://api.example.com/users
+
+### Create user
+# @tag smoke, write
+# @tag users
+POST https://api.example.com/users
+
+### Delete user
+# @tag write
+DELETE https://admin.example.com/users/1
+`)
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+	all := []int{0, 1, 2}
+
+	tests := map[string][]int{
+		`method==POST && name~"user" && tag in (smoke)`: {1},
+		`tag==write`:                       {1, 2},
+		`tag in (users) || index==1`:       {0, 1},
+		`host==admin.example.com`:          {2},
+		`path~users && tag not in (smoke)`: {2},
+	}
+	for expression, want := range tests {
+		sel, err := ParseSelector(expression)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error: %v", expression, err)
+		}
+		got, err := SelectMatching(requestsFile, all, sel)
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("SelectMatching(%q) = %v, %v; want %v", expression, got, err, want)
+		}
+	}
+
+	sel, _ := ParseSelector(`tag==nightly`)
+	if _, err := SelectMatching(requestsFile, all, sel); err == nil {
+		t.Error("Expected an error when nothing matches")
+	}
+	if _, err := ParseSelector(`status==200`); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestHeaderAndCookieAssertions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123"})
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	requestsFile, err := httprequest.ParseFile("test.http", `GET `+server.URL+`
+
+?? header Content-Type matches ^application/json
+?? header Content-Type contains "charset=utf-8"
+?? header X-RateLimit-Remaining > 0
+?? header X-Debug not-exists
+?? cookie session exists
+?? cookie session == abc123
+?? cookie theme exists
+`)
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+
+	exec := NewExecutor(nil, nil)
+	results, err := exec.ExecuteFile(requestsFile, "")
+	if err != nil {
+		t.Fatalf("ExecuteFile error: %v", err)
+	}
+
+	var messages []string
+	for _, assertion := range results[0].ScriptResult.Assertions {
+		messages = append(messages, assertion.Message)
+	}
+	want := []string{
+		`?? header X-RateLimit-Remaining > 0: header X-RateLimit-Remaining is "0"`,
+		`?? cookie theme exists: no cookie theme`,
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("Expected failures %q, got %q", want, messages)
+	}
+}
+
+func TestDeprecationOf(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		headers  map[string]string
+		notice   string
+		warnings int
+	}{
+		{map[string]string{"Deprecation": "@1688169599", "Sunset": "Tue, 30 Jun 2026 23:59:59 GMT", "Link": `<https://docs.example.com/v1>; rel="deprecation"`}, "Deprecated since 2023-06-30, sunset on 2026-06-30, see https://docs.example.com/v1", 0},
+		{map[string]string{"Deprecation": "true"}, "Deprecated", 0},
+		{map[string]string{"Deprecation": "true", "Sunset": "Sun, 01 Dec 2024 00:00:00 GMT"}, "Deprecated, sunset on 2024-12-01, which has passed", 0},
+		{map[string]string{"Sunset": "Tue, 30 Jun 2026 23:59:59 GMT"}, "Sunset on 2026-06-30", 0},
+		{map[string]string{"Warning": `299 api.example.com "Use /v2/orders instead"`}, "", 1},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for name, value := range tt.headers {
+			header.Set(name, value)
+		}
+		deprecation := deprecationOf(header)
+		if deprecation == nil {
+			t.Errorf("%v: expected a deprecation", tt.headers)
+			continue
+		}
+		if notice := deprecation.Notice(now); notice != tt.notice || len(deprecation.Warnings) != tt.warnings {
+			t.Errorf("%v: got %q and warnings %v", tt.headers, notice, deprecation.Warnings)
+		}
+	}
+
+	for _, header := range []http.Header{{}, {"Deprecation": {"false"}}} {
+		if deprecation := deprecationOf(header); deprecation != nil {
+			t.Errorf("%v: expected no deprecation, got %+v", header, deprecation)
+		}
+	}
+}
+
+func TestExecutorDeprecation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/orders" {
+			w.Header().Set("Deprecation", "@1688169599")
+			w.Header().Set("Warning", `299 - "Use /v2/orders instead"`)
+		}
+	}))
+	defer server.Close()
+
+	exec := NewExecutor(&environment.ResolvedEnvironment{Variables: map[string]interface{}{}}, nil)
+	var results []*ExecutionResult
+	for _, path := range []string{"/v1/orders", "/v2/orders"} {
+		result, err := exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + path}})
+		if err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+		results = append(results, result)
+	}
+	if results[0].Deprecation == nil || results[1].Deprecation != nil {
+		t.Fatalf("Expected only the v1 response to be deprecated: %+v, %+v", results[0].Deprecation, results[1].Deprecation)
+	}
+
+	formatter := NewFormatter(false)
+	if formatted := formatter.FormatResult(results[0], 1); !strings.Contains(formatted, "Deprecated since 2023-06-30") || !strings.Contains(formatted, "Warning: 299 Use /v2/orders instead") {
+		t.Errorf("deprecation not shown:\n%s", formatted)
+	}
+	if summary := formatter.FormatSummary(results); !strings.Contains(summary, "Deprecation warnings: 1") {
+		t.Errorf("deprecations not counted:\n%s", summary)
+	}
+	if report := NewRunReport("", "", results); report.Summary.Deprecated != 1 || report.Results[0].Deprecation == nil {
+		t.Errorf("unexpected report: %+v", report.Summary)
+	}
+}
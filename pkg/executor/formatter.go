@@ -1,27 +1,48 @@
 package executor
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
-
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/color"
+	"postie/pkg/display"
+	"postie/pkg/fault"
+	"postie/pkg/httprequest"
+	"postie/pkg/listener"
+	"postie/pkg/markup"
+	"postie/pkg/redact"
 	"postie/pkg/scripting"
 )
 
 // Formatter handles formatting and display of execution results
 type Formatter struct {
-	verbose bool
-	color   bool
+	verbose  bool
+	palette  *color.Palette
+	redactor *redact.Redactor
 }
 
 // NewFormatter creates a new result formatter
 func NewFormatter(verbose bool) *Formatter {
 	return &Formatter{
 		verbose: verbose,
-		color:   true,
 	}
 }
 
+// SetPalette sets the palette used to color output. Output isn't colored
+// by default.
+func (f *Formatter) SetPalette(palette *color.Palette) {
+	f.palette = palette
+}
+
+// SetRedactor sets the redactor used to mask secret values in output
+func (f *Formatter) SetRedactor(redactor *redact.Redactor) {
+	f.redactor = redactor
+}
+
 // FormatResult formats an execution result for display
 func (f *Formatter) FormatResult(result *ExecutionResult, index int) string {
 	var output strings.Builder
@@ -30,6 +51,11 @@ func (f *Formatter) FormatResult(result *ExecutionResult, index int) string {
 	output.WriteString(f.formatHeader(result, index))
 	output.WriteString("\n")
 
+	if result.Skipped {
+		output.WriteString(f.palette.Warning(fmt.Sprintf("%s Skipped: %s", display.Glyphs().Skip, result.SkipReason)) + "\n")
+		return f.redactor.Redact(output.String())
+	}
+
 	// Status
 	output.WriteString(f.formatStatus(result))
 	output.WriteString("\n")
@@ -60,23 +86,32 @@ func (f *Formatter) FormatResult(result *ExecutionResult, index int) string {
 		output.WriteString(fmt.Sprintf("\nResponse saved to: %s\n", result.ResponseFilePath))
 	}
 
-	return output.String()
+	return f.redactor.Redact(output.String())
+}
+
+// FormatFileHeader formats the heading shown before the results of each
+// file when several files run
+func (f *Formatter) FormatFileHeader(file string) string {
+	return "\n" + f.palette.Heading(fmt.Sprintf("%s %s %s", strings.Repeat("#", 10), file, strings.Repeat("#", 10))) + "\n"
 }
 
 // formatHeader formats the result header
 func (f *Formatter) formatHeader(result *ExecutionResult, index int) string {
 	var header strings.Builder
 
-	header.WriteString(fmt.Sprintf("\n%s Request %d: %s %s %s\n",
+	header.WriteString("\n" + f.palette.Heading(fmt.Sprintf("%s Request %d: %s %s %s",
 		strings.Repeat("=", 10),
 		index,
 		result.Request.Method,
 		result.Request.URL.Raw,
-		strings.Repeat("=", 10)))
+		strings.Repeat("=", 10))) + "\n")
 
 	if result.Request.Name != "" {
 		header.WriteString(fmt.Sprintf("Name: %s\n", result.Request.Name))
 	}
+	for _, correlation := range result.Correlation {
+		header.WriteString(fmt.Sprintf("%s: %s\n", correlation.Name, correlation.Value))
+	}
 
 	return header.String()
 }
@@ -86,32 +121,100 @@ func (f *Formatter) formatStatus(result *ExecutionResult) string {
 	var status strings.Builder
 
 	if result.Response != nil {
-		statusIcon := "✓"
-		if result.IsError() {
-			statusIcon = "✗"
+		statusLine := f.palette.Success(fmt.Sprintf("%s Status: %s", display.Glyphs().Pass, result.Status))
+		if result.StatusFailed() {
+			statusLine = f.palette.Failure(fmt.Sprintf("%s Status: %s", display.Glyphs().Fail, result.Status))
 		}
 
-		status.WriteString(fmt.Sprintf("%s Status: %s\n", statusIcon, result.Status))
-		status.WriteString(fmt.Sprintf("  Duration: %v\n", result.Duration))
-		status.WriteString(fmt.Sprintf("  Size: %d bytes\n", result.Response.Size()))
+		status.WriteString(statusLine + "\n")
+		if result.ExpectedErrors != nil {
+			status.WriteString(fmt.Sprintf("  Expected: %s (# @%s), got %d\n", result.ExpectedErrors, httprequest.DirectiveExpectError, result.StatusCode))
+		} else if result.ExpectedStatus != nil && result.StatusFailed() {
+			status.WriteString(fmt.Sprintf("  Expected: %s (# @%s), got %d\n", result.ExpectedStatus, httprequest.DirectiveExpect, result.StatusCode))
+		}
+		status.WriteString(f.palette.Muted(fmt.Sprintf("  Duration: %s", display.Duration(result.Duration))) + "\n")
+		if result.Cache != nil && result.Cache.Status != "" {
+			status.WriteString(f.palette.Muted("  Cache: "+result.Cache.String()) + "\n")
+		}
+		if f.verbose && result.Timings != nil {
+			status.WriteString(f.palette.Muted("  Timing: "+formatTimings(result.Timings)) + "\n")
+		}
+		status.WriteString(f.palette.Muted(fmt.Sprintf("  Size: %s", display.Size(result.Response.Size()))) + "\n")
+		if result.Pagination != nil {
+			pages := fmt.Sprintf("  Pages: %d (%d items)", result.Pagination.Pages, result.Pagination.Items)
+			if result.Pagination.Truncated {
+				pages += ", stopped at the page limit"
+			}
+			status.WriteString(f.palette.Muted(pages) + "\n")
+		}
+		if result.Poll != nil {
+			attempts := fmt.Sprintf("%d attempts over %s", result.Poll.Attempts, display.Duration(time.Duration(result.Poll.Waited)*time.Millisecond))
+			if result.Poll.Satisfied {
+				status.WriteString(f.palette.Muted("  Polled: "+attempts) + "\n")
+			} else {
+				status.WriteString(f.palette.Failure(fmt.Sprintf("  %s Poll: %s never held (%s)", display.Glyphs().Fail, result.Poll.Until, attempts)) + "\n")
+			}
+		}
 
 		contentType := result.Response.ContentType()
 		if contentType != "" {
-			status.WriteString(fmt.Sprintf("  Content-Type: %s\n", contentType))
+			status.WriteString(f.palette.Muted(fmt.Sprintf("  Content-Type: %s", contentType)) + "\n")
 		}
+		status.WriteString(f.formatDeprecation(result.Deprecation))
 	}
 
 	return status.String()
 }
 
+// formatDeprecation formats a response's deprecation, sunset and warning
+// headers so they stand out
+func (f *Formatter) formatDeprecation(deprecation *Deprecation) string {
+	if deprecation == nil {
+		return ""
+	}
+
+	var output strings.Builder
+	if notice := deprecation.Notice(time.Now()); notice != "" {
+		output.WriteString(f.palette.Warning(fmt.Sprintf("  %s %s", display.Glyphs().Error, notice)) + "\n")
+	}
+	for _, warning := range deprecation.Warnings {
+		output.WriteString(f.palette.Warning(fmt.Sprintf("  %s Warning: %s", display.Glyphs().Error, warning)) + "\n")
+	}
+	return output.String()
+}
+
+// formatTimings formats the phases of a request, such as "dns 2ms,
+// connect 1ms, tls 12ms, server 40ms, transfer 3ms"
+func formatTimings(timings *client.Timings) string {
+	var phases []string
+	if timings.Reused {
+		phases = append(phases, "reused connection")
+	} else {
+		phases = append(phases, "dns "+display.Duration(timings.DNS), "connect "+display.Duration(timings.Connect))
+		if timings.TLS > 0 {
+			phases = append(phases, "tls "+display.Duration(timings.TLS))
+		}
+	}
+	phases = append(phases, "server "+display.Duration(timings.ServerProcessing), "transfer "+display.Duration(timings.ContentTransfer))
+	return strings.Join(phases, ", ")
+}
+
 // formatRequestDetails formats detailed request information
 func (f *Formatter) formatRequestDetails(result *ExecutionResult) string {
 	var details strings.Builder
 
-	details.WriteString("\nRequest Details:\n")
+	details.WriteString("\n" + f.palette.Heading("Request Details:") + "\n")
 	details.WriteString(fmt.Sprintf("  Method: %s\n", result.Request.Method))
 	details.WriteString(fmt.Sprintf("  URL: %s\n", result.Request.URL.Raw))
 
+	// Query parameters, in the order written
+	if len(result.Request.URL.Query) > 0 {
+		details.WriteString("  Query:\n")
+		for _, param := range result.Request.URL.Query {
+			details.WriteString(fmt.Sprintf("    %s = %s\n", param.Name, param.Value))
+		}
+	}
+
 	// Headers
 	if len(result.Request.Headers) > 0 {
 		details.WriteString("  Headers:\n")
@@ -150,14 +253,21 @@ func (f *Formatter) formatResponseBody(result *ExecutionResult) string {
 		return body.String()
 	}
 
-	body.WriteString("\nResponse Body:\n")
+	body.WriteString("\n" + f.palette.Heading("Response Body:") + "\n")
 
 	// Try to format as JSON
 	contentType := result.Response.ContentType()
 	if strings.Contains(contentType, "json") || f.looksLikeJSON(text) {
 		formatted := f.formatJSON(text)
-		body.WriteString(formatted)
+		body.WriteString(f.palette.JSON(formatted))
 	} else {
+		// Indent XML and HTML; fall back to plain text if they don't parse
+		if markupType := markupKind(contentType, text); markupType != "" {
+			if indented, err := markup.Indent([]byte(text), markupType == "html"); err == nil && indented != "" {
+				text = strings.TrimSuffix(indented, "\n")
+			}
+		}
+
 		// Display as plain text
 		if len(text) > 1000 && !f.verbose {
 			body.WriteString(text[:1000])
@@ -187,6 +297,20 @@ func (f *Formatter) formatJSON(text string) string {
 	return string(prettyJSON) + "\n"
 }
 
+// markupKind returns "html" or "xml" if a body should be indented as markup
+func markupKind(contentType, text string) string {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case strings.Contains(contentType, "html"):
+		return "html"
+	case strings.Contains(contentType, "xml"), strings.HasPrefix(trimmed, "<?xml"):
+		return "xml"
+	case contentType == "" && strings.HasPrefix(trimmed, "<"):
+		return "xml"
+	}
+	return ""
+}
+
 // looksLikeJSON checks if text looks like JSON
 func (f *Formatter) looksLikeJSON(text string) bool {
 	trimmed := strings.TrimSpace(text)
@@ -194,9 +318,41 @@ func (f *Formatter) looksLikeJSON(text string) bool {
 		(strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"))
 }
 
-// formatError formats error information
+// FormatRaw returns a response's body exactly as received, for piping to
+// other tools. With includeHeaders, the status line and headers come first,
+// as curl -i prints them. Requests without a response return nil.
+func (f *Formatter) FormatRaw(result *ExecutionResult, includeHeaders bool) []byte {
+	if result.Response == nil {
+		return nil
+	}
+
+	var output bytes.Buffer
+	if includeHeaders {
+		fmt.Fprintf(&output, "%s %s\r\n", result.Response.Proto, result.Response.Status)
+		result.Response.Header.Write(&output)
+		output.WriteString("\r\n")
+	}
+	body, _ := result.Response.GetBody()
+	output.Write(body)
+	return output.Bytes()
+}
+
+// formatError formats error information, noting whether a
+// # @expect-error directive expected it
 func (f *Formatter) formatError(result *ExecutionResult) string {
-	return fmt.Sprintf("\n✗ Error: %v\n", result.Error)
+	if result.ErrorExpected() {
+		return "\n" + f.palette.Success(fmt.Sprintf("%s Expected error: %s (# @%s): %v", display.Glyphs().Pass, result.ErrorKind, httprequest.DirectiveExpectError, result.Error)) + "\n"
+	}
+
+	line := f.palette.Failure(fmt.Sprintf("%s Error: %v", display.Glyphs().Fail, result.Error)) + "\n"
+	if result.ExpectedErrors != nil {
+		kind := result.ErrorKind
+		if kind == "" {
+			kind = "unclassified error"
+		}
+		line += fmt.Sprintf("  Expected: %s (# @%s), got %s\n", result.ExpectedErrors, httprequest.DirectiveExpectError, kind)
+	}
+	return "\n" + line
 }
 
 // formatScriptResults formats response handler script execution results
@@ -207,27 +363,39 @@ func (f *Formatter) formatScriptResults(scriptResult *scripting.ScriptExecutionR
 		return ""
 	}
 
-	output.WriteString("\nResponse Handler Results:\n")
+	output.WriteString("\n" + f.palette.Heading("Response Handler Results:") + "\n")
 
 	// Format script execution error
 	if scriptResult.Error != nil {
-		output.WriteString(fmt.Sprintf("  ✗ Script Error: %v\n", scriptResult.Error))
+		output.WriteString("  " + f.palette.Failure(fmt.Sprintf("%s Script Error: %v", display.Glyphs().Fail, scriptResult.Error)) + "\n")
 		return output.String()
 	}
 
-	// Format test results
+	// Format test results, under a heading for each client.describe() group
 	if len(scriptResult.Tests) > 0 {
 		output.WriteString("\n  Tests:\n")
+		group := ""
 		for _, test := range scriptResult.Tests {
-			icon := "✓"
-			if !test.Passed {
-				icon = "✗"
+			indent := "    "
+			if test.Group != "" {
+				indent = "      "
+				if test.Group != group {
+					output.WriteString(fmt.Sprintf("    %s\n", test.Group))
+				}
 			}
-			output.WriteString(fmt.Sprintf("    %s %s", icon, test.Name))
-			if !test.Passed && test.Error != "" {
-				output.WriteString(fmt.Sprintf(" - %s", test.Error))
+			group = test.Group
+
+			line := fmt.Sprintf("%s %s (%s)", display.Glyphs().Pass, test.Name, display.Duration(test.Duration))
+			if test.Passed {
+				line = f.palette.Success(line)
+			} else {
+				line = fmt.Sprintf("%s %s (%s)", display.Glyphs().Fail, test.Name, display.Duration(test.Duration))
+				if test.Error != "" {
+					line += fmt.Sprintf(" - %s", test.Error)
+				}
+				line = f.palette.Failure(line)
 			}
-			output.WriteString("\n")
+			output.WriteString(indent + line + "\n")
 		}
 	}
 
@@ -235,7 +403,7 @@ func (f *Formatter) formatScriptResults(scriptResult *scripting.ScriptExecutionR
 	if len(scriptResult.Assertions) > 0 {
 		output.WriteString("\n  Assertions:\n")
 		for _, assertion := range scriptResult.Assertions {
-			output.WriteString(fmt.Sprintf("    ✗ %s\n", assertion.Message))
+			output.WriteString("    " + f.palette.Failure(display.Glyphs().Fail+" "+assertion.Message) + "\n")
 		}
 	}
 
@@ -255,6 +423,14 @@ func (f *Formatter) formatScriptResults(scriptResult *scripting.ScriptExecutionR
 		}
 	}
 
+	// Format client.env variables (if verbose)
+	if f.verbose && len(scriptResult.EnvVars) > 0 {
+		output.WriteString("\n  Environment Store:\n")
+		for name, value := range scriptResult.EnvVars {
+			output.WriteString(fmt.Sprintf("    %s = %v\n", name, value))
+		}
+	}
+
 	return output.String()
 }
 
@@ -265,24 +441,117 @@ func (f *Formatter) FormatSummary(results []*ExecutionResult) string {
 	successCount := 0
 	errorCount := 0
 	failureCount := 0
+	skippedCount := 0
+	deprecatedCount := 0
 
 	for _, result := range results {
-		if result.HasError() {
+		if result.Deprecation != nil {
+			deprecatedCount++
+		}
+		if result.Skipped {
+			skippedCount++
+		} else if result.Blocked {
+			failureCount++
+		} else if result.HasError() {
 			errorCount++
-		} else if result.IsSuccess() {
+		} else if result.StatusPassed() {
 			successCount++
-		} else if result.IsError() {
+		} else if result.StatusFailed() {
 			failureCount++
 		}
 	}
 
-	summary.WriteString(fmt.Sprintf("\n%s Execution Summary %s\n", strings.Repeat("=", 20), strings.Repeat("=", 20)))
+	summary.WriteString("\n" + f.palette.Heading(fmt.Sprintf("%s Execution Summary %s", strings.Repeat("=", 20), strings.Repeat("=", 20))) + "\n")
 	summary.WriteString(fmt.Sprintf("Total Requests: %d\n", len(results)))
-	summary.WriteString(fmt.Sprintf("✓ Successful: %d\n", successCount))
-	summary.WriteString(fmt.Sprintf("✗ Failed: %d\n", failureCount))
+	summary.WriteString(f.palette.Success(fmt.Sprintf("%s Successful: %d", display.Glyphs().Pass, successCount)) + "\n")
+	failed := fmt.Sprintf("%s Failed: %d", display.Glyphs().Fail, failureCount)
+	if failureCount > 0 {
+		failed = f.palette.Failure(failed)
+	}
+	summary.WriteString(failed + "\n")
 	if errorCount > 0 {
-		summary.WriteString(fmt.Sprintf("⚠ Errors: %d\n", errorCount))
+		summary.WriteString(f.palette.Failure(fmt.Sprintf("%s Errors: %d", display.Glyphs().Error, errorCount)) + "\n")
+	}
+	if skippedCount > 0 {
+		summary.WriteString(f.palette.Warning(fmt.Sprintf("%s Skipped: %d", display.Glyphs().Skip, skippedCount)) + "\n")
+	}
+	if deprecatedCount > 0 {
+		summary.WriteString(f.palette.Warning(fmt.Sprintf("%s Deprecation warnings: %d", display.Glyphs().Error, deprecatedCount)) + "\n")
 	}
 
 	return summary.String()
 }
+
+// FormatFaults formats the faults injected into a run, or nothing if
+// none were
+func (f *Formatter) FormatFaults(config fault.Config, counts *fault.Counts) string {
+	if counts == nil {
+		return ""
+	}
+
+	var output strings.Builder
+	output.WriteString("\nFaults Injected (" + config.String() + "):\n")
+	output.WriteString(fmt.Sprintf("  %d requests\n", counts.Requests))
+	if config.MaxDelay > 0 {
+		output.WriteString(fmt.Sprintf("  %d delayed, %s in total\n", counts.Delayed, display.Milliseconds(counts.Delay)))
+	}
+	if config.DropRate > 0 {
+		output.WriteString(f.palette.Warning(fmt.Sprintf("  %d dropped connections", counts.Dropped)) + "\n")
+	}
+	if config.ErrorRate > 0 {
+		output.WriteString(f.palette.Warning(fmt.Sprintf("  %d error responses", counts.Errors)) + "\n")
+	}
+	return output.String()
+}
+
+// FormatCallbacks lists the requests a run's listener received, or
+// nothing if there were none
+func (f *Formatter) FormatCallbacks(callbacks []listener.Callback) string {
+	if len(callbacks) == 0 {
+		return ""
+	}
+
+	var output strings.Builder
+	output.WriteString("\nCallbacks Received:\n")
+	for _, callback := range callbacks {
+		target := callback.Path
+		if callback.Query != "" {
+			target += "?" + callback.Query
+		}
+		output.WriteString(fmt.Sprintf("  %s  %s %s\n", callback.Time.Local().Format(time.TimeOnly), callback.Method, target))
+	}
+	return output.String()
+}
+
+// FormatRateLimits formats the quota each host reported, or nothing if
+// none did
+func (f *Formatter) FormatRateLimits(limits []RateLimit) string {
+	if len(limits) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, limit := range limits {
+		width = max(width, len(limit.Host))
+	}
+
+	var output strings.Builder
+	output.WriteString("\nRate Limits:\n")
+	for _, limit := range limits {
+		quota := fmt.Sprintf("%d remaining", limit.Remaining)
+		if limit.Limit > 0 {
+			quota = fmt.Sprintf("%d of %d remaining", limit.Remaining, limit.Limit)
+		}
+		if limit.Remaining == 0 {
+			quota = f.palette.Warning(quota)
+		}
+		if limit.Reset != nil {
+			quota += ", resets at " + limit.Reset.Local().Format(time.TimeOnly)
+		}
+		if limit.Waited > 0 {
+			quota += ", waited " + display.Milliseconds(limit.Waited)
+		}
+		output.WriteString(fmt.Sprintf("  %-*s  %s\n", width, limit.Host, quota))
+	}
+	return output.String()
+}
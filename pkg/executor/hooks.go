@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"fmt"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+)
+
+// Hook lets programs embedding postie observe and change request execution,
+// for example to add logging, metrics or extra headers
+type Hook interface {
+	// BeforeRequest runs after variables are expanded and before the request
+	// is sent. It may modify the request; an error stops it from being sent.
+	BeforeRequest(request *httprequest.Request) error
+
+	// AfterResponse runs once the response is received, the response
+	// handler script has run and the response is saved. An error marks the
+	// request as failed.
+	AfterResponse(result *ExecutionResult) error
+
+	// OnError runs when a request fails, including failures returned by
+	// BeforeRequest and AfterResponse
+	OnError(request *httprequest.Request, err error)
+}
+
+// HookFuncs adapts plain functions to the Hook interface. Nil functions are skipped.
+type HookFuncs struct {
+	BeforeRequestFunc func(request *httprequest.Request) error
+	AfterResponseFunc func(result *ExecutionResult) error
+	OnErrorFunc       func(request *httprequest.Request, err error)
+}
+
+// BeforeRequest calls BeforeRequestFunc if set
+func (h HookFuncs) BeforeRequest(request *httprequest.Request) error {
+	if h.BeforeRequestFunc == nil {
+		return nil
+	}
+	return h.BeforeRequestFunc(request)
+}
+
+// AfterResponse calls AfterResponseFunc if set
+func (h HookFuncs) AfterResponse(result *ExecutionResult) error {
+	if h.AfterResponseFunc == nil {
+		return nil
+	}
+	return h.AfterResponseFunc(result)
+}
+
+// OnError calls OnErrorFunc if set
+func (h HookFuncs) OnError(request *httprequest.Request, err error) {
+	if h.OnErrorFunc != nil {
+		h.OnErrorFunc(request, err)
+	}
+}
+
+// MiddlewareHook runs client middleware, such as the functions in the
+// middleware package, after each response
+func MiddlewareHook(middleware ...client.Middleware) Hook {
+	return HookFuncs{
+		AfterResponseFunc: func(result *ExecutionResult) error {
+			if result.Response == nil || result.Response.Response == nil {
+				return nil
+			}
+			resp := result.Response.Response
+			for _, m := range middleware {
+				if err := m(resp.Request, resp); err != nil {
+					return fmt.Errorf("middleware error: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// AddHook registers a hook. Hooks run in the order they were added.
+func (e *Executor) AddHook(hook Hook) {
+	e.hooks = append(e.hooks, hook)
+}
+
+// runBeforeRequest runs the BeforeRequest hooks, stopping at the first error
+func (e *Executor) runBeforeRequest(request *httprequest.Request) error {
+	for _, hook := range e.hooks {
+		if err := hook.BeforeRequest(request); err != nil {
+			return fmt.Errorf("before request hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// runAfterResponse runs the AfterResponse hooks, stopping at the first error
+func (e *Executor) runAfterResponse(result *ExecutionResult) error {
+	for _, hook := range e.hooks {
+		if err := hook.AfterResponse(result); err != nil {
+			return fmt.Errorf("after response hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// runOnError runs the OnError hooks
+func (e *Executor) runOnError(request *httprequest.Request, err error) {
+	for _, hook := range e.hooks {
+		hook.OnError(request, err)
+	}
+}
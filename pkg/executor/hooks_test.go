@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"postie/pkg/httprequest"
+)
+
+func TestExecutorHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo", r.Header.Get("X-Added"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var calls []string
+	var middlewareStatus int
+
+	exec := NewExecutor(nil, nil)
+	exec.AddHook(HookFuncs{
+		BeforeRequestFunc: func(request *httprequest.Request) error {
+			calls = append(calls, "before")
+			request.Headers = append(request.Headers, httprequest.Header{Name: "X-Added", Value: "yes"})
+			return nil
+		},
+		AfterResponseFunc: func(result *ExecutionResult) error {
+			calls = append(calls, "after")
+			return nil
+		},
+		OnErrorFunc: func(request *httprequest.Request, err error) {
+			calls = append(calls, "error")
+		},
+	})
+	exec.AddHook(MiddlewareHook(func(req *http.Request, resp *http.Response) error {
+		middlewareStatus = resp.StatusCode
+		return nil
+	}))
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}}
+	result, err := exec.ExecuteRequest(request)
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+
+	if got := result.Response.Response.Header.Get("X-Echo"); got != "yes" {
+		t.Errorf("Expected BeforeRequest to add a header, server saw %q", got)
+	}
+	if middlewareStatus != http.StatusNoContent {
+		t.Errorf("Expected middleware to see status 204, got %d", middlewareStatus)
+	}
+	if len(calls) != 2 || calls[0] != "before" || calls[1] != "after" {
+		t.Errorf("Unexpected hook calls: %v", calls)
+	}
+}
+
+func TestExecutorHookErrors(t *testing.T) {
+	var onError error
+
+	exec := NewExecutor(nil, &ExecutorConfig{
+		Hooks: []Hook{HookFuncs{
+			BeforeRequestFunc: func(request *httprequest.Request) error {
+				return errors.New("blocked")
+			},
+			OnErrorFunc: func(request *httprequest.Request, err error) {
+				onError = err
+			},
+		}},
+	})
+
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: "http://127.0.0.1:1"}}
+	result, err := exec.ExecuteRequest(request)
+	if err == nil || err.Error() != "before request hook: blocked" {
+		t.Fatalf("Expected before request hook error, got %v", err)
+	}
+	if result == nil || result.Error != err {
+		t.Errorf("Expected the error on the result, got %+v", result)
+	}
+	if onError != err {
+		t.Errorf("Expected OnError to receive %v, got %v", err, onError)
+	}
+}
+
+func TestRateLimitTracker(t *testing.T) {
+	remaining := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "2")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+		w.Header().Set("X-RateLimit-Reset", "30")
+		remaining--
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	tracker := NewRateLimitTracker(time.Minute)
+	tracker.now = func() time.Time { return now }
+	tracker.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+
+	exec := NewExecutor(nil, nil)
+	exec.AddHook(tracker)
+	request := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL + "/items"}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second response used up the quota, so the third request waited
+	if len(slept) != 1 || slept[0] != 30*time.Second {
+		t.Errorf("Expected one 30s wait, got %v", slept)
+	}
+	limits := tracker.Limits()
+	if len(limits) != 1 || limits[0].Host != requestHost(server.URL) || limits[0].Limit != 2 || limits[0].Responses != 3 || limits[0].Waited != 30000 {
+		t.Fatalf("Unexpected limits %+v", limits)
+	}
+
+	// Retry-After on a 429 runs the quota out until then; waits longer
+	// than allowed don't happen
+	header := http.Header{"Retry-After": {"600"}}
+	tracker.Observe("api.example.com", http.StatusTooManyRequests, header)
+	slept = nil
+	if err := tracker.BeforeRequest(&httprequest.Request{URL: &httprequest.URL{Raw: "https://api.example.com/"}}); err != nil || len(slept) != 0 {
+		t.Errorf("Expected no wait beyond the maximum, got %v (%v)", slept, err)
+	}
+	if limits := tracker.Limits(); len(limits) != 2 || limits[1].Host != "api.example.com" || limits[1].Remaining != 0 || !limits[1].Reset.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("Unexpected limits %+v", limits)
+	}
+}
+
+func TestRateLimitReset(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"60", now.Add(time.Minute)},
+		{"1700000900", time.Unix(1700000900, 0)},
+		{"1700000900000", time.Unix(1700000900, 0)},
+	}
+	for _, tt := range tests {
+		reset := resetTime(http.Header{"X-Ratelimit-Reset": {tt.value}}, now)
+		if reset == nil || !reset.Equal(tt.want) {
+			t.Errorf("resetTime(%q) = %v, want %v", tt.value, reset, tt.want)
+		}
+	}
+
+	if limit, ok := headerInt(http.Header{"Ratelimit-Limit": {"100, 100;w=60"}}, "X-RateLimit-Limit", "RateLimit-Limit"); !ok || limit != 100 {
+		t.Errorf("Expected 100, got %d", limit)
+	}
+}
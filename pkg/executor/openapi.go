@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+	"postie/pkg/schema"
+	"postie/pkg/scripting"
+)
+
+// checkOpenAPI validates a response against its OpenAPI operation: the one
+// named by the request's # @openapi directive, or else the operation of the
+// run's spec that matches the request's method and path. Violations are
+// returned as failed assertions.
+func (e *Executor) checkOpenAPI(request *httprequest.Request, resp *client.Response) []*scripting.AssertionError {
+	operation, err := e.openAPIOperation(request)
+	if err != nil {
+		return []*scripting.AssertionError{{Message: "OpenAPI: " + err.Error()}}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	body, err := resp.GetBody()
+	if err != nil {
+		return []*scripting.AssertionError{{Message: fmt.Sprintf("OpenAPI: failed to read response body: %v", err)}}
+	}
+
+	violations, err := operation.ValidateResponse(resp.Response.StatusCode, resp.ContentType(), body)
+	if err != nil {
+		return []*scripting.AssertionError{{Message: fmt.Sprintf("OpenAPI %s: %v", operation.Name(), err)}}
+	}
+
+	assertions := make([]*scripting.AssertionError, 0, len(violations))
+	for _, violation := range violations {
+		assertions = append(assertions, &scripting.AssertionError{
+			Message: fmt.Sprintf("OpenAPI %s: %s", operation.Name(), violation.Error()),
+		})
+	}
+	return assertions
+}
+
+// openAPIOperation finds the operation a request is checked against, or
+// nil if there is none
+func (e *Executor) openAPIOperation(request *httprequest.Request) (*schema.Operation, error) {
+	spec, operationID := e.openapi, ""
+
+	if value, ok := request.Metadata[httprequest.DirectiveOpenAPI]; ok {
+		path, id, _ := strings.Cut(value, "#")
+		operationID = id
+		if path != "" {
+			var err error
+			if spec, err = e.loadSpec(path); err != nil {
+				return nil, err
+			}
+		}
+		if spec == nil {
+			return nil, fmt.Errorf("@%s needs a spec file, e.g. # @%s ./openapi.json#%s", httprequest.DirectiveOpenAPI, httprequest.DirectiveOpenAPI, id)
+		}
+	}
+	if spec == nil {
+		return nil, nil
+	}
+
+	if operationID != "" {
+		operation, ok := spec.Operation(operationID)
+		if !ok {
+			return nil, fmt.Errorf("operation %q not found in the spec", operationID)
+		}
+		return operation, nil
+	}
+
+	path := ""
+	if request.URL != nil {
+		if parsed, err := url.Parse(request.URL.Raw); err == nil {
+			path = parsed.Path
+		}
+	}
+	operation, ok := spec.FindOperation(request.Method, path)
+	if !ok {
+		if _, named := request.Metadata[httprequest.DirectiveOpenAPI]; named {
+			return nil, fmt.Errorf("no operation matches %s %s", request.Method, path)
+		}
+		// Requests outside the run's spec aren't checked
+		return nil, nil
+	}
+	return operation, nil
+}
+
+// loadSpec loads an OpenAPI spec named by a # @openapi directive, once per run
+func (e *Executor) loadSpec(path string) (*schema.Spec, error) {
+	e.mu.Lock()
+	spec, ok := e.specs[path]
+	e.mu.Unlock()
+	if ok {
+		return spec, nil
+	}
+	spec, err := schema.LoadSpec(path)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.specs[path] = spec
+	e.mu.Unlock()
+	return spec, nil
+}
@@ -0,0 +1,280 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+	"postie/pkg/scripting"
+)
+
+// PaginationResult says what a # @paginate request fetched
+type PaginationResult struct {
+	Pages     int  `json:"pages"`
+	Items     int  `json:"items"`
+	Truncated bool `json:"truncated,omitempty"` // Stopped at the page limit with pages left
+}
+
+// itemKeys are the properties that usually hold the items of a page
+var itemKeys = []string{"data", "items", "results", "records", "entries"}
+
+// paginate fetches the pages that follow the first response of a
+// # @paginate request. It returns the first response with the items of
+// every page as its body, a JSON array, or the first page that didn't
+// succeed.
+func (e *Executor) paginate(ctx context.Context, request *httprequest.Request, pagination *httprequest.Pagination, first *client.Response) (*client.Response, *PaginationResult, error) {
+	result := &PaginationResult{Pages: 1}
+	current := request.URL.Raw
+	seen := map[string]bool{current: true}
+	pageNumber := 1
+	if pagination.Mode == httprequest.PaginatePageParam {
+		pageNumber = currentPage(current, pagination.Param)
+	}
+
+	var items []interface{}
+	found := false
+	resp := first
+	for {
+		body, err := resp.GetBody()
+		if err != nil {
+			return nil, result, err
+		}
+		if !resp.IsSuccess() {
+			// The failed page is the response, so its status and body are shown
+			return resp, result, nil
+		}
+
+		page, ok := pageItems(body, pagination.Items)
+		found = found || ok
+		items = append(items, page...)
+		result.Items = len(items)
+
+		next, err := nextPage(pagination, current, resp, body, pageNumber, len(page))
+		if err != nil {
+			return nil, result, err
+		}
+		if next == "" || seen[next] {
+			break
+		}
+		if result.Pages == pagination.MaxPages {
+			result.Truncated = true
+			logging.Warn("stopped paginating at the page limit", "name", request.Name, "pages", result.Pages, "next", next)
+			break
+		}
+
+		pageRequest := *request
+		pageRequest.URL = &httprequest.URL{Raw: next}
+		req, err := e.buildClientRequest(&pageRequest)
+		if err != nil {
+			return nil, result, fmt.Errorf("failed to build request for page %d: %w", result.Pages+1, err)
+		}
+		req.Context(ctx)
+
+		logging.Verbose("fetching page", "name", request.Name, "page", result.Pages+1, "url", next)
+		if resp, err = req.Execute(); err == nil {
+			if _, err = resp.GetBody(); err == nil {
+				if decodeErr := e.decodeResponse(&pageRequest, resp); decodeErr != nil {
+					logging.Warn("failed to decode response body", "error", decodeErr)
+				}
+			}
+		}
+		if err != nil {
+			return nil, result, fmt.Errorf("failed to fetch page %d: %w", result.Pages+1, err)
+		}
+
+		result.Pages++
+		current = next
+		seen[next] = true
+		pageNumber++
+	}
+
+	if found {
+		if items == nil {
+			items = []interface{}{}
+		}
+		combined, err := json.Marshal(items)
+		if err != nil {
+			return nil, result, fmt.Errorf("failed to combine pages: %w", err)
+		}
+		first.SetBody(combined)
+	}
+	return first, result, nil
+}
+
+// pageItems returns the items of a page: the array at path, or else the
+// body if it is an array, an array property such as data or items, or the
+// only array HAL embeds
+func pageItems(body []byte, path string) ([]interface{}, bool) {
+	var decoded interface{}
+	if json.Unmarshal(body, &decoded) != nil {
+		return nil, false
+	}
+
+	if path != "" {
+		value, found, err := scripting.EvalJSONPath(decoded, path)
+		items, ok := value.([]interface{})
+		return items, found && err == nil && ok
+	}
+
+	switch v := decoded.(type) {
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		for _, key := range itemKeys {
+			if items, ok := v[key].([]interface{}); ok {
+				return items, true
+			}
+		}
+		if embedded, ok := v["_embedded"].(map[string]interface{}); ok {
+			v = embedded
+		}
+		// Otherwise the only array property
+		var only []interface{}
+		arrays := 0
+		for _, value := range v {
+			if items, ok := value.([]interface{}); ok {
+				only = items
+				arrays++
+			}
+		}
+		return only, arrays == 1
+	}
+	return nil, false
+}
+
+// nextPage returns the URL of the page after the one at current, or "" if
+// it was the last
+func nextPage(pagination *httprequest.Pagination, current string, resp *client.Response, body []byte, pageNumber, items int) (string, error) {
+	switch pagination.Mode {
+	case httprequest.PaginateLinkHeader:
+		next := linkNext(resp.Header.Values("Link"))
+		if next == "" {
+			return "", nil
+		}
+		return resolveURL(current, next)
+
+	case httprequest.PaginateLinks:
+		var decoded interface{}
+		if json.Unmarshal(body, &decoded) != nil {
+			return "", nil
+		}
+		next, _ := scripting.HypermediaLink(decoded, "next")
+		if next == "" {
+			return "", nil
+		}
+		return resolveURL(current, next)
+
+	case httprequest.PaginateCursor:
+		var decoded interface{}
+		if json.Unmarshal(body, &decoded) != nil {
+			return "", nil
+		}
+		value, found, err := scripting.EvalJSONPath(decoded, pagination.Path)
+		if err != nil {
+			return "", fmt.Errorf("@%s: %w", httprequest.DirectivePaginate, err)
+		}
+		var cursor string
+		switch v := value.(type) {
+		case string:
+			cursor = v
+		case float64:
+			cursor = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if !found || cursor == "" {
+			return "", nil
+		}
+		// A next link rather than an opaque cursor
+		if strings.HasPrefix(cursor, "http://") || strings.HasPrefix(cursor, "https://") || strings.HasPrefix(cursor, "/") || strings.HasPrefix(cursor, "?") {
+			return resolveURL(current, cursor)
+		}
+		return withQueryParam(current, pagination.Param, cursor)
+
+	case httprequest.PaginatePageParam:
+		// An empty page is past the last one
+		if items == 0 {
+			return "", nil
+		}
+		return withQueryParam(current, pagination.Param, strconv.Itoa(pageNumber+1))
+	}
+	return "", nil
+}
+
+// linkNext returns the rel="next" target of Link headers
+func linkNext(values []string) string {
+	return linkTarget(values, "next")
+}
+
+// linkTarget returns the target of the first link of a relation in Link
+// headers
+func linkTarget(values []string, relation string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && containsFold(strings.Fields(strings.Trim(rel, `"`)), relation) {
+					return target[1 : len(target)-1]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// containsFold reports whether values holds s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveURL resolves a possibly relative link against the URL of the page
+// it came from
+func resolveURL(base, link string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", base, err)
+	}
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link %q: %w", link, err)
+	}
+	return baseURL.ResolveReference(linkURL).String(), nil
+}
+
+// withQueryParam returns rawURL with a query parameter set to value
+func withQueryParam(rawURL, name, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	query := parsed.Query()
+	query.Set(name, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// currentPage returns the page number a URL asks for, or 1
+func currentPage(rawURL, param string) int {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return 1
+	}
+	if n, err := strconv.Atoi(parsed.Query().Get(param)); err == nil {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+	"postie/pkg/scripting"
+)
+
+// PollResult says how often a # @poll request was sent and whether its
+// condition held before the timeout
+type PollResult struct {
+	Attempts  int    `json:"attempts"`
+	Waited    int64  `json:"waited_ms"` // From the first response to the last
+	Satisfied bool   `json:"satisfied"`
+	Until     string `json:"until"`
+}
+
+// poll sends a # @poll request again, every interval, until its condition
+// holds for the response or the timeout expires. It returns the last
+// response.
+func (e *Executor) poll(request *httprequest.Request, poll *httprequest.Poll, resp *client.Response) (*client.Response, *PollResult, error) {
+	result := &PollResult{Attempts: 1, Until: poll.Until}
+	start := time.Now()
+	deadline := start.Add(poll.Timeout)
+	for {
+		met, err := e.pollCondition(request, poll.Until, resp)
+		result.Waited = time.Since(start).Milliseconds()
+		if err != nil {
+			return resp, result, fmt.Errorf("@%s: %w", httprequest.DirectivePoll, err)
+		}
+		if met {
+			result.Satisfied = true
+			return resp, result, nil
+		}
+		if time.Now().Add(poll.Interval).After(deadline) {
+			logging.Warn("poll condition never held", "name", request.Name, "attempts", result.Attempts, "timeout", poll.Timeout)
+			return resp, result, nil
+		}
+
+		logging.Verbose("poll condition not met; sending again", "name", request.Name, "attempt", result.Attempts, "status", resp.StatusCode, "interval", poll.Interval)
+		time.Sleep(poll.Interval)
+		if resp, err = e.sendAgain(request); err != nil {
+			result.Waited = time.Since(start).Milliseconds()
+			return nil, result, fmt.Errorf("poll attempt %d failed: %w", result.Attempts+1, err)
+		}
+		result.Attempts++
+	}
+}
+
+// pollCondition evaluates a # @poll condition against a response
+func (e *Executor) pollCondition(request *httprequest.Request, until string, resp *client.Response) (bool, error) {
+	envVars := make(map[string]interface{})
+	if e.environment != nil {
+		envVars = e.environment.Variables
+	}
+	return scripting.EvaluateCondition(until, &scripting.ScriptContext{
+		Request:   request,
+		Response:  resp,
+		Env:       envVars,
+		Globals:   e.globals,
+		EnvStore:  e.envStore,
+		Limits:    e.scriptLimits,
+		Transport: e.transport,
+		Listener:  e.listener,
+	})
+}
+
+// sendAgain sends an expanded request once more, with its own timeouts,
+// and reads its body
+func (e *Executor) sendAgain(request *httprequest.Request) (*client.Response, error) {
+	req, err := e.buildClientRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := requestContext(request)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	req.Context(ctx)
+
+	resp, err := req.Execute()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resp.GetBody(); err != nil {
+		return nil, err
+	}
+	if err := e.decodeResponse(request, resp); err != nil {
+		logging.Warn("failed to decode response body", "error", err)
+	}
+	return resp, nil
+}
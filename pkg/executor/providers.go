@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/environment"
+	"postie/pkg/httprequest"
+)
+
+// VariableProvider returns the value of a dynamic variable such as
+// {{$vault secret/db}}. name is the variable's name without the $, for
+// example "vault" or "vault.read", and args the words after it. Secret
+// values are masked in output.
+type VariableProvider func(name string, args []string) (value string, secret bool, err error)
+
+// AddVariableProvider registers the provider of the dynamic variables
+// {{$prefix}} and {{$prefix.anything}}. A provider registered for a longer
+// prefix takes precedence.
+func (e *Executor) AddVariableProvider(prefix string, provider VariableProvider) {
+	if e.providers == nil {
+		e.providers = make(map[string]VariableProvider)
+	}
+	e.providers[prefix] = provider
+}
+
+// expandDynamicVariables evaluates the {{$...}} references left in an
+// expanded request. Each reference is evaluated once per request.
+func (e *Executor) expandDynamicVariables(expanded *httprequest.Request, missing []string) (*httprequest.Request, error) {
+	values := make(map[string]interface{})
+	for _, reference := range missing {
+		if !strings.HasPrefix(reference, "$") {
+			continue
+		}
+		fields := strings.Fields(reference[1:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		provider, ok := e.findProvider(fields[0])
+		if !ok {
+			continue
+		}
+		value, secret, err := provider(fields[0], fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("{{%s}}: %w", reference, err)
+		}
+		if secret && e.redactor != nil {
+			e.redactor.Add(value)
+		}
+		values[reference] = value
+	}
+
+	if len(values) == 0 {
+		return expanded, nil
+	}
+	result := expandRequest(expanded, &environment.ResolvedEnvironment{Variables: values})
+
+	// Values such as {{$faker.name}} may hold spaces, which aren't valid in
+	// the URL that is sent
+	if expanded.URL != nil {
+		escaped := make(map[string]interface{}, len(values))
+		for reference, value := range values {
+			escaped[reference] = escapeURLValue(value.(string))
+		}
+		result.URL.Raw = environment.NewResolver().ExpandString(expanded.URL.Raw, &environment.ResolvedEnvironment{Variables: escaped})
+	}
+	return result, nil
+}
+
+// escapeURLValue percent-encodes the characters of a value that can't
+// appear in a URL, leaving its structure (/, ?, & and so on) as is
+func escapeURLValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c > ' ' && c < 0x7f && !strings.ContainsRune(`"<>\^`+"`"+`{|}`, rune(c)) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// findProvider returns the provider of the longest registered prefix of a
+// dynamic variable's name
+func (e *Executor) findProvider(name string) (VariableProvider, bool) {
+	for prefix := name; ; {
+		if provider, ok := e.providers[prefix]; ok {
+			return provider, true
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			return nil, false
+		}
+		prefix = prefix[:i]
+	}
+}
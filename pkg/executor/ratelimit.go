@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+)
+
+// RateLimit is the quota a host reported in its last response with rate
+// limit headers
+type RateLimit struct {
+	Host      string     `json:"host"`
+	Limit     int        `json:"limit,omitempty"`
+	Remaining int        `json:"remaining"`
+	Reset     *time.Time `json:"reset,omitempty"`
+	Responses int        `json:"responses"` // Responses that reported the quota
+	Waited    int64      `json:"waited_ms,omitempty"`
+
+	warned bool // A wait longer than allowed was reported
+}
+
+// RateLimitTracker is a hook that follows the X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers (or RateLimit-* and
+// Retry-After) of each host. With a maximum wait, a request to a host
+// whose quota has run out waits for it to reset, if that's soon enough.
+type RateLimitTracker struct {
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	limits map[string]*RateLimit
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewRateLimitTracker creates a tracker that waits up to maxWait for
+// exhausted quotas to reset (0 to never wait)
+func NewRateLimitTracker(maxWait time.Duration) *RateLimitTracker {
+	return &RateLimitTracker{
+		maxWait: maxWait,
+		limits:  make(map[string]*RateLimit),
+		now:     time.Now,
+		sleep:   time.Sleep,
+	}
+}
+
+// Limits returns the quota of each host that reported one, by host
+func (t *RateLimitTracker) Limits() []RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limits := make([]RateLimit, 0, len(t.limits))
+	for _, limit := range t.limits {
+		limits = append(limits, *limit)
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Host < limits[j].Host })
+	return limits
+}
+
+// BeforeRequest waits for the quota of the request's host to reset if it
+// has run out
+func (t *RateLimitTracker) BeforeRequest(request *httprequest.Request) error {
+	if t.maxWait <= 0 || request.URL == nil {
+		return nil
+	}
+	host := requestHost(request.URL.Raw)
+
+	t.mu.Lock()
+	limit := t.limits[host]
+	var wait time.Duration
+	if limit != nil && limit.Remaining == 0 && limit.Reset != nil {
+		wait = limit.Reset.Sub(t.now())
+	}
+	tooLong := wait > t.maxWait && !limit.warned
+	if tooLong {
+		limit.warned = true
+	}
+	t.mu.Unlock()
+
+	if wait <= 0 || wait > t.maxWait {
+		if tooLong {
+			logging.Warn("rate limit reached; it resets later than --rate-limit-wait allows", "host", host, "wait", wait.Round(time.Second))
+		}
+		return nil
+	}
+
+	logging.Warn("rate limit reached; waiting for it to reset", "host", host, "wait", wait.Round(time.Second))
+	t.sleep(wait)
+
+	t.mu.Lock()
+	limit.Waited += wait.Milliseconds()
+	if limit.Remaining == 0 {
+		// Until the host says otherwise, assume the quota was renewed
+		limit.Remaining = limit.Limit
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// AfterResponse records the quota a response reports
+func (t *RateLimitTracker) AfterResponse(result *ExecutionResult) error {
+	if result.Response == nil || result.Request == nil || result.Request.URL == nil {
+		return nil
+	}
+	t.Observe(requestHost(result.Request.URL.Raw), result.StatusCode, result.Response.Header)
+	return nil
+}
+
+// OnError does nothing; failed requests report no quota
+func (t *RateLimitTracker) OnError(request *httprequest.Request, err error) {}
+
+// Observe records the quota reported by a response from host
+func (t *RateLimitTracker) Observe(host string, status int, header http.Header) {
+	now := t.now()
+	limit, hasLimit := headerInt(header, "X-RateLimit-Limit", "RateLimit-Limit")
+	remaining, hasRemaining := headerInt(header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	reset := resetTime(header, now)
+
+	// A 429 with Retry-After means no quota is left until then
+	if status == http.StatusTooManyRequests {
+		if retryAfter := retryAfterTime(header.Get("Retry-After"), now); retryAfter != nil {
+			remaining, hasRemaining, reset = 0, true, retryAfter
+		}
+	}
+	if !hasRemaining {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked := t.limits[host]
+	if tracked == nil {
+		tracked = &RateLimit{Host: host}
+		t.limits[host] = tracked
+	}
+	if hasLimit {
+		tracked.Limit = limit
+	}
+	tracked.Remaining = remaining
+	tracked.Reset = reset
+	tracked.Responses++
+	tracked.warned = false
+}
+
+// requestHost returns the host of a request URL
+func requestHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.Host
+}
+
+// headerInt returns the first number in the first of the headers that is
+// set. Values such as "100, 100;w=60" count as 100.
+func headerInt(header http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		end := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+			value = value[:end]
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// resetTime reads X-RateLimit-Reset or RateLimit-Reset, given in seconds
+// from now or, if large enough, as a Unix time in seconds or milliseconds
+func resetTime(header http.Header, now time.Time) *time.Time {
+	seconds, ok := headerInt(header, "X-RateLimit-Reset", "RateLimit-Reset")
+	if !ok {
+		return nil
+	}
+
+	var reset time.Time
+	switch {
+	case seconds > 1e12:
+		reset = time.UnixMilli(int64(seconds))
+	case seconds > 1e9:
+		reset = time.Unix(int64(seconds), 0)
+	default:
+		reset = now.Add(time.Duration(seconds) * time.Second)
+	}
+	return &reset
+}
+
+// retryAfterTime reads a Retry-After header, given in seconds or as an
+// HTTP date
+func retryAfterTime(value string, now time.Time) *time.Time {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		retry := now.Add(time.Duration(seconds) * time.Second)
+		return &retry
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return &date
+	}
+	return nil
+}
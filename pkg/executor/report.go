@@ -0,0 +1,254 @@
+package executor
+
+import (
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/fault"
+	"postie/pkg/listener"
+	"postie/pkg/redact"
+)
+
+// RunReport is the machine-readable summary of an execution run
+type RunReport struct {
+	Timestamp time.Time       `json:"timestamp"`
+	File      string          `json:"file,omitempty"`
+	Files     []string        `json:"files,omitempty"` // Set instead of File when several files ran
+	Env       string          `json:"environment,omitempty"`
+	Results   []*ResultReport `json:"results"`
+	Summary   ReportSummary   `json:"summary"`
+
+	// RateLimits are the quotas the hosts reported, set by the caller
+	RateLimits []RateLimit `json:"rate_limits,omitempty"`
+
+	// Faults are the faults --fault injected, set by the caller
+	Faults *fault.Counts `json:"faults,omitempty"`
+
+	// Callbacks are the requests the run's listener received, set by the
+	// caller
+	Callbacks []listener.Callback `json:"callbacks,omitempty"`
+}
+
+// ResultReport is the machine-readable form of a single ExecutionResult
+type ResultReport struct {
+	Index        int                   `json:"index"`
+	File         string                `json:"file,omitempty"` // Set when several files ran
+	Name         string                `json:"name,omitempty"`
+	Method       string                `json:"method"`
+	URL          string                `json:"url"`
+	StatusCode   int                   `json:"status_code,omitempty"`
+	Status       string                `json:"status,omitempty"`
+	Expected     string                `json:"expected_status,omitempty"`
+	DurationMs   int64                 `json:"duration_ms"`
+	Timings      *client.TimingsReport `json:"timings,omitempty"`
+	Cache        string                `json:"cache,omitempty"` // What the HTTP cache did, with --cache
+	Pagination   *PaginationResult     `json:"pagination,omitempty"`
+	Poll         *PollResult           `json:"poll,omitempty"`
+	Deprecation  *Deprecation          `json:"deprecation,omitempty"`
+	Size         int64                 `json:"size"`
+	ContentType  string                `json:"content_type,omitempty"`
+	Headers      map[string][]string   `json:"headers,omitempty"`
+	Body         string                `json:"body,omitempty"`
+	Error        string                `json:"error,omitempty"`
+	ErrorKind    string                `json:"error_kind,omitempty"`
+	ExpectedErr  string                `json:"expected_error,omitempty"`
+	Tests        []TestReport          `json:"tests,omitempty"`
+	Assertions   []string              `json:"failed_assertions,omitempty"`
+	Logs         []string              `json:"logs,omitempty"`
+	ScriptError  string                `json:"script_error,omitempty"`
+	ResponseFile string                `json:"response_file,omitempty"`
+	Correlation  map[string]string     `json:"correlation,omitempty"`
+	Skipped      bool                  `json:"skipped,omitempty"`
+	SkipReason   string                `json:"skip_reason,omitempty"`
+	Blocked      bool                  `json:"blocked,omitempty"`
+	Passed       bool                  `json:"passed"`
+}
+
+// TestReport is the machine-readable form of a client.test() result
+type TestReport struct {
+	Name       string `json:"name"`
+	Group      string `json:"group,omitempty"`
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ReportSummary holds aggregate counts for a run
+type ReportSummary struct {
+	Total      int   `json:"total"`
+	Successful int   `json:"successful"`
+	Failed     int   `json:"failed"`
+	Errors     int   `json:"errors"`
+	Skipped    int   `json:"skipped"`
+	Deprecated int   `json:"deprecated,omitempty"` // Responses with deprecation, sunset or warning headers
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// NewRunReport builds a report from execution results
+func NewRunReport(file, env string, results []*ExecutionResult) *RunReport {
+	report := &RunReport{
+		Timestamp: time.Now(),
+		File:      file,
+		Env:       env,
+		Results:   make([]*ResultReport, 0, len(results)),
+	}
+
+	for i, result := range results {
+		entry := NewResultReport(result, i+1)
+		report.Results = append(report.Results, entry)
+
+		report.Summary.Total++
+		report.Summary.DurationMs += entry.DurationMs
+		if result.Deprecation != nil {
+			report.Summary.Deprecated++
+		}
+		if result.Skipped {
+			report.Summary.Skipped++
+		} else if result.Blocked {
+			report.Summary.Failed++
+		} else if result.HasError() {
+			report.Summary.Errors++
+		} else if result.StatusPassed() {
+			report.Summary.Successful++
+		} else if result.StatusFailed() {
+			report.Summary.Failed++
+		}
+	}
+
+	return report
+}
+
+// MergeRunReports combines the reports of several files run together into
+// one, with each result labeled with its file
+func MergeRunReports(env string, reports []*RunReport) *RunReport {
+	merged := &RunReport{Timestamp: time.Now(), Env: env, Results: []*ResultReport{}}
+	for _, report := range reports {
+		merged.Files = append(merged.Files, report.File)
+		for _, result := range report.Results {
+			result.File = report.File
+			merged.Results = append(merged.Results, result)
+		}
+		merged.Summary.Total += report.Summary.Total
+		merged.Summary.Successful += report.Summary.Successful
+		merged.Summary.Failed += report.Summary.Failed
+		merged.Summary.Errors += report.Summary.Errors
+		merged.Summary.Skipped += report.Summary.Skipped
+		merged.Summary.Deprecated += report.Summary.Deprecated
+		merged.Summary.DurationMs += report.Summary.DurationMs
+	}
+	return merged
+}
+
+// NewResultReport converts a single execution result into its report form
+func NewResultReport(result *ExecutionResult, index int) *ResultReport {
+	entry := &ResultReport{
+		Index:        index,
+		StatusCode:   result.StatusCode,
+		Status:       result.Status,
+		Expected:     result.ExpectedStatus.String(),
+		ExpectedErr:  result.ExpectedErrors.String(),
+		DurationMs:   result.Duration.Milliseconds(),
+		Timings:      result.Timings.Report(),
+		Pagination:   result.Pagination,
+		Poll:         result.Poll,
+		Deprecation:  result.Deprecation,
+		ResponseFile: result.ResponseFilePath,
+		Skipped:      result.Skipped,
+		SkipReason:   result.SkipReason,
+		Blocked:      result.Blocked,
+	}
+
+	if result.Cache != nil {
+		entry.Cache = result.Cache.Status
+	}
+
+	if result.Request != nil {
+		entry.Name = result.Request.Name
+		entry.Method = result.Request.Method
+		if result.Request.URL != nil {
+			entry.URL = result.Request.URL.Raw
+		}
+	}
+
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+		entry.ErrorKind = result.ErrorKind
+	}
+
+	if len(result.Correlation) > 0 {
+		entry.Correlation = make(map[string]string, len(result.Correlation))
+		for _, header := range result.Correlation {
+			entry.Correlation[header.Name] = header.Value
+		}
+	}
+
+	if result.Response != nil {
+		entry.ContentType = result.Response.ContentType()
+		entry.Headers = result.Response.Header
+		if text, err := result.Response.Text(); err == nil {
+			entry.Body = text
+		}
+		entry.Size = result.Response.Size()
+	}
+
+	if result.ScriptResult != nil {
+		for _, test := range result.ScriptResult.Tests {
+			entry.Tests = append(entry.Tests, TestReport{
+				Name:       test.Name,
+				Group:      test.Group,
+				Passed:     test.Passed,
+				Error:      test.Error,
+				DurationMs: test.Duration.Milliseconds(),
+			})
+		}
+		for _, assertion := range result.ScriptResult.Assertions {
+			entry.Assertions = append(entry.Assertions, assertion.Message)
+		}
+		entry.Logs = result.ScriptResult.Logs
+		if result.ScriptResult.Error != nil {
+			entry.ScriptError = result.ScriptResult.Error.Error()
+		}
+	}
+
+	entry.Passed = result.Passed()
+
+	return entry
+}
+
+// Redact masks secret values in every result of the report
+func (r *RunReport) Redact(redactor *redact.Redactor) {
+	if redactor.Empty() {
+		return
+	}
+	for _, result := range r.Results {
+		result.Redact(redactor)
+	}
+}
+
+// Redact masks secret values in a result report
+func (r *ResultReport) Redact(redactor *redact.Redactor) {
+	if redactor.Empty() {
+		return
+	}
+
+	r.URL = redactor.Redact(r.URL)
+	r.Body = redactor.Redact(r.Body)
+	r.Error = redactor.Redact(r.Error)
+	r.ScriptError = redactor.Redact(r.ScriptError)
+	for key, values := range r.Headers {
+		masked := make([]string, len(values))
+		for i, value := range values {
+			masked[i] = redactor.Redact(value)
+		}
+		r.Headers[key] = masked
+	}
+	for i := range r.Tests {
+		r.Tests[i].Error = redactor.Redact(r.Tests[i].Error)
+	}
+	for i := range r.Assertions {
+		r.Assertions[i] = redactor.Redact(r.Assertions[i])
+	}
+	for i := range r.Logs {
+		r.Logs[i] = redactor.Redact(r.Logs[i])
+	}
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"postie/pkg/httprequest"
+	"postie/pkg/selector"
+)
+
+// ErrNoMatch is returned when a --request filter or --select expression
+// matches no request of a file
+var ErrNoMatch = errors.New("no requests match")
+
+// SelectorFields are the request fields --select expressions can use
+var SelectorFields = []string{"name", "method", "url", "host", "path", "tag", "index"}
+
+// ParseSelector parses a --select expression over the request fields
+func ParseSelector(expression string) (*selector.Selector, error) {
+	return selector.Parse(expression, SelectorFields...)
+}
+
+// requestTarget returns the values a --select expression matches a request
+// on. URLs are as written, before variables are expanded, and index is the
+// request's 1-based number in its file.
+func requestTarget(request *httprequest.Request, index int) selector.Target {
+	target := selector.Target{
+		"method": {request.Method},
+		"tag":    request.Tags(),
+		"index":  {strconv.Itoa(index + 1)},
+	}
+	if request.Name != "" {
+		target["name"] = []string{request.Name}
+	}
+	if request.URL != nil {
+		target["url"] = []string{request.URL.Raw}
+		target["host"] = []string{request.URL.Host}
+		target["path"] = []string{request.URL.Path}
+	}
+	return target
+}
+
+// SelectMatching returns the indexes in selected of the requests that match
+// a --select expression
+func SelectMatching(requestsFile *httprequest.RequestsFile, selected []int, sel *selector.Selector) ([]int, error) {
+	var matching []int
+	for _, i := range selected {
+		if sel.Match(requestTarget(&requestsFile.Requests[i], i)) {
+			matching = append(matching, i)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w --select %s", ErrNoMatch, sel)
+	}
+	return matching, nil
+}
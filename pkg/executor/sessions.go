@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/httprequest"
+	"postie/pkg/logging"
+	"postie/pkg/session"
+)
+
+// loadSessions makes the sessions a request references available, running
+// their login requests if they aren't saved yet or have expired
+func (e *Executor) loadSessions(request *httprequest.Request) error {
+	if e.sessionStore == nil {
+		return nil
+	}
+
+	for _, name := range e.sessionReferences(request) {
+		e.mu.Lock()
+		_, ok := e.sessions[name]
+		e.mu.Unlock()
+		if ok {
+			continue
+		}
+
+		saved, err := e.sessionStore.Load(name)
+		if err != nil {
+			return err
+		}
+		if saved == nil || saved.Expired() {
+			if saved, err = e.login(name); err != nil {
+				return err
+			}
+		}
+		e.mu.Lock()
+		e.sessions[name] = saved
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+// sessionReferences returns the names of the sessions a request uses, in
+// its own text or in the file variables before it
+func (e *Executor) sessionReferences(request *httprequest.Request) []string {
+	texts := requestTexts(request)
+	if e.requestsFile != nil {
+		for _, variable := range e.requestsFile.VariablesBefore(request.LineNumber) {
+			texts = append(texts, variable.Value)
+		}
+	}
+	return session.References(texts...)
+}
+
+// renewSessions runs the login requests of sessions a server rejected
+// again. Sessions another request renewed after since are kept.
+func (e *Executor) renewSessions(names []string, since time.Time) error {
+	for _, name := range names {
+		e.mu.Lock()
+		current, ok := e.sessions[name]
+		renewed := ok && !current.CreatedAt.Before(since)
+		if !renewed {
+			delete(e.sessions, name)
+		}
+		e.mu.Unlock()
+		if renewed {
+			continue
+		}
+
+		logging.Verbose("session rejected, logging in again", "session", name)
+		if _, err := e.login(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// login runs the request that declares a session and returns the session
+// it saved
+func (e *Executor) login(name string) (*session.Session, error) {
+	if e.loggingIn[name] {
+		return nil, fmt.Errorf("session %s: the login request uses its own session", name)
+	}
+
+	requestsFile, request, err := e.sessionStore.FindLogin(name)
+	if err != nil {
+		return nil, err
+	}
+	logging.Verbose("logging in", "session", name, "request", request.Name)
+
+	// The login request runs on a worker that sees the file variables of
+	// its own file, and knows which logins led to it
+	worker := e.Worker()
+	worker.requestsFile = requestsFile
+	worker.loggingIn = make(map[string]bool, len(e.loggingIn)+1)
+	for login := range e.loggingIn {
+		worker.loggingIn[login] = true
+	}
+	worker.loggingIn[name] = true
+	result, err := worker.ExecuteRequest(request)
+
+	if err != nil {
+		return nil, fmt.Errorf("session %s: login request failed: %w", name, err)
+	}
+	e.mu.Lock()
+	saved, ok := e.sessions[name]
+	e.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("session %s: login request failed with status %s", name, result.Status)
+	}
+	return saved, nil
+}
+
+// startSession records the variables the handler of a # @session request
+// sets. The returned function saves them, with the response's cookies, as
+// the session; it is nil for other requests.
+func (e *Executor) startSession(request *httprequest.Request) (func(*client.Response), error) {
+	name, ttl, ok, err := session.Directive(request)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var stops []func() map[string]interface{}
+	if e.globals != nil {
+		stops = append(stops, e.globals.Record())
+	}
+	if e.envStore != nil {
+		stops = append(stops, e.envStore.Record())
+	}
+
+	return func(resp *client.Response) {
+		values := make(map[string]interface{})
+		for _, stop := range stops {
+			for key, value := range stop() {
+				values[key] = value
+			}
+		}
+
+		if resp.IsError() {
+			logging.Warn("session not saved: login request failed", "session", name, "status", resp.Status)
+			return
+		}
+
+		var cookies []string
+		for _, cookie := range resp.Cookies() {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		if len(cookies) > 0 {
+			values["cookies"] = strings.Join(cookies, "; ")
+		}
+		if len(values) == 0 {
+			logging.Warn("session captured no values; set them with client.global.set() in the response handler", "session", name)
+		}
+
+		saved := &session.Session{Name: name, Values: values, CreatedAt: time.Now()}
+		if ttl > 0 {
+			expires := saved.CreatedAt.Add(ttl)
+			saved.ExpiresAt = &expires
+		}
+		e.mu.Lock()
+		e.sessions[name] = saved
+		e.mu.Unlock()
+
+		if e.sessionStore != nil {
+			if err := e.sessionStore.Save(saved); err != nil {
+				logging.Warn("failed to save session", "session", name, "error", err)
+			}
+		}
+	}, nil
+}
+
+// sessionVariables returns the values of the sessions loaded so far as
+// session.<name>.<key> variables
+func (e *Executor) sessionVariables() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	variables := make(map[string]interface{})
+	for _, saved := range e.sessions {
+		for key, value := range saved.Variables() {
+			variables[key] = value
+		}
+	}
+	return variables
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"postie/pkg/environment"
+	"postie/pkg/httprequest"
+)
+
+// skipReason returns why a request's # @only-env or # @skip-if directive
+// skips it in this run, or "" if it runs
+func (e *Executor) skipReason(request *httprequest.Request) (string, error) {
+	envName := ""
+	if e.environment != nil {
+		envName = e.environment.Name
+	}
+
+	if value, ok := request.Metadata[httprequest.DirectiveOnlyEnv]; ok {
+		allowed := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(allowed) == 0 {
+			return "", fmt.Errorf("@%s needs at least one environment", httprequest.DirectiveOnlyEnv)
+		}
+		if !containsString(allowed, envName) {
+			return fmt.Sprintf("only runs in %s", strings.Join(allowed, ", ")), nil
+		}
+	}
+
+	if condition, ok := request.Metadata[httprequest.DirectiveSkipIf]; ok {
+		// {{env}} is the environment's name unless a variable of that name exists
+		combined := e.getCombinedEnvironment(request)
+		if _, defined := combined.Variables["env"]; !defined {
+			combined.Variables["env"] = envName
+		}
+
+		expanded := environment.NewResolver().ExpandString(condition, combined)
+		skip, err := evalCondition(expanded)
+		if err != nil {
+			return "", fmt.Errorf("invalid @%s %q: %w", httprequest.DirectiveSkipIf, condition, err)
+		}
+		if skip {
+			return fmt.Sprintf("%s (%s)", condition, expanded), nil
+		}
+	}
+
+	return "", nil
+}
+
+// evalCondition evaluates "a == b", "a != b" or a single value, which is
+// true unless it is empty, "false" or "0". Operands may be quoted.
+func evalCondition(condition string) (bool, error) {
+	for _, operator := range []string{"==", "!="} {
+		left, right, found := strings.Cut(condition, operator)
+		if !found {
+			continue
+		}
+		if strings.Contains(right, "==") || strings.Contains(right, "!=") {
+			return false, fmt.Errorf("only one comparison is supported")
+		}
+		equal := conditionOperand(left) == conditionOperand(right)
+		return equal == (operator == "=="), nil
+	}
+
+	switch value := conditionOperand(condition); strings.ToLower(value) {
+	case "", "false", "0":
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// conditionOperand trims an operand and removes its quotes
+func conditionOperand(operand string) string {
+	operand = strings.TrimSpace(operand)
+	if len(operand) >= 2 && (operand[0] == '"' || operand[0] == '\'') && operand[len(operand)-1] == operand[0] {
+		return operand[1 : len(operand)-1]
+	}
+	return operand
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
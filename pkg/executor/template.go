@@ -0,0 +1,283 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"postie/pkg/environment"
+	"postie/pkg/httprequest"
+)
+
+// templateFuncs are the helpers available in # @template bodies. Names and
+// argument order follow the Sprig library used by Helm, so the last
+// argument can be piped in: {{.name | trimPrefix "Mr " | upper}}.
+var templateFuncs = template.FuncMap{
+	// Strings
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       templateJoin,
+	"repeat":     func(count interface{}, s string) string { return strings.Repeat(s, max(templateInt(count), 0)) },
+	"quote":      strconv.Quote,
+
+	// Values
+	"default":      templateDefault,
+	"empty":        templateEmpty,
+	"toJson":       templateJSON(""),
+	"toPrettyJson": templateJSON("  "),
+	"int":          templateInt,
+	"list":         func(values ...interface{}) []interface{} { return values },
+	"dict":         templateDict,
+
+	// Numbers and sequences
+	"add":   func(a, b interface{}) int { return templateInt(a) + templateInt(b) },
+	"sub":   func(a, b interface{}) int { return templateInt(a) - templateInt(b) },
+	"mul":   func(a, b interface{}) int { return templateInt(a) * templateInt(b) },
+	"div":   templateDiv,
+	"mod":   templateMod,
+	"until": func(n interface{}) []int { return templateSeq(0, templateInt(n)-1) },
+	"seq":   func(from, to interface{}) []int { return templateSeq(templateInt(from), templateInt(to)) },
+
+	// Random values and time
+	"randInt": func(min, max interface{}) int { return templateRandInt(templateInt(min), templateInt(max)) },
+	"now":     time.Now,
+}
+
+// renderTemplateBody renders the body of a # @template request as a Go
+// text/template. The data is the request's variables, which can also be
+// called like functions: {{baseUrl}} and {{.baseUrl}} are the same.
+// dynamic holds the functions of the dynamic variables, which win over
+// variables of the same name like the helpers do.
+func renderTemplateBody(request *httprequest.Request, env *environment.ResolvedEnvironment, dynamic template.FuncMap) (string, error) {
+	data := make(map[string]interface{}, len(env.Variables)+1)
+	funcs := make(template.FuncMap, len(env.Variables))
+	for name, value := range env.Variables {
+		data[name] = value
+		_, helper := templateFuncs[name]
+		if _, ok := dynamic[name]; ok {
+			helper = true
+		}
+		if !helper && templateFuncName.MatchString(name) {
+			funcs[name] = func() interface{} { return value }
+		}
+	}
+	if _, ok := data["env"]; !ok && env.Name != "" {
+		data["env"] = env.Name
+		funcs["env"] = func() interface{} { return env.Name }
+	}
+
+	tmpl, err := template.New("body").Funcs(funcs).Funcs(dynamic).Funcs(templateFuncs).Parse(request.Body.Content)
+	if err != nil {
+		return "", fmt.Errorf("@%s: %w", httprequest.DirectiveTemplate, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("@%s: %w", httprequest.DirectiveTemplate, err)
+	}
+	return out.String(), nil
+}
+
+// templateFuncName matches the variable names that can be called as
+// template functions
+var templateFuncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// withTemplateBody returns a copy of a request with its # @template body
+// rendered, or the request itself if it has no template body
+func (e *Executor) withTemplateBody(request *httprequest.Request) (*httprequest.Request, error) {
+	if !request.HasDirective(httprequest.DirectiveTemplate) || request.Body == nil {
+		return request, nil
+	}
+
+	env := e.getCombinedEnvironment(request)
+	env.Name = ""
+	if e.environment != nil {
+		env.Name = e.environment.Name
+	}
+	content, err := renderTemplateBody(request, env, e.dynamicTemplateFuncs())
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := *request
+	body := *request.Body
+	body.Content = content
+	rendered.Body = &body
+	return &rendered, nil
+}
+
+// dynamicTemplateFuncs returns the template functions of the dynamic
+// variables: {{faker "name"}} or {{faker "lorem" 20}} for {{$faker.name}}
+// and {{$faker.lorem 20}}, {{uuid}}, {{timestamp}} (Unix seconds) and
+// {{randomInt}} (0 to 1000). They use the executor's variable providers,
+// so --seed applies to them too.
+func (e *Executor) dynamicTemplateFuncs() template.FuncMap {
+	dynamic := func(name string, args ...interface{}) (string, error) {
+		provider, ok := e.findProvider(name)
+		if !ok {
+			return "", fmt.Errorf("no provider for $%s", name)
+		}
+		words := make([]string, len(args))
+		for i, arg := range args {
+			words[i] = fmt.Sprint(arg)
+		}
+		value, secret, err := provider(name, words)
+		if err != nil {
+			return "", err
+		}
+		if secret && e.redactor != nil {
+			e.redactor.Add(value)
+		}
+		return value, nil
+	}
+
+	return template.FuncMap{
+		"faker": func(name string, args ...interface{}) (string, error) {
+			return dynamic("faker."+name, args...)
+		},
+		"uuid":      func() (string, error) { return dynamic("faker.uuid") },
+		"timestamp": func() int64 { return time.Now().Unix() },
+		"randomInt": func() (int, error) {
+			value, err := dynamic("faker.number", 0, 1000)
+			if err != nil {
+				return 0, err
+			}
+			return strconv.Atoi(value)
+		},
+	}
+}
+
+func templateJoin(sep string, values interface{}) string {
+	switch v := values.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, value := range v {
+			parts[i] = fmt.Sprint(value)
+		}
+		return strings.Join(parts, sep)
+	case []int:
+		parts := make([]string, len(v))
+		for i, value := range v {
+			parts[i] = strconv.Itoa(value)
+		}
+		return strings.Join(parts, sep)
+	}
+	return fmt.Sprint(values)
+}
+
+// templateDefault returns given, or def if given is empty
+func templateDefault(def interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || templateEmpty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+// templateEmpty reports whether a value is missing, zero or empty
+func templateEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func templateJSON(indent string) func(value interface{}) (string, error) {
+	return func(value interface{}) (string, error) {
+		var out bytes.Buffer
+		encoder := json.NewEncoder(&out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", indent)
+		if err := encoder.Encode(value); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(out.String(), "\n"), nil
+	}
+}
+
+// templateInt converts numbers, as variables from JSON environment files
+// are float64, and numeric strings to int. Other values are 0.
+func templateInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	}
+	return 0
+}
+
+func templateDict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict needs key and value pairs")
+	}
+	dict := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		dict[fmt.Sprint(pairs[i])] = pairs[i+1]
+	}
+	return dict, nil
+}
+
+func templateDiv(a, b interface{}) (int, error) {
+	if templateInt(b) == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return templateInt(a) / templateInt(b), nil
+}
+
+func templateMod(a, b interface{}) (int, error) {
+	if templateInt(b) == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return templateInt(a) % templateInt(b), nil
+}
+
+// templateSeq returns the integers from from to to, inclusive
+func templateSeq(from, to int) []int {
+	var seq []int
+	for i := from; i <= to; i++ {
+		seq = append(seq, i)
+	}
+	return seq
+}
+
+// templateRandInt returns a random integer in [min, max)
+func templateRandInt(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + rand.Intn(max-min)
+}
@@ -1,9 +1,11 @@
 package executor
 
 import (
+	"errors"
 	"time"
 
 	"postie/pkg/client"
+	"postie/pkg/httpcache"
 	"postie/pkg/httprequest"
 	"postie/pkg/scripting"
 )
@@ -19,9 +21,21 @@ type ExecutionResult struct {
 	// Error is any error that occurred during execution
 	Error error
 
+	// ErrorKind classifies an error that kept the request from getting a
+	// response, such as timeout or connection-refused ("" if unclassified)
+	ErrorKind string
+
 	// Duration is how long the request took to execute
 	Duration time.Duration
 
+	// Cache says what the HTTP cache did with the request (nil without
+	// --cache)
+	Cache *httpcache.Outcome
+
+	// Timings break Duration down into DNS, connect, TLS, server processing
+	// and content transfer (nil if no response was received)
+	Timings *client.Timings
+
 	// StatusCode is the HTTP status code
 	StatusCode int
 
@@ -33,6 +47,40 @@ type ExecutionResult struct {
 
 	// ResponseFilePath is the path where the response was saved (if enabled)
 	ResponseFilePath string
+
+	// Correlation holds the correlation headers, such as X-Request-Id, the
+	// request was sent with
+	Correlation []httprequest.Header
+
+	// Skipped is true if a # @only-env or # @skip-if directive kept the
+	// request from running; SkipReason says why
+	Skipped    bool
+	SkipReason string
+
+	// Blocked is true if the request wasn't sent because a request it
+	// depends on or a # @setup request failed; Error says which
+	Blocked bool
+
+	// ExpectedStatus holds the statuses a # @expect directive allows (nil
+	// if the request has none)
+	ExpectedStatus httprequest.StatusExpectation
+
+	// ExpectedErrors holds the error kinds a # @expect-error directive
+	// allows (nil if the request has none). Such a request passes only if
+	// it fails with one of them.
+	ExpectedErrors httprequest.ErrorExpectation
+
+	// Pagination says how many pages and items a # @paginate request
+	// fetched (nil if the request has none)
+	Pagination *PaginationResult
+
+	// Poll says how often a # @poll request was sent and whether its
+	// condition held (nil if the request has none)
+	Poll *PollResult
+
+	// Deprecation holds what the response's Deprecation, Sunset and
+	// Warning headers say (nil if it has none)
+	Deprecation *Deprecation
 }
 
 // IsSuccess returns true if the request was successful (2xx status code)
@@ -45,7 +93,53 @@ func (r *ExecutionResult) IsError() bool {
 	return r.StatusCode >= 400
 }
 
-// HasError returns true if there was an execution error
+// HasError returns true if there was an execution error that a
+// # @expect-error directive didn't expect
 func (r *ExecutionResult) HasError() bool {
-	return r.Error != nil
+	return r.Error != nil && !r.ErrorExpected()
+}
+
+// NotSent returns true if an error, such as an unresolved variable or an
+// invalid directive, kept the request from being sent
+func (r *ExecutionResult) NotSent() bool {
+	var notSent *NotSentError
+	return errors.As(r.Error, &notSent)
+}
+
+// ErrorExpected returns true if the request failed with an error of a kind
+// its # @expect-error directive allows
+func (r *ExecutionResult) ErrorExpected() bool {
+	return r.Error != nil && r.ExpectedErrors.Matches(r.ErrorKind)
+}
+
+// StatusFailed returns true if the status differs from the # @expect
+// expectation or, without one, is an error status. A request with
+// # @expect-error fails if it got any response.
+func (r *ExecutionResult) StatusFailed() bool {
+	if r.ExpectedErrors != nil {
+		return r.Response != nil
+	}
+	if r.ExpectedStatus != nil {
+		return !r.ExpectedStatus.Matches(r.StatusCode)
+	}
+	return r.IsError()
+}
+
+// StatusPassed returns true if the status matches the # @expect
+// expectation or, without one, is a 2xx status
+func (r *ExecutionResult) StatusPassed() bool {
+	if r.ExpectedErrors != nil {
+		return r.ErrorExpected()
+	}
+	if r.ExpectedStatus != nil {
+		return r.ExpectedStatus.Matches(r.StatusCode)
+	}
+	return r.IsSuccess()
+}
+
+// Passed returns true if the request was sent without error, got an
+// expected or non-error status, met its # @poll condition and its response
+// handler tests and assertions passed
+func (r *ExecutionResult) Passed() bool {
+	return !r.HasError() && !r.StatusFailed() && (r.Poll == nil || r.Poll.Satisfied) && (r.ScriptResult == nil || r.ScriptResult.IsSuccess())
 }
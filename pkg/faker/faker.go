@@ -0,0 +1,262 @@
+// Package faker generates realistic test data, such as names, email
+// addresses and credit card numbers, for {{$faker.name}} variables. A
+// generator created with the same seed produces the same values.
+package faker
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Faker generates test data. It is safe for concurrent use.
+type Faker struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates a generator. A seed of 0 picks a random seed.
+func New(seed int64) *Faker {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Faker{rng: rand.New(rand.NewSource(seed))}
+}
+
+// generator returns a value, given the words after the generator's name
+type generator func(f *Faker, args []int) string
+
+// generators are the values a Faker can generate, by name. Arguments are
+// integers, such as the number of words of lorem.
+var generators = map[string]generator{
+	"firstName": func(f *Faker, _ []int) string { return f.pick(firstNames) },
+	"lastName":  func(f *Faker, _ []int) string { return f.pick(lastNames) },
+	"name":      func(f *Faker, _ []int) string { return f.pick(firstNames) + " " + f.pick(lastNames) },
+	"username":  (*Faker).username,
+	"email":     func(f *Faker, _ []int) string { return f.username(nil) + "@" + f.pick(domains) },
+	"phone":     func(f *Faker, _ []int) string { return f.digits("+1-###-###-####") },
+	"company":   func(f *Faker, _ []int) string { return f.pick(lastNames) + " " + f.pick(companySuffixes) },
+	"jobTitle": func(f *Faker, _ []int) string {
+		return f.pick(jobLevels) + " " + f.pick(jobAreas) + " " + f.pick(jobRoles)
+	},
+
+	"street":  (*Faker).street,
+	"city":    func(f *Faker, _ []int) string { return f.pick(cities) },
+	"country": func(f *Faker, _ []int) string { return f.pick(countries) },
+	"zip":     func(f *Faker, _ []int) string { return f.digits("#####") },
+	"address": func(f *Faker, _ []int) string { return f.street(nil) + ", " + f.pick(cities) + " " + f.digits("#####") },
+
+	"creditCard": (*Faker).creditCard,
+	"iban":       func(f *Faker, _ []int) string { return "DE" + f.digits("## #### #### #### #### ##") },
+	"currency":   func(f *Faker, _ []int) string { return f.pick(currencies) },
+	"price": func(f *Faker, args []int) string {
+		return fmt.Sprintf("%d.%02d", f.intn(arg(args, 0, 1), arg(args, 1, 1000)), f.intn(0, 99))
+	},
+
+	"uuid":   (*Faker).uuid,
+	"url":    func(f *Faker, _ []int) string { return "https://www." + f.pick(domains) + "/" + f.pick(loremWords) },
+	"domain": func(f *Faker, _ []int) string { return f.pick(domains) },
+	"ipv4": func(f *Faker, _ []int) string {
+		return fmt.Sprintf("%d.%d.%d.%d", f.intn(1, 254), f.intn(0, 255), f.intn(0, 255), f.intn(1, 254))
+	},
+	"password": (*Faker).password,
+
+	"word":      func(f *Faker, _ []int) string { return f.pick(loremWords) },
+	"lorem":     func(f *Faker, args []int) string { return f.words(arg(args, 0, 10)) },
+	"sentence":  func(f *Faker, args []int) string { return f.sentence(arg(args, 0, f.intn(6, 12))) },
+	"paragraph": (*Faker).paragraph,
+
+	"number": func(f *Faker, args []int) string { return strconv.Itoa(f.intn(arg(args, 0, 0), arg(args, 1, 1000))) },
+	"bool":   func(f *Faker, _ []int) string { return strconv.FormatBool(f.intn(0, 1) == 1) },
+	"date":   (*Faker).date,
+	"color":  func(f *Faker, _ []int) string { return fmt.Sprintf("#%06x", f.intn(0, 0xffffff)) },
+}
+
+// Names returns the names of the generators, sorted
+func Names() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate returns a value of the generator called name. args are its
+// arguments, such as "20" for 20 words of lorem.
+func (f *Faker) Generate(name string, args []string) (string, error) {
+	gen, ok := generators[name]
+	if !ok {
+		return "", fmt.Errorf("unknown faker %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+
+	numbers := make([]int, len(args))
+	for i, a := range args {
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("faker %s: invalid argument %q (expected a number)", name, a)
+		}
+		numbers[i] = n
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return gen(f, numbers), nil
+}
+
+// arg returns args[i], or def if it wasn't given
+func arg(args []int, i, def int) int {
+	if i < len(args) {
+		return args[i]
+	}
+	return def
+}
+
+// intn returns a random integer in [min, max]
+func (f *Faker) intn(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + f.rng.Intn(max-min+1)
+}
+
+func (f *Faker) pick(values []string) string {
+	return values[f.rng.Intn(len(values))]
+}
+
+// digits replaces each # in format with a random digit
+func (f *Faker) digits(format string) string {
+	var b strings.Builder
+	for _, c := range format {
+		if c == '#' {
+			b.WriteByte(byte('0' + f.rng.Intn(10)))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (f *Faker) username(_ []int) string {
+	return strings.ToLower(f.pick(firstNames)) + "." + strings.ToLower(f.pick(lastNames)) + strconv.Itoa(f.intn(1, 99))
+}
+
+func (f *Faker) street(_ []int) string {
+	return f.digits("###") + " " + f.pick(lastNames) + " " + f.pick(streetSuffixes)
+}
+
+// creditCard returns a 16-digit Visa test number with a valid Luhn check
+// digit
+func (f *Faker) creditCard(_ []int) string {
+	digits := []byte("4" + f.digits("##############"))
+	return string(digits) + strconv.Itoa(luhnCheckDigit(digits))
+}
+
+// luhnCheckDigit returns the digit that makes number pass the Luhn check
+func luhnCheckDigit(number []byte) int {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if (len(number)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// uuid returns a random (version 4) UUID
+func (f *Faker) uuid(_ []int) string {
+	b := make([]byte, 16)
+	f.rng.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func (f *Faker) password(args []int) string {
+	const chars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!@#$%&*"
+	b := make([]byte, max(arg(args, 0, 16), 1))
+	for i := range b {
+		b[i] = chars[f.rng.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func (f *Faker) words(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = f.pick(loremWords)
+	}
+	return strings.Join(words, " ")
+}
+
+func (f *Faker) sentence(n int) string {
+	s := f.words(max(n, 1))
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+func (f *Faker) paragraph(args []int) string {
+	sentences := make([]string, max(arg(args, 0, 3), 1))
+	for i := range sentences {
+		sentences[i] = f.sentence(f.intn(6, 12))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// date returns a date within the last n days (default 365) as YYYY-MM-DD
+func (f *Faker) date(args []int) string {
+	days := f.intn(0, arg(args, 0, 365))
+	return time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+}
+
+var firstNames = []string{
+	"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+	"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Carlos", "Karen",
+	"Aisha", "Wei", "Priya", "Mateo", "Yuki", "Olga", "Ahmed", "Sofia", "Liam", "Chloe",
+}
+
+var lastNames = []string{
+	"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+	"Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Moore", "Jackson", "Martin", "Lee", "Thompson",
+	"Nguyen", "Kim", "Patel", "Chen", "Schmidt", "Rossi", "Silva", "Novak", "Tanaka", "Okafor",
+}
+
+var domains = []string{"example.com", "example.org", "example.net", "test.com", "mail.test", "demo.io"}
+
+var companySuffixes = []string{"Inc", "LLC", "Group", "Ltd", "and Sons", "Partners", "Labs", "Systems"}
+
+var jobLevels = []string{"Senior", "Junior", "Lead", "Principal", "Chief", "Associate"}
+
+var jobAreas = []string{"Product", "Marketing", "Software", "Data", "Operations", "Security", "Finance"}
+
+var jobRoles = []string{"Engineer", "Manager", "Designer", "Analyst", "Consultant", "Architect"}
+
+var streetSuffixes = []string{"Street", "Avenue", "Road", "Lane", "Drive", "Court", "Way", "Boulevard"}
+
+var cities = []string{
+	"Springfield", "Riverside", "Franklin", "Greenville", "Bristol", "Clinton", "Fairview", "Salem",
+	"Madison", "Georgetown", "Arlington", "Ashland", "Dover", "Oxford", "Milton", "Newport",
+}
+
+var countries = []string{
+	"United States", "Canada", "Mexico", "Brazil", "United Kingdom", "Germany", "France", "Spain",
+	"Italy", "India", "Japan", "Australia", "Nigeria", "South Africa", "Sweden", "Netherlands",
+}
+
+var currencies = []string{"USD", "EUR", "GBP", "JPY", "INR", "AUD", "CAD", "CHF", "BRL", "SEK"}
+
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do",
+	"eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim",
+	"ad", "minim", "veniam", "quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi", "aliquip",
+	"ex", "ea", "commodo", "consequat", "duis", "aute", "irure", "in", "reprehenderit", "voluptate",
+	"velit", "esse", "cillum", "fugiat", "nulla", "pariatur", "excepteur", "sint", "occaecat", "cupidatat",
+}
@@ -0,0 +1,66 @@
+package faker
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSeeded(t *testing.T) {
+	a, b := New(42), New(42)
+	for _, name := range Names() {
+		x, err := a.Generate(name, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		y, _ := b.Generate(name, nil)
+		if x != y {
+			t.Errorf("%s: same seed gave %q and %q", name, x, y)
+		}
+		if x == "" {
+			t.Errorf("%s: empty value", name)
+		}
+	}
+}
+
+func TestGenerateValues(t *testing.T) {
+	f := New(7)
+	patterns := map[string]string{
+		"email":      `^[a-z]+\.[a-z]+\d+@[a-z.]+$`,
+		"creditCard": `^4\d{15}$`,
+		"uuid":       `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		"ipv4":       `^\d+\.\d+\.\d+\.\d+$`,
+		"date":       `^\d{4}-\d{2}-\d{2}$`,
+	}
+	for name, pattern := range patterns {
+		value, _ := f.Generate(name, nil)
+		if !regexp.MustCompile(pattern).MatchString(value) {
+			t.Errorf("%s: %q doesn't match %s", name, value, pattern)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		card, _ := f.Generate("creditCard", nil)
+		if !luhnValid(card) {
+			t.Errorf("credit card %s fails the Luhn check", card)
+		}
+	}
+
+	if lorem, _ := f.Generate("lorem", []string{"20"}); len(strings.Fields(lorem)) != 20 {
+		t.Errorf("expected 20 words, got %q", lorem)
+	}
+	if number, _ := f.Generate("number", []string{"5", "5"}); number != "5" {
+		t.Errorf("number 5 5 = %s", number)
+	}
+
+	if _, err := f.Generate("nope", nil); err == nil || !strings.Contains(err.Error(), `unknown faker "nope"`) {
+		t.Errorf("expected an unknown faker error, got %v", err)
+	}
+	if _, err := f.Generate("lorem", []string{"many"}); err == nil {
+		t.Error("expected an error for a non-numeric argument")
+	}
+}
+
+func luhnValid(number string) bool {
+	return luhnCheckDigit([]byte(number[:len(number)-1])) == int(number[len(number)-1]-'0')
+}
@@ -0,0 +1,270 @@
+// Package fault injects failures into the requests of a run, such as
+// random delays, dropped connections and 5xx responses, to check that
+// handler scripts and retry logic cope with them.
+package fault
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HeaderName marks the responses an Injector made up
+const HeaderName = "X-Postie-Fault"
+
+// Config says which faults to inject and how often
+type Config struct {
+	MinDelay    time.Duration // Shortest delay before each request
+	MaxDelay    time.Duration // Longest delay before each request (0 for none)
+	DropRate    float64       // Share of requests whose connection is dropped, from 0 to 1
+	ErrorRate   float64       // Share of requests answered with ErrorStatus instead of being sent
+	ErrorStatus int           // Status of injected errors (503 if 0)
+	Seed        int64         // Seed for choosing requests and delays (0 for random)
+}
+
+// Enabled returns true if the config injects any fault
+func (c Config) Enabled() bool {
+	return c.MaxDelay > 0 || c.DropRate > 0 || c.ErrorRate > 0
+}
+
+// String describes the faults, such as "delay 0s-2s, drop 10%, error 20% (503)"
+func (c Config) String() string {
+	var faults []string
+	if c.MaxDelay > 0 {
+		faults = append(faults, fmt.Sprintf("delay %s-%s", c.MinDelay, c.MaxDelay))
+	}
+	if c.DropRate > 0 {
+		faults = append(faults, "drop "+percent(c.DropRate))
+	}
+	if c.ErrorRate > 0 {
+		faults = append(faults, fmt.Sprintf("error %s (%d)", percent(c.ErrorRate), c.errorStatus()))
+	}
+	return strings.Join(faults, ", ")
+}
+
+func (c Config) errorStatus() int {
+	if c.ErrorStatus == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return c.ErrorStatus
+}
+
+// Parse adds the faults of a spec to the config. A spec is one or more
+// comma-separated faults: delay=2s or delay=200ms-2s, drop=10%, error=20%
+// or error=20%:502, and seed=42.
+func (c *Config) Parse(spec string) error {
+	for _, fault := range strings.Split(spec, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(fault), "=")
+		var err error
+		switch name {
+		case "delay":
+			err = c.parseDelay(value)
+		case "drop":
+			c.DropRate, err = parseRate(value)
+		case "error":
+			rate, status, hasStatus := strings.Cut(value, ":")
+			if c.ErrorRate, err = parseRate(rate); err == nil && hasStatus {
+				c.ErrorStatus, err = strconv.Atoi(status)
+				if err != nil || c.ErrorStatus < 500 || c.ErrorStatus > 599 {
+					err = fmt.Errorf("status %q isn't a 5xx status", status)
+				}
+			}
+		case "seed":
+			c.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return fmt.Errorf("unknown fault %q (use delay=, drop=, error= or seed=)", fault)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid fault %q: %w", fault, err)
+		}
+	}
+	return nil
+}
+
+// parseDelay parses "2s", up to 2s, or "200ms-2s"
+func (c *Config) parseDelay(value string) error {
+	low, high, isRange := strings.Cut(value, "-")
+	if !isRange {
+		low, high = "0s", value
+	}
+	minDelay, err := time.ParseDuration(low)
+	if err != nil {
+		return err
+	}
+	maxDelay, err := time.ParseDuration(high)
+	if err != nil {
+		return err
+	}
+	if minDelay < 0 || maxDelay <= 0 || minDelay > maxDelay {
+		return fmt.Errorf("use a delay such as 2s or 200ms-2s")
+	}
+	c.MinDelay, c.MaxDelay = minDelay, maxDelay
+	return nil
+}
+
+// parseRate parses "10%" or "0.1"
+func parseRate(value string) (float64, error) {
+	number, isPercent := strings.CutSuffix(value, "%")
+	rate, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("use a rate such as 10%% or 0.1")
+	}
+	if isPercent {
+		rate /= 100
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate must be between 0%% and 100%%")
+	}
+	return rate, nil
+}
+
+func percent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', -1, 64) + "%"
+}
+
+// Counts are the faults an Injector injected
+type Counts struct {
+	Requests int   `json:"requests"`
+	Delayed  int   `json:"delayed"`
+	Delay    int64 `json:"delay_ms"` // Total delay added
+	Dropped  int   `json:"dropped"`
+	Errors   int   `json:"errors"`
+}
+
+// Injector injects the faults of a config into the requests that go
+// through its transports. The transports of several executors can share
+// one, so a run reports one set of counts.
+type Injector struct {
+	config Config
+
+	mu     sync.Mutex
+	random *rand.Rand
+	counts Counts
+}
+
+// NewInjector creates an injector for config
+func NewInjector(config Config) *Injector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{config: config, random: rand.New(rand.NewSource(seed))}
+}
+
+// Config returns the faults the injector injects
+func (i *Injector) Config() Config {
+	return i.config
+}
+
+// Counts returns the faults injected so far
+func (i *Injector) Counts() Counts {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.counts
+}
+
+// Transport wraps base so that its requests get the injector's faults
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{injector: i, base: base}
+}
+
+// plan is the faults chosen for one request
+type plan struct {
+	delay time.Duration
+	drop  bool
+	error bool
+}
+
+// next chooses the faults of the next request
+func (i *Injector) next() plan {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var p plan
+	if i.config.MaxDelay > 0 {
+		p.delay = i.config.MinDelay + time.Duration(i.random.Int63n(int64(i.config.MaxDelay-i.config.MinDelay)+1))
+	}
+	// Draw every rate so a seed picks the same requests whatever is enabled
+	dropDraw, errorDraw := i.random.Float64(), i.random.Float64()
+	p.error = errorDraw < i.config.ErrorRate
+	p.drop = !p.error && dropDraw < i.config.DropRate
+
+	i.counts.Requests++
+	if p.delay > 0 {
+		i.counts.Delayed++
+		i.counts.Delay += p.delay.Milliseconds()
+	}
+	if p.drop {
+		i.counts.Dropped++
+	}
+	if p.error {
+		i.counts.Errors++
+	}
+	return p
+}
+
+// transport intercepts requests on their way to base
+type transport struct {
+	injector *Injector
+	base     http.RoundTripper
+}
+
+// RoundTrip delays the request, answers it with an error status, or sends
+// it and drops the connection before the response is read
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.injector.next()
+
+	if p.delay > 0 {
+		timer := time.NewTimer(p.delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	if p.error {
+		closeBody(req)
+		status := t.injector.config.errorStatus()
+		body := fmt.Sprintf("injected fault: %d %s\n", status, http.StatusText(status))
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}, HeaderName: {"error"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !p.drop {
+		return resp, err
+	}
+
+	// The server got the request, but its response is lost
+	resp.Body.Close()
+	return nil, fmt.Errorf("injected fault: %w", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)})
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestConfigParse(t *testing.T) {
+	var config Config
+	for _, spec := range []string{"delay=200ms-2s", "drop=10%,error=0.25:502", "seed=42"} {
+		if err := config.Parse(spec); err != nil {
+			t.Fatalf("Parse(%q) error: %v", spec, err)
+		}
+	}
+	want := Config{MinDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second, DropRate: 0.1, ErrorRate: 0.25, ErrorStatus: 502, Seed: 42}
+	if config != want {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+	if got := config.String(); got != "delay 200ms-2s, drop 10%, error 25% (502)" {
+		t.Errorf("unexpected String(): %q", got)
+	}
+
+	for _, spec := range []string{"latency=2s", "delay=soon", "delay=2s-1s", "drop=150%", "error=10%:404", "seed=x"} {
+		var config Config
+		if err := config.Parse(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+	if (Config{}).Enabled() || (Config{Seed: 1}).Enabled() {
+		t.Error("Expected a config without faults to be disabled")
+	}
+}
+
+func TestInjectorTransport(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	send := func(config Config, ctx context.Context) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		return NewInjector(config).Transport(nil).RoundTrip(req)
+	}
+
+	resp, err := send(Config{ErrorRate: 1}, context.Background())
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(HeaderName) != "error" {
+		t.Errorf("Expected an injected 503, got %v, %v", resp, err)
+	}
+	if received.Load() != 0 {
+		t.Error("Expected an injected error not to reach the server")
+	}
+
+	if _, err := send(Config{DropRate: 1}, context.Background()); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected a connection reset, got %v", err)
+	}
+	if received.Load() != 1 {
+		t.Error("Expected a dropped request to reach the server")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := send(Config{MinDelay: time.Minute, MaxDelay: time.Minute}, ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delay to end with the request's deadline, got %v", err)
+	}
+}
+
+func TestInjectorSeed(t *testing.T) {
+	config := Config{DropRate: 0.5, MaxDelay: time.Second, Seed: 7}
+	first, second := NewInjector(config), NewInjector(config)
+	for range 20 {
+		if a, b := first.next(), second.next(); a != b {
+			t.Fatalf("Expected the same faults for the same seed, got %+v and %+v", a, b)
+		}
+	}
+	if counts := first.Counts(); counts.Requests != 20 || counts.Dropped == 0 || counts.Dropped == 20 || counts.Errors != 0 {
+		t.Errorf("unexpected counts: %+v", counts)
+	}
+}
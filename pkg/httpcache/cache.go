@@ -0,0 +1,192 @@
+// Package httpcache is a client-side HTTP cache, like a browser's private
+// cache, for testing the caching headers of an API. Responses to GET and
+// HEAD requests are kept under .postie/cache between runs. Fresh ones are
+// served without a request; stale ones are revalidated with If-None-Match
+// and If-Modified-Since, so the API's 304 responses can be checked.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"postie/pkg/atomicfile"
+)
+
+// Dir holds the cache, relative to the project directory
+var Dir = filepath.Join(".postie", "cache")
+
+// Entry is a cached response
+type Entry struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	StatusCode int                 `json:"status_code"`
+	Status     string              `json:"status"`
+	Header     http.Header         `json:"headers"`
+	Body       []byte              `json:"body,omitempty"`
+	Vary       map[string][]string `json:"vary,omitempty"` // Request headers named by Vary, as sent
+	StoredAt   time.Time           `json:"stored_at"`
+}
+
+// ETag returns the entity tag the response was sent with
+func (e *Entry) ETag() string {
+	return e.Header.Get("ETag")
+}
+
+// LastModified returns the Last-Modified date the response was sent with
+func (e *Entry) LastModified() string {
+	return e.Header.Get("Last-Modified")
+}
+
+// Age returns how long ago the response was stored or revalidated
+func (e *Entry) Age(now time.Time) time.Duration {
+	return now.Sub(e.StoredAt)
+}
+
+// Fresh reports whether the response can still be used without asking the
+// server: Cache-Control max-age, or else Expires, hasn't passed and
+// no-cache isn't set. Responses without either are always revalidated.
+func (e *Entry) Fresh(now time.Time) bool {
+	directives := CacheControl(e.Header)
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	if value, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(value)
+		return err == nil && e.Age(now) < time.Duration(seconds)*time.Second
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		return err == nil && now.Before(at)
+	}
+	return false
+}
+
+// Validatable reports whether the response has an ETag or Last-Modified
+// date to revalidate it with
+func (e *Entry) Validatable() bool {
+	return e.ETag() != "" || e.LastModified() != ""
+}
+
+// matchesVary reports whether a request sends the same values of the
+// headers named by Vary as the request that was cached
+func (e *Entry) matchesVary(header http.Header) bool {
+	for name, values := range e.Vary {
+		if strings.Join(header.Values(name), ", ") != strings.Join(values, ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+// CacheControl parses the Cache-Control directives of header. Names are
+// lower case; directives without a value map to "".
+func CacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// storable reports whether a response may be cached
+func storable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if _, ok := CacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	if _, ok := CacheControl(resp.Request.Header)["no-store"]; ok {
+		return false
+	}
+	// "Vary: *" means no later request can be known to match
+	return resp.Header.Get("Vary") != "*"
+}
+
+// Store keeps cached responses in a project directory, one file each
+type Store struct {
+	root string // Project directory
+}
+
+// NewStore returns the cache of a project directory
+func NewStore(dir string) *Store {
+	return &Store{root: dir}
+}
+
+// Dir returns the directory holding the cached responses
+func (s *Store) Dir() string {
+	return filepath.Join(s.root, Dir)
+}
+
+func (s *Store) path(method, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	return filepath.Join(s.Dir(), hex.EncodeToString(sum[:16])+".json")
+}
+
+// Load returns the cached response to a request. It returns nil if there
+// is none.
+func (s *Store) Load(method, url string) (*Entry, error) {
+	data, err := os.ReadFile(s.path(method, url))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cached response: %w", err)
+	}
+	// Two URLs could share a file name only by a hash collision
+	if entry.Method != method || entry.URL != url {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Save writes a cached response, replacing any for the same request
+func (s *Store) Save(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir(), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(s.path(entry.Method, entry.URL), data, 0600); err != nil {
+		return fmt.Errorf("failed to save cached response: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the cached responses to GET and HEAD requests for url
+func (s *Store) Delete(url string) error {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if err := os.Remove(s.path(method, url)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete cached response: %w", err)
+		}
+	}
+	return nil
+}
+
+// Clear removes every cached response
+func (s *Store) Clear() error {
+	if err := os.RemoveAll(s.Dir()); err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return nil
+}
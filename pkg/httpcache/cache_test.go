@@ -0,0 +1,132 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEntryFresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header http.Header
+		age    time.Duration
+		want   bool
+	}{
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=60"}}, 30 * time.Second, true},
+		{"max-age passed", http.Header{"Cache-Control": {"max-age=60"}}, 2 * time.Minute, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0, false},
+		{"expires", http.Header{"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, 0, true},
+		{"expired", http.Header{"Expires": {now.Add(-time.Hour).UTC().Format(http.TimeFormat)}}, 0, false},
+		{"no freshness", http.Header{"ETag": {`"v1"`}}, 0, false},
+	}
+	for _, tt := range tests {
+		entry := &Entry{Header: tt.header, StoredAt: now.Add(-tt.age)}
+		if got := entry.Fresh(now); got != tt.want {
+			t.Errorf("%s: Fresh() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// get sends a GET through transport and returns the status, body and
+// what the cache did
+func get(t *testing.T, transport http.RoundTripper, url string) (int, string, Outcome) {
+	t.Helper()
+	var outcome Outcome
+	req, _ := http.NewRequestWithContext(WithOutcome(context.Background(), &outcome), http.MethodGet, url, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), outcome
+}
+
+func TestTransportRevalidates(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			requests++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("users"))
+	}))
+	defer server.Close()
+
+	transport := NewTransport(NewStore(t.TempDir()), nil)
+
+	status, body, outcome := get(t, transport, server.URL+"/users")
+	if status != http.StatusOK || body != "users" || outcome.Status != StatusMiss || !outcome.Stored {
+		t.Fatalf("first request: %d %q %+v", status, body, outcome)
+	}
+
+	status, _, outcome = get(t, transport, server.URL+"/users")
+	if status != http.StatusNotModified || outcome.Status != StatusRevalidated || outcome.Validator != `If-None-Match: "v1"` {
+		t.Fatalf("second request: %d %+v", status, outcome)
+	}
+
+	// A successful POST to the URL makes the cached response out of date
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/users", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	status, _, outcome = get(t, transport, server.URL+"/users")
+	if status != http.StatusOK || outcome.Status != StatusMiss {
+		t.Fatalf("after POST: %d %+v", status, outcome)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 GET requests to the server, got %d", requests)
+	}
+}
+
+func TestTransportServesFreshResponses(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("config"))
+	}))
+	defer server.Close()
+
+	transport := NewTransport(NewStore(t.TempDir()), nil)
+	get(t, transport, server.URL)
+
+	status, body, outcome := get(t, transport, server.URL)
+	if status != http.StatusOK || body != "config" || outcome.Status != StatusHit {
+		t.Fatalf("expected a hit, got %d %q %+v", status, body, outcome)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request to the server, got %d", requests)
+	}
+
+	// Once max-age has passed the response isn't used without asking
+	transport.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, _, outcome := get(t, transport, server.URL); outcome.Status != StatusMiss {
+		t.Errorf("expected a miss once stale, got %+v", outcome)
+	}
+}
+
+func TestTransportDoesNotStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	transport := NewTransport(NewStore(t.TempDir()), nil)
+	get(t, transport, server.URL)
+	if _, _, outcome := get(t, transport, server.URL); outcome.Status != StatusMiss || outcome.Stored {
+		t.Errorf("expected no-store responses not to be cached, got %+v", outcome)
+	}
+}
@@ -0,0 +1,220 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"postie/pkg/display"
+	"postie/pkg/logging"
+)
+
+// What the cache did with a request
+const (
+	StatusMiss        = "miss"        // Nothing cached; the response was stored if allowed
+	StatusHit         = "hit"         // A fresh response was served without a request
+	StatusRevalidated = "revalidated" // The server answered a conditional request with 304
+	StatusUpdated     = "updated"     // The server answered a conditional request with a new response
+	StatusBypass      = "bypass"      // The request can't use the cache
+)
+
+// Outcome describes what the cache did with a request
+type Outcome struct {
+	Status    string        // One of the Status constants
+	Age       time.Duration // For hits, how old the cached response was
+	Validator string        // For conditional requests, the header sent, e.g. `If-None-Match: "abc"`
+	Stored    bool          // The response was stored for later requests
+}
+
+// String describes the outcome for people, e.g.
+// `revalidated with If-None-Match: "abc"`
+func (o *Outcome) String() string {
+	var b strings.Builder
+	b.WriteString(o.Status)
+	switch o.Status {
+	case StatusHit:
+		fmt.Fprintf(&b, " (age %s)", display.Duration(o.Age))
+	case StatusRevalidated, StatusUpdated:
+		fmt.Fprintf(&b, " with %s", o.Validator)
+	}
+	if o.Stored && o.Status != StatusRevalidated {
+		b.WriteString(", stored")
+	}
+	return b.String()
+}
+
+type outcomeKey struct{}
+
+// WithOutcome returns a context that has the cache record what it did with
+// a request in outcome
+func WithOutcome(ctx context.Context, outcome *Outcome) context.Context {
+	return context.WithValue(ctx, outcomeKey{}, outcome)
+}
+
+// record sets the outcome in a request's context, if it has one
+func record(req *http.Request, outcome Outcome) {
+	if target, ok := req.Context().Value(outcomeKey{}).(*Outcome); ok {
+		*target = outcome
+	}
+}
+
+// Transport is an http.RoundTripper that caches responses in a Store
+type Transport struct {
+	Store *Store
+	Base  http.RoundTripper
+	now   func() time.Time
+}
+
+// NewTransport wraps base (http.DefaultTransport if nil) with a cache
+func NewTransport(store *Store, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Store: store, Base: base, now: time.Now}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := t.Base.RoundTrip(req)
+		// A successful unsafe request makes cached responses for its URL
+		// out of date
+		if err == nil && resp.StatusCode < 400 {
+			if err := t.Store.Delete(url); err != nil {
+				logging.Warn("failed to invalidate cached response", "url", url, "error", err)
+			}
+		}
+		record(req, Outcome{Status: StatusBypass})
+		return resp, err
+	}
+
+	// Requests that are conditional or refuse cached responses themselves
+	// are sent as written
+	requestDirectives := CacheControl(req.Header)
+	_, noCache := requestDirectives["no-cache"]
+	_, noStore := requestDirectives["no-store"]
+	if noStore || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		record(req, Outcome{Status: StatusBypass})
+		return t.Base.RoundTrip(req)
+	}
+
+	entry, err := t.Store.Load(req.Method, url)
+	if err != nil {
+		logging.Warn("ignoring cached response", "url", url, "error", err)
+		entry = nil
+	}
+	if entry != nil && !entry.matchesVary(req.Header) {
+		entry = nil
+	}
+
+	now := t.now()
+	if entry != nil && !noCache && entry.Fresh(now) {
+		record(req, Outcome{Status: StatusHit, Age: entry.Age(now)})
+		return entry.response(req, now), nil
+	}
+
+	outcome := Outcome{Status: StatusMiss}
+	if entry != nil && entry.Validatable() {
+		req = req.Clone(req.Context())
+		if etag := entry.ETag(); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+			outcome.Validator = "If-None-Match: " + etag
+		} else {
+			req.Header.Set("If-Modified-Since", entry.LastModified())
+			outcome.Validator = "If-Modified-Since: " + entry.LastModified()
+		}
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if outcome.Validator != "" {
+		outcome.Status = StatusUpdated
+		if resp.StatusCode == http.StatusNotModified {
+			// The cached response is fresh again, with the new headers
+			outcome.Status = StatusRevalidated
+			for name, values := range resp.Header {
+				entry.Header[name] = values
+			}
+			entry.StoredAt = now
+			outcome.Stored = t.save(entry)
+			record(req, outcome)
+			return resp, nil
+		}
+	}
+
+	if storable(resp) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		outcome.Stored = t.save(&Entry{
+			Method:     req.Method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header.Clone(),
+			Body:       body,
+			Vary:       varyHeaders(resp.Header, req.Header),
+			StoredAt:   now,
+		})
+	}
+	record(req, outcome)
+	return resp, nil
+}
+
+// save stores an entry, reporting whether it was saved. Failures are
+// logged, since the request itself succeeded.
+func (t *Transport) save(entry *Entry) bool {
+	if err := t.Store.Save(entry); err != nil {
+		logging.Warn("failed to cache response", "url", entry.URL, "error", err)
+		return false
+	}
+	return true
+}
+
+// response builds the response to req from a cached entry
+func (e *Entry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.Age(now).Seconds())))
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// varyHeaders returns the request headers that the response's Vary header
+// names, with the values sent
+func varyHeaders(response, request http.Header) map[string][]string {
+	var vary map[string][]string
+	for _, value := range response.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(map[string][]string)
+			}
+			vary[name] = request.Values(name)
+		}
+	}
+	return vary
+}
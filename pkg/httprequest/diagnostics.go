@@ -0,0 +1,279 @@
+package httprequest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"postie/pkg/environment"
+)
+
+// DiagnosticsVersion is the version of the diagnostics JSON schema.
+// It changes only when existing fields change meaning or are removed.
+const DiagnosticsVersion = 1
+
+// Severity is the importance of a diagnostic
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Position is a 1-based line and column in a file
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range is a span in a file. End is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Diagnostic is a problem found in an HTTP request file
+type Diagnostic struct {
+	Range    Range    `json:"range"`
+	Severity Severity `json:"severity"`
+	Source   string   `json:"source"`            // "lexer", "parser" or "validator"
+	Code     string   `json:"code,omitempty"`    // Validated field (URL, Headers, ...)
+	Rule     string   `json:"rule,omitempty"`    // Configurable rule, if any
+	Message  string   `json:"message"`           // Human readable description
+	Request  string   `json:"request,omitempty"` // Name of the request, if any
+}
+
+// DiagnosticsReport is the result of checking a file
+type DiagnosticsReport struct {
+	Version     int          `json:"version"`
+	File        string       `json:"file"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+}
+
+// HasErrors returns true if any diagnostic is an error
+func (r *DiagnosticsReport) HasErrors() bool {
+	return r.Errors > 0
+}
+
+// lexerPositionRegex extracts the position from lexer error messages
+var lexerPositionRegex = regexp.MustCompile(`\s*at line (\d+)(?:, column (\d+))?`)
+
+// CheckOptions configures Check
+type CheckOptions struct {
+	WorkingDir  string                           // Directory for resolving file references
+	Environment *environment.ResolvedEnvironment // Expand variables before validating (optional)
+	Rules       *ValidationConfig                // Rule configuration (optional)
+}
+
+// Check lexes, parses and validates content and returns all diagnostics.
+// Problems found by the normal validator are errors; problems only reported
+// in strict mode are warnings, unless the rule's severity is configured.
+func Check(filename, content string, opts CheckOptions) *DiagnosticsReport {
+	report := &DiagnosticsReport{
+		Version:     DiagnosticsVersion,
+		File:        filename,
+		Diagnostics: []Diagnostic{},
+	}
+	lines := strings.Split(content, "\n")
+
+	requestsFile, err := ParseFile(filename, content)
+	if err != nil {
+		report.add(parseErrorDiagnostic(err, lines))
+		return report
+	}
+
+	validator := NewValidator(false, opts.WorkingDir)
+	strictValidator := NewValidator(true, opts.WorkingDir)
+	for _, v := range []*Validator{validator, strictValidator} {
+		if opts.Environment != nil {
+			v.SetEnvironment(opts.Environment)
+		}
+		v.SetRules(opts.Rules)
+	}
+
+	seen := make(map[string]bool)
+	for _, validationErr := range validator.Validate(requestsFile) {
+		seen[validationKey(validationErr)] = true
+		report.add(validationDiagnostic(validationErr, validationErr.Severity, lines))
+	}
+	for _, validationErr := range strictValidator.Validate(requestsFile) {
+		if seen[validationKey(validationErr)] {
+			continue
+		}
+		severity := SeverityWarning
+		if rule, ok := opts.Rules.Rule(validationErr.Rule); ok && rule.Severity != "" {
+			severity = rule.Severity
+		}
+		report.add(validationDiagnostic(validationErr, severity, lines))
+	}
+
+	sort.SliceStable(report.Diagnostics, func(i, j int) bool {
+		a, b := report.Diagnostics[i].Range.Start, report.Diagnostics[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return report
+}
+
+// add appends a diagnostic and updates the counts
+func (r *DiagnosticsReport) add(d Diagnostic) {
+	r.Diagnostics = append(r.Diagnostics, d)
+	if d.Severity == SeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+// String formats a diagnostic as "file:line:col: severity: message"
+func (d Diagnostic) String(filename string) string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", filename, d.Range.Start.Line, d.Range.Start.Column, d.Severity, d.Message)
+}
+
+// validationKey identifies a validation error across validator runs
+func validationKey(err ValidationError) string {
+	line := 0
+	if err.Request != nil {
+		line = err.Request.LineNumber
+	}
+	return fmt.Sprintf("%d|%s|%s", line, err.Field, err.Message)
+}
+
+// parseErrorDiagnostic converts a lexer or parser error into a diagnostic
+func parseErrorDiagnostic(err error, lines []string) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Message: err.Error()}
+
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		d.Source = "parser"
+		d.Message = parseErr.Message
+		d.Range = lineRange(lines, parseErr.Line, parseErr.Column)
+		return d
+	}
+
+	d.Source = "lexer"
+	d.Message = strings.TrimPrefix(err.Error(), "lexing error: ")
+	line, column := 1, 1
+	if m := lexerPositionRegex.FindStringSubmatch(d.Message); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			column, _ = strconv.Atoi(m[2])
+		}
+		// The position is in the range, so drop it from the message
+		d.Message = strings.TrimSpace(strings.Replace(d.Message, m[0], "", 1))
+	}
+	d.Range = lineRange(lines, line, column)
+	return d
+}
+
+// validationDiagnostic converts a validation error into a diagnostic,
+// narrowing the range to the relevant part of the request where possible
+func validationDiagnostic(err ValidationError, severity Severity, lines []string) Diagnostic {
+	d := Diagnostic{
+		Severity: severity,
+		Source:   "validator",
+		Code:     err.Field,
+		Rule:     err.Rule,
+		Message:  err.Message,
+	}
+
+	if err.Request == nil || err.Request.LineNumber <= 0 {
+		d.Range = lineRange(lines, 1, 1)
+		return d
+	}
+
+	request := err.Request
+	d.Request = request.Name
+	line := request.LineNumber
+	d.Range = lineRange(lines, line, 1)
+
+	requestLine := ""
+	if line <= len(lines) {
+		requestLine = strings.TrimRight(lines[line-1], "\r")
+	}
+
+	// Some errors know exactly where they are
+	if err.Line > 0 {
+		d.Range = lineRange(lines, err.Line, err.Column)
+		return d
+	}
+
+	switch err.Field {
+	case "Method":
+		if request.Method != "" && strings.HasPrefix(strings.TrimSpace(requestLine), request.Method) {
+			start := strings.Index(requestLine, request.Method)
+			d.Range = spanRange(line, start, len(request.Method))
+		}
+	case "URL":
+		if request.URL != nil && request.URL.Raw != "" {
+			if start := strings.Index(requestLine, request.URL.Raw); start >= 0 {
+				d.Range = spanRange(line, start, len(request.URL.Raw))
+			}
+		}
+	case "Headers":
+		// Point at the header named in the message, if any
+		for _, header := range request.Headers {
+			if !strings.Contains(err.Message, header.Name) {
+				continue
+			}
+			if headerLine := findHeaderLine(lines, line, header.Name); headerLine > 0 {
+				d.Range = lineRange(lines, headerLine, 1)
+			}
+			break
+		}
+	}
+
+	return d
+}
+
+// findHeaderLine returns the line of a header within the request starting at requestLine
+func findHeaderLine(lines []string, requestLine int, name string) int {
+	prefix := strings.ToLower(name) + ":"
+	for i := requestLine; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "###") {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(trimmed), prefix) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// lineRange returns the range from column to the end of a line
+func lineRange(lines []string, line, column int) Range {
+	if line < 1 {
+		line = 1
+	}
+	if column < 1 {
+		column = 1
+	}
+	end := column
+	if line <= len(lines) {
+		end = len(strings.TrimRight(lines[line-1], "\r")) + 1
+		if end < column {
+			end = column
+		}
+	}
+	return Range{
+		Start: Position{Line: line, Column: column},
+		End:   Position{Line: line, Column: end},
+	}
+}
+
+// spanRange returns a range covering length bytes from a 0-based offset on a line
+func spanRange(line, offset, length int) Range {
+	return Range{
+		Start: Position{Line: line, Column: offset + 1},
+		End:   Position{Line: line, Column: offset + length + 1},
+	}
+}
@@ -0,0 +1,47 @@
+package httprequest
+
+import (
+	"testing"
+)
+
+func TestCheckValidationDiagnostics(t *testing.T) {
+	content := "### First\n" +
+		"GET http://example.com/a\n" +
+		"Content-Length: abc\n" +
+		"\n" +
+		"### Second\n" +
+		"GET /relative\n"
+
+	report := Check("test.http", content, CheckOptions{WorkingDir: "."})
+
+	if report.Version != DiagnosticsVersion {
+		t.Errorf("Expected version %d, got %d", DiagnosticsVersion, report.Version)
+	}
+	if report.Errors != 0 || report.Warnings != 2 {
+		t.Fatalf("Expected 0 errors and 2 warnings, got %d and %d: %+v", report.Errors, report.Warnings, report.Diagnostics)
+	}
+
+	header := report.Diagnostics[0]
+	if header.Code != "Headers" || header.Range.Start.Line != 3 {
+		t.Errorf("Expected Headers diagnostic on line 3, got %s on line %d", header.Code, header.Range.Start.Line)
+	}
+
+	url := report.Diagnostics[1]
+	if url.Code != "URL" || url.Range.Start.Line != 6 || url.Range.Start.Column != 5 || url.Range.End.Column != 14 {
+		t.Errorf("Expected URL diagnostic at 6:5-6:14, got %+v", url.Range)
+	}
+}
+
+func TestCheckParseError(t *testing.T) {
+	content := "GET http://example.com\n\n> {% client.test(\n"
+
+	report := Check("test.http", content, CheckOptions{WorkingDir: "."})
+
+	if !report.HasErrors() || len(report.Diagnostics) != 1 {
+		t.Fatalf("Expected a single error, got %+v", report.Diagnostics)
+	}
+	d := report.Diagnostics[0]
+	if d.Source != "lexer" || d.Range.Start.Line != 4 || d.Message != "unclosed response handler" {
+		t.Errorf("Expected lexer error on line 4, got %+v", d)
+	}
+}
@@ -7,6 +7,16 @@ import (
 	"unicode"
 )
 
+// lexState tracks which part of a request the lexer is in
+type lexState int
+
+const (
+	stateBetween   lexState = iota // before a request line
+	stateHeaders                   // after the request line, before the blank line
+	stateBody                      // after the blank line following the headers
+	stateMultipart                 // inside a multipart body
+)
+
 // Lexer tokenizes HTTP request files according to the specification
 type Lexer struct {
 	input    string
@@ -15,6 +25,9 @@ type Lexer struct {
 	column   int // current column number
 	start    int // start position of current token
 	tokens   []Token
+	state    lexState
+
+	afterHeader bool // the previous line was a header or a header continuation
 }
 
 // NewLexer creates a new lexer for the given input
@@ -42,6 +55,17 @@ func (l *Lexer) Tokenize() ([]Token, error) {
 
 // nextToken identifies and emits the next token
 func (l *Lexer) nextToken() error {
+	// Request bodies are captured verbatim, so # and // inside them are not comments
+	if l.atLineStart() {
+		continuation := l.isHeaderContinuation()
+		if l.updateLineState() {
+			return l.scanRawBody()
+		}
+		if continuation {
+			return l.scanHeaderContinuation()
+		}
+	}
+
 	l.skipWhitespace()
 
 	if l.position >= len(l.input) {
@@ -84,9 +108,15 @@ func (l *Lexer) nextToken() error {
 	case char == '>' && l.peek() == ' ':
 		return l.scanResponseHandler()
 
+	case char == '?' && l.peek() == '?' && l.atLineStart():
+		return l.scanAssertion()
+
 	case char == '{' && l.peek() == '{':
 		return l.scanVariable()
 
+	case char == '@' && l.atLineStart() && l.isVariableDefinition():
+		return l.scanVariableDefinition()
+
 	case char == ':':
 		l.emit(TokenColon, ":")
 		l.advance()
@@ -110,6 +140,187 @@ func (l *Lexer) nextToken() error {
 	return nil
 }
 
+// updateLineState advances the request state at the start of a line and
+// returns true if the line starts a raw body
+func (l *Lexer) updateLineState() bool {
+	line := strings.TrimLeft(l.lineAt(l.position), " \t\f")
+
+	if strings.HasPrefix(line, "###") {
+		l.state = stateBetween
+		return false
+	}
+
+	switch l.state {
+	case stateBetween:
+		if line != "" && !isCommentLine(line) && !isVariableDefinitionLine(line) {
+			l.state = stateHeaders // request line
+			l.afterHeader = false
+		}
+
+	case stateHeaders:
+		if line == "" {
+			l.state = stateBody
+		} else if isHandlerLine(line) {
+			l.state = stateBetween
+		} else {
+			l.afterHeader = l.isHeaderContinuation() || (strings.Contains(line, ":") && !isCommentLine(line))
+		}
+
+	case stateBody:
+		switch {
+		case line == "":
+			// Extra blank lines before the body
+		case isRequestLine(line):
+			// A new request without a ### separator
+			l.state = stateHeaders
+			l.afterHeader = false
+		case strings.HasPrefix(line, "--"):
+			l.state = stateMultipart
+		case isHandlerLine(line) || strings.HasPrefix(line, "< "):
+			l.state = stateBetween
+		case l.onlyCommentsUntilTerminator():
+			// Trailing comments after a request without a body
+			l.state = stateBetween
+		default:
+			l.state = stateBetween
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHeaderContinuation returns true if the line at the current position is
+// indented and follows a header, continuing its value (obsolete line folding)
+func (l *Lexer) isHeaderContinuation() bool {
+	if l.state != stateHeaders || !l.afterHeader {
+		return false
+	}
+	line := strings.TrimRight(l.lineAt(l.position), "\r")
+	return (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) &&
+		strings.TrimSpace(line) != ""
+}
+
+// scanHeaderContinuation scans an indented header continuation line
+func (l *Lexer) scanHeaderContinuation() error {
+	l.skipWhitespace()
+	start := l.position
+	startLine, startColumn := l.line, l.column
+
+	for l.position < len(l.input) && l.current() != '\n' && l.current() != '\r' {
+		l.advance()
+	}
+
+	l.tokens = append(l.tokens, Token{
+		Type:     TokenHeaderContinuation,
+		Value:    strings.TrimRight(l.input[start:l.position], " \t"),
+		Line:     startLine,
+		Column:   startColumn,
+		Position: start,
+	})
+	return nil
+}
+
+// scanRawBody scans body content verbatim up to the next ### separator,
+// response handler or response reference line
+func (l *Lexer) scanRawBody() error {
+	start := l.position
+	startLine, startColumn := l.line, l.column
+
+	end := l.bodyEnd(start)
+	for l.position < end {
+		l.advance()
+	}
+
+	l.tokens = append(l.tokens, Token{
+		Type:     TokenBodyContent,
+		Value:    l.input[start:end],
+		Line:     startLine,
+		Column:   startColumn,
+		Position: start,
+	})
+	return nil
+}
+
+// bodyEnd returns the end of a body starting at pos. The newline before the
+// terminating line is excluded so it is still emitted as a newline token.
+func (l *Lexer) bodyEnd(pos int) int {
+	lineStart := pos
+	for lineStart < len(l.input) {
+		next := strings.IndexByte(l.input[lineStart:], '\n')
+		if next < 0 {
+			return len(l.input)
+		}
+		lineStart += next + 1
+
+		line := strings.TrimLeft(l.lineAt(lineStart), " \t\f")
+		if isBodyTerminator(line) {
+			end := lineStart - 1
+			if end > pos && l.input[end-1] == '\r' {
+				end--
+			}
+			return end
+		}
+	}
+	return len(l.input)
+}
+
+// onlyCommentsUntilTerminator returns true if every non-blank line from the
+// current position to the end of the body is a comment or variable definition
+func (l *Lexer) onlyCommentsUntilTerminator() bool {
+	body := l.input[l.position:l.bodyEnd(l.position)]
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !isCommentLine(line) && !isVariableDefinitionLine(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineAt returns the text of the line starting at pos, without the line ending
+func (l *Lexer) lineAt(pos int) string {
+	rest := l.input[pos:]
+	if end := strings.IndexAny(rest, "\r\n"); end >= 0 {
+		return rest[:end]
+	}
+	return rest
+}
+
+// isBodyTerminator returns true if a line ends a raw body
+func isBodyTerminator(line string) bool {
+	return strings.HasPrefix(line, "###") || isHandlerLine(line)
+}
+
+// isHandlerLine returns true for response handler ("> {%", "> script.js"),
+// response reference ("<> file") and assertion ("?? ...") lines
+func isHandlerLine(line string) bool {
+	if strings.HasPrefix(line, "<> ") || strings.HasPrefix(line, "> {%") || strings.HasPrefix(line, "?? ") {
+		return true
+	}
+	return strings.HasPrefix(line, "> ") && strings.HasSuffix(strings.TrimSpace(line), ".js")
+}
+
+// isRequestLine returns true for "METHOD target [HTTP/x]" lines
+func isRequestLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 || !ValidHTTPMethods[fields[0]] {
+		return false
+	}
+	return len(fields) == 2 || strings.HasPrefix(fields[2], "HTTP/")
+}
+
+// isCommentLine returns true for lines starting with # or //
+func isCommentLine(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
+}
+
+// isVariableDefinitionLine returns true for @name = value lines
+func isVariableDefinitionLine(line string) bool {
+	_, _, ok := ParseVariableDefinition(line)
+	return ok
+}
+
 // scanComment scans a line comment (# or //)
 func (l *Lexer) scanComment() error {
 	start := l.position
@@ -206,6 +417,7 @@ func (l *Lexer) scanResponseHandler() error {
 
 		// Scan until %}
 		start := l.position
+		startLine, startColumn := l.line, l.column
 		depth := 1
 
 		for l.position < len(l.input) && depth > 0 {
@@ -224,8 +436,14 @@ func (l *Lexer) scanResponseHandler() error {
 			return fmt.Errorf("unclosed response handler at line %d", l.line)
 		}
 
-		script := l.input[start:l.position]
-		l.emit(TokenResponseHandlerCode, script)
+		// The script can span lines, so record where it starts
+		l.tokens = append(l.tokens, Token{
+			Type:     TokenResponseHandlerCode,
+			Value:    l.input[start:l.position],
+			Line:     startLine,
+			Column:   startColumn,
+			Position: start,
+		})
 
 		l.advance() // %
 		l.advance() // }
@@ -245,6 +463,21 @@ func (l *Lexer) scanResponseHandler() error {
 	return nil
 }
 
+// scanAssertion scans ?? subject operator value
+func (l *Lexer) scanAssertion() error {
+	l.advance() // ?
+	l.advance() // ?
+	l.skipWhitespace()
+
+	start := l.position
+	for l.position < len(l.input) && l.current() != '\n' && l.current() != '\r' {
+		l.advance()
+	}
+
+	l.emit(TokenAssertion, strings.TrimSpace(l.input[start:l.position]))
+	return nil
+}
+
 // scanVariable scans {{variableName}}
 func (l *Lexer) scanVariable() error {
 	l.advance() // first {
@@ -254,12 +487,19 @@ func (l *Lexer) scanVariable() error {
 	l.skipWhitespace()
 
 	start := l.position
+	// Dynamic variables such as {{$faker.lorem 20}} take arguments
+	dynamic := l.position < len(l.input) && l.current() == '$'
 	for l.position < len(l.input) {
 		char := l.current()
 		if char == '}' && l.peek() == '}' {
 			break
 		}
-		if !l.isIdentifierChar(char) && !unicode.IsSpace(rune(char)) {
+		if dynamic && char != '\n' && char != '\r' {
+			l.advance()
+			continue
+		}
+		// Dots separate the parts of names such as session.api.token
+		if !l.isIdentifierChar(char) && char != '.' && !unicode.IsSpace(rune(char)) {
 			return fmt.Errorf("invalid character in variable name at line %d, column %d", l.line, l.column)
 		}
 		l.advance()
@@ -283,6 +523,17 @@ func (l *Lexer) scanVariable() error {
 	return nil
 }
 
+// scanVariableDefinition scans an @name = value line
+func (l *Lexer) scanVariableDefinition() error {
+	start := l.position
+	for l.position < len(l.input) && l.current() != '\n' && l.current() != '\r' {
+		l.advance()
+	}
+
+	l.emit(TokenVariableDefinition, strings.TrimSpace(l.input[start:l.position]))
+	return nil
+}
+
 // scanBoundary scans multipart boundary --boundary
 func (l *Lexer) scanBoundary() error {
 	start := l.position
@@ -465,6 +716,24 @@ func (l *Lexer) emit(tokenType TokenType, value string) {
 	l.tokens = append(l.tokens, token)
 }
 
+// atLineStart returns true if only whitespace precedes the current position on its line
+func (l *Lexer) atLineStart() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	return l.tokens[len(l.tokens)-1].Type == TokenNewline
+}
+
+// isVariableDefinition checks if the current line is an @name = value definition
+func (l *Lexer) isVariableDefinition() bool {
+	end := strings.IndexAny(l.input[l.position:], "\r\n")
+	if end < 0 {
+		end = len(l.input) - l.position
+	}
+	_, _, ok := ParseVariableDefinition(l.input[l.position : l.position+end])
+	return ok
+}
+
 // isHTTPMethod checks if current position starts with an HTTP method
 func (l *Lexer) isHTTPMethod() bool {
 	remaining := l.input[l.position:]
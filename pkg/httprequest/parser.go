@@ -2,10 +2,15 @@ package httprequest
 
 import (
 	"fmt"
+	"net/url"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// variableRefRegex matches {{variable}} references
+var variableRefRegex = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
 // Parser parses HTTP request files into structured data
 type Parser struct {
 	tokens   []Token
@@ -43,9 +48,13 @@ func ParseFile(filename string, content string) (*RequestsFile, error) {
 // Parse parses the tokens into a RequestsFile
 func (p *Parser) Parse() (*RequestsFile, error) {
 	var requests []Request
+	var variables []FileVariable
+
+	// Comments before a request are kept for it; they may hold @directives
+	var pendingComments []string
 
 	// Skip initial request separators and whitespace
-	p.skipIgnorable()
+	p.collectComments(&pendingComments)
 
 	var pendingRequestName string
 
@@ -56,7 +65,22 @@ func (p *Parser) Parse() (*RequestsFile, error) {
 			separatorValue := p.current.Value
 			p.advance() // consume the separator
 			pendingRequestName = p.extractRequestName(separatorValue)
-			p.skipIgnorable()
+			pendingComments = nil
+			p.collectComments(&pendingComments)
+			continue
+		}
+
+		// File-level variable definitions (@name = value)
+		if p.check(TokenVariableDefinition) {
+			if name, value, ok := ParseVariableDefinition(p.current.Value); ok {
+				variables = append(variables, FileVariable{
+					Name:       name,
+					Value:      value,
+					LineNumber: p.current.Line,
+				})
+			}
+			p.advance()
+			p.collectComments(&pendingComments)
 			continue
 		}
 
@@ -79,15 +103,34 @@ func (p *Parser) Parse() (*RequestsFile, error) {
 				pendingRequestName = "" // Clear the pending name
 			}
 
+			// Comments and directives (# @name overrides the ### title)
+			applyComments(request, pendingComments)
+
+			// Spec and .proto paths in directives are relative to the request file
+			if spec, ok := request.Metadata[DirectiveOpenAPI]; ok && spec != "" && !strings.HasPrefix(spec, "#") {
+				request.Metadata[DirectiveOpenAPI] = p.GetAbsolutePath(spec)
+			}
+			for _, key := range []string{DirectiveProto, DirectiveProtoResponse} {
+				if file, ok := request.Metadata[key]; ok && file != "" && !strings.HasPrefix(file, "#") {
+					request.Metadata[key] = p.GetAbsolutePath(file)
+				}
+			}
+
 			requests = append(requests, *request)
 		}
 
-		p.skipIgnorable()
+		pendingComments = nil
+		p.collectComments(&pendingComments)
 	}
 
-	return &RequestsFile{
-		Requests: requests,
-	}, nil
+	requestsFile := &RequestsFile{
+		Requests:  requests,
+		Variables: variables,
+	}
+	if p.file != "" {
+		requestsFile.Dir = filepath.Dir(p.file)
+	}
+	return requestsFile, nil
 }
 
 // parseRequest parses a single HTTP request
@@ -112,7 +155,11 @@ func (p *Parser) parseRequest() (*Request, error) {
 		return nil, err
 	}
 
-	p.skipNewlines()
+	// The end of the request line. An empty line straight after it means
+	// there are no headers, and the body follows.
+	if p.check(TokenNewline) {
+		p.advance()
+	}
 
 	// Parse headers
 	if err := p.parseHeaders(request); err != nil {
@@ -131,13 +178,16 @@ func (p *Parser) parseRequest() (*Request, error) {
 		// Parse body content
 		if !p.isAtEnd() && !p.check(TokenRequestSeparator) &&
 			!p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) &&
-			!p.check(TokenMethod) {
+			!p.check(TokenAssertion) && !p.check(TokenMethod) && !p.check(TokenVariableDefinition) {
 			if err := p.parseBody(request); err != nil {
 				return nil, err
 			}
 		}
 	}
 
+	// Assertions may come before or after the response handler
+	p.parseAssertions(request)
+
 	// Parse response handler
 	if p.check(TokenResponseHandlerStart) ||
 		(p.check(TokenText) && strings.HasPrefix(strings.TrimSpace(p.current.Value), ">")) {
@@ -146,6 +196,8 @@ func (p *Parser) parseRequest() (*Request, error) {
 		}
 	}
 
+	p.parseAssertions(request)
+
 	// Parse response reference
 	if p.check(TokenResponseRefStart) {
 		if err := p.parseResponseReference(request); err != nil {
@@ -202,111 +254,105 @@ func (p *Parser) parseRequestLine(request *Request) error {
 
 // parseURL parses a URL string into a URL struct
 func (p *Parser) parseURL(urlStr string) (*URL, error) {
-	url := &URL{
+	result := &URL{
 		Raw: urlStr,
 	}
 
 	// Extract variables
-	url.GetVariables()
+	result.GetVariables()
 
-	// Parse URL components
-	if strings.HasPrefix(urlStr, "http://") || strings.HasPrefix(urlStr, "https://") {
-		// Absolute URL
-		parts := strings.SplitN(urlStr, "://", 2)
-		url.Scheme = parts[0]
+	if urlStr == "*" {
+		// Asterisk form
+		result.Path = "*"
+		return result, nil
+	}
 
-		if len(parts) > 1 {
-			remaining := parts[1]
+	// Only absolute and origin-form URLs have components; anything else
+	// (such as {{baseUrl}}/users) is resolved at execution time
+	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") &&
+		!strings.HasPrefix(urlStr, "/") {
+		return result, nil
+	}
 
-			// Extract host and port
-			pathStart := strings.Index(remaining, "/")
-			queryStart := strings.Index(remaining, "?")
-			fragmentStart := strings.Index(remaining, "#")
+	// Swap {{variables}} for placeholders that net/url accepts anywhere,
+	// including the port, then restore them in each component
+	masked, restore := maskVariables(urlStr)
 
-			hostEnd := len(remaining)
-			if pathStart != -1 && pathStart < hostEnd {
-				hostEnd = pathStart
-			}
-			if queryStart != -1 && queryStart < hostEnd {
-				hostEnd = queryStart
-			}
-			if fragmentStart != -1 && fragmentStart < hostEnd {
-				hostEnd = fragmentStart
-			}
+	parsed, err := url.Parse(masked)
+	if err != nil {
+		// Keep the raw URL; the validator reports malformed URLs
+		return result, nil
+	}
 
-			hostPort := remaining[:hostEnd]
-			if strings.Contains(hostPort, ":") {
-				parts := strings.SplitN(hostPort, ":", 2)
-				url.Host = parts[0]
-				url.Port = parts[1]
-			} else {
-				url.Host = hostPort
-			}
+	result.Scheme = parsed.Scheme
+	result.Host = restore(parsed.Hostname())
+	result.Port = restore(parsed.Port())
+	result.Path = restore(parsed.EscapedPath())
+	result.RawQuery = restore(parsed.RawQuery)
+	result.Fragment = restore(parsed.EscapedFragment())
+	if parsed.User != nil {
+		result.User = restore(parsed.User.String())
+	}
 
-			// Extract path
-			if pathStart != -1 {
-				pathEnd := len(remaining)
-				if queryStart != -1 && queryStart > pathStart {
-					pathEnd = queryStart
-				}
-				if fragmentStart != -1 && fragmentStart > pathStart {
-					pathEnd = fragmentStart
-				}
-				url.Path = remaining[pathStart:pathEnd]
-			}
+	result.Query = parseQuery(parsed.RawQuery, restore)
 
-			// Extract query
-			if queryStart != -1 {
-				queryEnd := len(remaining)
-				if fragmentStart != -1 && fragmentStart > queryStart {
-					queryEnd = fragmentStart
-				}
+	return result, nil
+}
 
-				queryStr := remaining[queryStart+1 : queryEnd]
-				url.Query = p.parseQuery(queryStr)
-			}
+// parseQuery splits a raw query string into ordered parameters. Pairs that
+// can't be unescaped are kept as written.
+func parseQuery(rawQuery string, restore func(string) string) QueryParams {
+	if rawQuery == "" {
+		return nil
+	}
 
-			// Extract fragment
-			if fragmentStart != -1 {
-				url.Fragment = remaining[fragmentStart+1:]
-			}
+	var params QueryParams
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
 		}
-	} else if strings.HasPrefix(urlStr, "/") {
-		// Path-only URL
-		url.Path = urlStr
-	} else if urlStr == "*" {
-		// Asterisk form
-		url.Path = "*"
+		name, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, QueryParam{Name: restore(name), Value: restore(value)})
 	}
-
-	return url, nil
+	return params
 }
 
-// parseQuery parses query string into key-value pairs
-func (p *Parser) parseQuery(queryStr string) map[string]string {
-	query := make(map[string]string)
-
-	if queryStr == "" {
-		return query
+// maskVariables replaces {{variable}} references with numeric placeholders
+// that don't occur elsewhere in s. The returned function undoes the replacement.
+func maskVariables(s string) (string, func(string) string) {
+	// Fixed-width placeholders on a base absent from s can't be confused
+	// with each other or with the URL's own digits
+	base := "918273645"
+	for strings.Contains(s, base) {
+		base += "7"
 	}
 
-	pairs := strings.Split(queryStr, "&")
-	for _, pair := range pairs {
-		if strings.Contains(pair, "=") {
-			parts := strings.SplitN(pair, "=", 2)
-			query[parts[0]] = parts[1]
-		} else {
-			query[pair] = ""
+	var originals []string
+	masked := variableRefRegex.ReplaceAllStringFunc(s, func(match string) string {
+		originals = append(originals, match)
+		return fmt.Sprintf("%s%04d", base, len(originals)-1)
+	})
+
+	restore := func(value string) string {
+		for i, original := range originals {
+			value = strings.ReplaceAll(value, fmt.Sprintf("%s%04d", base, i), original)
 		}
+		return value
 	}
 
-	return query
+	return masked, restore
 }
 
 // parseHeaders parses HTTP headers
 func (p *Parser) parseHeaders(request *Request) error {
 	for !p.isAtEnd() && !p.check(TokenRequestSeparator) &&
-		!p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) {
+		!p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) && !p.check(TokenAssertion) {
 
 		// Check for empty line (end of headers)
 		if p.check(TokenNewline) {
@@ -326,6 +372,7 @@ func (p *Parser) parseHeaders(request *Request) error {
 			if p.check(TokenNewline) {
 				p.advance()
 			}
+			p.parseHeaderContinuations(&request.Headers[len(request.Headers)-1])
 		} else if p.check(TokenHeaderName) {
 			header, err := p.parseHeader()
 			if err != nil {
@@ -337,6 +384,7 @@ func (p *Parser) parseHeaders(request *Request) error {
 			if p.check(TokenNewline) {
 				p.advance()
 			}
+			p.parseHeaderContinuations(&request.Headers[len(request.Headers)-1])
 		} else {
 			// Not a header, stop parsing headers
 			break
@@ -346,6 +394,23 @@ func (p *Parser) parseHeaders(request *Request) error {
 	return nil
 }
 
+// parseHeaderContinuations appends folded continuation lines to a header
+// value, joining them with a single space as RFC 7230 prescribes
+func (p *Parser) parseHeaderContinuations(header *Header) {
+	for p.check(TokenHeaderContinuation) {
+		if header.Value == "" {
+			header.Value = p.current.Value
+		} else {
+			header.Value += " " + p.current.Value
+		}
+		p.advance()
+
+		if p.check(TokenNewline) {
+			p.advance()
+		}
+	}
+}
+
 // parseHeader parses a single HTTP header
 func (p *Parser) parseHeader() (*Header, error) {
 	var name, value string
@@ -494,10 +559,28 @@ func (p *Parser) parseBody(request *Request) error {
 		return p.parseMultipartBody(request)
 	}
 
+	// Raw body captured verbatim by the lexer
+	if p.check(TokenBodyContent) {
+		content := strings.TrimRight(strings.TrimLeft(p.current.Value, "\r\n"), " \t\r\n")
+		p.advance()
+		p.skipNewlines()
+
+		if content != "" {
+			request.Body = &RequestBody{
+				Type:      BodyTypeInline,
+				Content:   content,
+				Variables: p.extractVariables(content),
+			}
+			request.Body.ContentType = request.Body.GetContentType()
+		}
+		return nil
+	}
+
 	// Parse inline body - collect all remaining content until next section
 	var bodyLines []string
 	for !p.isAtEnd() && !p.check(TokenRequestSeparator) &&
-		!p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) {
+		!p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) &&
+		!p.check(TokenAssertion) && !p.check(TokenVariableDefinition) {
 
 		if p.check(TokenText) {
 			bodyLines = append(bodyLines, p.current.Value)
@@ -618,7 +701,7 @@ func (p *Parser) parseResponseHandler(request *Request) error {
 			return p.error("expected response handler code")
 		}
 
-		script := p.current.Value
+		code := p.current
 		p.advance()
 
 		if !p.check(TokenResponseHandlerEnd) {
@@ -627,8 +710,10 @@ func (p *Parser) parseResponseHandler(request *Request) error {
 		p.advance()
 
 		request.ResponseHandler = &ResponseHandler{
-			Type:   HandlerTypeInline,
-			Script: script,
+			Type:       HandlerTypeInline,
+			Script:     code.Value,
+			LineNumber: code.Line,
+			Column:     code.Column,
 		}
 	} else if p.check(TokenText) && strings.HasPrefix(strings.TrimSpace(p.current.Value), ">") {
 		// File handler
@@ -646,6 +731,32 @@ func (p *Parser) parseResponseHandler(request *Request) error {
 	return nil
 }
 
+// parseAssertions parses "?? subject operator value" lines, which may be
+// separated by blank lines
+func (p *Parser) parseAssertions(request *Request) {
+	for {
+		start := p.position
+		for p.check(TokenNewline) {
+			p.advance()
+		}
+		if !p.check(TokenAssertion) {
+			// Keep blank lines before a response handler skipped
+			if !p.check(TokenResponseHandlerStart) && !p.check(TokenResponseRefStart) {
+				p.position, p.current = start, p.tokens[start]
+			}
+			return
+		}
+
+		assertion := ParseAssertion(p.current.Value, p.current.Line)
+		// Schema files are relative to the request file
+		if assertion.Operator == AssertMatchesSchema && assertion.Value != "" {
+			assertion.Value = p.GetAbsolutePath(assertion.Value)
+		}
+		request.Assertions = append(request.Assertions, assertion)
+		p.advance()
+	}
+}
+
 // parseResponseReference parses response references
 func (p *Parser) parseResponseReference(request *Request) error {
 	if !p.check(TokenResponseRefStart) {
@@ -711,6 +822,50 @@ func (p *Parser) skipIgnorable() {
 	}
 }
 
+// collectComments skips whitespace, newlines and comments, appending the comments to comments
+func (p *Parser) collectComments(comments *[]string) {
+	for p.check(TokenWhitespace) || p.check(TokenNewline) || p.check(TokenComment) {
+		if p.check(TokenComment) {
+			*comments = append(*comments, p.current.Value)
+		}
+		p.advance()
+	}
+}
+
+// applyComments stores comments on a request, turning "# @key value" lines into directives
+func applyComments(request *Request, comments []string) {
+	for _, comment := range comments {
+		text := strings.TrimSpace(comment)
+		text = strings.TrimPrefix(text, "//")
+		text = strings.TrimPrefix(text, "#")
+		text = strings.TrimSpace(text)
+
+		key, value, ok := ParseDirective(text)
+		if !ok {
+			if text != "" {
+				request.Comments = append(request.Comments, text)
+			}
+			continue
+		}
+
+		if key == DirectiveName {
+			if value != "" {
+				request.Name = value
+			}
+			continue
+		}
+
+		if request.Metadata == nil {
+			request.Metadata = make(map[string]string)
+		}
+		// Tags add up over several # @tag lines
+		if previous := request.Metadata[key]; key == DirectiveTag && previous != "" {
+			value = previous + ", " + value
+		}
+		request.Metadata[key] = value
+	}
+}
+
 // skipNewlines skips newline tokens
 func (p *Parser) skipNewlines() {
 	for p.check(TokenNewline) {
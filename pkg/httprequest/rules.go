@@ -0,0 +1,189 @@
+package httprequest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Validation rules that can be configured. They are enabled by default in
+// strict mode only.
+const (
+	RuleDuplicateHeader         = "duplicate-header"
+	RuleBodyOnGet               = "body-on-get"
+	RuleMissingHost             = "missing-host"
+	RuleJSSyntax                = "js-syntax"
+	RuleContentLengthFormat     = "content-length-format"
+	RuleContentTypeFormat       = "content-type-format"
+	RuleEmptyBody               = "empty-body"
+	RuleDuplicateMultipartField = "duplicate-multipart-field"
+	RuleMultipartContentType    = "multipart-content-type"
+	RuleResponseRefExists       = "response-ref-exists"
+	RuleUnresolvedVariable      = "unresolved-variable"
+)
+
+// ValidationRules lists every configurable rule with a short description
+var ValidationRules = map[string]string{
+	RuleDuplicateHeader:         "Header repeated that isn't normally repeatable",
+	RuleBodyOnGet:               "GET, HEAD or DELETE request with a body",
+	RuleMissingHost:             "Origin-form URL without a Host header",
+	RuleJSSyntax:                "JavaScript syntax errors in inline response handlers",
+	RuleContentLengthFormat:     "Content-Length that isn't a number",
+	RuleContentTypeFormat:       "Content-Type without a media type",
+	RuleEmptyBody:               "Inline body that is empty",
+	RuleDuplicateMultipartField: "Multipart field name used twice",
+	RuleMultipartContentType:    "Multipart body without a Content-Type header",
+	RuleResponseRefExists:       "Response reference to a file that doesn't exist",
+	RuleUnresolvedVariable:      "URL variable not defined in the environment (with --env)",
+}
+
+// ValidationConfigFile is the rule configuration file, relative to the project directory
+var ValidationConfigFile = filepath.Join(".postie", "validation.json")
+
+// RuleConfig configures a single validation rule
+type RuleConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// UnmarshalJSON accepts either an object or one of "off", "warn" and "error"
+func (r *RuleConfig) UnmarshalJSON(data []byte) error {
+	var level string
+	if err := json.Unmarshal(data, &level); err == nil {
+		return r.setLevel(level)
+	}
+
+	var raw struct {
+		Enabled  *bool  `json:"enabled"`
+		Severity string `json:"severity"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	r.Enabled = raw.Enabled == nil || *raw.Enabled
+	r.Severity = SeverityError
+	if raw.Severity != "" {
+		severity, err := ParseSeverity(raw.Severity)
+		if err != nil {
+			return err
+		}
+		r.Severity = severity
+	}
+	return nil
+}
+
+// setLevel applies an "off", "warn" or "error" shorthand
+func (r *RuleConfig) setLevel(level string) error {
+	if strings.EqualFold(level, "off") {
+		r.Enabled = false
+		r.Severity = SeverityError
+		return nil
+	}
+
+	severity, err := ParseSeverity(level)
+	if err != nil {
+		return err
+	}
+	r.Enabled = true
+	r.Severity = severity
+	return nil
+}
+
+// ParseSeverity parses "error", "warn" or "warning"
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error":
+		return SeverityError, nil
+	case "warn", "warning":
+		return SeverityWarning, nil
+	default:
+		return "", fmt.Errorf("invalid severity: %s (use error or warn)", s)
+	}
+}
+
+// ValidationConfig enables, disables and sets the severity of validation rules
+type ValidationConfig struct {
+	Rules map[string]RuleConfig `json:"rules"`
+}
+
+// Rule returns the configuration for a rule, if it is configured
+func (c *ValidationConfig) Rule(name string) (RuleConfig, bool) {
+	if c == nil {
+		return RuleConfig{}, false
+	}
+	rule, ok := c.Rules[name]
+	return rule, ok
+}
+
+// Validate returns an error if the configuration names an unknown rule
+func (c *ValidationConfig) Validate() error {
+	var unknown []string
+	for name := range c.Rules {
+		if _, ok := ValidationRules[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown validation rule(s): %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// ApplyRuleFlags applies comma-separated rule=level pairs, such as
+// "duplicate-header=off,body-on-get=warn", on top of the configuration
+func (c *ValidationConfig) ApplyRuleFlags(flags string) error {
+	if c.Rules == nil {
+		c.Rules = make(map[string]RuleConfig)
+	}
+
+	for _, pair := range strings.Split(flags, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid rule setting %q (use rule=off|warn|error)", pair)
+		}
+
+		var rule RuleConfig
+		if err := rule.setLevel(strings.TrimSpace(level)); err != nil {
+			return fmt.Errorf("invalid rule setting %q: %w", pair, err)
+		}
+		c.Rules[strings.TrimSpace(name)] = rule
+	}
+
+	return c.Validate()
+}
+
+// LoadValidationConfig reads .postie/validation.json from dir. A missing
+// file results in an empty configuration.
+func LoadValidationConfig(dir string) (*ValidationConfig, error) {
+	config := &ValidationConfig{Rules: make(map[string]RuleConfig)}
+
+	data, err := os.ReadFile(filepath.Join(dir, ValidationConfigFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("failed to read validation config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse validation config: %w", err)
+	}
+	if config.Rules == nil {
+		config.Rules = make(map[string]RuleConfig)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
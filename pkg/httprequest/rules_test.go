@@ -0,0 +1,111 @@
+package httprequest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidationConfigUnmarshal(t *testing.T) {
+	data := `{"rules": {
+		"duplicate-header": "off",
+		"body-on-get": "warn",
+		"missing-host": {"severity": "error"},
+		"js-syntax": {"enabled": false}
+	}}`
+
+	var config ValidationConfig
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+
+	tests := []struct {
+		rule     string
+		enabled  bool
+		severity Severity
+	}{
+		{RuleDuplicateHeader, false, SeverityError},
+		{RuleBodyOnGet, true, SeverityWarning},
+		{RuleMissingHost, true, SeverityError},
+		{RuleJSSyntax, false, SeverityError},
+	}
+	for _, tt := range tests {
+		rule, ok := config.Rule(tt.rule)
+		if !ok {
+			t.Errorf("Expected rule %s to be configured", tt.rule)
+			continue
+		}
+		if rule.Enabled != tt.enabled || rule.Severity != tt.severity {
+			t.Errorf("Rule %s: expected enabled=%v severity=%s, got %+v", tt.rule, tt.enabled, tt.severity, rule)
+		}
+	}
+}
+
+func TestValidatorRules(t *testing.T) {
+	content := "GET https://example.com/a\n" +
+		"X-Id: 1\n" +
+		"X-Id: 2\n" +
+		"\n" +
+		"body\n"
+
+	result, err := ParseFile("test.http", content)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// Strict mode reports both rules as errors by default
+	errors := NewValidator(true, "").Validate(result)
+	if len(errors) != 2 || !HasErrors(errors) {
+		t.Fatalf("Expected 2 errors in strict mode, got %v", errors)
+	}
+
+	config := &ValidationConfig{}
+	if err := config.ApplyRuleFlags("duplicate-header=off, body-on-get=warn"); err != nil {
+		t.Fatalf("ApplyRuleFlags error: %v", err)
+	}
+
+	validator := NewValidator(true, "")
+	validator.SetRules(config)
+	errors = validator.Validate(result)
+	if len(errors) != 1 || errors[0].Rule != RuleBodyOnGet || errors[0].Severity != SeverityWarning {
+		t.Fatalf("Expected a single body-on-get warning, got %+v", errors)
+	}
+	if HasErrors(errors) {
+		t.Error("Warnings alone should not count as errors")
+	}
+
+	formatted := FormatErrors(errors)
+	if !strings.Contains(formatted, "Found 1 validation warning(s)") || !strings.Contains(formatted, "[body-on-get]") {
+		t.Errorf("Unexpected formatting:\n%s", formatted)
+	}
+
+	// Configured rules also run outside strict mode
+	validator = NewValidator(false, "")
+	validator.SetRules(config)
+	if errors := validator.Validate(result); len(errors) != 1 {
+		t.Errorf("Expected configured rule to run in non-strict mode, got %v", errors)
+	}
+}
+
+func TestLoadValidationConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadValidationConfig(dir)
+	if err != nil || len(config.Rules) != 0 {
+		t.Fatalf("Expected empty config without a file, got %+v, %v", config, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".postie"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, ValidationConfigFile)
+	if err := os.WriteFile(path, []byte(`{"rules": {"no-such-rule": "off"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadValidationConfig(dir); err == nil || !strings.Contains(err.Error(), "no-such-rule") {
+		t.Errorf("Expected unknown rule error, got %v", err)
+	}
+}
@@ -3,38 +3,107 @@ package httprequest
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // RequestsFile represents the top-level structure of an HTTP requests file
 type RequestsFile struct {
-	Requests []Request `json:"requests"`
+	Requests  []Request      `json:"requests"`
+	Variables []FileVariable `json:"variables,omitempty"` // @name = value definitions
+	Dir       string         `json:"-"`                   // Directory of the file, for relative "< file" bodies
+}
+
+// FileVariable is a variable defined in the file with "@name = value".
+// It applies to requests that follow the definition.
+type FileVariable struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`                 // Raw value; may reference other {{variables}}
+	LineNumber int    `json:"line_number,omitempty"` // Line number in file
 }
 
 // Request represents a complete HTTP request with all its components
 type Request struct {
-	Name            string           `json:"name,omitempty"`             // From ### comments
-	Method          string           `json:"method"`                     // HTTP method (GET, POST, etc.)
-	URL             *URL             `json:"url"`                        // Request target
-	HTTPVersion     string           `json:"http_version,omitempty"`     // HTTP version (optional)
-	Headers         []Header         `json:"headers,omitempty"`          // Request headers
-	Body            *RequestBody     `json:"body,omitempty"`             // Request body
-	ResponseHandler *ResponseHandler `json:"response_handler,omitempty"` // Response handler script
-	ResponseRef     *ResponseRef     `json:"response_ref,omitempty"`     // Response reference
-	Comments        []string         `json:"comments,omitempty"`         // Associated comments
-	LineNumber      int              `json:"line_number,omitempty"`      // Line number in file
+	Name            string            `json:"name,omitempty"`             // From ### comments
+	Method          string            `json:"method"`                     // HTTP method (GET, POST, etc.)
+	URL             *URL              `json:"url"`                        // Request target
+	HTTPVersion     string            `json:"http_version,omitempty"`     // HTTP version (optional)
+	Headers         []Header          `json:"headers,omitempty"`          // Request headers
+	Body            *RequestBody      `json:"body,omitempty"`             // Request body
+	ResponseHandler *ResponseHandler  `json:"response_handler,omitempty"` // Response handler script
+	ResponseRef     *ResponseRef      `json:"response_ref,omitempty"`     // Response reference
+	Assertions      []Assertion       `json:"assertions,omitempty"`       // Declarative "??" assertions
+	Comments        []string          `json:"comments,omitempty"`         // Associated comments
+	Metadata        map[string]string `json:"metadata,omitempty"`         // Directives from "# @key value" comments
+	LineNumber      int               `json:"line_number,omitempty"`      // Line number in file
 }
 
 // URL represents the request target with all its components
 type URL struct {
-	Raw       string            `json:"raw"`                 // Original URL string
-	Scheme    string            `json:"scheme,omitempty"`    // http, https
-	Host      string            `json:"host,omitempty"`      // hostname or IP
-	Port      string            `json:"port,omitempty"`      // port number
-	Path      string            `json:"path,omitempty"`      // path segments
-	Query     map[string]string `json:"query,omitempty"`     // query parameters
-	Fragment  string            `json:"fragment,omitempty"`  // URL fragment
-	Variables []string          `json:"variables,omitempty"` // Found variables
+	Raw       string      `json:"raw"`                 // Original URL string
+	Scheme    string      `json:"scheme,omitempty"`    // http, https
+	User      string      `json:"user,omitempty"`      // userinfo (user or user:password)
+	Host      string      `json:"host,omitempty"`      // hostname or IP (IPv6 without brackets)
+	Port      string      `json:"port,omitempty"`      // port number
+	Path      string      `json:"path,omitempty"`      // path segments (escaped)
+	RawQuery  string      `json:"raw_query,omitempty"` // query string as written, without '?'
+	Query     QueryParams `json:"query,omitempty"`     // decoded query parameters, in order
+	Fragment  string      `json:"fragment,omitempty"`  // URL fragment
+	Variables []string    `json:"variables,omitempty"` // Found variables
+}
+
+// QueryParam is a single name/value pair from a query string
+type QueryParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// QueryParams is an ordered list of query parameters. Repeated names are
+// kept as separate entries in the order they appear.
+type QueryParams []QueryParam
+
+// Get returns the first value for name, or "" if it isn't present
+func (q QueryParams) Get(name string) string {
+	for _, param := range q {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values for name in order
+func (q QueryParams) Values(name string) []string {
+	var values []string
+	for _, param := range q {
+		if param.Name == name {
+			values = append(values, param.Value)
+		}
+	}
+	return values
+}
+
+// Has returns true if name appears at least once
+func (q QueryParams) Has(name string) bool {
+	for _, param := range q {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode builds a query string from the parameters, preserving order
+func (q QueryParams) Encode() string {
+	parts := make([]string, 0, len(q))
+	for _, param := range q {
+		parts = append(parts, url.QueryEscape(param.Name)+"="+url.QueryEscape(param.Value))
+	}
+	return strings.Join(parts, "&")
 }
 
 // Header represents an HTTP header field
@@ -63,6 +132,9 @@ const (
 	BodyTypeMultipart BodyType = "multipart"
 )
 
+// StdinFile is the file path of a "< -" body, read from standard input
+const StdinFile = "-"
+
 // MultipartField represents a field in multipart form data
 type MultipartField struct {
 	Name      string   `json:"name"`                // Field name
@@ -75,9 +147,11 @@ type MultipartField struct {
 
 // ResponseHandler represents a response handler script
 type ResponseHandler struct {
-	Type     HandlerType `json:"type"`                // inline or file
-	Script   string      `json:"script,omitempty"`    // Inline script content
-	FilePath string      `json:"file_path,omitempty"` // Script file path
+	Type       HandlerType `json:"type"`                  // inline or file
+	Script     string      `json:"script,omitempty"`      // Inline script content
+	FilePath   string      `json:"file_path,omitempty"`   // Script file path
+	LineNumber int         `json:"line_number,omitempty"` // Line where the inline script starts
+	Column     int         `json:"column,omitempty"`      // Column where the inline script starts
 }
 
 // HandlerType represents the type of response handler
@@ -93,6 +167,119 @@ type ResponseRef struct {
 	FilePath string `json:"file_path"` // Path to response file
 }
 
+// Assertion is a declarative check of the response, written as
+// "?? subject operator value", e.g. "?? body matches-schema ./user.json".
+// Header and cookie assertions name the header or cookie after the
+// subject: "?? header X-RateLimit-Remaining > 0".
+type Assertion struct {
+	Subject    string `json:"subject"`
+	Name       string `json:"name,omitempty"` // Header or cookie name
+	Operator   string `json:"operator"`
+	Value      string `json:"value,omitempty"`
+	LineNumber int    `json:"line_number,omitempty"`
+}
+
+// Assertion subjects
+const (
+	AssertBody   = "body"   // The response body
+	AssertHeader = "header" // A response header, by name
+	AssertCookie = "cookie" // A cookie set by the response, by name
+)
+
+// Assertion operators
+const (
+	AssertMatchesSchema = "matches-schema" // ?? body matches-schema <file.json>
+	AssertExists        = "exists"         // ?? cookie session exists
+	AssertNotExists     = "not-exists"     // ?? header X-Debug not-exists
+	AssertEquals        = "=="             // ?? header Content-Type == application/json
+	AssertNotEquals     = "!="
+	AssertGreater       = ">" // Numeric comparisons
+	AssertGreaterEqual  = ">="
+	AssertLess          = "<"
+	AssertLessEqual     = "<="
+	AssertContains      = "contains" // ?? header Cache-Control contains no-store
+	AssertMatches       = "matches"  // ?? header ETag matches ^"[0-9a-f]+"$ (a regular expression)
+)
+
+// valueOperators are the operators that compare a header or cookie value
+var valueOperators = []string{AssertEquals, AssertNotEquals, AssertGreater, AssertGreaterEqual, AssertLess, AssertLessEqual, AssertContains, AssertMatches}
+
+// String returns the assertion as written
+func (a Assertion) String() string {
+	parts := []string{"??"}
+	for _, part := range []string{a.Subject, a.Name, a.Operator, a.Value} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseAssertion splits the text after "??" into subject, name, operator
+// and value. A value in double quotes is unquoted.
+func ParseAssertion(text string, line int) Assertion {
+	assertion := Assertion{LineNumber: line}
+	rest := strings.TrimSpace(text)
+	next := func() string {
+		field, remaining, _ := strings.Cut(rest, " ")
+		rest = strings.TrimSpace(remaining)
+		return field
+	}
+
+	assertion.Subject = next()
+	if assertion.Subject == AssertHeader || assertion.Subject == AssertCookie {
+		assertion.Name = next()
+	}
+	assertion.Operator = next()
+	assertion.Value = rest
+	if len(rest) >= 2 && strings.HasPrefix(rest, `"`) && strings.HasSuffix(rest, `"`) {
+		if unquoted, err := strconv.Unquote(rest); err == nil {
+			assertion.Value = unquoted
+		}
+	}
+	return assertion
+}
+
+// Validate reports an assertion that can't be evaluated, such as an
+// unknown operator or an invalid regular expression
+func (a Assertion) Validate() error {
+	switch a.Subject {
+	case AssertBody:
+		if a.Operator != AssertMatchesSchema {
+			return fmt.Errorf("unsupported body assertion (supported: ?? body %s <file.json>)", AssertMatchesSchema)
+		}
+		if a.Value == "" {
+			return fmt.Errorf("schema file required")
+		}
+		return nil
+	case AssertHeader, AssertCookie:
+	default:
+		return fmt.Errorf("unsupported assertion subject %q (supported: %s, %s, %s)", a.Subject, AssertBody, AssertHeader, AssertCookie)
+	}
+
+	if a.Name == "" {
+		return fmt.Errorf("%s name required, e.g. ?? %s X-Request-Id exists", a.Subject, a.Subject)
+	}
+	switch a.Operator {
+	case AssertExists, AssertNotExists:
+		return nil
+	case AssertGreater, AssertGreaterEqual, AssertLess, AssertLessEqual:
+		if _, err := strconv.ParseFloat(a.Value, 64); err != nil {
+			return fmt.Errorf("%s needs a number, got %q", a.Operator, a.Value)
+		}
+		return nil
+	case AssertMatches:
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return nil
+	case AssertEquals, AssertNotEquals, AssertContains:
+		return nil
+	default:
+		return fmt.Errorf("unsupported operator %q (supported: %s, %s, %s)", a.Operator, AssertExists, AssertNotExists, strings.Join(valueOperators, ", "))
+	}
+}
+
 // Token represents a lexical token
 type Token struct {
 	Type     TokenType `json:"type"`
@@ -122,9 +309,10 @@ const (
 	TokenHTTPVersion // HTTP/1.1
 
 	// Header tokens
-	TokenHeaderName  // header name
-	TokenColon       // :
-	TokenHeaderValue // header value
+	TokenHeaderName         // header name
+	TokenColon              // :
+	TokenHeaderValue        // header value
+	TokenHeaderContinuation // indented line continuing the previous header value
 
 	// Body tokens
 	TokenBodyContent   // inline body content
@@ -144,10 +332,14 @@ const (
 	TokenResponseRefStart // <>
 	TokenResponseRefPath  // file path
 
+	// Assertion tokens
+	TokenAssertion // ?? subject operator value
+
 	// Variable tokens
-	TokenVariableStart // {{
-	TokenVariableEnd   // }}
-	TokenVariableName  // variable name
+	TokenVariableStart      // {{
+	TokenVariableEnd        // }}
+	TokenVariableName       // variable name
+	TokenVariableDefinition // @name = value
 
 	// Content tokens
 	TokenText       // general text content
@@ -182,6 +374,8 @@ func (t TokenType) String() string {
 		return "COLON"
 	case TokenHeaderValue:
 		return "HEADER_VALUE"
+	case TokenHeaderContinuation:
+		return "HEADER_CONTINUATION"
 	case TokenBodyContent:
 		return "BODY_CONTENT"
 	case TokenFileReference:
@@ -202,12 +396,16 @@ func (t TokenType) String() string {
 		return "RESPONSE_REF_START"
 	case TokenResponseRefPath:
 		return "RESPONSE_REF_PATH"
+	case TokenAssertion:
+		return "ASSERTION"
 	case TokenVariableStart:
 		return "VARIABLE_START"
 	case TokenVariableEnd:
 		return "VARIABLE_END"
 	case TokenVariableName:
 		return "VARIABLE_NAME"
+	case TokenVariableDefinition:
+		return "VARIABLE_DEFINITION"
 	case TokenText:
 		return "TEXT"
 	case TokenIdentifier:
@@ -239,9 +437,13 @@ func (e *ParseError) Error() string {
 
 // ValidationError represents a validation error
 type ValidationError struct {
-	Field   string   `json:"field"`
-	Message string   `json:"message"`
-	Request *Request `json:"request,omitempty"`
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+	Request  *Request `json:"request,omitempty"`
+	Rule     string   `json:"rule,omitempty"`     // Configurable rule that reported the error, if any
+	Severity Severity `json:"severity,omitempty"` // error or warning
+	Line     int      `json:"line,omitempty"`     // Position in the file, if more precise than the request
+	Column   int      `json:"column,omitempty"`
 }
 
 // Error implements the error interface
@@ -281,6 +483,30 @@ var ValidHTTPMethods = map[string]bool{
 	"TRACE":   true,
 }
 
+// RepeatableHeaders lists headers that may appear more than once in a
+// request. Each occurrence is sent as a separate header field.
+var RepeatableHeaders = map[string]bool{
+	"accept":          true,
+	"accept-charset":  true,
+	"accept-encoding": true,
+	"accept-language": true,
+	"cache-control":   true,
+	"cookie":          true,
+	"forwarded":       true,
+	"link":            true,
+	"prefer":          true,
+	"set-cookie":      true,
+	"te":              true,
+	"via":             true,
+	"warning":         true,
+	"x-forwarded-for": true,
+}
+
+// IsRepeatableHeader returns true if the header may appear more than once
+func IsRepeatableHeader(name string) bool {
+	return RepeatableHeaders[strings.ToLower(name)]
+}
+
 // ExecutionContext holds context for request execution
 type ExecutionContext struct {
 	Variables       map[string]interface{} `json:"variables"`
@@ -337,6 +563,43 @@ func (u *URL) HasVariables() bool {
 	return len(u.Variables) > 0
 }
 
+// String reassembles the URL from its components. URLs without components
+// (such as {{baseUrl}}/users) are returned as written.
+func (u *URL) String() string {
+	if u.Scheme == "" && u.Host == "" && u.Path == "" {
+		return u.Raw
+	}
+
+	var b strings.Builder
+	if u.Scheme != "" {
+		b.WriteString(u.Scheme)
+		b.WriteString("://")
+	}
+	if u.User != "" {
+		b.WriteString(u.User)
+		b.WriteString("@")
+	}
+	if strings.Contains(u.Host, ":") {
+		b.WriteString("[" + u.Host + "]")
+	} else {
+		b.WriteString(u.Host)
+	}
+	if u.Port != "" {
+		b.WriteString(":")
+		b.WriteString(u.Port)
+	}
+	b.WriteString(u.Path)
+	if u.RawQuery != "" {
+		b.WriteString("?")
+		b.WriteString(u.RawQuery)
+	}
+	if u.Fragment != "" {
+		b.WriteString("#")
+		b.WriteString(u.Fragment)
+	}
+	return b.String()
+}
+
 // GetVariables extracts all variable names from the URL
 func (u *URL) GetVariables() []string {
 	if u.Variables != nil {
@@ -373,6 +636,17 @@ func (r *Request) IsValidMethod() bool {
 	return ValidHTTPMethods[strings.ToUpper(r.Method)]
 }
 
+// HeaderValues returns the values of every header named name, in order
+func (r *Request) HeaderValues(name string) []string {
+	var values []string
+	for _, header := range r.Headers {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
 // GetAllVariables returns all variables used in the request
 func (r *Request) GetAllVariables() []string {
 	var variables []string
@@ -404,3 +678,336 @@ func (r *Request) GetAllVariables() []string {
 
 	return unique
 }
+
+// variableDefinitionRegex matches "@name = value" lines
+var variableDefinitionRegex = regexp.MustCompile(`^@([a-zA-Z_][a-zA-Z0-9_.-]*)\s*=\s*(.*)$`)
+
+// ParseVariableDefinition splits an "@name = value" line into name and value
+func ParseVariableDefinition(line string) (name, value string, ok bool) {
+	match := variableDefinitionRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], strings.TrimSpace(match[2]), true
+}
+
+// VariablesBefore returns the file variables defined before the given line, in
+// definition order. A line of 0 or less returns all variables.
+func (f *RequestsFile) VariablesBefore(line int) []FileVariable {
+	var variables []FileVariable
+	for _, variable := range f.Variables {
+		if line > 0 && variable.LineNumber >= line {
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	return variables
+}
+
+// Request directives set with "# @key [value]" comments before a request
+const (
+	DirectiveName              = "name"               // Stable request ID, used by --request and chaining
+	DirectiveNoLog             = "no-log"             // Don't save the response
+	DirectiveTimeout           = "timeout"            // Overall request timeout
+	DirectiveConnectionTimeout = "connection-timeout" // Timeout for establishing the connection
+	DirectiveOpenAPI           = "openapi"            // OpenAPI spec, and optionally #operationId, to check the response against
+	DirectiveEncode            = "encode"             // Binary encoding of the JSON body: msgpack or protobuf
+	DirectiveProto             = "proto"              // .proto file and #Message the request body is encoded as
+	DirectiveProtoResponse     = "proto-response"     // .proto file and #Message the response body is decoded as
+	DirectiveSession           = "session"            // Save the values the handler sets as a named session, with an optional time to live
+	DirectiveSkipIf            = "skip-if"            // Skip the request when a condition such as {{env}} == "production" holds
+	DirectiveOnlyEnv           = "only-env"           // Run the request only in the listed environments
+	DirectiveDependsOn         = "depends-on"         // Run the named requests first and only send this one if they pass
+	DirectiveSetup             = "setup"              // Run the request before the selected requests of its file
+	DirectiveTeardown          = "teardown"           // Run the request after the selected requests of its file, even if they fail
+	DirectiveIdempotencyKey    = "idempotency-key"    // Send a generated Idempotency-Key header: auto (new key per send) or run (one key per run)
+	DirectiveAuth              = "auth"               // Authenticate with a plugin's auth scheme, followed by its arguments
+	DirectiveTemplate          = "template"           // Render the body as a Go text/template with the variables as data
+	DirectiveExpect            = "expect"             // Fail the request unless its status is one of these codes, such as 201 or 2xx
+	DirectiveTag               = "tag"                // Tags for --select, separated by commas or spaces
+	DirectiveExpectError       = "expect-error"       // Pass only if the request can't be sent, failing with one of these errors, such as timeout
+	DirectivePaginate          = "paginate"           // Follow the pages of a list: link-header, links, cursor:$.next or page-param:page
+	DirectivePoll              = "poll"               // Send the request again until a condition holds: interval=2s timeout=60s until=<expression>
+)
+
+// directiveRegex matches "@key" or "@key value"
+var directiveRegex = regexp.MustCompile(`^@([a-zA-Z][a-zA-Z0-9_-]*)(?:\s+(.*))?$`)
+
+// ParseDirective splits a comment's text ("@key value") into key and value
+func ParseDirective(text string) (key, value string, ok bool) {
+	match := directiveRegex.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], strings.TrimSpace(match[2]), true
+}
+
+// HasDirective returns true if the request has the given # @directive
+func (r *Request) HasDirective(key string) bool {
+	_, exists := r.Metadata[key]
+	return exists
+}
+
+// Tags returns the request's # @tag values
+func (r *Request) Tags() []string {
+	return strings.FieldsFunc(r.Metadata[DirectiveTag], func(c rune) bool { return c == ',' || c == ' ' || c == '\t' })
+}
+
+// DirectiveDuration parses a duration directive such as "# @timeout 30" or
+// "# @connection-timeout 500 ms". Plain numbers are seconds; ms, s and m
+// units are accepted.
+func (r *Request) DirectiveDuration(key string) (time.Duration, bool, error) {
+	value, exists := r.Metadata[key]
+	if !exists {
+		return 0, false, nil
+	}
+	duration, err := parseDirectiveDuration(key, value)
+	return duration, true, err
+}
+
+// parseDirectiveDuration parses the duration value of a directive, such as
+// "30", "500 ms" or "2s"
+func parseDirectiveDuration(key, value string) (time.Duration, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, fmt.Errorf("invalid @%s value: %q", key, value)
+	}
+
+	number, unit := fields[0], "s"
+	if len(fields) == 2 {
+		unit = fields[1]
+	} else if i := strings.IndexFunc(number, func(c rune) bool { return c < '0' || c > '9' }); i > 0 {
+		number, unit = number[:i], number[i:]
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid @%s value: %q", key, value)
+	}
+
+	switch unit {
+	case "ms":
+		return time.Duration(n) * time.Millisecond, nil
+	case "s":
+		return time.Duration(n) * time.Second, nil
+	case "m":
+		return time.Duration(n) * time.Minute, nil
+	default:
+		return 0, fmt.Errorf("invalid @%s unit: %q (use ms, s or m)", key, unit)
+	}
+}
+
+// StatusExpectation is the list of statuses a # @expect directive allows.
+// Each is a code such as 201 or a class such as 2xx.
+type StatusExpectation []string
+
+// statusPattern matches a status code or class
+var statusPattern = regexp.MustCompile(`^[1-5](?:[0-9]{2}|xx)$`)
+
+// ExpectedStatus parses "# @expect 201" or "# @expect 200 204" or
+// "# @expect 2xx". Codes may also be separated by commas.
+func (r *Request) ExpectedStatus() (StatusExpectation, bool, error) {
+	value, exists := r.Metadata[DirectiveExpect]
+	if !exists {
+		return nil, false, nil
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(value), func(c rune) bool { return c == ',' || c == ' ' || c == '\t' })
+	if len(fields) == 0 {
+		return nil, true, fmt.Errorf("@%s requires a status code, such as 201 or 2xx", DirectiveExpect)
+	}
+	for _, field := range fields {
+		if !statusPattern.MatchString(field) {
+			return nil, true, fmt.Errorf("invalid @%s status: %q (use a code such as 201 or a class such as 2xx)", DirectiveExpect, field)
+		}
+	}
+	return StatusExpectation(fields), true, nil
+}
+
+// Matches returns true if code is one of the expected statuses
+func (e StatusExpectation) Matches(code int) bool {
+	status := strconv.Itoa(code)
+	for _, expected := range e {
+		if expected == status || (strings.HasSuffix(expected, "xx") && len(status) == 3 && status[0] == expected[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the expected statuses as written, such as "200 or 204"
+func (e StatusExpectation) String() string {
+	return strings.Join(e, " or ")
+}
+
+// Kinds of errors that keep a request from getting a response, as named by
+// # @expect-error
+const (
+	ErrorTimeout           = "timeout"            // The request or connection timed out
+	ErrorConnectionRefused = "connection-refused" // Nothing is listening on the port
+	ErrorConnectionReset   = "connection-reset"   // The server closed the connection without a response
+	ErrorDNS               = "dns"                // The host name didn't resolve
+	ErrorTLS               = "tls"                // The TLS handshake or certificate check failed
+)
+
+// ErrorKinds lists the error kinds # @expect-error accepts
+var ErrorKinds = []string{ErrorTimeout, ErrorConnectionRefused, ErrorConnectionReset, ErrorDNS, ErrorTLS}
+
+// ErrorExpectation is the list of error kinds a # @expect-error directive
+// allows
+type ErrorExpectation []string
+
+// ExpectedErrors parses "# @expect-error timeout" or "# @expect-error
+// timeout connection-refused". Kinds may also be separated by commas.
+func (r *Request) ExpectedErrors() (ErrorExpectation, bool, error) {
+	value, exists := r.Metadata[DirectiveExpectError]
+	if !exists {
+		return nil, false, nil
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(value), func(c rune) bool { return c == ',' || c == '|' || c == ' ' || c == '\t' })
+	if len(fields) == 0 {
+		return nil, true, fmt.Errorf("@%s requires an error kind: %s", DirectiveExpectError, strings.Join(ErrorKinds, ", "))
+	}
+	for _, field := range fields {
+		if !slices.Contains(ErrorKinds, field) {
+			return nil, true, fmt.Errorf("invalid @%s kind: %q (use %s)", DirectiveExpectError, field, strings.Join(ErrorKinds, ", "))
+		}
+	}
+	return ErrorExpectation(fields), true, nil
+}
+
+// Matches returns true if kind is one of the expected error kinds
+func (e ErrorExpectation) Matches(kind string) bool {
+	return slices.Contains(e, kind)
+}
+
+// String returns the expected error kinds as written, such as "timeout or dns"
+func (e ErrorExpectation) String() string {
+	return strings.Join(e, " or ")
+}
+
+// Ways a # @paginate directive finds the next page
+const (
+	PaginateLinkHeader = "link-header" // The Link header's rel="next" URL
+	PaginateLinks      = "links"       // The next link of a JSON:API (links.next) or HAL (_links.next.href) body
+	PaginateCursor     = "cursor"      // A URL or cursor at a JSON path of the body
+	PaginatePageParam  = "page-param"  // A page number query parameter, counted up
+)
+
+// DefaultMaxPages is how many pages a # @paginate request fetches at most
+// unless it gives max=
+const DefaultMaxPages = 100
+
+// Pagination is how a # @paginate request pages through a list
+type Pagination struct {
+	Mode     string // PaginateLinkHeader, PaginateLinks, PaginateCursor or PaginatePageParam
+	Path     string // JSON path of the cursor, for PaginateCursor
+	Param    string // Query parameter for a cursor or page number
+	Items    string // JSON path of the items of a page ("" to find them)
+	MaxPages int
+}
+
+// Pagination parses "# @paginate link-header", "# @paginate links",
+// "# @paginate cursor:$.meta.next [param]" or "# @paginate
+// page-param:page", each optionally followed by items=$.data and max=20
+func (r *Request) Pagination() (*Pagination, bool, error) {
+	value, exists := r.Metadata[DirectivePaginate]
+	if !exists {
+		return nil, false, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil, true, fmt.Errorf("@%s requires %s, %s, %s:$.next or %s:page", DirectivePaginate, PaginateLinkHeader, PaginateLinks, PaginateCursor, PaginatePageParam)
+	}
+
+	pagination := &Pagination{MaxPages: DefaultMaxPages}
+	mode, arg, _ := strings.Cut(fields[0], ":")
+	switch mode {
+	case PaginateLinkHeader, PaginateLinks:
+	case PaginateCursor:
+		if !strings.HasPrefix(arg, "$") {
+			return nil, true, fmt.Errorf("@%s %s needs the JSON path of the next cursor, e.g. %s:$.next", DirectivePaginate, PaginateCursor, PaginateCursor)
+		}
+		pagination.Path, pagination.Param = arg, "cursor"
+	case PaginatePageParam:
+		if arg == "" {
+			return nil, true, fmt.Errorf("@%s %s needs a query parameter, e.g. %s:page", DirectivePaginate, PaginatePageParam, PaginatePageParam)
+		}
+		pagination.Param = arg
+	default:
+		return nil, true, fmt.Errorf("invalid @%s mode: %q (use %s, %s, %s or %s)", DirectivePaginate, fields[0], PaginateLinkHeader, PaginateLinks, PaginateCursor, PaginatePageParam)
+	}
+	pagination.Mode = mode
+
+	for _, field := range fields[1:] {
+		name, option, hasValue := strings.Cut(field, "=")
+		switch {
+		case name == "items" && strings.HasPrefix(option, "$"):
+			pagination.Items = option
+		case name == "max" && hasValue:
+			n, err := strconv.Atoi(option)
+			if err != nil || n < 1 {
+				return nil, true, fmt.Errorf("invalid @%s max: %q (use a positive number)", DirectivePaginate, option)
+			}
+			pagination.MaxPages = n
+		case !hasValue && mode == PaginateCursor:
+			pagination.Param = field
+		default:
+			return nil, true, fmt.Errorf("invalid @%s option: %q (use items=$.path or max=n)", DirectivePaginate, field)
+		}
+	}
+	return pagination, true, nil
+}
+
+// Defaults for the options a # @poll directive leaves out
+const (
+	DefaultPollInterval = time.Second
+	DefaultPollTimeout  = 30 * time.Second
+)
+
+// Poll is how a # @poll request is sent again until its condition holds
+type Poll struct {
+	Interval time.Duration // Wait between attempts
+	Timeout  time.Duration // Give up once this much time has passed
+	Until    string        // JavaScript expression, such as response.body.status == "READY"
+}
+
+// Poll parses "# @poll interval=2s timeout=60s until=<expression>". The
+// condition is the rest of the line after until=.
+func (r *Request) Poll() (*Poll, bool, error) {
+	value, exists := r.Metadata[DirectivePoll]
+	if !exists {
+		return nil, false, nil
+	}
+
+	options, until, found := strings.Cut(value, "until=")
+	until = strings.TrimSpace(until)
+	if !found || until == "" {
+		return nil, true, fmt.Errorf("@%s requires until=<condition>, such as until=response.body.status == \"READY\"", DirectivePoll)
+	}
+
+	poll := &Poll{Interval: DefaultPollInterval, Timeout: DefaultPollTimeout, Until: until}
+	for _, field := range strings.Fields(options) {
+		name, option, _ := strings.Cut(field, "=")
+		var target *time.Duration
+		switch name {
+		case "interval":
+			target = &poll.Interval
+		case "timeout":
+			target = &poll.Timeout
+		default:
+			return nil, true, fmt.Errorf("invalid @%s option: %q (use interval=, timeout= or until=)", DirectivePoll, field)
+		}
+		duration, err := parseDirectiveDuration(DirectivePoll+" "+name, option)
+		if err != nil {
+			return nil, true, err
+		}
+		if duration <= 0 {
+			return nil, true, fmt.Errorf("invalid @%s %s: %q (use a positive duration)", DirectivePoll, name, option)
+		}
+		*target = duration
+	}
+	return poll, true, nil
+}
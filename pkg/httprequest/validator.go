@@ -1,12 +1,19 @@
 package httprequest
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/dop251/goja"
+	jsparser "github.com/dop251/goja/parser"
+
+	"postie/pkg/environment"
 )
 
 // Validator validates parsed HTTP requests according to the specification
@@ -14,6 +21,10 @@ type Validator struct {
 	strict     bool   // Enable strict validation mode
 	workingDir string // Working directory for file path resolution
 	errors     []ValidationError
+
+	env          *environment.ResolvedEnvironment // Expand variables before validating (optional)
+	requestsFile *RequestsFile                    // File being validated, for file variables
+	rules        *ValidationConfig                // Per-rule overrides (optional)
 }
 
 // NewValidator creates a new validator
@@ -29,9 +40,22 @@ func NewValidator(strict bool, workingDir string) *Validator {
 	}
 }
 
+// SetEnvironment makes the validator expand variables from env (and file
+// variables) before checking URLs, headers and file paths
+func (v *Validator) SetEnvironment(env *environment.ResolvedEnvironment) {
+	v.env = env
+}
+
+// SetRules applies a rule configuration. Configured rules are checked even
+// outside strict mode unless disabled, and report with their configured severity.
+func (v *Validator) SetRules(config *ValidationConfig) {
+	v.rules = config
+}
+
 // Validate validates a RequestsFile
 func (v *Validator) Validate(requestsFile *RequestsFile) []ValidationError {
 	v.errors = make([]ValidationError, 0)
+	v.requestsFile = requestsFile
 
 	if requestsFile == nil {
 		v.addError("", "RequestsFile is nil", nil)
@@ -45,6 +69,9 @@ func (v *Validator) Validate(requestsFile *RequestsFile) []ValidationError {
 
 	// Validate each request
 	for i, request := range requestsFile.Requests {
+		if v.env != nil {
+			request = v.expandRequest(request)
+		}
 		v.validateRequest(&request, i)
 	}
 
@@ -76,12 +103,20 @@ func (v *Validator) validateRequest(request *Request, index int) {
 	// Validate body
 	v.validateBody(request)
 
+	// Content-Length is only comparable once variables are expanded
+	if v.env != nil {
+		v.validateContentLength(request)
+	}
+
 	// Validate response handler
 	v.validateResponseHandler(request)
 
 	// Validate response reference
 	v.validateResponseReference(request)
 
+	// Validate "??" assertions
+	v.validateAssertions(request)
+
 	// Validate variables
 	v.validateVariables(request)
 }
@@ -115,28 +150,43 @@ func (v *Validator) validateURL(request *Request) {
 		return
 	}
 
-	// Skip validation for template variables and asterisk form
-	if request.URL.Raw == "*" || strings.Contains(request.URL.Raw, "{{") {
+	// Skip validation for asterisk form
+	if request.URL.Raw == "*" {
+		return
+	}
+
+	// URLs with variables can only be checked once they are expanded
+	if strings.Contains(request.URL.Raw, "{{") {
+		if v.env != nil && v.ruleEnabled(RuleUnresolvedVariable) {
+			v.addRuleError(RuleUnresolvedVariable, "URL", fmt.Sprintf("Unresolved variables in URL: %s", strings.Join(variableRefRegex.FindAllString(request.URL.Raw, -1), ", ")), request)
+		}
 		return
 	}
 
 	// Validate URL format
 	if strings.HasPrefix(request.URL.Raw, "http://") || strings.HasPrefix(request.URL.Raw, "https://") {
 		// Absolute URL
-		_, err := url.Parse(request.URL.Raw)
+		parsed, err := url.Parse(request.URL.Raw)
 		if err != nil {
 			v.addError("URL", fmt.Sprintf("Invalid URL format: %s", err.Error()), request)
+		} else if parsed.Host == "" {
+			v.addError("URL", "URL has no host", request)
 		}
 	} else if strings.HasPrefix(request.URL.Raw, "/") {
-		// Origin form - path only
-		if v.strict && !v.hasHostHeader(request) {
-			v.addError("URL", "Origin-form URL requires Host header", request)
+		// Origin form - path only, unless the environment sets a base URL
+		if v.ruleEnabled(RuleMissingHost) && !v.hasHostHeader(request) && !v.hasBaseURL() {
+			v.addRuleError(RuleMissingHost, "URL", "Origin-form URL requires Host header", request)
 		}
 	} else if request.URL.Raw != "*" {
 		v.addError("URL", "URL must be absolute, origin-form, or asterisk-form", request)
 	}
 }
 
+// hasBaseURL reports whether the environment prefixes origin-form URLs
+func (v *Validator) hasBaseURL() bool {
+	return v.env != nil && v.env.Defaults.BaseURL != ""
+}
+
 // validateHTTPVersion validates the HTTP version
 func (v *Validator) validateHTTPVersion(request *Request) {
 	if request.HTTPVersion == "" {
@@ -162,9 +212,9 @@ func (v *Validator) validateHeaders(request *Request) {
 
 		// Check for duplicate headers (case-insensitive)
 		lowerName := strings.ToLower(header.Name)
-		if headerNames[lowerName] {
-			if v.strict {
-				v.addError("Headers", fmt.Sprintf("Duplicate header: %s", header.Name), request)
+		if headerNames[lowerName] && !IsRepeatableHeader(header.Name) {
+			if v.ruleEnabled(RuleDuplicateHeader) {
+				v.addRuleError(RuleDuplicateHeader, "Headers", fmt.Sprintf("Duplicate header: %s", header.Name), request)
 			}
 		}
 		headerNames[lowerName] = true
@@ -188,15 +238,15 @@ func (v *Validator) validateBody(request *Request) {
 	switch request.Body.Type {
 	case BodyTypeInline:
 		// For inline body, content should not be empty
-		if request.Body.Content == "" && v.strict {
-			v.addError("Body", "Inline body content is empty", request)
+		if request.Body.Content == "" && v.ruleEnabled(RuleEmptyBody) {
+			v.addRuleError(RuleEmptyBody, "Body", "Inline body content is empty", request)
 		}
 
 	case BodyTypeFile:
 		// Validate file reference
 		if request.Body.FilePath == "" {
 			v.addError("Body", "File path is required for file body type", request)
-		} else {
+		} else if request.Body.FilePath != StdinFile {
 			v.validateFilePath(request.Body.FilePath, "Body.FilePath", request)
 		}
 
@@ -209,9 +259,9 @@ func (v *Validator) validateBody(request *Request) {
 	}
 
 	// Validate body for methods that shouldn't have body
-	if v.strict && (request.Method == "GET" || request.Method == "HEAD" || request.Method == "DELETE") {
+	if v.ruleEnabled(RuleBodyOnGet) && (request.Method == "GET" || request.Method == "HEAD" || request.Method == "DELETE") {
 		if request.Body != nil && request.Body.Content != "" {
-			v.addError("Body", fmt.Sprintf("%s requests should not have a body", request.Method), request)
+			v.addRuleError(RuleBodyOnGet, "Body", fmt.Sprintf("%s requests should not have a body", request.Method), request)
 		}
 	}
 }
@@ -243,8 +293,8 @@ func (v *Validator) validateMultipartBody(body *RequestBody, request *Request) {
 		}
 	}
 
-	if v.strict && !hasContentType {
-		v.addError("Body", "Multipart body requires Content-Type header", request)
+	if v.ruleEnabled(RuleMultipartContentType) && !hasContentType {
+		v.addRuleError(RuleMultipartContentType, "Body", "Multipart body requires Content-Type header", request)
 	}
 
 	// Validate each multipart field
@@ -252,8 +302,8 @@ func (v *Validator) validateMultipartBody(body *RequestBody, request *Request) {
 	for i, field := range body.Multipart {
 		if field.Name == "" {
 			v.addError("Body", fmt.Sprintf("Multipart field at index %d has no name", i), request)
-		} else if fieldNames[field.Name] && v.strict {
-			v.addError("Body", fmt.Sprintf("Duplicate multipart field name: %s", field.Name), request)
+		} else if fieldNames[field.Name] && v.ruleEnabled(RuleDuplicateMultipartField) {
+			v.addRuleError(RuleDuplicateMultipartField, "Body", fmt.Sprintf("Duplicate multipart field name: %s", field.Name), request)
 		}
 		fieldNames[field.Name] = true
 
@@ -281,9 +331,9 @@ func (v *Validator) validateResponseHandler(request *Request) {
 			v.addError("ResponseHandler", "Inline response handler script is empty", request)
 		}
 
-		// Basic JavaScript syntax validation (if strict)
-		if v.strict {
-			v.validateJavaScript(request.ResponseHandler.Script, request)
+		// Basic JavaScript syntax validation
+		if v.ruleEnabled(RuleJSSyntax) {
+			v.validateJavaScript(request.ResponseHandler, request)
 		}
 
 	case HandlerTypeFile:
@@ -308,9 +358,27 @@ func (v *Validator) validateResponseReference(request *Request) {
 		v.addError("ResponseRef", "Response reference file path is required", request)
 	}
 
-	// In strict mode, validate that referenced response file exists
-	if v.strict {
-		v.validateFilePath(request.ResponseRef.FilePath, "ResponseRef.FilePath", request)
+	// Validate that the referenced response file exists
+	if v.ruleEnabled(RuleResponseRefExists) {
+		if message := v.checkFilePath(request.ResponseRef.FilePath); message != "" {
+			v.addRuleError(RuleResponseRefExists, "ResponseRef.FilePath", message, request)
+		}
+	}
+}
+
+// validateAssertions reports "??" assertions that can't be evaluated
+func (v *Validator) validateAssertions(request *Request) {
+	for _, assertion := range request.Assertions {
+		if err := assertion.Validate(); err != nil {
+			v.errors = append(v.errors, ValidationError{
+				Field:    "Assertions",
+				Message:  fmt.Sprintf("%s: %v", assertion, err),
+				Request:  request,
+				Severity: SeverityError,
+				Line:     assertion.LineNumber,
+				Column:   1,
+			})
+		}
 	}
 }
 
@@ -363,25 +431,137 @@ func (v *Validator) validateSpecificHeader(header Header, request *Request) {
 	switch strings.ToLower(header.Name) {
 	case "content-length":
 		// Content-Length should be a number
-		if v.strict && header.Value != "" && !regexp.MustCompile(`^\d+$`).MatchString(header.Value) {
-			v.addError("Headers", "Content-Length must be a number", request)
+		if v.ruleEnabled(RuleContentLengthFormat) && header.Value != "" && !regexp.MustCompile(`^\d+$`).MatchString(header.Value) {
+			v.addRuleError(RuleContentLengthFormat, "Headers", "Content-Length must be a number", request)
 		}
 
 	case "content-type":
 		// Basic content-type validation
-		if v.strict && header.Value != "" {
+		if v.ruleEnabled(RuleContentTypeFormat) && header.Value != "" {
 			// Should contain at least a media type
 			if !strings.Contains(header.Value, "/") {
-				v.addError("Headers", "Invalid Content-Type format", request)
+				v.addRuleError(RuleContentTypeFormat, "Headers", "Invalid Content-Type format", request)
 			}
 		}
 	}
 }
 
+// validateContentLength checks an explicit Content-Length against the inline body
+func (v *Validator) validateContentLength(request *Request) {
+	values := request.HeaderValues("Content-Length")
+	if len(values) == 0 {
+		return
+	}
+
+	length, err := strconv.Atoi(values[0])
+	if err != nil {
+		return // Reported by validateSpecificHeader in strict mode
+	}
+
+	actual := 0
+	if request.Body != nil && request.Body.Type == BodyTypeInline {
+		actual = len(request.Body.Content)
+	} else if request.Body != nil {
+		return // File and multipart bodies are sized at execution time
+	}
+
+	if length != actual {
+		v.addError("Headers", fmt.Sprintf("Content-Length %d does not match body length %d", length, actual), request)
+	}
+}
+
+// expandRequest returns a copy of request with variables expanded from the
+// validator's environment and the file variables defined above it
+func (v *Validator) expandRequest(request Request) Request {
+	resolver := environment.NewResolver()
+	env := v.environmentFor(&request)
+	expand := func(s string) string {
+		return resolver.ExpandString(s, env)
+	}
+
+	if request.URL != nil {
+		expandedURL, _ := (&Parser{}).parseURL(expand(request.URL.Raw))
+		request.URL = expandedURL
+	}
+
+	if len(request.Headers) > 0 {
+		headers := make([]Header, len(request.Headers))
+		for i, header := range request.Headers {
+			headers[i] = Header{Name: header.Name, Value: expand(header.Value)}
+		}
+		request.Headers = headers
+	}
+
+	if request.Body != nil {
+		body := *request.Body
+		body.Content = expand(body.Content)
+		body.FilePath = expand(body.FilePath)
+		if len(body.Multipart) > 0 {
+			fields := make([]MultipartField, len(body.Multipart))
+			for i, field := range body.Multipart {
+				field.Content = expand(field.Content)
+				field.FilePath = expand(field.FilePath)
+				fields[i] = field
+			}
+			body.Multipart = fields
+		}
+		request.Body = &body
+	}
+
+	if request.ResponseHandler != nil {
+		handler := *request.ResponseHandler
+		handler.FilePath = expand(handler.FilePath)
+		request.ResponseHandler = &handler
+	}
+
+	if request.ResponseRef != nil {
+		request.ResponseRef = &ResponseRef{FilePath: expand(request.ResponseRef.FilePath)}
+	}
+
+	return request
+}
+
+// environmentFor combines the file variables defined above request with the
+// validator's environment, which takes precedence
+func (v *Validator) environmentFor(request *Request) *environment.ResolvedEnvironment {
+	resolver := environment.NewResolver()
+
+	vars := make(map[string]interface{})
+	if v.requestsFile != nil {
+		for _, variable := range v.requestsFile.VariablesBefore(request.LineNumber) {
+			scope := make(map[string]interface{}, len(vars)+len(v.env.Variables))
+			for k, val := range vars {
+				scope[k] = val
+			}
+			for k, val := range v.env.Variables {
+				scope[k] = val
+			}
+			vars[variable.Name] = resolver.ExpandString(variable.Value, &environment.ResolvedEnvironment{Variables: scope})
+		}
+	}
+
+	for k, val := range v.env.Variables {
+		vars[k] = val
+	}
+
+	return &environment.ResolvedEnvironment{
+		Name:      v.env.Name,
+		Variables: vars,
+		Source:    make(map[string]string),
+	}
+}
+
 // validateFilePath validates that a file path exists and is readable
 func (v *Validator) validateFilePath(path, field string, request *Request) {
+	if message := v.checkFilePath(path); message != "" {
+		v.addError(field, message, request)
+	}
+}
+
+// checkFilePath returns a message if path doesn't exist or can't be accessed
+func (v *Validator) checkFilePath(path string) string {
 	if path == "" {
-		return
+		return ""
 	}
 
 	// Resolve relative paths
@@ -391,43 +571,63 @@ func (v *Validator) validateFilePath(path, field string, request *Request) {
 
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		v.addError(field, fmt.Sprintf("File not found: %s", path), request)
+		return fmt.Sprintf("File not found: %s", path)
 	} else if err != nil {
-		v.addError(field, fmt.Sprintf("Cannot access file: %s", err.Error()), request)
+		return fmt.Sprintf("Cannot access file: %s", err.Error())
 	}
+	return ""
 }
 
-// validateJavaScript performs basic JavaScript syntax validation
-func (v *Validator) validateJavaScript(script string, request *Request) {
-	// Basic checks for common JavaScript syntax errors
-	script = strings.TrimSpace(script)
-
-	if script == "" {
+// validateJavaScript compiles an inline response handler and reports syntax
+// errors at their position in the .http file
+func (v *Validator) validateJavaScript(handler *ResponseHandler, request *Request) {
+	if strings.TrimSpace(handler.Script) == "" {
 		return
 	}
 
-	// Check for unmatched braces
-	braceCount := 0
-	for _, char := range script {
-		if char == '{' {
-			braceCount++
-		} else if char == '}' {
-			braceCount--
+	// The parser reports every syntax error with its position; the compiler
+	// catches the few it doesn't (such as invalid assignment targets)
+	var line, column int
+	var message string
+	if _, err := jsparser.ParseFile(nil, "", handler.Script, 0); err != nil {
+		var errList jsparser.ErrorList
+		if errors.As(err, &errList) && len(errList) > 0 {
+			line, column, message = errList[0].Position.Line, errList[0].Position.Column, errList[0].Message
+		} else {
+			message = err.Error()
 		}
+	} else if _, err := goja.Compile("", handler.Script, false); err != nil {
+		var syntaxErr *goja.CompilerSyntaxError
+		if errors.As(err, &syntaxErr) {
+			message = syntaxErr.Message
+			if syntaxErr.File != nil {
+				position := syntaxErr.File.Position(syntaxErr.Offset)
+				line, column = position.Line, position.Column
+			}
+		} else {
+			message = err.Error()
+		}
+	} else {
+		return
 	}
 
-	if braceCount != 0 {
-		v.addError("ResponseHandler", "Unmatched braces in JavaScript code", request)
+	// Map the position inside the script to the file
+	fileLine, fileColumn := 0, 0
+	if handler.LineNumber > 0 && line > 0 {
+		fileLine, fileColumn = handler.LineNumber+line-1, column
+		if line == 1 {
+			fileColumn += handler.Column - 1
+		}
 	}
 
-	// Check for common typos in API usage
-	if strings.Contains(script, "client.") {
-		// This is probably using the client API - basic validation
-		if !strings.Contains(script, "client.test") && !strings.Contains(script, "client.assert") &&
-			!strings.Contains(script, "client.global") {
-			// Warning: might be valid code, but let's check
-		}
+	text := fmt.Sprintf("JavaScript syntax error: %s", message)
+	if fileLine > 0 {
+		text = fmt.Sprintf("JavaScript syntax error at line %d, column %d: %s", fileLine, fileColumn, message)
 	}
+
+	v.addRuleError(RuleJSSyntax, "ResponseHandler", text, request)
+	v.errors[len(v.errors)-1].Line = fileLine
+	v.errors[len(v.errors)-1].Column = fileColumn
 }
 
 // isValidVariableName checks if variable name is valid
@@ -436,21 +636,53 @@ func (v *Validator) isValidVariableName(name string) bool {
 		return false
 	}
 
-	// Variable names should start with letter or underscore, followed by letters, digits, underscore, or hyphen
-	variableNameRegex := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
-	return variableNameRegex.MatchString(name)
+	// Variable names should start with letter or underscore, followed by letters, digits, underscore, or hyphen.
+	// Dots separate parts (session.api.token), and dynamic variables such as $faker.lorem 20 take arguments.
+	variableNameRegex := regexp.MustCompile(`^\$?[a-zA-Z_][a-zA-Z0-9_-]*(\.[a-zA-Z_][a-zA-Z0-9_-]*)*$`)
+	fields := strings.Fields(name)
+	if len(fields) > 1 && !strings.HasPrefix(name, "$") {
+		return false
+	}
+	return len(fields) > 0 && variableNameRegex.MatchString(fields[0])
+}
+
+// ruleEnabled returns true if a configurable rule should be checked. Rules
+// run in strict mode unless configured otherwise.
+func (v *Validator) ruleEnabled(rule string) bool {
+	if config, ok := v.rules.Rule(rule); ok {
+		return config.Enabled
+	}
+	return v.strict
 }
 
 // addError adds a validation error
 func (v *Validator) addError(field, message string, request *Request) {
 	error := ValidationError{
-		Field:   field,
-		Message: message,
-		Request: request,
+		Field:    field,
+		Message:  message,
+		Request:  request,
+		Severity: SeverityError,
 	}
 	v.errors = append(v.errors, error)
 }
 
+// addRuleError adds a validation error for a configurable rule, using the
+// rule's configured severity
+func (v *Validator) addRuleError(rule, field, message string, request *Request) {
+	severity := SeverityError
+	if config, ok := v.rules.Rule(rule); ok && config.Severity != "" {
+		severity = config.Severity
+	}
+
+	v.errors = append(v.errors, ValidationError{
+		Field:    field,
+		Message:  message,
+		Request:  request,
+		Rule:     rule,
+		Severity: severity,
+	})
+}
+
 // ValidateFile validates an HTTP request file
 func ValidateFile(filename string, content string, strict bool) ([]ValidationError, error) {
 	requestsFile, err := ParseFile(filename, content)
@@ -469,18 +701,52 @@ func IsValid(errors []ValidationError) bool {
 	return len(errors) == 0
 }
 
-// FormatErrors formats validation errors for display
+// HasErrors returns true if any validation error has error severity.
+// Warnings alone don't fail validation.
+func HasErrors(errors []ValidationError) bool {
+	for _, err := range errors {
+		if err.Severity != SeverityWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatErrors formats validation errors for display, errors first and
+// then warnings
 func FormatErrors(errors []ValidationError) string {
 	if len(errors) == 0 {
 		return ""
 	}
 
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Found %d validation error(s):\n", len(errors)))
+	var failures, warnings []ValidationError
+	for _, err := range errors {
+		if err.Severity == SeverityWarning {
+			warnings = append(warnings, err)
+		} else {
+			failures = append(failures, err)
+		}
+	}
 
-	for i, err := range errors {
-		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, err.Error()))
+	var builder strings.Builder
+	writeGroup := func(title string, group []ValidationError) {
+		if len(group) == 0 {
+			return
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(fmt.Sprintf("Found %d validation %s:\n", len(group), title))
+		for i, err := range group {
+			builder.WriteString(fmt.Sprintf("%d. %s", i+1, err.Error()))
+			if err.Rule != "" {
+				builder.WriteString(fmt.Sprintf(" [%s]", err.Rule))
+			}
+			builder.WriteString("\n")
+		}
 	}
+	writeGroup("error(s)", failures)
+	writeGroup("warning(s)", warnings)
 
 	return builder.String()
 }
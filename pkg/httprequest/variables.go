@@ -0,0 +1,151 @@
+package httprequest
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VariableRef is a {{variable}} reference in an .http file
+type VariableRef struct {
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// VariableScan lists the variables an .http file uses and defines
+type VariableScan struct {
+	Refs       []VariableRef  // {{variable}} references outside comments and scripts, in order
+	FileVars   map[string]int // @name = value definitions, by line
+	ScriptVars map[string]int // Names set with client.global.set or client.env.set in inline scripts, by line
+}
+
+// variableRefPattern matches {{variable}} references and captures the name
+var variableRefPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// scriptSetPattern matches client.global.set("name" and client.env.set("name"
+var scriptSetPattern = regexp.MustCompile(`client\.(?:global|env)\.set\(\s*["'` + "`" + `]([^"'` + "`" + `]+)["'` + "`" + `]`)
+
+// ScanVariables finds the variables referenced and defined in the content
+// of an .http file. Line and column numbers are 1-based.
+func ScanVariables(content string) *VariableScan {
+	scan := &VariableScan{
+		FileVars:   make(map[string]int),
+		ScriptVars: make(map[string]int),
+	}
+
+	// A # @template request's body holds template actions, not variables
+	inScript, templateRequest, inTemplateBody := false, false, false
+	requestLine := false
+	for i, line := range strings.Split(content, "\n") {
+		lineNumber := i + 1
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "###") {
+			templateRequest, inTemplateBody, requestLine = false, false, false
+		}
+
+		if !inScript && strings.HasPrefix(trimmed, "> {%") {
+			inScript = true
+			line = line[strings.Index(line, "{%")+2:]
+		}
+		if inScript {
+			for _, match := range scriptSetPattern.FindAllStringSubmatch(line, -1) {
+				if _, seen := scan.ScriptVars[match[1]]; !seen {
+					scan.ScriptVars[match[1]] = lineNumber
+				}
+			}
+			if strings.Contains(line, "%}") {
+				inScript = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			text := strings.TrimSpace(strings.TrimLeft(trimmed, "#/"))
+			if key, _, ok := ParseDirective(text); ok && key == DirectiveTemplate {
+				templateRequest = true
+			}
+			continue
+		}
+		if templateRequest && !inTemplateBody {
+			if trimmed == "" && requestLine {
+				inTemplateBody = true
+			}
+			requestLine = requestLine || trimmed != ""
+		}
+
+		if name, _, ok := ParseVariableDefinition(trimmed); ok {
+			if _, seen := scan.FileVars[name]; !seen {
+				scan.FileVars[name] = lineNumber
+			}
+		}
+
+		for _, match := range variableRefPattern.FindAllStringSubmatchIndex(line, -1) {
+			name := line[match[2]:match[3]]
+			if inTemplateBody {
+				// Template actions such as {{range .items}} aren't variables
+				if name = templateVariable(name); name == "" {
+					continue
+				}
+			}
+			scan.Refs = append(scan.Refs, VariableRef{
+				Name:   name,
+				Line:   lineNumber,
+				Column: match[0] + 1,
+			})
+		}
+	}
+
+	return scan
+}
+
+// templateKeywords are the template actions that look like variable names
+var templateKeywords = map[string]bool{"end": true, "else": true, "break": true, "continue": true, "nil": true, "true": true, "false": true}
+
+// templateVariable returns the variable a {{...}} action in a # @template
+// body uses directly, as in {{name}} or {{.name}}, or "" for other actions
+func templateVariable(action string) string {
+	name := strings.TrimPrefix(action, ".")
+	if i := strings.Index(name, "."); i > 0 && name != action {
+		name = name[:i] // {{.user.id}} uses user
+	}
+	if !templateVariablePattern.MatchString(name) || templateKeywords[name] {
+		return ""
+	}
+	return name
+}
+
+// templateVariablePattern matches a variable name in a template action
+var templateVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Names returns the distinct referenced variable names, sorted
+func (s *VariableScan) Names() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, ref := range s.Refs {
+		if !seen[ref.Name] {
+			seen[ref.Name] = true
+			names = append(names, ref.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// External returns the referenced variable names that the file doesn't
+// define itself with @name = value or a response handler, sorted. Dynamic
+// variables such as {{$uuid}} are left out.
+func (s *VariableScan) External() []string {
+	var names []string
+	for _, name := range s.Names() {
+		if strings.HasPrefix(name, "$") {
+			continue
+		}
+		_, fileVar := s.FileVars[name]
+		_, scriptVar := s.ScriptVars[name]
+		if !fileVar && !scriptVar {
+			names = append(names, name)
+		}
+	}
+	return names
+}
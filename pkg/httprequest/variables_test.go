@@ -0,0 +1,67 @@
+package httprequest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanVariables(t *testing.T) {
+	content := "@host = {{region}}.example.com\n" +
+		"# GET {{commented}}\n" +
+		"GET https://{{host}}/users/{{ userId }}?id={{$uuid}}\n" +
+		"Authorization: Bearer {{token}}\n" +
+		"\n" +
+		"> {%\n" +
+		"  client.global.set(\"session\", response.body.id);\n" +
+		"  client.log(\"{{notARef}}\");\n" +
+		"%}\n" +
+		"\n" +
+		"###\n" +
+		"GET https://{{host}}/sessions/{{session}}\n"
+
+	scan := ScanVariables(content)
+
+	if len(scan.Refs) != 7 {
+		t.Fatalf("Expected 7 references, got %d: %+v", len(scan.Refs), scan.Refs)
+	}
+	userID := scan.Refs[2]
+	if userID.Name != "userId" || userID.Line != 3 || userID.Column != 28 {
+		t.Errorf("Expected userId at 3:28, got %+v", userID)
+	}
+	if line := scan.FileVars["host"]; line != 1 {
+		t.Errorf("Expected host to be defined on line 1, got %d", line)
+	}
+	if line := scan.ScriptVars["session"]; line != 7 {
+		t.Errorf("Expected session to be set on line 7, got %d", line)
+	}
+
+	want := []string{"region", "token", "userId"}
+	if got := scan.External(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected external variables %v, got %v", want, got)
+	}
+}
+
+func TestScanVariablesTemplate(t *testing.T) {
+	content := "### Bulk create\n" +
+		"# @template\n" +
+		"POST {{baseUrl}}/users\n" +
+		"Authorization: Bearer {{session.api.token}}\n" +
+		"\n" +
+		"[{{range $i, $n := until .count}}{{if $i}},{{end}}\n" +
+		"  {\"name\": \"{{prefix}}-{{$n}}\", \"team\": {{.team.id | toJson}}}\n" +
+		"{{- end}}]\n" +
+		"\n" +
+		"###\n" +
+		"POST {{baseUrl}}/plain\n" +
+		"\n" +
+		"{\"id\": \"{{.notTemplate}}\"}\n"
+
+	var names []string
+	for _, ref := range ScanVariables(content).Refs {
+		names = append(names, ref.Name)
+	}
+	want := []string{"baseUrl", "session.api.token", "prefix", "team", "baseUrl", ".notTemplate"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected references %v, got %v", want, names)
+	}
+}
@@ -0,0 +1,227 @@
+// Package listener runs a temporary HTTP server that captures the requests
+// it receives, such as webhook callbacks, so that runs can check they were
+// delivered.
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/logging"
+)
+
+// MaxBodySize is the most of a callback's body that is kept
+const MaxBodySize = 10 << 20
+
+// URLVariable is the variable requests use for the listener's URL, such as
+// a callback URL to register
+const URLVariable = "listener.url"
+
+// Callback is a request the listener received
+type Callback struct {
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Query      string              `json:"query,omitempty"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	RemoteAddr string              `json:"remote_addr,omitempty"`
+}
+
+// JSON returns the body decoded as JSON, or nil if it isn't JSON
+func (c Callback) JSON() interface{} {
+	var value interface{}
+	if json.Unmarshal([]byte(c.Body), &value) != nil {
+		return nil
+	}
+	return value
+}
+
+// Listener is a running HTTP server that answers every request with the
+// same status and records it
+type Listener struct {
+	server    *http.Server
+	listener  net.Listener
+	status    int
+	publicURL string // URL of a tunnel to the listener ("" for none)
+
+	mu        sync.Mutex
+	callbacks []Callback
+	delivered int           // Callbacks that OnCallback's fn has been called with
+	arrived   chan struct{} // Closed, and replaced, when a callback is delivered
+	notify    func(Callback)
+}
+
+// Start listens on addr, such as ":9000" or "127.0.0.1:0", and answers
+// requests with status (200 if 0)
+func Start(addr string, status int) (*Listener, error) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	l := &Listener{listener: ln, status: status, arrived: make(chan struct{})}
+	l.server = &http.Server{Handler: l, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := l.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Warn("listener stopped", "addr", addr, "error", err)
+		}
+	}()
+	return l, nil
+}
+
+// Addr returns the address the listener is bound to
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Port returns the port the listener is bound to
+func (l *Listener) Port() int {
+	return l.listener.Addr().(*net.TCPAddr).Port
+}
+
+// LocalURL returns the listener's local base URL, such as
+// http://localhost:9000
+func (l *Listener) LocalURL() string {
+	return fmt.Sprintf("http://localhost:%d", l.Port())
+}
+
+// URL returns the public URL of the listener's tunnel, or its local URL
+// without one
+func (l *Listener) URL() string {
+	if l.publicURL != "" {
+		return l.publicURL
+	}
+	return l.LocalURL()
+}
+
+// SetPublicURL sets the URL of a tunnel to the listener, which URL returns
+// from then on. Call it before the listener is shared.
+func (l *Listener) SetPublicURL(url string) {
+	l.publicURL = strings.TrimSuffix(url, "/")
+}
+
+// OnCallback calls fn with each callback as it arrives
+func (l *Listener) OnCallback(fn func(Callback)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.notify = fn
+}
+
+// Callbacks returns the callbacks received so far, oldest first
+func (l *Listener) Callbacks() []Callback {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Callback(nil), l.callbacks...)
+}
+
+// Wait waits until count callbacks have arrived, and OnCallback's fn has
+// been called with them, and returns them all. If ctx ends first, it
+// returns those received so far with ctx's error.
+func (l *Listener) Wait(ctx context.Context, count int) ([]Callback, error) {
+	for {
+		l.mu.Lock()
+		received, arrived := l.delivered, l.arrived
+		l.mu.Unlock()
+		if received >= count {
+			return l.Callbacks(), nil
+		}
+
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return l.Callbacks(), ctx.Err()
+		}
+	}
+}
+
+// Close stops the listener
+func (l *Listener) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return l.server.Shutdown(ctx)
+}
+
+// ServeHTTP records a request and answers it
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, MaxBodySize))
+	callback := Callback{
+		Time:       time.Now(),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Headers:    r.Header,
+		Body:       string(body),
+		RemoteAddr: r.RemoteAddr,
+	}
+
+	l.mu.Lock()
+	l.callbacks = append(l.callbacks, callback)
+	notify := l.notify
+	l.mu.Unlock()
+
+	// Waiters wake once the callback has been passed on, so that they
+	// see what fn did with it
+	if notify != nil {
+		notify(callback)
+	}
+	l.mu.Lock()
+	l.delivered++
+	close(l.arrived)
+	l.arrived = make(chan struct{})
+	l.mu.Unlock()
+
+	w.WriteHeader(l.status)
+}
+
+// String formats a callback as its request line, headers and body
+func (c Callback) String() string {
+	var output strings.Builder
+	target := c.Path
+	if c.Query != "" {
+		target += "?" + c.Query
+	}
+	output.WriteString(fmt.Sprintf("%s %s\n", c.Method, target))
+	names := make([]string, 0, len(c.Headers))
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range c.Headers[name] {
+			output.WriteString(fmt.Sprintf("%s: %s\n", name, value))
+		}
+	}
+	if c.Body != "" {
+		output.WriteString("\n" + c.Body + "\n")
+	}
+	return output.String()
+}
+
+// Save writes callbacks to path as a JSON array
+func Save(path string, callbacks []Callback) error {
+	if callbacks == nil {
+		callbacks = []Callback{}
+	}
+	data, err := json.MarshalIndent(callbacks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write callbacks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	l, err := Start("127.0.0.1:0", http.StatusAccepted)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer l.Close()
+
+	notified := make(chan Callback, 2)
+	l.OnCallback(func(callback Callback) { notified <- callback })
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Post(l.URL()+"/hooks/orders?source=test", "application/json", strings.NewReader(`{"event":"created"}`))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				t.Errorf("Expected 202, got %d", resp.StatusCode)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	callbacks, err := l.Wait(ctx, 1)
+	if err != nil || len(callbacks) != 1 {
+		t.Fatalf("Expected one callback, got %v, %v", callbacks, err)
+	}
+	callback := callbacks[0]
+	if callback.Method != "POST" || callback.Path != "/hooks/orders" || callback.Query != "source=test" || callback.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("unexpected callback: %+v", callback)
+	}
+	if body, ok := callback.JSON().(map[string]interface{}); !ok || body["event"] != "created" {
+		t.Errorf("unexpected JSON: %v", callback.JSON())
+	}
+	select {
+	case callback := <-notified:
+		if callback.Path != "/hooks/orders" {
+			t.Errorf("unexpected notified callback: %+v", callback)
+		}
+	default:
+		t.Errorf("Expected OnCallback to be called before Wait returned")
+	}
+
+	// Fewer than expected by the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if callbacks, err := l.Wait(ctx, 2); len(callbacks) != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the one callback and a deadline error, got %d, %v", len(callbacks), err)
+	}
+
+	path := filepath.Join(t.TempDir(), "callbacks.json")
+	if err := Save(path, l.Callbacks()); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"path": "/hooks/orders"`) {
+		t.Errorf("unexpected file:\n%s", data)
+	}
+}
@@ -0,0 +1,131 @@
+package listener
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"postie/pkg/logging"
+)
+
+// DefaultTunnelTimeout is how long a tunnel client has to report its
+// public URL
+const DefaultTunnelTimeout = 30 * time.Second
+
+var (
+	// ngrokURL matches the public URL in ngrok's JSON log
+	ngrokURL = regexp.MustCompile(`"url":"(https://[^"]+)"`)
+
+	// publicURL matches the first https URL another client prints
+	publicURL = regexp.MustCompile(`https://[A-Za-z0-9.-]+(?::[0-9]+)?`)
+)
+
+// Tunnel is a running tunnel client that exposes a local port at a public
+// URL
+type Tunnel struct {
+	URL string
+
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+// TunnelCommand returns the command that opens a tunnel to port for spec:
+//
+//	ngrok               ngrok http <port>
+//	ssh:<destination>   ssh -R 80:localhost:<port> <destination>, for
+//	                    services such as localhost.run
+//	<command>           any other client; {port} is replaced with the port
+//
+// and the pattern that finds the public URL in its output
+func TunnelCommand(spec string, port int) ([]string, *regexp.Regexp, error) {
+	portText := strconv.Itoa(port)
+	switch {
+	case spec == "ngrok":
+		return []string{"ngrok", "http", portText, "--log", "stdout", "--log-format", "json"}, ngrokURL, nil
+	case strings.HasPrefix(spec, "ssh:"):
+		destination := strings.TrimPrefix(spec, "ssh:")
+		if destination == "" {
+			return nil, nil, fmt.Errorf("ssh tunnel needs a destination, e.g. ssh:nokey@localhost.run")
+		}
+		return []string{"ssh", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=30", "-R", "80:localhost:" + portText, destination}, publicURL, nil
+	}
+
+	args := strings.Fields(strings.ReplaceAll(spec, "{port}", portText))
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("empty tunnel command")
+	}
+	return args, publicURL, nil
+}
+
+// StartTunnel runs the tunnel client for spec and waits up to timeout for
+// it to print its public URL
+func StartTunnel(spec string, port int, timeout time.Duration) (*Tunnel, error) {
+	args, pattern, err := TunnelCommand(spec, port)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	output, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start tunnel %s: %w", args[0], err)
+	}
+	tunnel := &Tunnel{cmd: cmd}
+
+	found := make(chan string, 1)
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+		writer.Close()
+	}()
+	go func() {
+		// Keep reading so the client never blocks on a full pipe
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			line := scanner.Text()
+			logging.Debug("tunnel output", "line", line)
+			if match := pattern.FindStringSubmatch(line); match != nil {
+				select {
+				case found <- match[len(match)-1]:
+				default:
+				}
+			}
+		}
+		io.Copy(io.Discard, output)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case url := <-found:
+		tunnel.URL = strings.TrimSuffix(url, "/")
+		return tunnel, nil
+	case err := <-exited:
+		reason := "it exited"
+		if err != nil {
+			reason = err.Error()
+		}
+		return nil, fmt.Errorf("tunnel %s stopped before printing its URL: %s (see its output with --log-level debug)", args[0], reason)
+	case <-timer.C:
+		tunnel.Close()
+		return nil, fmt.Errorf("tunnel %s didn't print its URL within %s", args[0], timeout)
+	}
+}
+
+// Close stops the tunnel client
+func (t *Tunnel) Close() error {
+	var err error
+	t.once.Do(func() {
+		if t.cmd.Process != nil {
+			err = t.cmd.Process.Kill()
+		}
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+package listener
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTunnelCommand(t *testing.T) {
+	args, _, err := TunnelCommand("ngrok", 9000)
+	if err != nil || strings.Join(args[:3], " ") != "ngrok http 9000" {
+		t.Errorf("Expected ngrok http 9000, got %v (%v)", args, err)
+	}
+
+	args, _, err = TunnelCommand("ssh:nokey@localhost.run", 9000)
+	if err != nil || args[0] != "ssh" || args[len(args)-1] != "nokey@localhost.run" || !strings.Contains(strings.Join(args, " "), "-R 80:localhost:9000") {
+		t.Errorf("Unexpected ssh command %v (%v)", args, err)
+	}
+
+	args, _, err = TunnelCommand("cloudflared tunnel --url http://localhost:{port}", 8123)
+	if err != nil || args[len(args)-1] != "http://localhost:8123" {
+		t.Errorf("Expected {port} replaced, got %v (%v)", args, err)
+	}
+
+	for _, spec := range []string{"ssh:", "  "} {
+		if _, _, err := TunnelCommand(spec, 9000); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestStartTunnel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "tunnel.sh")
+	content := "#!/bin/sh\necho starting\necho \"forwarding https://abc123.example.test/ -> localhost:$1\"\nexec sleep 30\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tunnel, err := StartTunnel(script+" {port}", 9000, 5*time.Second)
+	if err != nil {
+		t.Fatalf("StartTunnel error: %v", err)
+	}
+	defer tunnel.Close()
+	if tunnel.URL != "https://abc123.example.test" {
+		t.Errorf("Expected the printed URL, got %q", tunnel.URL)
+	}
+	if err := tunnel.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+
+	// A client that exits without a URL
+	if _, err := StartTunnel("true", 9000, 5*time.Second); err == nil || !strings.Contains(err.Error(), "stopped before printing its URL") {
+		t.Errorf("Expected an early exit error, got %v", err)
+	}
+}
+
+func TestListenerPublicURL(t *testing.T) {
+	l, err := Start("127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer l.Close()
+
+	if l.URL() != l.LocalURL() {
+		t.Errorf("Expected the local URL without a tunnel, got %q", l.URL())
+	}
+	l.SetPublicURL("https://abc123.example.test")
+	if l.URL() != "https://abc123.example.test" || !strings.HasPrefix(l.LocalURL(), "http://localhost:") {
+		t.Errorf("Unexpected URLs %q and %q", l.URL(), l.LocalURL())
+	}
+}
@@ -0,0 +1,220 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"postie/pkg/redact"
+)
+
+// Log levels from least to most detailed.
+// Normal shows warnings and errors; quiet shows errors only.
+const (
+	LevelTrace   = slog.Level(-8)
+	LevelDebug   = slog.LevelDebug
+	LevelVerbose = slog.LevelInfo
+	LevelNormal  = slog.LevelWarn
+	LevelQuiet   = slog.LevelError
+)
+
+var (
+	mu       sync.RWMutex
+	level              = new(slog.LevelVar)
+	output   io.Writer = os.Stderr
+	logger             = newLogger(os.Stderr)
+	redactor *redact.Redactor
+	wire     bool // --trace: dump requests and responses without trace logs
+)
+
+func init() {
+	level.Set(LevelNormal)
+}
+
+// newLogger creates a structured text logger writing to w
+func newLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Timestamps add noise to interactive CLI output
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			if len(groups) == 0 && a.Key == slog.LevelKey {
+				if lvl, ok := a.Value.Any().(slog.Level); ok {
+					a.Value = slog.StringValue(LevelName(lvl))
+				}
+				return a
+			}
+			return redactAttr(a)
+		},
+	}))
+}
+
+// redactAttr masks secret values in string and error attributes
+func redactAttr(a slog.Attr) slog.Attr {
+	r := currentRedactor()
+	if r.Empty() {
+		return a
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(r.Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// SetRedactor sets the redactor applied to log attributes and wire dumps
+func SetRedactor(r *redact.Redactor) {
+	mu.Lock()
+	defer mu.Unlock()
+	redactor = r
+}
+
+// currentRedactor returns the active redactor (may be nil)
+func currentRedactor() *redact.Redactor {
+	mu.RLock()
+	defer mu.RUnlock()
+	return redactor
+}
+
+// ParseLevel converts a level name to a log level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "quiet", "error":
+		return LevelQuiet, nil
+	case "normal", "warn", "":
+		return LevelNormal, nil
+	case "verbose", "info":
+		return LevelVerbose, nil
+	case "debug":
+		return LevelDebug, nil
+	case "trace":
+		return LevelTrace, nil
+	default:
+		return LevelNormal, fmt.Errorf("unknown log level: %s (use quiet, normal, verbose, debug, trace)", name)
+	}
+}
+
+// LevelName returns the display name of a log level
+func LevelName(lvl slog.Level) string {
+	switch {
+	case lvl <= LevelTrace:
+		return "TRACE"
+	case lvl <= LevelDebug:
+		return "DEBUG"
+	case lvl <= LevelVerbose:
+		return "INFO"
+	case lvl <= LevelNormal:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// SetLevel sets the minimum level that is logged
+func SetLevel(lvl slog.Level) {
+	level.Set(lvl)
+}
+
+// GetLevel returns the current minimum log level
+func GetLevel() slog.Level {
+	return level.Level()
+}
+
+// Enabled returns true if messages at the given level are logged
+func Enabled(lvl slog.Level) bool {
+	return lvl >= level.Level()
+}
+
+// IsQuiet returns true if only errors should be shown
+func IsQuiet() bool {
+	return level.Level() >= LevelQuiet
+}
+
+// SetOutput redirects log output (stderr by default)
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+	logger = newLogger(w)
+}
+
+// Logger returns the underlying structured logger
+func Logger() *slog.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+// SetWireTrace turns wire dumps on or off, independently of the log level
+func SetWireTrace(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	wire = enabled
+}
+
+// WireTraceEnabled returns true if requests and responses are dumped: with
+// --trace, or at trace level
+func WireTraceEnabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return wire || Enabled(LevelTrace)
+}
+
+// Trace logs wire-level details
+func Trace(msg string, args ...any) {
+	Logger().Log(context.Background(), LevelTrace, msg, args...)
+}
+
+// Debug logs internal details useful for troubleshooting
+func Debug(msg string, args ...any) {
+	Logger().Log(context.Background(), LevelDebug, msg, args...)
+}
+
+// Verbose logs progress information shown with --verbose
+func Verbose(msg string, args ...any) {
+	Logger().Log(context.Background(), LevelVerbose, msg, args...)
+}
+
+// Warn logs problems that don't stop execution
+func Warn(msg string, args ...any) {
+	Logger().Log(context.Background(), LevelNormal, msg, args...)
+}
+
+// Error logs failures
+func Error(msg string, args ...any) {
+	Logger().Log(context.Background(), LevelQuiet, msg, args...)
+}
+
+// Dump writes a multi-line block (such as a raw HTTP message) when wire
+// tracing is enabled, prefixing each line so request and response dumps
+// are easy to tell apart
+func Dump(prefix string, text string) {
+	if !WireTraceEnabled() {
+		return
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	text = redactor.Redact(text)
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(prefix)
+		b.WriteString(" ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	io.WriteString(output, b.String())
+}
@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"postie/pkg/display"
+)
+
+// redactedHeaders lists headers whose values never appear in wire dumps
+var redactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// TraceTransport is an http.RoundTripper that dumps requests and responses,
+// like curl -v, with the time spent on DNS, connecting, TLS and waiting for
+// the first byte. It does nothing unless wire tracing is enabled. Response
+// bodies are only dumped at trace level, since commands print them anyway.
+type TraceTransport struct {
+	Base http.RoundTripper
+}
+
+// NewTraceTransport wraps base (http.DefaultTransport if nil) with wire logging
+func NewTraceTransport(base http.RoundTripper) *TraceTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &TraceTransport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !WireTraceEnabled() {
+		return t.Base.RoundTrip(req)
+	}
+
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		Dump(">", RedactDump(string(dump)))
+	} else {
+		Trace("failed to dump request", "error", err)
+	}
+
+	timings := &wireTimings{start: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace()))
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		Dump("*", timings.String())
+		Dump("*", "request failed: "+err.Error())
+		return resp, err
+	}
+
+	if dump, err := httputil.DumpResponse(resp, Enabled(LevelTrace)); err == nil {
+		Dump("<", RedactDump(string(dump)))
+	} else {
+		Trace("failed to dump response", "error", err)
+	}
+	Dump("*", timings.String())
+
+	return resp, nil
+}
+
+// wireTimings records when each phase of a request happened
+type wireTimings struct {
+	mu                        sync.Mutex
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+	addr                      string
+	tlsVersion                string
+	reused                    bool
+}
+
+// clientTrace returns the hooks that fill in the timings
+func (w *wireTimings) clientTrace() *httptrace.ClientTrace {
+	record := func(at *time.Time) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { record(&w.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { record(&w.dnsDone) },
+		ConnectStart:      func(string, string) { record(&w.connectStart) },
+		ConnectDone:       func(string, string, error) { record(&w.connectDone) },
+		TLSHandshakeStart: func() { record(&w.tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, _ error) {
+			record(&w.tlsDone)
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.tlsVersion = tls.VersionName(state.Version)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.reused = info.Reused
+			if info.Conn != nil {
+				w.addr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() { record(&w.firstByte) },
+	}
+}
+
+// String formats the timings, such as
+// "connected to 127.0.0.1:443 (dns 2ms, connect 1ms, tls TLS 1.3 12ms, ttfb 40ms)"
+func (w *wireTimings) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var phases []string
+	if !w.dnsStart.IsZero() && !w.dnsDone.IsZero() {
+		phases = append(phases, "dns "+display.Duration(w.dnsDone.Sub(w.dnsStart)))
+	}
+	if !w.connectStart.IsZero() && !w.connectDone.IsZero() {
+		phases = append(phases, "connect "+display.Duration(w.connectDone.Sub(w.connectStart)))
+	}
+	if !w.tlsStart.IsZero() && !w.tlsDone.IsZero() {
+		phases = append(phases, "tls "+strings.TrimSpace(w.tlsVersion+" "+display.Duration(w.tlsDone.Sub(w.tlsStart))))
+	}
+	if !w.firstByte.IsZero() {
+		phases = append(phases, "ttfb "+display.Duration(w.firstByte.Sub(w.start)))
+	}
+
+	connection := "no connection"
+	switch {
+	case w.reused:
+		connection = "reused connection to " + w.addr
+	case w.addr != "":
+		connection = "connected to " + w.addr
+	}
+	if len(phases) == 0 {
+		return connection
+	}
+	return fmt.Sprintf("%s (%s)", connection, strings.Join(phases, ", "))
+}
+
+// RedactDump masks credential headers in a raw HTTP message dump.
+// For Authorization headers the scheme is kept so "Bearer" vs "Basic" stays visible.
+func RedactDump(dump string) string {
+	lines := strings.Split(dump, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r")
+		if trimmed == "" {
+			break // end of headers; leave the body alone
+		}
+
+		colon := strings.Index(trimmed, ":")
+		if colon <= 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(trimmed[:colon])
+		if !redactedHeaders[strings.ToLower(name)] {
+			continue
+		}
+
+		lines[i] = name + ": " + RedactHeaderValue(name, strings.TrimSpace(trimmed[colon+1:]))
+		if strings.HasSuffix(line, "\r") {
+			lines[i] += "\r"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RedactHeaderValue masks a credential header value
+func RedactHeaderValue(name, value string) string {
+	lower := strings.ToLower(name)
+	if lower == "authorization" || lower == "proxy-authorization" {
+		if scheme, _, found := strings.Cut(value, " "); found {
+			return scheme + " [REDACTED]"
+		}
+	}
+	return "[REDACTED]"
+}
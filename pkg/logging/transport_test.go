@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactDump(t *testing.T) {
+	dump := "GET /users HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Authorization: Bearer secret-token\r\n" +
+		"Cookie: session=abc\r\n" +
+		"\r\n" +
+		"Authorization: body text is untouched"
+
+	got := RedactDump(dump)
+
+	if strings.Contains(got, "secret-token") || strings.Contains(got, "session=abc") {
+		t.Errorf("credentials leaked in dump:\n%s", got)
+	}
+	if !strings.Contains(got, "Authorization: Bearer [REDACTED]\r\n") {
+		t.Errorf("expected scheme to be kept, got:\n%s", got)
+	}
+	if !strings.Contains(got, "Host: example.com\r\n") {
+		t.Errorf("expected other headers unchanged, got:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "Authorization: body text is untouched") {
+		t.Errorf("expected body unchanged, got:\n%s", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]string{
+		"quiet":   "ERROR",
+		"normal":  "WARN",
+		"verbose": "INFO",
+		"debug":   "DEBUG",
+		"TRACE":   "TRACE",
+	}
+	for name, want := range tests {
+		lvl, err := ParseLevel(name)
+		if err != nil {
+			t.Fatalf("ParseLevel(%q): %v", name, err)
+		}
+		if got := LevelName(lvl); got != want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", name, got, want)
+		}
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestTraceTransportWireTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=server-secret")
+		io.WriteString(w, "response body")
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	SetOutput(&out)
+	SetWireTrace(true)
+	defer func() {
+		SetOutput(os.Stderr)
+		SetWireTrace(false)
+	}()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/users", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Authorization", "Bearer client-secret")
+	resp, err := (&http.Client{Transport: NewTraceTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := out.String()
+	for _, want := range []string{
+		"> POST /users HTTP/1.1",
+		"> Authorization: Bearer [REDACTED]",
+		`> {"name":"a"}`,
+		"< HTTP/1.1 200 OK",
+		"< Set-Cookie: [REDACTED]",
+		"* connected to " + server.Listener.Addr().String(),
+		"ttfb ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in trace:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"client-secret", "server-secret", "response body"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("unexpected %q in trace:\n%s", secret, got)
+		}
+	}
+}
+
+func TestWireTimingsString(t *testing.T) {
+	start := time.Now()
+	timings := &wireTimings{
+		start:        start,
+		dnsStart:     start,
+		dnsDone:      start.Add(2 * time.Millisecond),
+		connectStart: start.Add(2 * time.Millisecond),
+		connectDone:  start.Add(5 * time.Millisecond),
+		tlsStart:     start.Add(5 * time.Millisecond),
+		tlsDone:      start.Add(15 * time.Millisecond),
+		firstByte:    start.Add(40 * time.Millisecond),
+		addr:         "127.0.0.1:443",
+		tlsVersion:   "TLS 1.3",
+	}
+	want := "connected to 127.0.0.1:443 (dns 2ms, connect 3ms, tls TLS 1.3 10ms, ttfb 40ms)"
+	if got := timings.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	reused := &wireTimings{start: start, firstByte: start.Add(time.Millisecond), addr: "127.0.0.1:80", reused: true}
+	if got := reused.String(); got != "reused connection to 127.0.0.1:80 (ttfb 1ms)" {
+		t.Errorf("got %q", got)
+	}
+}
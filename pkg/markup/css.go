@@ -0,0 +1,249 @@
+package markup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Select returns the elements matching a CSS selector, in document order.
+// Supported are type, universal (*), #id, .class and attribute selectors
+// ([attr], [attr=v], [attr~=v], [attr^=v], [attr$=v], [attr*=v]), the
+// descendant (space) and child (>) combinators, and comma-separated groups.
+func Select(root *Node, selector string) ([]*Node, error) {
+	var groups [][]compound
+	for _, group := range strings.Split(selector, ",") {
+		compounds, err := parseSelector(strings.TrimSpace(group))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		groups = append(groups, compounds)
+	}
+
+	var matches []*Node
+	var walk func(*Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			if child.Type != ElementNode {
+				continue
+			}
+			for _, compounds := range groups {
+				if matchesSelector(child, compounds) {
+					matches = append(matches, child)
+					break
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	return matches, nil
+}
+
+// compound is a sequence of simple selectors, such as div.note[title],
+// with the combinator that joins it to the previous compound
+type compound struct {
+	child      bool // Joined by >
+	tag        string
+	id         string
+	classes    []string
+	attributes []attributeSelector
+}
+
+type attributeSelector struct {
+	name     string
+	operator string // "", =, ~=, ^=, $= or *=
+	value    string
+}
+
+// parseSelector parses one selector of a group
+func parseSelector(selector string) ([]compound, error) {
+	if selector == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	var compounds []compound
+	child := false
+	i := 0
+	for i < len(selector) {
+		switch selector[i] {
+		case ' ', '\t', '\n':
+			i++
+			continue
+		case '>':
+			if len(compounds) == 0 || child {
+				return nil, fmt.Errorf("unexpected >")
+			}
+			child = true
+			i++
+			continue
+		}
+
+		c, next, err := parseCompound(selector, i)
+		if err != nil {
+			return nil, err
+		}
+		c.child = child
+		compounds = append(compounds, c)
+		child = false
+		i = next
+	}
+	if child {
+		return nil, fmt.Errorf("selector ends with >")
+	}
+	return compounds, nil
+}
+
+// parseCompound parses the compound selector starting at i
+func parseCompound(selector string, i int) (compound, int, error) {
+	var c compound
+	start := i
+	for i < len(selector) {
+		switch ch := selector[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '>':
+			return c, i, nil
+		case ch == '*' && i == start:
+			c.tag = "*"
+			i++
+		case ch == '#' || ch == '.':
+			name, next := readIdentifier(selector, i+1)
+			if name == "" {
+				return c, i, fmt.Errorf("expected a name after %c", ch)
+			}
+			if ch == '#' {
+				c.id = name
+			} else {
+				c.classes = append(c.classes, name)
+			}
+			i = next
+		case ch == '[':
+			end := indexOutsideQuotes(selector[i:], "]")
+			if end < 0 {
+				return c, i, fmt.Errorf("unclosed [")
+			}
+			attribute, err := parseAttributeSelector(selector[i+1 : i+end])
+			if err != nil {
+				return c, i, err
+			}
+			c.attributes = append(c.attributes, attribute)
+			i += end + 1
+		case i == start:
+			name, next := readIdentifier(selector, i)
+			if name == "" {
+				return c, i, fmt.Errorf("unexpected %q", ch)
+			}
+			c.tag = name
+			i = next
+		default:
+			return c, i, fmt.Errorf("unexpected %q", ch)
+		}
+	}
+	return c, i, nil
+}
+
+func parseAttributeSelector(text string) (attributeSelector, error) {
+	for _, operator := range []string{"~=", "^=", "$=", "*=", "="} {
+		if i := indexOutsideQuotes(text, operator); i >= 0 {
+			value := strings.TrimSpace(text[i+len(operator):])
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			}
+			return attributeSelector{name: strings.TrimSpace(text[:i]), operator: operator, value: value}, nil
+		}
+	}
+	name := strings.TrimSpace(text)
+	if name == "" {
+		return attributeSelector{}, fmt.Errorf("empty attribute selector")
+	}
+	return attributeSelector{name: name}, nil
+}
+
+// readIdentifier reads a tag, id, or class name
+func readIdentifier(selector string, i int) (string, int) {
+	start := i
+	for i < len(selector) {
+		ch := selector[i]
+		if ch == '-' || ch == '_' || ch == ':' || ch >= 0x80 ||
+			(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') {
+			i++
+			continue
+		}
+		break
+	}
+	return selector[start:i], i
+}
+
+// matchesSelector reports whether an element matches the last compound and
+// has ancestors matching the ones before it
+func matchesSelector(node *Node, compounds []compound) bool {
+	last := len(compounds) - 1
+	if !compounds[last].matches(node) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+
+	if compounds[last].child {
+		parent := node.Parent
+		return parent != nil && parent.Type == ElementNode && matchesSelector(parent, compounds[:last])
+	}
+	for ancestor := node.Parent; ancestor != nil && ancestor.Type == ElementNode; ancestor = ancestor.Parent {
+		if matchesSelector(ancestor, compounds[:last]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether an element matches a compound selector
+func (c compound) matches(node *Node) bool {
+	if c.tag != "" && c.tag != "*" && !strings.EqualFold(node.Name, c.tag) {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := node.Attr("id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := node.Attr("class")
+		classes := strings.Fields(class)
+		for _, want := range c.classes {
+			if !contains(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, attribute := range c.attributes {
+		value, ok := node.Attr(attribute.name)
+		if !ok || !attribute.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a attributeSelector) matches(value string) bool {
+	switch a.operator {
+	case "=":
+		return value == a.value
+	case "~=":
+		return contains(strings.Fields(value), a.value)
+	case "^=":
+		return a.value != "" && strings.HasPrefix(value, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(value, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(value, a.value)
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package markup
+
+import (
+	"reflect"
+	"testing"
+)
+
+const catalog = `<?xml version="1.0" encoding="UTF-8"?>
+<catalog xmlns:x="urn:x">
+  <book id="1" lang="en"><title>Go</title><price>30</price></book>
+  <book id="2"><title>Rust</title><price>45.0</price></book>
+  <x:book id="3"><title>Zig</title></x:book>
+</catalog>`
+
+const page = `<!DOCTYPE html>
+<html>
+<head><title>Home &amp; Away</title><script>if (a < b) {}</script></head>
+<body>
+  <div id="main" class="content wide">
+    <p class="note">One<br>Two
+    <p>Three</p>
+    <a href="https://example.com/docs">Docs</a>
+  </div>
+  <ul><li>a</li><li class="last">b</li></ul>
+</body>
+</html>`
+
+func texts(nodes []*Node) []string {
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, node.Text())
+	}
+	return values
+}
+
+func TestXPath(t *testing.T) {
+	doc, err := ParseXML([]byte(catalog))
+	if err != nil {
+		t.Fatalf("ParseXML failed: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"//title/text()", []string{"Go", "Rust", "Zig"}},
+		{"/catalog/book/@id", []string{"1", "2", "3"}},
+		{"//book[@lang='en']/title", []string{"Go"}},
+		{"//book[price=45]/title", []string{"Rust"}},
+		{"//book[price!=30]/@id", []string{"2"}},
+		{"//book[2]/title", []string{"Rust"}},
+		{"//book[last()]/title", []string{"Zig"}},
+		{"//x:book/title", []string{"Go", "Rust", "Zig"}},
+		{"//book[contains(title, 'us')]/@id", []string{"2"}},
+		{"//book[starts-with(title, 'Z')]/@id", []string{"3"}},
+		{"//title[.='Go']/../@id", []string{"1"}},
+		{"/catalog/*[@id='2']/price", []string{"45.0"}},
+		{"//missing", []string{}},
+	}
+
+	for _, tt := range tests {
+		nodes, err := XPath(doc, tt.expr)
+		if err != nil {
+			t.Errorf("XPath(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := texts(nodes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("XPath(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "//book[", "//book[@id=foo]"} {
+		if _, err := XPath(doc, expr); err == nil {
+			t.Errorf("XPath(%q) expected an error", expr)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	doc, err := ParseHTML([]byte(page))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"title", []string{"Home & Away"}},
+		{"#main > a", []string{"Docs"}},
+		{"div.content.wide a[href^='https://']", []string{"Docs"}},
+		{"li.last", []string{"b"}},
+		{"ul > li", []string{"a", "b"}},
+		{"body > li", []string{}},
+		{"[class~=note]", []string{"OneTwo\n    "}},
+		{"title, li.last", []string{"Home & Away", "b"}},
+	}
+
+	for _, tt := range tests {
+		nodes, err := Select(doc, tt.selector)
+		if err != nil {
+			t.Errorf("Select(%q) failed: %v", tt.selector, err)
+			continue
+		}
+		if got := texts(nodes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%q) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+
+	scripts, _ := Select(doc, "script")
+	if len(scripts) != 1 || scripts[0].Text() != "if (a < b) {}" {
+		t.Errorf("script content = %q", texts(scripts))
+	}
+
+	for _, selector := range []string{"", "div >", "a[href", "div, "} {
+		if _, err := Select(doc, selector); err == nil {
+			t.Errorf("Select(%q) expected an error", selector)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	got, err := Indent([]byte(`<?xml version="1.0"?><a><b x="1&amp;2">text</b><c/><!-- note --></a>`), false)
+	if err != nil {
+		t.Fatalf("Indent failed: %v", err)
+	}
+	want := `<?xml version="1.0"?>
+<a>
+  <b x="1&amp;2">text</b>
+  <c/>
+  <!-- note -->
+</a>
+`
+	if got != want {
+		t.Errorf("Indent XML =\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = Indent([]byte(`<!DOCTYPE html><html><body><p>Hi<br></p><img src="a.png"></body></html>`), true)
+	if err != nil {
+		t.Fatalf("Indent failed: %v", err)
+	}
+	want = `<!DOCTYPE html>
+<html>
+  <body>
+    <p>
+      Hi
+      <br>
+    </p>
+    <img src="a.png">
+  </body>
+</html>
+`
+	if got != want {
+		t.Errorf("Indent HTML =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Indent([]byte("<a><b></a>"), false); err == nil {
+		t.Error("Indent expected an error for malformed XML")
+	}
+}
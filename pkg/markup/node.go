@@ -0,0 +1,262 @@
+// Package markup parses XML and HTML response bodies so they can be
+// queried with XPath and CSS selectors, and pretty-printed.
+package markup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NodeType is the kind of a node in a document tree
+type NodeType int
+
+const (
+	DocumentNode  NodeType = iota
+	ElementNode            // <name attr="value">...</name>
+	TextNode               // Character data, including CDATA sections
+	CommentNode            // <!-- ... -->
+	AttributeNode          // An attribute selected by XPath's @name
+	ProcInstNode           // <?xml version="1.0"?>
+	DirectiveNode          // <!DOCTYPE html>
+)
+
+// Attr is an attribute of an element
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Node is a node of a parsed XML or HTML document
+type Node struct {
+	Type     NodeType
+	Name     string // Element or attribute name, without namespace prefix
+	Data     string // Text, comment, attribute value or raw markup
+	Attrs    []Attr
+	Parent   *Node
+	Children []*Node
+}
+
+// Attr returns the value of an element's attribute
+func (n *Node) Attr(name string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Text returns the text of a node: the concatenated text of an element's
+// descendants, or the value of a text, comment or attribute node
+func (n *Node) Text() string {
+	if n.Type != ElementNode && n.Type != DocumentNode {
+		return n.Data
+	}
+	var text strings.Builder
+	var walk func(*Node)
+	walk = func(node *Node) {
+		for _, child := range node.Children {
+			switch child.Type {
+			case TextNode:
+				text.WriteString(child.Data)
+			case ElementNode:
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return text.String()
+}
+
+// Elements returns the element children of a node
+func (n *Node) Elements() []*Node {
+	var elements []*Node
+	for _, child := range n.Children {
+		if child.Type == ElementNode {
+			elements = append(elements, child)
+		}
+	}
+	return elements
+}
+
+// Root returns the document element
+func (n *Node) Root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// ParseXML parses an XML document
+func ParseXML(data []byte) (*Node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = passthroughCharset
+	return build(decoder, false)
+}
+
+// ParseHTML parses an HTML document. Parsing is lenient: missing end tags
+// are inferred and void elements such as <br> need no end tag. Element and
+// attribute names are lower-cased.
+func ParseHTML(data []byte) (*Node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(protectRawText(data)))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = passthroughCharset
+	return build(decoder, true)
+}
+
+// build reads a document's tokens into a tree
+func build(decoder *xml.Decoder, html bool) (*Node, error) {
+	document := &Node{Type: DocumentNode}
+	current := document
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// HTML is often not well-formed; keep what was parsed
+			if html && len(document.Children) > 0 {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &Node{Type: ElementNode, Name: t.Name.Local}
+			if html {
+				element.Name = strings.ToLower(element.Name)
+				for current.Type == ElementNode && impliesEnd(current.Name, element.Name) {
+					current = current.Parent
+				}
+			}
+			element.Parent = current
+			for _, attr := range t.Attr {
+				name := attr.Name.Local
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && name == "xmlns") {
+					// Namespace declarations are kept under their written name
+					if attr.Name.Space != "" {
+						name = "xmlns:" + name
+					}
+				} else if html {
+					name = strings.ToLower(name)
+				}
+				element.Attrs = append(element.Attrs, Attr{Name: name, Value: attr.Value})
+			}
+			current.Children = append(current.Children, element)
+			current = element
+		case xml.EndElement:
+			// Close the nearest open element of that name; in HTML it may
+			// already have been closed implicitly
+			name := t.Name.Local
+			if html {
+				name = strings.ToLower(name)
+			}
+			for open := current; open.Type == ElementNode; open = open.Parent {
+				if open.Name == name {
+					current = open.Parent
+					break
+				}
+			}
+		case xml.CharData:
+			current.Children = append(current.Children, &Node{Type: TextNode, Data: string(t), Parent: current})
+		case xml.Comment:
+			current.Children = append(current.Children, &Node{Type: CommentNode, Data: string(t), Parent: current})
+		case xml.ProcInst:
+			current.Children = append(current.Children, &Node{Type: ProcInstNode, Name: t.Target, Data: string(t.Inst), Parent: current})
+		case xml.Directive:
+			current.Children = append(current.Children, &Node{Type: DirectiveNode, Data: string(t), Parent: current})
+		}
+	}
+
+	return document, nil
+}
+
+// closedByBlock are the block elements whose start ends an open <p>
+var closedByBlock = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true, "dl": true,
+	"fieldset": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// impliesEnd reports whether an HTML start tag ends the open element,
+// as <li> ends a previous <li>
+func impliesEnd(open, start string) bool {
+	switch open {
+	case "p":
+		return closedByBlock[start]
+	case "li":
+		return start == "li"
+	case "dt", "dd":
+		return start == "dt" || start == "dd"
+	case "option":
+		return start == "option"
+	case "tr":
+		return start == "tr"
+	case "td", "th":
+		return start == "td" || start == "th" || start == "tr"
+	}
+	return false
+}
+
+// rawTextElements hold text that isn't markup
+var rawTextElements = []string{"script", "style"}
+
+// protectRawText wraps the content of <script> and <style> elements in
+// CDATA sections, so characters such as < in them aren't read as markup
+func protectRawText(data []byte) []byte {
+	lower := bytes.ToLower(data)
+	var out bytes.Buffer
+	pos := 0
+
+	for pos < len(data) {
+		// Find the next raw text element
+		start, name := -1, ""
+		for _, element := range rawTextElements {
+			if i := bytes.Index(lower[pos:], []byte("<"+element)); i >= 0 && (start < 0 || pos+i < start) {
+				start, name = pos+i, element
+			}
+		}
+		if start < 0 {
+			break
+		}
+		openEnd := bytes.IndexByte(lower[start:], '>')
+		if openEnd < 0 {
+			break
+		}
+		contentStart := start + openEnd + 1
+		closeStart := bytes.Index(lower[contentStart:], []byte("</"+name))
+		if closeStart < 0 {
+			break
+		}
+		contentEnd := contentStart + closeStart
+
+		out.Write(data[pos:contentStart])
+		content := data[contentStart:contentEnd]
+		if len(bytes.TrimSpace(content)) > 0 && !bytes.Contains(content, []byte("]]>")) {
+			out.WriteString("<![CDATA[")
+			out.Write(content)
+			out.WriteString("]]>")
+		} else {
+			out.Write(content)
+		}
+		pos = contentEnd
+	}
+
+	out.Write(data[pos:])
+	return out.Bytes()
+}
+
+// passthroughCharset reads documents in other declared encodings as is;
+// most responses are UTF-8 whatever their declaration says
+func passthroughCharset(charset string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
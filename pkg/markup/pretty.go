@@ -0,0 +1,162 @@
+package markup
+
+import (
+	"strings"
+)
+
+// voidElements are the HTML elements that have no end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// maxInlineText is the longest text kept on the same line as its element
+const maxInlineText = 80
+
+// Indent pretty-prints an XML or HTML document with two-space indentation.
+// Whitespace between elements is dropped; an element holding only a short
+// text stays on one line.
+func Indent(data []byte, html bool) (string, error) {
+	var document *Node
+	var err error
+	if html {
+		document, err = ParseHTML(data)
+	} else {
+		document, err = ParseXML(data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	p := &printer{html: html}
+	for _, child := range document.Children {
+		p.print(child, 0)
+	}
+	return p.out.String(), nil
+}
+
+type printer struct {
+	out  strings.Builder
+	html bool
+}
+
+func (p *printer) line(depth int, text string) {
+	p.out.WriteString(strings.Repeat("  ", depth))
+	p.out.WriteString(text)
+	p.out.WriteString("\n")
+}
+
+func (p *printer) print(node *Node, depth int) {
+	switch node.Type {
+	case TextNode:
+		if text := strings.TrimSpace(node.Data); text != "" {
+			p.line(depth, escapeText(text))
+		}
+	case CommentNode:
+		p.line(depth, "<!--"+node.Data+"-->")
+	case ProcInstNode:
+		p.line(depth, "<?"+node.Name+" "+strings.TrimSpace(node.Data)+"?>")
+	case DirectiveNode:
+		p.line(depth, "<!"+node.Data+">")
+	case ElementNode:
+		p.printElement(node, depth)
+	}
+}
+
+func (p *printer) printElement(node *Node, depth int) {
+	open := p.startTag(node)
+	children := significantChildren(node)
+
+	if len(children) == 0 {
+		switch {
+		case p.html && voidElements[node.Name]:
+			p.line(depth, open+">")
+		case p.html:
+			p.line(depth, open+"></"+node.Name+">")
+		default:
+			p.line(depth, open+"/>")
+		}
+		return
+	}
+
+	if p.html && (node.Name == "script" || node.Name == "style") {
+		p.line(depth, open+">")
+		for _, line := range dedent(node.Text()) {
+			p.line(depth+1, line)
+		}
+		p.line(depth, "</"+node.Name+">")
+		return
+	}
+
+	if len(children) == 1 && children[0].Type == TextNode {
+		text := strings.TrimSpace(children[0].Data)
+		if len(text) <= maxInlineText && !strings.Contains(text, "\n") {
+			p.line(depth, open+">"+escapeText(text)+"</"+node.Name+">")
+			return
+		}
+	}
+
+	p.line(depth, open+">")
+	for _, child := range children {
+		p.print(child, depth+1)
+	}
+	p.line(depth, "</"+node.Name+">")
+}
+
+func (p *printer) startTag(node *Node) string {
+	var tag strings.Builder
+	tag.WriteString("<" + node.Name)
+	for _, attr := range node.Attrs {
+		tag.WriteString(" " + attr.Name + `="` + escapeAttr(attr.Value) + `"`)
+	}
+	return tag.String()
+}
+
+// significantChildren drops whitespace-only text between elements
+func significantChildren(node *Node) []*Node {
+	var children []*Node
+	for _, child := range node.Children {
+		if child.Type == TextNode && strings.TrimSpace(child.Data) == "" {
+			continue
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+// dedent splits text into lines and removes their common indentation
+func dedent(text string) []string {
+	lines := strings.Split(strings.Trim(text, "\n"), "\n")
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || indent < common {
+			common = indent
+		}
+	}
+
+	var result []string
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+func escapeAttr(value string) string {
+	return attrEscaper.Replace(value)
+}
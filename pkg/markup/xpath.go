@@ -0,0 +1,337 @@
+package markup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// XPath selects nodes with an XPath 1.0 location path. Supported are
+// absolute and relative paths, the / and // separators, name tests
+// (namespace prefixes are ignored), *, ., .., @name, @*, text(), node(),
+// and predicates: positions, last(), [@attr], [name], comparisons of
+// @attr, name, text() or . with = or != against a string or number, and
+// contains() and starts-with(). Results are in document order.
+func XPath(root *Node, expr string) ([]*Node, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty XPath expression")
+	}
+
+	steps, absolute, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	context := []*Node{root}
+	if absolute {
+		context = []*Node{root.Root()}
+	}
+	for _, step := range steps {
+		context, err = step.apply(context)
+		if err != nil {
+			return nil, fmt.Errorf("invalid XPath %q: %w", expr, err)
+		}
+	}
+	return context, nil
+}
+
+// xpathStep is one step of a location path
+type xpathStep struct {
+	descendant bool   // Preceded by //
+	test       string // Name test: name, *, @name, @*, text(), node(), . or ..
+	predicates []string
+}
+
+// parseXPath splits a location path into steps
+func parseXPath(expr string) ([]xpathStep, bool, error) {
+	absolute := strings.HasPrefix(expr, "/")
+	var steps []xpathStep
+
+	i := 0
+	for i < len(expr) {
+		step := xpathStep{}
+		if strings.HasPrefix(expr[i:], "//") {
+			step.descendant = true
+			i += 2
+		} else if expr[i] == '/' {
+			i++
+		}
+
+		// The name test runs to the first predicate or separator
+		start := i
+		for i < len(expr) && expr[i] != '/' && expr[i] != '[' {
+			i++
+		}
+		step.test = strings.TrimSpace(expr[start:i])
+		if step.test == "" {
+			if i >= len(expr) && !step.descendant && len(steps) == 0 {
+				// "/" selects the document
+				return nil, true, nil
+			}
+			return nil, false, fmt.Errorf("invalid XPath %q: empty step", expr)
+		}
+
+		for i < len(expr) && expr[i] == '[' {
+			end, err := closingBracket(expr, i)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid XPath %q: %w", expr, err)
+			}
+			step.predicates = append(step.predicates, strings.TrimSpace(expr[i+1:end]))
+			i = end + 1
+		}
+		steps = append(steps, step)
+	}
+	return steps, absolute, nil
+}
+
+// closingBracket finds the ] matching the [ at start, skipping quoted strings
+func closingBracket(expr string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed [")
+}
+
+// apply selects the nodes a step reaches from each context node
+func (s xpathStep) apply(context []*Node) ([]*Node, error) {
+	seen := make(map[*Node]bool)
+	var result []*Node
+
+	for _, node := range context {
+		origins := []*Node{node}
+		if s.descendant {
+			origins = descendantsOrSelf(node)
+		}
+		for _, origin := range origins {
+			candidates := s.candidates(origin)
+			for _, predicate := range s.predicates {
+				var err error
+				if candidates, err = filter(candidates, predicate); err != nil {
+					return nil, err
+				}
+			}
+			for _, candidate := range candidates {
+				if !seen[candidate] {
+					seen[candidate] = true
+					result = append(result, candidate)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// candidates returns the nodes matching the step's test from one node
+func (s xpathStep) candidates(node *Node) []*Node {
+	switch test := s.test; {
+	case test == ".":
+		return []*Node{node}
+	case test == "..":
+		if node.Parent == nil {
+			return nil
+		}
+		return []*Node{node.Parent}
+	case strings.HasPrefix(test, "@"):
+		return attributeNodes(node, test[1:])
+	case test == "text()":
+		var texts []*Node
+		for _, child := range node.Children {
+			if child.Type == TextNode {
+				texts = append(texts, child)
+			}
+		}
+		return texts
+	case test == "node()":
+		return append([]*Node(nil), node.Children...)
+	default:
+		var elements []*Node
+		for _, child := range node.Children {
+			if child.Type == ElementNode && nameMatches(child.Name, test) {
+				elements = append(elements, child)
+			}
+		}
+		return elements
+	}
+}
+
+// attributeNodes returns an element's attributes as nodes
+func attributeNodes(node *Node, name string) []*Node {
+	var attrs []*Node
+	for _, attr := range node.Attrs {
+		if name == "*" || nameMatches(attr.Name, name) {
+			attrs = append(attrs, &Node{Type: AttributeNode, Name: attr.Name, Data: attr.Value, Parent: node})
+		}
+	}
+	return attrs
+}
+
+// nameMatches compares a name with a name test, ignoring namespace prefixes
+func nameMatches(name, test string) bool {
+	if test == "*" {
+		return true
+	}
+	if i := strings.LastIndex(test, ":"); i >= 0 {
+		test = test[i+1:]
+	}
+	return name == test
+}
+
+func descendantsOrSelf(node *Node) []*Node {
+	nodes := []*Node{node}
+	for _, child := range node.Children {
+		if child.Type == ElementNode {
+			nodes = append(nodes, descendantsOrSelf(child)...)
+		}
+	}
+	return nodes
+}
+
+// filter keeps the nodes for which a predicate holds
+func filter(nodes []*Node, predicate string) ([]*Node, error) {
+	if predicate == "last()" {
+		if len(nodes) == 0 {
+			return nil, nil
+		}
+		return nodes[len(nodes)-1:], nil
+	}
+	if position, err := strconv.Atoi(predicate); err == nil {
+		if position < 1 || position > len(nodes) {
+			return nil, nil
+		}
+		return nodes[position-1 : position], nil
+	}
+
+	var kept []*Node
+	for _, node := range nodes {
+		ok, err := evalPredicate(node, predicate)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, node)
+		}
+	}
+	return kept, nil
+}
+
+// evalPredicate evaluates a boolean predicate for a node
+func evalPredicate(node *Node, predicate string) (bool, error) {
+	for _, function := range []string{"contains", "starts-with"} {
+		if strings.HasPrefix(predicate, function+"(") && strings.HasSuffix(predicate, ")") {
+			args := strings.SplitN(predicate[len(function)+1:len(predicate)-1], ",", 2)
+			if len(args) != 2 {
+				return false, fmt.Errorf("%s() needs 2 arguments", function)
+			}
+			values := operandValues(node, strings.TrimSpace(args[0]))
+			needle, err := literal(strings.TrimSpace(args[1]))
+			if err != nil {
+				return false, err
+			}
+			for _, value := range values {
+				if (function == "contains" && strings.Contains(value, needle)) ||
+					(function == "starts-with" && strings.HasPrefix(value, needle)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+
+	operator := ""
+	index := -1
+	if i := indexOutsideQuotes(predicate, "!="); i >= 0 {
+		operator, index = "!=", i
+	} else if i := indexOutsideQuotes(predicate, "="); i >= 0 {
+		operator, index = "=", i
+	}
+	if operator == "" {
+		// Existence test, such as [@id] or [price]
+		return len(operandValues(node, predicate)) > 0, nil
+	}
+
+	values := operandValues(node, strings.TrimSpace(predicate[:index]))
+	expected, err := literal(strings.TrimSpace(predicate[index+len(operator):]))
+	if err != nil {
+		return false, err
+	}
+	for _, value := range values {
+		if (value == expected || numericEqual(value, expected)) == (operator == "=") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// operandValues returns the string values an operand selects from a node
+func operandValues(node *Node, operand string) []string {
+	if operand == "." {
+		return []string{node.Text()}
+	}
+	steps, _, err := parseXPath(operand)
+	if err != nil {
+		return nil
+	}
+	context := []*Node{node}
+	for _, step := range steps {
+		if context, err = step.apply(context); err != nil {
+			return nil
+		}
+	}
+	values := make([]string, 0, len(context))
+	for _, selected := range context {
+		values = append(values, selected.Text())
+	}
+	return values
+}
+
+// literal reads a quoted string or a number
+func literal(token string) (string, error) {
+	if len(token) >= 2 && (token[0] == '\'' || token[0] == '"') && token[len(token)-1] == token[0] {
+		return token[1 : len(token)-1], nil
+	}
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return token, nil
+	}
+	return "", fmt.Errorf("expected a string or number, got %q", token)
+}
+
+func numericEqual(a, b string) bool {
+	x, errX := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	return errX == nil && errY == nil && x == y
+}
+
+// indexOutsideQuotes finds sep in s, ignoring quoted strings
+func indexOutsideQuotes(s, sep string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			return i
+		}
+	}
+	return -1
+}
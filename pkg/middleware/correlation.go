@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/telemetry"
+)
+
+// DefaultCorrelationHeaders are the headers CorrelationHook adds when none are given
+var DefaultCorrelationHeaders = []string{"X-Request-Id", "traceparent"}
+
+// CorrelationHook returns an executor hook that adds correlation headers to
+// every request so server-side logs and traces can be matched to a run. A
+// traceparent header gets a new W3C trace context; any other header gets a
+// random UUID. Headers the request already sets are left alone.
+func CorrelationHook(headers ...string) executor.Hook {
+	if len(headers) == 0 {
+		headers = DefaultCorrelationHeaders
+	}
+
+	return executor.HookFuncs{
+		BeforeRequestFunc: func(request *httprequest.Request) error {
+			for _, name := range headers {
+				if hasHeader(request, name) {
+					continue
+				}
+				value := NewUUID()
+				if strings.EqualFold(name, "traceparent") {
+					value = telemetry.Traceparent(telemetry.NewTraceID(), telemetry.NewSpanID())
+				}
+				request.Headers = append(request.Headers, httprequest.Header{Name: name, Value: value})
+			}
+			return nil
+		},
+	}
+}
+
+// NewUUID returns a random (version 4) UUID
+func NewUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// hasHeader reports whether a request sets a header
+func hasHeader(request *httprequest.Request, name string) bool {
+	for _, header := range request.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/telemetry"
+)
+
+func TestCorrelationHook(t *testing.T) {
+	var requestID, traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID, traceparent = r.Header.Get("X-Request-Id"), r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	exec := executor.NewExecutor(nil, &executor.ExecutorConfig{CorrelationHeaders: DefaultCorrelationHeaders})
+	exec.AddHook(CorrelationHook())
+
+	result, err := exec.ExecuteRequest(&httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}})
+	if err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(requestID) {
+		t.Errorf("Expected a UUID request ID, got %q", requestID)
+	}
+	if _, _, ok := telemetry.ParseTraceparent(traceparent); !ok {
+		t.Errorf("Expected a traceparent header, got %q", traceparent)
+	}
+
+	if len(result.Correlation) != 2 || result.Correlation[0].Value != requestID || result.Correlation[1].Value != traceparent {
+		t.Errorf("Expected the sent headers in the result, got %v", result.Correlation)
+	}
+}
+
+func TestCorrelationHookKeepsRequestHeaders(t *testing.T) {
+	request := &httprequest.Request{
+		Method:  "GET",
+		Headers: []httprequest.Header{{Name: "x-request-id", Value: "fixed"}},
+	}
+
+	CorrelationHook("X-Request-Id").BeforeRequest(request)
+
+	if len(request.Headers) != 1 || request.Headers[0].Value != "fixed" {
+		t.Errorf("Expected the request's own X-Request-Id to be kept, got %v", request.Headers)
+	}
+}
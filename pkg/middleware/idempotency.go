@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+// IdempotencyKeyHeader is the header IdempotencyHook adds
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyHook returns an executor hook that adds an Idempotency-Key
+// header to requests with a # @idempotency-key directive. "auto" sends a
+// new key each time the request is sent; "run" reuses one key for the
+// request throughout the hook's lifetime, so sending it again within a run
+// is recognised as a retry. A header the request already sets is left alone.
+func IdempotencyHook() executor.Hook {
+	var mu sync.Mutex
+	keys := make(map[string]string)
+
+	return executor.HookFuncs{
+		BeforeRequestFunc: func(request *httprequest.Request) error {
+			mode, ok := request.Metadata[httprequest.DirectiveIdempotencyKey]
+			if !ok || hasHeader(request, IdempotencyKeyHeader) {
+				return nil
+			}
+
+			var key string
+			switch strings.ToLower(mode) {
+			case "", "auto":
+				key = NewUUID()
+			case "run":
+				id := request.Name
+				if id == "" {
+					id = request.Method + " " + request.URL.Raw
+				}
+				mu.Lock()
+				if key = keys[id]; key == "" {
+					key = NewUUID()
+					keys[id] = key
+				}
+				mu.Unlock()
+			default:
+				return fmt.Errorf("invalid @%s value %q (supported: auto, run)", httprequest.DirectiveIdempotencyKey, mode)
+			}
+
+			request.Headers = append(request.Headers, httprequest.Header{Name: IdempotencyKeyHeader, Value: key})
+			return nil
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+func TestIdempotencyHook(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+	}))
+	defer server.Close()
+
+	exec := executor.NewExecutor(nil, nil)
+	exec.AddHook(IdempotencyHook())
+
+	send := func(mode string, headers ...httprequest.Header) {
+		t.Helper()
+		request := &httprequest.Request{
+			Name:     "charge",
+			Method:   "POST",
+			URL:      &httprequest.URL{Raw: server.URL},
+			Headers:  headers,
+			Metadata: map[string]string{httprequest.DirectiveIdempotencyKey: mode},
+		}
+		if _, err := exec.ExecuteRequest(request); err != nil {
+			t.Fatalf("ExecuteRequest error: %v", err)
+		}
+	}
+
+	// auto sends a new key each time; run reuses the request's key
+	send("auto")
+	send("auto")
+	send("run")
+	send("run")
+	send("auto", httprequest.Header{Name: "Idempotency-Key", Value: "fixed"})
+
+	if len(keys) != 5 || keys[0] == "" || keys[0] == keys[1] {
+		t.Errorf("Expected a new key per send for auto, got %v", keys)
+	}
+	if keys[2] == "" || keys[2] != keys[3] {
+		t.Errorf("Expected one key per run for run, got %v", keys)
+	}
+	if keys[4] != "fixed" {
+		t.Errorf("Expected the request's own key to be kept, got %q", keys[4])
+	}
+
+	request := &httprequest.Request{Method: "POST", URL: &httprequest.URL{Raw: server.URL}, Metadata: map[string]string{httprequest.DirectiveIdempotencyKey: "always"}}
+	if _, err := exec.ExecuteRequest(request); err == nil {
+		t.Error("Expected an error for an invalid mode")
+	}
+}
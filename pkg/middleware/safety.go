@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+// DestructiveMethods are the methods a safe hosts list guards
+var DestructiveMethods = []string{"POST", "PUT", "PATCH", "DELETE"}
+
+// HostSafetyHook returns an executor hook that stops POST, PUT, PATCH and
+// DELETE requests to hosts that don't match one of safeHosts, so a suite
+// meant for staging can't change production. Other methods are sent
+// anywhere.
+func HostSafetyHook(safeHosts []string) executor.Hook {
+	check := HostSafetyCheck(safeHosts)
+	return executor.HookFuncs{
+		BeforeRequestFunc: func(request *httprequest.Request) error {
+			if request.URL == nil {
+				return check(request.Method, "")
+			}
+			return check(request.Method, request.URL.Raw)
+		},
+	}
+}
+
+// HostSafetyCheck returns the check of HostSafetyHook for a method and
+// URL, such as those of a script's http() calls, or nil if safeHosts is
+// empty. A URL without a host that can be checked is blocked.
+func HostSafetyCheck(safeHosts []string) func(method, rawURL string) error {
+	if len(safeHosts) == 0 {
+		return nil
+	}
+	return func(method, rawURL string) error {
+		if !isDestructive(method) {
+			return nil
+		}
+		parsed, err := url.Parse(rawURL)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("%s to %q blocked: it has no host to check against the safe hosts (%s)",
+				strings.ToUpper(method), rawURL, strings.Join(safeHosts, ", "))
+		}
+		if HostAllowed(safeHosts, parsed.Host) {
+			return nil
+		}
+		return fmt.Errorf("%s to %s blocked: it isn't one of the safe hosts (%s); add it with 'postie context set --safe-hosts' or run with --yes-i-know",
+			strings.ToUpper(method), parsed.Host, strings.Join(safeHosts, ", "))
+	}
+}
+
+// HostAllowed reports whether host, a host name or host:port, matches one
+// of the patterns: a host name, which matches any port, host:port, or
+// *.example.com for the subdomains of example.com
+func HostAllowed(patterns []string, host string) bool {
+	parsed, err := url.Parse("//" + strings.ToLower(host))
+	if err != nil {
+		return false
+	}
+	hostname, port := parsed.Hostname(), parsed.Port()
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if patternHost, patternPort, err := net.SplitHostPort(pattern); err == nil {
+			if patternPort != port {
+				continue
+			}
+			pattern = patternHost
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(hostname, "."+suffix) {
+				return true
+			}
+		} else if strings.Trim(pattern, "[]") == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// isDestructive reports whether method is one of DestructiveMethods
+func isDestructive(method string) bool {
+	for _, destructive := range DestructiveMethods {
+		if strings.EqualFold(method, destructive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+func TestHostAllowed(t *testing.T) {
+	patterns := []string{"localhost", "api.staging.example.com", "*.dev.example.com", "127.0.0.1:8080", "[::1]"}
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"localhost", true},
+		{"localhost:3000", true},
+		{"API.Staging.Example.com", true},
+		{"orders.dev.example.com", true},
+		{"dev.example.com", false},
+		{"api.example.com", false},
+		{"127.0.0.1:8080", true},
+		{"127.0.0.1:9090", false},
+		{"127.0.0.1", false},
+		{"[::1]:8080", true},
+		{"staging.example.com.evil.test", false},
+	}
+	for _, tt := range tests {
+		if got := HostAllowed(patterns, tt.host); got != tt.allowed {
+			t.Errorf("%s: got %v, want %v", tt.host, got, tt.allowed)
+		}
+	}
+}
+
+func TestHostSafetyHook(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+	}))
+	defer server.Close()
+
+	// The test server listens on 127.0.0.1, which isn't a safe host
+	exec := executor.NewExecutor(nil, nil)
+	exec.AddHook(HostSafetyHook([]string{"localhost"}))
+
+	get := &httprequest.Request{Method: "GET", URL: &httprequest.URL{Raw: server.URL}}
+	if _, err := exec.ExecuteRequest(get); err != nil {
+		t.Fatalf("Expected GET to be sent anywhere, got %v", err)
+	}
+	post := &httprequest.Request{Method: "POST", URL: &httprequest.URL{Raw: server.URL}}
+	if _, err := exec.ExecuteRequest(post); err == nil || !strings.Contains(err.Error(), "--yes-i-know") {
+		t.Errorf("Expected POST to be blocked, got %v", err)
+	}
+	if len(methods) != 1 || methods[0] != "GET" {
+		t.Errorf("Expected only the GET to be sent, got %v", methods)
+	}
+
+	local := &httprequest.Request{Method: "DELETE", URL: &httprequest.URL{Raw: strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}}
+	if _, err := exec.ExecuteRequest(local); err != nil {
+		t.Errorf("Expected DELETE to a safe host to be sent, got %v", err)
+	}
+
+	// A script's http() calls are checked too
+	methods = nil
+	exec = executor.NewExecutor(nil, &executor.ExecutorConfig{ScriptHTTPCheck: HostSafetyCheck([]string{"localhost"})})
+	get.ResponseHandler = &httprequest.ResponseHandler{Type: httprequest.HandlerTypeInline, Script: `http({method: "POST", url: "` + server.URL + `"});`}
+	result, _ := exec.ExecuteRequest(get)
+	if result == nil || result.ScriptResult == nil || result.ScriptResult.Error == nil || !strings.Contains(result.ScriptResult.Error.Error(), "blocked") {
+		t.Errorf("Expected the script's POST to be blocked, got %+v", result)
+	}
+	if len(methods) != 1 || methods[0] != "GET" {
+		t.Errorf("Expected only the GET to be sent, got %v", methods)
+	}
+}
+
+func TestHostSafetyCheck(t *testing.T) {
+	if HostSafetyCheck(nil) != nil {
+		t.Errorf("Expected no check without safe hosts")
+	}
+
+	check := HostSafetyCheck([]string{"localhost"})
+	tests := []struct {
+		method, url string
+		allowed     bool
+	}{
+		{"POST", "http://localhost:8080/users", true},
+		{"GET", "https://api.example.com", true},
+		{"POST", "https://api.example.com", false},
+		// URLs whose host can't be checked are blocked
+		{"PUT", "/users/1", false},
+		{"DELETE", "http://%zz/", false},
+		{"PATCH", "", false},
+	}
+	for _, tt := range tests {
+		if err := check(tt.method, tt.url); (err == nil) != tt.allowed {
+			t.Errorf("%s %s: got %v, want allowed %v", tt.method, tt.url, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// YAMLEncoder writes values as YAML documents.
+// Field names and omission rules follow the same `json` struct tags used for
+// JSON output, so both formats describe the same structure.
+type YAMLEncoder struct {
+	w io.Writer
+}
+
+// NewYAMLEncoder creates a new YAML encoder writing to w
+func NewYAMLEncoder(w io.Writer) *YAMLEncoder {
+	return &YAMLEncoder{w: w}
+}
+
+// Encode writes the YAML representation of v
+func (e *YAMLEncoder) Encode(v interface{}) error {
+	var builder strings.Builder
+	if err := writeYAMLValue(&builder, reflect.ValueOf(v), 0, yamlTop); err != nil {
+		return err
+	}
+
+	out := builder.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+
+	_, err := io.WriteString(e.w, out)
+	return err
+}
+
+// MarshalYAML returns the YAML representation of v
+func MarshalYAML(v interface{}) ([]byte, error) {
+	var builder strings.Builder
+	if err := NewYAMLEncoder(&builder).Encode(v); err != nil {
+		return nil, err
+	}
+	return []byte(builder.String()), nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// yamlField is a struct field or map entry ready for output
+type yamlField struct {
+	key   string
+	value reflect.Value
+}
+
+// yamlPosition describes what precedes a value on the current line
+type yamlPosition int
+
+const (
+	yamlTop       yamlPosition = iota // start of a line
+	yamlAfterKey                      // after "key:"
+	yamlAfterDash                     // after "- "
+)
+
+// writeYAMLValue writes v at the given indentation level
+func writeYAMLValue(b *strings.Builder, v reflect.Value, indent int, pos yamlPosition) error {
+	v = normalizeYAMLValue(v)
+	if !v.IsValid() {
+		b.WriteString("null\n")
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return writeYAMLMapping(b, structFields(v), indent, pos)
+
+	case reflect.Map:
+		if v.Len() == 0 {
+			b.WriteString("{}\n")
+			return nil
+		}
+		return writeYAMLMapping(b, mapFields(v), indent, pos)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is rendered as text, matching how bodies are displayed
+			writeYAMLString(b, string(v.Bytes()), indent)
+			return nil
+		}
+		if v.Len() == 0 {
+			b.WriteString("[]\n")
+			return nil
+		}
+		if pos == yamlAfterKey {
+			b.WriteString("\n")
+		}
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 || pos != yamlAfterDash {
+				b.WriteString(strings.Repeat("  ", indent))
+			}
+			b.WriteString("- ")
+			if err := writeYAMLValue(b, v.Index(i), indent+1, yamlAfterDash); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		writeYAMLString(b, v.String(), indent)
+		return nil
+
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+		b.WriteString("\n")
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+		b.WriteString("\n")
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+		b.WriteString("\n")
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+		b.WriteString("\n")
+		return nil
+
+	default:
+		return fmt.Errorf("yaml: unsupported type %s", v.Type())
+	}
+}
+
+// normalizeYAMLValue dereferences pointers and interfaces and converts types
+// with custom JSON encoding (time.Time, environment.Variable, ...) through
+// JSON so they render the same way in both formats.
+// It returns an invalid value for nil.
+func normalizeYAMLValue(v reflect.Value) reflect.Value {
+	for v.IsValid() {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+			return reflect.Value{}
+		}
+
+		if v.Type().Implements(jsonMarshalerType) {
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return reflect.ValueOf(err.Error())
+			}
+			var generic interface{}
+			if err := json.Unmarshal(data, &generic); err != nil {
+				return reflect.ValueOf(string(data))
+			}
+			v = reflect.ValueOf(generic)
+			continue
+		}
+
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// writeYAMLMapping writes key/value pairs as a YAML mapping
+func writeYAMLMapping(b *strings.Builder, fields []yamlField, indent int, pos yamlPosition) error {
+	if len(fields) == 0 {
+		b.WriteString("{}\n")
+		return nil
+	}
+
+	if pos == yamlAfterKey {
+		b.WriteString("\n")
+	}
+
+	for i, field := range fields {
+		// The first key of a list item stays on the "- " line
+		if i > 0 || pos != yamlAfterDash {
+			b.WriteString(strings.Repeat("  ", indent))
+		}
+
+		b.WriteString(formatYAMLKey(field.key))
+		b.WriteString(":")
+
+		value := normalizeYAMLValue(field.value)
+		if !isYAMLCollection(value) {
+			b.WriteString(" ")
+		}
+		if err := writeYAMLValue(b, value, indent+1, yamlAfterKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isYAMLCollection reports whether a normalized value renders as a block mapping or sequence
+func isYAMLCollection(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return len(structFields(v)) > 0
+	case reflect.Map:
+		return v.Len() > 0
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		return v.Len() > 0
+	}
+	return false
+}
+
+// structFields returns the exported fields of a struct honoring json tags
+func structFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		value := v.Field(i)
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+		if omitEmpty && (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0 {
+			continue
+		}
+
+		fields = append(fields, yamlField{key: name, value: value})
+	}
+
+	return fields
+}
+
+// mapFields returns map entries sorted by key
+func mapFields(v reflect.Value) []yamlField {
+	fields := make([]yamlField, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		fields = append(fields, yamlField{key: fmt.Sprintf("%v", key.Interface()), value: v.MapIndex(key)})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+	return fields
+}
+
+// formatYAMLKey quotes a mapping key when needed
+func formatYAMLKey(key string) string {
+	if needsYAMLQuotes(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// writeYAMLString writes a scalar string, using a literal block for multi-line text
+func writeYAMLString(b *strings.Builder, s string, indent int) {
+	if canUseYAMLBlock(s) {
+		b.WriteString("|")
+		if !strings.HasSuffix(s, "\n") {
+			b.WriteString("-")
+		}
+		b.WriteString("\n")
+
+		pad := strings.Repeat("  ", indent)
+		for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+			if line != "" {
+				b.WriteString(pad)
+				b.WriteString(line)
+			}
+			b.WriteString("\n")
+		}
+		return
+	}
+
+	if needsYAMLQuotes(s) {
+		b.WriteString(strconv.Quote(s))
+	} else {
+		b.WriteString(s)
+	}
+	b.WriteString("\n")
+}
+
+// canUseYAMLBlock reports whether s can be written as a literal block scalar.
+// Block indentation is inferred from the first non-empty line, so text whose
+// first line is indented (or that has trailing spaces) is quoted instead.
+func canUseYAMLBlock(s string) bool {
+	if !strings.Contains(s, "\n") || strings.ContainsAny(s, "\r\t") {
+		return false
+	}
+	first := ""
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimRight(line, " ") != line {
+			return false // trailing spaces would not survive a round trip
+		}
+		if first == "" {
+			first = line
+		}
+	}
+	return first != "" && !strings.HasPrefix(first, " ")
+}
+
+// needsYAMLQuotes reports whether a plain scalar would be misread by a YAML parser
+func needsYAMLQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return true
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` ") || strings.HasSuffix(s, " ") {
+		return true
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+type yamlTestItem struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count,omitempty"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Secret  string            `json:"-"`
+	Enabled bool              `json:"enabled"`
+}
+
+func TestMarshalYAMLStruct(t *testing.T) {
+	data, err := MarshalYAML(yamlTestItem{
+		Name:    "Get Users",
+		Tags:    []string{"smoke", "users"},
+		Labels:  map[string]string{"team": "api", "env": "dev"},
+		Secret:  "hidden",
+		Enabled: true,
+	})
+	if err != nil {
+		t.Fatalf("MarshalYAML error: %v", err)
+	}
+
+	expected := `name: Get Users
+tags:
+  - smoke
+  - users
+labels:
+  env: dev
+  team: api
+enabled: true
+`
+	if string(data) != expected {
+		t.Errorf("unexpected YAML:\n%s\nwant:\n%s", data, expected)
+	}
+}
+
+func TestMarshalYAMLListOfMappings(t *testing.T) {
+	data, err := MarshalYAML(map[string]interface{}{
+		"requests": []yamlTestItem{{Name: "one", Count: 1}, {Name: "two"}},
+	})
+	if err != nil {
+		t.Fatalf("MarshalYAML error: %v", err)
+	}
+
+	expected := `requests:
+  - name: one
+    count: 1
+    enabled: false
+  - name: two
+    enabled: false
+`
+	if string(data) != expected {
+		t.Errorf("unexpected YAML:\n%s\nwant:\n%s", data, expected)
+	}
+}
+
+func TestMarshalYAMLQuoting(t *testing.T) {
+	data, err := MarshalYAML(map[string]interface{}{
+		"empty":    "",
+		"number":   "123",
+		"bool":     "true",
+		"variable": "{{baseUrl}}/users",
+		"plain":    "https://example.com/a",
+		"body":     "{\n  \"id\": 1\n}",
+	})
+	if err != nil {
+		t.Fatalf("MarshalYAML error: %v", err)
+	}
+
+	out := string(data)
+	for _, want := range []string{
+		`empty: ""`,
+		`number: "123"`,
+		`bool: "true"`,
+		`variable: "{{baseUrl}}/users"`,
+		`plain: https://example.com/a`,
+		"body: |-\n  {\n    \"id\": 1\n  }\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
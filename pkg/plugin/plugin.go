@@ -0,0 +1,185 @@
+// Package plugin loads plugins that extend postie without changing it:
+// auth schemes, providers of {{$name}} variables and request middleware.
+// A plugin is a directory with a plugin.json manifest and a program that
+// postie runs for each call, exchanging one JSON message on standard input
+// and output.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of a plugin's manifest
+const ManifestFile = "plugin.json"
+
+// DirEnv is the environment variable that overrides the plugins directory
+const DirEnv = "POSTIE_PLUGINS_DIR"
+
+// Manifest describes a plugin and what it provides
+type Manifest struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Command     string   `json:"command"`              // Program to run, relative to the plugin directory or on PATH
+	Args        []string `json:"args,omitempty"`       // Arguments passed to the program
+	Auth        []string `json:"auth,omitempty"`       // Auth schemes used with # @auth <scheme>
+	Variables   []string `json:"variables,omitempty"`  // Prefixes of {{$prefix...}} variables
+	Middleware  bool     `json:"middleware,omitempty"` // Run before every request
+}
+
+// Plugin is an installed plugin
+type Plugin struct {
+	Manifest
+	Dir string `json:"dir"` // Directory holding the manifest
+}
+
+// Dir returns the plugins directory: $POSTIE_PLUGINS_DIR, or
+// ~/.postie/plugins
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the plugins directory: %w", err)
+	}
+	return filepath.Join(home, ".postie", "plugins"), nil
+}
+
+// Load reads the plugin in dir
+func Load(dir string) (*Plugin, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("%s: name is required", filepath.Join(dir, ManifestFile))
+	}
+	if manifest.Command == "" {
+		return nil, fmt.Errorf("%s: command is required", filepath.Join(dir, ManifestFile))
+	}
+	return &Plugin{Manifest: manifest, Dir: dir}, nil
+}
+
+// Discover loads the plugins in the subdirectories of dir, sorted by name.
+// A missing directory has no plugins.
+func Discover(dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		plugin, err := Load(filepath.Join(dir, entry.Name()))
+		if os.IsNotExist(err) {
+			continue // Not a plugin
+		}
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// Provides summarises what a plugin provides, such as "auth: hmac"
+func (p *Plugin) Provides() string {
+	var provides []string
+	if len(p.Auth) > 0 {
+		provides = append(provides, "auth: "+strings.Join(p.Auth, ", "))
+	}
+	if len(p.Variables) > 0 {
+		provides = append(provides, "variables: $"+strings.Join(p.Variables, ", $"))
+	}
+	if p.Middleware {
+		provides = append(provides, "middleware")
+	}
+	return strings.Join(provides, "; ")
+}
+
+// Install copies the plugin in source, a directory with a plugin.json, to
+// a subdirectory of dir named after the plugin. An installed plugin of the
+// same name is only replaced if force is true.
+func Install(source, dir string, force bool) (*Plugin, error) {
+	plugin, err := Load(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s is not a plugin: %s not found", source, ManifestFile)
+		}
+		return nil, err
+	}
+	if strings.ContainsAny(plugin.Name, `/\`) || plugin.Name == "." || plugin.Name == ".." {
+		return nil, fmt.Errorf("invalid plugin name %q", plugin.Name)
+	}
+
+	target := filepath.Join(dir, plugin.Name)
+	if _, err := os.Stat(target); err == nil {
+		if !force {
+			return nil, fmt.Errorf("plugin %s is already installed in %s (use --force to replace it)", plugin.Name, target)
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+	}
+
+	if err := copyDir(source, target); err != nil {
+		os.RemoveAll(target)
+		return nil, fmt.Errorf("failed to install plugin %s: %w", plugin.Name, err)
+	}
+	return Load(target)
+}
+
+// copyDir copies a directory tree, keeping file modes
+func copyDir(source, target string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil // Skip symlinks and special files
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"postie/pkg/environment"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+// script answers auth calls with a signed request, variable calls with the
+// variable's name and arguments, and fails other calls
+const script = `#!/bin/sh
+input=$(cat)
+case "$input" in
+*'"type":"auth"'*)
+	echo '{"request":{"method":"POST","url":"'"$TARGET"'/signed","headers":[{"name":"Authorization","value":"HMAC key-1"}],"body":"signed"}}' ;;
+*'"type":"variable"'*)
+	echo '{"value":"secret-value","secret":true}' ;;
+*)
+	echo '{"error":"unexpected call"}' ;;
+esac
+`
+
+// writePlugin creates a plugin in dir/name and returns its directory
+func writePlugin(t *testing.T, dir, name, manifest string) string {
+	t.Helper()
+	pluginDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(pluginDir, ManifestFile), []byte(manifest), 0644)
+	os.WriteFile(filepath.Join(pluginDir, "run.sh"), []byte(script), 0755)
+	return pluginDir
+}
+
+func TestDiscoverAndInstall(t *testing.T) {
+	source := writePlugin(t, t.TempDir(), "src", `{"name": "hmac", "version": "1.0.0", "command": "run.sh", "auth": ["hmac"], "variables": ["vault"]}`)
+
+	dir := filepath.Join(t.TempDir(), "plugins")
+	plugins, err := Discover(dir)
+	if err != nil || len(plugins) != 0 {
+		t.Fatalf("expected no plugins in a missing directory, got %v, %v", plugins, err)
+	}
+
+	installed, err := Install(source, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed.Dir != filepath.Join(dir, "hmac") {
+		t.Errorf("installed in %s", installed.Dir)
+	}
+	if info, err := os.Stat(filepath.Join(installed.Dir, "run.sh")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("program not copied as executable: %v", err)
+	}
+	if _, err := Install(source, dir, false); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("expected an error reinstalling without force, got %v", err)
+	}
+	if _, err := Install(source, dir, true); err != nil {
+		t.Errorf("reinstall with force: %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "not-a-plugin"), 0755)
+	plugins, err = Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || plugins[0].Name != "hmac" {
+		t.Fatalf("unexpected plugins: %v", plugins)
+	}
+	if provides := plugins[0].Provides(); provides != "auth: hmac; variables: $vault" {
+		t.Errorf("Provides() = %q", provides)
+	}
+
+	if _, err := Install(t.TempDir(), dir, false); err == nil || !strings.Contains(err.Error(), "not a plugin") {
+		t.Errorf("expected an error for a directory without plugin.json, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization")+" "+r.Header.Get("X-Secret"))
+	}))
+	defer server.Close()
+	t.Setenv("TARGET", server.URL)
+
+	dir := writePlugin(t, t.TempDir(), "hmac", `{"name": "hmac", "command": "run.sh", "auth": ["hmac"], "variables": ["vault"]}`)
+	plugin, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := executor.NewExecutor(&environment.ResolvedEnvironment{Name: "dev", Variables: map[string]interface{}{}}, nil)
+	Register(exec, []*Plugin{plugin}, "dev")
+
+	request := &httprequest.Request{
+		Method:   "GET",
+		URL:      &httprequest.URL{Raw: server.URL + "/original"},
+		Headers:  []httprequest.Header{{Name: "X-Secret", Value: "{{$vault db}}"}},
+		Metadata: map[string]string{httprequest.DirectiveAuth: "hmac key-1"},
+	}
+	if _, err := exec.ExecuteRequest(request); err != nil {
+		t.Fatalf("ExecuteRequest error: %v", err)
+	}
+	// The auth plugin replaced the request, including the header set by the
+	// variable provider
+	if len(got) != 1 || got[0] != "POST /signed HMAC key-1 " {
+		t.Errorf("unexpected request: %q", got)
+	}
+	if exec.Redactor().Redact("secret-value") != "[REDACTED]" {
+		t.Error("secret variable value not masked")
+	}
+
+	request.Metadata[httprequest.DirectiveAuth] = "oauth1"
+	if _, err := exec.ExecuteRequest(request); err == nil || !strings.Contains(err.Error(), `unknown auth scheme "oauth1"`) {
+		t.Errorf("expected an unknown scheme error, got %v", err)
+	}
+
+	output, err := plugin.Call(&Input{Type: CallMiddleware})
+	if err == nil || !strings.Contains(err.Error(), "unexpected call") {
+		t.Errorf("expected the plugin's error, got %v, %v", output, err)
+	}
+}
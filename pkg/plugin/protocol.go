@@ -0,0 +1,211 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"postie/pkg/auth"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+// CallTimeout is how long a plugin may take to answer one call
+var CallTimeout = 30 * time.Second
+
+// Call types
+const (
+	CallAuth       = "auth"       // Authenticate a request with a scheme
+	CallMiddleware = "middleware" // Change a request before it is sent
+	CallVariable   = "variable"   // Return the value of a {{$name}} variable
+)
+
+// Request is a request as plugins see it: expanded, before it is sent
+type Request struct {
+	Name     string               `json:"name,omitempty"`
+	Method   string               `json:"method"`
+	URL      string               `json:"url"`
+	Headers  []httprequest.Header `json:"headers,omitempty"`
+	Body     string               `json:"body,omitempty"`
+	Metadata map[string]string    `json:"metadata,omitempty"` // # @directives
+}
+
+// Input is the message written to a plugin's standard input
+type Input struct {
+	Type        string   `json:"type"`                  // auth, middleware or variable
+	Environment string   `json:"environment,omitempty"` // Active environment
+	Scheme      string   `json:"scheme,omitempty"`      // Auth scheme (auth)
+	Name        string   `json:"name,omitempty"`        // Variable name without the $ (variable)
+	Args        []string `json:"args,omitempty"`        // Words after the scheme or variable name
+	Request     *Request `json:"request,omitempty"`     // Request to change (auth, middleware)
+}
+
+// Output is the message a plugin writes to its standard output
+type Output struct {
+	Request *Request `json:"request,omitempty"` // Replaces the request; omit to leave it unchanged
+	Value   string   `json:"value,omitempty"`   // Variable value
+	Secret  bool     `json:"secret,omitempty"`  // Mask the value in output
+	Error   string   `json:"error,omitempty"`   // Fails the call
+}
+
+// Call runs the plugin's program with one message
+func (p *Plugin) Call(input *Input) (*Output, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CallTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.command(), p.Args...)
+	cmd.Dir = p.Dir
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin %s timed out after %s", p.Name, CallTimeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("plugin %s: %w: %s", p.Name, err, message)
+		}
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+
+	var output Output
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid output: %w", p.Name, err)
+	}
+	if output.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Name, output.Error)
+	}
+	return &output, nil
+}
+
+// command is the path of the plugin's program: a file in the plugin
+// directory, or a program on PATH
+func (p *Plugin) command() string {
+	if filepath.IsAbs(p.Command) {
+		return p.Command
+	}
+	local := filepath.Join(p.Dir, p.Command)
+	if _, err := os.Stat(local); err == nil {
+		if abs, err := filepath.Abs(local); err == nil {
+			return abs
+		}
+		return local
+	}
+	return p.Command
+}
+
+// Register adds plugins to an executor: their variable providers, and a
+// hook that applies # @auth schemes and middleware before each request.
+// Built-in schemes such as bearer are left to the executor.
+func Register(exec *executor.Executor, plugins []*Plugin, env string) {
+	schemes := make(map[string]*Plugin)
+	var middleware []*Plugin
+	for _, plugin := range plugins {
+		for _, scheme := range plugin.Auth {
+			schemes[scheme] = plugin
+		}
+		for _, prefix := range plugin.Variables {
+			exec.AddVariableProvider(prefix, variableProvider(plugin, env))
+		}
+		if plugin.Middleware {
+			middleware = append(middleware, plugin)
+		}
+	}
+	if len(schemes) == 0 && len(middleware) == 0 {
+		return
+	}
+
+	exec.AddHook(executor.HookFuncs{
+		BeforeRequestFunc: func(request *httprequest.Request) error {
+			if value, ok := request.Metadata[httprequest.DirectiveAuth]; ok && !isBuiltinAuth(value) {
+				fields := strings.Fields(value)
+				if len(fields) == 0 {
+					return fmt.Errorf("@%s needs a scheme", httprequest.DirectiveAuth)
+				}
+				plugin, ok := schemes[fields[0]]
+				if !ok {
+					return fmt.Errorf("unknown auth scheme %q (see postie plugin list)", fields[0])
+				}
+				input := &Input{Type: CallAuth, Environment: env, Scheme: fields[0], Args: fields[1:]}
+				if err := callWithRequest(plugin, input, request); err != nil {
+					return err
+				}
+			}
+
+			for _, plugin := range middleware {
+				if err := callWithRequest(plugin, &Input{Type: CallMiddleware, Environment: env}, request); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// isBuiltinAuth reports whether a # @auth directive uses one of the schemes
+// the executor applies itself
+func isBuiltinAuth(value string) bool {
+	fields := strings.Fields(value)
+	return len(fields) > 0 && auth.IsScheme(fields[0])
+}
+
+// variableProvider returns a provider that asks a plugin for values
+func variableProvider(plugin *Plugin, env string) executor.VariableProvider {
+	return func(name string, args []string) (string, bool, error) {
+		output, err := plugin.Call(&Input{Type: CallVariable, Environment: env, Name: name, Args: args})
+		if err != nil {
+			return "", false, err
+		}
+		return output.Value, output.Secret, nil
+	}
+}
+
+// callWithRequest sends a request to a plugin and applies the request it
+// returns
+func callWithRequest(plugin *Plugin, input *Input, request *httprequest.Request) error {
+	input.Request = &Request{
+		Name:     request.Name,
+		Method:   request.Method,
+		Headers:  request.Headers,
+		Metadata: request.Metadata,
+	}
+	if request.URL != nil {
+		input.Request.URL = request.URL.Raw
+	}
+	if request.Body != nil {
+		input.Request.Body = request.Body.Content
+	}
+
+	output, err := plugin.Call(input)
+	if err != nil || output.Request == nil {
+		return err
+	}
+
+	changed := output.Request
+	if changed.Method != "" {
+		request.Method = strings.ToUpper(changed.Method)
+	}
+	if changed.URL != "" && (request.URL == nil || changed.URL != request.URL.Raw) {
+		request.URL = &httprequest.URL{Raw: changed.URL}
+	}
+	request.Headers = changed.Headers
+	if request.Body != nil {
+		request.Body.Content = changed.Body
+	} else if changed.Body != "" {
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: changed.Body}
+	}
+	return nil
+}
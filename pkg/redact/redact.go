@@ -0,0 +1,83 @@
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in output
+const Mask = "[REDACTED]"
+
+// MinSecretLength is the shortest value that is treated as a secret.
+// Very short values ("1", "on") would mask unrelated text everywhere.
+const MinSecretLength = 4
+
+// Redactor masks known secret values in text. It is safe for concurrent
+// use.
+type Redactor struct {
+	mu       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// New creates a redactor for the given secret values
+func New(values ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(values...)
+	return r
+}
+
+// Add registers more secret values
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(r.values))
+	for _, v := range r.values {
+		seen[v] = true
+	}
+
+	for _, v := range values {
+		if len(v) < MinSecretLength || seen[v] {
+			continue
+		}
+		seen[v] = true
+		r.values = append(r.values, v)
+	}
+
+	// Longest first so a secret containing another is masked as a whole
+	sort.Slice(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+
+	pairs := make([]string, 0, len(r.values)*2)
+	for _, v := range r.values {
+		pairs = append(pairs, v, Mask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Empty returns true if there is nothing to redact
+func (r *Redactor) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.values) == 0
+}
+
+// Redact masks all secret values in s. A nil redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
@@ -0,0 +1,52 @@
+package redact
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	r := New("secret-token", "secret-token-long", "abc")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Bearer secret-token", "Bearer [REDACTED]"},
+		{"key=secret-token-long&x=1", "key=[REDACTED]&x=1"},
+		{"abc is too short to mask", "abc is too short to mask"},
+		{"nothing here", "nothing here"},
+	}
+
+	for _, tt := range tests {
+		if got := r.Redact(tt.input); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	if got := r.Redact("secret-token"); got != "secret-token" {
+		t.Errorf("nil redactor changed input: %q", got)
+	}
+	if !r.Empty() {
+		t.Error("nil redactor should be empty")
+	}
+}
+
+func TestRedactorConcurrent(t *testing.T) {
+	r := New()
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			r.Add("secret-token")
+			r.Redact("Bearer secret-token")
+			r.Empty()
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	if got := r.Redact("Bearer secret-token"); got != "Bearer [REDACTED]" {
+		t.Errorf("Redact() = %q", got)
+	}
+}
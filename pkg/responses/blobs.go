@@ -0,0 +1,140 @@
+package responses
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobsDir is the directory under BaseDir holding deduplicated bodies
+const blobsDir = ".blobs"
+
+// blobRefPrefix prefixes the hash in StoredResponse.BodyRef
+const blobRefPrefix = "sha256:"
+
+// blobPath returns the file holding the body referenced by ref
+func (s *Storage) blobPath(ref string) (string, error) {
+	hash := strings.TrimPrefix(ref, blobRefPrefix)
+	if hash == ref || len(hash) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid body reference: %s", ref)
+	}
+	return filepath.Join(s.config.BaseDir, blobsDir, hash[:2], hash), nil
+}
+
+// writeBlob stores body under its sha256 hash, unless an identical body is
+// already stored, and returns its reference
+func (s *Storage) writeBlob(body string) (string, error) {
+	sum := sha256.Sum256([]byte(body))
+	ref := blobRefPrefix + hex.EncodeToString(sum[:])
+
+	path, err := s.blobPath(ref)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ref, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	// Write to a temporary file first so a partial blob is never referenced
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write response body: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write response body: %w", err)
+	}
+	if _, err := tmp.WriteString(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write response body: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write response body: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write response body: %w", err)
+	}
+
+	return ref, nil
+}
+
+// readBlob loads the body referenced by ref
+func (s *Storage) readBlob(ref string) (string, error) {
+	path, err := s.blobPath(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	return string(data), nil
+}
+
+// blobSize returns the size of the body referenced by ref (0 if missing)
+func (s *Storage) blobSize(ref string) int64 {
+	path, err := s.blobPath(ref)
+	if err != nil {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// readBodyRef returns the body reference of a stored response file without
+// loading the body
+func readBodyRef(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var meta struct {
+		BodyRef string `json:"body_ref"`
+	}
+	if json.Unmarshal(data, &meta) != nil {
+		return ""
+	}
+	return meta.BodyRef
+}
+
+// pruneBlobs removes bodies no longer referenced by any of the given refs
+// and returns the number of bytes freed
+func (s *Storage) pruneBlobs(referenced map[string]bool) (int64, error) {
+	root := filepath.Join(s.config.BaseDir, blobsDir)
+	var freed int64
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || referenced[blobRefPrefix+info.Name()] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove unused response body: %w", err)
+		}
+		os.Remove(filepath.Dir(path))
+		freed += info.Size()
+		return nil
+	})
+
+	return freed, err
+}
@@ -0,0 +1,81 @@
+package responses
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageDeduplicatesBodies(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewStorage(&StorageConfig{BaseDir: dir, UseRequestName: true, UseTimestamp: true, BlobThreshold: 1024})
+
+	body := strings.Repeat("x", 4096)
+	var paths []string
+	for i, name := range []string{"First", "Second", "Small"} {
+		response := &StoredResponse{
+			RequestName: name,
+			Method:      "GET",
+			Timestamp:   time.Now().Add(time.Duration(i) * time.Second),
+			StatusCode:  200,
+			Body:        body,
+		}
+		if name == "Small" {
+			response.Body = "tiny"
+		}
+		path, err := storage.Save(response)
+		if err != nil {
+			t.Fatalf("Save error: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	blobs, _ := filepath.Glob(filepath.Join(dir, blobsDir, "*", "*"))
+	if len(blobs) != 1 {
+		t.Fatalf("Expected one shared blob, got %v", blobs)
+	}
+
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), body) || !strings.Contains(string(data), `"body_ref": "sha256:`) {
+		t.Errorf("Expected the metadata to reference the body, got:\n%s", data)
+	}
+
+	for i, path := range paths {
+		loaded, err := storage.Load(path)
+		if err != nil {
+			t.Fatalf("Load error: %v", err)
+		}
+		if i < 2 && loaded.Body != body {
+			t.Errorf("Expected the deduplicated body to be loaded for %s", path)
+		}
+		if i == 2 && (loaded.Body != "tiny" || loaded.BodyRef != "") {
+			t.Errorf("Expected small bodies to stay inline, got %+v", loaded)
+		}
+	}
+
+	// The blob is removed with the last response referencing it
+	os.Remove(paths[0])
+	if _, err := storage.Collect(false); err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if _, err := os.Stat(blobs[0]); err != nil {
+		t.Error("Blob still referenced by the second response was removed")
+	}
+
+	os.Remove(paths[1])
+	result, err := storage.Collect(false)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if _, err := os.Stat(blobs[0]); !os.IsNotExist(err) {
+		t.Error("Expected the unreferenced blob to be removed")
+	}
+	if result.FreedBytes != int64(len(body)) {
+		t.Errorf("Expected %d bytes freed, got %d", len(body), result.FreedBytes)
+	}
+}
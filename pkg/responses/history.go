@@ -0,0 +1,58 @@
+package responses
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryRecord is a saved response listed by Recent
+type HistoryRecord struct {
+	ID          int       `json:"id"` // 1 is the most recent response
+	FilePath    string    `json:"file_path"`
+	RequestName string    `json:"request_name,omitempty"`
+	Method      string    `json:"method"`
+	RequestURL  string    `json:"request_url"`
+	StatusCode  int       `json:"status_code"`
+	Status      string    `json:"status"`
+	Timestamp   time.Time `json:"timestamp"`
+	Duration    int64     `json:"duration_ms"`
+}
+
+// Recent returns up to limit saved responses, most recent first, optionally
+// only those of the named request. A limit of 0 returns them all.
+func (s *Storage) Recent(requestName string, limit int) ([]HistoryRecord, error) {
+	files, err := s.storedFiles()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	var records []HistoryRecord
+	for _, file := range files {
+		if limit > 0 && len(records) == limit {
+			break
+		}
+
+		response, err := s.Load(file.path)
+		if err != nil {
+			continue // Skip invalid files
+		}
+		if requestName != "" && response.RequestName != requestName {
+			continue
+		}
+
+		records = append(records, HistoryRecord{
+			ID:          len(records) + 1,
+			FilePath:    file.path,
+			RequestName: response.RequestName,
+			Method:      response.Method,
+			RequestURL:  response.RequestURL,
+			StatusCode:  response.StatusCode,
+			Status:      response.Status,
+			Timestamp:   response.Timestamp,
+			Duration:    response.Duration,
+		})
+	}
+
+	return records, nil
+}
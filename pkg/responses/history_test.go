@@ -0,0 +1,37 @@
+package responses
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorageRecent(t *testing.T) {
+	storage := NewStorage(&StorageConfig{BaseDir: t.TempDir(), UseRequestName: true, UseTimestamp: true})
+	now := time.Now()
+
+	// Saved oldest first, one minute apart
+	for i, name := range []string{"login", "orders", "login"} {
+		path, err := storage.Save(&StoredResponse{RequestName: name, Method: "GET", RequestURL: "http://example.com/" + name, StatusCode: 200 + i, Timestamp: now.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatalf("Save error: %v", err)
+		}
+		modTime := now.Add(time.Duration(i-3) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := storage.Recent("", 0)
+	if err != nil {
+		t.Fatalf("Recent error: %v", err)
+	}
+	if len(records) != 3 || records[0].ID != 1 || records[0].StatusCode != 202 || records[2].StatusCode != 200 {
+		t.Errorf("Expected the most recent response first, got %+v", records)
+	}
+
+	records, _ = storage.Recent("login", 1)
+	if len(records) != 1 || records[0].StatusCode != 202 {
+		t.Errorf("Expected the latest login response, got %+v", records)
+	}
+}
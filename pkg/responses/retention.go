@@ -0,0 +1,253 @@
+package responses
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"postie/pkg/logging"
+)
+
+// CollectResult summarises a retention pass over the responses directory
+type CollectResult struct {
+	Removed        []string // Paths of removed response files
+	FreedBytes     int64
+	Remaining      int
+	RemainingBytes int64
+}
+
+// storedFile is a response file considered for removal
+type storedFile struct {
+	path    string
+	dir     string
+	size    int64
+	modTime time.Time
+	bodyRef string // Deduplicated body, counted once for all files sharing it
+}
+
+// HasRetention reports whether any retention limit is configured
+func (c *StorageConfig) HasRetention() bool {
+	return c.MaxHistoryPerReq > 0 || c.MaxAge > 0 || c.MaxTotalSize > 0
+}
+
+// Collect removes stored responses that exceed the configured limits:
+// responses older than MaxAge, then the oldest responses of each request
+// beyond MaxHistoryPerReq, then the oldest responses overall until the
+// directory fits in MaxTotalSize. With dryRun set nothing is deleted.
+func (s *Storage) Collect(dryRun bool) (*CollectResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.storedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first, so limits keep the most recent responses
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	remove := make(map[string]bool)
+
+	if s.config.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.config.MaxAge)
+		for _, file := range files {
+			if file.modTime.Before(cutoff) {
+				remove[file.path] = true
+			}
+		}
+	}
+
+	if s.config.MaxHistoryPerReq > 0 {
+		kept := make(map[string]int)
+		for _, file := range files {
+			if remove[file.path] {
+				continue
+			}
+			kept[file.dir]++
+			if kept[file.dir] > s.config.MaxHistoryPerReq {
+				remove[file.path] = true
+			}
+		}
+	}
+
+	if s.config.MaxTotalSize > 0 {
+		var total int64
+		counted := make(map[string]bool)
+		for _, file := range files {
+			if remove[file.path] {
+				continue
+			}
+			size := file.size
+			if file.bodyRef != "" && !counted[file.bodyRef] {
+				size += s.blobSize(file.bodyRef)
+			}
+			if total+size > s.config.MaxTotalSize {
+				remove[file.path] = true
+				continue
+			}
+			total += size
+			if file.bodyRef != "" {
+				counted[file.bodyRef] = true
+			}
+		}
+	}
+
+	result := &CollectResult{}
+	referenced := make(map[string]bool)
+	for _, file := range files {
+		if !remove[file.path] {
+			result.Remaining++
+			result.RemainingBytes += file.size
+			if file.bodyRef != "" && !referenced[file.bodyRef] {
+				referenced[file.bodyRef] = true
+				result.RemainingBytes += s.blobSize(file.bodyRef)
+			}
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove old response: %w", err)
+			}
+			// Drop the request directory once its last response is gone
+			if file.dir != s.config.BaseDir {
+				os.Remove(file.dir)
+			}
+		}
+		result.Removed = append(result.Removed, file.path)
+		result.FreedBytes += file.size
+	}
+
+	// Remove bodies no longer referenced by a kept response
+	if dryRun {
+		counted := make(map[string]bool)
+		for _, file := range files {
+			if remove[file.path] && file.bodyRef != "" && !referenced[file.bodyRef] && !counted[file.bodyRef] {
+				counted[file.bodyRef] = true
+				result.FreedBytes += s.blobSize(file.bodyRef)
+			}
+		}
+	} else {
+		freed, err := s.pruneBlobs(referenced)
+		result.FreedBytes += freed
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// collectInBackground applies the retention limits without blocking the
+// caller. Wait blocks until it has finished.
+func (s *Storage) collectInBackground() {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if _, err := s.Collect(false); err != nil {
+			logging.Warn("failed to apply response retention", "error", err)
+		}
+	}()
+}
+
+// Wait blocks until background retention passes started by Save finish
+func (s *Storage) Wait() {
+	s.pending.Wait()
+}
+
+// storedFiles lists the response files under the base directory
+func (s *Storage) storedFiles() ([]storedFile, error) {
+	var files []storedFile
+
+	err := filepath.Walk(s.config.BaseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == blobsDir && filepath.Dir(path) == filepath.Clean(s.config.BaseDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+
+		files = append(files, storedFile{
+			path:    path,
+			dir:     filepath.Dir(path),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			bodyRef: readBodyRef(path),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list responses: %w", err)
+	}
+
+	return files, nil
+}
+
+// ParseSize parses a size such as "500KB", "100MB" or "1.5GB" (powers of
+// 1024). A plain number is a size in bytes.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"G", 1 << 30},
+		{"M", 1 << 20},
+		{"K", 1 << 10},
+		{"B", 1},
+	}
+
+	factor := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q (use a value such as 100MB)", s)
+	}
+	return int64(number * factor), nil
+}
+
+// ParseAge parses a duration such as "12h" or "30m", also accepting a
+// number of days such as "7d"
+func ParseAge(s string) (time.Duration, error) {
+	value := strings.TrimSpace(s)
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q (use a value such as 7d or 12h)", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q (use a value such as 7d or 12h)", s)
+	}
+	return age, nil
+}
@@ -0,0 +1,93 @@
+package responses
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{
+		"512":   512,
+		"10KB":  10 << 10,
+		"1.5mb": 3 << 19,
+		"2G":    2 << 30,
+	}
+	for input, expected := range sizes {
+		if got, err := ParseSize(input); err != nil || got != expected {
+			t.Errorf("ParseSize(%q) = %d, %v; expected %d", input, got, err, expected)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+
+	if got, err := ParseAge("7d"); err != nil || got != 7*24*time.Hour {
+		t.Errorf("ParseAge(7d) = %v, %v", got, err)
+	}
+	if got, err := ParseAge("90m"); err != nil || got != 90*time.Minute {
+		t.Errorf("ParseAge(90m) = %v, %v", got, err)
+	}
+}
+
+func TestStorageCollect(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// Three responses for "a" and one old response for "b", one minute apart
+	write := func(name string, age time.Duration, size int) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a1 := write("a/1.json", 3*time.Minute, 100)
+	a2 := write("a/2.json", 2*time.Minute, 100)
+	a3 := write("a/3.json", 1*time.Minute, 100)
+	b1 := write("b/1.json", 48*time.Hour, 100)
+
+	storage := NewStorage(&StorageConfig{BaseDir: dir, MaxHistoryPerReq: 2, MaxAge: 24 * time.Hour})
+
+	result, err := storage.Collect(true)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if len(result.Removed) != 2 || result.Remaining != 2 {
+		t.Fatalf("Expected 2 removals and 2 remaining, got %+v", result)
+	}
+	if _, err := os.Stat(a1); err != nil {
+		t.Error("Dry run should not delete files")
+	}
+
+	if _, err := storage.Collect(false); err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	for _, path := range []string{a1, b1} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b")); !os.IsNotExist(err) {
+		t.Error("Expected the empty request directory to be removed")
+	}
+
+	// The total size limit removes the oldest remaining response
+	storage = NewStorage(&StorageConfig{BaseDir: dir, MaxTotalSize: 150})
+	if _, err := storage.Collect(false); err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if _, err := os.Stat(a2); !os.IsNotExist(err) {
+		t.Error("Expected the oldest response to be removed by the size limit")
+	}
+	if _, err := os.Stat(a3); err != nil {
+		t.Error("Expected the newest response to be kept")
+	}
+}
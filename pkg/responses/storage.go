@@ -6,11 +6,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // Storage handles saving and loading responses
 type Storage struct {
 	config *StorageConfig
+
+	mu      sync.Mutex     // Serializes saves and retention passes
+	pending sync.WaitGroup // Background retention passes
 }
 
 // NewStorage creates a new response storage
@@ -39,8 +43,23 @@ func (s *Storage) Save(response *StoredResponse) (string, error) {
 		return "", fmt.Errorf("failed to create response directory: %w", err)
 	}
 
+	// A retention pass must not remove a body before its metadata is written
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Large bodies are stored once and referenced by hash
+	stored := *response
+	if s.config.BlobThreshold > 0 && len(stored.Body) >= s.config.BlobThreshold {
+		ref, err := s.writeBlob(stored.Body)
+		if err != nil {
+			return "", err
+		}
+		stored.Body = ""
+		stored.BodyRef = ref
+	}
+
 	// Marshal response to JSON
-	data, err := json.MarshalIndent(response, "", "  ")
+	data, err := json.MarshalIndent(&stored, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal response: %w", err)
 	}
@@ -50,6 +69,10 @@ func (s *Storage) Save(response *StoredResponse) (string, error) {
 		return "", fmt.Errorf("failed to write response file: %w", err)
 	}
 
+	if s.config.HasRetention() {
+		s.collectInBackground()
+	}
+
 	return filePath, nil
 }
 
@@ -67,6 +90,14 @@ func (s *Storage) Load(filePath string) (*StoredResponse, error) {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	if response.BodyRef != "" {
+		body, err := s.readBlob(response.BodyRef)
+		if err != nil {
+			return nil, err
+		}
+		response.Body = body
+	}
+
 	return &response, nil
 }
 
@@ -1,20 +1,23 @@
 package responses
 
 import (
+	"sort"
 	"time"
 
 	"postie/pkg/client"
 	"postie/pkg/httprequest"
+	"postie/pkg/redact"
 )
 
 // StoredResponse represents a saved response with metadata
 type StoredResponse struct {
 	// Metadata
-	RequestName string    `json:"request_name,omitempty"`
-	RequestURL  string    `json:"request_url"`
-	Method      string    `json:"method"`
-	Timestamp   time.Time `json:"timestamp"`
-	Duration    int64     `json:"duration_ms"` // Duration in milliseconds
+	RequestName string                `json:"request_name,omitempty"`
+	RequestURL  string                `json:"request_url"`
+	Method      string                `json:"method"`
+	Timestamp   time.Time             `json:"timestamp"`
+	Duration    int64                 `json:"duration_ms"`       // Duration in milliseconds
+	Timings     *client.TimingsReport `json:"timings,omitempty"` // Time spent in each phase
 
 	// Request details
 	RequestHeaders map[string]string `json:"request_headers,omitempty"`
@@ -25,6 +28,7 @@ type StoredResponse struct {
 	Status        string            `json:"status"`
 	Headers       map[string]string `json:"headers"`
 	Body          string            `json:"body"`
+	BodyRef       string            `json:"body_ref,omitempty"` // sha256 reference to a deduplicated body
 	ContentType   string            `json:"content_type"`
 	ContentLength int64             `json:"content_length"`
 }
@@ -35,6 +39,11 @@ type StorageConfig struct {
 	UseRequestName   bool   // Organize by request name
 	UseTimestamp     bool   // Include timestamp in filename
 	MaxHistoryPerReq int    // Maximum number of responses to keep per request (0 = unlimited)
+	BlobThreshold    int    // Bodies of at least this many bytes are stored once by hash (0 = always inline)
+
+	// Retention limits applied after each save (0 = unlimited)
+	MaxTotalSize int64         // Maximum total size of stored responses in bytes
+	MaxAge       time.Duration // Maximum age of a stored response
 }
 
 // DefaultStorageConfig returns the default storage configuration
@@ -44,6 +53,7 @@ func DefaultStorageConfig() *StorageConfig {
 		UseRequestName:   true,
 		UseTimestamp:     true,
 		MaxHistoryPerReq: 10,
+		BlobThreshold:    1024,
 	}
 }
 
@@ -111,6 +121,7 @@ func FromClientResponse(response *client.Response, request *httprequest.Request,
 		Method:         request.Method,
 		Timestamp:      time.Now(),
 		Duration:       duration.Milliseconds(),
+		Timings:        response.Timings().Report(),
 		RequestHeaders: reqHeaders,
 		RequestBody:    reqBody,
 		StatusCode:     response.StatusCode,
@@ -121,3 +132,45 @@ func FromClientResponse(response *client.Response, request *httprequest.Request,
 		ContentLength:  response.ContentLength,
 	}, nil
 }
+
+// Request rebuilds the request that produced the stored response, as it
+// was sent. Headers are sorted by name.
+func (r *StoredResponse) Request() *httprequest.Request {
+	request := &httprequest.Request{
+		Name:   r.RequestName,
+		Method: r.Method,
+		URL:    &httprequest.URL{Raw: r.RequestURL},
+	}
+
+	names := make([]string, 0, len(r.RequestHeaders))
+	for name := range r.RequestHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request.Headers = append(request.Headers, httprequest.Header{Name: name, Value: r.RequestHeaders[name]})
+	}
+
+	if r.RequestBody != "" {
+		request.Body = &httprequest.RequestBody{Type: httprequest.BodyTypeInline, Content: r.RequestBody}
+		request.Body.ContentType = request.Body.GetContentType()
+	}
+	return request
+}
+
+// Redact masks secret values in the stored request and response
+func (r *StoredResponse) Redact(redactor *redact.Redactor) {
+	if redactor.Empty() {
+		return
+	}
+
+	r.RequestURL = redactor.Redact(r.RequestURL)
+	r.RequestBody = redactor.Redact(r.RequestBody)
+	r.Body = redactor.Redact(r.Body)
+	for key, value := range r.RequestHeaders {
+		r.RequestHeaders[key] = redactor.Redact(value)
+	}
+	for key, value := range r.Headers {
+		r.Headers[key] = redactor.Redact(value)
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"math"
+	"sort"
+)
+
+// Infer returns a JSON Schema describing the shape of a decoded JSON
+// value: the type of each value, and every property of an object as
+// required. Arrays are described by their first item, and nulls allow any
+// value.
+func Infer(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties := make(map[string]interface{}, len(v))
+		names := make([]string, 0, len(v))
+		for name, property := range v {
+			properties[name] = Infer(property)
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		inferred := map[string]interface{}{"type": "object", "properties": properties}
+		if len(names) > 0 {
+			// As encoding/json decodes it, so the schema validates
+			required := make([]interface{}, len(names))
+			for i, name := range names {
+				required[i] = name
+			}
+			inferred["required"] = required
+		}
+		return inferred
+	case []interface{}:
+		inferred := map[string]interface{}{"type": "array"}
+		if len(v) > 0 {
+			inferred["items"] = Infer(v[0])
+		}
+		return inferred
+	case string:
+		return map[string]interface{}{"type": "string"}
+	case float64:
+		if v == math.Trunc(v) {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	}
+	// A null may stand for a value of any type
+	return map[string]interface{}{}
+}
@@ -0,0 +1,249 @@
+package schema
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Spec is an OpenAPI 3 or Swagger 2 document in JSON form
+type Spec struct {
+	doc  map[string]interface{}
+	file string
+	docs *documents
+}
+
+// Operation is an operation of an OpenAPI spec
+type Operation struct {
+	ID     string
+	Method string
+	Path   string // Path template, e.g. /users/{id}
+
+	spec      *Spec
+	responses map[string]interface{}
+}
+
+// LoadSpec reads an OpenAPI spec. Only JSON specs are supported.
+func LoadSpec(path string) (*Spec, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	docs := &documents{files: make(map[string]interface{})}
+	doc, err := docs.load(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to load OpenAPI spec %s: not a JSON object", path)
+	}
+	if _, ok := object["paths"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("failed to load OpenAPI spec %s: no paths defined", path)
+	}
+	return &Spec{doc: object, file: abs, docs: docs}, nil
+}
+
+// operationMethods are the path item keys that hold operations
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Operations returns the spec's operations, ordered by path and method
+func (s *Spec) Operations() []*Operation {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+
+	templates := make([]string, 0, len(paths))
+	for template := range paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var operations []*Operation
+	for _, template := range templates {
+		item, _ := paths[template].(map[string]interface{})
+		for _, method := range operationMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := operation["operationId"].(string)
+			responses, _ := operation["responses"].(map[string]interface{})
+			operations = append(operations, &Operation{
+				ID:        id,
+				Method:    strings.ToUpper(method),
+				Path:      template,
+				spec:      s,
+				responses: responses,
+			})
+		}
+	}
+	return operations
+}
+
+// Operation finds an operation by its operationId
+func (s *Spec) Operation(id string) (*Operation, bool) {
+	for _, operation := range s.Operations() {
+		if operation.ID == id {
+			return operation, true
+		}
+	}
+	return nil, false
+}
+
+// FindOperation finds the operation for a request method and URL path.
+// The path may have a prefix the spec's paths don't, such as the base
+// path of a server. Literal path segments are preferred over parameters.
+func (s *Spec) FindOperation(method, path string) (*Operation, bool) {
+	segments := splitPath(path)
+
+	var best *Operation
+	bestScore := -1
+	for _, operation := range s.Operations() {
+		if operation.Method != strings.ToUpper(method) {
+			continue
+		}
+		template := splitPath(operation.Path)
+		if len(template) > len(segments) {
+			continue
+		}
+
+		// Compare against the end of the request path
+		tail := segments[len(segments)-len(template):]
+		score := 0
+		matched := true
+		for i, segment := range template {
+			switch {
+			case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			case segment == tail[i]:
+				score++
+			default:
+				matched = false
+			}
+			if !matched {
+				break
+			}
+		}
+		// Prefer longer templates, then more literal segments
+		score += 1000 * len(template)
+		if matched && score > bestScore {
+			best, bestScore = operation, score
+		}
+	}
+	return best, best != nil
+}
+
+// Name identifies the operation in messages
+func (o *Operation) Name() string {
+	if o.ID != "" {
+		return o.ID
+	}
+	return o.Method + " " + o.Path
+}
+
+// ResponseSchema returns the schema of the response body for a status code
+// and content type. documented is false if the operation doesn't describe
+// the status code at all; schema is nil if the response has no JSON body
+// schema to check.
+func (o *Operation) ResponseSchema(status int, contentType string) (schema *Schema, documented bool) {
+	code := strconv.Itoa(status)
+	response, ok := o.responses[code]
+	if !ok {
+		response, ok = o.responses[code[:1]+"XX"]
+	}
+	if !ok {
+		response, ok = o.responses["default"]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	responseObject, ok := o.spec.deref(response).(map[string]interface{})
+	if !ok {
+		return nil, true
+	}
+
+	// Swagger 2 puts the schema on the response
+	if node, ok := responseObject["schema"]; ok {
+		return o.spec.schema(node), true
+	}
+
+	content, _ := responseObject["content"].(map[string]interface{})
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, candidate := range []string{mediaType, wildcard(mediaType), "*/*"} {
+		if candidate == "" {
+			continue
+		}
+		media, ok := content[candidate].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if node, ok := media["schema"]; ok && isJSON(mediaType) {
+			return o.spec.schema(node), true
+		}
+		return nil, true
+	}
+	return nil, true
+}
+
+// ValidateResponse checks a response against the operation. A status code
+// the operation doesn't describe is a violation; bodies are checked only if
+// they are JSON and the spec gives a schema for them.
+func (o *Operation) ValidateResponse(status int, contentType string, body []byte) ([]Violation, error) {
+	schema, documented := o.ResponseSchema(status, contentType)
+	if !documented {
+		return []Violation{{Path: "$", Message: fmt.Sprintf("status %d is not documented for %s", status, o.Name())}}, nil
+	}
+	if schema == nil {
+		return nil, nil
+	}
+	return schema.ValidateJSON(body)
+}
+
+// schema wraps a node of the spec as a schema
+func (s *Spec) schema(node interface{}) *Schema {
+	return &Schema{node: node, root: s.doc, file: s.file, docs: s.docs}
+}
+
+// deref follows a local $ref, as used for shared response objects
+func (s *Spec) deref(node interface{}) interface{} {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return node
+	}
+	v := &validation{docs: s.docs}
+	target, _, _, err := v.resolve(ref, s.doc, s.file)
+	if err != nil {
+		return node
+	}
+	return target
+}
+
+// wildcard turns application/json into application/*
+func wildcard(mediaType string) string {
+	if kind, _, ok := strings.Cut(mediaType, "/"); ok {
+		return kind + "/*"
+	}
+	return ""
+}
+
+// isJSON reports whether a media type is JSON, such as application/json
+// or application/problem+json
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,588 @@
+// Package schema validates JSON values against JSON Schema and OpenAPI
+// response schemas.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRefDepth bounds $ref chains, so that self-referencing schemas fail
+// instead of recursing forever
+const maxRefDepth = 64
+
+// Schema is a JSON Schema, possibly part of a larger document such as an
+// OpenAPI spec. The keywords of drafts 4 to 2020-12 that describe data are
+// supported, along with OpenAPI 3.0's nullable; $ref may point into the same
+// document or to another file.
+type Schema struct {
+	node interface{} // Schema object or boolean
+	root interface{} // Document the schema belongs to, for $ref
+	file string      // Path of the document, for relative $refs ("" if none)
+	docs *documents
+}
+
+// Violation is a way in which a value doesn't match a schema
+type Violation struct {
+	Path    string `json:"path"` // Location in the value, e.g. $.users[0].id
+	Message string `json:"message"`
+}
+
+func (v Violation) Error() string {
+	return v.Path + ": " + v.Message
+}
+
+// documents caches schema files loaded through $ref
+type documents struct {
+	mu    sync.Mutex
+	files map[string]interface{}
+}
+
+func (d *documents) load(path string) (interface{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if doc, ok := d.files[path]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON in schema %s: %w", path, err)
+	}
+	d.files[path] = doc
+	return doc, nil
+}
+
+// Load reads a JSON Schema file
+func Load(path string) (*Schema, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	docs := &documents{files: make(map[string]interface{})}
+	doc, err := docs.load(abs)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{node: doc, root: doc, file: abs, docs: docs}, nil
+}
+
+// Parse parses a JSON Schema. Relative $refs to other files are resolved
+// against the working directory.
+func Parse(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return New(doc), nil
+}
+
+// New creates a schema from a decoded JSON value
+func New(doc interface{}) *Schema {
+	return &Schema{node: doc, root: doc, docs: &documents{files: make(map[string]interface{})}}
+}
+
+// Validate checks a decoded JSON value, as produced by encoding/json,
+// against the schema
+func (s *Schema) Validate(value interface{}) []Violation {
+	v := &validation{docs: s.docs}
+	v.check(s.node, s.root, s.file, value, "$", 0)
+	return v.violations
+}
+
+// ValidateJSON decodes JSON and validates it against the schema
+func (s *Schema) ValidateJSON(data []byte) ([]Violation, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	return s.Validate(value), nil
+}
+
+// validation collects the violations found while validating a value
+type validation struct {
+	docs       *documents
+	violations []Violation
+}
+
+func (v *validation) fail(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check validates value against the schema node and reports violations
+func (v *validation) check(node, root interface{}, file string, value interface{}, path string, depth int) {
+	switch schema := node.(type) {
+	case bool:
+		if !schema {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		v.checkObject(schema, root, file, value, path, depth)
+	}
+}
+
+func (v *validation) checkObject(schema map[string]interface{}, root interface{}, file string, value interface{}, path string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			v.fail(path, "$ref %s nests too deeply", ref)
+			return
+		}
+		target, targetRoot, targetFile, err := v.resolve(ref, root, file)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		v.check(target, targetRoot, targetFile, value, path, depth+1)
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return
+		}
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesAnyType(value, types) {
+		v.fail(path, "expected %s, got %s", strings.Join(types, " or "), typeName(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "%s is not one of %s", formatValue(value), formatValue(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		v.fail(path, "expected %s, got %s", formatValue(constant), formatValue(value))
+	}
+
+	switch typed := value.(type) {
+	case string:
+		v.checkString(schema, typed, path)
+	case float64:
+		v.checkNumber(schema, typed, path)
+	case []interface{}:
+		v.checkArray(schema, root, file, typed, path, depth)
+	case map[string]interface{}:
+		v.checkProperties(schema, root, file, typed, path, depth)
+	}
+
+	v.checkCombinators(schema, root, file, value, path, depth)
+}
+
+func (v *validation) checkString(schema map[string]interface{}, value, path string) {
+	length := len([]rune(value))
+	if min, ok := number(schema["minLength"]); ok && float64(length) < min {
+		v.fail(path, "length %d is less than %g", length, min)
+	}
+	if max, ok := number(schema["maxLength"]); ok && float64(length) > max {
+		v.fail(path, "length %d is more than %g", length, max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q in schema", pattern)
+		} else if !re.MatchString(value) {
+			v.fail(path, "%q does not match pattern %q", value, pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !matchesFormat(format, value) {
+		v.fail(path, "%q is not a valid %s", value, format)
+	}
+}
+
+func (v *validation) checkNumber(schema map[string]interface{}, value float64, path string) {
+	if min, ok := number(schema["minimum"]); ok {
+		if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && value <= min {
+			v.fail(path, "%g is not greater than %g", value, min)
+		} else if value < min {
+			v.fail(path, "%g is less than the minimum %g", value, min)
+		}
+	}
+	if max, ok := number(schema["maximum"]); ok {
+		if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && value >= max {
+			v.fail(path, "%g is not less than %g", value, max)
+		} else if value > max {
+			v.fail(path, "%g is more than the maximum %g", value, max)
+		}
+	}
+	if min, ok := number(schema["exclusiveMinimum"]); ok && value <= min {
+		v.fail(path, "%g is not greater than %g", value, min)
+	}
+	if max, ok := number(schema["exclusiveMaximum"]); ok && value >= max {
+		v.fail(path, "%g is not less than %g", value, max)
+	}
+	if multiple, ok := number(schema["multipleOf"]); ok && multiple > 0 {
+		if quotient := value / multiple; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.fail(path, "%g is not a multiple of %g", value, multiple)
+		}
+	}
+}
+
+func (v *validation) checkArray(schema map[string]interface{}, root interface{}, file string, items []interface{}, path string, depth int) {
+	if min, ok := number(schema["minItems"]); ok && float64(len(items)) < min {
+		v.fail(path, "has %d items, fewer than %g", len(items), min)
+	}
+	if max, ok := number(schema["maxItems"]); ok && float64(len(items)) > max {
+		v.fail(path, "has %d items, more than %g", len(items), max)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if equal(items[i], items[j]) {
+					v.fail(path, "items %d and %d are equal", j, i)
+				}
+			}
+		}
+	}
+
+	// prefixItems (2020-12), or items as an array (earlier drafts), are
+	// positional; the remaining items match items or additionalItems
+	prefix, _ := schema["prefixItems"].([]interface{})
+	rest, hasRest := schema["items"]
+	if tuple, ok := rest.([]interface{}); ok {
+		prefix = tuple
+		rest, hasRest = schema["additionalItems"]
+	}
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if i < len(prefix) {
+			v.check(prefix[i], root, file, item, itemPath, depth)
+		} else if hasRest {
+			v.check(rest, root, file, item, itemPath, depth)
+		}
+	}
+
+	if contains, ok := schema["contains"]; ok {
+		for _, item := range items {
+			if len(v.sub(contains, root, file, item, path, depth)) == 0 {
+				return
+			}
+		}
+		v.fail(path, "no item matches the contains schema")
+	}
+}
+
+func (v *validation) checkProperties(schema map[string]interface{}, root interface{}, file string, object map[string]interface{}, path string, depth int) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := object[name]; !present {
+					v.fail(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+	if min, ok := number(schema["minProperties"]); ok && float64(len(object)) < min {
+		v.fail(path, "has %d properties, fewer than %g", len(object), min)
+	}
+	if max, ok := number(schema["maxProperties"]); ok && float64(len(object)) > max {
+		v.fail(path, "has %d properties, more than %g", len(object), max)
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	patterns, _ := schema["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := propertyPath(path, name)
+		matched := false
+		if property, ok := properties[name]; ok {
+			v.check(property, root, file, object[name], propertyPath, depth)
+			matched = true
+		}
+		for pattern, property := range patterns {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(name) {
+				v.check(property, root, file, object[name], propertyPath, depth)
+				matched = true
+			}
+		}
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.fail(path, "unexpected property %q", name)
+			} else {
+				v.check(additional, root, file, object[name], propertyPath, depth)
+			}
+		}
+	}
+}
+
+func (v *validation) checkCombinators(schema map[string]interface{}, root interface{}, file string, value interface{}, path string, depth int) {
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.check(sub, root, file, value, path, depth)
+		}
+	}
+	if any, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range any {
+			if len(v.sub(sub, root, file, value, path, depth)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "does not match any of the anyOf schemas")
+		}
+	}
+	if one, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		var first []Violation
+		for i, sub := range one {
+			violations := v.sub(sub, root, file, value, path, depth)
+			if len(violations) == 0 {
+				matches++
+			} else if i == 0 {
+				first = violations
+			}
+		}
+		switch {
+		case matches == 0 && len(one) == 1:
+			v.violations = append(v.violations, first...)
+		case matches == 0:
+			v.fail(path, "does not match any of the oneOf schemas")
+		case matches > 1:
+			v.fail(path, "matches %d of the oneOf schemas, expected exactly one", matches)
+		}
+	}
+	if not, ok := schema["not"]; ok && len(v.sub(not, root, file, value, path, depth)) == 0 {
+		v.fail(path, "must not match the schema in not")
+	}
+}
+
+// sub validates against a subschema without reporting its violations
+func (v *validation) sub(node, root interface{}, file string, value interface{}, path string, depth int) []Violation {
+	nested := &validation{docs: v.docs}
+	nested.check(node, root, file, value, path, depth)
+	return nested.violations
+}
+
+// resolve finds the schema a $ref points to: "#/components/schemas/User",
+// "user.json" or "common.json#/definitions/id"
+func (v *validation) resolve(ref string, root interface{}, file string) (interface{}, interface{}, string, error) {
+	location, fragment, _ := strings.Cut(ref, "#")
+	if location != "" {
+		if strings.Contains(location, "://") {
+			return nil, nil, "", fmt.Errorf("remote $ref %s is not supported", ref)
+		}
+		if !filepath.IsAbs(location) {
+			dir := "."
+			if file != "" {
+				dir = filepath.Dir(file)
+			}
+			location = filepath.Join(dir, location)
+		}
+		doc, err := v.docs.load(location)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		root, file = doc, location
+	}
+
+	node := root
+	if fragment == "" || fragment == "/" {
+		return node, root, file, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return nil, nil, "", fmt.Errorf("unsupported $ref %s", ref)
+	}
+	for _, token := range strings.Split(fragment[1:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch current := node.(type) {
+		case map[string]interface{}:
+			next, ok := current[token]
+			if !ok {
+				return nil, nil, "", fmt.Errorf("$ref %s not found", ref)
+			}
+			node = next
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, nil, "", fmt.Errorf("$ref %s not found", ref)
+			}
+			node = current[index]
+		default:
+			return nil, nil, "", fmt.Errorf("$ref %s not found", ref)
+		}
+	}
+	return node, root, file, nil
+}
+
+// schemaTypes reads the type keyword, a name or a list of names
+func schemaTypes(value interface{}) ([]string, bool) {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}, true
+	case []interface{}:
+		types := make([]string, 0, len(typed))
+		for _, t := range typed {
+			if name, ok := t.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+// typeName names the JSON type of a value
+func typeName(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if typed == math.Trunc(typed) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// formatValue renders a value as compact JSON for messages
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(data) > 80 {
+		return string(data[:77]) + "..."
+	}
+	return string(data)
+}
+
+func number(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// propertyPath appends a property name to a path, quoting names that
+// aren't identifiers
+func propertyPath(path, name string) string {
+	if identifierPattern.MatchString(name) {
+		return path + "." + name
+	}
+	return fmt.Sprintf("%s[%q]", path, name)
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+)
+
+// matchesFormat checks the common string formats. Unknown formats pass.
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	case "uri":
+		parsed, err := url.Parse(value)
+		return err == nil && parsed.Scheme != ""
+	case "hostname":
+		return len(value) <= 253 && hostnamePattern.MatchString(value)
+	case "ipv4":
+		parts := strings.Split(value, ".")
+		if len(parts) != 4 {
+			return false
+		}
+		for _, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 || n > 255 || (len(part) > 1 && part[0] == '0') {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
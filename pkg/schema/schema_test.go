@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "object",
+		"required": ["id", "email"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"email": {"type": "string", "format": "email"},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+			"manager": {"$ref": "#/$defs/person"}
+		},
+		"additionalProperties": false,
+		"$defs": {
+			"person": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}, "nullable": true}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := map[string]interface{}{
+		"id": 1.0, "email": "a@example.com", "role": "admin",
+		"tags": []interface{}{"x", "y"}, "manager": map[string]interface{}{"name": "Ann"},
+	}
+	if violations := s.Validate(valid); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+
+	invalid := map[string]interface{}{
+		"id": 1.5, "role": "guest", "tags": []interface{}{"x", "x"},
+		"manager": map[string]interface{}{}, "extra": true,
+	}
+	var messages []string
+	for _, violation := range s.Validate(invalid) {
+		messages = append(messages, violation.Error())
+	}
+	got := strings.Join(messages, "\n")
+	for _, want := range []string{
+		`$: missing required property "email"`,
+		`$: unexpected property "extra"`,
+		`$.id: expected integer, got number`,
+		`$.manager: missing required property "name"`,
+		`$.role: "guest" is not one of ["admin","user"]`,
+		`$.tags: items 0 and 1 are equal`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected violation %s, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s := New(map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "number", "multipleOf": 5.0},
+		},
+	})
+
+	if violations := s.Validate(10.0); len(violations) != 0 {
+		t.Errorf("Expected 10 to match, got %v", violations)
+	}
+	if violations := s.Validate(7.0); len(violations) != 1 {
+		t.Errorf("Expected 7 to match no schema, got %v", violations)
+	}
+	if violations := s.Validate(true); len(violations) != 1 {
+		t.Errorf("Expected true to match no schema, got %v", violations)
+	}
+}
+
+func TestLoadResolvesFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "common.json"), []byte(`{"definitions": {"id": {"type": "string", "format": "uuid"}}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "user.json"), []byte(`{"properties": {"id": {"$ref": "common.json#/definitions/id"}}}`), 0644)
+
+	s, err := Load(filepath.Join(dir, "user.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := s.ValidateJSON([]byte(`{"id": "not-a-uuid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Path != "$.id" {
+		t.Errorf("Expected a $.id violation, got %v", violations)
+	}
+}
+
+func TestSpecValidateResponse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(path, []byte(`{
+		"openapi": "3.0.3",
+		"paths": {
+			"/users/{id}": {
+				"get": {
+					"operationId": "getUser",
+					"responses": {
+						"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+						"4XX": {"$ref": "#/components/responses/Error"}
+					}
+				}
+			},
+			"/users/me": {"get": {"operationId": "getMe", "responses": {"204": {"description": "none"}}}}
+		},
+		"components": {
+			"schemas": {"User": {"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}},
+			"responses": {"Error": {"content": {"application/problem+json": {"schema": {"required": ["title"]}}}}}
+		}
+	}`), 0644)
+
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operation, ok := spec.FindOperation("GET", "/api/v1/users/42")
+	if !ok || operation.ID != "getUser" {
+		t.Fatalf("Expected getUser, got %+v", operation)
+	}
+	if operation, _ := spec.FindOperation("GET", "/users/me"); operation.ID != "getMe" {
+		t.Errorf("Expected the literal path to win, got %s", operation.ID)
+	}
+	if _, ok := spec.FindOperation("POST", "/users/42"); ok {
+		t.Error("Expected no POST operation")
+	}
+
+	violations, err := operation.ValidateResponse(200, "application/json; charset=utf-8", []byte(`{"id": "42"}`))
+	if err != nil || len(violations) != 1 || violations[0].Error() != "$.id: expected integer, got string" {
+		t.Errorf("Expected an id type violation, got %v (%v)", violations, err)
+	}
+
+	violations, _ = operation.ValidateResponse(404, "application/problem+json", []byte(`{}`))
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, `"title"`) {
+		t.Errorf("Expected the 4XX schema to apply, got %v", violations)
+	}
+
+	violations, _ = operation.ValidateResponse(500, "application/json", []byte(`{}`))
+	if len(violations) != 1 || violations[0].Message != "status 500 is not documented for getUser" {
+		t.Errorf("Expected an undocumented status violation, got %v", violations)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	var sample interface{}
+	json.Unmarshal([]byte(`{"id": 1, "name": "Ann", "score": 4.5, "tags": ["a"], "manager": null, "active": true}`), &sample)
+	s := New(Infer(sample))
+
+	if violations := s.Validate(sample); len(violations) != 0 {
+		t.Errorf("expected the sample to match its own schema, got %v", violations)
+	}
+
+	var other interface{}
+	json.Unmarshal([]byte(`{"id": "1", "name": "Bo", "score": 3, "tags": [1], "manager": {"id": 2}}`), &other)
+	violations := s.Validate(other)
+	var paths []string
+	for _, violation := range violations {
+		paths = append(paths, violation.Path)
+	}
+	if got := strings.Join(paths, " "); got != "$ $.id $.tags[0]" {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+}
@@ -0,0 +1,39 @@
+package scripting
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// EvaluateCondition runs a JavaScript expression, such as
+// response.body.status == "READY", with the same response, request, client
+// and env objects as a response handler, and reports whether its value is
+// truthy
+func EvaluateCondition(expression string, context *ScriptContext) (met bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			met, err = false, fmt.Errorf("script panic: %v", r)
+		}
+	}()
+
+	engine := NewEngine(context)
+	if timeout := context.Limits.Timeout; timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			engine.vm.Interrupt(fmt.Sprintf("condition timed out after %s", timeout))
+		})
+		defer timer.Stop()
+	}
+
+	value, err := engine.vm.RunString(expression)
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return false, fmt.Errorf("condition %q: %v", expression, interrupted.Value())
+		}
+		return false, fmt.Errorf("condition %q: %w", expression, err)
+	}
+	return value.ToBoolean(), nil
+}
@@ -2,12 +2,16 @@ package scripting
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 
 	"postie/pkg/client"
 	"postie/pkg/httprequest"
+	"postie/pkg/markup"
 )
 
 // Engine executes JavaScript response handler scripts
@@ -15,6 +19,13 @@ type Engine struct {
 	vm      *goja.Runtime
 	context *ScriptContext
 	results *ScriptExecutionResult
+	groups  []string // client.describe() names enclosing the current test
+
+	deadline time.Time // When the script is interrupted (zero for no limit)
+
+	httpCall func(goja.FunctionCall) goja.Value // http(), also used by follow()
+
+	droppedLogs int // client.log() entries over Limits.MaxLogEntries
 }
 
 // NewEngine creates a new JavaScript execution engine
@@ -30,10 +41,15 @@ func NewEngine(context *ScriptContext) *Engine {
 		},
 	}
 
+	if context.Limits.MaxCallStackSize > 0 {
+		engine.vm.SetMaxCallStackSize(context.Limits.MaxCallStackSize)
+	}
+
 	engine.setupClientAPI()
 	engine.setupResponseObject()
 	engine.setupRequestObject()
 	engine.setupEnvironmentVariables()
+	engine.setupHTTPFunction()
 
 	return engine
 }
@@ -46,15 +62,49 @@ func (e *Engine) Execute(script string) *ScriptExecutionResult {
 		}
 	}()
 
+	// Interrupt long-running scripts, such as infinite loops
+	if timeout := e.context.Limits.Timeout; timeout > 0 {
+		e.deadline = time.Now().Add(timeout)
+		timer := time.AfterFunc(timeout, func() {
+			e.vm.Interrupt(fmt.Sprintf("script timed out after %s", timeout))
+		})
+		defer timer.Stop()
+	}
+
 	_, err := e.vm.RunString(script)
 	if err != nil {
-		e.results.Error = fmt.Errorf("script execution error: %w", err)
+		var interrupted *goja.InterruptedError
+		var overflow *goja.StackOverflowError
+		if errors.As(err, &interrupted) {
+			e.results.Error = fmt.Errorf("script execution error: %v", interrupted.Value())
+		} else if errors.As(err, &overflow) {
+			e.results.Error = fmt.Errorf("script execution error: maximum call stack size of %d exceeded (%s)", e.context.Limits.MaxCallStackSize, strings.TrimSpace(overflow.String()))
+		} else {
+			e.results.Error = fmt.Errorf("script execution error: %w", err)
+		}
+	}
+
+	if e.droppedLogs > 0 {
+		e.results.Logs = append(e.results.Logs, fmt.Sprintf("... %d more log entries dropped", e.droppedLogs))
+	}
+
+	// Promise jobs have run by now; there is no event loop, so anything
+	// still pending can never finish
+	for _, test := range e.results.Tests {
+		if test.pending {
+			test.pending = false
+			test.Passed = false
+			test.Error = "async test did not complete"
+		}
 	}
 
 	// Copy globals back to context
 	if e.context.Globals != nil {
 		e.results.Globals = e.context.Globals.GetAll()
 	}
+	if e.context.EnvStore != nil {
+		e.results.EnvVars = e.context.EnvStore.GetAll()
+	}
 
 	return e.results
 }
@@ -79,17 +129,57 @@ func (e *Engine) setupClientAPI() {
 
 		result := &TestResult{
 			Name:   name,
+			Group:  strings.Join(e.groups, " > "),
 			Passed: true,
 		}
+		e.results.Tests = append(e.results.Tests, result)
 
 		// Execute the test function
-		_, err := testFunc(goja.Undefined())
+		start := time.Now()
+		value, err := testFunc(goja.Undefined())
+		result.Duration = time.Since(start)
 		if err != nil {
 			result.Passed = false
 			result.Error = err.Error()
+			return goja.Undefined()
+		}
+
+		// Async functions return a promise; the test settles with it
+		if promise, ok := value.Export().(*goja.Promise); ok {
+			e.awaitTest(result, value, promise, start)
+		}
+
+		return goja.Undefined()
+	})
+
+	// client.describe(name, function) groups the tests registered inside it
+	client.Set("describe", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 2 {
+			e.results.Error = fmt.Errorf("client.describe() requires 2 arguments: name and function")
+			return goja.Undefined()
+		}
+
+		name := call.Argument(0).String()
+		groupFunc, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			e.results.Error = fmt.Errorf("client.describe() second argument must be a function")
+			return goja.Undefined()
+		}
+
+		e.groups = append(e.groups, name)
+		_, err := groupFunc(goja.Undefined())
+		e.groups = e.groups[:len(e.groups)-1]
+
+		// A group that throws is reported as a failed test in the enclosing group
+		if err != nil {
+			e.results.Tests = append(e.results.Tests, &TestResult{
+				Name:   name,
+				Group:  strings.Join(e.groups, " > "),
+				Passed: false,
+				Error:  err.Error(),
+			})
 		}
 
-		e.results.Tests = append(e.results.Tests, result)
 		return goja.Undefined()
 	})
 
@@ -116,6 +206,9 @@ func (e *Engine) setupClientAPI() {
 		return goja.Undefined()
 	})
 
+	// client.assertSchema(schemaOrPath, value)
+	client.Set("assertSchema", e.assertSchema)
+
 	// client.log(...messages)
 	client.Set("log", func(call goja.FunctionCall) goja.Value {
 		messages := make([]string, len(call.Arguments))
@@ -123,76 +216,137 @@ func (e *Engine) setupClientAPI() {
 			messages[i] = arg.String()
 		}
 
+		if limit := e.context.Limits.MaxLogEntries; limit > 0 && len(e.results.Logs) >= limit {
+			e.droppedLogs++
+			return goja.Undefined()
+		}
+
 		logMessage := fmt.Sprint(messages)
 		e.results.Logs = append(e.results.Logs, logMessage)
 		return goja.Undefined()
 	})
 
-	// client.global object for global variables
-	global := e.vm.NewObject()
+	// client.global holds variables for the rest of the run
+	var globals variableStore
+	if e.context.Globals != nil {
+		globals = e.context.Globals
+	}
+	client.Set("global", e.newStoreObject("client.global", globals))
+
+	// client.env holds variables persisted for the current environment
+	var envStore variableStore
+	if e.context.EnvStore != nil {
+		envStore = e.context.EnvStore
+	}
+	client.Set("env", e.newStoreObject("client.env", envStore))
+
+	// client.listener returns the requests the run's listener received
+	if e.context.Listener != nil {
+		client.Set("listener", e.listenerObject())
+	}
 
-	// client.global.set(name, value)
-	global.Set("set", func(call goja.FunctionCall) goja.Value {
+	e.vm.Set("client", client)
+}
+
+// variableStore is the variable storage behind client.global and client.env
+type variableStore interface {
+	Set(name string, value interface{})
+	Get(name string) (interface{}, bool)
+	Clear(name string)
+	GetAll() map[string]interface{}
+}
+
+// newStoreObject creates a script object with set, get, clear and isEmpty
+// methods backed by store. A nil store ignores writes and reads as empty.
+func (e *Engine) newStoreObject(name string, store variableStore) *goja.Object {
+	object := e.vm.NewObject()
+
+	// set(name, value)
+	object.Set("set", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 2 {
-			e.results.Error = fmt.Errorf("client.global.set() requires 2 arguments: name and value")
+			e.results.Error = fmt.Errorf("%s.set() requires 2 arguments: name and value", name)
 			return goja.Undefined()
 		}
 
-		name := call.Argument(0).String()
-		value := call.Argument(1).Export()
-
-		if e.context.Globals != nil {
-			e.context.Globals.Set(name, value)
+		if store != nil {
+			store.Set(call.Argument(0).String(), call.Argument(1).Export())
 		}
 
 		return goja.Undefined()
 	})
 
-	// client.global.get(name)
-	global.Set("get", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) < 1 {
+	// get(name)
+	object.Set("get", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 || store == nil {
 			return goja.Undefined()
 		}
 
-		name := call.Argument(0).String()
-
-		if e.context.Globals != nil {
-			if value, exists := e.context.Globals.Get(name); exists {
-				return e.vm.ToValue(value)
-			}
+		if value, exists := store.Get(call.Argument(0).String()); exists {
+			return e.vm.ToValue(value)
 		}
 
 		return goja.Undefined()
 	})
 
-	// client.global.clear(name)
-	global.Set("clear", func(call goja.FunctionCall) goja.Value {
+	// clear(name)
+	object.Set("clear", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			return goja.Undefined()
 		}
 
-		name := call.Argument(0).String()
-
-		if e.context.Globals != nil {
-			e.context.Globals.Clear(name)
+		if store != nil {
+			store.Clear(call.Argument(0).String())
 		}
 
 		return goja.Undefined()
 	})
 
-	// client.global.isEmpty()
-	global.Set("isEmpty", func(call goja.FunctionCall) goja.Value {
-		if e.context.Globals == nil {
+	// isEmpty()
+	object.Set("isEmpty", func(call goja.FunctionCall) goja.Value {
+		if store == nil {
 			return e.vm.ToValue(true)
 		}
-
-		globals := e.context.Globals.GetAll()
-		return e.vm.ToValue(len(globals) == 0)
+		return e.vm.ToValue(len(store.GetAll()) == 0)
 	})
 
-	client.Set("global", global)
+	return object
+}
 
-	e.vm.Set("client", client)
+// awaitTest settles an async test when its promise resolves or rejects
+func (e *Engine) awaitTest(result *TestResult, value goja.Value, promise *goja.Promise, start time.Time) {
+	switch promise.State() {
+	case goja.PromiseStateFulfilled:
+		return
+	case goja.PromiseStateRejected:
+		result.Passed = false
+		result.Error = promise.Result().String()
+		return
+	}
+
+	result.pending = true
+	then, ok := goja.AssertFunction(value.ToObject(e.vm).Get("then"))
+	if !ok {
+		return
+	}
+
+	onFulfilled := func(goja.FunctionCall) goja.Value {
+		result.pending = false
+		result.Duration = time.Since(start)
+		return goja.Undefined()
+	}
+	onRejected := func(call goja.FunctionCall) goja.Value {
+		result.pending = false
+		result.Duration = time.Since(start)
+		result.Passed = false
+		result.Error = call.Argument(0).String()
+		return goja.Undefined()
+	}
+
+	if _, err := then(value, e.vm.ToValue(onFulfilled), e.vm.ToValue(onRejected)); err != nil {
+		result.pending = false
+		result.Passed = false
+		result.Error = err.Error()
+	}
 }
 
 // setupResponseObject sets up the response object in the script context
@@ -233,9 +387,128 @@ func (e *Engine) setupResponseObject() {
 	// response.contentType
 	response.Set("contentType", e.context.Response.ContentType())
 
+	// response.durationMs
+	response.Set("durationMs", float64(e.context.Response.Duration)/float64(time.Millisecond))
+
+	// response.header(name) returns the first value of a header (case-insensitive) or null
+	response.Set("header", func(call goja.FunctionCall) goja.Value {
+		values := e.context.Response.Header.Values(call.Argument(0).String())
+		if len(values) == 0 {
+			return goja.Null()
+		}
+		return e.vm.ToValue(values[0])
+	})
+
+	// response.json() parses the body once and returns the same object on later calls
+	var parsedJSON goja.Value
+	response.Set("json", func(call goja.FunctionCall) goja.Value {
+		if parsedJSON == nil {
+			parsedJSON = e.parseResponseJSON()
+		}
+		return parsedJSON
+	})
+
+	// response.jsonPath(path) evaluates a JSONPath expression against the body
+	response.Set("jsonPath", func(call goja.FunctionCall) goja.Value {
+		data, err := e.responseJSONData()
+		if err != nil {
+			panic(e.vm.NewTypeError(err.Error()))
+		}
+
+		value, found, err := EvalJSONPath(data, call.Argument(0).String())
+		if err != nil {
+			panic(e.vm.NewTypeError(err.Error()))
+		}
+		if !found {
+			return goja.Undefined()
+		}
+		return e.vm.ToValue(value)
+	})
+
+	// response.xpath(expr) and response.css(selector) query an XML or HTML
+	// body and return the text of the matching nodes
+	var document *markup.Node
+	query := func(find func(*markup.Node, string) ([]*markup.Node, error)) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			if document == nil {
+				parsed, err := e.responseDocument()
+				if err != nil {
+					panic(e.vm.NewTypeError(err.Error()))
+				}
+				document = parsed
+			}
+
+			nodes, err := find(document, call.Argument(0).String())
+			if err != nil {
+				panic(e.vm.NewTypeError(err.Error()))
+			}
+			texts := make([]interface{}, 0, len(nodes))
+			for _, node := range nodes {
+				texts = append(texts, node.Text())
+			}
+			return e.vm.ToValue(texts)
+		}
+	}
+	response.Set("xpath", query(markup.XPath))
+	response.Set("css", query(markup.Select))
+
+	// response.link(rel), links(), embedded(rel) and follow(rel) navigate
+	// JSON:API and HAL documents
+	e.setHypermediaFunctions(response, e.context.Response)
+
 	e.vm.Set("response", response)
 }
 
+// responseDocument parses the response body as HTML if the content type
+// says so, and otherwise as XML, falling back to HTML
+func (e *Engine) responseDocument() (*markup.Node, error) {
+	body, err := e.context.Response.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if strings.Contains(e.context.Response.ContentType(), "html") {
+		return markup.ParseHTML(body)
+	}
+	document, err := markup.ParseXML(body)
+	if err != nil {
+		if document, htmlErr := markup.ParseHTML(body); htmlErr == nil {
+			return document, nil
+		}
+		return nil, fmt.Errorf("response body is not valid XML or HTML: %w", err)
+	}
+	return document, nil
+}
+
+// responseJSONData decodes the response body as JSON
+func (e *Engine) responseJSONData() (interface{}, error) {
+	return responseJSON(e.context.Response)
+}
+
+// responseJSON decodes a response body as JSON
+func responseJSON(response *client.Response) (interface{}, error) {
+	body, err := response.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	return data, nil
+}
+
+// parseResponseJSON returns the response body as a JavaScript value, or
+// throws a TypeError if it isn't JSON
+func (e *Engine) parseResponseJSON() goja.Value {
+	data, err := e.responseJSONData()
+	if err != nil {
+		panic(e.vm.NewTypeError(err.Error()))
+	}
+	return e.vm.ToValue(data)
+}
+
 // setupRequestObject sets up the request object in the script context
 func (e *Engine) setupRequestObject() {
 	if e.context.Request == nil {
@@ -271,8 +544,9 @@ func (e *Engine) setupEnvironmentVariables() {
 	e.vm.Set("env", e.context.Env)
 }
 
-// ExecuteResponseHandler executes a response handler script
-func ExecuteResponseHandler(handler *httprequest.ResponseHandler, response *client.Response, request *httprequest.Request, env map[string]interface{}, globals *GlobalStore) *ScriptExecutionResult {
+// ExecuteResponseHandler executes a response handler script with the
+// request, response, variables and settings of context
+func ExecuteResponseHandler(handler *httprequest.ResponseHandler, context *ScriptContext) *ScriptExecutionResult {
 	if handler == nil {
 		return &ScriptExecutionResult{
 			Tests:      make([]*TestResult, 0),
@@ -282,13 +556,6 @@ func ExecuteResponseHandler(handler *httprequest.ResponseHandler, response *clie
 		}
 	}
 
-	context := &ScriptContext{
-		Request:  request,
-		Response: response,
-		Env:      env,
-		Globals:  globals,
-	}
-
 	engine := NewEngine(context)
 
 	// Execute inline script or load from file
@@ -0,0 +1,146 @@
+package scripting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"postie/pkg/atomicfile"
+)
+
+// EnvStoreFile is the client.env store, relative to the project directory
+var EnvStoreFile = filepath.Join(".postie", "env-store.json")
+
+// EnvStore holds the client.env variables of a single environment. Unlike
+// globals they are saved to disk between runs, keyed by environment name,
+// so a token stored while running against one environment is never visible
+// in another.
+type EnvStore struct {
+	*GlobalStore
+
+	path        string
+	environment string
+
+	mu      sync.Mutex
+	changed bool
+}
+
+// LoadEnvStore reads the variables stored for environment from
+// .postie/env-store.json in dir. A missing file results in an empty store.
+func LoadEnvStore(dir, environment string) (*EnvStore, error) {
+	store := &EnvStore{
+		GlobalStore: NewGlobalStore(),
+		path:        filepath.Join(dir, EnvStoreFile),
+		environment: environment,
+	}
+
+	all, err := store.readAll()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range all[environment] {
+		store.GlobalStore.Set(name, value)
+	}
+
+	return store, nil
+}
+
+// Environment returns the name of the environment the store belongs to
+func (s *EnvStore) Environment() string {
+	return s.environment
+}
+
+// Set sets a variable
+func (s *EnvStore) Set(name string, value interface{}) {
+	s.GlobalStore.Set(name, value)
+	s.markChanged()
+}
+
+// Clear removes a variable
+func (s *EnvStore) Clear(name string) {
+	s.GlobalStore.Clear(name)
+	s.markChanged()
+}
+
+// ClearAll removes all variables
+func (s *EnvStore) ClearAll() {
+	s.GlobalStore.ClearAll()
+	s.markChanged()
+}
+
+func (s *EnvStore) markChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changed = true
+}
+
+// Save writes the variables back to the store file if they changed,
+// leaving the entries of other environments untouched
+func (s *EnvStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.changed {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create env store directory: %w", err)
+	}
+
+	// Another postie process may be saving other environments
+	unlock, err := atomicfile.Lock(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	all, err := s.readAll()
+	if err != nil {
+		return err
+	}
+
+	variables := s.GetAll()
+	if len(variables) == 0 {
+		delete(all, s.environment)
+	} else {
+		all[s.environment] = variables
+	}
+
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal env store: %w", err)
+	}
+
+	// The store usually holds tokens, so keep it private to the user
+	if err := atomicfile.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write env store: %w", err)
+	}
+
+	s.changed = false
+	return nil
+}
+
+// readAll reads the variables of every environment from the store file
+func (s *EnvStore) readAll() (map[string]map[string]interface{}, error) {
+	all := make(map[string]map[string]interface{})
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("failed to read env store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse env store %s: %w", s.path, err)
+	}
+	if all == nil {
+		all = make(map[string]map[string]interface{})
+	}
+
+	return all, nil
+}
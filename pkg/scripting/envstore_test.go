@@ -0,0 +1,98 @@
+package scripting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvStorePersistsPerEnvironment(t *testing.T) {
+	dir := t.TempDir()
+
+	staging, err := LoadEnvStore(dir, "staging")
+	if err != nil {
+		t.Fatalf("LoadEnvStore error: %v", err)
+	}
+	staging.Set("token", "staging-token")
+	if err := staging.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	production, err := LoadEnvStore(dir, "production")
+	if err != nil {
+		t.Fatalf("LoadEnvStore error: %v", err)
+	}
+	if production.Has("token") {
+		t.Error("Variables stored for staging should not be visible in production")
+	}
+	production.Set("token", "production-token")
+	if err := production.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	reloaded, err := LoadEnvStore(dir, "staging")
+	if err != nil {
+		t.Fatalf("LoadEnvStore error: %v", err)
+	}
+	if got := reloaded.GetString("token"); got != "staging-token" {
+		t.Errorf("Expected staging-token after reload, got %q", got)
+	}
+
+	// Clearing the last variable removes the environment from the file
+	reloaded.Clear("token")
+	if err := reloaded.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, EnvStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "{\n  \"production\": {\n    \"token\": \"production-token\"\n  }\n}" {
+		t.Errorf("Unexpected store file:\n%s", got)
+	}
+}
+
+func TestEnvStoreScriptAPI(t *testing.T) {
+	store, err := LoadEnvStore(t.TempDir(), "development")
+	if err != nil {
+		t.Fatalf("LoadEnvStore error: %v", err)
+	}
+
+	engine := NewEngine(&ScriptContext{EnvStore: store, Globals: NewGlobalStore()})
+	result := engine.Execute(`
+		client.env.set("token", "abc");
+		client.global.set("run", "only");
+		client.assert(client.env.get("token") === "abc", "env get");
+		client.assert(client.env.get("run") === undefined, "globals are separate");
+	`)
+	if !result.IsSuccess() {
+		t.Fatalf("Script failed: %v %v", result.Error, result.Assertions)
+	}
+	if result.EnvVars["token"] != "abc" {
+		t.Errorf("Expected token in result EnvVars, got %v", result.EnvVars)
+	}
+}
+
+func TestGlobalStoreConcurrentRecords(t *testing.T) {
+	store := NewGlobalStore()
+	done := make(chan map[string]interface{})
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			stop := store.Record()
+			store.Set(fmt.Sprintf("var%d", i), i)
+			store.GetAll()
+			done <- stop()
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		recorded := <-done
+		if len(recorded) == 0 {
+			t.Errorf("a recording missed the variable set during it: %v", recorded)
+		}
+	}
+	if len(store.GetAll()) != 4 {
+		t.Errorf("Expected 4 variables, got %v", store.GetAll())
+	}
+}
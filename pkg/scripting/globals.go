@@ -4,10 +4,13 @@ import (
 	"sync"
 )
 
-// GlobalStore manages global variables that persist across requests
+// GlobalStore manages global variables that persist across requests. It
+// is safe for concurrent use.
 type GlobalStore struct {
 	mu        sync.RWMutex
 	variables map[string]interface{}
+	recorders map[int]map[string]interface{} // Variables set since each active Record call
+	nextID    int
 }
 
 // NewGlobalStore creates a new global variable store
@@ -22,6 +25,31 @@ func (g *GlobalStore) Set(name string, value interface{}) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	g.variables[name] = value
+	for _, recorded := range g.recorders {
+		recorded[name] = value
+	}
+}
+
+// Record starts collecting the variables that are set. The returned
+// function stops recording and returns them. Several recordings may be
+// active at once.
+func (g *GlobalStore) Record() func() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.recorders == nil {
+		g.recorders = make(map[int]map[string]interface{})
+	}
+	id := g.nextID
+	g.nextID++
+	g.recorders[id] = make(map[string]interface{})
+
+	return func() map[string]interface{} {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		recorded := g.recorders[id]
+		delete(g.recorders, id)
+		return recorded
+	}
 }
 
 // Get retrieves a global variable
@@ -0,0 +1,218 @@
+package scripting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"postie/pkg/client"
+	"postie/pkg/logging"
+)
+
+const (
+	// DefaultHTTPCallLimit is the number of http() calls a script may make
+	DefaultHTTPCallLimit = 10
+	// DefaultHTTPTimeout applies to http() calls without a timeout option
+	DefaultHTTPTimeout = 10 * time.Second
+)
+
+// scriptHTTPRequest holds the options passed to http()
+type scriptHTTPRequest struct {
+	Method  string
+	URL     string
+	Headers map[string][]string
+	Body    string
+	Timeout time.Duration
+}
+
+// setupHTTPFunction exposes http(request) for fetching tokens or cleaning
+// up fixtures from a script. Calls are synchronous and limited in number.
+func (e *Engine) setupHTTPFunction() {
+	limit := e.context.HTTPCallLimit
+	if limit == 0 {
+		limit = DefaultHTTPCallLimit
+	}
+	calls := 0
+
+	e.httpCall = func(call goja.FunctionCall) goja.Value {
+		if limit > 0 && calls >= limit {
+			panic(e.vm.NewGoError(fmt.Errorf("http() call limit of %d reached", limit)))
+		}
+		calls++
+
+		request, err := e.parseHTTPRequest(call.Argument(0))
+		if err != nil {
+			panic(e.vm.NewTypeError(err.Error()))
+		}
+
+		response, err := e.doHTTPRequest(request)
+		if err != nil {
+			panic(e.vm.NewGoError(err))
+		}
+		return e.httpResponseObject(response)
+	}
+	e.vm.Set("http", e.httpCall)
+}
+
+// parseHTTPRequest reads http() options: a URL string, or an object with
+// url, method, headers, body and timeout (milliseconds)
+func (e *Engine) parseHTTPRequest(arg goja.Value) (*scriptHTTPRequest, error) {
+	request := &scriptHTTPRequest{
+		Method:  "GET",
+		Headers: make(map[string][]string),
+		Timeout: DefaultHTTPTimeout,
+	}
+
+	if goja.IsUndefined(arg) || goja.IsNull(arg) {
+		return nil, fmt.Errorf("http() requires a URL or request object")
+	}
+
+	if url, ok := arg.Export().(string); ok {
+		request.URL = url
+		return request, nil
+	}
+
+	options := arg.ToObject(e.vm)
+	if v := options.Get("url"); v != nil && !goja.IsUndefined(v) {
+		request.URL = v.String()
+	}
+	if request.URL == "" {
+		return nil, fmt.Errorf("http() request requires a url")
+	}
+
+	if v := options.Get("method"); v != nil && !goja.IsUndefined(v) {
+		request.Method = strings.ToUpper(v.String())
+	}
+
+	if v := options.Get("headers"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		headers := v.ToObject(e.vm)
+		for _, name := range headers.Keys() {
+			switch value := headers.Get(name).Export().(type) {
+			case []interface{}:
+				for _, item := range value {
+					request.Headers[name] = append(request.Headers[name], fmt.Sprint(item))
+				}
+			default:
+				request.Headers[name] = append(request.Headers[name], fmt.Sprint(value))
+			}
+		}
+	}
+
+	if v := options.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if text, ok := v.Export().(string); ok {
+			request.Body = text
+		} else {
+			// Objects are sent as JSON
+			data, err := json.Marshal(v.Export())
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode http() body: %w", err)
+			}
+			request.Body = string(data)
+			if !hasHeader(request.Headers, "Content-Type") {
+				request.Headers["Content-Type"] = []string{"application/json"}
+			}
+		}
+	}
+
+	if v := options.Get("timeout"); v != nil && !goja.IsUndefined(v) {
+		if ms := v.ToInteger(); ms > 0 {
+			request.Timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return request, nil
+}
+
+// doHTTPRequest sends a script request through pkg/client
+func (e *Engine) doHTTPRequest(request *scriptHTTPRequest) (*client.Response, error) {
+	if e.context.CheckHTTP != nil {
+		if err := e.context.CheckHTTP(request.Method, request.URL); err != nil {
+			return nil, fmt.Errorf("http() %s %s: %w", request.Method, request.URL, err)
+		}
+	}
+
+	apiClient := client.NewClient(&client.Config{
+		Transport: logging.NewTraceTransport(client.SharedTransport(e.context.Transport)),
+	})
+
+	// Interrupting the script can't stop a call in progress, so calls end
+	// by the script's deadline
+	timeout := request.Timeout
+	if !e.deadline.IsZero() {
+		timeout = min(timeout, time.Until(e.deadline))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := apiClient.NewRequest(request.Method, request.URL).Context(ctx)
+	if request.Body != "" {
+		req.Text(request.Body)
+	}
+
+	// Set after the body so an explicit Content-Type replaces text/plain
+	for name, values := range request.Headers {
+		for i, value := range values {
+			if i == 0 {
+				req.Header(name, value)
+			} else {
+				req.AddHeader(name, value)
+			}
+		}
+	}
+
+	logging.Debug("script http call", "method", request.Method, "url", request.URL)
+	response, err := req.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("http() %s %s: %w", request.Method, request.URL, err)
+	}
+
+	// Read the body before the context is cancelled
+	if _, err := response.GetBody(); err != nil {
+		return nil, fmt.Errorf("http() %s %s: failed to read body: %w", request.Method, request.URL, err)
+	}
+	return response, nil
+}
+
+// httpResponseObject converts a response into the object http() returns
+func (e *Engine) httpResponseObject(response *client.Response) goja.Value {
+	result := e.vm.NewObject()
+	result.Set("status", response.StatusCode)
+	result.Set("statusText", response.Status)
+	result.Set("durationMs", float64(response.Duration)/float64(time.Millisecond))
+
+	headers := make(map[string]string)
+	for key, values := range response.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+	result.Set("headers", headers)
+
+	text, _ := response.Text()
+	result.Set("text", text)
+
+	// body is parsed JSON when possible, like response.body
+	var jsonBody interface{}
+	if err := json.Unmarshal([]byte(text), &jsonBody); err == nil {
+		result.Set("body", jsonBody)
+	} else {
+		result.Set("body", text)
+	}
+	e.setHypermediaFunctions(result, response)
+
+	return result
+}
+
+// hasHeader reports whether headers contains name, ignoring case
+func hasHeader(headers map[string][]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package scripting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPCallEndsByScriptDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	engine := NewEngine(&ScriptContext{
+		Env:     map[string]interface{}{},
+		Globals: NewGlobalStore(),
+		Limits:  Limits{Timeout: 200 * time.Millisecond},
+	})
+	start := time.Now()
+	result := engine.Execute(`http("` + server.URL + `");`)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected http() to stop at the script timeout, took %s", elapsed)
+	}
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "http()") {
+		t.Errorf("Expected the http() call to fail, got %v", result.Error)
+	}
+}
@@ -0,0 +1,194 @@
+package scripting
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/dop251/goja"
+
+	"postie/pkg/client"
+)
+
+// HypermediaLink returns the href of a link relation in a JSON:API
+// ("links": {"next": "..."}) or HAL ("_links": {"next": {"href": "..."}})
+// document, as written
+func HypermediaLink(data interface{}, rel string) (string, bool) {
+	href, ok := HypermediaLinks(data)[rel]
+	return href, ok
+}
+
+// HypermediaLinks returns the href of every link relation of a JSON:API or
+// HAL document. HAL links win over JSON:API links of the same name.
+func HypermediaLinks(data interface{}) map[string]string {
+	links := make(map[string]string)
+	document, ok := data.(map[string]interface{})
+	if !ok {
+		return links
+	}
+	for _, key := range []string{"links", "_links"} {
+		relations, ok := document[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for rel, link := range relations {
+			if href := linkHref(link); href != "" {
+				links[rel] = href
+			}
+		}
+	}
+	return links
+}
+
+// linkHref returns the target of a link: a string, an object with href, or
+// the first of an array of them
+func linkHref(link interface{}) string {
+	switch v := link.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		href, _ := v["href"].(string)
+		return href
+	case []interface{}:
+		if len(v) > 0 {
+			return linkHref(v[0])
+		}
+	}
+	return ""
+}
+
+// HypermediaEmbedded returns the resources embedded in a document under
+// rel: HAL's _embedded[rel], or for JSON:API the included resources that a
+// relationship of the primary data points to, or else those of type rel
+func HypermediaEmbedded(data interface{}, rel string) (interface{}, bool) {
+	document, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	if embedded, ok := document["_embedded"].(map[string]interface{}); ok {
+		if resources, ok := embedded[rel]; ok {
+			return resources, true
+		}
+	}
+
+	included, _ := document["included"].([]interface{})
+	if primary, ok := document["data"].(map[string]interface{}); ok {
+		relationships, _ := primary["relationships"].(map[string]interface{})
+		if relationship, ok := relationships[rel].(map[string]interface{}); ok {
+			if linkage, ok := relationship["data"]; ok {
+				return resolveLinkage(linkage, included), true
+			}
+		}
+	}
+
+	var resources []interface{}
+	for _, resource := range included {
+		if object, ok := resource.(map[string]interface{}); ok && object["type"] == rel {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, resources != nil
+}
+
+// resolveLinkage replaces JSON:API resource identifiers, {"type", "id"},
+// with the included resources they identify where there is one
+func resolveLinkage(linkage interface{}, included []interface{}) interface{} {
+	switch v := linkage.(type) {
+	case map[string]interface{}:
+		for _, resource := range included {
+			if object, ok := resource.(map[string]interface{}); ok && object["type"] == v["type"] && object["id"] == v["id"] {
+				return resource
+			}
+		}
+		return v
+	case []interface{}:
+		resources := make([]interface{}, len(v))
+		for i, identifier := range v {
+			resources[i] = resolveLinkage(identifier, included)
+		}
+		return resources
+	}
+	return linkage
+}
+
+// setHypermediaFunctions adds link(rel), links(), embedded(rel) and
+// follow(rel, [options]) to a response object. Links are resolved against
+// the URL the response came from, and follow() sends a GET to one with
+// http(), so it counts towards the http() call limit.
+func (e *Engine) setHypermediaFunctions(object *goja.Object, response *client.Response) {
+	resolve := func(href string) string {
+		if response.Request == nil || response.Request.URL == nil {
+			return href
+		}
+		target, err := url.Parse(href)
+		if err != nil {
+			return href
+		}
+		return response.Request.URL.ResolveReference(target).String()
+	}
+	var decoded interface{}
+	document := func() interface{} {
+		if decoded == nil {
+			data, err := responseJSON(response)
+			if err != nil {
+				panic(e.vm.NewTypeError(err.Error()))
+			}
+			decoded = data
+		}
+		return decoded
+	}
+
+	object.Set("link", func(call goja.FunctionCall) goja.Value {
+		href, ok := HypermediaLink(document(), call.Argument(0).String())
+		if !ok {
+			return goja.Null()
+		}
+		return e.vm.ToValue(resolve(href))
+	})
+
+	object.Set("links", func(call goja.FunctionCall) goja.Value {
+		links := HypermediaLinks(document())
+		resolved := make(map[string]interface{}, len(links))
+		for rel, href := range links {
+			resolved[rel] = resolve(href)
+		}
+		return e.vm.ToValue(resolved)
+	})
+
+	object.Set("embedded", func(call goja.FunctionCall) goja.Value {
+		resources, ok := HypermediaEmbedded(document(), call.Argument(0).String())
+		if !ok {
+			return goja.Undefined()
+		}
+		return e.vm.ToValue(resources)
+	})
+
+	object.Set("follow", func(call goja.FunctionCall) goja.Value {
+		rel := call.Argument(0).String()
+		href, ok := HypermediaLink(document(), rel)
+		if !ok {
+			panic(e.vm.NewTypeError(fmt.Sprintf("no %q link (links: %v)", rel, linkNames(document()))))
+		}
+
+		options := e.vm.NewObject()
+		if arg := call.Argument(1); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			given := arg.ToObject(e.vm)
+			for _, key := range given.Keys() {
+				options.Set(key, given.Get(key))
+			}
+		}
+		options.Set("url", resolve(href))
+		return e.httpCall(goja.FunctionCall{Arguments: []goja.Value{options}})
+	})
+}
+
+// linkNames returns the link relations of a document, sorted
+func linkNames(data interface{}) []string {
+	var names []string
+	for rel := range HypermediaLinks(data) {
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+	return names
+}
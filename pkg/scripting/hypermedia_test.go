@@ -0,0 +1,69 @@
+package scripting
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHypermediaLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     map[string]string
+	}{
+		{"JSON:API", `{"links":{"self":"/orders?page=1","next":"/orders?page=2","prev":null}}`, map[string]string{"self": "/orders?page=1", "next": "/orders?page=2"}},
+		{"JSON:API link objects", `{"links":{"next":{"href":"/orders?page=2","meta":{}}}}`, map[string]string{"next": "/orders?page=2"}},
+		{"HAL", `{"_links":{"self":{"href":"/orders"},"next":{"href":"/orders?page=2"},"item":[{"href":"/orders/1"},{"href":"/orders/2"}]}}`, map[string]string{"self": "/orders", "next": "/orders?page=2", "item": "/orders/1"}},
+		{"no links", `[1, 2]`, map[string]string{}},
+	}
+	for _, tt := range tests {
+		var data interface{}
+		if err := json.Unmarshal([]byte(tt.document), &data); err != nil {
+			t.Fatal(err)
+		}
+		if got := HypermediaLinks(data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHypermediaEmbedded(t *testing.T) {
+	document := `{
+		"data": {"type": "orders", "id": "1", "relationships": {
+			"customer": {"data": {"type": "people", "id": "9"}},
+			"items": {"data": [{"type": "products", "id": "3"}, {"type": "products", "id": "4"}]},
+			"coupon": {"data": null}
+		}},
+		"included": [
+			{"type": "people", "id": "9", "attributes": {"name": "Ada"}},
+			{"type": "products", "id": "3", "attributes": {"name": "Book"}}
+		],
+		"_embedded": {"shipments": [{"id": 7}]}
+	}`
+	var data interface{}
+	if err := json.Unmarshal([]byte(document), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if customer, ok := HypermediaEmbedded(data, "customer"); !ok || customer.(map[string]interface{})["attributes"] == nil {
+		t.Errorf("Expected the included customer, got %v", customer)
+	}
+	// Identifiers without an included resource are kept
+	items, _ := HypermediaEmbedded(data, "items")
+	if list := items.([]interface{}); len(list) != 2 || list[0].(map[string]interface{})["attributes"] == nil || list[1].(map[string]interface{})["attributes"] != nil {
+		t.Errorf("Unexpected items %v", items)
+	}
+	if coupon, ok := HypermediaEmbedded(data, "coupon"); !ok || coupon != nil {
+		t.Errorf("Expected an empty relationship, got %v, %v", coupon, ok)
+	}
+	if people, ok := HypermediaEmbedded(data, "people"); !ok || len(people.([]interface{})) != 1 {
+		t.Errorf("Expected included resources by type, got %v", people)
+	}
+	if shipments, ok := HypermediaEmbedded(data, "shipments"); !ok || len(shipments.([]interface{})) != 1 {
+		t.Errorf("Expected HAL embedded resources, got %v", shipments)
+	}
+	if _, ok := HypermediaEmbedded(data, "invoices"); ok {
+		t.Error("Expected nothing for an unknown relation")
+	}
+}
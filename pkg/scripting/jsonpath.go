@@ -0,0 +1,161 @@
+package scripting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment is one step of a JSONPath expression
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// EvalJSONPath evaluates a JSONPath expression such as "$.items[0].id" against
+// decoded JSON. It supports child names (.name and ['name']), array indexes
+// (negative ones count from the end) and the * wildcard. found is false if
+// the path doesn't match anything. Paths with a wildcard return a slice of
+// all matches.
+func EvalJSONPath(data interface{}, path string) (value interface{}, found bool, err error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	nodes := []interface{}{data}
+	multiple := false
+
+	for _, segment := range segments {
+		var next []interface{}
+		for _, node := range nodes {
+			next = append(next, segment.apply(node)...)
+		}
+		nodes = next
+		if segment.wildcard {
+			multiple = true
+		}
+	}
+
+	if multiple {
+		if nodes == nil {
+			nodes = []interface{}{}
+		}
+		return nodes, true, nil
+	}
+	if len(nodes) == 0 {
+		return nil, false, nil
+	}
+	return nodes[0], true, nil
+}
+
+// apply returns the values a segment selects from node
+func (s jsonPathSegment) apply(node interface{}) []interface{} {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			// Sorted by key so results are stable
+			keys := make([]string, 0, len(typed))
+			for key := range typed {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			values := make([]interface{}, 0, len(typed))
+			for _, key := range keys {
+				values = append(values, typed[key])
+			}
+			return values
+		}
+		if value, ok := typed[s.key]; ok && !s.isIndex {
+			return []interface{}{value}
+		}
+
+	case []interface{}:
+		if s.wildcard {
+			return typed
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(typed)
+			}
+			if index >= 0 && index < len(typed) {
+				return []interface{}{typed[index]}
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseJSONPath splits a JSONPath expression into segments
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+
+	var segments []jsonPathSegment
+	i := 1
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+			if i < len(path) && path[i] == '.' {
+				return nil, fmt.Errorf("invalid JSONPath %q: recursive descent (..) is not supported", path)
+			}
+			if i < len(path) && path[i] == '*' {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+				i++
+				continue
+			}
+
+			start := i
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty name at position %d", path, start)
+			}
+			segments = append(segments, jsonPathSegment{key: path[start:i]})
+
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed '['", path)
+			}
+			segment, err := parseJSONPathBracket(strings.TrimSpace(path[i+1 : i+end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %w", path, err)
+			}
+			segments = append(segments, segment)
+			i += end + 1
+
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q at position %d", path, path[i], i)
+		}
+	}
+
+	return segments, nil
+}
+
+// parseJSONPathBracket parses the inside of [...]: *, an index or a quoted name
+func parseJSONPathBracket(inner string) (jsonPathSegment, error) {
+	if inner == "*" {
+		return jsonPathSegment{wildcard: true}, nil
+	}
+
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+		return jsonPathSegment{key: inner[1 : len(inner)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonPathSegment{}, fmt.Errorf("unsupported selector [%s]", inner)
+	}
+	return jsonPathSegment{index: index, isIndex: true}, nil
+}
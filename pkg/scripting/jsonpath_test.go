@@ -0,0 +1,49 @@
+package scripting
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvalJSONPath(t *testing.T) {
+	var data interface{}
+	body := `{"items": [{"id": 1, "tags": ["a"]}, {"id": 2, "tags": []}], "meta": {"total": 2, "next page": "x"}}`
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{"$", data, true},
+		{"$.items[0].id", float64(1), true},
+		{"$.items[-1].id", float64(2), true},
+		{"$['meta']['next page']", "x", true},
+		{"$.items[*].id", []interface{}{float64(1), float64(2)}, true},
+		{"$.meta.*", []interface{}{"x", float64(2)}, true},
+		{"$.items[5]", nil, false},
+		{"$.missing.id", nil, false},
+		{"$.items[1].tags[*]", []interface{}{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found, err := EvalJSONPath(data, tt.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if found != tt.found || !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %v (found=%v), got %v (found=%v)", tt.expected, tt.found, value, found)
+			}
+		})
+	}
+
+	for _, path := range []string{"items", "$..id", "$.items[", "$.items[x]", "$."} {
+		if _, _, err := EvalJSONPath(data, path); err == nil {
+			t.Errorf("Expected error for %q", path)
+		}
+	}
+}
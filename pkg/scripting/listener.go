@@ -0,0 +1,92 @@
+package scripting
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"postie/pkg/listener"
+)
+
+// DefaultListenerWait is how long client.listener.wait() waits unless it
+// is given a timeout
+const DefaultListenerWait = 2 * time.Second
+
+// listenerObject creates client.listener, which gives scripts the requests
+// the run's listener received:
+//
+//	client.listener.url                  the listener's URL, public with --tunnel
+//	client.listener.requests()           the requests received so far
+//	client.listener.wait(count, [ms])    wait for count requests, and return them
+func (e *Engine) listenerObject() *goja.Object {
+	l := e.context.Listener
+	object := e.vm.NewObject()
+	object.Set("url", l.URL())
+
+	object.Set("requests", func(call goja.FunctionCall) goja.Value {
+		return e.callbackValues(l.Callbacks())
+	})
+
+	// Returns the requests received by the timeout even if there are fewer
+	// than count, so scripts can assert on how many arrived
+	object.Set("wait", func(call goja.FunctionCall) goja.Value {
+		count := 1
+		if len(call.Arguments) > 0 {
+			count = int(call.Argument(0).ToInteger())
+		}
+		timeout := DefaultListenerWait
+		if len(call.Arguments) > 1 {
+			timeout = time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+		}
+		// Leave the script time to check what arrived
+		if !e.deadline.IsZero() {
+			timeout = min(timeout, time.Until(e.deadline)-100*time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		callbacks, _ := l.Wait(ctx, count)
+		return e.callbackValues(callbacks)
+	})
+
+	return object
+}
+
+// callbackValues converts callbacks to script objects shaped like
+// response: method, path, query, headers, header(name), body and time
+func (e *Engine) callbackValues(callbacks []listener.Callback) goja.Value {
+	values := make([]interface{}, len(callbacks))
+	for i, callback := range callbacks {
+		object := e.vm.NewObject()
+		object.Set("method", callback.Method)
+		object.Set("path", callback.Path)
+		object.Set("query", callback.Query)
+		object.Set("time", callback.Time.Format(time.RFC3339Nano))
+
+		headers := make(map[string]string)
+		for key, values := range callback.Headers {
+			if len(values) > 0 {
+				headers[key] = values[0]
+			}
+		}
+		object.Set("headers", headers)
+		object.Set("header", func(call goja.FunctionCall) goja.Value {
+			value := http.Header(callback.Headers).Get(call.Argument(0).String())
+			if value == "" {
+				return goja.Null()
+			}
+			return e.vm.ToValue(value)
+		})
+
+		// Like response.body: parsed if it is JSON
+		if body := callback.JSON(); body != nil {
+			object.Set("body", body)
+		} else {
+			object.Set("body", callback.Body)
+		}
+		values[i] = object
+	}
+	return e.vm.ToValue(values)
+}
@@ -0,0 +1,82 @@
+package scripting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"postie/pkg/schema"
+)
+
+// maxSchemaMessages is how many schema violations an assertion message lists
+const maxSchemaMessages = 5
+
+// assertSchema implements client.assertSchema(schemaOrPath[, value]). The
+// value, or the response body if it's omitted, is checked against a JSON
+// Schema given as an object or as the path of a schema file.
+func (e *Engine) assertSchema(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(e.vm.NewGoError(fmt.Errorf("client.assertSchema() requires a schema or schema file path")))
+	}
+
+	s, err := e.schemaArgument(call.Argument(0))
+	if err != nil {
+		panic(e.vm.NewGoError(err))
+	}
+
+	var data interface{}
+	if len(call.Arguments) >= 2 {
+		data, err = exportJSON(call.Argument(1))
+	} else {
+		data, err = e.responseJSONData()
+	}
+	if err != nil {
+		panic(e.vm.NewTypeError(err.Error()))
+	}
+
+	violations := s.Validate(data)
+	if len(violations) == 0 {
+		return goja.Undefined()
+	}
+
+	messages := make([]string, 0, maxSchemaMessages+1)
+	for i, violation := range violations {
+		if i == maxSchemaMessages {
+			messages = append(messages, fmt.Sprintf("and %d more", len(violations)-i))
+			break
+		}
+		messages = append(messages, violation.Error())
+	}
+	assertErr := &AssertionError{Message: "Schema validation failed: " + strings.Join(messages, "; ")}
+	e.results.Assertions = append(e.results.Assertions, assertErr)
+	panic(e.vm.NewGoError(assertErr))
+}
+
+// schemaArgument reads a schema object, or loads the schema file a string
+// names (relative to the working directory)
+func (e *Engine) schemaArgument(value goja.Value) (*schema.Schema, error) {
+	if path, ok := value.Export().(string); ok {
+		return schema.Load(path)
+	}
+	data, err := exportJSON(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return schema.New(data), nil
+}
+
+// exportJSON converts a JavaScript value to the form encoding/json decodes
+// JSON into, so numbers are float64 and objects are maps
+func exportJSON(value goja.Value) (interface{}, error) {
+	encoded, err := json.Marshal(value.Export())
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,40 @@
+package scripting
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"postie/pkg/client"
+)
+
+func TestAssertSchema(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "user.json")
+	os.WriteFile(schemaFile, []byte(`{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`), 0644)
+
+	response := &client.Response{Response: &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"id": "7"}`)),
+	}}
+
+	engine := NewEngine(&ScriptContext{Response: response, Globals: NewGlobalStore()})
+	result := engine.Execute(`
+		client.test("inline schema", function() {
+			client.assertSchema({type: "array", minItems: 1}, [1, 2]);
+		});
+		client.test("body against file", function() {
+			client.assertSchema("` + filepath.ToSlash(schemaFile) + `");
+		});
+	`)
+
+	if len(result.Tests) != 2 || !result.Tests[0].Passed || result.Tests[1].Passed {
+		t.Fatalf("Expected only the second test to fail, got %+v", result.Tests)
+	}
+	if len(result.Assertions) != 1 || !strings.Contains(result.Assertions[0].Message, "$.id: expected integer, got string") {
+		t.Errorf("Expected the id violation to be reported, got %+v", result.Assertions)
+	}
+}
@@ -1,8 +1,11 @@
 package scripting
 
 import (
+	"time"
+
 	"postie/pkg/client"
 	"postie/pkg/httprequest"
+	"postie/pkg/listener"
 )
 
 // ScriptContext contains the context for script execution
@@ -11,15 +14,44 @@ type ScriptContext struct {
 	Response *client.Response
 	Env      map[string]interface{} // Environment variables
 	Globals  *GlobalStore           // Global variables (persist across requests)
+	EnvStore *EnvStore              // client.env variables (persist across runs, per environment)
+
+	HTTPCallLimit int    // Maximum http() calls per script (0 for DefaultHTTPCallLimit, negative for no limit)
+	Limits        Limits // Execution limits
+
+	Transport client.TransportConfig         // Connection settings of http() calls
+	CheckHTTP func(method, url string) error // Checks each http() call before it is sent, such as against the safe hosts (nil for none)
+
+	Listener *listener.Listener // Listener whose requests client.listener returns (nil for none)
+}
+
+// Limits bounds what a script can do so a buggy handler can't hang a run
+type Limits struct {
+	Timeout          time.Duration // Maximum run time (0 for no limit)
+	MaxLogEntries    int           // client.log() entries kept (0 for no limit)
+	MaxCallStackSize int           // Maximum JavaScript call depth (0 for no limit)
+}
+
+// DefaultLimits returns the limits used by http run
+func DefaultLimits() Limits {
+	return Limits{
+		Timeout:          5 * time.Second,
+		MaxLogEntries:    1000,
+		MaxCallStackSize: 10000,
+	}
 }
 
 // TestResult represents the result of a client.test() call
 type TestResult struct {
-	Name   string
-	Passed bool
-	Error  string
-	Line   int
-	Column int
+	Name     string
+	Group    string // Enclosing client.describe() names, joined with " > "
+	Passed   bool
+	Error    string
+	Duration time.Duration
+	Line     int
+	Column   int
+
+	pending bool // async test whose promise hasn't settled
 }
 
 // AssertionError represents a failed assertion
@@ -41,6 +73,7 @@ type ScriptExecutionResult struct {
 	Assertions []*AssertionError
 	Logs       []string
 	Globals    map[string]interface{}
+	EnvVars    map[string]interface{} // client.env variables after the script ran
 	Error      error
 }
 
@@ -0,0 +1,324 @@
+// Package sdk runs .http files from Go programs. It loads request files and
+// environments, executes requests and returns typed results without printing
+// anything; rendering is left to the caller.
+//
+//	runner, err := sdk.New(sdk.Options{Environment: "staging"})
+//	file, err := sdk.LoadFile("api.http")
+//	results, err := runner.Run(file, "")
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"postie/pkg/client"
+	"postie/pkg/environment"
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/middleware"
+	"postie/pkg/schema"
+	"postie/pkg/scripting"
+	"postie/pkg/session"
+)
+
+// Defaults used when Options fields are empty
+const (
+	DefaultEnvironment    = "development"
+	DefaultEnvFile        = "http-client.env.json"
+	DefaultPrivateEnvFile = "http-client.private.env.json"
+)
+
+// Options configures a Runner
+type Options struct {
+	Dir            string                 // Directory for environment files and the client.env store (default ".")
+	Environment    string                 // Environment to run against (default "development")
+	EnvFile        string                 // Public environment file, relative to Dir (default http-client.env.json)
+	PrivateEnvFile string                 // Private environment file, relative to Dir (default http-client.private.env.json)
+	Timeout        time.Duration          // Request timeout (0 for none, or the environment's timeout variable)
+	ScriptTimeout  time.Duration          // Response handler time limit (0 for the default, negative for none)
+	PersistEnv     bool                   // Load and save client.env variables in Dir
+	Sessions       bool                   // Save # @session logins in Dir and reuse them across runs
+	DotEnvFile     string                 // .env file, relative to Dir (default .env; missing files are ignored)
+	Variables      map[string]string      // Override environment variables, like --var
+	OpenAPI        string                 // OpenAPI spec to check responses against, relative to Dir, like --openapi
+	Transport      client.TransportConfig // Connection pooling; Runners with the same settings share connections
+	Hooks          []executor.Hook
+}
+
+// Runner executes requests against one environment. Global variables set
+// by response handlers are kept for the lifetime of the Runner.
+type Runner struct {
+	env  *environment.ResolvedEnvironment
+	exec *executor.Executor
+}
+
+// New loads the environment described by opts and creates a Runner
+func New(opts Options) (*Runner, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Environment == "" {
+		opts.Environment = DefaultEnvironment
+	}
+	if opts.EnvFile == "" {
+		opts.EnvFile = DefaultEnvFile
+	}
+	if opts.PrivateEnvFile == "" {
+		opts.PrivateEnvFile = DefaultPrivateEnvFile
+	}
+	if opts.DotEnvFile == "" {
+		opts.DotEnvFile = environment.DefaultDotEnvFile
+	}
+
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory: %w", err)
+	}
+
+	env, err := environment.NewLoader(dir).Load(&environment.EnvironmentConfig{
+		PublicFile:  opts.EnvFile,
+		PrivateFile: opts.PrivateEnvFile,
+		Environment: opts.Environment,
+		DotEnvFile:  opts.DotEnvFile,
+		Variables:   opts.Variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	config := &executor.ExecutorConfig{
+		Timeout:       opts.Timeout,
+		ScriptTimeout: opts.ScriptTimeout,
+		Hooks:         opts.Hooks,
+		ShowSecrets:   true,
+		Transport:     opts.Transport,
+	}
+	if opts.OpenAPI != "" {
+		path := opts.OpenAPI
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if config.OpenAPI, err = schema.LoadSpec(path); err != nil {
+			return nil, err
+		}
+	}
+	if opts.PersistEnv {
+		config.EnvStore, err = scripting.LoadEnvStore(dir, env.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Sessions {
+		config.Sessions = session.NewStore(dir, env.Name)
+	}
+
+	exec := executor.NewExecutor(env, config)
+	exec.AddHook(middleware.IdempotencyHook())
+
+	return &Runner{
+		env:  env,
+		exec: exec,
+	}, nil
+}
+
+// Environment returns the name of the environment the Runner uses
+func (r *Runner) Environment() string {
+	return r.env.Name
+}
+
+// Variables returns the resolved environment variables
+func (r *Runner) Variables() map[string]interface{} {
+	variables := make(map[string]interface{}, len(r.env.Variables))
+	for name, value := range r.env.Variables {
+		variables[name] = value
+	}
+	return variables
+}
+
+// Run executes the requests in file. An empty filter runs every request;
+// otherwise only requests matching the name or 1-based number are run.
+// Request failures are reported in each Result, not as an error.
+func (r *Runner) Run(file *File, filter string) ([]*Result, error) {
+	if file == nil {
+		return nil, fmt.Errorf("file cannot be nil")
+	}
+
+	executed, err := r.exec.ExecuteFile(file.parsed, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, len(executed))
+	for i, result := range executed {
+		results[i] = newResult(result)
+	}
+	return results, nil
+}
+
+// RunRequest executes the single request in file selected by name or
+// 1-based number
+func (r *Runner) RunRequest(file *File, nameOrNumber string) (*Result, error) {
+	if nameOrNumber == "" {
+		return nil, fmt.Errorf("request name or number is required")
+	}
+
+	results, err := r.Run(file, nameOrNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("%d requests match %q", len(results), nameOrNumber)
+	}
+	return results[0], nil
+}
+
+// File is a parsed .http file
+type File struct {
+	Path   string
+	parsed *httprequest.RequestsFile
+}
+
+// LoadFile reads and parses a .http file
+func LoadFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP file: %w", err)
+	}
+	return ParseFile(path, string(content))
+}
+
+// ParseFile parses .http content. name is used to resolve relative paths
+// and in error messages.
+func ParseFile(name, content string) (*File, error) {
+	parsed, err := httprequest.ParseFile(name, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTTP file: %w", err)
+	}
+	return &File{Path: name, parsed: parsed}, nil
+}
+
+// RequestInfo describes a request in a File
+type RequestInfo struct {
+	Number int // 1-based position in the file
+	Name   string
+	Method string
+	URL    string // URL before variable expansion
+}
+
+// Requests lists the requests in the file
+func (f *File) Requests() []RequestInfo {
+	requests := make([]RequestInfo, len(f.parsed.Requests))
+	for i, request := range f.parsed.Requests {
+		requests[i] = RequestInfo{
+			Number: i + 1,
+			Name:   request.Name,
+			Method: request.Method,
+		}
+		if request.URL != nil {
+			requests[i].URL = request.URL.Raw
+		}
+	}
+	return requests
+}
+
+// Result is the outcome of executing one request
+type Result struct {
+	Name       string
+	Method     string
+	URL        string // Expanded URL
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+
+	Tests            []TestResult
+	FailedAssertions []string
+	Logs             []string
+	ScriptError      error // Error running the response handler
+	Err              error // Error sending the request or reading the response
+
+	// SkipReason is set if a # @skip-if or # @only-env directive skipped the request
+	SkipReason string
+
+	// ExpectedStatus holds the statuses a # @expect directive allows, such
+	// as "201" or "2xx" (empty if the request has none)
+	ExpectedStatus string
+
+	statusFailed bool
+}
+
+// TestResult is the outcome of a client.test() call
+type TestResult struct {
+	Name     string
+	Group    string
+	Passed   bool
+	Error    string
+	Duration time.Duration
+}
+
+// Passed reports whether the request succeeded with an expected or
+// non-error status and its response handler tests and assertions passed
+func (r *Result) Passed() bool {
+	if r.Err != nil || r.ScriptError != nil || r.statusFailed || len(r.FailedAssertions) > 0 {
+		return false
+	}
+	for _, test := range r.Tests {
+		if !test.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// newResult converts an executor result
+func newResult(result *executor.ExecutionResult) *Result {
+	converted := &Result{
+		StatusCode: result.StatusCode,
+		Status:     result.Status,
+		Duration:   result.Duration,
+		Err:        result.Error,
+		SkipReason: result.SkipReason,
+
+		ExpectedStatus: result.ExpectedStatus.String(),
+		statusFailed:   !result.Skipped && result.StatusFailed(),
+	}
+
+	if result.Request != nil {
+		converted.Name = result.Request.Name
+		converted.Method = result.Request.Method
+		if result.Request.URL != nil {
+			converted.URL = result.Request.URL.Raw
+		}
+	}
+
+	if result.Response != nil {
+		converted.Header = result.Response.Header
+		if body, err := result.Response.GetBody(); err == nil {
+			converted.Body = body
+		}
+	}
+
+	if script := result.ScriptResult; script != nil {
+		for _, test := range script.Tests {
+			converted.Tests = append(converted.Tests, TestResult{
+				Name:     test.Name,
+				Group:    test.Group,
+				Passed:   test.Passed,
+				Error:    test.Error,
+				Duration: test.Duration,
+			})
+		}
+		for _, assertion := range script.Assertions {
+			converted.FailedAssertions = append(converted.FailedAssertions, assertion.Message)
+		}
+		converted.Logs = script.Logs
+		converted.ScriptError = script.Error
+	}
+
+	return converted
+}
@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunnerRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	envFile := fmt.Sprintf(`{"staging": {"host": %q}}`, server.URL)
+	if err := os.WriteFile(filepath.Join(dir, DefaultEnvFile), []byte(envFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := New(Options{Dir: dir, Environment: "staging"})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	file, err := ParseFile("api.http", "### List users\n"+
+		"GET {{host}}/users\n"+
+		"\n"+
+		"> {%\n"+
+		"    client.test(\"path\", function() {\n"+
+		"        client.assert(response.body.path === \"/users\", \"wrong path\");\n"+
+		"    });\n"+
+		"%}\n"+
+		"\n"+
+		"### Get user\n"+
+		"GET {{host}}/users/1\n")
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+
+	if requests := file.Requests(); len(requests) != 2 || requests[1].Name != "Get user" || requests[1].URL != "{{host}}/users/1" {
+		t.Fatalf("Unexpected requests: %+v", requests)
+	}
+
+	results, err := runner.Run(file, "")
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	first := results[0]
+	if !first.Passed() || first.StatusCode != 200 || first.URL != server.URL+"/users" {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+	if len(first.Tests) != 1 || !first.Tests[0].Passed {
+		t.Errorf("Expected one passing test, got %+v", first.Tests)
+	}
+
+	result, err := runner.RunRequest(file, "2")
+	if err != nil {
+		t.Fatalf("RunRequest error: %v", err)
+	}
+	if string(result.Body) != `{"path": "/users/1"}` {
+		t.Errorf("Unexpected body: %s", result.Body)
+	}
+}
@@ -0,0 +1,355 @@
+// Package selector implements the expressions that pick requests to run,
+// such as:
+//
+//	method==POST && name~"user" && tag in (smoke, auth)
+//
+// A comparison matches a field against a value, ignoring case: == and !=
+// compare whole values, ~ and !~ check for a substring, and in and "not
+// in" compare with each value of a list. Comparisons combine with &&,
+// || and !, and group with parentheses. Values are bare words or quoted
+// strings.
+//
+// A field can have several values, such as a request's tags. A comparison
+// matches if any of the values does, and its negation if none does.
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target holds the values of the fields an expression is matched against
+type Target map[string][]string
+
+// Selector is a parsed expression
+type Selector struct {
+	source string
+	root   node
+}
+
+// Parse parses an expression. If fields are given, the expression may only
+// use those fields.
+func Parse(expression string, fields ...string) (*Selector, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return &Selector{source: expression, root: root}, nil
+}
+
+// Match reports whether target matches the expression
+func (s *Selector) Match(target Target) bool {
+	return s.root.match(target)
+}
+
+// String returns the expression as given
+func (s *Selector) String() string {
+	return s.source
+}
+
+// node is a part of an expression
+type node interface {
+	match(target Target) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(t Target) bool { return n.left.match(t) && n.right.match(t) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(t Target) bool { return n.left.match(t) || n.right.match(t) }
+
+type notNode struct{ operand node }
+
+func (n notNode) match(t Target) bool { return !n.operand.match(t) }
+
+// comparison matches a field with ==, ~ or in. Negated operators are
+// parsed as a notNode around one of these.
+type comparison struct {
+	field    string
+	operator string
+	values   []string
+}
+
+func (c comparison) match(t Target) bool {
+	for _, actual := range t[c.field] {
+		for _, want := range c.values {
+			switch c.operator {
+			case "~":
+				if strings.Contains(strings.ToLower(actual), strings.ToLower(want)) {
+					return true
+				}
+			default:
+				if strings.EqualFold(actual, want) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// token is a word, a quoted string or an operator
+type token struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+func (t token) String() string {
+	if t.pos < 0 {
+		return t.text
+	}
+	if t.quoted {
+		return fmt.Sprintf("%q", t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// operators, longest first so "!=" isn't read as "!"
+var operators = []string{"&&", "||", "==", "!=", "!~", "~", "!", "(", ")", ","}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(expression) && expression[j] != c; j++ {
+				if expression[j] == '\\' && j+1 < len(expression) {
+					j++
+				}
+				text.WriteByte(expression[j])
+			}
+			if j >= len(expression) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, token{text: text.String(), quoted: true, pos: i})
+			i = j + 1
+			continue
+		}
+
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(expression[i:], op) {
+				tokens = append(tokens, token{text: op, pos: i})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		j := i
+		for j < len(expression) && !strings.ContainsRune(" \t\n\"'&|=!~(),", rune(expression[j])) {
+			j++
+		}
+		if j == i {
+			return nil, fmt.Errorf("unexpected %q at position %d", expression[i], i+1)
+		}
+		tokens = append(tokens, token{text: expression[i:j], pos: i})
+		i = j
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over the tokens
+type parser struct {
+	tokens []token
+	pos    int
+	fields []string
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{text: "end of expression", pos: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is the operator or keyword text
+func (p *parser) accept(text string) bool {
+	if !p.done() && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	position := p.peek().pos + 1
+	if p.done() {
+		// Just after the last token
+		position = len(p.tokens)
+		if position > 0 {
+			last := p.tokens[position-1]
+			position = last.pos + len(last.text) + 1
+		}
+	}
+	return fmt.Errorf("invalid selector at position %d: %s", position, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected ')', got %s", p.peek())
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	field := p.peek()
+	if p.done() || field.quoted || strings.ContainsAny(field.text, "&|=!~(),") {
+		return nil, p.errorf("expected a field name, got %s", field)
+	}
+	if err := p.checkField(field.text); err != nil {
+		return nil, err
+	}
+	p.pos++
+
+	negate := false
+	var c comparison
+	switch {
+	case p.accept("=="):
+		c = comparison{field: field.text, operator: "=="}
+	case p.accept("!="):
+		c, negate = comparison{field: field.text, operator: "=="}, true
+	case p.accept("~"):
+		c = comparison{field: field.text, operator: "~"}
+	case p.accept("!~"):
+		c, negate = comparison{field: field.text, operator: "~"}, true
+	case p.accept("in"):
+		c = comparison{field: field.text, operator: "in"}
+	case p.accept("not"):
+		if !p.accept("in") {
+			return nil, p.errorf("expected 'in' after 'not', got %s", p.peek())
+		}
+		c, negate = comparison{field: field.text, operator: "in"}, true
+	default:
+		return nil, p.errorf("expected ==, !=, ~, !~, in or not in after %s, got %s", field.text, p.peek())
+	}
+
+	if c.operator == "in" {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		c.values = values
+	} else {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.values = []string{value}
+	}
+
+	if negate {
+		return notNode{c}, nil
+	}
+	return c, nil
+}
+
+// parseList parses "(a, b, c)"
+func (p *parser) parseList() ([]string, error) {
+	if !p.accept("(") {
+		return nil, p.errorf("expected '(' to start a list, got %s", p.peek())
+	}
+	var values []string
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.accept(")") {
+			return values, nil
+		}
+		if !p.accept(",") {
+			return nil, p.errorf("expected ',' or ')', got %s", p.peek())
+		}
+	}
+}
+
+func (p *parser) parseValue() (string, error) {
+	value := p.peek()
+	if p.done() || (!value.quoted && strings.ContainsAny(value.text, "&|=!~(),")) {
+		return "", p.errorf("expected a value, got %s", value)
+	}
+	p.pos++
+	return value.text, nil
+}
+
+func (p *parser) checkField(name string) error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	for _, field := range p.fields {
+		if field == name {
+			return nil
+		}
+	}
+	return p.errorf("unknown field %q (available: %s)", name, strings.Join(p.fields, ", "))
+}
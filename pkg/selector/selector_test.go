@@ -0,0 +1,68 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectorMatch(t *testing.T) {
+	target := Target{
+		"name":   {"Create user"},
+		"method": {"POST"},
+		"tag":    {"smoke", "users"},
+		"index":  {"3"},
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`method==POST`, true},
+		{`method == post`, true},
+		{`method!=POST`, false},
+		{`name~"user"`, true},
+		{`name~'USER'`, true},
+		{`name!~user`, false},
+		{`tag in (smoke)`, true},
+		{`tag in (slow, nightly)`, false},
+		{`tag not in (slow)`, true},
+		{`method==POST && name~"user" && tag in (smoke)`, true},
+		{`method==GET || index==3`, true},
+		{`!(method==GET || index==4)`, true},
+		{`method==GET && index==3 || tag==users`, true},
+		{`method==GET && (index==3 || tag==users)`, false},
+		{`host==example.com`, false},
+		{`host!=example.com`, true},
+	}
+	for _, test := range tests {
+		selector, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", test.expression, err)
+			continue
+		}
+		if got := selector.Match(target); got != test.want {
+			t.Errorf("Match(%q) = %v, want %v", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	tests := map[string]string{
+		`method==`:          "expected a value",
+		`method POST`:       "expected ==",
+		`(method==POST`:     "expected ')'",
+		`name=="user`:       "unterminated string",
+		`tag in smoke`:      "expected '('",
+		`tag in (a b)`:      "expected ',' or ')'",
+		`method==POST &&`:   "expected a field name",
+		`method==POST name`: "unexpected 'name'",
+		`color==red`:        `unknown field "color"`,
+		`tag not (a)`:       "expected 'in' after 'not'",
+	}
+	for expression, want := range tests {
+		_, err := Parse(expression, "name", "method", "tag")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", expression, err, want)
+		}
+	}
+}
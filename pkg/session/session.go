@@ -0,0 +1,234 @@
+// Package session stores values captured by login requests, such as tokens
+// and cookies, so requests in other .http files can reuse them as
+// {{session.<name>.<key>}} without logging in on every run.
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"postie/pkg/atomicfile"
+	"postie/pkg/httprequest"
+)
+
+// Dir holds the saved sessions, relative to the project directory
+var Dir = filepath.Join(".postie", "sessions")
+
+// VariablePrefix starts the variables that refer to session values
+const VariablePrefix = "session."
+
+// namePattern is the syntax of session names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Session is the set of values captured by a login request
+type Session struct {
+	Name      string                 `json:"name"`
+	Values    map[string]interface{} `json:"values"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt *time.Time             `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the session's time to live has passed
+func (s *Session) Expired() bool {
+	return s.ExpiresAt != nil && time.Now().After(*s.ExpiresAt)
+}
+
+// Variables returns the session's values as session.<name>.<key> variables
+func (s *Session) Variables() map[string]interface{} {
+	variables := make(map[string]interface{}, len(s.Values))
+	for key, value := range s.Values {
+		variables[VariablePrefix+s.Name+"."+key] = value
+	}
+	return variables
+}
+
+// Directive parses a request's "# @session <name> [ttl]" directive. ttl is
+// a Go duration such as 30m or 12h; zero means the session doesn't expire.
+func Directive(request *httprequest.Request) (name string, ttl time.Duration, ok bool, err error) {
+	value, ok := request.Metadata[httprequest.DirectiveSession]
+	if !ok {
+		return "", 0, false, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 || !namePattern.MatchString(fields[0]) {
+		return "", 0, true, fmt.Errorf("invalid @%s value %q, e.g. # @%s api 30m", httprequest.DirectiveSession, value, httprequest.DirectiveSession)
+	}
+	if len(fields) == 2 {
+		if ttl, err = time.ParseDuration(fields[1]); err != nil || ttl < 0 {
+			return "", 0, true, fmt.Errorf("invalid @%s time to live %q, e.g. 30m or 12h", httprequest.DirectiveSession, fields[1])
+		}
+	}
+	return fields[0], ttl, true, nil
+}
+
+// referencePattern matches {{session.<name>.<key>}} references
+var referencePattern = regexp.MustCompile(`\{\{\s*session\.([A-Za-z0-9_-]+)\.`)
+
+// References returns the names of the sessions referenced in texts, in
+// order of first use
+func References(texts ...string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, match := range referencePattern.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	return names
+}
+
+// Store saves the sessions of one environment under .postie/sessions, so
+// a development token is never sent to production
+type Store struct {
+	root        string // Project directory
+	environment string
+}
+
+// NewStore returns the session store of an environment in a project directory
+func NewStore(dir, environment string) *Store {
+	return &Store{root: dir, environment: environment}
+}
+
+// Environment returns the name of the environment the store belongs to
+func (s *Store) Environment() string {
+	return s.environment
+}
+
+func (s *Store) dir() string {
+	environment := s.environment
+	if environment == "" {
+		environment = "default"
+	}
+	return filepath.Join(s.root, Dir, environment)
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir(), name+".json")
+}
+
+// Load reads a saved session. It returns nil if there is none.
+func (s *Store) Load(name string) (*Session, error) {
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read session %s: %w", name, err)
+	}
+
+	var saved Session
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", name, err)
+	}
+	saved.Name = name
+	return &saved, nil
+}
+
+// Save writes a session. Sessions hold credentials, so the files are
+// private to the user.
+func (s *Store) Save(saved *Session) error {
+	if !namePattern.MatchString(saved.Name) {
+		return fmt.Errorf("invalid session name %q", saved.Name)
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := os.MkdirAll(s.dir(), 0700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	if err := atomicfile.WriteFile(s.path(saved.Name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a saved session
+func (s *Store) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %s not found", name)
+		}
+		return fmt.Errorf("failed to delete session %s: %w", name, err)
+	}
+	return nil
+}
+
+// List returns the saved sessions, sorted by name
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		saved, err := s.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, saved)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Name < sessions[j].Name })
+	return sessions, nil
+}
+
+// FindLogin searches the project's .http files for the request declaring
+// "# @session <name>"
+func (s *Store) FindLogin(name string) (*httprequest.RequestsFile, *httprequest.Request, error) {
+	var files []string
+	err := filepath.WalkDir(s.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != s.root && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(strings.ToLower(path), ".http") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search for session %s: %w", name, err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil || !strings.Contains(string(content), "@"+httprequest.DirectiveSession) {
+			continue
+		}
+		requestsFile, err := httprequest.ParseFile(file, string(content))
+		if err != nil {
+			continue
+		}
+		for i := range requestsFile.Requests {
+			request := &requestsFile.Requests[i]
+			if declared, _, ok, _ := Directive(request); ok && declared == name {
+				return requestsFile, request, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no request declares # @%s %s", httprequest.DirectiveSession, name)
+}
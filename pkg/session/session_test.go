@@ -0,0 +1,103 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"postie/pkg/httprequest"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, "staging")
+
+	if saved, err := store.Load("api"); err != nil || saved != nil {
+		t.Fatalf("Load of a missing session = %v, %v", saved, err)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	for _, saved := range []*Session{
+		{Name: "api", Values: map[string]interface{}{"token": "abc"}, CreatedAt: time.Now()},
+		{Name: "admin", Values: map[string]interface{}{}, CreatedAt: time.Now(), ExpiresAt: &expired},
+	} {
+		if err := store.Save(saved); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, Dir, "staging", "api.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private session file, got %v, %v", info, err)
+	}
+	if saved, _ := NewStore(dir, "production").Load("api"); saved != nil {
+		t.Error("Sessions must not be shared between environments")
+	}
+
+	saved, err := store.Load("api")
+	if err != nil || saved.Expired() {
+		t.Fatalf("Load = %+v, %v", saved, err)
+	}
+	if want := map[string]interface{}{"session.api.token": "abc"}; !reflect.DeepEqual(saved.Variables(), want) {
+		t.Errorf("Variables = %v, want %v", saved.Variables(), want)
+	}
+
+	sessions, err := store.List()
+	if err != nil || len(sessions) != 2 || sessions[0].Name != "admin" || !sessions[0].Expired() {
+		t.Errorf("List = %+v, %v", sessions, err)
+	}
+
+	if err := store.Delete("api"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := store.Delete("api"); err == nil {
+		t.Error("Expected an error deleting a missing session")
+	}
+}
+
+func TestDirectiveAndReferences(t *testing.T) {
+	tests := []struct {
+		value string
+		name  string
+		ttl   time.Duration
+		err   bool
+	}{
+		{"api", "api", 0, false},
+		{"api 30m", "api", 30 * time.Minute, false},
+		{"", "", 0, true},
+		{"api soon", "", 0, true},
+		{"a/b", "", 0, true},
+	}
+	for _, tt := range tests {
+		request := &httprequest.Request{Metadata: map[string]string{httprequest.DirectiveSession: tt.value}}
+		name, ttl, ok, err := Directive(request)
+		if !ok || (err != nil) != tt.err || name != tt.name || ttl != tt.ttl {
+			t.Errorf("Directive(%q) = %q, %v, %v, %v", tt.value, name, ttl, ok, err)
+		}
+	}
+
+	got := References("Bearer {{session.api.token}}", "{{ session.admin.cookies }} {{session.api.id}} {{sessionless}}")
+	if want := []string{"api", "admin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("References = %v, want %v", got, want)
+	}
+}
+
+func TestFindLogin(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "auth"), 0755)
+	os.WriteFile(filepath.Join(dir, "auth", "login.http"), []byte("GET https://example.com/health\n\n###\n# @session api 1h\nPOST https://example.com/login\n"), 0644)
+
+	file, request, err := NewStore(dir, "dev").FindLogin("api")
+	if err != nil {
+		t.Fatalf("FindLogin failed: %v", err)
+	}
+	if request.Method != "POST" || len(file.Requests) != 2 {
+		t.Errorf("FindLogin found %s %v in a file of %d requests", request.Method, request.URL, len(file.Requests))
+	}
+
+	if _, _, err := NewStore(dir, "dev").FindLogin("other"); err == nil {
+		t.Error("Expected an error for an undeclared session")
+	}
+}
@@ -0,0 +1,113 @@
+// Package snippet renders requests as code in other languages and tools,
+// such as curl commands or Go net/http programs, using text templates.
+// Templates in .postie/snippets override the built-in ones and add
+// languages.
+package snippet
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"postie/pkg/httprequest"
+)
+
+// TemplatesDir holds user templates, one <language>.tmpl file per language
+var TemplatesDir = filepath.Join(".postie", "snippets")
+
+// templateExt is the file extension of snippet templates
+const templateExt = ".tmpl"
+
+//go:embed templates/*.tmpl
+var builtins embed.FS
+
+// Request is the data a snippet template renders
+type Request struct {
+	Name    string
+	Method  string
+	URL     string
+	Headers []httprequest.Header
+	Body    string
+}
+
+// Funcs are the functions available to snippet templates
+var Funcs = template.FuncMap{
+	"goquote":    strconv.Quote,
+	"strquote":   stringLiteral,
+	"shellquote": shellQuote,
+}
+
+// Languages returns the built-in languages and those with a template in dir
+func Languages(dir string) []string {
+	seen := make(map[string]bool)
+	add := func(names []string) {
+		for _, name := range names {
+			seen[strings.TrimSuffix(filepath.Base(name), templateExt)] = true
+		}
+	}
+	builtin, _ := builtins.ReadDir("templates")
+	for _, entry := range builtin {
+		add([]string{entry.Name()})
+	}
+	user, _ := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	add(user)
+
+	languages := make([]string, 0, len(seen))
+	for name := range seen {
+		languages = append(languages, name)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Render renders a request as a snippet in a language, using the language's
+// template in dir if there is one and the built-in template otherwise
+func Render(dir, language string, request *Request) (string, error) {
+	if language == "" || strings.ContainsAny(language, `/\`) {
+		return "", fmt.Errorf("invalid language %q", language)
+	}
+
+	name := language + templateExt
+	source, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		source, err = builtins.ReadFile("templates/" + name)
+		if err != nil {
+			return "", fmt.Errorf("unknown language %q (available: %s)", language, strings.Join(Languages(dir), ", "))
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(Funcs).Parse(string(source))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", language, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, request); err != nil {
+		return "", fmt.Errorf("failed to render %s snippet: %w", language, err)
+	}
+	return strings.TrimRight(out.String(), "\n") + "\n", nil
+}
+
+// stringLiteral quotes a string as a JSON string, which is also a valid
+// JavaScript and Python string literal
+func stringLiteral(s string) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
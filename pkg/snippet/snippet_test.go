@@ -0,0 +1,113 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"postie/pkg/httprequest"
+)
+
+var testRequest = &Request{
+	Name:   "Create user",
+	Method: "POST",
+	URL:    "https://api.example.com/users?q=a&b='c'",
+	Headers: []httprequest.Header{
+		{Name: "Content-Type", Value: "application/json"},
+		{Name: "Authorization", Value: "Bearer it's"},
+	},
+	Body: "{\"name\": \"Ada <3\"}\n",
+}
+
+func TestRenderBuiltins(t *testing.T) {
+	tests := map[string][]string{
+		"curl": {
+			`curl -X POST 'https://api.example.com/users?q=a&b='\''c'\'''`,
+			`  -H 'Authorization: Bearer it'\''s' \`,
+			`  --data-raw '{"name": "Ada <3"}`,
+		},
+		"go": {
+			`body := strings.NewReader("{\"name\": \"Ada <3\"}\n")`,
+			`http.NewRequest("POST", "https://api.example.com/users?q=a&b='c'", body)`,
+			`req.Header.Add("Authorization", "Bearer it's")`,
+		},
+		"python": {
+			`url = "https://api.example.com/users?q=a&b='c'"`,
+			`    "Content-Type": "application/json",`,
+			`data = "{\"name\": \"Ada <3\"}\n"`,
+			`requests.request("POST", url, headers=headers, data=data)`,
+		},
+		"js": {
+			`await fetch("https://api.example.com/users?q=a&b='c'", {`,
+			`    "Authorization": "Bearer it's",`,
+			`  body: "{\"name\": \"Ada <3\"}\n",`,
+		},
+	}
+
+	for lang, wants := range tests {
+		code, err := Render(t.TempDir(), lang, testRequest)
+		if err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s snippet is missing %q:\n%s", lang, want, code)
+			}
+		}
+	}
+}
+
+func TestRenderWithoutBody(t *testing.T) {
+	code, err := Render(t.TempDir(), "go", &Request{Method: "GET", URL: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, `"strings"`) || !strings.Contains(code, `"http://localhost", nil)`) {
+		t.Errorf("unexpected snippet:\n%s", code)
+	}
+
+	code, err = Render(t.TempDir(), "curl", &Request{Method: "GET", URL: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "curl -X GET 'http://localhost'\n" {
+		t.Errorf("unexpected snippet: %q", code)
+	}
+}
+
+func TestUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "httpie.tmpl"), []byte(`http {{.Method}} {{shellquote .URL}}{{range .Headers}} {{shellquote (printf "%s:%s" .Name .Value)}}{{end}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "curl.tmpl"), []byte(`curl -sS -X {{.Method}} {{shellquote .URL}}`), 0644)
+
+	want := []string{"curl", "go", "httpie", "js", "python"}
+	if got := Languages(dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages() = %v, want %v", got, want)
+	}
+
+	code, err := Render(dir, "httpie", &Request{Method: "GET", URL: "http://x", Headers: []httprequest.Header{{Name: "A", Value: "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "http GET 'http://x' 'A:b'\n" {
+		t.Errorf("httpie snippet = %q", code)
+	}
+
+	// User templates override the built-in ones
+	code, err = Render(dir, "curl", &Request{Method: "GET", URL: "http://x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "curl -sS -X GET 'http://x'\n" {
+		t.Errorf("curl snippet = %q", code)
+	}
+
+	if _, err := Render(dir, "cobol", testRequest); err == nil || !strings.Contains(err.Error(), "available: curl, go, httpie, js, python") {
+		t.Errorf("unexpected error for unknown language: %v", err)
+	}
+	if _, err := Render(dir, "../curl", testRequest); err == nil {
+		t.Error("expected an error for a language with a path")
+	}
+}
@@ -0,0 +1,185 @@
+package telemetry
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+	"postie/pkg/redact"
+)
+
+// Telemetry is an executor hook that traces each request and records its
+// metrics. Either the tracer or the metrics may be nil.
+type Telemetry struct {
+	Tracer  *Tracer
+	Metrics *Metrics
+
+	mu       sync.Mutex
+	redactor *redact.Redactor
+	run      *Span
+	active   map[*httprequest.Request]*Span
+}
+
+// New creates a telemetry hook
+func New(tracer *Tracer, metrics *Metrics) *Telemetry {
+	return &Telemetry{
+		Tracer:  tracer,
+		Metrics: metrics,
+		active:  make(map[*httprequest.Request]*Span),
+	}
+}
+
+// SetRedactor masks secret values in exported span attributes
+func (t *Telemetry) SetRedactor(redactor *redact.Redactor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.redactor = redactor
+}
+
+// StartRun starts the root span that request spans of a run belong to
+func (t *Telemetry) StartRun(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.run = &Span{
+		TraceID:    NewTraceID(),
+		SpanID:     NewSpanID(),
+		Name:       name,
+		Kind:       spanKindInternal,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+}
+
+// EndRun ends the run span and exports the run's spans
+func (t *Telemetry) EndRun() error {
+	t.mu.Lock()
+	run := t.run
+	t.run = nil
+	t.mu.Unlock()
+
+	if t.Tracer == nil {
+		return nil
+	}
+	if run != nil {
+		run.End = time.Now()
+		t.Tracer.Record(run)
+	}
+	return t.Tracer.Flush()
+}
+
+// BeforeRequest starts a span for the request and propagates it in a
+// traceparent header, unless the request sets one itself
+func (t *Telemetry) BeforeRequest(request *httprequest.Request) error {
+	if t.Tracer == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &Span{
+		SpanID: NewSpanID(),
+		Name:   request.Method,
+		Kind:   spanKindClient,
+		Start:  time.Now(),
+		Attributes: map[string]interface{}{
+			"http.request.method": request.Method,
+		},
+	}
+	if t.run != nil {
+		span.TraceID = t.run.TraceID
+		span.ParentSpanID = t.run.SpanID
+	} else {
+		span.TraceID = NewTraceID()
+	}
+
+	if request.URL != nil {
+		span.Attributes["url.full"] = t.redactor.Redact(request.URL.Raw)
+	}
+	if request.Name != "" {
+		span.Name = request.Name
+		span.Attributes["postie.request.name"] = request.Name
+	}
+
+	if existing, ok := headerValue(request, "traceparent"); ok {
+		// Join the trace chosen by the request
+		if traceID, parentID, ok := ParseTraceparent(existing); ok {
+			span.TraceID, span.ParentSpanID = traceID, parentID
+		}
+	} else {
+		request.Headers = append(request.Headers, httprequest.Header{
+			Name:  "traceparent",
+			Value: Traceparent(span.TraceID, span.SpanID),
+		})
+	}
+
+	t.active[request] = span
+	return nil
+}
+
+// AfterResponse finishes the request's span and records its metrics
+func (t *Telemetry) AfterResponse(result *executor.ExecutionResult) error {
+	failed := result.HasError() || result.StatusFailed() ||
+		(result.ScriptResult != nil && !result.ScriptResult.IsSuccess())
+
+	span := t.finish(result.Request)
+	if span != nil {
+		span.Attributes["http.response.status_code"] = result.StatusCode
+		if failed {
+			span.Error = result.Status
+		}
+		t.Tracer.Record(span)
+	}
+
+	t.observe(result.Request, result.StatusCode, result.Duration, failed)
+	return nil
+}
+
+// OnError finishes the request's span as failed and records the failure
+func (t *Telemetry) OnError(request *httprequest.Request, err error) {
+	span := t.finish(request)
+	if span != nil {
+		span.Error = t.redactor.Redact(err.Error())
+		t.Tracer.Record(span)
+	}
+
+	var duration time.Duration
+	if span != nil {
+		duration = span.End.Sub(span.Start)
+	}
+	t.observe(request, 0, duration, true)
+}
+
+// finish removes and ends the active span of a request
+func (t *Telemetry) finish(request *httprequest.Request) *Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span, ok := t.active[request]
+	if !ok {
+		return nil
+	}
+	delete(t.active, request)
+	span.End = time.Now()
+	return span
+}
+
+// observe records a request in the metrics
+func (t *Telemetry) observe(request *httprequest.Request, status int, duration time.Duration, failed bool) {
+	if t.Metrics == nil || request == nil {
+		return
+	}
+	t.Metrics.Observe(request.Name, request.Method, status, duration, failed)
+}
+
+// headerValue returns the first value of a request header
+func headerValue(request *httprequest.Request, name string) (string, bool) {
+	for _, header := range request.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the request duration histogram
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics counts executed requests and their durations
+type Metrics struct {
+	mu        sync.Mutex
+	requests  map[string]float64 // by label set
+	failures  map[string]float64
+	durations map[string]*histogram
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:  make(map[string]float64),
+		failures:  make(map[string]float64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// Observe records an executed request. status is the HTTP status code, or
+// 0 if the request failed without a response.
+func (m *Metrics) Observe(name, method string, status int, duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statusLabel := "error"
+	if status > 0 {
+		statusLabel = fmt.Sprint(status)
+	}
+
+	m.requests[labels("request", name, "method", method, "status", statusLabel)]++
+	if failed {
+		m.failures[labels("request", name, "method", method)]++
+	}
+
+	key := labels("request", name, "method", method)
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		m.durations[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	buf.WriteString("# HELP postie_requests_total Requests executed.\n")
+	buf.WriteString("# TYPE postie_requests_total counter\n")
+	for _, key := range sortedKeys(m.requests) {
+		fmt.Fprintf(&buf, "postie_requests_total{%s} %g\n", key, m.requests[key])
+	}
+
+	buf.WriteString("# HELP postie_request_failures_total Requests that failed, returned an error status or failed their tests.\n")
+	buf.WriteString("# TYPE postie_request_failures_total counter\n")
+	for _, key := range sortedKeys(m.failures) {
+		fmt.Fprintf(&buf, "postie_request_failures_total{%s} %g\n", key, m.failures[key])
+	}
+
+	buf.WriteString("# HELP postie_request_duration_seconds Request duration.\n")
+	buf.WriteString("# TYPE postie_request_duration_seconds histogram\n")
+	for _, key := range sortedKeys(m.durations) {
+		h := m.durations[key]
+		var cumulative uint64
+		for i, bound := range DurationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&buf, "postie_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", key, bound, cumulative)
+		}
+		fmt.Fprintf(&buf, "postie_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", key, h.count)
+		fmt.Fprintf(&buf, "postie_request_duration_seconds_sum{%s} %g\n", key, h.sum)
+		fmt.Fprintf(&buf, "postie_request_duration_seconds_count{%s} %d\n", key, h.count)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteText(w)
+}
+
+// Push sends the metrics to a Prometheus Pushgateway, such as
+// http://localhost:9091, under the "postie" job
+func (m *Metrics) Push(gateway string) error {
+	var buf bytes.Buffer
+	if err := m.WriteText(&buf); err != nil {
+		return err
+	}
+
+	url := strings.TrimRight(gateway, "/") + "/metrics/job/postie"
+	req, err := http.NewRequest(http.MethodPut, url, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to push metrics: %s from %s", resp.Status, url)
+	}
+	return nil
+}
+
+// labels formats name/value pairs as a Prometheus label set
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
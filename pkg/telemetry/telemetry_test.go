@@ -0,0 +1,129 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"postie/pkg/executor"
+	"postie/pkg/httprequest"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID := NewTraceID(), NewSpanID()
+
+	gotTrace, gotSpan, ok := ParseTraceparent(Traceparent(traceID, spanID))
+	if !ok || gotTrace != traceID || gotSpan != spanID {
+		t.Errorf("Expected %s/%s, got %s/%s (ok=%v)", traceID, spanID, gotTrace, gotSpan, ok)
+	}
+
+	for _, header := range []string{"", "00-abc-def-01", "00-" + strings.Repeat("z", 32) + "-" + spanID + "-01"} {
+		if _, _, ok := ParseTraceparent(header); ok {
+			t.Errorf("Expected %q to be rejected", header)
+		}
+	}
+}
+
+func TestTelemetryExportsSpans(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Expected /v1/traces, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	hook := New(NewTracer(server.URL), NewMetrics())
+	hook.StartRun("postie run api.http")
+
+	request := &httprequest.Request{Name: "Get Users", Method: "GET"}
+	if err := hook.BeforeRequest(request); err != nil {
+		t.Fatal(err)
+	}
+	header, ok := headerValue(request, "traceparent")
+	if !ok {
+		t.Fatal("Expected a traceparent header to be added")
+	}
+	traceID, _, _ := ParseTraceparent(header)
+
+	hook.AfterResponse(&executor.ExecutionResult{Request: request, StatusCode: 500, Status: "500 Internal Server Error"})
+	if err := hook.EndRun(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(payload)
+	for _, want := range []string{traceID, `"name":"Get Users"`, `"name":"postie run api.http"`, `"message":"500 Internal Server Error"`} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected exported spans to contain %s, got %s", want, data)
+		}
+	}
+}
+
+func TestTelemetryKeepsRequestTraceparent(t *testing.T) {
+	hook := New(NewTracer("http://localhost:4318"), nil)
+	existing := Traceparent(NewTraceID(), NewSpanID())
+	request := &httprequest.Request{
+		Method:  "GET",
+		Headers: []httprequest.Header{{Name: "Traceparent", Value: existing}},
+	}
+
+	hook.BeforeRequest(request)
+
+	if len(request.Headers) != 1 || request.Headers[0].Value != existing {
+		t.Errorf("Expected the request's traceparent to be kept, got %v", request.Headers)
+	}
+}
+
+func TestMetricsWriteText(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Observe("Get Users", "GET", 200, 30*time.Millisecond, false)
+	metrics.Observe("Get Users", "GET", 500, 2*time.Second, true)
+	metrics.Observe(`Say "hi"`, "POST", 0, 0, true)
+
+	var buf bytes.Buffer
+	if err := metrics.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+
+	for _, want := range []string{
+		`postie_requests_total{request="Get Users",method="GET",status="200"} 1`,
+		`postie_requests_total{request="Say \"hi\"",method="POST",status="error"} 1`,
+		`postie_request_failures_total{request="Get Users",method="GET"} 1`,
+		`postie_request_duration_seconds_bucket{request="Get Users",method="GET",le="0.05"} 1`,
+		`postie_request_duration_seconds_bucket{request="Get Users",method="GET",le="2.5"} 2`,
+		`postie_request_duration_seconds_count{request="Get Users",method="GET"} 2`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestMetricsPush(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics()
+	metrics.Observe("Ping", "GET", 204, time.Millisecond, false)
+	if err := metrics.Push(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if method != http.MethodPut || path != "/metrics/job/postie" {
+		t.Errorf("Expected PUT /metrics/job/postie, got %s %s", method, path)
+	}
+	if !strings.Contains(body, `status="204"`) {
+		t.Errorf("Expected pushed metrics, got %s", body)
+	}
+}
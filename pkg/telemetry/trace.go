@@ -0,0 +1,194 @@
+// Package telemetry exports request traces in the OpenTelemetry (OTLP/HTTP
+// JSON) format and request metrics in the Prometheus text format.
+package telemetry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds and status codes from the OTLP specification
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	statusOK    = 1
+	statusError = 2
+)
+
+// Span is a single timed operation in a trace
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	Error        string
+}
+
+// NewTraceID returns a random 16-byte trace ID in hex
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID returns a random 8-byte span ID in hex
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Traceparent formats a W3C traceparent header for a span
+func Traceparent(traceID, spanID string) string {
+	return fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+}
+
+// ParseTraceparent extracts the trace and parent span IDs from a W3C
+// traceparent header
+func ParseTraceparent(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Tracer collects spans and exports them to an OTLP/HTTP endpoint
+type Tracer struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewTracer creates a tracer exporting to endpoint, such as
+// http://localhost:4318 (the /v1/traces path is added if missing)
+func NewTracer(endpoint string) *Tracer {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &Tracer{
+		endpoint:    endpoint,
+		serviceName: "postie",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Record queues a finished span for export
+func (t *Tracer) Record(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+}
+
+// Flush exports the queued spans
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(t.otlpPayload(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export spans: %s from %s", resp.Status, t.endpoint)
+	}
+	return nil
+}
+
+// otlpPayload builds an OTLP ExportTraceServiceRequest in its JSON encoding
+func (t *Tracer) otlpPayload(spans []*Span) map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		entry := map[string]interface{}{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"name":              span.Name,
+			"kind":              span.Kind,
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"status":            map[string]interface{}{"code": statusOK},
+		}
+		if span.ParentSpanID != "" {
+			entry["parentSpanId"] = span.ParentSpanID
+		}
+		if span.Error != "" {
+			entry["status"] = map[string]interface{}{"code": statusError, "message": span.Error}
+		}
+		encoded = append(encoded, entry)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": t.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "postie"},
+						"spans": encoded,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpAttributes converts attributes to OTLP key/value pairs
+func otlpAttributes(attributes map[string]interface{}) []map[string]interface{} {
+	keys := sortedKeys(attributes)
+	encoded := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": value})
+	}
+	return encoded
+}
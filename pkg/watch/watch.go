@@ -0,0 +1,131 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultInterval is how often watched files are polled
+const DefaultInterval = 500 * time.Millisecond
+
+// Watcher polls a set of files for changes.
+// Polling keeps it dependency-free and works the same on every platform and
+// with editors that replace files on save.
+type Watcher struct {
+	interval time.Duration
+	files    map[string]fileState
+}
+
+// fileState is the last observed state of a watched file
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher creates a watcher that polls at the given interval
+func NewWatcher(interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Watcher{
+		interval: interval,
+		files:    make(map[string]fileState),
+	}
+}
+
+// SetFiles replaces the watched set. Files that were already watched keep
+// their last state so changes made in between are not lost.
+func (w *Watcher) SetFiles(paths ...string) {
+	files := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		path = filepath.Clean(path)
+		if state, ok := w.files[path]; ok {
+			files[path] = state
+		} else {
+			files[path] = stat(path)
+		}
+	}
+	w.files = files
+}
+
+// Files returns the watched paths in sorted order
+func (w *Watcher) Files() []string {
+	paths := make([]string, 0, len(w.files))
+	for path := range w.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Poll returns the files that changed since the last poll
+func (w *Watcher) Poll() []string {
+	var changed []string
+	for path, old := range w.files {
+		current := stat(path)
+		if current != old {
+			w.files[path] = current
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Wait blocks until at least one file changes or stop is closed.
+// Changes that arrive within one interval of each other are reported together,
+// since editors often write a file in several steps.
+func (w *Watcher) Wait(stop <-chan struct{}) ([]string, bool) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var changed []string
+	for {
+		select {
+		case <-stop:
+			return nil, false
+		case <-ticker.C:
+			more := w.Poll()
+			if len(more) > 0 {
+				changed = appendUnique(changed, more...)
+				continue
+			}
+			if len(changed) > 0 {
+				return changed, true
+			}
+		}
+	}
+}
+
+// stat returns the current state of a file
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// appendUnique appends paths that are not already in list
+func appendUnique(list []string, paths ...string) []string {
+	for _, path := range paths {
+		found := false
+		for _, existing := range list {
+			if existing == path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, path)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,51 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "requests.http")
+	missing := filepath.Join(dir, "missing.json")
+
+	if err := os.WriteFile(path, []byte("GET http://example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(10 * time.Millisecond)
+	w.SetFiles(path, missing)
+
+	if changed := w.Poll(); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+
+	if err := os.WriteFile(path, []byte("POST http://example.com\n\n{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(missing, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := w.Poll()
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 changes, got %v", changed)
+	}
+
+	if changed := w.Poll(); len(changed) != 0 {
+		t.Errorf("expected changes to be reported once, got %v", changed)
+	}
+}
+
+func TestWaitStops(t *testing.T) {
+	w := NewWatcher(10 * time.Millisecond)
+	stop := make(chan struct{})
+	close(stop)
+
+	if _, ok := w.Wait(stop); ok {
+		t.Error("expected Wait to return false after stop")
+	}
+}